@@ -0,0 +1,78 @@
+// Package main runs WeKnora as an MCP server over stdio, for MCP clients that start their servers
+// as subprocesses, such as IDE agents and desktop assistants.
+//
+// The server uses the same configuration and environment variables as the WeKnora server and
+// serves the knowledge bases of the tenant of the API key in WEKNORA_API_KEY, which can be a
+// tenant API key or a scoped API key. MCP clients that connect over HTTP can use the /mcp
+// endpoint of the WeKnora server instead.
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/Tencent/WeKnora/internal/container"
+	"github.com/Tencent/WeKnora/internal/mcpserver"
+	"github.com/Tencent/WeKnora/internal/middleware"
+	"github.com/Tencent/WeKnora/internal/runtime"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+)
+
+func main() {
+	// Stdout carries the MCP messages, everything else the services print goes to stderr
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	log.SetFlags(log.LstdFlags | log.Lmicroseconds | log.Lshortfile)
+	log.SetOutput(os.Stderr)
+	gormlogger.Default = gormlogger.New(log.New(os.Stderr, "\r\n", log.LstdFlags), gormlogger.Config{
+		SlowThreshold: 200 * time.Millisecond,
+		LogLevel:      gormlogger.Warn,
+	})
+
+	apiKey := os.Getenv("WEKNORA_API_KEY")
+	if apiKey == "" {
+		log.Fatal("WEKNORA_API_KEY is required")
+	}
+
+	// The asynchronous tasks enqueued by the services are run by the workers of the WeKnora server
+	c := container.BuildServiceContainer(runtime.GetContainer())
+
+	err := c.Invoke(func(
+		tenantService interfaces.TenantService,
+		apiKeyService interfaces.APIKeyService,
+		server *mcpserver.Server,
+		resourceCleaner interfaces.ResourceCleaner,
+	) error {
+		defer func() {
+			if errs := resourceCleaner.Cleanup(context.Background()); len(errs) > 0 {
+				log.Printf("Errors occurred during resource cleanup: %v", errs)
+			}
+		}()
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		tenantID, tenant, principal, err := middleware.AuthenticateAPIKey(ctx, tenantService, apiKeyService, apiKey)
+		if err != nil {
+			return err
+		}
+		ctx = middleware.WithAPIKeyContext(ctx, tenantID, tenant, principal)
+
+		log.Printf("MCP server is serving tenant %d over stdio", tenantID)
+		err = server.ServeStdio(ctx, os.Stdin, stdout, log.New(os.Stderr, "", log.LstdFlags))
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to run MCP server: %v", err)
+	}
+}
@@ -23,7 +23,7 @@ export function formatStringDate(date: any) {
   );
 }
 export function kbFileTypeVerification(file: any, silent = false) {
-  let validTypes = ["pdf", "txt", "md", "docx", "doc", "jpg", "jpeg", "png", "csv", "xlsx", "xls"];
+  let validTypes = ["pdf", "txt", "md", "docx", "doc", "jpg", "jpeg", "png", "csv", "tsv", "xlsx", "xls", "json", "jsonl", "html", "htm"];
   let type = file.name.substring(file.name.lastIndexOf(".") + 1);
   if (!validTypes.includes(type)) {
     if (!silent) {
@@ -33,6 +33,8 @@ type AgentEngine struct {
 	contextManager       interfaces.ContextManager // Context manager for writing agent conversation to LLM context
 	sessionID            string                    // Session ID for context management
 	systemPromptTemplate string                    // System prompt template (optional, uses default if empty)
+	// Approvals of the tool calls requiring approval (optional)
+	toolApprovalService interfaces.ToolApprovalService
 }
 
 // listToolNames returns tool.function names for logging
@@ -55,6 +57,7 @@ func NewAgentEngine(
 	contextManager interfaces.ContextManager,
 	sessionID string,
 	systemPromptTemplate string,
+	toolApprovalService interfaces.ToolApprovalService,
 ) *AgentEngine {
 	if eventBus == nil {
 		eventBus = event.NewEventBus()
@@ -69,6 +72,7 @@ func NewAgentEngine(
 		contextManager:       contextManager,
 		sessionID:            sessionID,
 		systemPromptTemplate: systemPromptTemplate,
+		toolApprovalService:  toolApprovalService,
 	}
 }
 
@@ -90,6 +94,11 @@ func (e *AgentEngine) Execute(
 		"context_msgs": len(llmContext),
 	})
 
+	// Tools run on behalf of this message, including the tools of the sub-agents
+	if messageID != "" {
+		ctx = context.WithValue(ctx, types.MessageIDContextKey, messageID)
+	}
+
 	// Initialize state
 	state := &types.AgentState{
 		RoundSteps:    []types.AgentStep{},
@@ -109,7 +118,7 @@ func (e *AgentEngine) Execute(
 	logger.Debugf(ctx, "[Agent] SystemPrompt (stream)\n----\n%s\n----", systemPrompt)
 
 	// Initialize messages with history
-	messages := e.buildMessagesWithLLMContext(systemPrompt, query, llmContext)
+	messages := e.buildMessagesWithLLMContext(ctx, systemPrompt, query, llmContext)
 	logger.Infof(ctx, "[Agent] Total messages for LLM: %d (system: 1, history: %d, user query: 1)",
 		len(messages), len(llmContext))
 
@@ -304,7 +313,8 @@ func (e *AgentEngine) executeLoop(
 					"tool_call_id": tc.ID,
 					"tool_index":   fmt.Sprintf("%d/%d", i+1, len(response.ToolCalls)),
 				})
-				result, err := e.toolRegistry.ExecuteTool(ctx, tc.Function.Name, args)
+				toolCtx := context.WithValue(ctx, types.ToolCallIDContextKey, tc.ID)
+				result, err := e.executeToolCall(toolCtx, tc, args, state.CurrentRound, sessionID)
 				duration := time.Since(toolCallStartTime).Milliseconds()
 				logger.Infof(ctx, "[Agent][Round-%d][Tool-%d/%d] Tool execution completed in %dms",
 					state.CurrentRound+1, i+1, len(response.ToolCalls), duration)
@@ -495,6 +505,10 @@ func (e *AgentEngine) buildToolsForLLM() []chat.Tool {
 	functionDefs := e.toolRegistry.GetFunctionDefinitions()
 	tools := make([]chat.Tool, 0, len(functionDefs))
 	for _, def := range functionDefs {
+		// Denied tools are hidden from the LLM
+		if e.config.ToolPolicy(def.Name) == types.ToolPolicyDeny {
+			continue
+		}
 		tools = append(tools, chat.Tool{
 			Type: "function",
 			Function: chat.FunctionDef{
@@ -508,6 +522,99 @@ func (e *AgentEngine) buildToolsForLLM() []chat.Tool {
 	return tools
 }
 
+// executeToolCall runs a tool call according to the policy of its tool.
+// Calls of denied tools are rejected, calls of tools requiring approval wait for a person to approve them
+// and are skipped when rejected or not decided in time.
+func (e *AgentEngine) executeToolCall(
+	ctx context.Context,
+	tc types.LLMToolCall,
+	args map[string]any,
+	iteration int,
+	sessionID string,
+) (*types.ToolResult, error) {
+	switch e.config.ToolPolicy(tc.Function.Name) {
+	case types.ToolPolicyDeny:
+		logger.Warnf(ctx, "[Agent] Tool %s is denied by the tool policy", tc.Function.Name)
+		return &types.ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("Tool %s is not allowed by the tool policy", tc.Function.Name),
+		}, nil
+	case types.ToolPolicyRequireApproval:
+		approval, err := e.waitForToolApproval(ctx, tc, args, iteration, sessionID)
+		if err != nil {
+			return &types.ToolResult{Success: false, Error: err.Error()}, err
+		}
+		switch approval.Status {
+		case types.ToolApprovalApproved:
+			logger.Infof(ctx, "[Agent] Tool call %s approved", tc.ID)
+		case types.ToolApprovalRejected:
+			message := "The user rejected this tool call, do not retry it"
+			if approval.Reason != "" {
+				message += ": " + approval.Reason
+			}
+			return &types.ToolResult{Success: false, Error: message}, nil
+		default:
+			return &types.ToolResult{
+				Success: false,
+				Error:   "Nobody approved this tool call in time, it was skipped",
+			}, nil
+		}
+	}
+	return e.toolRegistry.ExecuteTool(ctx, tc.Function.Name, args)
+}
+
+// waitForToolApproval persists a tool call requiring approval, asks the user to approve it
+// through the EventBus and waits for the decision
+func (e *AgentEngine) waitForToolApproval(
+	ctx context.Context,
+	tc types.LLMToolCall,
+	args map[string]any,
+	iteration int,
+	sessionID string,
+) (*types.ToolApproval, error) {
+	if e.toolApprovalService == nil {
+		return nil, fmt.Errorf("tool %s requires approval, which is not available here", tc.Function.Name)
+	}
+	if unavailable, _ := ctx.Value(types.ToolApprovalUnavailableContextKey).(bool); unavailable {
+		return nil, fmt.Errorf("tool %s requires approval, which is not available through this API, "+
+			"use the agent chat API to approve its calls", tc.Function.Name)
+	}
+	messageID, _ := ctx.Value(types.MessageIDContextKey).(string)
+	approval, err := e.toolApprovalService.CreateToolApproval(ctx, &types.ToolApproval{
+		SessionID:  sessionID,
+		MessageID:  messageID,
+		ToolCallID: tc.ID,
+		ToolName:   tc.Function.Name,
+		Arguments:  types.JSON(tc.Function.Arguments),
+	}, time.Duration(e.config.ToolApprovalTimeout)*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request approval of tool %s: %w", tc.Function.Name, err)
+	}
+
+	e.eventBus.Emit(ctx, event.Event{
+		ID:        tc.ID + "-tool-approval",
+		Type:      event.EventAgentToolApprovalRequired,
+		SessionID: sessionID,
+		Data: event.AgentToolApprovalData{
+			ApprovalID: approval.ID,
+			ToolCallID: tc.ID,
+			ToolName:   tc.Function.Name,
+			Arguments:  args,
+			ExpiresAt:  approval.ExpiresAt,
+			Iteration:  iteration,
+		},
+	})
+	logger.Infof(ctx, "[Agent] Waiting for approval %s of tool call %s", approval.ID, tc.ID)
+	common.PipelineInfo(ctx, "Agent", "tool_approval_wait", map[string]interface{}{
+		"iteration":    iteration,
+		"tool":         tc.Function.Name,
+		"tool_call_id": tc.ID,
+		"approval_id":  approval.ID,
+	})
+
+	return e.toolApprovalService.WaitForToolApproval(ctx, approval.ID)
+}
+
 // appendToolResults adds tool results to the message history following OpenAI's tool calling format
 // Also writes these messages to the context manager for persistence
 func (e *AgentEngine) appendToolResults(
@@ -784,7 +891,7 @@ func (e *AgentEngine) streamFinalAnswerToEventBus(
 
 	messages := []chat.Message{
 		{Role: "system", Content: systemPrompt},
-		{Role: "user", Content: query},
+		{Role: "user", Content: query, Images: e.config.QueryImages},
 	}
 
 	// Add all tool call results as context
@@ -874,9 +981,16 @@ func countTotalToolCalls(steps []types.AgentStep) int {
 
 // buildMessagesWithLLMContext builds the message array with LLM context
 func (e *AgentEngine) buildMessagesWithLLMContext(
+	ctx context.Context,
 	systemPrompt, currentQuery string,
 	llmContext []chat.Message,
 ) []chat.Message {
+	// Recalled memories of the user carry over what they told the agent in earlier sessions
+	if len(e.config.Memories) > 0 {
+		systemPrompt += formatUserMemories(e.config.Memories)
+		logger.Infof(ctx, "Added %d user memories to system prompt", len(e.config.Memories))
+	}
+
 	messages := []chat.Message{
 		{Role: "system", Content: systemPrompt},
 	}
@@ -890,12 +1004,13 @@ func (e *AgentEngine) buildMessagesWithLLMContext(
 				messages = append(messages, msg)
 			}
 		}
-		logger.Infof(context.Background(), "Added %d history messages to context", len(llmContext))
+		logger.Infof(ctx, "Added %d history messages to context", len(llmContext))
 	}
 
 	messages = append(messages, chat.Message{
 		Role:    "user",
 		Content: currentQuery,
+		Images:  e.config.QueryImages,
 	})
 
 	return messages
@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeToolApprovalService decides every approval with the given status right away
+type fakeToolApprovalService struct {
+	status  types.ToolApprovalStatus
+	created []*types.ToolApproval
+}
+
+func (s *fakeToolApprovalService) CreateToolApproval(
+	ctx context.Context, approval *types.ToolApproval, timeout time.Duration,
+) (*types.ToolApproval, error) {
+	approval.ID = "approval1"
+	s.created = append(s.created, approval)
+	return approval, nil
+}
+
+func (s *fakeToolApprovalService) WaitForToolApproval(ctx context.Context, id string) (*types.ToolApproval, error) {
+	return &types.ToolApproval{ID: id, Status: s.status}, nil
+}
+
+func (s *fakeToolApprovalService) DecideToolApproval(
+	ctx context.Context, sessionID string, id string, req *types.ToolApprovalDecisionRequest,
+) (*types.ToolApproval, error) {
+	return nil, nil
+}
+
+func (s *fakeToolApprovalService) ListToolApprovals(
+	ctx context.Context, sessionID string, status types.ToolApprovalStatus,
+) ([]*types.ToolApproval, error) {
+	return nil, nil
+}
+
+func TestExecuteToolCallApprovalUnavailable(t *testing.T) {
+	approvals := &fakeToolApprovalService{status: types.ToolApprovalApproved}
+	engine := &AgentEngine{
+		config: &types.AgentConfig{
+			ToolPolicies: map[string]types.ToolPolicy{"mcp.*": types.ToolPolicyRequireApproval},
+		},
+		toolApprovalService: approvals,
+	}
+	tc := types.LLMToolCall{ID: "call1", Function: types.FunctionCall{Name: "mcp.tickets.create"}}
+	ctx := context.WithValue(context.Background(), types.ToolApprovalUnavailableContextKey, true)
+
+	result, err := engine.executeToolCall(ctx, tc, nil, 0, "session1")
+	require.Error(t, err)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "requires approval")
+	// The call fails without waiting for a decision nobody can make
+	assert.Empty(t, approvals.created)
+}
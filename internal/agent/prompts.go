@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/Tencent/WeKnora/internal/types"
 )
 
 // formatFileSize formats file size in human-readable format
@@ -227,6 +229,25 @@ func formatSelectedDocuments(docs []*SelectedDocumentInfo) string {
 	return builder.String()
 }
 
+// formatUserMemories formats the memories of the user recalled from earlier sessions
+func formatUserMemories(memories []*types.UserMemory) string {
+	if len(memories) == 0 {
+		return ""
+	}
+
+	var builder strings.Builder
+	builder.WriteString("\n### User Memory\n")
+	builder.WriteString("Facts and preferences the user shared in earlier sessions. ")
+	builder.WriteString("Use them to tailor your answer without asking for them again, ")
+	builder.WriteString("but what the user says in this conversation takes precedence.\n\n")
+	for _, memory := range memories {
+		builder.WriteString(fmt.Sprintf("- [%s] %s\n", memory.Category, memory.Content))
+	}
+	builder.WriteString("\n")
+
+	return builder.String()
+}
+
 // renderPromptPlaceholdersWithStatus renders placeholders including web search status
 // Supported placeholders:
 //   - {{knowledge_bases}}
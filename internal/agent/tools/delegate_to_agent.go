@@ -0,0 +1,147 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// SubAgentRunner runs the sub-agent of a profile on a task and returns its final state.
+// The events of the sub-agent are nested under the tool call parentToolCallID of the delegating agent.
+type SubAgentRunner func(
+	ctx context.Context,
+	profile *types.AgentProfile,
+	task string,
+	parentToolCallID string,
+) (*types.AgentState, error)
+
+// DelegateToAgentTool delegates a task to a named sub-agent, which runs its own ReAct loop
+// with its own tools, knowledge bases and iteration budget, and returns its final answer
+type DelegateToAgentTool struct {
+	BaseTool
+	profiles []types.AgentProfile
+	run      SubAgentRunner
+}
+
+// NewDelegateToAgentTool creates a new delegate_to_agent tool for the given profiles
+func NewDelegateToAgentTool(profiles []types.AgentProfile, run SubAgentRunner) *DelegateToAgentTool {
+	var agents strings.Builder
+	for _, profile := range profiles {
+		fmt.Fprintf(&agents, "- **%s**: %s\n", profile.Name, profile.Description)
+	}
+
+	description := `Delegate a task to a specialized sub-agent and get its answer.
+
+## Available Agents
+
+` + agents.String() + `
+## When to Use
+
+Use this tool when:
+- The question touches a domain covered by one of the agents above
+- A cross-domain question needs answers from several agents, delegate one task to each agent and combine their answers
+
+Do not use when:
+- You can answer from your own knowledge bases and tools
+
+
+## Notes
+
+- The sub-agent does not see this conversation, the task must be self-contained and include all the context it needs
+- The sub-agent searches its own knowledge bases, its answer is returned as the tool output
+- Delegate independent tasks to different agents instead of asking one agent several unrelated questions`
+
+	return &DelegateToAgentTool{
+		BaseTool: NewBaseTool("delegate_to_agent", description),
+		profiles: profiles,
+		run:      run,
+	}
+}
+
+// Parameters returns the JSON schema for the tool's parameters
+func (t *DelegateToAgentTool) Parameters() map[string]interface{} {
+	names := make([]string, 0, len(t.profiles))
+	for _, profile := range t.profiles {
+		names = append(names, profile.Name)
+	}
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"agent": map[string]interface{}{
+				"type":        "string",
+				"description": "Name of the agent to delegate the task to",
+				"enum":        names,
+			},
+			"task": map[string]interface{}{
+				"type":        "string",
+				"description": "Self-contained task or question for the agent, including the context it needs",
+			},
+		},
+		"required": []string{"agent", "task"},
+	}
+}
+
+// Execute runs the sub-agent on the task and returns its final answer
+func (t *DelegateToAgentTool) Execute(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	name, _ := args["agent"].(string)
+	task, _ := args["task"].(string)
+	task = strings.TrimSpace(task)
+	if name == "" || task == "" {
+		return &types.ToolResult{
+			Success: false,
+			Error:   "agent and task are required",
+		}, fmt.Errorf("agent and task are required")
+	}
+
+	var profile *types.AgentProfile
+	for i := range t.profiles {
+		if t.profiles[i].Name == name {
+			profile = &t.profiles[i]
+			break
+		}
+	}
+	if profile == nil {
+		return &types.ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("unknown agent: %s", name),
+		}, fmt.Errorf("unknown agent: %s", name)
+	}
+
+	parentToolCallID, _ := ctx.Value(types.ToolCallIDContextKey).(string)
+	logger.Infof(ctx, "[Tool][DelegateToAgent] Delegating to agent %s, tool call: %s", name, parentToolCallID)
+
+	state, err := t.run(ctx, profile, task, parentToolCallID)
+	if err != nil {
+		logger.Errorf(ctx, "[Tool][DelegateToAgent] Agent %s failed: %v", name, err)
+		return &types.ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("agent %s failed: %v", name, err),
+		}, err
+	}
+
+	output := fmt.Sprintf("=== Answer from agent %s ===\n\n%s\n", name, state.FinalAnswer)
+	return &types.ToolResult{
+		Success: true,
+		Output:  output,
+		Data: map[string]interface{}{
+			"display_type": "delegation",
+			"agent_name":   name,
+			"task":         task,
+			"answer":       state.FinalAnswer,
+			"rounds":       state.CurrentRound,
+			"tool_calls":   countToolCalls(state.RoundSteps),
+		},
+	}, nil
+}
+
+// countToolCalls counts the tool calls made in the steps of an agent
+func countToolCalls(steps []types.AgentStep) int {
+	count := 0
+	for _, step := range steps {
+		count += len(step.ToolCalls)
+	}
+	return count
+}
@@ -35,7 +35,8 @@ type KnowledgeSearchTool struct {
 	knowledgeService     interfaces.KnowledgeService
 	chunkService         interfaces.ChunkService
 	tenantID             uint64
-	searchTargets        types.SearchTargets // Pre-computed unified search targets
+	searchTargets        types.SearchTargets    // Pre-computed unified search targets
+	filter               *types.RetrievalFilter // Filter of the request on the searched documents
 	rerankModel          rerank.Reranker
 	chatModel            chat.Chat      // Optional chat model for LLM-based reranking
 	config               *config.Config // Global config for fallback values
@@ -48,6 +49,7 @@ func NewKnowledgeSearchTool(
 	chunkService interfaces.ChunkService,
 	tenantID uint64,
 	searchTargets types.SearchTargets,
+	filter *types.RetrievalFilter,
 	rerankModel rerank.Reranker,
 	chatModel chat.Chat,
 	cfg *config.Config,
@@ -103,6 +105,12 @@ Avoid:
 - queries (required): 1–5 semantic questions or conceptual statements.
   These should reflect the meaning or topic you want embeddings to capture.
 - knowledge_base_ids (optional): limit the search scope.
+- filter (optional): only search documents whose tags, file type, creation time or metadata match.
+  A condition is {"field", "op", "value"} with ops eq, in, range (gt/gte/lt/lte) and exists,
+  fields tag_id, tag (tag name), file_type, created_at and metadata.<key>;
+  combine conditions with {"and": [...]}, {"or": [...]} and {"not": {...}}.
+  Example: {"and": [{"field": "tag", "op": "eq", "value": "HR"},
+  {"field": "created_at", "op": "range", "gte": "2024-01-01"}]}
 
 ## Output
 Returns chunks ranked by semantic similarity, reranked when applicable.  
@@ -116,6 +124,7 @@ Results represent conceptual relevance, not literal keyword overlap.
 		chunkService:         chunkService,
 		tenantID:             tenantID,
 		searchTargets:        searchTargets,
+		filter:               filter,
 		rerankModel:          rerankModel,
 		chatModel:            chatModel,
 		config:               cfg,
@@ -145,6 +154,10 @@ func (t *KnowledgeSearchTool) Parameters() map[string]interface{} {
 				"minItems": 0,
 				"maxItems": 10,
 			},
+			"filter": map[string]interface{}{
+				"type":        "object",
+				"description": "Optional: filter on the tags, file type, creation time or metadata of the documents",
+			},
 		},
 		"required": []string{"queries"},
 	}
@@ -219,6 +232,15 @@ func (t *KnowledgeSearchTool) Execute(ctx context.Context, args map[string]inter
 
 	logger.Infof(ctx, "[Tool][KnowledgeSearch] Queries: %v", queries)
 
+	filter, err := t.parseFilter(args["filter"])
+	if err != nil {
+		logger.Errorf(ctx, "[Tool][KnowledgeSearch] Invalid filter: %v", err)
+		return &types.ToolResult{
+			Success: false,
+			Error:   fmt.Sprintf("invalid filter: %v", err),
+		}, err
+	}
+
 	// Get search parameters from tenant conversation config, fallback to global config
 	var topK int
 	var vectorThreshold, keywordThreshold, minScore float64
@@ -280,13 +302,19 @@ func (t *KnowledgeSearchTool) Execute(ctx context.Context, args map[string]inter
 		len(searchTargets))
 	kbTypeMap := t.getKnowledgeBaseTypes(ctx, kbIDs)
 
-	allResults := t.concurrentSearchByTargets(ctx, queries, searchTargets,
+	allResults := t.concurrentSearchByTargets(ctx, queries, searchTargets, filter,
 		topK, vectorThreshold, keywordThreshold, kbTypeMap)
 	logger.Infof(ctx, "[Tool][KnowledgeSearch] Concurrent search completed: %d raw results", len(allResults))
 
-	// Note: HybridSearch now uses RRF (Reciprocal Rank Fusion) which produces normalized scores
-	// RRF scores are in range [0, ~0.033] (max when rank=1 on both sides: 2/(60+1))
-	// Threshold filtering is already done inside HybridSearch before RRF, so we skip it here
+	// The scale of the fused scores depends on the fusion mode of each knowledge base
+	// (RRF scores are in range [0, ~0.033], weighted and max scores in [0, 1]),
+	// so they are normalized per knowledge base before the results of several knowledge bases are merged.
+	// Threshold filtering is already done inside HybridSearch before fusion, so we skip it here
+	searchutil.NormalizeScoresByGroup(allResults,
+		func(r *searchResultWithMeta) string { return r.KnowledgeBaseID },
+		func(r *searchResultWithMeta) float64 { return r.Score },
+		func(r *searchResultWithMeta, score float64) { r.Score = score },
+	)
 
 	// Deduplicate before reranking to reduce processing overhead
 	deduplicatedBeforeRerank := t.deduplicateResults(allResults)
@@ -367,9 +395,8 @@ func (t *KnowledgeSearchTool) Execute(ctx context.Context, args map[string]inter
 		}
 	}
 
-	// Note: minScore filter is skipped because HybridSearch now uses RRF scores
-	// RRF scores are in range [0, ~0.033], not [0, 1], so old thresholds don't apply
-	// Threshold filtering is already done inside HybridSearch before RRF fusion
+	// Note: minScore filter is skipped because the scores are normalized per knowledge base,
+	// so old thresholds don't apply. Threshold filtering is already done inside HybridSearch before fusion
 
 	// Final deduplication after rerank (in case rerank changed scores/order but duplicates remain)
 	logger.Debugf(ctx, "[Tool][KnowledgeSearch] Final deduplication after rerank...")
@@ -430,12 +457,35 @@ func (t *KnowledgeSearchTool) getKnowledgeBaseTypes(ctx context.Context, kbIDs [
 	return kbTypeMap
 }
 
+// parseFilter parses the filter argument, the filter of the request always applies on top of it
+func (t *KnowledgeSearchTool) parseFilter(raw interface{}) (*types.RetrievalFilter, error) {
+	if raw == nil {
+		return t.filter, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var filter types.RetrievalFilter
+	if err := json.Unmarshal(data, &filter); err != nil {
+		return nil, err
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	if t.filter == nil {
+		return &filter, nil
+	}
+	return &types.RetrievalFilter{And: []*types.RetrievalFilter{t.filter, &filter}}, nil
+}
+
 // concurrentSearchByTargets executes hybrid search using pre-computed search targets
 // This avoids duplicate searches when a knowledge file is already covered by its KB's full search
 func (t *KnowledgeSearchTool) concurrentSearchByTargets(
 	ctx context.Context,
 	queries []string,
 	searchTargets types.SearchTargets,
+	filter *types.RetrievalFilter,
 	topK int,
 	vectorThreshold, keywordThreshold float64,
 	kbTypeMap map[string]string,
@@ -457,6 +507,7 @@ func (t *KnowledgeSearchTool) concurrentSearchByTargets(
 					MatchCount:       topK,
 					VectorThreshold:  vectorThreshold,
 					KeywordThreshold: keywordThreshold,
+					Filter:           filter,
 				}
 
 				// If target has specific knowledge IDs, add them to search params
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"gorm.io/gorm"
+)
+
+// auditRepository stores the append-only audit log
+type auditRepository struct {
+	db *gorm.DB
+}
+
+// NewAuditRepository creates a new audit repository
+func NewAuditRepository(db *gorm.DB) interfaces.AuditRepository {
+	return &auditRepository{db: db}
+}
+
+// Create appends a record to the audit log
+func (r *auditRepository) Create(ctx context.Context, entry *types.AuditLog) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+// filter restricts a query to the audit logs of a tenant matching a filter
+func (r *auditRepository) filter(ctx context.Context, tenantID uint64, filter *types.AuditLogFilter) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&types.AuditLog{}).Where("tenant_id = ?", tenantID)
+	if filter == nil {
+		return query
+	}
+	if filter.ActorID != "" {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.ResourceType != "" {
+		query = query.Where("resource_type = ?", filter.ResourceType)
+	}
+	if filter.ResourceID != "" {
+		query = query.Where("resource_id = ?", filter.ResourceID)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.StartTime != nil {
+		query = query.Where("created_at >= ?", *filter.StartTime)
+	}
+	if filter.EndTime != nil {
+		query = query.Where("created_at < ?", *filter.EndTime)
+	}
+	return query
+}
+
+// List lists the audit logs of a tenant, newest first
+func (r *auditRepository) List(
+	ctx context.Context, tenantID uint64, filter *types.AuditLogFilter, page *types.Pagination,
+) ([]*types.AuditLog, int64, error) {
+	var total int64
+	if err := r.filter(ctx, tenantID, filter).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var entries []*types.AuditLog
+	if err := r.filter(ctx, tenantID, filter).
+		Order("id DESC").
+		Offset(page.Offset()).
+		Limit(page.Limit()).
+		Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// ListAfter lists up to limit audit logs of a tenant with an ID greater than afterID, oldest first
+func (r *auditRepository) ListAfter(
+	ctx context.Context, tenantID uint64, filter *types.AuditLogFilter, afterID uint64, limit int,
+) ([]*types.AuditLog, error) {
+	var entries []*types.AuditLog
+	if err := r.filter(ctx, tenantID, filter).
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// evaluationRepository implements persistence for evaluation tasks, question results and datasets
+type evaluationRepository struct {
+	db *gorm.DB
+}
+
+// NewEvaluationRepository creates a new evaluation repository
+func NewEvaluationRepository(db *gorm.DB) interfaces.EvaluationRepository {
+	return &evaluationRepository{db: db}
+}
+
+// CreateTask creates an evaluation task
+func (r *evaluationRepository) CreateTask(ctx context.Context, task *types.EvaluationTask) error {
+	return r.db.WithContext(ctx).Create(task).Error
+}
+
+// UpdateTask saves an evaluation task
+func (r *evaluationRepository) UpdateTask(ctx context.Context, task *types.EvaluationTask) error {
+	return r.db.WithContext(ctx).Save(task).Error
+}
+
+// GetTask retrieves an evaluation task of a tenant by ID
+func (r *evaluationRepository) GetTask(ctx context.Context, tenantID uint64, id string) (*types.EvaluationTask, error) {
+	var task types.EvaluationTask
+	if err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND id = ?", tenantID, id).
+		First(&task).Error; err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// ListTasks lists the evaluation tasks of a tenant, newest first
+func (r *evaluationRepository) ListTasks(ctx context.Context,
+	tenantID uint64, page *types.Pagination,
+) ([]*types.EvaluationTask, int64, error) {
+	query := r.db.WithContext(ctx).Model(&types.EvaluationTask{}).Where("tenant_id = ?", tenantID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var tasks []*types.EvaluationTask
+	if err := query.Order("created_at DESC").
+		Offset(page.Offset()).
+		Limit(page.GetPageSize()).
+		Find(&tasks).Error; err != nil {
+		return nil, 0, err
+	}
+	return tasks, total, nil
+}
+
+// SaveQuestionResult saves the result of one question, replacing a previous result of the same question
+func (r *evaluationRepository) SaveQuestionResult(ctx context.Context, result *types.EvaluationQuestionResult) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "task_id"}, {Name: "question_index"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"qid", "question", "expected_answer", "relevant_passage_ids", "search_results",
+			"rerank_results", "generated_answer", "err_msg", "metric", "created_at",
+		}),
+	}).Create(result).Error
+}
+
+// GetQuestionResult retrieves the result of one question of a task
+func (r *evaluationRepository) GetQuestionResult(ctx context.Context,
+	taskID string, questionIndex int,
+) (*types.EvaluationQuestionResult, error) {
+	var result types.EvaluationQuestionResult
+	if err := r.db.WithContext(ctx).
+		Where("task_id = ? AND question_index = ?", taskID, questionIndex).
+		First(&result).Error; err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListQuestionResults lists the question results of a task ordered by question index
+func (r *evaluationRepository) ListQuestionResults(ctx context.Context,
+	taskID string, page *types.Pagination,
+) ([]*types.EvaluationQuestionResult, int64, error) {
+	query := r.db.WithContext(ctx).Model(&types.EvaluationQuestionResult{}).Where("task_id = ?", taskID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var results []*types.EvaluationQuestionResult
+	if err := query.Order("question_index ASC").
+		Offset(page.Offset()).
+		Limit(page.GetPageSize()).
+		Find(&results).Error; err != nil {
+		return nil, 0, err
+	}
+	return results, total, nil
+}
+
+// ListQuestionMetrics lists the question index, question and metric of every answered question of a task
+func (r *evaluationRepository) ListQuestionMetrics(ctx context.Context,
+	taskID string,
+) ([]*types.EvaluationQuestionResult, error) {
+	var results []*types.EvaluationQuestionResult
+	if err := r.db.WithContext(ctx).
+		Select("question_index", "question", "metric").
+		Where("task_id = ?", taskID).
+		Order("question_index ASC").
+		Find(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// CreateComparison creates an evaluation comparison
+func (r *evaluationRepository) CreateComparison(ctx context.Context, comparison *types.EvaluationComparison) error {
+	return r.db.WithContext(ctx).Create(comparison).Error
+}
+
+// GetComparison retrieves an evaluation comparison of a tenant by ID
+func (r *evaluationRepository) GetComparison(ctx context.Context,
+	tenantID uint64, id string,
+) (*types.EvaluationComparison, error) {
+	var comparison types.EvaluationComparison
+	if err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND id = ?", tenantID, id).
+		First(&comparison).Error; err != nil {
+		return nil, err
+	}
+	return &comparison, nil
+}
+
+// ListComparisons lists the evaluation comparisons of a tenant, newest first
+func (r *evaluationRepository) ListComparisons(ctx context.Context,
+	tenantID uint64, page *types.Pagination,
+) ([]*types.EvaluationComparison, int64, error) {
+	query := r.db.WithContext(ctx).Model(&types.EvaluationComparison{}).Where("tenant_id = ?", tenantID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var comparisons []*types.EvaluationComparison
+	if err := query.Order("created_at DESC").
+		Offset(page.Offset()).
+		Limit(page.GetPageSize()).
+		Find(&comparisons).Error; err != nil {
+		return nil, 0, err
+	}
+	return comparisons, total, nil
+}
+
+// CreateDataset creates an evaluation dataset
+func (r *evaluationRepository) CreateDataset(ctx context.Context, dataset *types.EvaluationDataset) error {
+	return r.db.WithContext(ctx).Create(dataset).Error
+}
+
+// GetDataset retrieves an evaluation dataset of a tenant including its QA pairs
+func (r *evaluationRepository) GetDataset(ctx context.Context,
+	tenantID uint64, id string,
+) (*types.EvaluationDataset, error) {
+	var dataset types.EvaluationDataset
+	if err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND id = ?", tenantID, id).
+		First(&dataset).Error; err != nil {
+		return nil, err
+	}
+	return &dataset, nil
+}
+
+// ListDatasets lists the evaluation datasets of a tenant without their QA pairs
+func (r *evaluationRepository) ListDatasets(ctx context.Context, tenantID uint64) ([]*types.EvaluationDataset, error) {
+	var datasets []*types.EvaluationDataset
+	if err := r.db.WithContext(ctx).
+		Omit("qa_pairs").
+		Where("tenant_id = ?", tenantID).
+		Order("created_at DESC").
+		Find(&datasets).Error; err != nil {
+		return nil, err
+	}
+	return datasets, nil
+}
+
+// DeleteDataset deletes an evaluation dataset of a tenant
+func (r *evaluationRepository) DeleteDataset(ctx context.Context, tenantID uint64, id string) error {
+	return r.db.WithContext(ctx).
+		Where("tenant_id = ? AND id = ?", tenantID, id).
+		Delete(&types.EvaluationDataset{}).Error
+}
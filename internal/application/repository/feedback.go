@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"gorm.io/gorm"
+)
+
+// ErrFeedbackNotFound is returned when a user has no feedback on a message
+var ErrFeedbackNotFound = errors.New("feedback not found")
+
+// feedbackStatsColumns counts the feedback f joined with its references r
+const feedbackStatsColumns = `COUNT(DISTINCT f.id) AS total,
+	COUNT(DISTINCT f.id) FILTER (WHERE f.rating = 'up') AS up,
+	COUNT(DISTINCT f.id) FILTER (WHERE f.rating = 'down') AS down,
+	COUNT(r.id) AS reference_count,
+	COUNT(r.id) FILTER (WHERE r.irrelevant) AS irrelevant_references`
+
+// feedbackRepository stores the feedback on answers
+type feedbackRepository struct {
+	db *gorm.DB
+}
+
+// NewFeedbackRepository creates a new feedback repository
+func NewFeedbackRepository(db *gorm.DB) interfaces.FeedbackRepository {
+	return &feedbackRepository{db: db}
+}
+
+// Upsert creates the feedback of a user on a message or replaces it, with its references
+func (r *feedbackRepository) Upsert(ctx context.Context, feedback *types.MessageFeedback) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing types.MessageFeedback
+		err := tx.Where("tenant_id = ? AND message_id = ? AND user_id = ?",
+			feedback.TenantID, feedback.MessageID, feedback.UserID).First(&existing).Error
+		switch {
+		case err == nil:
+			feedback.ID = existing.ID
+			feedback.CreatedAt = existing.CreatedAt
+			if err := tx.Where("feedback_id = ?", existing.ID).Delete(&types.FeedbackReference{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Omit("References").Save(feedback).Error; err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := tx.Omit("References").Create(feedback).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		if len(feedback.References) == 0 {
+			return nil
+		}
+		for _, reference := range feedback.References {
+			reference.ID = 0
+			reference.FeedbackID = feedback.ID
+			reference.TenantID = feedback.TenantID
+		}
+		return tx.Create(&feedback.References).Error
+	})
+}
+
+// Get gets the feedback of a user on a message with its references
+func (r *feedbackRepository) Get(
+	ctx context.Context, tenantID uint64, messageID string, userID string,
+) (*types.MessageFeedback, error) {
+	var feedback types.MessageFeedback
+	if err := r.db.WithContext(ctx).
+		Preload("References", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("tenant_id = ? AND message_id = ? AND user_id = ?", tenantID, messageID, userID).
+		First(&feedback).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFeedbackNotFound
+		}
+		return nil, err
+	}
+	return &feedback, nil
+}
+
+// Delete deletes the feedback of a user on a message with its references
+func (r *feedbackRepository) Delete(ctx context.Context, tenantID uint64, messageID string, userID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var feedback types.MessageFeedback
+		if err := tx.Where("tenant_id = ? AND message_id = ? AND user_id = ?", tenantID, messageID, userID).
+			First(&feedback).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrFeedbackNotFound
+			}
+			return err
+		}
+		if err := tx.Where("feedback_id = ?", feedback.ID).Delete(&types.FeedbackReference{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&feedback).Error
+	})
+}
+
+// filter restricts a query on the feedback f to a tenant and a filter. When the references r are joined,
+// the knowledge filters keep the matching references, otherwise the feedback having one.
+func (r *feedbackRepository) filter(
+	query *gorm.DB, tenantID uint64, filter *types.FeedbackFilter, joined bool,
+) *gorm.DB {
+	query = query.Where("f.tenant_id = ?", tenantID)
+	if filter == nil {
+		return query
+	}
+	if filter.KnowledgeBaseID != "" {
+		if joined {
+			query = query.Where("r.knowledge_base_id = ?", filter.KnowledgeBaseID)
+		} else {
+			query = query.Where(`EXISTS (SELECT 1 FROM message_feedback_references fr
+				WHERE fr.feedback_id = f.id AND fr.knowledge_base_id = ?)`, filter.KnowledgeBaseID)
+		}
+	}
+	if filter.KnowledgeID != "" {
+		if joined {
+			query = query.Where("r.knowledge_id = ?", filter.KnowledgeID)
+		} else {
+			query = query.Where(`EXISTS (SELECT 1 FROM message_feedback_references fr
+				WHERE fr.feedback_id = f.id AND fr.knowledge_id = ?)`, filter.KnowledgeID)
+		}
+	}
+	if filter.ModelID != "" {
+		query = query.Where("f.chat_model_id = ?", filter.ModelID)
+	}
+	if filter.StartTime != nil {
+		query = query.Where("f.created_at >= ?", *filter.StartTime)
+	}
+	if filter.EndTime != nil {
+		query = query.Where("f.created_at < ?", *filter.EndTime)
+	}
+	return query
+}
+
+// joined queries the feedback f of a tenant joined with their references r
+func (r *feedbackRepository) joined(ctx context.Context, tenantID uint64, filter *types.FeedbackFilter) *gorm.DB {
+	query := r.db.WithContext(ctx).Table("message_feedbacks AS f").
+		Joins("LEFT JOIN message_feedback_references r ON r.feedback_id = f.id")
+	return r.filter(query, tenantID, filter, true)
+}
+
+// Summary aggregates the feedback of a tenant by group, the totals without group
+func (r *feedbackRepository) Summary(
+	ctx context.Context, tenantID uint64, filter *types.FeedbackFilter, groupBy string,
+) ([]*types.FeedbackStats, error) {
+	query := r.joined(ctx, tenantID, filter)
+	var key, name string
+	switch groupBy {
+	case "":
+		var totals types.FeedbackStats
+		if err := query.Select(feedbackStatsColumns).Scan(&totals).Error; err != nil {
+			return nil, err
+		}
+		return []*types.FeedbackStats{&totals}, nil
+	case types.FeedbackGroupByKnowledgeBase:
+		query = query.Joins("LEFT JOIN knowledge_bases kb ON kb.id = r.knowledge_base_id")
+		key, name = "COALESCE(r.knowledge_base_id, '')", "MAX(kb.name)"
+	case types.FeedbackGroupByKnowledge:
+		key, name = "COALESCE(r.knowledge_id, '')", "MAX(r.knowledge_title)"
+	case types.FeedbackGroupByModel:
+		query = query.Joins("LEFT JOIN models m ON m.id = f.chat_model_id")
+		key, name = "f.chat_model_id", "MAX(m.name)"
+	case types.FeedbackGroupByDay:
+		key, name = "TO_CHAR(f.created_at, 'YYYY-MM-DD')", "''"
+	default:
+		return nil, errors.New("unknown feedback grouping " + groupBy)
+	}
+
+	order := "down DESC, total DESC, " + key
+	if groupBy == types.FeedbackGroupByDay {
+		order = key
+	}
+	var groups []*types.FeedbackStats
+	if err := query.
+		Select(key + " AS key, COALESCE(" + name + ", '') AS name, " + feedbackStatsColumns).
+		Group(key).
+		Order(order).
+		Scan(&groups).Error; err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// WorstQueries lists up to limit questions of a tenant with downvoted answers, most downvoted first
+func (r *feedbackRepository) WorstQueries(
+	ctx context.Context, tenantID uint64, filter *types.FeedbackFilter, limit int,
+) ([]*types.FeedbackQueryStats, error) {
+	var queries []*types.FeedbackQueryStats
+	query := r.filter(r.db.WithContext(ctx).Table("message_feedbacks AS f"), tenantID, filter, false)
+	if err := query.
+		Select(`MAX(f.query) AS query, COUNT(*) AS total,
+			COUNT(*) FILTER (WHERE f.rating = 'up') AS up,
+			COUNT(*) FILTER (WHERE f.rating = 'down') AS down,
+			MAX(f.updated_at) AS last_rated_at`).
+		Where("f.query <> ''").
+		Group("LOWER(TRIM(f.query))").
+		Having("COUNT(*) FILTER (WHERE f.rating = 'down') > 0").
+		Order(`COUNT(*) FILTER (WHERE f.rating = 'down') DESC, COUNT(*) FILTER (WHERE f.rating = 'up'),
+			last_rated_at DESC`).
+		Limit(limit).
+		Scan(&queries).Error; err != nil {
+		return nil, err
+	}
+	return queries, nil
+}
+
+// DownvotedChunks lists up to limit chunks of a tenant referenced by downvoted answers or marked not relevant
+func (r *feedbackRepository) DownvotedChunks(
+	ctx context.Context, tenantID uint64, filter *types.FeedbackFilter, limit int,
+) ([]*types.FeedbackChunkStats, error) {
+	var chunks []*types.FeedbackChunkStats
+	if err := r.joined(ctx, tenantID, filter).
+		Select(`r.chunk_id, MAX(r.knowledge_id) AS knowledge_id, MAX(r.knowledge_base_id) AS knowledge_base_id,
+			MAX(r.knowledge_title) AS knowledge_title, MAX(r.content) AS content,
+			COUNT(*) FILTER (WHERE f.rating = 'down') AS downvotes,
+			COUNT(*) FILTER (WHERE f.rating = 'up') AS upvotes,
+			COUNT(*) FILTER (WHERE r.irrelevant) AS irrelevant_marks`).
+		Where("r.chunk_id <> ''").
+		Group("r.chunk_id").
+		Having("COUNT(*) FILTER (WHERE f.rating = 'down' OR r.irrelevant) > 0").
+		Order("COUNT(*) FILTER (WHERE f.rating = 'down' OR r.irrelevant) DESC, r.chunk_id").
+		Limit(limit).
+		Scan(&chunks).Error; err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// ListNegativeAfter lists up to limit downvoted feedback of a tenant with an ID greater than afterID,
+// oldest first, with their references
+func (r *feedbackRepository) ListNegativeAfter(
+	ctx context.Context, tenantID uint64, filter *types.FeedbackFilter, afterID uint64, limit int,
+) ([]*types.MessageFeedback, error) {
+	var feedback []*types.MessageFeedback
+	query := r.filter(r.db.WithContext(ctx).Table("message_feedbacks AS f"), tenantID, filter, false)
+	if err := query.
+		Preload("References", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("f.rating = ? AND f.id > ?", types.FeedbackRatingDown, afterID).
+		Order("f.id ASC").
+		Limit(limit).
+		Find(&feedback).Error; err != nil {
+		return nil, err
+	}
+	return feedback, nil
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// newTestFeedbackRepository creates a feedback repository on an in-memory SQLite database
+func newTestFeedbackRepository(t *testing.T) (*feedbackRepository, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&types.MessageFeedback{}, &types.FeedbackReference{}))
+	return &feedbackRepository{db: db}, db
+}
+
+func TestFeedbackUpsertReplaces(t *testing.T) {
+	ctx := context.Background()
+	repo, db := newTestFeedbackRepository(t)
+
+	first := &types.MessageFeedback{
+		TenantID:  1,
+		SessionID: "session1",
+		MessageID: "message1",
+		UserID:    "u1",
+		Rating:    types.FeedbackRatingDown,
+		Reason:    "incorrect",
+		Comment:   "wrong version",
+		References: []*types.FeedbackReference{
+			{ChunkID: "c1", KnowledgeID: "k1", KnowledgeBaseID: "kb1", Irrelevant: true},
+			{ChunkID: "c2", KnowledgeID: "k1", KnowledgeBaseID: "kb1"},
+		},
+	}
+	require.NoError(t, repo.Upsert(ctx, first))
+	created, err := repo.Get(ctx, 1, "message1", "u1")
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+
+	// Rating the message again replaces the feedback and its references
+	second := &types.MessageFeedback{
+		TenantID:  1,
+		SessionID: "session1",
+		MessageID: "message1",
+		UserID:    "u1",
+		Rating:    types.FeedbackRatingUp,
+		References: []*types.FeedbackReference{
+			{ChunkID: "c3", KnowledgeID: "k2", KnowledgeBaseID: "kb1"},
+		},
+	}
+	require.NoError(t, repo.Upsert(ctx, second))
+	assert.Equal(t, created.ID, second.ID)
+
+	feedback, err := repo.Get(ctx, 1, "message1", "u1")
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, feedback.ID)
+	assert.Equal(t, types.FeedbackRatingUp, feedback.Rating)
+	// Fields left empty are cleared, not kept from the previous feedback
+	assert.Empty(t, feedback.Reason)
+	assert.Empty(t, feedback.Comment)
+	assert.True(t, feedback.CreatedAt.Equal(created.CreatedAt))
+	assert.True(t, feedback.UpdatedAt.After(created.UpdatedAt))
+	require.Len(t, feedback.References, 1)
+	assert.Equal(t, "c3", feedback.References[0].ChunkID)
+	assert.False(t, feedback.References[0].Irrelevant)
+	assert.Equal(t, uint64(1), feedback.References[0].TenantID)
+
+	var feedbackCount, referenceCount int64
+	require.NoError(t, db.Model(&types.MessageFeedback{}).Count(&feedbackCount).Error)
+	require.NoError(t, db.Model(&types.FeedbackReference{}).Count(&referenceCount).Error)
+	assert.Equal(t, int64(1), feedbackCount)
+	assert.Equal(t, int64(1), referenceCount)
+}
+
+func TestFeedbackUpsertPerUser(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestFeedbackRepository(t)
+
+	for _, userID := range []string{"u1", "u2"} {
+		require.NoError(t, repo.Upsert(ctx, &types.MessageFeedback{
+			TenantID:  1,
+			MessageID: "message1",
+			UserID:    userID,
+			Rating:    types.FeedbackRatingUp,
+		}))
+	}
+	// Without references the previous references are removed
+	require.NoError(t, repo.Upsert(ctx, &types.MessageFeedback{
+		TenantID:  1,
+		MessageID: "message1",
+		UserID:    "u1",
+		Rating:    types.FeedbackRatingDown,
+	}))
+
+	u1, err := repo.Get(ctx, 1, "message1", "u1")
+	require.NoError(t, err)
+	u2, err := repo.Get(ctx, 1, "message1", "u2")
+	require.NoError(t, err)
+	assert.NotEqual(t, u1.ID, u2.ID)
+	assert.Equal(t, types.FeedbackRatingDown, u1.Rating)
+	assert.Equal(t, types.FeedbackRatingUp, u2.Rating)
+	assert.Empty(t, u1.References)
+
+	// The feedback of another tenant is not found
+	_, err = repo.Get(ctx, 2, "message1", "u1")
+	assert.ErrorIs(t, err, ErrFeedbackNotFound)
+}
+
+func TestFeedbackUpsertReusedReferences(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestFeedbackRepository(t)
+	references := []*types.FeedbackReference{{ChunkID: "c1"}}
+
+	// The same reference values can be stored again, e.g. when a request is retried
+	for range 2 {
+		require.NoError(t, repo.Upsert(ctx, &types.MessageFeedback{
+			TenantID:   1,
+			MessageID:  "message1",
+			UserID:     "u1",
+			Rating:     types.FeedbackRatingDown,
+			References: references,
+		}))
+	}
+	feedback, err := repo.Get(ctx, 1, "message1", "u1")
+	require.NoError(t, err)
+	require.Len(t, feedback.References, 1)
+	assert.Equal(t, "c1", feedback.References[0].ChunkID)
+}
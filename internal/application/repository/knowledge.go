@@ -257,6 +257,18 @@ func (r *knowledgeRepository) UpdateKnowledgeColumn(
 	return err
 }
 
+// ListRefreshScheduledKnowledge lists the URL knowledge of all tenants that has a refresh schedule
+func (r *knowledgeRepository) ListRefreshScheduledKnowledge(ctx context.Context) ([]*types.Knowledge, error) {
+	var knowledges []*types.Knowledge
+	err := r.db.WithContext(ctx).
+		Select("id", "tenant_id", "knowledge_base_id", "refresh_schedule").
+		Where("type = 'url' AND refresh_schedule IS NOT NULL AND refresh_schedule <> ''").
+		Where("parse_status <> ?", types.ParseStatusDeleting).
+		Order("created_at").
+		Find(&knowledges).Error
+	return knowledges, err
+}
+
 // CountKnowledgeByKnowledgeBaseID counts the number of knowledge items in a knowledge base
 func (r *knowledgeRepository) CountKnowledgeByKnowledgeBaseID(
 	ctx context.Context,
@@ -300,6 +312,7 @@ func (r *knowledgeRepository) CountKnowledgeByStatus(
 func (r *knowledgeRepository) SearchKnowledge(
 	ctx context.Context,
 	tenantID uint64,
+	kbIDs []string,
 	keyword string,
 	offset, limit int,
 ) ([]*types.Knowledge, bool, error) {
@@ -318,6 +331,11 @@ func (r *knowledgeRepository) SearchKnowledge(
 		Where("knowledge_bases.type = ?", types.KnowledgeBaseTypeDocument).
 		Where("knowledges.deleted_at IS NULL")
 
+	// Restrict to the given knowledge bases
+	if kbIDs != nil {
+		query = query.Where("knowledges.knowledge_base_id IN ?", kbIDs)
+	}
+
 	// If keyword is provided, filter by file_name or title
 	if keyword != "" {
 		query = query.Where("knowledges.file_name LIKE ? ", "%"+keyword+"%")
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/Tencent/WeKnora/internal/types"
 	"github.com/Tencent/WeKnora/internal/types/interfaces"
@@ -76,6 +77,18 @@ func (r *knowledgeBaseRepository) UpdateKnowledgeBase(ctx context.Context, kb *t
 	return r.db.WithContext(ctx).Save(kb).Error
 }
 
+// UpdateEmbeddingModel switches the embedding model of a knowledge base and its knowledge atomically
+func (r *knowledgeBaseRepository) UpdateEmbeddingModel(ctx context.Context, id string, modelID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&types.KnowledgeBase{}).Where("id = ?", id).
+			Updates(map[string]interface{}{"embedding_model_id": modelID, "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&types.Knowledge{}).Where("knowledge_base_id = ?", id).
+			Update("embedding_model_id", modelID).Error
+	})
+}
+
 // DeleteKnowledgeBase deletes a knowledge base
 func (r *knowledgeBaseRepository) DeleteKnowledgeBase(ctx context.Context, id string) error {
 	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&types.KnowledgeBase{}).Error
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"gorm.io/gorm"
+)
+
+// ErrMemoryNotFound is returned when a user memory does not exist
+var ErrMemoryNotFound = errors.New("memory not found")
+
+// memoryRepository stores the user memories
+type memoryRepository struct {
+	db *gorm.DB
+}
+
+// NewMemoryRepository creates a new user memory repository
+func NewMemoryRepository(db *gorm.DB) interfaces.MemoryRepository {
+	return &memoryRepository{db: db}
+}
+
+// Create creates a user memory
+func (r *memoryRepository) Create(ctx context.Context, memory *types.UserMemory) error {
+	return r.db.WithContext(ctx).Create(memory).Error
+}
+
+// Update updates the content and embedding of a user memory
+func (r *memoryRepository) Update(ctx context.Context, memory *types.UserMemory) error {
+	return r.db.WithContext(ctx).
+		Where("tenant_id = ? AND user_id = ? AND id = ?", memory.TenantID, memory.UserID, memory.ID).
+		Select("content", "category", "source_session_id", "embedding_model_id", "embedding", "updated_at").
+		Updates(memory).Error
+}
+
+// GetByID gets a memory of a user by its ID
+func (r *memoryRepository) GetByID(
+	ctx context.Context, tenantID uint64, userID string, id string,
+) (*types.UserMemory, error) {
+	var memory types.UserMemory
+	if err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND user_id = ? AND id = ?", tenantID, userID, id).
+		First(&memory).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMemoryNotFound
+		}
+		return nil, err
+	}
+	return &memory, nil
+}
+
+// ListByUser lists the memories of a user, newest first
+func (r *memoryRepository) ListByUser(
+	ctx context.Context, tenantID uint64, userID string,
+) ([]*types.UserMemory, error) {
+	var memories []*types.UserMemory
+	if err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND user_id = ?", tenantID, userID).
+		Order("updated_at DESC").
+		Find(&memories).Error; err != nil {
+		return nil, err
+	}
+	return memories, nil
+}
+
+// Delete deletes a memory of a user
+func (r *memoryRepository) Delete(ctx context.Context, tenantID uint64, userID string, id string) error {
+	return r.db.WithContext(ctx).
+		Where("tenant_id = ? AND user_id = ? AND id = ?", tenantID, userID, id).
+		Delete(&types.UserMemory{}).Error
+}
+
+// DeleteByUser deletes all memories of a user
+func (r *memoryRepository) DeleteByUser(ctx context.Context, tenantID uint64, userID string) error {
+	return r.db.WithContext(ctx).
+		Where("tenant_id = ? AND user_id = ?", tenantID, userID).
+		Delete(&types.UserMemory{}).Error
+}
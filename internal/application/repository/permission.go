@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"gorm.io/gorm"
+)
+
+// ErrTenantMemberNotFound is returned when a user is not a member of a tenant
+var ErrTenantMemberNotFound = errors.New("tenant member not found")
+
+// permissionRepository stores tenant members and knowledge base grants
+type permissionRepository struct {
+	db *gorm.DB
+}
+
+// NewPermissionRepository creates a new permission repository
+func NewPermissionRepository(db *gorm.DB) interfaces.PermissionRepository {
+	return &permissionRepository{db: db}
+}
+
+// CreateMember creates a tenant membership
+func (r *permissionRepository) CreateMember(ctx context.Context, member *types.TenantMember) error {
+	return r.db.WithContext(ctx).Create(member).Error
+}
+
+// UpdateMember updates a tenant membership
+func (r *permissionRepository) UpdateMember(ctx context.Context, member *types.TenantMember) error {
+	return r.db.WithContext(ctx).Save(member).Error
+}
+
+// GetMember gets the membership of a user in a tenant
+func (r *permissionRepository) GetMember(
+	ctx context.Context, tenantID uint64, userID string,
+) (*types.TenantMember, error) {
+	var member types.TenantMember
+	if err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND user_id = ?", tenantID, userID).
+		First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTenantMemberNotFound
+		}
+		return nil, err
+	}
+	return &member, nil
+}
+
+// ListMembers lists the members of a tenant
+func (r *permissionRepository) ListMembers(ctx context.Context, tenantID uint64) ([]*types.TenantMember, error) {
+	var members []*types.TenantMember
+	if err := r.db.WithContext(ctx).
+		Where("tenant_id = ?", tenantID).
+		Order("created_at ASC").
+		Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// ListMembershipsByUser lists the tenants a user is a member of
+func (r *permissionRepository) ListMembershipsByUser(
+	ctx context.Context, userID string,
+) ([]*types.TenantMember, error) {
+	var members []*types.TenantMember
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// DeleteMember deletes the membership of a user in a tenant together with the user's grants in the tenant
+func (r *permissionRepository) DeleteMember(ctx context.Context, tenantID uint64, userID string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tenant_id = ? AND user_id = ?", tenantID, userID).
+			Delete(&types.KnowledgeBaseGrant{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("tenant_id = ? AND user_id = ?", tenantID, userID).
+			Delete(&types.TenantMember{}).Error
+	})
+}
+
+// GetGrant gets the grant of a user on a knowledge base
+func (r *permissionRepository) GetGrant(
+	ctx context.Context, tenantID uint64, kbID string, userID string,
+) (*types.KnowledgeBaseGrant, error) {
+	var grant types.KnowledgeBaseGrant
+	if err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND knowledge_base_id = ? AND user_id = ?", tenantID, kbID, userID).
+		First(&grant).Error; err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// SaveGrant creates or updates a knowledge base grant
+func (r *permissionRepository) SaveGrant(ctx context.Context, grant *types.KnowledgeBaseGrant) error {
+	return r.db.WithContext(ctx).Save(grant).Error
+}
+
+// ListGrantsByKnowledgeBase lists the grants on a knowledge base
+func (r *permissionRepository) ListGrantsByKnowledgeBase(
+	ctx context.Context, tenantID uint64, kbID string,
+) ([]*types.KnowledgeBaseGrant, error) {
+	var grants []*types.KnowledgeBaseGrant
+	if err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND knowledge_base_id = ?", tenantID, kbID).
+		Order("created_at ASC").
+		Find(&grants).Error; err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+// ListGrantsByUser lists the grants of a user in a tenant
+func (r *permissionRepository) ListGrantsByUser(
+	ctx context.Context, tenantID uint64, userID string,
+) ([]*types.KnowledgeBaseGrant, error) {
+	var grants []*types.KnowledgeBaseGrant
+	if err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND user_id = ?", tenantID, userID).
+		Find(&grants).Error; err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+// DeleteGrant deletes the grant of a user on a knowledge base
+func (r *permissionRepository) DeleteGrant(ctx context.Context, tenantID uint64, kbID string, userID string) error {
+	return r.db.WithContext(ctx).
+		Where("tenant_id = ? AND knowledge_base_id = ? AND user_id = ?", tenantID, kbID, userID).
+		Delete(&types.KnowledgeBaseGrant{}).Error
+}
+
+// DeleteGrantsByKnowledgeBase deletes all grants on a knowledge base
+func (r *permissionRepository) DeleteGrantsByKnowledgeBase(ctx context.Context, tenantID uint64, kbID string) error {
+	return r.db.WithContext(ctx).
+		Where("tenant_id = ? AND knowledge_base_id = ?", tenantID, kbID).
+		Delete(&types.KnowledgeBaseGrant{}).Error
+}
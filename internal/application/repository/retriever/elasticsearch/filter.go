@@ -0,0 +1,165 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// FilterFieldNames are the document fields holding the filter fields of the knowledge
+var FilterFieldNames = []string{"tag_id", "file_type", "knowledge_created_at", "metadata_keys", "filter_metadata"}
+
+// FilterMappingProperties maps the filter fields of the documents, so that they are matched exactly
+// and the metadata entries are matched one by one
+func FilterMappingProperties() map[string]interface{} {
+	keyword := map[string]interface{}{"type": "keyword"}
+	return map[string]interface{}{
+		"tag_id":               keyword,
+		"file_type":            keyword,
+		"knowledge_created_at": map[string]interface{}{"type": "date"},
+		"metadata_keys":        keyword,
+		"filter_metadata": map[string]interface{}{
+			"type": "nested",
+			"properties": map[string]interface{}{
+				"key":    keyword,
+				"string": keyword,
+				"number": map[string]interface{}{"type": "double"},
+				"bool":   map[string]interface{}{"type": "boolean"},
+			},
+		},
+	}
+}
+
+// FilterFieldsUpdateScript replaces the filter fields of the documents with params.values
+const FilterFieldsUpdateScript = "for (String field : params.fields) { ctx._source.remove(field); } " +
+	"for (def entry : params.values.entrySet()) { ctx._source[entry.getKey()] = entry.getValue(); }"
+
+// FilterFieldsUpdateParams builds the parameters of FilterFieldsUpdateScript
+func FilterFieldsUpdateParams(fields *types.IndexFilterFields) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if fields != nil {
+		encoded, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(encoded, &values); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]interface{}{"fields": FilterFieldNames, "values": values}, nil
+}
+
+// matchNone is a query matching no document
+func matchNone() map[string]interface{} {
+	return map[string]interface{}{"bool": map[string]interface{}{
+		"must_not": []interface{}{map[string]interface{}{"match_all": map[string]interface{}{}}},
+	}}
+}
+
+// termsQuery matches a field equal to one of the values
+func termsQuery(field string, values []interface{}) map[string]interface{} {
+	if len(values) == 0 {
+		return matchNone()
+	}
+	return map[string]interface{}{"terms": map[string]interface{}{field: values}}
+}
+
+// FilterQuery renders a validated retrieval filter as an Elasticsearch query on the filter fields of
+// the documents. Conditions on tag names must be resolved to tag IDs first.
+func FilterQuery(filter *types.RetrievalFilter) (map[string]interface{}, error) {
+	switch {
+	case len(filter.And) > 0 || len(filter.Or) > 0:
+		children := filter.And
+		if len(filter.Or) > 0 {
+			children = filter.Or
+		}
+		queries := make([]interface{}, 0, len(children))
+		for _, child := range children {
+			query, err := FilterQuery(child)
+			if err != nil {
+				return nil, err
+			}
+			queries = append(queries, query)
+		}
+		if len(filter.And) > 0 {
+			return map[string]interface{}{"bool": map[string]interface{}{"filter": queries}}, nil
+		}
+		return map[string]interface{}{"bool": map[string]interface{}{
+			"should": queries, "minimum_should_match": 1,
+		}}, nil
+	case filter.Not != nil:
+		query, err := FilterQuery(filter.Not)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"bool": map[string]interface{}{"must_not": []interface{}{query}}}, nil
+	}
+
+	switch {
+	case filter.Field == types.FilterFieldTagID:
+		if filter.Op == types.FilterOpExists {
+			return map[string]interface{}{"exists": map[string]interface{}{"field": "tag_id"}}, nil
+		}
+		return termsQuery("tag_id", filter.Values()), nil
+	case filter.Field == types.FilterFieldFileType:
+		values := make([]interface{}, 0)
+		for _, value := range filter.Values() {
+			values = append(values, types.FileTypeValue(value))
+		}
+		return termsQuery("file_type", values), nil
+	case filter.Field == types.FilterFieldCreatedAt:
+		bounds := map[string]interface{}{}
+		for _, bound := range filter.Bounds() {
+			bounds[bound.Op] = types.FilterTimeValue(bound.Value).UTC().Format(time.RFC3339Nano)
+		}
+		return map[string]interface{}{"range": map[string]interface{}{"knowledge_created_at": bounds}}, nil
+	case !strings.HasPrefix(filter.Field, types.FilterFieldMetadataPrefix):
+		return nil, fmt.Errorf("unsupported filter field %q", filter.Field)
+	}
+
+	key := strings.TrimPrefix(filter.Field, types.FilterFieldMetadataPrefix)
+	if filter.Op == types.FilterOpExists {
+		return map[string]interface{}{"term": map[string]interface{}{"metadata_keys": key}}, nil
+	}
+	var value map[string]interface{}
+	if filter.Op == types.FilterOpRange {
+		// Numbers are only compared to numbers and strings to strings
+		field, bounds := "filter_metadata.string", map[string]interface{}{}
+		for _, bound := range filter.Bounds() {
+			if _, numeric := bound.Value.(float64); numeric {
+				field = "filter_metadata.number"
+			}
+			bounds[bound.Op] = bound.Value
+		}
+		value = map[string]interface{}{"range": map[string]interface{}{field: bounds}}
+	} else {
+		byField := map[string][]interface{}{}
+		for _, v := range filter.Values() {
+			switch v.(type) {
+			case string:
+				byField["filter_metadata.string"] = append(byField["filter_metadata.string"], v)
+			case float64:
+				byField["filter_metadata.number"] = append(byField["filter_metadata.number"], v)
+			case bool:
+				byField["filter_metadata.bool"] = append(byField["filter_metadata.bool"], v)
+			}
+		}
+		should := make([]interface{}, 0, len(byField))
+		for _, field := range []string{"filter_metadata.string", "filter_metadata.number", "filter_metadata.bool"} {
+			if len(byField[field]) > 0 {
+				should = append(should, termsQuery(field, byField[field]))
+			}
+		}
+		value = map[string]interface{}{"bool": map[string]interface{}{"should": should, "minimum_should_match": 1}}
+	}
+	return map[string]interface{}{"nested": map[string]interface{}{
+		"path": "filter_metadata",
+		"query": map[string]interface{}{"bool": map[string]interface{}{"filter": []interface{}{
+			map[string]interface{}{"term": map[string]interface{}{"filter_metadata.key": key}},
+			value,
+		}}},
+	}}, nil
+}
@@ -0,0 +1,95 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		query  string
+		err    bool
+	}{
+		{
+			name:   "tag_id in",
+			filter: `{"field": "tag_id", "op": "in", "value": ["t1", "t2"]}`,
+			query:  `{"terms": {"tag_id": ["t1", "t2"]}}`,
+		},
+		{
+			name:   "no tag matches nothing",
+			filter: `{"field": "tag_id", "op": "in", "value": []}`,
+			query:  `{"bool": {"must_not": [{"match_all": {}}]}}`,
+		},
+		{
+			name:   "file_type normalized",
+			filter: `{"field": "file_type", "op": "eq", "value": ".PDF"}`,
+			query:  `{"terms": {"file_type": ["pdf"]}}`,
+		},
+		{
+			name:   "created_at range in UTC",
+			filter: `{"field": "created_at", "op": "range", "gte": "2024-01-01T08:00:00+08:00"}`,
+			query:  `{"range": {"knowledge_created_at": {"gte": "2024-01-01T00:00:00Z"}}}`,
+		},
+		{
+			name:   "metadata exists",
+			filter: `{"field": "metadata.source.system", "op": "exists"}`,
+			query:  `{"term": {"metadata_keys": "source.system"}}`,
+		},
+		{
+			name:   "metadata in",
+			filter: `{"field": "metadata.product", "op": "in", "value": [true, "WeKnora", 1]}`,
+			query: `{"nested": {"path": "filter_metadata", "query": {"bool": {"filter": [
+				{"term": {"filter_metadata.key": "product"}},
+				{"bool": {"minimum_should_match": 1, "should": [
+					{"terms": {"filter_metadata.string": ["WeKnora"]}},
+					{"terms": {"filter_metadata.number": [1]}},
+					{"terms": {"filter_metadata.bool": [true]}}
+				]}}
+			]}}}}`,
+		},
+		{
+			name:   "metadata numeric range",
+			filter: `{"field": "metadata.score", "op": "range", "gt": 1, "lte": 5}`,
+			query: `{"nested": {"path": "filter_metadata", "query": {"bool": {"filter": [
+				{"term": {"filter_metadata.key": "score"}},
+				{"range": {"filter_metadata.number": {"gt": 1, "lte": 5}}}
+			]}}}}`,
+		},
+		{
+			name:   "and, or and not",
+			filter: `{"and": [{"or": [{"field": "tag_id", "op": "exists"}]},
+				{"not": {"field": "file_type", "op": "eq", "value": "md"}}]}`,
+			query: `{"bool": {"filter": [
+				{"bool": {"minimum_should_match": 1, "should": [{"exists": {"field": "tag_id"}}]}},
+				{"bool": {"must_not": [{"terms": {"file_type": ["md"]}}]}}
+			]}}`,
+		},
+		{
+			name:   "unresolved tag names",
+			filter: `{"field": "tag", "op": "eq", "value": "faq"}`,
+			err:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var filter types.RetrievalFilter
+			require.NoError(t, json.Unmarshal([]byte(tt.filter), &filter))
+			query, err := FilterQuery(&filter)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			encoded, err := json.Marshal(query)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.query, string(encoded))
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package elasticsearch
+
+// PromoteAttempts bounds the passes relabeling the shadow documents, or deleting the previous documents,
+// which are repeated while concurrent updates of the documents conflict with them
+const PromoteAttempts = 5
+
+// PromoteScript relabels the shadow documents to the target knowledge base under a new index generation,
+// which tells them apart from the previous documents of the knowledge base
+const PromoteScript = "ctx._source.knowledge_base_id = params.knowledge_base_id; " +
+	"ctx._source.index_generation = params.index_generation"
+
+// PromoteScriptParams builds the parameters of PromoteScript
+func PromoteScriptParams(targetKnowledgeBaseID string, generation string) map[string]interface{} {
+	return map[string]interface{}{"knowledge_base_id": targetKnowledgeBaseID, "index_generation": generation}
+}
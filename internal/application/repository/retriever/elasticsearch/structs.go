@@ -17,6 +17,8 @@ type VectorEmbedding struct {
 	KnowledgeBaseID string    `json:"knowledge_base_id" gorm:"column:knowledge_base_id"`    // ID of the knowledge base
 	Embedding       []float32 `json:"embedding"         gorm:"column:embedding;not null"`   // Vector embedding of the content
 	IsEnabled       bool      `json:"is_enabled"`                                           // Whether the chunk is enabled
+	// Fields of the knowledge matched by retrieval filters
+	*types.IndexFilterFields
 }
 
 // VectorEmbeddingWithScore extends VectorEmbedding with similarity score
@@ -28,13 +30,14 @@ type VectorEmbeddingWithScore struct {
 // ToDBVectorEmbedding converts IndexInfo to Elasticsearch document format
 func ToDBVectorEmbedding(embedding *types.IndexInfo, additionalParams map[string]interface{}) *VectorEmbedding {
 	vector := &VectorEmbedding{
-		Content:         embedding.Content,
-		SourceID:        embedding.SourceID,
-		SourceType:      int(embedding.SourceType),
-		ChunkID:         embedding.ChunkID,
-		KnowledgeID:     embedding.KnowledgeID,
-		KnowledgeBaseID: embedding.KnowledgeBaseID,
-		IsEnabled:       true, // Default to enabled
+		Content:           embedding.Content,
+		SourceID:          embedding.SourceID,
+		SourceType:        int(embedding.SourceType),
+		ChunkID:           embedding.ChunkID,
+		KnowledgeID:       embedding.KnowledgeID,
+		KnowledgeBaseID:   embedding.KnowledgeBaseID,
+		IsEnabled:         true, // Default to enabled
+		IndexFilterFields: embedding.FilterFields,
 	}
 	// Add embedding data if available in additionalParams
 	if additionalParams != nil && slices.Contains(slices.Collect(maps.Keys(additionalParams)), "embedding") {
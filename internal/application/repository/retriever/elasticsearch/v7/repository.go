@@ -44,9 +44,52 @@ func NewElasticsearchEngineRepository(client *elasticsearch.Client,
 
 	log.Infof("[ElasticsearchV7] Using index: %s", indexName)
 	res := &elasticsearchRepository{client: client, index: indexName}
+	if err := res.ensureFilterMapping(context.Background()); err != nil {
+		log.Errorf("[ElasticsearchV7] Failed to map filter fields: %v", err)
+	}
 	return res
 }
 
+// ensureFilterMapping maps the filter fields of the documents, creating the index if it does not exist
+func (e *elasticsearchRepository) ensureFilterMapping(ctx context.Context) error {
+	log := logger.GetLogger(ctx)
+	mapping, err := json.Marshal(map[string]interface{}{
+		"properties": elasticsearchRetriever.FilterMappingProperties(),
+	})
+	if err != nil {
+		return err
+	}
+
+	exists, err := e.client.Indices.Exists([]string{e.index}, e.client.Indices.Exists.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	exists.Body.Close()
+
+	var res *esapi.Response
+	if exists.StatusCode == 404 {
+		log.Infof("[ElasticsearchV7] Creating index: %s", e.index)
+		body := fmt.Sprintf(`{"mappings": %s}`, mapping)
+		res, err = e.client.Indices.Create(e.index,
+			e.client.Indices.Create.WithBody(strings.NewReader(body)),
+			e.client.Indices.Create.WithContext(ctx),
+		)
+	} else {
+		res, err = e.client.Indices.PutMapping(bytes.NewReader(mapping),
+			e.client.Indices.PutMapping.WithIndex(e.index),
+			e.client.Indices.PutMapping.WithContext(ctx),
+		)
+	}
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("failed to map filter fields: %s", res.String())
+	}
+	return nil
+}
+
 func (e *elasticsearchRepository) EngineType() typesLocal.RetrieverEngineType {
 	return typesLocal.ElasticsearchRetrieverEngineType
 }
@@ -303,6 +346,132 @@ func (e *elasticsearchRepository) DeleteByKnowledgeIDList(ctx context.Context,
 	return e.deleteByFieldList(ctx, "knowledge_id.keyword", knowledgeIDList)
 }
 
+// DeleteByKnowledgeBaseID Delete all indices of a knowledge base
+func (e *elasticsearchRepository) DeleteByKnowledgeBaseID(ctx context.Context,
+	knowledgeBaseID string, dimension int, knowledgeType string,
+) error {
+	return e.deleteByFieldList(ctx, "knowledge_base_id.keyword", []string{knowledgeBaseID})
+}
+
+// PromoteShadowIndices Replace the indices of the target knowledge base with the shadow indices.
+// The shadow indices are relabeled first, and the previous indices are only deleted once no shadow
+// index is left, so that a failed promotion keeps the knowledge base searchable.
+func (e *elasticsearchRepository) PromoteShadowIndices(ctx context.Context,
+	shadowKnowledgeBaseID string, targetKnowledgeBaseID string, shadowDimension int, targetDimension int,
+) error {
+	log := logger.GetLogger(ctx)
+	log.Infof("[ElasticsearchV7] Promoting shadow indices %s to knowledge base %s",
+		shadowKnowledgeBaseID, targetKnowledgeBaseID)
+
+	generation := uuid.New().String()
+	shadow := map[string]interface{}{
+		"term": map[string]interface{}{"knowledge_base_id.keyword": shadowKnowledgeBaseID},
+	}
+	relabel := map[string]interface{}{
+		"query": shadow,
+		"script": map[string]interface{}{
+			"source": elasticsearchRetriever.PromoteScript,
+			"lang":   "painless",
+			"params": elasticsearchRetriever.PromoteScriptParams(targetKnowledgeBaseID, generation),
+		},
+	}
+	err := e.repeatUntilNoneLeft(ctx, "relabel shadow indices", shadow, func() (*esapi.Response, error) {
+		body, _ := json.Marshal(relabel)
+		refresh := true
+		return esapi.UpdateByQueryRequest{
+			Index:     []string{e.index},
+			Body:      bytes.NewReader(body),
+			Conflicts: "proceed",
+			Refresh:   &refresh,
+		}.Do(ctx, e.client)
+	})
+	if err != nil {
+		log.Errorf("[ElasticsearchV7] Failed to promote shadow indices: %v", err)
+		return err
+	}
+
+	// The indices of the target knowledge base from before the promotion have another generation or none
+	previous := map[string]interface{}{
+		"bool": map[string]interface{}{
+			"filter": []interface{}{map[string]interface{}{
+				"term": map[string]interface{}{"knowledge_base_id.keyword": targetKnowledgeBaseID},
+			}},
+			"must_not": []interface{}{map[string]interface{}{
+				"term": map[string]interface{}{"index_generation.keyword": generation},
+			}},
+		},
+	}
+	err = e.repeatUntilNoneLeft(ctx, "delete previous indices", previous, func() (*esapi.Response, error) {
+		body, _ := json.Marshal(map[string]interface{}{"query": previous})
+		refresh := true
+		return esapi.DeleteByQueryRequest{
+			Index:     []string{e.index},
+			Body:      bytes.NewReader(body),
+			Conflicts: "proceed",
+			Refresh:   &refresh,
+		}.Do(ctx, e.client)
+	})
+	if err != nil {
+		log.Errorf("[ElasticsearchV7] Failed to delete the previous indices after promotion: %v", err)
+		return err
+	}
+
+	log.Infof("[ElasticsearchV7] Successfully promoted shadow indices")
+	return nil
+}
+
+// repeatUntilNoneLeft Run a by-query operation until no document matches the query any more. Documents
+// which concurrent updates conflicted with are skipped by the operation and handled by the next pass.
+func (e *elasticsearchRepository) repeatUntilNoneLeft(ctx context.Context,
+	operation string, query map[string]interface{}, run func() (*esapi.Response, error),
+) error {
+	for attempt := 1; ; attempt++ {
+		res, err := run()
+		if err != nil {
+			return fmt.Errorf("%s: %w", operation, err)
+		}
+		var result struct {
+			VersionConflicts int64 `json:"version_conflicts"`
+		}
+		if err := decodeResponse(res, &result); err != nil {
+			return fmt.Errorf("%s: %w", operation, err)
+		}
+
+		body, _ := json.Marshal(map[string]interface{}{"query": query})
+		res, err = e.client.Count(
+			e.client.Count.WithContext(ctx),
+			e.client.Count.WithIndex(e.index),
+			e.client.Count.WithBody(bytes.NewReader(body)),
+		)
+		if err != nil {
+			return fmt.Errorf("%s: %w", operation, err)
+		}
+		var count struct {
+			Count int64 `json:"count"`
+		}
+		if err := decodeResponse(res, &count); err != nil {
+			return fmt.Errorf("%s: %w", operation, err)
+		}
+		if count.Count == 0 {
+			return nil
+		}
+		if attempt == elasticsearchRetriever.PromoteAttempts {
+			return fmt.Errorf("%s: %d documents left after %d attempts", operation, count.Count, attempt)
+		}
+		logger.GetLogger(ctx).Warnf("[ElasticsearchV7] %s: %d documents left after %d version conflicts, retrying",
+			operation, count.Count, result.VersionConflicts)
+	}
+}
+
+// decodeResponse Decode the body of a successful response and close it
+func decodeResponse(res *esapi.Response, result interface{}) error {
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("elasticsearch request failed: %s", res.String())
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
 // deleteByFieldList Delete documents by field value list
 func (e *elasticsearchRepository) deleteByFieldList(ctx context.Context, field string, valueList []string) error {
 	log := logger.GetLogger(ctx)
@@ -357,7 +526,7 @@ func (e *elasticsearchRepository) deleteByFieldList(ctx context.Context, field s
 // It creates MUST conditions for required fields and MUST_NOT conditions for excluded fields
 // KnowledgeBaseIDs and KnowledgeIDs use AND logic (search specific documents within knowledge bases)
 // Returns a JSON string representing the query conditions
-func (e *elasticsearchRepository) getBaseConds(params typesLocal.RetrieveParams) string {
+func (e *elasticsearchRepository) getBaseConds(params typesLocal.RetrieveParams) (string, error) {
 	// Build MUST conditions (positive filters)
 	must := make([]map[string]interface{}, 0)
 
@@ -379,6 +548,14 @@ func (e *elasticsearchRepository) getBaseConds(params typesLocal.RetrieveParams)
 			},
 		})
 	}
+	// Retrieval filters match the filter fields stored on the documents
+	if params.Filter != nil {
+		filter, err := elasticsearchRetriever.FilterQuery(params.Filter)
+		if err != nil {
+			return "", err
+		}
+		must = append(must, filter)
+	}
 
 	// Build MUST_NOT conditions (negative filters)
 	mustNot := make([]map[string]interface{}, 0)
@@ -432,9 +609,9 @@ func (e *elasticsearchRepository) getBaseConds(params typesLocal.RetrieveParams)
 	// Marshal to JSON string
 	jsonBytes, err := json.Marshal(query)
 	if err != nil {
-		return "{}"
+		return "", err
 	}
-	return string(jsonBytes)
+	return string(jsonBytes), nil
 }
 
 func (e *elasticsearchRepository) Retrieve(ctx context.Context,
@@ -491,7 +668,11 @@ func (e *elasticsearchRepository) buildVectorSearchQuery(ctx context.Context,
 
 	// Parse filter conditions
 	var filterQuery map[string]interface{}
-	filterJSON := e.getBaseConds(params)
+	filterJSON, err := e.getBaseConds(params)
+	if err != nil {
+		log.Errorf("[ElasticsearchV7] Failed to build filter: %v", err)
+		return "", err
+	}
 	if err := json.Unmarshal([]byte(filterJSON), &filterQuery); err != nil {
 		log.Errorf("[ElasticsearchV7] Failed to unmarshal filter: %v", err)
 		filterQuery = map[string]interface{}{}
@@ -596,7 +777,11 @@ func (e *elasticsearchRepository) buildKeywordSearchQuery(ctx context.Context,
 		return "", err
 	}
 
-	filter := e.getBaseConds(params)
+	filter, err := e.getBaseConds(params)
+	if err != nil {
+		log.Errorf("[ElasticsearchV7] Failed to build filter: %v", err)
+		return "", err
+	}
 	query := fmt.Sprintf(
 		`{"query": {"bool": {"must": [{"match": {"content": %s}}], "filter": [%s]}}}`,
 		string(content), filter,
@@ -905,7 +1090,11 @@ func (e *elasticsearchRepository) querySourceBatch(ctx context.Context,
 	log := logger.GetLogger(ctx)
 
 	// Build query request safely
-	filterJSON := e.getBaseConds(retrieveParams)
+	filterJSON, err := e.getBaseConds(retrieveParams)
+	if err != nil {
+		log.Errorf("[ElasticsearchV7] Failed to build base conditions: %v", err)
+		return nil, err
+	}
 	var filter map[string]interface{}
 	if err := json.Unmarshal([]byte(filterJSON), &filter); err != nil {
 		log.Errorf("[ElasticsearchV7] Failed to parse base conditions: %v", err)
@@ -1227,3 +1416,48 @@ func (e *elasticsearchRepository) BatchUpdateChunkEnabledStatus(
 	log.Infof("[ElasticsearchV7] Successfully batch updated chunk enabled status")
 	return nil
 }
+
+// BatchUpdateKnowledgeFilterFields replaces the filter fields stored on the documents of the given knowledge
+func (e *elasticsearchRepository) BatchUpdateKnowledgeFilterFields(ctx context.Context,
+	fields map[string]*typesLocal.IndexFilterFields,
+) error {
+	log := logger.GetLogger(ctx)
+	log.Infof("[ElasticsearchV7] Updating filter fields of %d knowledge", len(fields))
+
+	for knowledgeID, knowledgeFields := range fields {
+		params, err := elasticsearchRetriever.FilterFieldsUpdateParams(knowledgeFields)
+		if err != nil {
+			return err
+		}
+		query := map[string]interface{}{
+			"query": map[string]interface{}{
+				"term": map[string]interface{}{"knowledge_id.keyword": knowledgeID},
+			},
+			"script": map[string]interface{}{
+				"source": elasticsearchRetriever.FilterFieldsUpdateScript,
+				"lang":   "painless",
+				"params": params,
+			},
+		}
+		queryJSON, err := json.Marshal(query)
+		if err != nil {
+			return err
+		}
+		res, err := esapi.UpdateByQueryRequest{
+			Index:     []string{e.index},
+			Body:      bytes.NewReader(queryJSON),
+			Conflicts: "proceed",
+		}.Do(ctx, e.client)
+		if err != nil {
+			log.Errorf("[ElasticsearchV7] Failed to update filter fields of knowledge %s: %v", knowledgeID, err)
+			return err
+		}
+		res.Body.Close()
+		if res.IsError() {
+			log.Errorf("[ElasticsearchV7] Failed to update filter fields of knowledge %s: %s",
+				knowledgeID, res.String())
+			return fmt.Errorf("elasticsearch update_by_query failed with status: %d", res.StatusCode)
+		}
+	}
+	return nil
+}
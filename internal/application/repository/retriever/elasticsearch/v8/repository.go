@@ -1,6 +1,7 @@
 package v8
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -15,6 +16,7 @@ import (
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/core/search"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/types"
+	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/conflicts"
 	"github.com/elastic/go-elasticsearch/v8/typedapi/types/enums/scriptlanguage"
 	"github.com/google/uuid"
 )
@@ -234,10 +236,119 @@ func (e *elasticsearchRepository) DeleteByKnowledgeIDList(ctx context.Context,
 	return nil
 }
 
+// DeleteByKnowledgeBaseID removes all documents of a knowledge base from the index
+// Returns an error if the delete operation fails
+func (e *elasticsearchRepository) DeleteByKnowledgeBaseID(ctx context.Context,
+	knowledgeBaseID string, dimension int, knowledgeType string,
+) error {
+	log := logger.GetLogger(ctx)
+	log.Infof("[Elasticsearch] Deleting indices by knowledge base ID: %s", knowledgeBaseID)
+	_, err := e.client.DeleteByQuery(e.index).Query(&types.Query{
+		Term: map[string]types.TermQuery{"knowledge_base_id.keyword": {Value: knowledgeBaseID}},
+	}).Do(ctx)
+	if err != nil {
+		log.Errorf("[Elasticsearch] Failed to delete by knowledge base ID: %v", err)
+		return fmt.Errorf("failed to delete by query: %w", err)
+	}
+
+	log.Infof("[Elasticsearch] Successfully deleted documents by knowledge base ID")
+	return nil
+}
+
+// PromoteShadowIndices replaces the documents of the target knowledge base with the shadow documents.
+// The shadow documents are relabeled first, and the previous documents are only deleted once no shadow
+// document is left, so that a failed promotion keeps the knowledge base searchable.
+func (e *elasticsearchRepository) PromoteShadowIndices(ctx context.Context,
+	shadowKnowledgeBaseID string, targetKnowledgeBaseID string, shadowDimension int, targetDimension int,
+) error {
+	log := logger.GetLogger(ctx)
+	log.Infof("[Elasticsearch] Promoting shadow indices %s to knowledge base %s",
+		shadowKnowledgeBaseID, targetKnowledgeBaseID)
+
+	generation := uuid.New().String()
+	params := map[string]json.RawMessage{}
+	for key, value := range elasticsearchRetriever.PromoteScriptParams(targetKnowledgeBaseID, generation) {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		params[key] = encoded
+	}
+	source := elasticsearchRetriever.PromoteScript
+	lang := scriptlanguage.Painless
+	script := types.Script{Source: &source, Lang: &lang, Params: params}
+
+	shadow := &types.Query{
+		Term: map[string]types.TermQuery{"knowledge_base_id.keyword": {Value: shadowKnowledgeBaseID}},
+	}
+	err := e.repeatUntilNoneLeft(ctx, "relabel shadow documents", shadow, func() (int64, error) {
+		res, err := e.client.UpdateByQuery(e.index).Query(shadow).Script(&script).
+			Conflicts(conflicts.Proceed).Refresh(true).Do(ctx)
+		if err != nil || res.VersionConflicts == nil {
+			return 0, err
+		}
+		return *res.VersionConflicts, nil
+	})
+	if err != nil {
+		log.Errorf("[Elasticsearch] Failed to promote shadow indices: %v", err)
+		return err
+	}
+
+	// The documents of the target knowledge base from before the promotion have another generation or none
+	previous := &types.Query{Bool: &types.BoolQuery{
+		Filter: []types.Query{{
+			Term: map[string]types.TermQuery{"knowledge_base_id.keyword": {Value: targetKnowledgeBaseID}},
+		}},
+		MustNot: []types.Query{{
+			Term: map[string]types.TermQuery{"index_generation.keyword": {Value: generation}},
+		}},
+	}}
+	err = e.repeatUntilNoneLeft(ctx, "delete previous documents", previous, func() (int64, error) {
+		res, err := e.client.DeleteByQuery(e.index).Query(previous).
+			Conflicts(conflicts.Proceed).Refresh(true).Do(ctx)
+		if err != nil || res.VersionConflicts == nil {
+			return 0, err
+		}
+		return *res.VersionConflicts, nil
+	})
+	if err != nil {
+		log.Errorf("[Elasticsearch] Failed to delete the previous indices after promotion: %v", err)
+		return err
+	}
+
+	log.Infof("[Elasticsearch] Successfully promoted shadow indices")
+	return nil
+}
+
+// repeatUntilNoneLeft runs a by-query operation until no document matches the query any more. Documents
+// which concurrent updates conflicted with are skipped by the operation and handled by the next pass.
+func (e *elasticsearchRepository) repeatUntilNoneLeft(ctx context.Context,
+	operation string, query *types.Query, run func() (conflicts int64, err error),
+) error {
+	for attempt := 1; ; attempt++ {
+		versionConflicts, err := run()
+		if err != nil {
+			return fmt.Errorf("%s: %w", operation, err)
+		}
+		res, err := e.client.Count().Index(e.index).Query(query).Do(ctx)
+		if err != nil {
+			return fmt.Errorf("%s: %w", operation, err)
+		}
+		if res.Count == 0 {
+			return nil
+		}
+		if attempt == elasticsearchRetriever.PromoteAttempts {
+			return fmt.Errorf("%s: %d documents left after %d attempts", operation, res.Count, attempt)
+		}
+		logger.GetLogger(ctx).Warnf("[Elasticsearch] %s: %d documents left after %d version conflicts, retrying",
+			operation, res.Count, versionConflicts)
+	}
+}
+
 // getBaseConds creates the base query conditions for retrieval operations
 // Returns a slice of Query objects with must and must_not conditions
 // KnowledgeBaseIDs and KnowledgeIDs use AND logic (search specific documents within knowledge bases)
-func (e *elasticsearchRepository) getBaseConds(params typesLocal.RetrieveParams) []types.Query {
+func (e *elasticsearchRepository) getBaseConds(params typesLocal.RetrieveParams) ([]types.Query, error) {
 	must := []types.Query{}
 
 	// KnowledgeBaseIDs and KnowledgeIDs use AND logic
@@ -258,6 +369,14 @@ func (e *elasticsearchRepository) getBaseConds(params typesLocal.RetrieveParams)
 			},
 		}})
 	}
+	// Retrieval filters match the filter fields stored on the documents
+	if params.Filter != nil {
+		filter, err := filterQuery(params.Filter)
+		if err != nil {
+			return nil, err
+		}
+		must = append(must, *filter)
+	}
 
 	mustNot := make([]types.Query, 0)
 	// Exclude disabled chunks (is_enabled = false)
@@ -275,7 +394,24 @@ func (e *elasticsearchRepository) getBaseConds(params typesLocal.RetrieveParams)
 			TermsQuery: map[string]types.TermsQueryField{"chunk_id.keyword": params.ExcludeChunkIDs},
 		}})
 	}
-	return []types.Query{{Bool: &types.BoolQuery{Must: must, MustNot: mustNot}}}
+	return []types.Query{{Bool: &types.BoolQuery{Must: must, MustNot: mustNot}}}, nil
+}
+
+// filterQuery renders a retrieval filter as a typed query
+func filterQuery(filter *typesLocal.RetrievalFilter) (*types.Query, error) {
+	rendered, err := elasticsearchRetriever.FilterQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(rendered)
+	if err != nil {
+		return nil, err
+	}
+	query := &types.Query{}
+	if err := json.Unmarshal(encoded, query); err != nil {
+		return nil, err
+	}
+	return query, nil
 }
 
 // createIndexIfNotExists checks if the specified index exists and creates it if not
@@ -291,14 +427,27 @@ func (e *elasticsearchRepository) createIndexIfNotExists(ctx context.Context) er
 		return err
 	}
 
+	// Map the filter fields of the documents, the other fields are mapped dynamically
+	mapping, err := json.Marshal(map[string]interface{}{
+		"properties": elasticsearchRetriever.FilterMappingProperties(),
+	})
+	if err != nil {
+		return err
+	}
+
 	if exists {
 		log.Debugf("[Elasticsearch] Index already exists: %s", e.index)
+		if _, err := e.client.Indices.PutMapping(e.index).Raw(bytes.NewReader(mapping)).Do(ctx); err != nil {
+			log.Errorf("[Elasticsearch] Failed to map filter fields: %v", err)
+			return err
+		}
 		return nil
 	}
 
 	// Create index if it doesn't exist
 	log.Infof("[Elasticsearch] Creating index: %s", e.index)
-	_, err = e.client.Indices.Create(e.index).Do(ctx)
+	body := fmt.Sprintf(`{"mappings": %s}`, mapping)
+	_, err = e.client.Indices.Create(e.index).Raw(strings.NewReader(body)).Do(ctx)
 	if err != nil {
 		log.Errorf("[Elasticsearch] Failed to create index: %v", err)
 		return err
@@ -338,7 +487,11 @@ func (e *elasticsearchRepository) VectorRetrieve(ctx context.Context,
 	log.Infof("[Elasticsearch] Vector retrieval: dim=%d, topK=%d, threshold=%.4f",
 		len(params.Embedding), params.TopK, params.Threshold)
 
-	filter := e.getBaseConds(params)
+	filter, err := e.getBaseConds(params)
+	if err != nil {
+		log.Errorf("[Elasticsearch] Failed to build filter: %v", err)
+		return nil, err
+	}
 
 	// Build script scoring query with cosine similarity
 	queryVectorJSON, err := json.Marshal(params.Embedding)
@@ -409,7 +562,11 @@ func (e *elasticsearchRepository) KeywordsRetrieve(ctx context.Context,
 	log := logger.GetLogger(ctx)
 	log.Infof("[Elasticsearch] Performing keywords retrieval with query: %s, topK: %d", params.Query, params.TopK)
 
-	filter := e.getBaseConds(params)
+	filter, err := e.getBaseConds(params)
+	if err != nil {
+		log.Errorf("[Elasticsearch] Failed to build filter: %v", err)
+		return nil, err
+	}
 	// Build must conditions for content matching
 	must := []types.Query{
 		{Match: map[string]types.MatchQuery{"content": {Query: params.Query}}},
@@ -482,7 +639,10 @@ func (e *elasticsearchRepository) CopyIndices(ctx context.Context,
 	}
 
 	// Build base query conditions
-	filter := e.getBaseConds(params)
+	filter, err := e.getBaseConds(params)
+	if err != nil {
+		return err
+	}
 
 	// Set batch processing parameters
 	batchSize := 500
@@ -567,6 +727,7 @@ func (e *elasticsearchRepository) CopyIndices(ctx context.Context,
 				ChunkID:         targetChunkID,
 				KnowledgeID:     targetKnowledgeID,
 				KnowledgeBaseID: targetKnowledgeBaseID,
+				FilterFields:    sourceDoc.IndexFilterFields,
 			}
 
 			indexInfoList = append(indexInfoList, indexInfo)
@@ -682,3 +843,35 @@ func (e *elasticsearchRepository) BatchUpdateChunkEnabledStatus(
 	log.Infof("[Elasticsearch] Successfully batch updated chunk enabled status")
 	return nil
 }
+
+// BatchUpdateKnowledgeFilterFields replaces the filter fields stored on the documents of the given knowledge
+func (e *elasticsearchRepository) BatchUpdateKnowledgeFilterFields(ctx context.Context,
+	fields map[string]*typesLocal.IndexFilterFields,
+) error {
+	log := logger.GetLogger(ctx)
+	log.Infof("[Elasticsearch] Updating filter fields of %d knowledge", len(fields))
+
+	source := elasticsearchRetriever.FilterFieldsUpdateScript
+	lang := scriptlanguage.Painless
+	for knowledgeID, knowledgeFields := range fields {
+		params, err := elasticsearchRetriever.FilterFieldsUpdateParams(knowledgeFields)
+		if err != nil {
+			return err
+		}
+		scriptParams := make(map[string]json.RawMessage, len(params))
+		for name, value := range params {
+			if scriptParams[name], err = json.Marshal(value); err != nil {
+				return err
+			}
+		}
+		script := types.Script{Source: &source, Lang: &lang, Params: scriptParams}
+		_, err = e.client.UpdateByQuery(e.index).Query(&types.Query{
+			Term: map[string]types.TermQuery{"knowledge_id.keyword": {Value: knowledgeID}},
+		}).Script(&script).Conflicts(conflicts.Proceed).Do(ctx)
+		if err != nil {
+			log.Errorf("[Elasticsearch] Failed to update filter fields of knowledge %s: %v", knowledgeID, err)
+			return err
+		}
+	}
+	return nil
+}
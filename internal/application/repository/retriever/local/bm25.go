@@ -0,0 +1,145 @@
+package local
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// keywordIndex is a BM25 inverted index over document terms
+type keywordIndex struct {
+	// postings maps a term to the documents containing it and their term frequency
+	postings map[string]map[string]int
+	// lengths maps a document ID to its token count
+	lengths     map[string]int
+	totalLength int
+}
+
+// newKeywordIndex creates an empty inverted index
+func newKeywordIndex() *keywordIndex {
+	return &keywordIndex{
+		postings: make(map[string]map[string]int),
+		lengths:  make(map[string]int),
+	}
+}
+
+// add indexes the terms of doc
+func (k *keywordIndex) add(doc *localDocument) {
+	if _, exists := k.lengths[doc.ID]; exists {
+		k.remove(doc)
+	}
+	for term, tf := range doc.Terms {
+		docs, ok := k.postings[term]
+		if !ok {
+			docs = make(map[string]int)
+			k.postings[term] = docs
+		}
+		docs[doc.ID] = tf
+	}
+	k.lengths[doc.ID] = doc.Length
+	k.totalLength += doc.Length
+}
+
+// remove drops the terms of doc from the index
+func (k *keywordIndex) remove(doc *localDocument) {
+	length, exists := k.lengths[doc.ID]
+	if !exists {
+		return
+	}
+	for term := range doc.Terms {
+		if docs, ok := k.postings[term]; ok {
+			delete(docs, doc.ID)
+			if len(docs) == 0 {
+				delete(k.postings, term)
+			}
+		}
+	}
+	delete(k.lengths, doc.ID)
+	k.totalLength -= length
+}
+
+// score computes BM25 scores of the query terms for every matching document accepted by the filter
+func (k *keywordIndex) score(queryTerms []string, accept func(docID string) bool) map[string]float64 {
+	n := float64(len(k.lengths))
+	if n == 0 {
+		return nil
+	}
+	avgLength := float64(k.totalLength) / n
+	if avgLength == 0 {
+		avgLength = 1
+	}
+
+	scores := make(map[string]float64)
+	for _, term := range queryTerms {
+		docs, ok := k.postings[term]
+		if !ok {
+			continue
+		}
+		df := float64(len(docs))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for docID, tf := range docs {
+			if !accept(docID) {
+				continue
+			}
+			freq := float64(tf)
+			norm := freq + bm25K1*(1-bm25B+bm25B*float64(k.lengths[docID])/avgLength)
+			scores[docID] += idf * freq * (bm25K1 + 1) / norm
+		}
+	}
+	return scores
+}
+
+// analyze tokenizes text into term frequencies and the total token count
+func analyze(text string) (map[string]int, int) {
+	tokens := tokenize(text)
+	terms := make(map[string]int, len(tokens))
+	for _, token := range tokens {
+		terms[token]++
+	}
+	return terms, len(tokens)
+}
+
+// queryTerms tokenizes a query into its distinct terms
+func queryTerms(query string) []string {
+	seen := make(map[string]bool)
+	result := make([]string, 0)
+	for _, token := range tokenize(query) {
+		if !seen[token] {
+			seen[token] = true
+			result = append(result, token)
+		}
+	}
+	return result
+}
+
+// tokenize splits text into lowercase terms using jieba search-mode segmentation,
+// dropping punctuation and single-character words
+func tokenize(text string) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	words := types.Jieba.CutForSearch(text, true)
+	result := make([]string, 0, len(words))
+	for _, word := range words {
+		word = strings.TrimSpace(strings.ToLower(word))
+		if utf8.RuneCountInString(word) < 2 || !strings.ContainsFunc(word, isWordRune) {
+			continue
+		}
+		result = append(result, word)
+	}
+	return result
+}
+
+// isWordRune reports whether r is a letter or digit
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,290 @@
+package local
+
+import (
+	"cmp"
+	"container/heap"
+	"math"
+	"math/rand"
+	"slices"
+)
+
+const (
+	// hnswM is the number of neighbors kept per node on the upper layers
+	hnswM = 16
+	// hnswEfConstruction is the candidate list size used while inserting
+	hnswEfConstruction = 200
+)
+
+// hnswNode is a vertex of the HNSW graph
+type hnswNode struct {
+	docID   string
+	vector  []float32
+	friends [][]int32
+	deleted bool
+}
+
+// hnswIndex is an in-memory Hierarchical Navigable Small World graph over normalized vectors.
+// Deleted nodes stay in the graph as routing points and are skipped in results until rebuild.
+type hnswIndex struct {
+	dimension  int
+	nodes      []*hnswNode
+	nodeByDoc  map[string]int32
+	entryPoint int32
+	maxLevel   int
+	deleted    int
+	levelMult  float64
+	rng        *rand.Rand
+}
+
+// newHNSWIndex creates an empty graph for vectors of the given dimension
+func newHNSWIndex(dimension int) *hnswIndex {
+	return &hnswIndex{
+		dimension:  dimension,
+		nodeByDoc:  make(map[string]int32),
+		entryPoint: -1,
+		levelMult:  1 / math.Log(float64(hnswM)),
+		rng:        rand.New(rand.NewSource(int64(dimension))),
+	}
+}
+
+// size returns the number of live vectors in the graph
+func (h *hnswIndex) size() int {
+	return len(h.nodes) - h.deleted
+}
+
+// needsRebuild reports whether tombstoned nodes dominate the graph
+func (h *hnswIndex) needsRebuild() bool {
+	return h.deleted > 1000 && h.deleted > len(h.nodes)/2
+}
+
+// insert adds a normalized vector for docID to the graph
+func (h *hnswIndex) insert(docID string, vector []float32) {
+	if _, exists := h.nodeByDoc[docID]; exists {
+		h.remove(docID)
+	}
+
+	level := int(math.Floor(-math.Log(1-h.rng.Float64()) * h.levelMult))
+	node := &hnswNode{docID: docID, vector: vector, friends: make([][]int32, level+1)}
+	id := int32(len(h.nodes))
+	h.nodes = append(h.nodes, node)
+	h.nodeByDoc[docID] = id
+
+	if h.entryPoint < 0 {
+		h.entryPoint = id
+		h.maxLevel = level
+		return
+	}
+
+	current := h.entryPoint
+	currentSim := dot(vector, h.nodes[current].vector)
+	for l := h.maxLevel; l > level; l-- {
+		current, currentSim = h.greedyClosest(vector, current, currentSim, l)
+	}
+
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		candidates := h.searchLayer(vector, []scoredNode{{id: current, score: currentSim}}, hnswEfConstruction, l)
+		maxFriends := hnswM
+		if l == 0 {
+			maxFriends = hnswM * 2
+		}
+		neighbors := selectNeighbors(candidates, maxFriends)
+		node.friends[l] = make([]int32, 0, len(neighbors))
+		for _, n := range neighbors {
+			node.friends[l] = append(node.friends[l], n.id)
+			h.link(n.id, id, l, maxFriends)
+		}
+		if len(candidates) > 0 {
+			current, currentSim = candidates[0].id, candidates[0].score
+		}
+	}
+
+	if level > h.maxLevel {
+		h.maxLevel = level
+		h.entryPoint = id
+	}
+}
+
+// remove marks the vector of docID as deleted
+func (h *hnswIndex) remove(docID string) {
+	id, ok := h.nodeByDoc[docID]
+	if !ok {
+		return
+	}
+	delete(h.nodeByDoc, docID)
+	h.nodes[id].deleted = true
+	h.deleted++
+}
+
+// search returns up to ef nearest live vectors accepted by the filter, best first
+func (h *hnswIndex) search(query []float32, ef int, accept func(docID string) bool) []scoredNode {
+	if h.entryPoint < 0 || h.size() == 0 {
+		return nil
+	}
+	current := h.entryPoint
+	currentSim := dot(query, h.nodes[current].vector)
+	for l := h.maxLevel; l > 0; l-- {
+		current, currentSim = h.greedyClosest(query, current, currentSim, l)
+	}
+	candidates := h.searchLayer(query, []scoredNode{{id: current, score: currentSim}}, ef, 0)
+
+	results := make([]scoredNode, 0, len(candidates))
+	for _, c := range candidates {
+		node := h.nodes[c.id]
+		if node.deleted || !accept(node.docID) {
+			continue
+		}
+		results = append(results, c)
+	}
+	return results
+}
+
+// greedyClosest walks layer l towards the node most similar to query
+func (h *hnswIndex) greedyClosest(query []float32, current int32, currentSim float64, l int) (int32, float64) {
+	for changed := true; changed; {
+		changed = false
+		for _, friend := range h.friendsAt(current, l) {
+			if sim := dot(query, h.nodes[friend].vector); sim > currentSim {
+				current, currentSim = friend, sim
+				changed = true
+			}
+		}
+	}
+	return current, currentSim
+}
+
+// searchLayer runs a best-first search on layer l and returns up to ef nodes, best first
+func (h *hnswIndex) searchLayer(query []float32, entries []scoredNode, ef int, l int) []scoredNode {
+	visited := make(map[int32]struct{}, ef*4)
+	candidates := &maxHeap{}
+	found := &minHeap{}
+	for _, e := range entries {
+		visited[e.id] = struct{}{}
+		heap.Push(candidates, e)
+		heap.Push(found, e)
+	}
+
+	for candidates.Len() > 0 {
+		best := heap.Pop(candidates).(scoredNode)
+		if found.Len() >= ef && best.score < (*found)[0].score {
+			break
+		}
+		for _, friend := range h.friendsAt(best.id, l) {
+			if _, seen := visited[friend]; seen {
+				continue
+			}
+			visited[friend] = struct{}{}
+			sim := dot(query, h.nodes[friend].vector)
+			if found.Len() < ef || sim > (*found)[0].score {
+				heap.Push(candidates, scoredNode{id: friend, score: sim})
+				heap.Push(found, scoredNode{id: friend, score: sim})
+				if found.Len() > ef {
+					heap.Pop(found)
+				}
+			}
+		}
+	}
+
+	results := make([]scoredNode, found.Len())
+	for i := len(results) - 1; i >= 0; i-- {
+		results[i] = heap.Pop(found).(scoredNode)
+	}
+	return results
+}
+
+// link adds a back edge from node `from` to `to` on layer l, pruning to the closest maxFriends
+func (h *hnswIndex) link(from, to int32, l int, maxFriends int) {
+	node := h.nodes[from]
+	if l >= len(node.friends) {
+		return
+	}
+	node.friends[l] = append(node.friends[l], to)
+	if len(node.friends[l]) <= maxFriends {
+		return
+	}
+	scored := make([]scoredNode, 0, len(node.friends[l]))
+	for _, f := range node.friends[l] {
+		scored = append(scored, scoredNode{id: f, score: dot(node.vector, h.nodes[f].vector)})
+	}
+	slices.SortFunc(scored, func(a, b scoredNode) int { return cmp.Compare(b.score, a.score) })
+	node.friends[l] = node.friends[l][:0]
+	for _, s := range scored[:maxFriends] {
+		node.friends[l] = append(node.friends[l], s.id)
+	}
+}
+
+// friendsAt returns the neighbors of node id on layer l
+func (h *hnswIndex) friendsAt(id int32, l int) []int32 {
+	friends := h.nodes[id].friends
+	if l >= len(friends) {
+		return nil
+	}
+	return friends[l]
+}
+
+// selectNeighbors keeps the m most similar candidates; candidates are sorted best first
+func selectNeighbors(candidates []scoredNode, m int) []scoredNode {
+	if len(candidates) <= m {
+		return candidates
+	}
+	return candidates[:m]
+}
+
+// scoredNode pairs a node with its similarity to the query
+type scoredNode struct {
+	id    int32
+	score float64
+}
+
+// minHeap keeps the worst result on top
+type minHeap []scoredNode
+
+func (h minHeap) Len() int           { return len(h) }
+func (h minHeap) Less(i, j int) bool { return h[i].score < h[j].score }
+func (h minHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *minHeap) Push(x any)        { *h = append(*h, x.(scoredNode)) }
+func (h *minHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// maxHeap keeps the best candidate on top
+type maxHeap []scoredNode
+
+func (h maxHeap) Len() int           { return len(h) }
+func (h maxHeap) Less(i, j int) bool { return h[i].score > h[j].score }
+func (h maxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *maxHeap) Push(x any)        { *h = append(*h, x.(scoredNode)) }
+func (h *maxHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// dot returns the inner product of two vectors of equal length
+func dot(a, b []float32) float64 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return float64(sum)
+}
+
+// normalize returns an L2-normalized copy of v
+func normalize(v []float32) []float32 {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	out := make([]float32, len(v))
+	if norm == 0 {
+		return out
+	}
+	inv := float32(1 / math.Sqrt(norm))
+	for i, x := range v {
+		out[i] = x * inv
+	}
+	return out
+}
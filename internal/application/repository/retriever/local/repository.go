@@ -0,0 +1,670 @@
+package local
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/Tencent/WeKnora/internal/common"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"github.com/google/uuid"
+)
+
+const (
+	fieldEmbedding    = "embedding"
+	fieldChunkEnabled = "chunk_enabled"
+
+	// bruteForceLimit is the graph size below which vector search scans all vectors exactly
+	bruteForceLimit = 10000
+	// minSearchEf is the minimum candidate list size used for HNSW search
+	minSearchEf = 200
+)
+
+// NewLocalRetrieveEngineRepository opens the embedded retriever engine stored under dir,
+// loading the segment files and rebuilding the in-memory HNSW and BM25 indices
+func NewLocalRetrieveEngineRepository(dir string) (interfaces.RetrieveEngineRepository, error) {
+	log := logger.GetLogger(context.Background())
+	log.Infof("[Local] Initializing local retriever engine repository at %s", dir)
+
+	store, err := openSegmentStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := store.load()
+	if err != nil {
+		store.close()
+		return nil, err
+	}
+
+	r := &localRepository{store: store, docs: docs}
+	r.rebuildIndices()
+
+	log.Infof("[Local] Loaded %d indices from %d segments", len(docs), len(store.manifest.Segments))
+	return r, nil
+}
+
+// EngineType returns the retriever engine type
+func (r *localRepository) EngineType() types.RetrieverEngineType {
+	return types.LocalRetrieverEngineType
+}
+
+// Support returns supported retriever types (keywords and vector)
+func (r *localRepository) Support() []types.RetrieverType {
+	return []types.RetrieverType{types.KeywordsRetrieverType, types.VectorRetrieverType}
+}
+
+// EstimateStorageSize estimates total storage size for multiple indices
+func (r *localRepository) EstimateStorageSize(ctx context.Context,
+	indexInfoList []*types.IndexInfo, params map[string]any,
+) int64 {
+	var totalStorageSize int64
+	for _, indexInfo := range indexInfoList {
+		totalStorageSize += calculateStorageSize(toLocalDocument(indexInfo, params))
+	}
+	logger.GetLogger(ctx).Infof(
+		"[Local] Estimated storage size for %d indices: %d bytes", len(indexInfoList), totalStorageSize,
+	)
+	return totalStorageSize
+}
+
+// Save stores a single index entry
+func (r *localRepository) Save(ctx context.Context, indexInfo *types.IndexInfo, params map[string]any) error {
+	logger.GetLogger(ctx).Debugf("[Local] Saving index for source ID: %s", indexInfo.SourceID)
+	return r.BatchSave(ctx, []*types.IndexInfo{indexInfo}, params)
+}
+
+// BatchSave stores multiple index entries as a new segment. Entries with the same
+// knowledge base, source ID and dimension as an existing entry replace it.
+func (r *localRepository) BatchSave(ctx context.Context,
+	indexInfoList []*types.IndexInfo, params map[string]any,
+) error {
+	log := logger.GetLogger(ctx)
+	if len(indexInfoList) == 0 {
+		log.Warn("[Local] Empty list provided to BatchSave, skipping")
+		return nil
+	}
+
+	docs := make([]*localDocument, 0, len(indexInfoList))
+	for _, indexInfo := range indexInfoList {
+		docs = append(docs, toLocalDocument(indexInfo, params))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.store.writeSegment(docs); err != nil {
+		log.Errorf("[Local] Failed to write segment: %v", err)
+		return err
+	}
+
+	sourceKeys := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		sourceKeys[sourceKey(doc)] = true
+	}
+	replaced := make([]string, 0)
+	for id, existing := range r.docs {
+		if sourceKeys[sourceKey(existing)] {
+			replaced = append(replaced, id)
+		}
+	}
+	if err := r.deleteDocs(replaced); err != nil {
+		log.Errorf("[Local] Failed to replace existing indices: %v", err)
+		return err
+	}
+	for _, doc := range docs {
+		r.addDoc(doc)
+	}
+
+	log.Infof("[Local] Successfully batch saved %d indices", len(docs))
+	return r.maybeCompact(ctx)
+}
+
+// DeleteByChunkIDList deletes indices by chunk IDs
+func (r *localRepository) DeleteByChunkIDList(ctx context.Context,
+	chunkIDList []string, dimension int, knowledgeType string,
+) error {
+	logger.GetLogger(ctx).Infof("[Local] Deleting indices by chunk IDs, count: %d", len(chunkIDList))
+	return r.deleteWhere(ctx, func(doc *localDocument) string { return doc.ChunkID }, chunkIDList)
+}
+
+// DeleteBySourceIDList deletes indices by source IDs
+func (r *localRepository) DeleteBySourceIDList(ctx context.Context,
+	sourceIDList []string, dimension int, knowledgeType string,
+) error {
+	logger.GetLogger(ctx).Infof("[Local] Deleting indices by source IDs, count: %d", len(sourceIDList))
+	return r.deleteWhere(ctx, func(doc *localDocument) string { return doc.SourceID }, sourceIDList)
+}
+
+// DeleteByKnowledgeIDList deletes indices by knowledge IDs
+func (r *localRepository) DeleteByKnowledgeIDList(ctx context.Context,
+	knowledgeIDList []string, dimension int, knowledgeType string,
+) error {
+	logger.GetLogger(ctx).Infof("[Local] Deleting indices by knowledge IDs, count: %d", len(knowledgeIDList))
+	return r.deleteWhere(ctx, func(doc *localDocument) string { return doc.KnowledgeID }, knowledgeIDList)
+}
+
+// DeleteByKnowledgeBaseID deletes all indices of a knowledge base
+func (r *localRepository) DeleteByKnowledgeBaseID(ctx context.Context,
+	knowledgeBaseID string, dimension int, knowledgeType string,
+) error {
+	logger.GetLogger(ctx).Infof("[Local] Deleting indices by knowledge base ID: %s", knowledgeBaseID)
+	return r.deleteWhere(ctx, func(doc *localDocument) string { return doc.KnowledgeBaseID }, []string{knowledgeBaseID})
+}
+
+// PromoteShadowIndices replaces the indices of the target knowledge base with the shadow indices.
+// Relabeled shadow documents keep their IDs and are written as a new segment that overrides the
+// old copies on load, before the replaced documents are deleted.
+func (r *localRepository) PromoteShadowIndices(ctx context.Context,
+	shadowKnowledgeBaseID string, targetKnowledgeBaseID string, shadowDimension int, targetDimension int,
+) error {
+	log := logger.GetLogger(ctx)
+	log.Infof("[Local] Promoting shadow indices %s to knowledge base %s", shadowKnowledgeBaseID, targetKnowledgeBaseID)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	replaced := make([]string, 0)
+	promoted := make([]*localDocument, 0)
+	for id, doc := range r.docs {
+		switch doc.KnowledgeBaseID {
+		case targetKnowledgeBaseID:
+			replaced = append(replaced, id)
+		case shadowKnowledgeBaseID:
+			relabeled := *doc
+			relabeled.KnowledgeBaseID = targetKnowledgeBaseID
+			promoted = append(promoted, &relabeled)
+		}
+	}
+
+	if err := r.store.writeSegment(promoted); err != nil {
+		log.Errorf("[Local] Failed to write promoted indices: %v", err)
+		return err
+	}
+	if err := r.deleteDocs(replaced); err != nil {
+		log.Errorf("[Local] Failed to delete replaced indices: %v", err)
+		return err
+	}
+	// Terms and vectors are unchanged, so the in-memory indices only need the new document
+	for _, doc := range promoted {
+		r.docs[doc.ID] = doc
+	}
+
+	log.Infof("[Local] Promoted %d shadow indices, replaced %d indices", len(promoted), len(replaced))
+	return r.maybeCompact(ctx)
+}
+
+// BatchUpdateChunkEnabledStatus updates the enabled status of chunks in batch
+func (r *localRepository) BatchUpdateChunkEnabledStatus(ctx context.Context, chunkStatusMap map[string]bool) error {
+	log := logger.GetLogger(ctx)
+	if len(chunkStatusMap) == 0 {
+		log.Warn("[Local] Empty chunk status map provided, skipping")
+		return nil
+	}
+	log.Infof("[Local] Batch updating chunk enabled status, count: %d", len(chunkStatusMap))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	enabledIDs := make([]string, 0)
+	disabledIDs := make([]string, 0)
+	for id, doc := range r.docs {
+		enabled, ok := chunkStatusMap[doc.ChunkID]
+		if !ok || doc.IsEnabled == enabled {
+			continue
+		}
+		if enabled {
+			enabledIDs = append(enabledIDs, id)
+		} else {
+			disabledIDs = append(disabledIDs, id)
+		}
+	}
+
+	for _, op := range []operation{
+		{Op: opSetStatus, IDs: enabledIDs, Enabled: true},
+		{Op: opSetStatus, IDs: disabledIDs, Enabled: false},
+	} {
+		if err := r.store.appendOperation(op); err != nil {
+			log.Errorf("[Local] Failed to update chunk enabled status: %v", err)
+			return err
+		}
+		applyOperation(r.docs, op)
+	}
+
+	log.Infof("[Local] Updated %d chunks to enabled, %d chunks to disabled", len(enabledIDs), len(disabledIDs))
+	return r.maybeCompact(ctx)
+}
+
+// BatchUpdateKnowledgeFilterFields replaces the filter fields stored on the documents of the given knowledge
+func (r *localRepository) BatchUpdateKnowledgeFilterFields(ctx context.Context,
+	fields map[string]*types.IndexFilterFields,
+) error {
+	log := logger.GetLogger(ctx)
+	log.Infof("[Local] Updating filter fields of %d knowledge", len(fields))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make(map[string][]string, len(fields))
+	for id, doc := range r.docs {
+		if _, ok := fields[doc.KnowledgeID]; ok {
+			ids[doc.KnowledgeID] = append(ids[doc.KnowledgeID], id)
+		}
+	}
+	for knowledgeID, docIDs := range ids {
+		op := operation{Op: opSetFilterFields, IDs: docIDs, FilterFields: fields[knowledgeID]}
+		if err := r.store.appendOperation(op); err != nil {
+			log.Errorf("[Local] Failed to update filter fields: %v", err)
+			return err
+		}
+		applyOperation(r.docs, op)
+	}
+	return r.maybeCompact(ctx)
+}
+
+// Retrieve handles retrieval requests and routes to appropriate method
+func (r *localRepository) Retrieve(ctx context.Context, params types.RetrieveParams) ([]*types.RetrieveResult, error) {
+	logger.GetLogger(ctx).Debugf("[Local] Processing retrieval request of type: %s", params.RetrieverType)
+	switch params.RetrieverType {
+	case types.KeywordsRetrieverType:
+		return r.KeywordsRetrieve(ctx, params)
+	case types.VectorRetrieverType:
+		return r.VectorRetrieve(ctx, params)
+	}
+	err := fmt.Errorf("invalid retriever type: %v", params.RetrieverType)
+	logger.GetLogger(ctx).Errorf("[Local] %v", err)
+	return nil, err
+}
+
+// KeywordsRetrieve performs BM25 keyword search over the inverted index
+func (r *localRepository) KeywordsRetrieve(ctx context.Context,
+	params types.RetrieveParams,
+) ([]*types.RetrieveResult, error) {
+	log := logger.GetLogger(ctx)
+	log.Infof("[Local] Keywords retrieval: query=%s, topK=%d", params.Query, params.TopK)
+
+	terms := queryTerms(params.Query)
+	if len(terms) == 0 {
+		log.Warnf("[Local] No searchable terms in query: %s", params.Query)
+		return buildRetrieveResult(nil, types.KeywordsRetrieverType), nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	accept := r.filter(params)
+	scores := r.keywords.score(terms, func(id string) bool { return accept(r.docs[id]) })
+
+	results := make([]*types.IndexWithScore, 0, len(scores))
+	for id, score := range scores {
+		results = append(results, fromLocalDocument(r.docs[id], score, types.MatchTypeKeywords))
+	}
+	results = topResults(results, params.TopK)
+
+	log.Infof("[Local] Keywords retrieval found %d results", len(results))
+	return buildRetrieveResult(results, types.KeywordsRetrieverType), nil
+}
+
+// VectorRetrieve performs cosine similarity search. Small graphs are scanned exactly,
+// larger ones are searched through HNSW with a candidate list widened until TopK
+// filtered results are found.
+func (r *localRepository) VectorRetrieve(ctx context.Context,
+	params types.RetrieveParams,
+) ([]*types.RetrieveResult, error) {
+	log := logger.GetLogger(ctx)
+	dimension := len(params.Embedding)
+	log.Infof("[Local] Vector retrieval: dim=%d, topK=%d, threshold=%.4f", dimension, params.TopK, params.Threshold)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	graph, ok := r.vectors[dimension]
+	if !ok || graph.size() == 0 {
+		log.Warnf("[Local] No vectors with dimension %d, returning empty results", dimension)
+		return buildRetrieveResult(nil, types.VectorRetrieverType), nil
+	}
+
+	query := normalize(params.Embedding)
+	accept := r.filter(params)
+	results := make([]*types.IndexWithScore, 0)
+	collect := func(doc *localDocument, score float64) {
+		if score >= params.Threshold {
+			results = append(results, fromLocalDocument(doc, score, types.MatchTypeEmbedding))
+		}
+	}
+
+	if graph.size() <= bruteForceLimit {
+		for _, node := range graph.nodes {
+			if doc := r.docs[node.docID]; !node.deleted && accept(doc) {
+				collect(doc, dot(query, node.vector))
+			}
+		}
+	} else {
+		for ef := max(params.TopK*10, minSearchEf); ; ef *= 2 {
+			nodes := graph.search(query, ef, func(id string) bool { return accept(r.docs[id]) })
+			if len(nodes) >= params.TopK || ef >= graph.size() {
+				for _, n := range nodes {
+					collect(r.docs[graph.nodes[n.id].docID], n.score)
+				}
+				break
+			}
+		}
+	}
+	results = topResults(results, params.TopK)
+
+	if len(results) == 0 {
+		log.Warnf("[Local] No vector matches found that meet threshold %.4f", params.Threshold)
+	} else {
+		log.Infof("[Local] Vector retrieval found %d results, top score: %.4f", len(results), results[0].Score)
+	}
+	return buildRetrieveResult(results, types.VectorRetrieverType), nil
+}
+
+// CopyIndices copies index data from source knowledge base to target knowledge base
+func (r *localRepository) CopyIndices(ctx context.Context,
+	sourceKnowledgeBaseID string,
+	sourceToTargetKBIDMap map[string]string,
+	sourceToTargetChunkIDMap map[string]string,
+	targetKnowledgeBaseID string,
+	dimension int,
+	knowledgeType string,
+) error {
+	log := logger.GetLogger(ctx)
+	log.Infof(
+		"[Local] Copying indices, source knowledge base: %s, target knowledge base: %s, mapping count: %d",
+		sourceKnowledgeBaseID, targetKnowledgeBaseID, len(sourceToTargetChunkIDMap),
+	)
+	if len(sourceToTargetChunkIDMap) == 0 {
+		log.Warn("[Local] Mapping is empty, no need to copy")
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	targets := make([]*localDocument, 0)
+	for _, source := range r.docs {
+		if source.KnowledgeBaseID != sourceKnowledgeBaseID {
+			continue
+		}
+		targetChunkID, ok := sourceToTargetChunkIDMap[source.ChunkID]
+		if !ok {
+			log.Warnf("[Local] Source chunk %s not found in target chunk mapping, skipping", source.ChunkID)
+			continue
+		}
+		targetKnowledgeID, ok := sourceToTargetKBIDMap[source.KnowledgeID]
+		if !ok {
+			log.Warnf("[Local] Source knowledge %s not found in target knowledge mapping, skipping", source.KnowledgeID)
+			continue
+		}
+
+		// Handle SourceID transformation for generated questions
+		// Generated questions have SourceID format: {chunkID}-{questionID}
+		// Regular chunks have SourceID == ChunkID
+		var targetSourceID string
+		if source.SourceID == source.ChunkID {
+			targetSourceID = targetChunkID
+		} else if strings.HasPrefix(source.SourceID, source.ChunkID+"-") {
+			questionID := strings.TrimPrefix(source.SourceID, source.ChunkID+"-")
+			targetSourceID = fmt.Sprintf("%s-%s", targetChunkID, questionID)
+		} else {
+			targetSourceID = uuid.New().String()
+		}
+
+		targets = append(targets, &localDocument{
+			ID:              uuid.New().String(),
+			Content:         source.Content,
+			SourceID:        targetSourceID,
+			SourceType:      source.SourceType,
+			ChunkID:         targetChunkID,
+			KnowledgeID:     targetKnowledgeID,
+			KnowledgeBaseID: targetKnowledgeBaseID,
+			Embedding:       source.Embedding,
+			IsEnabled:       true,
+			Terms:           source.Terms,
+			Length:          source.Length,
+			FilterFields:    source.FilterFields,
+		})
+	}
+
+	if len(targets) == 0 {
+		log.Warn("[Local] No source index data found")
+		return nil
+	}
+	if err := r.store.writeSegment(targets); err != nil {
+		log.Errorf("[Local] Failed to write copied indices: %v", err)
+		return err
+	}
+	for _, doc := range targets {
+		r.addDoc(doc)
+	}
+
+	log.Infof("[Local] Index copying completed, total copied: %d", len(targets))
+	return r.maybeCompact(ctx)
+}
+
+// filter builds the predicate shared by keyword and vector retrieval
+func (r *localRepository) filter(params types.RetrieveParams) func(doc *localDocument) bool {
+	knowledgeBaseIDs := toSet(params.KnowledgeBaseIDs)
+	knowledgeIDs := toSet(params.KnowledgeIDs)
+	excludeKnowledgeIDs := toSet(params.ExcludeKnowledgeIDs)
+	excludeChunkIDs := toSet(params.ExcludeChunkIDs)
+	retrievalFilter := params.Filter
+
+	// KnowledgeBaseIDs and KnowledgeIDs use AND logic
+	// - If only KnowledgeBaseIDs: search entire knowledge bases
+	// - If only KnowledgeIDs: search specific documents
+	// - If both: search specific documents within the knowledge bases (AND)
+	return func(doc *localDocument) bool {
+		if doc == nil || !doc.IsEnabled {
+			return false
+		}
+		if len(knowledgeBaseIDs) > 0 && !knowledgeBaseIDs[doc.KnowledgeBaseID] {
+			return false
+		}
+		if len(knowledgeIDs) > 0 && !knowledgeIDs[doc.KnowledgeID] {
+			return false
+		}
+		if retrievalFilter != nil && !retrievalFilter.Match(doc.FilterFields) {
+			return false
+		}
+		return !excludeKnowledgeIDs[doc.KnowledgeID] && !excludeChunkIDs[doc.ChunkID]
+	}
+}
+
+// deleteWhere deletes every document whose key is in values
+func (r *localRepository) deleteWhere(ctx context.Context, key func(doc *localDocument) string, values []string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	wanted := toSet(values)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]string, 0)
+	for id, doc := range r.docs {
+		if wanted[key(doc)] {
+			ids = append(ids, id)
+		}
+	}
+	if err := r.deleteDocs(ids); err != nil {
+		logger.GetLogger(ctx).Errorf("[Local] Failed to delete indices: %v", err)
+		return err
+	}
+	logger.GetLogger(ctx).Infof("[Local] Successfully deleted %d indices", len(ids))
+	return r.maybeCompact(ctx)
+}
+
+// deleteDocs logs a delete operation and removes the documents from memory; caller holds the lock
+func (r *localRepository) deleteDocs(ids []string) error {
+	if err := r.store.appendOperation(operation{Op: opDelete, IDs: ids}); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		doc, ok := r.docs[id]
+		if !ok {
+			continue
+		}
+		if graph, ok := r.vectors[doc.dimension()]; ok {
+			graph.remove(id)
+		}
+		r.keywords.remove(doc)
+		delete(r.docs, id)
+	}
+	return nil
+}
+
+// addDoc inserts a document into memory and the indices; caller holds the lock
+func (r *localRepository) addDoc(doc *localDocument) {
+	r.docs[doc.ID] = doc
+	r.keywords.add(doc)
+	if dimension := doc.dimension(); dimension > 0 {
+		graph, ok := r.vectors[dimension]
+		if !ok {
+			graph = newHNSWIndex(dimension)
+			r.vectors[dimension] = graph
+		}
+		graph.insert(doc.ID, doc.Embedding)
+	}
+}
+
+// rebuildIndices rebuilds the HNSW graphs and the inverted index from the live documents
+func (r *localRepository) rebuildIndices() {
+	r.vectors = make(map[int]*hnswIndex)
+	r.keywords = newKeywordIndex()
+	ids := slices.Sorted(maps.Keys(r.docs))
+	for _, id := range ids {
+		r.addDoc(r.docs[id])
+	}
+}
+
+// maybeCompact merges segments and drops tombstoned graph nodes once they pile up; caller holds the lock
+func (r *localRepository) maybeCompact(ctx context.Context) error {
+	rebuild := false
+	for _, graph := range r.vectors {
+		rebuild = rebuild || graph.needsRebuild()
+	}
+	if rebuild {
+		logger.GetLogger(ctx).Infof("[Local] Rebuilding vector indices")
+		r.rebuildIndices()
+	}
+	if !r.store.needsCompaction() {
+		return nil
+	}
+
+	logger.GetLogger(ctx).Infof("[Local] Compacting %d segments into one", len(r.store.manifest.Segments))
+	if err := r.store.compact(slices.Collect(maps.Values(r.docs))); err != nil {
+		logger.GetLogger(ctx).Errorf("[Local] Compaction failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// toLocalDocument converts IndexInfo to the persisted document format
+func toLocalDocument(indexInfo *types.IndexInfo, additionalParams map[string]any) *localDocument {
+	content := common.CleanInvalidUTF8(indexInfo.Content)
+	terms, length := analyze(content)
+	doc := &localDocument{
+		ID:              uuid.New().String(),
+		Content:         content,
+		SourceID:        indexInfo.SourceID,
+		SourceType:      int(indexInfo.SourceType),
+		ChunkID:         indexInfo.ChunkID,
+		KnowledgeID:     indexInfo.KnowledgeID,
+		KnowledgeBaseID: indexInfo.KnowledgeBaseID,
+		IsEnabled:       true, // Default to enabled
+		Terms:           terms,
+		Length:          length,
+		FilterFields:    indexInfo.FilterFields,
+	}
+	if additionalParams == nil {
+		return doc
+	}
+	if embeddingMap, ok := additionalParams[fieldEmbedding].(map[string][]float32); ok {
+		if embedding := embeddingMap[indexInfo.SourceID]; len(embedding) > 0 {
+			doc.Embedding = normalize(embedding)
+		}
+	}
+	if chunkEnabledMap, ok := additionalParams[fieldChunkEnabled].(map[string]bool); ok {
+		if enabled, exists := chunkEnabledMap[indexInfo.ChunkID]; exists {
+			doc.IsEnabled = enabled
+		}
+	}
+	return doc
+}
+
+// fromLocalDocument converts a document to IndexWithScore domain model
+func fromLocalDocument(doc *localDocument, score float64, matchType types.MatchType) *types.IndexWithScore {
+	return &types.IndexWithScore{
+		ID:              doc.ID,
+		Content:         doc.Content,
+		SourceID:        doc.SourceID,
+		SourceType:      types.SourceType(doc.SourceType),
+		ChunkID:         doc.ChunkID,
+		KnowledgeID:     doc.KnowledgeID,
+		KnowledgeBaseID: doc.KnowledgeBaseID,
+		Score:           score,
+		MatchType:       matchType,
+		IsEnabled:       doc.IsEnabled,
+	}
+}
+
+// calculateStorageSize estimates the on-disk and in-memory footprint of a document
+func calculateStorageSize(doc *localDocument) int64 {
+	// Content and identifiers
+	size := int64(len(doc.Content) + len(doc.SourceID) + len(doc.ChunkID) +
+		len(doc.KnowledgeID) + len(doc.KnowledgeBaseID) + len(doc.ID))
+	// Inverted index postings: term bytes plus document reference per distinct term
+	for term := range doc.Terms {
+		size += int64(len(term)) + 16
+	}
+	// Vector (4 bytes per dimension) and HNSW links on layer 0 (2*M neighbors of 4 bytes)
+	if dimension := doc.dimension(); dimension > 0 {
+		size += int64(dimension)*4 + hnswM*2*4
+	}
+	return size
+}
+
+// topResults sorts results by descending score and truncates them to topK
+func topResults(results []*types.IndexWithScore, topK int) []*types.IndexWithScore {
+	slices.SortFunc(results, func(a, b *types.IndexWithScore) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	if topK > 0 && len(results) > topK {
+		results = results[:topK]
+	}
+	return results
+}
+
+func buildRetrieveResult(results []*types.IndexWithScore, retrieverType types.RetrieverType) []*types.RetrieveResult {
+	return []*types.RetrieveResult{
+		{
+			Results:             results,
+			RetrieverEngineType: types.LocalRetrieverEngineType,
+			RetrieverType:       retrieverType,
+			Error:               nil,
+		},
+	}
+}
+
+// sourceKey identifies the entry a document replaces on save
+func sourceKey(doc *localDocument) string {
+	return fmt.Sprintf("%s:%s:%d", doc.KnowledgeBaseID, doc.SourceID, doc.dimension())
+}
+
+// toSet converts a string slice to a lookup set
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
@@ -0,0 +1,230 @@
+package local
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestIndexInfo(chunkID, knowledgeID, kbID, content string) *types.IndexInfo {
+	return &types.IndexInfo{
+		Content:         content,
+		SourceID:        chunkID,
+		ChunkID:         chunkID,
+		KnowledgeID:     knowledgeID,
+		KnowledgeBaseID: kbID,
+	}
+}
+
+func saveTestData(t *testing.T, repo *localRepository) {
+	infos := []*types.IndexInfo{
+		newTestIndexInfo("c1", "k1", "kb1", "WeKnora supports hybrid retrieval with keywords and vectors"),
+		newTestIndexInfo("c2", "k1", "kb1", "The embedded engine stores segments on local disk"),
+		newTestIndexInfo("c3", "k2", "kb2", "Elasticsearch clusters are not needed for laptops"),
+	}
+	embeddings := map[string][]float32{
+		"c1": {1, 0, 0},
+		"c2": {0, 1, 0},
+		"c3": {0.9, 0.1, 0},
+	}
+	require.NoError(t, repo.BatchSave(context.Background(), infos, map[string]any{"embedding": embeddings}))
+}
+
+func openTestRepository(t *testing.T, dir string) *localRepository {
+	repo, err := NewLocalRetrieveEngineRepository(dir)
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.(*localRepository).store.close() })
+	return repo.(*localRepository)
+}
+
+func chunkIDs(results []*types.RetrieveResult) []string {
+	ids := make([]string, 0)
+	for _, r := range results {
+		for _, item := range r.Results {
+			ids = append(ids, item.ChunkID)
+		}
+	}
+	return ids
+}
+
+func TestLocalRepository_Retrieve(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestRepository(t, t.TempDir())
+	saveTestData(t, repo)
+
+	vector, err := repo.Retrieve(ctx, types.RetrieveParams{
+		Embedding:     []float32{1, 0, 0},
+		TopK:          10,
+		Threshold:     0.5,
+		RetrieverType: types.VectorRetrieverType,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c1", "c3"}, chunkIDs(vector))
+
+	vector, err = repo.Retrieve(ctx, types.RetrieveParams{
+		Embedding:        []float32{1, 0, 0},
+		KnowledgeBaseIDs: []string{"kb1"},
+		TopK:             10,
+		RetrieverType:    types.VectorRetrieverType,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c1", "c2"}, chunkIDs(vector))
+
+	keywords, err := repo.Retrieve(ctx, types.RetrieveParams{
+		Query:         "local disk segments",
+		TopK:          10,
+		RetrieverType: types.KeywordsRetrieverType,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c2"}, chunkIDs(keywords))
+}
+
+func TestLocalRepository_DeleteAndStatus(t *testing.T) {
+	ctx := context.Background()
+	repo := openTestRepository(t, t.TempDir())
+	saveTestData(t, repo)
+
+	params := types.RetrieveParams{Embedding: []float32{1, 0, 0}, TopK: 10, RetrieverType: types.VectorRetrieverType}
+
+	require.NoError(t, repo.BatchUpdateChunkEnabledStatus(ctx, map[string]bool{"c1": false}))
+	results, err := repo.Retrieve(ctx, params)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c3", "c2"}, chunkIDs(results))
+
+	require.NoError(t, repo.DeleteByKnowledgeIDList(ctx, []string{"k2"}, 3, ""))
+	results, err = repo.Retrieve(ctx, params)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c2"}, chunkIDs(results))
+
+	require.NoError(t, repo.DeleteBySourceIDList(ctx, []string{"c2"}, 3, ""))
+	require.NoError(t, repo.DeleteByChunkIDList(ctx, []string{"c1"}, 3, ""))
+	assert.Empty(t, repo.docs)
+}
+
+func TestLocalRepository_PersistenceAndCopy(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repo := openTestRepository(t, dir)
+	saveTestData(t, repo)
+	require.NoError(t, repo.BatchUpdateChunkEnabledStatus(ctx, map[string]bool{"c2": false}))
+	require.NoError(t, repo.DeleteByChunkIDList(ctx, []string{"c3"}, 3, ""))
+	require.NoError(t, repo.CopyIndices(ctx, "kb1",
+		map[string]string{"k1": "k1-copy"},
+		map[string]string{"c1": "c1-copy", "c2": "c2-copy"},
+		"kb1-copy", 3, ""))
+	require.NoError(t, repo.store.close())
+
+	reopened := openTestRepository(t, dir)
+	assert.Len(t, reopened.docs, 4)
+
+	results, err := reopened.Retrieve(ctx, types.RetrieveParams{
+		Embedding:        []float32{0, 1, 0},
+		KnowledgeBaseIDs: []string{"kb1", "kb1-copy"},
+		TopK:             10,
+		Threshold:        0.5,
+		RetrieverType:    types.VectorRetrieverType,
+	})
+	require.NoError(t, err)
+	// c2 is disabled in the source, copies are always enabled
+	assert.Equal(t, []string{"c2-copy"}, chunkIDs(results))
+
+	require.NoError(t, reopened.store.compact(nil))
+	assert.Empty(t, reopened.store.manifest.Segments)
+}
+
+func TestLocalRepository_PromoteShadowIndices(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repo := openTestRepository(t, dir)
+	saveTestData(t, repo)
+
+	// Re-embed kb1 into a shadow knowledge base with a different dimension
+	shadow := []*types.IndexInfo{
+		newTestIndexInfo("c1", "k1", "kb1-shadow", "WeKnora supports hybrid retrieval with keywords and vectors"),
+		newTestIndexInfo("c2", "k1", "kb1-shadow", "The embedded engine stores segments on local disk"),
+	}
+	embeddings := map[string][]float32{"c1": {1, 0}, "c2": {0, 1}}
+	require.NoError(t, repo.BatchSave(ctx, shadow, map[string]any{"embedding": embeddings}))
+	assert.Len(t, repo.docs, 5)
+
+	require.NoError(t, repo.PromoteShadowIndices(ctx, "kb1-shadow", "kb1", 2, 3))
+	require.NoError(t, repo.store.close())
+
+	reopened := openTestRepository(t, dir)
+	assert.Len(t, reopened.docs, 3)
+	results, err := reopened.Retrieve(ctx, types.RetrieveParams{
+		Embedding:        []float32{0, 1},
+		KnowledgeBaseIDs: []string{"kb1"},
+		TopK:             10,
+		Threshold:        0.5,
+		RetrieverType:    types.VectorRetrieverType,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c2"}, chunkIDs(results))
+
+	results, err = reopened.Retrieve(ctx, types.RetrieveParams{
+		Embedding:        []float32{1, 0, 0},
+		KnowledgeBaseIDs: []string{"kb1"},
+		TopK:             10,
+		RetrieverType:    types.VectorRetrieverType,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, chunkIDs(results))
+
+	require.NoError(t, reopened.DeleteByKnowledgeBaseID(ctx, "kb2", 3, ""))
+	assert.Len(t, reopened.docs, 2)
+}
+
+func TestHNSWIndex_Recall(t *testing.T) {
+	const (
+		dimension = 16
+		count     = 2000
+		topK      = 10
+	)
+	rng := rand.New(rand.NewSource(42))
+	randomVector := func() []float32 {
+		v := make([]float32, dimension)
+		for i := range v {
+			v[i] = rng.Float32()*2 - 1
+		}
+		return normalize(v)
+	}
+
+	graph := newHNSWIndex(dimension)
+	for i := 0; i < count; i++ {
+		graph.insert(fmt.Sprintf("doc-%d", i), randomVector())
+	}
+
+	hits := 0
+	for q := 0; q < 20; q++ {
+		query := randomVector()
+		exact := make([]scoredNode, 0, count)
+		for i, node := range graph.nodes {
+			exact = append(exact, scoredNode{id: int32(i), score: dot(query, node.vector)})
+		}
+		truth := make(map[int32]bool)
+		for _, n := range bestOf(exact, topK) {
+			truth[n.id] = true
+		}
+		for _, n := range graph.search(query, minSearchEf, func(string) bool { return true })[:topK] {
+			if truth[n.id] {
+				hits++
+			}
+		}
+	}
+	recall := float64(hits) / float64(20*topK)
+	assert.Greater(t, recall, 0.9)
+}
+
+func bestOf(nodes []scoredNode, k int) []scoredNode {
+	sorted := slices.Clone(nodes)
+	slices.SortFunc(sorted, func(a, b scoredNode) int { return cmp.Compare(b.score, a.score) })
+	return sorted[:k]
+}
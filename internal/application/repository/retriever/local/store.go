@@ -0,0 +1,280 @@
+package local
+
+import (
+	"bufio"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	manifestFileName = "MANIFEST.json"
+	segmentPrefix    = "seg-"
+	segmentSuffix    = ".gob"
+	opLogPrefix      = "ops-"
+	opLogSuffix      = ".log"
+
+	// maxSegments triggers a compaction once the number of segment files exceeds it
+	maxSegments = 32
+	// maxOperations triggers a compaction once the operation log grows beyond it
+	maxOperations = 1000
+)
+
+// segmentStore persists documents as immutable segment files. Deletes and status
+// changes are appended to an operation log and folded into a single segment on compaction.
+type segmentStore struct {
+	dir      string
+	manifest manifest
+	opLog    *os.File
+	opCount  int
+}
+
+// openSegmentStore opens (or initializes) the store rooted at dir
+func openSegmentStore(dir string) (*segmentStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create index directory: %w", err)
+	}
+	s := &segmentStore{dir: dir}
+
+	data, err := os.ReadFile(filepath.Join(dir, manifestFileName))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		s.manifest = manifest{NextSegmentID: 1}
+		s.manifest.OpLog = s.nextFileName(opLogPrefix, opLogSuffix)
+		if err := s.writeManifest(); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	default:
+		if err := json.Unmarshal(data, &s.manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest: %w", err)
+		}
+	}
+
+	s.opLog, err = os.OpenFile(filepath.Join(dir, s.manifest.OpLog), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open operation log: %w", err)
+	}
+	s.removeOrphans()
+	return s, nil
+}
+
+// load reads every segment and replays the operation log, returning the live documents
+func (s *segmentStore) load() (map[string]*localDocument, error) {
+	docs := make(map[string]*localDocument)
+	for _, name := range s.manifest.Segments {
+		seg, err := readSegment(filepath.Join(s.dir, name))
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range seg.Docs {
+			docs[doc.ID] = doc
+		}
+	}
+
+	if _, err := s.opLog.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind operation log: %w", err)
+	}
+	scanner := bufio.NewScanner(s.opLog)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var op operation
+		if err := json.Unmarshal(scanner.Bytes(), &op); err != nil {
+			// A torn write can only affect the last line, everything before it is valid
+			break
+		}
+		applyOperation(docs, op)
+		s.opCount++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read operation log: %w", err)
+	}
+	return docs, nil
+}
+
+// applyOperation applies a logged operation to the document set
+func applyOperation(docs map[string]*localDocument, op operation) {
+	for _, id := range op.IDs {
+		switch op.Op {
+		case opDelete:
+			delete(docs, id)
+		case opSetStatus:
+			if doc, ok := docs[id]; ok {
+				doc.IsEnabled = op.Enabled
+			}
+		case opSetFilterFields:
+			if doc, ok := docs[id]; ok {
+				doc.FilterFields = op.FilterFields
+			}
+		}
+	}
+}
+
+// writeSegment persists docs as a new immutable segment file
+func (s *segmentStore) writeSegment(docs []*localDocument) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	name := s.nextFileName(segmentPrefix, segmentSuffix)
+	if err := writeSegmentFile(filepath.Join(s.dir, name), docs); err != nil {
+		return err
+	}
+	s.manifest.Segments = append(s.manifest.Segments, name)
+	return s.writeManifest()
+}
+
+// appendOperation appends op to the operation log and syncs it to disk
+func (s *segmentStore) appendOperation(op operation) error {
+	if len(op.IDs) == 0 {
+		return nil
+	}
+	line, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	if _, err := s.opLog.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write operation log: %w", err)
+	}
+	if err := s.opLog.Sync(); err != nil {
+		return fmt.Errorf("failed to sync operation log: %w", err)
+	}
+	s.opCount++
+	return nil
+}
+
+// needsCompaction reports whether the segments and operation log should be merged
+func (s *segmentStore) needsCompaction() bool {
+	return len(s.manifest.Segments) > maxSegments || s.opCount > maxOperations
+}
+
+// compact rewrites the live documents into a single segment with an empty operation log.
+// The manifest is swapped atomically, so a crash leaves either the old or the new state.
+func (s *segmentStore) compact(docs []*localDocument) error {
+	oldFiles := append(slices.Clone(s.manifest.Segments), s.manifest.OpLog)
+
+	next := manifest{NextSegmentID: s.manifest.NextSegmentID}
+	if len(docs) > 0 {
+		name := fmt.Sprintf("%s%06d%s", segmentPrefix, next.NextSegmentID, segmentSuffix)
+		next.NextSegmentID++
+		if err := writeSegmentFile(filepath.Join(s.dir, name), docs); err != nil {
+			return err
+		}
+		next.Segments = []string{name}
+	}
+	next.OpLog = fmt.Sprintf("%s%06d%s", opLogPrefix, next.NextSegmentID, opLogSuffix)
+	next.NextSegmentID++
+
+	opLog, err := os.OpenFile(filepath.Join(s.dir, next.OpLog), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create operation log: %w", err)
+	}
+	s.manifest = next
+	if err := s.writeManifest(); err != nil {
+		opLog.Close()
+		return err
+	}
+
+	s.opLog.Close()
+	s.opLog = opLog
+	s.opCount = 0
+	for _, name := range oldFiles {
+		os.Remove(filepath.Join(s.dir, name))
+	}
+	return nil
+}
+
+// close releases the operation log file handle
+func (s *segmentStore) close() error {
+	return s.opLog.Close()
+}
+
+// nextFileName allocates a new file name with the given prefix and suffix
+func (s *segmentStore) nextFileName(prefix, suffix string) string {
+	name := fmt.Sprintf("%s%06d%s", prefix, s.manifest.NextSegmentID, suffix)
+	s.manifest.NextSegmentID++
+	return name
+}
+
+// writeManifest atomically replaces the manifest file
+func (s *segmentStore) writeManifest() error {
+	data, err := json.Marshal(s.manifest)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(s.dir, manifestFileName), func(f *os.File) error {
+		_, err := f.Write(data)
+		return err
+	})
+}
+
+// removeOrphans deletes segment and log files left behind by an interrupted write or compaction
+func (s *segmentStore) removeOrphans() {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		isData := strings.HasPrefix(name, segmentPrefix) || strings.HasPrefix(name, opLogPrefix)
+		if !isData || name == s.manifest.OpLog || slices.Contains(s.manifest.Segments, name) {
+			continue
+		}
+		os.Remove(filepath.Join(s.dir, name))
+	}
+}
+
+// readSegment decodes a segment file
+func readSegment(path string) (*segment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open segment %s: %w", filepath.Base(path), err)
+	}
+	defer f.Close()
+	seg := &segment{}
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(seg); err != nil {
+		return nil, fmt.Errorf("failed to decode segment %s: %w", filepath.Base(path), err)
+	}
+	return seg, nil
+}
+
+// writeSegmentFile encodes docs into a segment file
+func writeSegmentFile(path string, docs []*localDocument) error {
+	return writeFileAtomic(path, func(f *os.File) error {
+		w := bufio.NewWriter(f)
+		if err := gob.NewEncoder(w).Encode(&segment{Docs: docs}); err != nil {
+			return err
+		}
+		return w.Flush()
+	})
+}
+
+// writeFileAtomic writes to a temporary file and renames it over path once synced
+func writeFileAtomic(path string, write func(f *os.File) error) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Base(tmp), err)
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to sync %s: %w", filepath.Base(path), err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
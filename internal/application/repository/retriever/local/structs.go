@@ -0,0 +1,74 @@
+package local
+
+import (
+	"sync"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// localRepository is an embedded retriever engine that keeps all indices on local disk.
+// Vectors are searched through per-dimension HNSW graphs and keywords through a BM25
+// inverted index, both rebuilt in memory from the segment files on startup.
+type localRepository struct {
+	mu sync.RWMutex
+	// store persists documents as immutable segment files plus an operation log
+	store *segmentStore
+	// docs holds all live documents keyed by document ID
+	docs map[string]*localDocument
+	// vectors holds one HNSW graph per embedding dimension
+	vectors map[int]*hnswIndex
+	// keywords is the BM25 inverted index over all live documents
+	keywords *keywordIndex
+}
+
+// localDocument is the persisted representation of a single index entry
+type localDocument struct {
+	ID              string
+	Content         string
+	SourceID        string
+	SourceType      int
+	ChunkID         string
+	KnowledgeID     string
+	KnowledgeBaseID string
+	// Embedding is L2-normalized, so cosine similarity is a plain dot product
+	Embedding []float32
+	IsEnabled bool
+	// Terms holds the term frequencies used by the BM25 index
+	Terms map[string]int
+	// Length is the number of tokens in the content
+	Length int
+	// FilterFields are the fields of the knowledge matched by retrieval filters
+	FilterFields *types.IndexFilterFields
+}
+
+// dimension returns the embedding dimension of the document, 0 for keyword-only entries
+func (d *localDocument) dimension() int {
+	return len(d.Embedding)
+}
+
+// segment is the on-disk format of a segment file
+type segment struct {
+	Docs []*localDocument
+}
+
+// manifest lists the segment files and the operation log that make up the current state
+type manifest struct {
+	Segments      []string `json:"segments"`
+	OpLog         string   `json:"op_log"`
+	NextSegmentID int      `json:"next_segment_id"`
+}
+
+// operation is a mutation applied on top of the segment files
+type operation struct {
+	Op      string   `json:"op"`
+	IDs     []string `json:"ids"`
+	Enabled bool     `json:"enabled,omitempty"`
+	// FilterFields replaces the filter fields of the documents for set_filter_fields
+	FilterFields *types.IndexFilterFields `json:"filter_fields,omitempty"`
+}
+
+const (
+	opDelete          = "delete"
+	opSetStatus       = "set_status"
+	opSetFilterFields = "set_filter_fields"
+)
@@ -146,6 +146,44 @@ func (g *pgRepository) DeleteByKnowledgeIDList(ctx context.Context, knowledgeIDL
 	return nil
 }
 
+// DeleteByKnowledgeBaseID deletes all indices of a knowledge base
+func (g *pgRepository) DeleteByKnowledgeBaseID(ctx context.Context, knowledgeBaseID string, dimension int, knowledgeType string) error {
+	logger.GetLogger(ctx).Infof("[Postgres] Deleting indices by knowledge base ID: %s", knowledgeBaseID)
+	result := g.db.WithContext(ctx).Where("knowledge_base_id = ?", knowledgeBaseID).Delete(&pgVector{})
+	if result.Error != nil {
+		logger.GetLogger(ctx).Errorf("[Postgres] Failed to delete indices by knowledge base ID: %v", result.Error)
+		return result.Error
+	}
+	logger.GetLogger(ctx).Infof("[Postgres] Successfully deleted %d indices by knowledge base ID", result.RowsAffected)
+	return nil
+}
+
+// PromoteShadowIndices replaces the indices of the target knowledge base with the shadow indices
+// in a single transaction, so readers see either the old or the new indices
+func (g *pgRepository) PromoteShadowIndices(ctx context.Context,
+	shadowKnowledgeBaseID string, targetKnowledgeBaseID string, shadowDimension int, targetDimension int,
+) error {
+	logger.GetLogger(ctx).Infof("[Postgres] Promoting shadow indices %s to knowledge base %s",
+		shadowKnowledgeBaseID, targetKnowledgeBaseID)
+	var promoted int64
+	err := g.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("knowledge_base_id = ?", targetKnowledgeBaseID).Delete(&pgVector{}).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&pgVector{}).
+			Where("knowledge_base_id = ?", shadowKnowledgeBaseID).
+			Update("knowledge_base_id", targetKnowledgeBaseID)
+		promoted = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		logger.GetLogger(ctx).Errorf("[Postgres] Failed to promote shadow indices: %v", err)
+		return err
+	}
+	logger.GetLogger(ctx).Infof("[Postgres] Successfully promoted %d shadow indices", promoted)
+	return nil
+}
+
 // Retrieve handles retrieval requests and routes to appropriate method
 func (g *pgRepository) Retrieve(ctx context.Context, params types.RetrieveParams) ([]*types.RetrieveResult, error) {
 	logger.GetLogger(ctx).Debugf("[Postgres] Processing retrieval request of type: %s", params.RetrieverType)
@@ -185,6 +223,15 @@ func (g *pgRepository) KeywordsRetrieve(ctx context.Context,
 			Values: common.ToInterfaceSlice(params.KnowledgeIDs),
 		})
 	}
+	if params.Filter != nil {
+		logger.GetLogger(ctx).Debugf("[Postgres] Filtering by knowledge filter")
+		vars := make([]interface{}, 0)
+		sql := knowledgeFilterSQL(params.Filter, func(arg interface{}) string {
+			vars = append(vars, arg)
+			return "?"
+		})
+		conds = append(conds, clause.Expr{SQL: sql, Vars: vars})
+	}
 	conds = append(conds, clause.Expr{
 		SQL:  "id @@@ paradedb.match(field => 'content', value => ?, distance => 1)",
 		Vars: []interface{}{params.Query},
@@ -239,6 +286,13 @@ func (g *pgRepository) KeywordsRetrieve(ctx context.Context,
 	}, nil
 }
 
+// knowledgeFilterSQL renders a filter on the documents as a condition on the knowledge_id of the embeddings,
+// so that tag and metadata changes of a document apply without reindexing its chunks
+func knowledgeFilterSQL(filter *types.RetrievalFilter, bind func(arg interface{}) string) string {
+	return "knowledge_id IN (SELECT knowledges.id FROM knowledges WHERE knowledges.deleted_at IS NULL AND " +
+		filter.KnowledgeSQL(bind) + ")"
+}
+
 // VectorRetrieve performs vector similarity search using pgvector
 // Optimized to use HNSW index efficiently and avoid recalculating vector distance
 func (g *pgRepository) VectorRetrieve(ctx context.Context,
@@ -293,6 +347,13 @@ func (g *pgRepository) VectorRetrieve(ctx context.Context,
 		whereParts = append(whereParts, fmt.Sprintf("knowledge_id IN (%s)",
 			strings.Join(placeholders, ", ")))
 	}
+	if params.Filter != nil {
+		logger.GetLogger(ctx).Debugf("[Postgres] Filtering vector search by knowledge filter")
+		whereParts = append(whereParts, knowledgeFilterSQL(params.Filter, func(arg interface{}) string {
+			allVars = append(allVars, arg)
+			return fmt.Sprintf("$%d", len(allVars))
+		}))
+	}
 
 	// is_enabled filter
 	whereParts = append(whereParts, fmt.Sprintf("(is_enabled IS NULL OR is_enabled = $%d)", len(allVars)+1))
@@ -561,3 +622,10 @@ func (g *pgRepository) BatchUpdateChunkEnabledStatus(ctx context.Context, chunkS
 	logger.GetLogger(ctx).Infof("[Postgres] Successfully batch updated chunk enabled status")
 	return nil
 }
+
+// BatchUpdateKnowledgeFilterFields does nothing, as Postgres filters on the knowledges table
+func (g *pgRepository) BatchUpdateKnowledgeFilterFields(ctx context.Context,
+	fields map[string]*types.IndexFilterFields,
+) error {
+	return nil
+}
@@ -0,0 +1,206 @@
+package qdrant
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	fieldTagID              = "tag_id"
+	fieldFileType           = "file_type"
+	fieldKnowledgeCreatedAt = "knowledge_created_at"
+	fieldMetadataKeys       = "metadata_keys"
+	fieldFilterMetadata     = "filter_metadata"
+)
+
+// filterFieldNames are the payload fields holding the filter fields of the knowledge
+var filterFieldNames = []string{
+	fieldTagID, fieldFileType, fieldKnowledgeCreatedAt, fieldMetadataKeys, fieldFilterMetadata,
+}
+
+// filterPayload converts the filter fields of a knowledge to payload values, the fields the knowledge
+// does not have are null
+func filterPayload(fields *types.IndexFilterFields) map[string]any {
+	payload := map[string]any{}
+	if fields != nil {
+		// The fields are strings, numbers, booleans and lists of them, which always encode
+		encoded, _ := json.Marshal(fields)
+		_ = json.Unmarshal(encoded, &payload)
+	}
+	for _, field := range filterFieldNames {
+		if _, ok := payload[field]; !ok {
+			payload[field] = nil
+		}
+	}
+	return payload
+}
+
+// filterIndexes are the payload indexes of the filter fields, nested fields are indexed by their path
+var filterIndexes = map[string]qdrant.FieldType{
+	fieldTagID:                        qdrant.FieldType_FieldTypeKeyword,
+	fieldFileType:                     qdrant.FieldType_FieldTypeKeyword,
+	fieldKnowledgeCreatedAt:           qdrant.FieldType_FieldTypeDatetime,
+	fieldMetadataKeys:                 qdrant.FieldType_FieldTypeKeyword,
+	fieldFilterMetadata + "[].key":    qdrant.FieldType_FieldTypeKeyword,
+	fieldFilterMetadata + "[].string": qdrant.FieldType_FieldTypeKeyword,
+	fieldFilterMetadata + "[].number": qdrant.FieldType_FieldTypeFloat,
+	fieldFilterMetadata + "[].bool":   qdrant.FieldType_FieldTypeBool,
+}
+
+// matchNone is a condition matching no point, as an empty filter matches every point
+func matchNone() *qdrant.Condition {
+	return qdrant.NewFilterAsCondition(&qdrant.Filter{
+		MustNot: []*qdrant.Condition{qdrant.NewFilterAsCondition(&qdrant.Filter{})},
+	})
+}
+
+// filterCondition renders a validated retrieval filter as a condition on the filter fields of the points.
+// Conditions on tag names must be resolved to tag IDs first.
+func filterCondition(filter *types.RetrievalFilter) (*qdrant.Condition, error) {
+	switch {
+	case len(filter.And) > 0 || len(filter.Or) > 0:
+		children := filter.And
+		if len(filter.Or) > 0 {
+			children = filter.Or
+		}
+		conditions := make([]*qdrant.Condition, 0, len(children))
+		for _, child := range children {
+			condition, err := filterCondition(child)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, condition)
+		}
+		if len(filter.And) > 0 {
+			return qdrant.NewFilterAsCondition(&qdrant.Filter{Must: conditions}), nil
+		}
+		return qdrant.NewFilterAsCondition(&qdrant.Filter{Should: conditions}), nil
+	case filter.Not != nil:
+		condition, err := filterCondition(filter.Not)
+		if err != nil {
+			return nil, err
+		}
+		return qdrant.NewFilterAsCondition(&qdrant.Filter{MustNot: []*qdrant.Condition{condition}}), nil
+	}
+
+	switch {
+	case filter.Field == types.FilterFieldTagID:
+		if filter.Op == types.FilterOpExists {
+			return qdrant.NewFilterAsCondition(&qdrant.Filter{
+				MustNot: []*qdrant.Condition{qdrant.NewIsEmpty(fieldTagID)},
+			}), nil
+		}
+		return matchKeywords(fieldTagID, filter.Values(), func(value interface{}) string {
+			id, _ := value.(string)
+			return id
+		}), nil
+	case filter.Field == types.FilterFieldFileType:
+		return matchKeywords(fieldFileType, filter.Values(), types.FileTypeValue), nil
+	case filter.Field == types.FilterFieldCreatedAt:
+		bounds := &qdrant.DatetimeRange{}
+		for _, bound := range filter.Bounds() {
+			setDatetimeBound(bounds, bound.Op, timestamppb.New(types.FilterTimeValue(bound.Value)))
+		}
+		return qdrant.NewDatetimeRange(fieldKnowledgeCreatedAt, bounds), nil
+	case !strings.HasPrefix(filter.Field, types.FilterFieldMetadataPrefix):
+		return nil, fmt.Errorf("unsupported filter field %q", filter.Field)
+	}
+
+	key := strings.TrimPrefix(filter.Field, types.FilterFieldMetadataPrefix)
+	if filter.Op == types.FilterOpExists {
+		return qdrant.NewMatchKeyword(fieldMetadataKeys, key), nil
+	}
+	var value *qdrant.Condition
+	if filter.Op == types.FilterOpRange {
+		condition, err := metadataRange(filter)
+		if err != nil {
+			return nil, err
+		}
+		value = condition
+	} else {
+		keywords := make([]string, 0)
+		should := make([]*qdrant.Condition, 0)
+		for _, v := range filter.Values() {
+			switch v := v.(type) {
+			case string:
+				keywords = append(keywords, v)
+			case float64:
+				should = append(should, qdrant.NewRange("number", &qdrant.Range{Gte: &v, Lte: &v}))
+			case bool:
+				should = append(should, qdrant.NewMatchBool("bool", v))
+			}
+		}
+		if len(keywords) > 0 {
+			should = append(should, qdrant.NewMatchKeywords("string", keywords...))
+		}
+		value = qdrant.NewFilterAsCondition(&qdrant.Filter{Should: should})
+	}
+	// Keys of nested filters are relative to the metadata entries
+	return qdrant.NewNestedFilter(fieldFilterMetadata, &qdrant.Filter{
+		Must: []*qdrant.Condition{qdrant.NewMatchKeyword("key", key), value},
+	}), nil
+}
+
+// matchKeywords matches a keyword field equal to one of the values
+func matchKeywords(field string, values []interface{}, keyword func(value interface{}) string) *qdrant.Condition {
+	if len(values) == 0 {
+		return matchNone()
+	}
+	keywords := make([]string, 0, len(values))
+	for _, value := range values {
+		keywords = append(keywords, keyword(value))
+	}
+	return qdrant.NewMatchKeywords(field, keywords...)
+}
+
+// metadataRange renders a range on a metadata key. Qdrant compares strings only as datetimes,
+// so string bounds must be RFC3339 times or dates.
+func metadataRange(filter *types.RetrievalFilter) (*qdrant.Condition, error) {
+	bounds := filter.Bounds()
+	if _, numeric := bounds[0].Value.(float64); numeric {
+		numbers := &qdrant.Range{}
+		for _, bound := range bounds {
+			value := bound.Value.(float64)
+			switch bound.Op {
+			case "gt":
+				numbers.Gt = &value
+			case "gte":
+				numbers.Gte = &value
+			case "lt":
+				numbers.Lt = &value
+			default:
+				numbers.Lte = &value
+			}
+		}
+		return qdrant.NewRange("number", numbers), nil
+	}
+
+	datetimes := &qdrant.DatetimeRange{}
+	for _, bound := range bounds {
+		value, err := types.ParseFilterTime(bound.Value.(string))
+		if err != nil {
+			return nil, fmt.Errorf("field %s: Qdrant only compares strings as RFC3339 times or dates", filter.Field)
+		}
+		setDatetimeBound(datetimes, bound.Op, timestamppb.New(value))
+	}
+	return qdrant.NewDatetimeRange("string", datetimes), nil
+}
+
+// setDatetimeBound sets a bound of a datetime range
+func setDatetimeBound(datetimes *qdrant.DatetimeRange, op string, value *timestamppb.Timestamp) {
+	switch op {
+	case "gt":
+		datetimes.Gt = value
+	case "gte":
+		datetimes.Gte = value
+	case "lt":
+		datetimes.Lt = value
+	default:
+		datetimes.Lte = value
+	}
+}
@@ -27,6 +27,10 @@ const (
 	fieldKnowledgeBaseID  = "knowledge_base_id"
 	fieldEmbedding        = "embedding"
 	fieldIsEnabled        = "is_enabled"
+	fieldIndexGeneration  = "index_generation"
+
+	// promoteAttempts bounds the passes relabeling the shadow points
+	promoteAttempts = 5
 )
 
 // NewQdrantRetrieveEngineRepository creates and initializes a new Qdrant repository
@@ -133,6 +137,18 @@ func (q *qdrantRepository) ensureCollection(ctx context.Context, dimension int)
 		log.Infof("[Qdrant] Successfully created collection %s", collectionName)
 	}
 
+	// Index the filter fields, also on collections created before they were stored
+	for field, fieldType := range filterIndexes {
+		_, err = q.client.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{
+			CollectionName: collectionName,
+			FieldName:      field,
+			FieldType:      fieldType.Enum(),
+		})
+		if err != nil {
+			log.Warnf("[Qdrant] Failed to create index for field %s: %v", field, err)
+		}
+	}
+
 	// Mark as initialized
 	q.initializedCollections.Store(dimension, true)
 	return nil
@@ -351,6 +367,115 @@ func (q *qdrantRepository) DeleteBySourceIDList(ctx context.Context,
 	return nil
 }
 
+// DeleteByKnowledgeBaseID removes all points of a knowledge base from the collection
+func (q *qdrantRepository) DeleteByKnowledgeBaseID(ctx context.Context,
+	knowledgeBaseID string, dimension int, knowledgeType string,
+) error {
+	log := logger.GetLogger(ctx)
+	if err := q.ensureCollection(ctx, dimension); err != nil {
+		return err
+	}
+
+	collectionName := q.getCollectionName(dimension)
+	log.Infof("[Qdrant] Deleting indices of knowledge base %s from %s", knowledgeBaseID, collectionName)
+
+	_, err := q.client.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: collectionName,
+		Points: qdrant.NewPointsSelectorFilter(&qdrant.Filter{
+			Must: []*qdrant.Condition{
+				qdrant.NewMatch(fieldKnowledgeBaseID, knowledgeBaseID),
+			},
+		}),
+	})
+	if err != nil {
+		log.Errorf("[Qdrant] Failed to delete by knowledge base ID: %v", err)
+		return fmt.Errorf("failed to delete by knowledge base ID: %w", err)
+	}
+
+	log.Infof("[Qdrant] Successfully deleted documents by knowledge base ID")
+	return nil
+}
+
+// PromoteShadowIndices replaces the points of the target knowledge base with the shadow points.
+// The shadow points are relabeled in place under a new index generation, so no vectors are copied,
+// and the previous points are only deleted once no shadow point is left, so that a failed promotion
+// keeps the knowledge base searchable
+func (q *qdrantRepository) PromoteShadowIndices(ctx context.Context,
+	shadowKnowledgeBaseID string, targetKnowledgeBaseID string, shadowDimension int, targetDimension int,
+) error {
+	log := logger.GetLogger(ctx)
+	log.Infof("[Qdrant] Promoting shadow indices %s to knowledge base %s", shadowKnowledgeBaseID, targetKnowledgeBaseID)
+
+	if err := q.ensureCollection(ctx, shadowDimension); err != nil {
+		return err
+	}
+	if err := q.ensureCollection(ctx, targetDimension); err != nil {
+		return err
+	}
+
+	generation := uuid.New().String()
+	shadowCollection := q.getCollectionName(shadowDimension)
+	shadow := &qdrant.Filter{
+		Must: []*qdrant.Condition{qdrant.NewMatch(fieldKnowledgeBaseID, shadowKnowledgeBaseID)},
+	}
+	// Points upserted into the shadow while relabeling are relabeled by the next pass
+	for attempt := 1; ; attempt++ {
+		_, err := q.client.SetPayload(ctx, &qdrant.SetPayloadPoints{
+			CollectionName: shadowCollection,
+			Wait:           qdrant.PtrOf(true),
+			Payload: qdrant.NewValueMap(map[string]any{
+				fieldKnowledgeBaseID: targetKnowledgeBaseID,
+				fieldIndexGeneration: generation,
+			}),
+			PointsSelector: qdrant.NewPointsSelectorFilter(shadow),
+		})
+		if err != nil {
+			log.Errorf("[Qdrant] Failed to promote shadow indices: %v", err)
+			return fmt.Errorf("failed to promote shadow indices: %w", err)
+		}
+		left, err := q.client.Count(ctx, &qdrant.CountPoints{
+			CollectionName: shadowCollection,
+			Filter:         shadow,
+			Exact:          qdrant.PtrOf(true),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to count shadow indices: %w", err)
+		}
+		if left == 0 {
+			break
+		}
+		if attempt == promoteAttempts {
+			return fmt.Errorf("%d shadow indices left after %d attempts", left, attempt)
+		}
+		log.Warnf("[Qdrant] %d shadow indices left after relabeling, retrying", left)
+	}
+
+	// The points of the target knowledge base from before the promotion have another generation or none,
+	// in the collection of either dimension
+	previous := &qdrant.Filter{
+		Must:    []*qdrant.Condition{qdrant.NewMatch(fieldKnowledgeBaseID, targetKnowledgeBaseID)},
+		MustNot: []*qdrant.Condition{qdrant.NewMatch(fieldIndexGeneration, generation)},
+	}
+	collections := []string{q.getCollectionName(targetDimension)}
+	if targetDimension != shadowDimension {
+		collections = append(collections, shadowCollection)
+	}
+	for _, collectionName := range collections {
+		_, err := q.client.Delete(ctx, &qdrant.DeletePoints{
+			CollectionName: collectionName,
+			Wait:           qdrant.PtrOf(true),
+			Points:         qdrant.NewPointsSelectorFilter(previous),
+		})
+		if err != nil {
+			log.Errorf("[Qdrant] Failed to delete the previous indices after promotion: %v", err)
+			return fmt.Errorf("failed to delete the previous indices: %w", err)
+		}
+	}
+
+	log.Infof("[Qdrant] Successfully promoted shadow indices")
+	return nil
+}
+
 // BatchUpdateChunkEnabledStatus updates the enabled status of chunks in batch
 // This method operates on all collections since dimension is not provided
 func (q *qdrantRepository) BatchUpdateChunkEnabledStatus(ctx context.Context, chunkStatusMap map[string]bool) error {
@@ -426,7 +551,7 @@ func (q *qdrantRepository) BatchUpdateChunkEnabledStatus(ctx context.Context, ch
 	return nil
 }
 
-func (q *qdrantRepository) getBaseFilter(params types.RetrieveParams) *qdrant.Filter {
+func (q *qdrantRepository) getBaseFilter(params types.RetrieveParams) (*qdrant.Filter, error) {
 	must := make([]*qdrant.Condition, 0)
 	mustNot := make([]*qdrant.Condition, 0)
 
@@ -443,6 +568,14 @@ func (q *qdrantRepository) getBaseFilter(params types.RetrieveParams) *qdrant.Fi
 	if len(params.KnowledgeIDs) > 0 {
 		must = append(must, qdrant.NewMatchKeywords(fieldKnowledgeID, params.KnowledgeIDs...))
 	}
+	// Retrieval filters match the filter fields stored on the points
+	if params.Filter != nil {
+		condition, err := filterCondition(params.Filter)
+		if err != nil {
+			return nil, err
+		}
+		must = append(must, condition)
+	}
 
 	if len(params.ExcludeKnowledgeIDs) > 0 {
 		mustNot = append(mustNot, qdrant.NewMatchKeywords(fieldKnowledgeID, params.ExcludeKnowledgeIDs...))
@@ -457,7 +590,7 @@ func (q *qdrantRepository) getBaseFilter(params types.RetrieveParams) *qdrant.Fi
 		MustNot: mustNot,
 	}
 
-	return filter
+	return filter, nil
 }
 
 // Retrieve dispatches the retrieval operation to the appropriate method based on retriever type
@@ -502,7 +635,11 @@ func (q *qdrantRepository) VectorRetrieve(ctx context.Context,
 		return buildRetrieveResult(nil, types.VectorRetrieverType), nil
 	}
 
-	filter := q.getBaseFilter(params)
+	filter, err := q.getBaseFilter(params)
+	if err != nil {
+		log.Errorf("[Qdrant] Failed to build filter: %v", err)
+		return nil, err
+	}
 
 	limit := uint64(params.TopK)
 	scoreThreshold := float32(params.Threshold)
@@ -569,6 +706,12 @@ func (q *qdrantRepository) KeywordsRetrieve(ctx context.Context,
 
 	log.Debugf("[Qdrant] Found %d collections, base name: %s", len(collections), q.collectionBaseName)
 
+	baseFilter, err := q.getBaseFilter(params)
+	if err != nil {
+		log.Errorf("[Qdrant] Failed to build filter: %v", err)
+		return nil, err
+	}
+
 	// Tokenize query for OR-based search (better for Chinese and multi-word queries)
 	queryTokens := tokenizeQuery(params.Query)
 	log.Debugf("[Qdrant] Tokenized query into %d tokens: %v", len(queryTokens), queryTokens)
@@ -583,7 +726,10 @@ func (q *qdrantRepository) KeywordsRetrieve(ctx context.Context,
 			continue
 		}
 
-		filter := q.getBaseFilter(params)
+		filter := &qdrant.Filter{
+			Must:    slices.Clone(baseFilter.Must),
+			MustNot: baseFilter.MustNot,
+		}
 
 		// Build should conditions for each token (OR logic)
 		// This allows matching documents that contain any of the query tokens
@@ -747,6 +893,11 @@ func (q *qdrantRepository) CopyIndices(ctx context.Context,
 				fieldKnowledgeBaseID: targetKnowledgeBaseID,
 				fieldIsEnabled:       true,
 			})
+			for _, field := range filterFieldNames {
+				if value, ok := payload[field]; ok {
+					newPayload[field] = value
+				}
+			}
 
 			var vectors *qdrant.Vectors
 			if vectorOutput := sourcePoint.Vectors.GetVector(); vectorOutput != nil {
@@ -807,6 +958,9 @@ func createPayload(embedding *QdrantVectorEmbedding) map[string]*qdrant.Value {
 		fieldKnowledgeBaseID: embedding.KnowledgeBaseID,
 		fieldIsEnabled:       embedding.IsEnabled,
 	}
+	if embedding.FilterFields != nil {
+		maps.Copy(payload, filterPayload(embedding.FilterFields))
+	}
 	return qdrant.NewValueMap(payload)
 }
 
@@ -863,6 +1017,7 @@ func toQdrantVectorEmbedding(embedding *types.IndexInfo, additionalParams map[st
 		KnowledgeID:     embedding.KnowledgeID,
 		KnowledgeBaseID: embedding.KnowledgeBaseID,
 		IsEnabled:       true, // Default to enabled
+		FilterFields:    embedding.FilterFields,
 	}
 	if additionalParams != nil && slices.Contains(slices.Collect(maps.Keys(additionalParams)), fieldEmbedding) {
 		if embeddingMap, ok := additionalParams[fieldEmbedding].(map[string][]float32); ok {
@@ -916,3 +1071,42 @@ func tokenizeQuery(query string) []string {
 
 	return result
 }
+
+// BatchUpdateKnowledgeFilterFields replaces the filter fields stored on the points of the given knowledge
+// This method operates on all collections since dimension is not provided
+func (q *qdrantRepository) BatchUpdateKnowledgeFilterFields(ctx context.Context,
+	fields map[string]*types.IndexFilterFields,
+) error {
+	log := logger.GetLogger(ctx)
+	log.Infof("[Qdrant] Updating filter fields of %d knowledge", len(fields))
+
+	collections, err := q.client.ListCollections(ctx)
+	if err != nil {
+		log.Errorf("[Qdrant] Failed to list collections: %v", err)
+		return fmt.Errorf("failed to list collections: %w", err)
+	}
+
+	for _, collectionName := range collections {
+		// Only process collections that start with our base name
+		if len(collectionName) <= len(q.collectionBaseName) ||
+			collectionName[:len(q.collectionBaseName)] != q.collectionBaseName {
+			continue
+		}
+		for knowledgeID, knowledgeFields := range fields {
+			// Setting every filter field, null when missing, replaces the previous values
+			_, err := q.client.SetPayload(ctx, &qdrant.SetPayloadPoints{
+				CollectionName: collectionName,
+				Payload:        qdrant.NewValueMap(filterPayload(knowledgeFields)),
+				PointsSelector: qdrant.NewPointsSelectorFilter(&qdrant.Filter{
+					Must: []*qdrant.Condition{qdrant.NewMatch(fieldKnowledgeID, knowledgeID)},
+				}),
+			})
+			if err != nil {
+				log.Errorf("[Qdrant] Failed to update filter fields of knowledge %s in %s: %v",
+					knowledgeID, collectionName, err)
+				return fmt.Errorf("failed to update filter fields: %w", err)
+			}
+		}
+	}
+	return nil
+}
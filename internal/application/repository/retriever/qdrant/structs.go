@@ -3,6 +3,7 @@ package qdrant
 import (
 	"sync"
 
+	"github.com/Tencent/WeKnora/internal/types"
 	"github.com/qdrant/go-client/qdrant"
 )
 
@@ -22,6 +23,8 @@ type QdrantVectorEmbedding struct {
 	KnowledgeBaseID string    `json:"knowledge_base_id"`
 	Embedding       []float32 `json:"embedding"`
 	IsEnabled       bool      `json:"is_enabled"`
+	// Fields of the knowledge matched by retrieval filters
+	FilterFields *types.IndexFilterFields `json:"-"`
 }
 
 type QdrantVectorEmbeddingWithScore struct {
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"gorm.io/gorm"
+)
+
+// usageRepository stores the usage ledger
+type usageRepository struct {
+	db *gorm.DB
+}
+
+// NewUsageRepository creates a new usage repository
+func NewUsageRepository(db *gorm.DB) interfaces.UsageRepository {
+	return &usageRepository{db: db}
+}
+
+// Create writes a usage record
+func (r *usageRepository) Create(ctx context.Context, record *types.UsageRecord) error {
+	return r.db.WithContext(ctx).Create(record).Error
+}
+
+// SumTokens sums the tokens of a tenant since a time
+func (r *usageRepository) SumTokens(
+	ctx context.Context, tenantID uint64, apiKeyID string, since time.Time,
+) (int64, error) {
+	var total int64
+	query := r.db.WithContext(ctx).Model(&types.UsageRecord{}).
+		Where("tenant_id = ? AND created_at >= ?", tenantID, since)
+	if apiKeyID != "" {
+		query = query.Where("api_key_id = ?", apiKeyID)
+	}
+	if err := query.Select("COALESCE(SUM(total_tokens), 0)").Scan(&total).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// Report aggregates the usage of a tenant by day, model and call type
+func (r *usageRepository) Report(
+	ctx context.Context, tenantID uint64, apiKeyID string, from, to time.Time,
+) ([]*types.UsageReportItem, error) {
+	var items []*types.UsageReportItem
+	query := r.db.WithContext(ctx).Model(&types.UsageRecord{}).
+		Select(`TO_CHAR(created_at, 'YYYY-MM-DD') AS day, model_id, MAX(model_name) AS model_name, call_type,
+			SUM(prompt_tokens) AS prompt_tokens, SUM(completion_tokens) AS completion_tokens,
+			SUM(total_tokens) AS total_tokens, SUM(requests) AS requests`).
+		Where("tenant_id = ? AND created_at >= ? AND created_at < ?", tenantID, from, to)
+	if apiKeyID != "" {
+		query = query.Where("api_key_id = ?", apiKeyID)
+	}
+	if err := query.Group("day, model_id, call_type").
+		Order("day, model_id, call_type").
+		Scan(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrWebhookNotFound is returned when a webhook does not exist
+	ErrWebhookNotFound = errors.New("webhook not found")
+	// ErrWebhookDeliveryNotFound is returned when a webhook delivery does not exist
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+// webhookRepository stores webhooks and their deliveries
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+// NewWebhookRepository creates a new webhook repository
+func NewWebhookRepository(db *gorm.DB) interfaces.WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+// Create creates a webhook
+func (r *webhookRepository) Create(ctx context.Context, webhook *types.Webhook) error {
+	return r.db.WithContext(ctx).Create(webhook).Error
+}
+
+// GetByID gets a webhook of a tenant by its ID
+func (r *webhookRepository) GetByID(ctx context.Context, tenantID uint64, id string) (*types.Webhook, error) {
+	var webhook types.Webhook
+	if err := r.db.WithContext(ctx).Where("tenant_id = ? AND id = ?", tenantID, id).First(&webhook).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// List lists the webhooks of a tenant, newest first
+func (r *webhookRepository) List(ctx context.Context, tenantID uint64) ([]*types.Webhook, error) {
+	var webhooks []*types.Webhook
+	if err := r.db.WithContext(ctx).
+		Where("tenant_id = ?", tenantID).
+		Order("created_at DESC").
+		Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// Update updates a webhook
+func (r *webhookRepository) Update(ctx context.Context, webhook *types.Webhook) error {
+	return r.db.WithContext(ctx).Save(webhook).Error
+}
+
+// Delete deletes a webhook and its deliveries
+func (r *webhookRepository) Delete(ctx context.Context, tenantID uint64, id string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tenant_id = ? AND webhook_id = ?", tenantID, id).
+			Delete(&types.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("tenant_id = ? AND id = ?", tenantID, id).Delete(&types.Webhook{}).Error
+	})
+}
+
+// CreateDelivery creates a webhook delivery
+func (r *webhookRepository) CreateDelivery(ctx context.Context, delivery *types.WebhookDelivery) error {
+	return r.db.WithContext(ctx).Create(delivery).Error
+}
+
+// GetDelivery gets a webhook delivery of a tenant by its ID
+func (r *webhookRepository) GetDelivery(
+	ctx context.Context, tenantID uint64, id string,
+) (*types.WebhookDelivery, error) {
+	var delivery types.WebhookDelivery
+	if err := r.db.WithContext(ctx).
+		Where("tenant_id = ? AND id = ?", tenantID, id).
+		First(&delivery).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookDeliveryNotFound
+		}
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// UpdateDelivery updates a webhook delivery
+func (r *webhookRepository) UpdateDelivery(ctx context.Context, delivery *types.WebhookDelivery) error {
+	return r.db.WithContext(ctx).Save(delivery).Error
+}
+
+// ListDeliveries lists the deliveries of a webhook, newest first
+func (r *webhookRepository) ListDeliveries(
+	ctx context.Context, tenantID uint64, webhookID string, page *types.Pagination,
+) ([]*types.WebhookDelivery, int64, error) {
+	query := func() *gorm.DB {
+		return r.db.WithContext(ctx).Model(&types.WebhookDelivery{}).
+			Where("tenant_id = ? AND webhook_id = ?", tenantID, webhookID)
+	}
+	var total int64
+	if err := query().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var deliveries []*types.WebhookDelivery
+	if err := query().Order("created_at DESC").
+		Offset(page.Offset()).
+		Limit(page.Limit()).
+		Find(&deliveries).Error; err != nil {
+		return nil, 0, err
+	}
+	return deliveries, total, nil
+}
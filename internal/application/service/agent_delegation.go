@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/Tencent/WeKnora/internal/agent/tools"
+	"github.com/Tencent/WeKnora/internal/event"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/models/chat"
+	"github.com/Tencent/WeKnora/internal/models/rerank"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	secutils "github.com/Tencent/WeKnora/internal/utils"
+)
+
+// DefaultSubAgentMaxIterations is the iteration budget of a sub-agent whose profile sets none
+const DefaultSubAgentMaxIterations = 10
+
+// subAgentRunner returns the runner of the delegate_to_agent tool.
+// Each delegation creates a sub-agent from its profile, without profiles of its own so that it cannot delegate further,
+// and without conversation history, its streaming events are nested under the tool call on the parent event bus.
+func (s *agentService) subAgentRunner(
+	parent *types.AgentConfig,
+	chatModel chat.Chat,
+	rerankModel rerank.Reranker,
+	eventBus *event.EventBus,
+	sessionID string,
+	sessionService interfaces.SessionService,
+) tools.SubAgentRunner {
+	return func(
+		ctx context.Context,
+		profile *types.AgentProfile,
+		task string,
+		parentToolCallID string,
+	) (*types.AgentState, error) {
+		config := s.buildSubAgentConfig(ctx, parent, profile)
+
+		model := chatModel
+		if profile.ModelID != "" {
+			profileModel, err := s.modelService.GetChatModel(ctx, profile.ModelID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get chat model of agent %s: %w", profile.Name, err)
+			}
+			model = profileModel
+		}
+
+		subEventBus := event.NewEventBus()
+		if eventBus != nil {
+			forwardSubAgentEvents(subEventBus, eventBus, profile.Name, parentToolCallID)
+		}
+
+		engine, err := s.CreateAgentEngine(ctx, config, model, rerankModel, subEventBus, nil, sessionID, sessionService)
+		if err != nil {
+			return nil, err
+		}
+		logger.Infof(ctx, "Running sub-agent %s with %d knowledge base(s), max iterations: %d",
+			profile.Name, len(config.KnowledgeBases), config.MaxIterations)
+		// Tool approvals of the sub-agent belong to the message the delegating agent is answering
+		messageID, _ := ctx.Value(types.MessageIDContextKey).(string)
+		return engine.Execute(ctx, sessionID, messageID, task, nil)
+	}
+}
+
+// buildSubAgentConfig builds the runtime configuration of the sub-agent of a profile.
+// The knowledge bases of the profile are limited to those of the tenant which the caller can read,
+// web search is only available to the sub-agent when it is enabled for the delegating agent,
+// and the tool policies of the delegating agent apply to the sub-agent.
+func (s *agentService) buildSubAgentConfig(
+	ctx context.Context,
+	parent *types.AgentConfig,
+	profile *types.AgentProfile,
+) *types.AgentConfig {
+	tenantID, _ := ctx.Value(types.TenantIDContextKey).(uint64)
+	kbIDs := make([]string, 0, len(profile.KnowledgeBases))
+	for _, kbID := range profile.KnowledgeBases {
+		kb, err := s.knowledgeBaseService.GetKnowledgeBaseByID(ctx, kbID)
+		if err != nil || kb == nil || kb.TenantID != tenantID {
+			logger.Warnf(ctx, "Skipping knowledge base %s of agent %s: not found",
+				secutils.SanitizeForLog(kbID), profile.Name)
+			continue
+		}
+		kbIDs = append(kbIDs, kb.ID)
+	}
+	kbIDs = types.FilterReadableKnowledgeBaseIDs(ctx, kbIDs)
+
+	searchTargets := make(types.SearchTargets, 0, len(kbIDs))
+	for _, kbID := range kbIDs {
+		searchTargets = append(searchTargets, &types.SearchTarget{
+			Type:            types.SearchTargetTypeKnowledgeBase,
+			KnowledgeBaseID: kbID,
+		})
+	}
+
+	maxIterations := profile.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = DefaultSubAgentMaxIterations
+	}
+
+	config := &types.AgentConfig{
+		MaxIterations:       min(maxIterations, MAX_ITERATIONS),
+		ReflectionEnabled:   parent.ReflectionEnabled,
+		AllowedTools:        profile.AllowedTools,
+		Temperature:         parent.Temperature,
+		KnowledgeBases:      kbIDs,
+		WebSearchMaxResults: parent.WebSearchMaxResults,
+		SearchTargets:       searchTargets,
+		ToolPolicies:        parent.ToolPolicies,
+		ToolApprovalTimeout: parent.ToolApprovalTimeout,
+	}
+	config.WebSearchEnabled = parent.WebSearchEnabled &&
+		(len(profile.AllowedTools) == 0 || slices.Contains(profile.AllowedTools, "web_search"))
+	if profile.SystemPrompt != "" {
+		config.UseCustomSystemPrompt = true
+		config.SystemPromptWebEnabled = profile.SystemPrompt
+		config.SystemPromptWebDisabled = profile.SystemPrompt
+	}
+	return config
+}
+
+// forwardSubAgentEvents forwards the streaming events of a sub-agent to the event bus of the delegating agent,
+// nested under its delegate_to_agent tool call. The final answer and the completion of the sub-agent are
+// not forwarded, the answer is returned to the delegating agent as the tool result.
+func forwardSubAgentEvents(from, to *event.EventBus, agentName, parentToolCallID string) {
+	delegation := event.DelegationData{ParentToolCallID: parentToolCallID, AgentName: agentName}
+	// Sub-agent IDs are prefixed so that they cannot collide with the IDs of the delegating agent
+	nested := func(id string) string {
+		return parentToolCallID + "/" + id
+	}
+
+	from.On(event.EventAgentThought, func(ctx context.Context, evt event.Event) error {
+		data, ok := evt.Data.(event.AgentThoughtData)
+		if !ok {
+			return nil
+		}
+		data.DelegationData = delegation
+		evt.ID, evt.Data = nested(evt.ID), data
+		return to.Emit(ctx, evt)
+	})
+	from.On(event.EventAgentToolCall, func(ctx context.Context, evt event.Event) error {
+		data, ok := evt.Data.(event.AgentToolCallData)
+		if !ok {
+			return nil
+		}
+		data.ToolCallID = nested(data.ToolCallID)
+		data.DelegationData = delegation
+		evt.ID, evt.Data = nested(evt.ID), data
+		return to.Emit(ctx, evt)
+	})
+	from.On(event.EventAgentToolResult, func(ctx context.Context, evt event.Event) error {
+		data, ok := evt.Data.(event.AgentToolResultData)
+		if !ok {
+			return nil
+		}
+		data.ToolCallID = nested(data.ToolCallID)
+		data.DelegationData = delegation
+		evt.ID, evt.Data = nested(evt.ID), data
+		return to.Emit(ctx, evt)
+	})
+	from.On(event.EventAgentToolApprovalRequired, func(ctx context.Context, evt event.Event) error {
+		data, ok := evt.Data.(event.AgentToolApprovalData)
+		if !ok {
+			return nil
+		}
+		data.ToolCallID = nested(data.ToolCallID)
+		data.DelegationData = delegation
+		evt.ID, evt.Data = nested(evt.ID), data
+		return to.Emit(ctx, evt)
+	})
+	from.On(event.EventAgentReflection, func(ctx context.Context, evt event.Event) error {
+		data, ok := evt.Data.(event.AgentReflectionData)
+		if !ok {
+			return nil
+		}
+		data.ToolCallID = nested(data.ToolCallID)
+		data.DelegationData = delegation
+		evt.ID, evt.Data = nested(evt.ID), data
+		return to.Emit(ctx, evt)
+	})
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeKnowledgeBaseService serves the knowledge bases of a map, the other methods are not implemented
+type fakeKnowledgeBaseService struct {
+	interfaces.KnowledgeBaseService
+	knowledgeBases map[string]*types.KnowledgeBase
+}
+
+func (s *fakeKnowledgeBaseService) GetKnowledgeBaseByID(ctx context.Context, id string) (*types.KnowledgeBase, error) {
+	kb, ok := s.knowledgeBases[id]
+	if !ok {
+		return nil, errors.New("knowledge base not found")
+	}
+	return kb, nil
+}
+
+// newTestDelegationService creates an agent service whose tenant 1 has the knowledge bases kb1 and kb2,
+// and tenant 2 has kb3
+func newTestDelegationService() *agentService {
+	return &agentService{knowledgeBaseService: &fakeKnowledgeBaseService{
+		knowledgeBases: map[string]*types.KnowledgeBase{
+			"kb1": {ID: "kb1", TenantID: 1},
+			"kb2": {ID: "kb2", TenantID: 1},
+			"kb3": {ID: "kb3", TenantID: 2},
+		},
+	}}
+}
+
+func delegationTestContext(principal *types.Principal) context.Context {
+	ctx := context.WithValue(context.Background(), types.TenantIDContextKey, uint64(1))
+	if principal != nil {
+		ctx = context.WithValue(ctx, types.PrincipalContextKey, principal)
+	}
+	return ctx
+}
+
+func TestBuildSubAgentConfigKnowledgeBases(t *testing.T) {
+	profile := &types.AgentProfile{Name: "support", KnowledgeBases: []string{"kb1", "kb2", "kb3", "missing"}}
+	tests := []struct {
+		name      string
+		principal *types.Principal
+		expected  []string
+	}{
+		{
+			name:     "internal call",
+			expected: []string{"kb1", "kb2"},
+		},
+		{
+			name:      "owner",
+			principal: &types.Principal{UserID: "u1", Role: types.TenantRoleOwner},
+			expected:  []string{"kb1", "kb2"},
+		},
+		{
+			name: "editor with a grant",
+			principal: &types.Principal{
+				UserID: "u2",
+				Role:   types.TenantRoleEditor,
+				Grants: map[string]types.KBPermission{"kb2": types.KBPermissionRead},
+			},
+			expected: []string{"kb2"},
+		},
+		{
+			name:      "viewer without grants",
+			principal: &types.Principal{UserID: "u3", Role: types.TenantRoleViewer},
+			expected:  []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestDelegationService()
+			config := s.buildSubAgentConfig(delegationTestContext(tt.principal), &types.AgentConfig{}, profile)
+
+			// Knowledge bases of other tenants, unknown ones and unreadable ones are dropped
+			assert.Equal(t, tt.expected, config.KnowledgeBases)
+			assert.Len(t, config.SearchTargets, len(tt.expected))
+			for i, target := range config.SearchTargets {
+				assert.Equal(t, types.SearchTargetTypeKnowledgeBase, target.Type)
+				assert.Equal(t, tt.expected[i], target.KnowledgeBaseID)
+			}
+		})
+	}
+}
+
+func TestBuildSubAgentConfigMaxIterations(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxIterations int
+		expected      int
+	}{
+		{"default", 0, DefaultSubAgentMaxIterations},
+		{"negative", -1, DefaultSubAgentMaxIterations},
+		{"profile", 5, 5},
+		{"capped", MAX_ITERATIONS + 10, MAX_ITERATIONS},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestDelegationService()
+			config := s.buildSubAgentConfig(delegationTestContext(nil), &types.AgentConfig{},
+				&types.AgentProfile{Name: "support", MaxIterations: tt.maxIterations})
+			assert.Equal(t, tt.expected, config.MaxIterations)
+		})
+	}
+}
+
+func TestBuildSubAgentConfigWebSearch(t *testing.T) {
+	tests := []struct {
+		name          string
+		parentEnabled bool
+		allowedTools  []string
+		expected      bool
+	}{
+		{"enabled for the parent", true, nil, true},
+		{"disabled for the parent", false, nil, false},
+		{"allowed tool", true, []string{"knowledge_search", "web_search"}, true},
+		{"not an allowed tool", true, []string{"knowledge_search"}, false},
+		{"allowed tool disabled for the parent", false, []string{"web_search"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestDelegationService()
+			parent := &types.AgentConfig{
+				WebSearchEnabled: tt.parentEnabled,
+				ToolPolicies:     map[string]types.ToolPolicy{"mcp.*": types.ToolPolicyDeny},
+			}
+			config := s.buildSubAgentConfig(delegationTestContext(nil), parent,
+				&types.AgentProfile{Name: "support", AllowedTools: tt.allowedTools})
+			assert.Equal(t, tt.expected, config.WebSearchEnabled)
+			// The tool policies of the delegating agent apply to the sub-agent
+			assert.Equal(t, parent.ToolPolicies, config.ToolPolicies)
+		})
+	}
+}
@@ -32,6 +32,7 @@ type agentService struct {
 	knowledgeBaseService interfaces.KnowledgeBaseService
 	knowledgeService     interfaces.KnowledgeService
 	chunkService         interfaces.ChunkService
+	toolApprovalService  interfaces.ToolApprovalService
 }
 
 // NewAgentService creates a new agent service
@@ -46,6 +47,7 @@ func NewAgentService(
 	eventBus *event.EventBus,
 	db *gorm.DB,
 	webSearchService interfaces.WebSearchService,
+	toolApprovalService interfaces.ToolApprovalService,
 ) interfaces.AgentService {
 	return &agentService{
 		cfg:                  cfg,
@@ -58,6 +60,7 @@ func NewAgentService(
 		eventBus:             eventBus,
 		db:                   db,
 		webSearchService:     webSearchService,
+		toolApprovalService:  toolApprovalService,
 	}
 }
 
@@ -125,6 +128,15 @@ func (s *agentService) CreateAgentEngine(
 		}
 	}
 
+	// Register the delegation tool, sub-agents have no profiles of their own and cannot delegate further
+	if len(config.Profiles) > 0 {
+		toolRegistry.RegisterTool(tools.NewDelegateToAgentTool(
+			config.Profiles,
+			s.subAgentRunner(config, chatModel, rerankModel, eventBus, sessionID, sessionService),
+		))
+		logger.Infof(ctx, "Registered delegate_to_agent tool with %d agent profile(s)", len(config.Profiles))
+	}
+
 	// Get knowledge base detailed information for prompt
 	kbInfos, err := s.getKnowledgeBaseInfos(ctx, config.KnowledgeBases)
 	if err != nil {
@@ -164,6 +176,7 @@ func (s *agentService) CreateAgentEngine(
 		contextManager,
 		sessionID,
 		systemPromptTemplate,
+		s.toolApprovalService,
 	)
 
 	return engine, nil
@@ -181,6 +194,15 @@ func (s *agentService) registerTools(
 ) error {
 	// If no specific tools allowed, register default tools
 	allowedTools := tools.DefaultAllowedTools()
+	if len(config.AllowedTools) > 0 {
+		allowedTools = make([]string, 0, len(config.AllowedTools))
+		for _, toolName := range config.AllowedTools {
+			// Web tools are added below when web search is enabled
+			if toolName != "web_search" && toolName != "web_fetch" {
+				allowedTools = append(allowedTools, toolName)
+			}
+		}
+	}
 
 	// Filter out knowledge base tools if no knowledge bases or knowledge IDs are configured
 	hasKnowledge := len(config.KnowledgeBases) > 0 || len(config.KnowledgeIDs) > 0
@@ -243,6 +265,7 @@ func (s *agentService) registerTools(
 					s.chunkService,
 					tenantID,
 					config.SearchTargets,
+					config.RetrievalFilter,
 					rerankModel,
 					chatModel,
 					s.cfg,
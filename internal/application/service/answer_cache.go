@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// answerCacheKeyPrefix prefixes the lists of cached answers
+	answerCacheKeyPrefix = "answer_cache:"
+	// answerCacheVersionKeyPrefix prefixes the per knowledge base version counters
+	answerCacheVersionKeyPrefix = "answer_cache:kb_version:"
+	// answerCacheMaxEntries bounds the number of answers cached per knowledge base set and model configuration
+	answerCacheMaxEntries = 200
+)
+
+// answerCacheService caches knowledge QA answers in Redis, keyed by the embedding of the rewritten query.
+// Every knowledge base has a version counter which is part of the cache scope,
+// bumping it on knowledge changes makes the stale answers unreachable until they expire.
+type answerCacheService struct {
+	redisClient  *redis.Client
+	modelService interfaces.ModelService
+	kbRepo       interfaces.KnowledgeBaseRepository
+}
+
+// NewAnswerCacheService creates a new semantic answer cache service
+func NewAnswerCacheService(
+	redisClient *redis.Client,
+	modelService interfaces.ModelService,
+	kbRepo interfaces.KnowledgeBaseRepository,
+) interfaces.AnswerCacheService {
+	return &answerCacheService{
+		redisClient:  redisClient,
+		modelService: modelService,
+		kbRepo:       kbRepo,
+	}
+}
+
+// answerCacheScope lists everything that changes the answer of a question besides the query itself
+type answerCacheScope struct {
+	KnowledgeBases   []string                `json:"knowledge_bases"`
+	Versions         []int64                 `json:"versions"`
+	RetrievalConfigs []types.RetrievalConfig `json:"retrieval_configs"`
+	Pipelines        []*types.PipelineConfig `json:"pipelines"`
+	KnowledgeIDs     []string                `json:"knowledge_ids"`
+	EmbeddingModelID string                  `json:"embedding_model_id"`
+	ChatModelID      string                  `json:"chat_model_id"`
+	RerankModelID    string                  `json:"rerank_model_id"`
+	VectorThreshold  float64                 `json:"vector_threshold"`
+	KeywordThreshold float64                 `json:"keyword_threshold"`
+	EmbeddingTopK    int                     `json:"embedding_top_k"`
+	RerankTopK       int                     `json:"rerank_top_k"`
+	RerankThreshold  float64                 `json:"rerank_threshold"`
+	SummaryConfig    types.SummaryConfig     `json:"summary_config"`
+}
+
+// Lookup embeds the rewritten query and returns the most similar cached answer above the threshold
+func (s *answerCacheService) Lookup(ctx context.Context,
+	chatManage *types.ChatManage,
+) (*types.AnswerCacheEntry, *types.AnswerCacheKey, error) {
+	// Web search results change over time and are not tracked by the knowledge base versions,
+	// nor are the tags and metadata the retrieval filters match
+	if s.redisClient == nil || chatManage.WebSearchEnabled || len(chatManage.SearchTargets) == 0 ||
+		chatManage.RetrievalFilter != nil {
+		return nil, nil, nil
+	}
+
+	kbIDs := make([]string, 0, len(chatManage.SearchTargets))
+	knowledgeIDs := make([]string, 0)
+	for _, target := range chatManage.SearchTargets {
+		if !slices.Contains(kbIDs, target.KnowledgeBaseID) {
+			kbIDs = append(kbIDs, target.KnowledgeBaseID)
+		}
+		knowledgeIDs = append(knowledgeIDs, target.KnowledgeIDs...)
+	}
+	slices.Sort(kbIDs)
+	slices.Sort(knowledgeIDs)
+
+	kbs, err := s.kbRepo.GetKnowledgeBaseByIDs(ctx, kbIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(kbs) != len(kbIDs) {
+		return nil, nil, nil
+	}
+	slices.SortFunc(kbs, func(a, b *types.KnowledgeBase) int {
+		switch {
+		case a.ID < b.ID:
+			return -1
+		case a.ID > b.ID:
+			return 1
+		}
+		return 0
+	})
+
+	// Only cache when every knowledge base opts in, the strictest settings apply
+	key := &types.AnswerCacheKey{
+		TenantID: chatManage.TenantID,
+		Query:    chatManage.RewriteQuery,
+	}
+	retrievalConfigs := make([]types.RetrievalConfig, 0, len(kbs))
+	pipelines := make([]*types.PipelineConfig, 0, len(kbs))
+	for _, kb := range kbs {
+		retrievalConfigs = append(retrievalConfigs, kb.RetrievalConfig.WithDefaults())
+		pipelines = append(pipelines, kb.PipelineConfig)
+		config := kb.AnswerCacheConfig.WithDefaults()
+		if !config.Enabled {
+			return nil, nil, nil
+		}
+		key.SimilarityThreshold = math.Max(key.SimilarityThreshold, config.SimilarityThreshold)
+		ttl := time.Duration(config.TTLSeconds) * time.Second
+		if key.TTL == 0 || ttl < key.TTL {
+			key.TTL = ttl
+		}
+	}
+
+	versions, err := s.versions(ctx, kbIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	scope, err := json.Marshal(answerCacheScope{
+		KnowledgeBases:   kbIDs,
+		Versions:         versions,
+		RetrievalConfigs: retrievalConfigs,
+		Pipelines:        pipelines,
+		KnowledgeIDs:     knowledgeIDs,
+		EmbeddingModelID: kbs[0].EmbeddingModelID,
+		ChatModelID:      chatManage.ChatModelID,
+		RerankModelID:    chatManage.RerankModelID,
+		VectorThreshold:  chatManage.VectorThreshold,
+		KeywordThreshold: chatManage.KeywordThreshold,
+		EmbeddingTopK:    chatManage.EmbeddingTopK,
+		RerankTopK:       chatManage.RerankTopK,
+		RerankThreshold:  chatManage.RerankThreshold,
+		SummaryConfig:    chatManage.SummaryConfig,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	hash := sha256.Sum256(scope)
+	key.Scope = hex.EncodeToString(hash[:])
+
+	embedder, err := s.modelService.GetEmbeddingModel(ctx, kbs[0].EmbeddingModelID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get embedding model: %w", err)
+	}
+	key.Embedding, err = embedder.Embed(ctx, key.Query)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	raws, err := s.redisClient.LRange(ctx, s.entriesKey(key), 0, -1).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	var best *types.AnswerCacheEntry
+	bestScore := key.SimilarityThreshold
+	for _, raw := range raws {
+		var entry types.AnswerCacheEntry
+		if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+			logger.Warnf(ctx, "Failed to decode answer cache entry: %v", err)
+			continue
+		}
+		if time.Since(entry.CreatedAt) > key.TTL {
+			continue
+		}
+		if score := cosineSimilarity(key.Embedding, entry.Embedding); score >= bestScore {
+			best, bestScore = &entry, score
+		}
+	}
+	if best != nil {
+		logger.Infof(ctx, "Answer cache hit, query: %s, cached query: %s, similarity: %.4f",
+			key.Query, best.Query, bestScore)
+	}
+	return best, key, nil
+}
+
+// Store caches an answer and its references under the key returned by Lookup
+func (s *answerCacheService) Store(ctx context.Context,
+	key *types.AnswerCacheKey, answer string, references []*types.SearchResult,
+) error {
+	if key == nil {
+		return nil
+	}
+	raw, err := json.Marshal(&types.AnswerCacheEntry{
+		Query:      key.Query,
+		Embedding:  key.Embedding,
+		Answer:     answer,
+		References: references,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	entriesKey := s.entriesKey(key)
+	pipe := s.redisClient.TxPipeline()
+	pipe.LPush(ctx, entriesKey, raw)
+	pipe.LTrim(ctx, entriesKey, 0, answerCacheMaxEntries-1)
+	pipe.Expire(ctx, entriesKey, key.TTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	logger.Infof(ctx, "Answer cached, query: %s, references: %d", key.Query, len(references))
+	return nil
+}
+
+// InvalidateKnowledgeBase drops the cached answers of every knowledge base set containing the knowledge base
+func (s *answerCacheService) InvalidateKnowledgeBase(ctx context.Context, knowledgeBaseID string) error {
+	if s.redisClient == nil || knowledgeBaseID == "" {
+		return nil
+	}
+	return s.redisClient.Incr(ctx, answerCacheVersionKeyPrefix+knowledgeBaseID).Err()
+}
+
+// versions reads the version counters of the knowledge bases, missing counters are version 0
+func (s *answerCacheService) versions(ctx context.Context, kbIDs []string) ([]int64, error) {
+	keys := make([]string, 0, len(kbIDs))
+	for _, id := range kbIDs {
+		keys = append(keys, answerCacheVersionKeyPrefix+id)
+	}
+	values, err := s.redisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int64, len(values))
+	for i, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			continue
+		}
+		version, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid answer cache version of knowledge base %s: %w", kbIDs[i], err)
+		}
+		versions[i] = version
+	}
+	return versions, nil
+}
+
+// entriesKey returns the Redis list holding the cached answers of the key scope
+func (s *answerCacheService) entriesKey(key *types.AnswerCacheKey) string {
+	return fmt.Sprintf("%s%d:%s", answerCacheKeyPrefix, key.TenantID, key.Scope)
+}
+
+// cosineSimilarity returns the cosine similarity of two vectors, 0 when their dimensions differ
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/application/repository"
+	werrors "github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"github.com/google/uuid"
+)
+
+// apiKeyLastUsedInterval is the precision of the last used time, to avoid a write on every request
+const apiKeyLastUsedInterval = time.Minute
+
+// apiKeyService implements interfaces.APIKeyService
+type apiKeyService struct {
+	repo      interfaces.APIKeyRepository
+	kbService interfaces.KnowledgeBaseService
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(
+	repo interfaces.APIKeyRepository,
+	kbService interfaces.KnowledgeBaseService,
+) interfaces.APIKeyService {
+	return &apiKeyService{repo: repo, kbService: kbService}
+}
+
+// CreateAPIKey creates an API key in the current tenant, the plain key is only returned here
+func (s *apiKeyService) CreateAPIKey(
+	ctx context.Context, req *types.CreateAPIKeyRequest,
+) (*types.CreatedAPIKey, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, werrors.NewValidationError("API Key 名称不能为空")
+	}
+	scopes := make(types.StringArray, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !scope.IsValid() {
+			return nil, werrors.NewValidationError("无效的 API Key 权限范围").WithDetails(string(scope))
+		}
+		if !slices.Contains(scopes, string(scope)) {
+			scopes = append(scopes, string(scope))
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, werrors.NewValidationError("过期时间必须晚于当前时间")
+	}
+	kbIDs := make(types.StringArray, 0, len(req.KnowledgeBaseIDs))
+	for _, kbID := range req.KnowledgeBaseIDs {
+		if slices.Contains(kbIDs, kbID) {
+			continue
+		}
+		kb, err := s.kbService.GetKnowledgeBaseByID(ctx, kbID)
+		if err != nil || kb.TenantID != tenantID {
+			return nil, werrors.NewNotFoundError("知识库不存在").WithDetails(kbID)
+		}
+		kbIDs = append(kbIDs, kbID)
+	}
+
+	plainKey, err := generateScopedAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	key := &types.APIKey{
+		ID:                 uuid.New().String(),
+		TenantID:           tenantID,
+		Name:               name,
+		KeyPrefix:          plainKey[:len(types.APIKeyPrefix)+8],
+		KeyHash:            hashAPIKey(plainKey),
+		Scopes:             scopes,
+		KnowledgeBaseIDs:   kbIDs,
+		ExpiresAt:          req.ExpiresAt,
+		RequestsPerMinute:  req.RequestsPerMinute,
+		MonthlyTokenBudget: req.MonthlyTokenBudget,
+	}
+	if principal := types.PrincipalFromContext(ctx); principal != nil {
+		key.CreatedBy = principal.UserID
+	}
+	if err := s.repo.Create(ctx, key); err != nil {
+		return nil, err
+	}
+	logger.Infof(ctx, "API key %s created in tenant %d with scopes %v", key.ID, tenantID, scopes)
+	return &types.CreatedAPIKey{APIKey: key, Key: plainKey}, nil
+}
+
+// ListAPIKeys lists the API keys of the current tenant
+func (s *apiKeyService) ListAPIKeys(ctx context.Context) ([]*types.APIKey, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	return s.repo.List(ctx, tenantID)
+}
+
+// RevokeAPIKey revokes an API key of the current tenant
+func (s *apiKeyService) RevokeAPIKey(ctx context.Context, id string) error {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	key, err := s.repo.GetByID(ctx, tenantID, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			return werrors.NewNotFoundError("API Key 不存在")
+		}
+		return err
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	key.RevokedAt = &now
+	if err := s.repo.Update(ctx, key); err != nil {
+		return err
+	}
+	logger.Infof(ctx, "API key %s revoked in tenant %d", id, tenantID)
+	return nil
+}
+
+// Authenticate returns the active API key matching a plain key, an Unauthorized error otherwise
+func (s *apiKeyService) Authenticate(ctx context.Context, plainKey string) (*types.APIKey, error) {
+	key, err := s.repo.GetByHash(ctx, hashAPIKey(plainKey))
+	if err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			return nil, werrors.NewUnauthorizedError("无效的 API Key")
+		}
+		return nil, err
+	}
+	now := time.Now()
+	if !key.IsActive(now) {
+		return nil, werrors.NewUnauthorizedError("API Key 已撤销或已过期")
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyLastUsedInterval {
+		if err := s.repo.UpdateLastUsedAt(ctx, key.ID, now); err != nil {
+			logger.Warnf(ctx, "Failed to record last use of API key %s: %v", key.ID, err)
+		} else {
+			key.LastUsedAt = &now
+		}
+	}
+	return key, nil
+}
+
+// generateScopedAPIKey generates a random scoped API key
+func generateScopedAPIKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return types.APIKeyPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashAPIKey returns the hex encoded SHA-256 hash of a plain API key
+func hashAPIKey(plainKey string) string {
+	sum := sha256.Sum256([]byte(plainKey))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/Tencent/WeKnora/docreader/client"
+	"github.com/Tencent/WeKnora/docreader/proto"
+	"github.com/Tencent/WeKnora/internal/config"
+	werrors "github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/models/chat"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"gorm.io/gorm"
+)
+
+const (
+	// attachmentChunkSize is the chunk size used to parse the documents attached to questions
+	attachmentChunkSize = 1000
+	// defaultImageCaptionPrompt asks the VLM model to describe an image attached to a question,
+	// used when conversation.image_caption_prompt is not configured
+	defaultImageCaptionPrompt = "请简要描述这张图片的内容，使用图片中文字的语言作答，图片中没有文字时使用中文。" +
+		"如果图片中有文字（例如报错信息、弹窗、表格），请完整摘录这些文字。"
+)
+
+// attachmentService stores the files attached to questions and extracts their text
+type attachmentService struct {
+	fileService     interfaces.FileService
+	sessionRepo     interfaces.SessionRepository
+	messageRepo     interfaces.MessageRepository
+	kbService       interfaces.KnowledgeBaseService
+	modelService    interfaces.ModelService
+	usageService    interfaces.UsageService
+	docReaderClient *client.Client
+	config          *config.Config
+}
+
+// NewAttachmentService creates a new attachment service
+func NewAttachmentService(fileService interfaces.FileService,
+	sessionRepo interfaces.SessionRepository,
+	messageRepo interfaces.MessageRepository,
+	kbService interfaces.KnowledgeBaseService,
+	modelService interfaces.ModelService,
+	usageService interfaces.UsageService,
+	docReaderClient *client.Client,
+	config *config.Config,
+) interfaces.AttachmentService {
+	return &attachmentService{
+		fileService:     fileService,
+		sessionRepo:     sessionRepo,
+		messageRepo:     messageRepo,
+		kbService:       kbService,
+		modelService:    modelService,
+		usageService:    usageService,
+		docReaderClient: docReaderClient,
+		config:          config,
+	}
+}
+
+// attachmentType returns the type of an attachment by its file name, empty when it is not supported
+func attachmentType(filename string) string {
+	switch strings.ToLower(getFileType(filename)) {
+	case "jpg", "jpeg", "png", "gif", "webp", "bmp":
+		return types.AttachmentTypeImage
+	}
+	if isValidFileType(filename) {
+		return types.AttachmentTypeFile
+	}
+	return ""
+}
+
+// PrepareAttachments stores the files attached to a question of a session and extracts their text
+func (s *attachmentService) PrepareAttachments(ctx context.Context,
+	sessionID string, knowledgeBaseIDs []string, files []*multipart.FileHeader,
+) (types.MessageAttachments, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	if len(files) > types.MaxMessageAttachments {
+		return nil, werrors.NewBadRequestError(fmt.Sprintf("最多上传%d个附件", types.MaxMessageAttachments))
+	}
+	for _, file := range files {
+		if attachmentType(file.Filename) == "" {
+			return nil, werrors.NewBadRequestError("不支持的附件类型").WithDetails(file.Filename)
+		}
+		if file.Size > types.MaxAttachmentSize {
+			return nil, werrors.NewBadRequestError(
+				fmt.Sprintf("附件大小不能超过%dMB", types.MaxAttachmentSize>>20)).WithDetails(file.Filename)
+		}
+	}
+
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	var vlm chat.Chat
+	vlmLoaded := false
+	attachments := make(types.MessageAttachments, 0, len(files))
+	for _, file := range files {
+		data, err := readMultipartFile(file)
+		if err != nil {
+			return nil, err
+		}
+		attachment := types.MessageAttachment{
+			Type:     attachmentType(file.Filename),
+			Name:     file.Filename,
+			MimeType: http.DetectContentType(data),
+			Size:     file.Size,
+			Data:     data,
+		}
+
+		switch attachment.Type {
+		case types.AttachmentTypeImage:
+			if !strings.HasPrefix(attachment.MimeType, "image/") {
+				return nil, werrors.NewBadRequestError("无法识别的图片").WithDetails(file.Filename)
+			}
+			if !vlmLoaded {
+				vlm = s.captionModel(ctx, knowledgeBaseIDs)
+				vlmLoaded = true
+			}
+			if vlm != nil {
+				attachment.Caption = s.captionImage(ctx, vlm, &attachment)
+			}
+		case types.AttachmentTypeFile:
+			content, err := s.parseDocument(ctx, file.Filename, data)
+			if err != nil {
+				logger.Warnf(ctx, "Failed to parse attachment %s: %v", file.Filename, err)
+				return nil, werrors.NewBadRequestError("附件解析失败").WithDetails(file.Filename)
+			}
+			if content == "" {
+				return nil, werrors.NewBadRequestError("附件中没有可读取的文本").WithDetails(file.Filename)
+			}
+			attachment.Content = content
+		}
+
+		attachment.FilePath, err = s.fileService.SaveFile(ctx, file, tenantID, "attachments/"+sessionID)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to save attachment %s: %v", file.Filename, err)
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	logger.Infof(ctx, "Prepared %d attachments for session %s", len(attachments), sessionID)
+	return attachments, nil
+}
+
+// readMultipartFile reads the content of an uploaded file
+func readMultipartFile(file *multipart.FileHeader) ([]byte, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return io.ReadAll(src)
+}
+
+// captionModel returns the VLM model of the first knowledge base enabling one, nil when there is none
+func (s *attachmentService) captionModel(ctx context.Context, knowledgeBaseIDs []string) chat.Chat {
+	for _, kbID := range types.FilterReadableKnowledgeBaseIDs(ctx, knowledgeBaseIDs) {
+		kb, err := s.kbService.GetKnowledgeBaseByID(ctx, kbID)
+		if err != nil {
+			logger.Warnf(ctx, "Failed to get knowledge base %s for image captions: %v", kbID, err)
+			continue
+		}
+		if !kb.VLMConfig.IsEnabled() {
+			continue
+		}
+		var vlm chat.Chat
+		if kb.VLMConfig.Enabled && kb.VLMConfig.ModelID != "" {
+			vlm, err = s.modelService.GetChatModel(ctx, kb.VLMConfig.ModelID)
+		} else {
+			// 兼容老版本配置
+			source := types.ModelSourceRemote
+			if kb.VLMConfig.InterfaceType == "ollama" {
+				source = types.ModelSourceLocal
+			}
+			vlm, err = chat.NewChat(&chat.ChatConfig{
+				Source:    source,
+				BaseURL:   kb.VLMConfig.BaseURL,
+				ModelName: kb.VLMConfig.ModelName,
+				APIKey:    kb.VLMConfig.APIKey,
+			})
+			if err == nil {
+				vlm = s.usageService.WrapChat(vlm)
+			}
+		}
+		if err != nil {
+			logger.Warnf(ctx, "Failed to get VLM model of knowledge base %s: %v", kbID, err)
+			continue
+		}
+		return vlm
+	}
+	return nil
+}
+
+// captionImage describes an image with the VLM model, empty when it fails as the caption is optional
+func (s *attachmentService) captionImage(
+	ctx context.Context, vlm chat.Chat, attachment *types.MessageAttachment,
+) string {
+	prompt := s.config.Conversation.ImageCaptionPrompt
+	if prompt == "" {
+		prompt = defaultImageCaptionPrompt
+	}
+	response, err := vlm.Chat(ctx, []chat.Message{{
+		Role:    "user",
+		Content: prompt,
+		Images:  []string{attachment.DataURL()},
+	}}, &chat.ChatOptions{Temperature: 0.1})
+	if err != nil {
+		logger.Warnf(ctx, "Failed to caption image %s: %v", attachment.Name, err)
+		return ""
+	}
+	return strings.TrimSpace(response.Content)
+}
+
+// parseDocument parses a document into text, truncated to MaxAttachmentContentLength characters
+func (s *attachmentService) parseDocument(ctx context.Context, filename string, data []byte) (string, error) {
+	requestID, _ := ctx.Value(types.RequestIDContextKey).(string)
+	chunks, err := parseFileChunks(ctx, s.docReaderClient, &proto.ReadFromFileRequest{
+		FileContent: data,
+		FileName:    filename,
+		FileType:    strings.ToLower(getFileType(filename)),
+		ReadConfig: &proto.ReadConfig{
+			ChunkSize: attachmentChunkSize,
+		},
+		RequestId: requestID,
+	})
+	if err != nil {
+		return "", err
+	}
+	var content strings.Builder
+	for _, chunk := range chunks {
+		if text := strings.TrimSpace(chunk.Content); text != "" {
+			content.WriteString(text)
+			content.WriteString("\n")
+		}
+	}
+	text := []rune(strings.TrimSpace(content.String()))
+	if len(text) > types.MaxAttachmentContentLength {
+		logger.Infof(ctx, "Attachment %s truncated from %d characters", filename, len(text))
+		text = text[:types.MaxAttachmentContentLength]
+	}
+	return string(text), nil
+}
+
+// GetAttachment gets an attachment of a message with the content of its file
+func (s *attachmentService) GetAttachment(ctx context.Context,
+	sessionID string, messageID string, index int,
+) (*types.MessageAttachment, io.ReadCloser, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	if _, err := s.sessionRepo.Get(ctx, tenantID, sessionID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, werrors.NewNotFoundError("会话不存在")
+		}
+		return nil, nil, err
+	}
+	message, err := s.messageRepo.GetMessage(ctx, sessionID, messageID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, werrors.NewNotFoundError("消息不存在")
+		}
+		return nil, nil, err
+	}
+	if index < 0 || index >= len(message.Attachments) {
+		return nil, nil, werrors.NewNotFoundError("附件不存在")
+	}
+	attachment := message.Attachments[index]
+	file, err := s.fileService.GetFile(ctx, attachment.FilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &attachment, file, nil
+}
+
+// questionWithCaptions appends the captions of the images attached to a question,
+// so that the knowledge bases are searched for what the images show
+func questionWithCaptions(query string, attachments types.MessageAttachments) string {
+	if captions := attachments.Captions(); captions != "" {
+		return query + "\n" + captions
+	}
+	return query
+}
+
+// attachmentContent formats the text of the documents attached to a question for the prompt
+func attachmentContent(attachments types.MessageAttachments) string {
+	var content strings.Builder
+	for _, attachment := range attachments {
+		if attachment.Type == types.AttachmentTypeFile && attachment.Content != "" {
+			fmt.Fprintf(&content, "\n\n附件《%s》内容：\n%s", attachment.Name, attachment.Content)
+		}
+	}
+	return content.String()
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+)
+
+const (
+	// auditExportBatchSize is the number of audit logs read at once when exporting
+	auditExportBatchSize = 500
+	// auditRedacted replaces secrets in the recorded changes
+	auditRedacted = "******"
+)
+
+// auditSecretFields are the suffixes of the JSON fields whose values are never written to the audit log
+var auditSecretFields = []string{
+	"api_key", "apikey", "password", "secret", "secret_key", "secretkey", "token", "key_hash", "access_key",
+}
+
+// auditIgnoredFields change on every update and carry no information
+var auditIgnoredFields = []string{"updated_at"}
+
+// auditService records operations in the append-only audit log
+type auditService struct {
+	repo interfaces.AuditRepository
+}
+
+// NewAuditService creates a new audit service
+func NewAuditService(repo interfaces.AuditRepository) interfaces.AuditService {
+	return &auditService{repo: repo}
+}
+
+// Record writes an operation to the audit log, with the changed fields between before and after
+func (s *auditService) Record(ctx context.Context, entry *types.AuditLog, before, after any) error {
+	if before != nil || after != nil {
+		changes, err := diffAuditState(before, after)
+		if err != nil {
+			logger.Warnf(ctx, "Failed to compute audit changes of %s: %v", entry.Action, err)
+		} else if len(changes) > 0 {
+			entry.Changes = changes
+		}
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	// The operation has been performed, it is recorded even if the client went away
+	if err := s.repo.Create(context.WithoutCancel(ctx), entry); err != nil {
+		logger.Errorf(ctx, "Failed to write audit log of %s in tenant %d: %v", entry.Action, entry.TenantID, err)
+		return err
+	}
+	return nil
+}
+
+// ListAuditLogs lists the audit logs of the current tenant, newest first
+func (s *auditService) ListAuditLogs(
+	ctx context.Context, filter *types.AuditLogFilter, page *types.Pagination,
+) (*types.PageResult, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	entries, total, err := s.repo.List(ctx, tenantID, filter, page)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to list audit logs of tenant %d: %v", tenantID, err)
+		return nil, err
+	}
+	return types.NewPageResult(total, page, entries), nil
+}
+
+// ExportAuditLogs writes the audit logs of the current tenant to w as JSON lines, oldest first
+func (s *auditService) ExportAuditLogs(ctx context.Context, filter *types.AuditLogFilter, w io.Writer) error {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	encoder := json.NewEncoder(w)
+	var afterID uint64
+	for {
+		entries, err := s.repo.ListAfter(ctx, tenantID, filter, afterID, auditExportBatchSize)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to export audit logs of tenant %d: %v", tenantID, err)
+			return err
+		}
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				return err
+			}
+		}
+		if len(entries) < auditExportBatchSize {
+			return nil
+		}
+		afterID = entries[len(entries)-1].ID
+	}
+}
+
+// diffAuditState returns the top level fields which differ between the JSON forms of before and after,
+// with secrets redacted. Values which are not JSON objects are compared as a whole under an empty field name.
+func diffAuditState(before, after any) (types.AuditChanges, error) {
+	beforeValue, err := toAuditValue(before)
+	if err != nil {
+		return nil, err
+	}
+	afterValue, err := toAuditValue(after)
+	if err != nil {
+		return nil, err
+	}
+
+	beforeFields, beforeIsObject := beforeValue.(map[string]any)
+	afterFields, afterIsObject := afterValue.(map[string]any)
+	if (!beforeIsObject && beforeValue != nil) || (!afterIsObject && afterValue != nil) {
+		if reflect.DeepEqual(beforeValue, afterValue) {
+			return nil, nil
+		}
+		return types.AuditChanges{"": {
+			Before: redactAuditValue(beforeValue), After: redactAuditValue(afterValue),
+		}}, nil
+	}
+
+	changes := types.AuditChanges{}
+	addChange := func(field string, before, after any) {
+		if isAuditSecretField(field) {
+			// A changed secret is recorded without its values
+			changes[field] = types.AuditChange{Before: redactAuditSecret(before), After: redactAuditSecret(after)}
+			return
+		}
+		changes[field] = types.AuditChange{Before: redactAuditValue(before), After: redactAuditValue(after)}
+	}
+	for field, value := range beforeFields {
+		if isAuditIgnoredField(field) {
+			continue
+		}
+		if afterField, ok := afterFields[field]; !ok || !reflect.DeepEqual(value, afterField) {
+			addChange(field, value, afterFields[field])
+		}
+	}
+	for field, value := range afterFields {
+		if _, ok := beforeFields[field]; !ok && !isAuditIgnoredField(field) {
+			addChange(field, nil, value)
+		}
+	}
+	return changes, nil
+}
+
+// toAuditValue converts a value to its JSON form
+func toAuditValue(value any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var result any
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// redactAuditSecret hides a secret value, keeping whether it is set
+func redactAuditSecret(value any) any {
+	if value == nil || value == "" {
+		return value
+	}
+	return auditRedacted
+}
+
+// redactAuditValue replaces the values of secret fields at any depth
+func redactAuditValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for field, fieldValue := range v {
+			if isAuditSecretField(field) {
+				v[field] = redactAuditSecret(fieldValue)
+				continue
+			}
+			v[field] = redactAuditValue(fieldValue)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactAuditValue(item)
+		}
+	}
+	return value
+}
+
+func isAuditSecretField(field string) bool {
+	field = strings.ToLower(field)
+	for _, secret := range auditSecretFields {
+		if strings.HasSuffix(field, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+func isAuditIgnoredField(field string) bool {
+	return slices.Contains(auditIgnoredFields, field)
+}
@@ -0,0 +1,102 @@
+package chatpipline
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Tencent/WeKnora/internal/event"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+)
+
+// PluginAnswerCache replays cached answers of similar questions and caches new answers
+type PluginAnswerCache struct {
+	answerCacheService interfaces.AnswerCacheService
+}
+
+// NewPluginAnswerCache creates a new answer cache plugin and registers it with the event manager
+func NewPluginAnswerCache(eventManager *EventManager,
+	answerCacheService interfaces.AnswerCacheService,
+) *PluginAnswerCache {
+	res := &PluginAnswerCache{answerCacheService: answerCacheService}
+	eventManager.Register(res)
+	return res
+}
+
+// ActivationEvents returns the event types this plugin handles
+func (p *PluginAnswerCache) ActivationEvents() []types.EventType {
+	return []types.EventType{types.ANSWER_CACHE}
+}
+
+// OnEvent looks up the rewritten query in the answer cache.
+// On a hit the cached answer and references are put on the chat manage and ErrAnswerCacheHit stops the pipeline,
+// on a miss the streamed answer is cached once it completes.
+func (p *PluginAnswerCache) OnEvent(ctx context.Context,
+	eventType types.EventType, chatManage *types.ChatManage, next func() *PluginError,
+) *PluginError {
+	// The answer to a question with attachments depends on them, it is neither replayed nor cached
+	if len(chatManage.Images) > 0 || chatManage.AttachmentContent != "" {
+		return next()
+	}
+	entry, key, err := p.answerCacheService.Lookup(ctx, chatManage)
+	if err != nil {
+		// The cache is an optimization, answer the question without it
+		pipelineWarn(ctx, "AnswerCache", "lookup", map[string]interface{}{
+			"session_id": chatManage.SessionID,
+			"error":      err.Error(),
+		})
+		return next()
+	}
+	if key == nil {
+		return next()
+	}
+	if entry != nil {
+		pipelineInfo(ctx, "AnswerCache", "hit", map[string]interface{}{
+			"session_id":   chatManage.SessionID,
+			"cached_query": entry.Query,
+			"references":   len(entry.References),
+		})
+		chatManage.MergeResult = entry.References
+		chatManage.ChatResponse = &types.ChatResponse{Content: entry.Answer}
+		chatManage.AnswerCacheHit = true
+		return ErrAnswerCacheHit
+	}
+
+	pipelineInfo(ctx, "AnswerCache", "miss", map[string]interface{}{
+		"session_id": chatManage.SessionID,
+	})
+	if chatManage.EventBus != nil {
+		p.storeOnCompletion(ctx, chatManage, key)
+	}
+	return next()
+}
+
+// storeOnCompletion caches the streamed answer once its last chunk is emitted.
+// Fallback answers, which are given without references, and no-match answers are not cached.
+func (p *PluginAnswerCache) storeOnCompletion(ctx context.Context,
+	chatManage *types.ChatManage, key *types.AnswerCacheKey,
+) {
+	answer := &strings.Builder{}
+	chatManage.EventBus.On(types.EventType(event.EventAgentFinalAnswer), func(_ context.Context, evt types.Event) error {
+		data, ok := evt.Data.(event.AgentFinalAnswerData)
+		if !ok {
+			return nil
+		}
+		answer.WriteString(data.Content)
+		if !data.Done {
+			return nil
+		}
+		content := answer.String()
+		noMatchPrefix := chatManage.SummaryConfig.NoMatchPrefix
+		if len(chatManage.MergeResult) == 0 || strings.TrimSpace(content) == "" ||
+			(noMatchPrefix != "" && strings.HasPrefix(content, noMatchPrefix)) {
+			return nil
+		}
+		// The request context is cancelled as soon as the answer completes
+		if err := p.answerCacheService.Store(context.WithoutCancel(ctx), key, content, chatManage.MergeResult); err != nil {
+			logger.Warnf(ctx, "Failed to cache answer: %v", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,141 @@
+package chatpipline
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/event"
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// fakeAnswerCache returns a canned lookup result and records stored answers
+type fakeAnswerCache struct {
+	entry  *types.AnswerCacheEntry
+	key    *types.AnswerCacheKey
+	err    error
+	stored []string
+}
+
+func (f *fakeAnswerCache) Lookup(ctx context.Context,
+	chatManage *types.ChatManage,
+) (*types.AnswerCacheEntry, *types.AnswerCacheKey, error) {
+	return f.entry, f.key, f.err
+}
+
+func (f *fakeAnswerCache) Store(ctx context.Context,
+	key *types.AnswerCacheKey, answer string, references []*types.SearchResult,
+) error {
+	f.stored = append(f.stored, answer)
+	return nil
+}
+
+func (f *fakeAnswerCache) InvalidateKnowledgeBase(ctx context.Context, knowledgeBaseID string) error {
+	return nil
+}
+
+func emitAnswer(t *testing.T, bus types.EventBusInterface, content string, done bool) {
+	t.Helper()
+	if err := bus.Emit(context.Background(), types.Event{
+		Type: types.EventType(event.EventAgentFinalAnswer),
+		Data: event.AgentFinalAnswerData{Content: content, Done: done},
+	}); err != nil {
+		t.Fatalf("Emit() error = %v", err)
+	}
+}
+
+func TestPluginAnswerCache_Hit(t *testing.T) {
+	references := []*types.SearchResult{{ID: "chunk-1"}}
+	cache := &fakeAnswerCache{
+		entry: &types.AnswerCacheEntry{Query: "cached", Answer: "cached answer", References: references},
+		key:   &types.AnswerCacheKey{},
+	}
+	manager := NewEventManager()
+	NewPluginAnswerCache(manager, cache)
+
+	chatManage := &types.ChatManage{}
+	err := manager.Trigger(context.Background(), types.ANSWER_CACHE, chatManage)
+	if err != ErrAnswerCacheHit {
+		t.Fatalf("Trigger() error = %v, want ErrAnswerCacheHit", err)
+	}
+	if !chatManage.AnswerCacheHit || chatManage.ChatResponse.Content != "cached answer" ||
+		len(chatManage.MergeResult) != 1 {
+		t.Errorf("cached answer not applied: %+v", chatManage)
+	}
+}
+
+func TestPluginAnswerCache_MissStoresCompletedAnswer(t *testing.T) {
+	cache := &fakeAnswerCache{key: &types.AnswerCacheKey{}}
+	manager := NewEventManager()
+	NewPluginAnswerCache(manager, cache)
+
+	bus := event.NewEventBus().AsEventBusInterface()
+	chatManage := &types.ChatManage{EventBus: bus}
+	if err := manager.Trigger(context.Background(), types.ANSWER_CACHE, chatManage); err != nil {
+		t.Fatalf("Trigger() error = %v", err)
+	}
+	chatManage.MergeResult = []*types.SearchResult{{ID: "chunk-1"}}
+
+	emitAnswer(t, bus, "Hello, ", false)
+	if len(cache.stored) != 0 {
+		t.Fatalf("answer stored before completion: %v", cache.stored)
+	}
+	emitAnswer(t, bus, "world", true)
+	if len(cache.stored) != 1 || cache.stored[0] != "Hello, world" {
+		t.Errorf("stored = %v, want [Hello, world]", cache.stored)
+	}
+}
+
+func TestPluginAnswerCache_SkipsUncacheableAnswers(t *testing.T) {
+	tests := []struct {
+		name       string
+		cache      *fakeAnswerCache
+		references []*types.SearchResult
+	}{
+		{name: "disabled", cache: &fakeAnswerCache{}, references: []*types.SearchResult{{ID: "chunk-1"}}},
+		{name: "lookup error", cache: &fakeAnswerCache{err: errors.New("redis down")}},
+		{name: "fallback without references", cache: &fakeAnswerCache{key: &types.AnswerCacheKey{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewEventManager()
+			NewPluginAnswerCache(manager, tt.cache)
+
+			bus := event.NewEventBus().AsEventBusInterface()
+			chatManage := &types.ChatManage{EventBus: bus}
+			if err := manager.Trigger(context.Background(), types.ANSWER_CACHE, chatManage); err != nil {
+				t.Fatalf("Trigger() error = %v", err)
+			}
+			chatManage.MergeResult = tt.references
+
+			emitAnswer(t, bus, "answer", true)
+			if len(tt.cache.stored) != 0 {
+				t.Errorf("stored = %v, want nothing", tt.cache.stored)
+			}
+		})
+	}
+}
+
+func TestPluginAnswerCache_BypassesQuestionsWithAttachments(t *testing.T) {
+	cache := &fakeAnswerCache{
+		entry: &types.AnswerCacheEntry{Query: "cached", Answer: "cached answer"},
+		key:   &types.AnswerCacheKey{},
+	}
+	manager := NewEventManager()
+	NewPluginAnswerCache(manager, cache)
+
+	bus := event.NewEventBus().AsEventBusInterface()
+	chatManage := &types.ChatManage{EventBus: bus, Images: []string{"data:image/png;base64,AA=="}}
+	if err := manager.Trigger(context.Background(), types.ANSWER_CACHE, chatManage); err != nil {
+		t.Fatalf("Trigger() error = %v", err)
+	}
+	if chatManage.AnswerCacheHit {
+		t.Error("cached answer replayed for a question with an image")
+	}
+	chatManage.MergeResult = []*types.SearchResult{{ID: "chunk-1"}}
+
+	emitAnswer(t, bus, "answer", true)
+	if len(cache.stored) != 0 {
+		t.Errorf("stored = %v, want nothing", cache.stored)
+	}
+}
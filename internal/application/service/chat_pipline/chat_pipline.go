@@ -122,6 +122,10 @@ var (
 		Description: "Failed to get conversation history",
 		ErrorType:   "get_history_failed",
 	}
+	ErrAnswerCacheHit = &PluginError{
+		Description: "Answer replayed from cache",
+		ErrorType:   "answer_cache_hit",
+	}
 )
 
 // clone creates a copy of the PluginError
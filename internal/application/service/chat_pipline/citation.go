@@ -0,0 +1,279 @@
+package chatpipline
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Tencent/WeKnora/internal/event"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/google/uuid"
+)
+
+// maxPendingMarker bounds the text held back while a citation marker may still be completed by the next chunk
+const maxPendingMarker = 32
+
+var (
+	// citationMarkerRegex matches a citation marker at the start of the text, such as [1] or [1, 3]
+	citationMarkerRegex = regexp.MustCompile(`^\[(\d{1,3}(?:\s*[,，]\s*\d{1,3})*)\]`)
+	// citationPrefixRegex matches the start of a citation marker which is not complete yet
+	citationPrefixRegex = regexp.MustCompile(`^\[[\d\s,，]*$`)
+	// citationNumberRegex matches the source numbers of a citation marker
+	citationNumberRegex = regexp.MustCompile(`\d+`)
+)
+
+// PluginVerifyCitations verifies the citation markers of the streamed answer against the numbered sources.
+// The stage wraps the event bus the answer of the following completion stage is streamed to:
+// markers citing no reference are stripped or flagged, and the citations are emitted before the last answer chunk.
+type PluginVerifyCitations struct{}
+
+// NewPluginVerifyCitations creates a new citation verification plugin and registers it with the event manager
+func NewPluginVerifyCitations(eventManager *EventManager) *PluginVerifyCitations {
+	res := &PluginVerifyCitations{}
+	eventManager.Register(res)
+	return res
+}
+
+// ActivationEvents returns the event types this plugin handles
+func (p *PluginVerifyCitations) ActivationEvents() []types.EventType {
+	return []types.EventType{types.VERIFY_CITATIONS}
+}
+
+// OnEvent wraps the event bus of the chat manage to verify the citations of the answer
+func (p *PluginVerifyCitations) OnEvent(ctx context.Context,
+	eventType types.EventType, chatManage *types.ChatManage, next func() *PluginError,
+) *PluginError {
+	mode := types.CitationMode(chatManage.StageParams.String("mode", string(types.CitationModeStrip)))
+	pipelineInfo(ctx, "VerifyCitations", "input", map[string]interface{}{
+		"session_id": chatManage.SessionID,
+		"sources":    len(chatManage.Sources),
+		"mode":       mode,
+	})
+	if chatManage.EventBus == nil {
+		pipelineWarn(ctx, "VerifyCitations", "skip", map[string]interface{}{
+			"reason": "eventbus_missing",
+		})
+		return next()
+	}
+
+	chatManage.EventBus = &citationEventBus{
+		EventBusInterface: chatManage.EventBus,
+		verifier:          newCitationVerifier(chatManage.Sources, chatManage.MergeResult, mode),
+		sessionID:         chatManage.SessionID,
+	}
+	return next()
+}
+
+// ValidateStageParams checks the parameters of a citation verification stage
+func (p *PluginVerifyCitations) ValidateStageParams(eventType types.EventType, params types.PipelineStageParams) error {
+	if err := validateStageParams(params, map[string]stageParamKind{"mode": stageParamString}); err != nil {
+		return err
+	}
+	switch mode := types.CitationMode(params.String("mode", string(types.CitationModeStrip))); mode {
+	case types.CitationModeStrip, types.CitationModeFlag:
+		return nil
+	default:
+		return fmt.Errorf("parameter mode must be %s or %s", types.CitationModeStrip, types.CitationModeFlag)
+	}
+}
+
+// VerifyCitations verifies the citation markers of a complete answer,
+// it returns the answer without the stripped markers and its citations
+func VerifyCitations(answer string,
+	sources []*types.SearchResult, references []*types.SearchResult, mode types.CitationMode,
+) (string, []types.Citation) {
+	verifier := newCitationVerifier(sources, references, mode)
+	content := verifier.Write(answer, true)
+	return content, verifier.citations
+}
+
+// citationEventBus verifies the citations of the answer chunks emitted to the wrapped event bus
+type citationEventBus struct {
+	types.EventBusInterface
+	verifier  *citationVerifier
+	sessionID string
+}
+
+// Emit verifies the answer chunks and emits the citations before the last one, other events are emitted as is
+func (b *citationEventBus) Emit(ctx context.Context, evt types.Event) error {
+	data, ok := evt.Data.(event.AgentFinalAnswerData)
+	if evt.Type != types.EventType(event.EventAgentFinalAnswer) || !ok {
+		return b.EventBusInterface.Emit(ctx, evt)
+	}
+
+	data.Content = b.verifier.Write(data.Content, data.Done)
+	if !data.Done {
+		if data.Content == "" {
+			return nil
+		}
+		evt.Data = data
+		return b.EventBusInterface.Emit(ctx, evt)
+	}
+
+	// The stream handler completes the message on the last chunk, the citations have to be known by then
+	pipelineInfo(ctx, "VerifyCitations", "output", map[string]interface{}{
+		"session_id": b.sessionID,
+		"citations":  len(b.verifier.citations),
+		"stripped":   b.verifier.stripped,
+	})
+	if err := b.EventBusInterface.Emit(ctx, types.Event{
+		ID:        fmt.Sprintf("%s-citations", uuid.New().String()[:8]),
+		Type:      types.EventType(event.EventAgentCitations),
+		SessionID: b.sessionID,
+		Data:      event.AgentCitationsData{Citations: b.verifier.citations},
+	}); err != nil {
+		pipelineError(ctx, "VerifyCitations", "emit", map[string]interface{}{
+			"session_id": b.sessionID,
+			"error":      err.Error(),
+		})
+	}
+	evt.Data = data
+	return b.EventBusInterface.Emit(ctx, evt)
+}
+
+// citationVerifier rewrites a streamed answer, checking every citation marker against the numbered sources.
+// A source is verified when it was numbered in the prompt and is one of the references of the answer.
+type citationVerifier struct {
+	sources    []*types.SearchResult
+	references map[string]bool
+	mode       types.CitationMode
+
+	pending   string          // text held back until a possible citation marker is complete
+	answer    strings.Builder // the rewritten answer emitted so far
+	prevRune  rune
+	citations []types.Citation
+	stripped  int
+
+	sentenceStart int // byte offset of the sentence being written
+	markerEnd     int // byte offset after the last citation marker
+	spanStart     int // byte offsets of the sentence cited by the last citation marker
+	spanEnd       int
+	cited         bool
+}
+
+// newCitationVerifier creates a verifier of the citations of the sources, only the references may be cited
+func newCitationVerifier(sources []*types.SearchResult,
+	references []*types.SearchResult, mode types.CitationMode,
+) *citationVerifier {
+	verifier := &citationVerifier{
+		sources:    sources,
+		references: make(map[string]bool, len(references)),
+		mode:       mode,
+	}
+	for _, reference := range references {
+		verifier.references[reference.ID] = true
+	}
+	return verifier
+}
+
+// Write rewrites the next chunk of the answer, final flushes the text held back
+func (v *citationVerifier) Write(content string, final bool) string {
+	v.pending += content
+	var out strings.Builder
+	for {
+		i := strings.IndexByte(v.pending, '[')
+		if i < 0 {
+			v.emit(&out, v.pending)
+			v.pending = ""
+			break
+		}
+		v.emit(&out, v.pending[:i])
+		v.pending = v.pending[i:]
+
+		loc := citationMarkerRegex.FindStringSubmatchIndex(v.pending)
+		if loc == nil {
+			if !final && len(v.pending) < maxPendingMarker && citationPrefixRegex.MatchString(v.pending) {
+				break
+			}
+			v.emit(&out, "[")
+			v.pending = v.pending[1:]
+			continue
+		}
+		end := loc[1]
+		if end == len(v.pending) && !final {
+			// The next character tells a citation from a Markdown link
+			break
+		}
+		if strings.HasPrefix(v.pending[end:], "(") {
+			v.emit(&out, v.pending[:end])
+		} else {
+			v.cite(&out, v.pending[:end], v.pending[loc[2]:loc[3]])
+		}
+		v.pending = v.pending[end:]
+	}
+	return out.String()
+}
+
+// emit writes text to the answer and tracks where the sentences start
+func (v *citationVerifier) emit(out *strings.Builder, text string) {
+	for _, r := range text {
+		v.answer.WriteRune(r)
+		switch {
+		case strings.ContainsRune("。！？!?；;\n", r):
+			v.sentenceStart = v.answer.Len()
+		case unicode.IsSpace(r) && v.prevRune == '.':
+			v.sentenceStart = v.answer.Len()
+		}
+		v.prevRune = r
+	}
+	out.WriteString(text)
+}
+
+// cite verifies the sources of a citation marker, records their citations and writes the verified marker
+func (v *citationVerifier) cite(out *strings.Builder, marker string, numbers string) {
+	answer := v.answer.String()
+	start, end := max(v.sentenceStart, v.markerEnd), len(answer)
+	if v.cited && strings.TrimSpace(answer[v.markerEnd:]) == "" {
+		// Adjacent markers cite the same sentence
+		start, end = v.spanStart, v.spanEnd
+	} else {
+		start = end - len(strings.TrimLeftFunc(answer[start:end], unicode.IsSpace))
+		end = start + len(strings.TrimRightFunc(answer[start:end], unicode.IsSpace))
+	}
+
+	all := citationNumberRegex.FindAllString(numbers, -1)
+	kept := make([]string, 0, len(all))
+	for _, number := range all {
+		source, _ := strconv.Atoi(number)
+		citation := v.verify(source)
+		if !citation.Verified && v.mode != types.CitationModeFlag {
+			v.stripped++
+			continue
+		}
+		citation.Start = utf8.RuneCountInString(answer[:start])
+		citation.End = citation.Start + utf8.RuneCountInString(answer[start:end])
+		citation.Text = answer[start:end]
+		v.citations = append(v.citations, citation)
+		kept = append(kept, number)
+	}
+
+	switch {
+	case len(kept) == len(all):
+		v.emit(out, marker)
+	case len(kept) > 0:
+		v.emit(out, "["+strings.Join(kept, ", ")+"]")
+	}
+	v.markerEnd = v.answer.Len()
+	v.spanStart, v.spanEnd, v.cited = start, end, true
+}
+
+// verify returns the citation of a source, verified when the source is one of the references
+func (v *citationVerifier) verify(source int) types.Citation {
+	citation := types.Citation{Source: source}
+	if source < 1 || source > len(v.sources) || !v.references[v.sources[source-1].ID] {
+		return citation
+	}
+	chunk := v.sources[source-1]
+	citation.Verified = true
+	citation.ChunkID = chunk.ID
+	citation.KnowledgeID = chunk.KnowledgeID
+	citation.KnowledgeTitle = chunk.KnowledgeTitle
+	citation.ChunkIndex = chunk.ChunkIndex
+	citation.StartAt = chunk.StartAt
+	citation.EndAt = chunk.EndAt
+	return citation
+}
@@ -0,0 +1,105 @@
+package chatpipline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/event"
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+func testCitationSources() []*types.SearchResult {
+	return []*types.SearchResult{
+		{ID: "chunk-1", KnowledgeID: "k1", KnowledgeTitle: "合同法", StartAt: 0, EndAt: 120},
+		{ID: "chunk-2", KnowledgeID: "k2", KnowledgeTitle: "民法典", StartAt: 40, EndAt: 300},
+	}
+}
+
+func TestVerifyCitationsStrip(t *testing.T) {
+	sources := testCitationSources()
+	answer, citations := VerifyCitations("合同需要书面形式[1]。违约需赔偿[2][7]。链接[1](http://a)无引用",
+		sources, sources, types.CitationModeStrip)
+
+	if want := "合同需要书面形式[1]。违约需赔偿[2]。链接[1](http://a)无引用"; answer != want {
+		t.Errorf("Expected answer %q, got %q", want, answer)
+	}
+	if len(citations) != 2 {
+		t.Fatalf("Expected 2 citations, got %d: %+v", len(citations), citations)
+	}
+	if c := citations[0]; c.Source != 1 || c.ChunkID != "chunk-1" || c.Text != "合同需要书面形式" ||
+		c.Start != 0 || c.End != 8 || !c.Verified {
+		t.Errorf("Unexpected first citation %+v", c)
+	}
+	if c := citations[1]; c.Source != 2 || c.ChunkID != "chunk-2" || c.Text != "违约需赔偿" ||
+		c.Start != 12 || c.End != 17 || c.EndAt != 300 {
+		t.Errorf("Unexpected second citation %+v", c)
+	}
+}
+
+func TestVerifyCitationsFlag(t *testing.T) {
+	sources := testCitationSources()
+	// The second source is not a reference of the answer
+	answer, citations := VerifyCitations("A contract needs a written form [1, 2]. Damages apply [3].",
+		sources, sources[:1], types.CitationModeFlag)
+
+	if want := "A contract needs a written form [1, 2]. Damages apply [3]."; answer != want {
+		t.Errorf("Expected answer %q, got %q", want, answer)
+	}
+	if len(citations) != 3 {
+		t.Fatalf("Expected 3 citations, got %d: %+v", len(citations), citations)
+	}
+	if !citations[0].Verified || citations[1].Verified || citations[2].Verified {
+		t.Errorf("Expected only the first citation to be verified, got %+v", citations)
+	}
+	if citations[1].Text != "A contract needs a written form" || citations[2].Text != "Damages apply" {
+		t.Errorf("Unexpected cited sentences %q and %q", citations[1].Text, citations[2].Text)
+	}
+}
+
+func TestCitationEventBusStreaming(t *testing.T) {
+	ctx := context.Background()
+	bus := event.NewEventBus()
+	var answer string
+	var citations []types.Citation
+	bus.On(event.EventAgentFinalAnswer, func(ctx context.Context, evt event.Event) error {
+		answer += evt.Data.(event.AgentFinalAnswerData).Content
+		return nil
+	})
+	bus.On(event.EventAgentCitations, func(ctx context.Context, evt event.Event) error {
+		if answer == "" {
+			t.Errorf("Expected citations after the first answer chunks")
+		}
+		citations = evt.Data.(event.AgentCitationsData).Citations
+		return nil
+	})
+
+	sources := testCitationSources()
+	manager := &EventManager{}
+	NewPluginVerifyCitations(manager)
+	chatManage := &types.ChatManage{
+		EventBus:    bus.AsEventBusInterface(),
+		Sources:     sources,
+		MergeResult: sources,
+	}
+	if err := manager.Trigger(ctx, types.VERIFY_CITATIONS, chatManage); err != nil {
+		t.Fatalf("Expected nil error, got %v", err)
+	}
+
+	// Markers split across chunks are verified once complete
+	for i, chunk := range []string{"合同需要书面形式[", "1", "]。违约需赔偿[9", "]", "。"} {
+		if err := chatManage.EventBus.Emit(ctx, types.Event{
+			ID:   "answer",
+			Type: types.EventType(event.EventAgentFinalAnswer),
+			Data: event.AgentFinalAnswerData{Content: chunk, Done: i == 4},
+		}); err != nil {
+			t.Fatalf("Expected nil error, got %v", err)
+		}
+	}
+
+	if want := "合同需要书面形式[1]。违约需赔偿。"; answer != want {
+		t.Errorf("Expected answer %q, got %q", want, answer)
+	}
+	if len(citations) != 1 || citations[0].ChunkID != "chunk-1" {
+		t.Errorf("Expected a citation of chunk-1, got %+v", citations)
+	}
+}
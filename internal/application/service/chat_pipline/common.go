@@ -66,8 +66,12 @@ func prepareMessagesWithHistory(chatManage *types.ChatManage) []chat.Message {
 		chatMessages = append(chatMessages, chat.Message{Role: "assistant", Content: history.Answer})
 	}
 
-	// Add current user message
-	chatMessages = append(chatMessages, chat.Message{Role: "user", Content: chatManage.UserContent})
+	// Add current user message with the attachments of the question
+	chatMessages = append(chatMessages, chat.Message{
+		Role:    "user",
+		Content: chatManage.UserContent + chatManage.AttachmentContent,
+		Images:  chatManage.Images,
+	})
 
 	return chatMessages
 }
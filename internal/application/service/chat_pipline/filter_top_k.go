@@ -26,9 +26,10 @@ func (p *PluginFilterTopK) ActivationEvents() []types.EventType {
 func (p *PluginFilterTopK) OnEvent(ctx context.Context,
 	eventType types.EventType, chatManage *types.ChatManage, next func() *PluginError,
 ) *PluginError {
+	topK := chatManage.StageParams.Int("top_k", chatManage.RerankTopK)
 	pipelineInfo(ctx, "FilterTopK", "input", map[string]interface{}{
 		"session_id": chatManage.SessionID,
-		"top_k":      chatManage.RerankTopK,
+		"top_k":      topK,
 		"merge_cnt":  len(chatManage.MergeResult),
 		"rerank_cnt": len(chatManage.RerankResult),
 		"search_cnt": len(chatManage.SearchResult),
@@ -46,11 +47,11 @@ func (p *PluginFilterTopK) OnEvent(ctx context.Context,
 	}
 
 	if len(chatManage.MergeResult) > 0 {
-		chatManage.MergeResult = filterTopK(chatManage.MergeResult, chatManage.RerankTopK)
+		chatManage.MergeResult = filterTopK(chatManage.MergeResult, topK)
 	} else if len(chatManage.RerankResult) > 0 {
-		chatManage.RerankResult = filterTopK(chatManage.RerankResult, chatManage.RerankTopK)
+		chatManage.RerankResult = filterTopK(chatManage.RerankResult, topK)
 	} else if len(chatManage.SearchResult) > 0 {
-		chatManage.SearchResult = filterTopK(chatManage.SearchResult, chatManage.RerankTopK)
+		chatManage.SearchResult = filterTopK(chatManage.SearchResult, topK)
 	} else {
 		pipelineWarn(ctx, "FilterTopK", "skip", map[string]interface{}{
 			"reason": "no_results",
@@ -64,3 +65,8 @@ func (p *PluginFilterTopK) OnEvent(ctx context.Context,
 	})
 	return next()
 }
+
+// ValidateStageParams checks the parameters of a top K filtering stage
+func (p *PluginFilterTopK) ValidateStageParams(eventType types.EventType, params types.PipelineStageParams) error {
+	return validateStageParams(params, map[string]stageParamKind{"top_k": stageParamInt})
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"html/template"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -38,12 +39,13 @@ func (p *PluginIntoChatMessage) OnEvent(ctx context.Context,
 		"template_len":     len(chatManage.SummaryConfig.ContextTemplate),
 	})
 
-	// Extract content from merge results
+	// Extract content from merge results, numbered so that the answer can cite them
 	passages := make([]string, len(chatManage.MergeResult))
 	for i, result := range chatManage.MergeResult {
 		// 合并内容和图片信息
-		passages[i] = getEnrichedPassageForChat(ctx, result)
+		passages[i] = fmt.Sprintf("[%d] %s", i+1, getEnrichedPassageForChat(ctx, result))
 	}
+	chatManage.Sources = slices.Clone(chatManage.MergeResult)
 
 	// Parse the context template
 	tmpl, err := template.New("searchContent").Parse(chatManage.SummaryConfig.ContextTemplate)
@@ -0,0 +1,165 @@
+package chatpipline
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// maxPipelineStages bounds the number of stages of a declared pipeline
+const maxPipelineStages = 30
+
+// ConfigurablePlugin is implemented by the plugins whose stages accept parameters in pipeline definitions
+type ConfigurablePlugin interface {
+	Plugin
+	// ValidateStageParams checks the parameters of a stage triggering an event handled by the plugin
+	ValidateStageParams(eventType types.EventType, params types.PipelineStageParams) error
+}
+
+// PluginFunc is a plugin of a single stage implemented by a function
+type PluginFunc struct {
+	// Event is the name of the stage the function handles
+	Event types.EventType
+	// Handle handles the stage, it calls next to continue with the other plugins of the stage
+	Handle func(ctx context.Context, chatManage *types.ChatManage, next func() *PluginError) *PluginError
+}
+
+// ActivationEvents returns the stage of the function
+func (p *PluginFunc) ActivationEvents() []types.EventType {
+	return []types.EventType{p.Event}
+}
+
+// OnEvent calls the function
+func (p *PluginFunc) OnEvent(ctx context.Context,
+	eventType types.EventType, chatManage *types.ChatManage, next func() *PluginError,
+) *PluginError {
+	return p.Handle(ctx, chatManage, next)
+}
+
+// RegisterFunc registers a function as a custom stage, pipelines reference it by name
+func (e *EventManager) RegisterFunc(event types.EventType,
+	handle func(ctx context.Context, chatManage *types.ChatManage, next func() *PluginError) *PluginError,
+) {
+	e.Register(&PluginFunc{Event: event, Handle: handle})
+}
+
+// ValidatePipeline checks a declared pipeline only uses registered stages with valid parameters,
+// and that it answers by streaming a completion built from the chat message
+func (e *EventManager) ValidatePipeline(pipeline *types.PipelineConfig) error {
+	if pipeline == nil {
+		return nil
+	}
+	if len(pipeline.Stages) == 0 || len(pipeline.Stages) > maxPipelineStages {
+		return fmt.Errorf("a pipeline needs between 1 and %d stages", maxPipelineStages)
+	}
+
+	intoMessage, citations, completion := -1, -1, -1
+	for i, stage := range pipeline.Stages {
+		plugins, ok := e.listeners[stage.Event]
+		if !ok {
+			return fmt.Errorf("stage %d: unknown event %q", i+1, stage.Event)
+		}
+		switch stage.Event {
+		case types.CHAT_COMPLETION:
+			return fmt.Errorf("stage %d: use %s, answers are streamed", i+1, types.CHAT_COMPLETION_STREAM)
+		case types.INTO_CHAT_MESSAGE:
+			intoMessage = i
+		case types.VERIFY_CITATIONS:
+			citations = i
+		case types.CHAT_COMPLETION_STREAM:
+			if completion >= 0 {
+				return fmt.Errorf("stage %d: %s may only appear once", i+1, stage.Event)
+			}
+			completion = i
+		}
+		if len(stage.Params) == 0 {
+			continue
+		}
+
+		configurable := false
+		for _, plugin := range plugins {
+			if configurablePlugin, ok := plugin.(ConfigurablePlugin); ok {
+				configurable = true
+				if err := configurablePlugin.ValidateStageParams(stage.Event, stage.Params); err != nil {
+					return fmt.Errorf("stage %d (%s): %w", i+1, stage.Event, err)
+				}
+			}
+		}
+		if !configurable {
+			return fmt.Errorf("stage %d: %s accepts no parameters", i+1, stage.Event)
+		}
+	}
+	if completion < 0 {
+		return fmt.Errorf("a pipeline must contain the %s stage", types.CHAT_COMPLETION_STREAM)
+	}
+	if intoMessage < 0 || intoMessage > completion {
+		return fmt.Errorf("the %s stage must come before %s", types.INTO_CHAT_MESSAGE, types.CHAT_COMPLETION_STREAM)
+	}
+	// The citations are verified by wrapping the stream of the completion
+	if citations > completion {
+		return fmt.Errorf("the %s stage must come before %s", types.VERIFY_CITATIONS, types.CHAT_COMPLETION_STREAM)
+	}
+	return nil
+}
+
+// Run triggers the stages of a pipeline in order, each with its parameters.
+// It stops at the first stage returning an error and returns the error with the event of the stage.
+func (e *EventManager) Run(ctx context.Context,
+	stages []types.PipelineStage, chatManage *types.ChatManage,
+) (types.EventType, *PluginError) {
+	defer func() { chatManage.StageParams = nil }()
+	for _, stage := range stages {
+		pipelineInfo(ctx, "Pipeline", "trigger", map[string]interface{}{
+			"event":  stage.Event,
+			"params": stage.Params,
+		})
+		chatManage.StageParams = stage.Params
+		if err := e.Trigger(ctx, stage.Event, chatManage); err != nil {
+			return stage.Event, err
+		}
+	}
+	return "", nil
+}
+
+// stageParamKind is the kind of value of a stage parameter
+type stageParamKind int
+
+const (
+	stageParamString stageParamKind = iota
+	stageParamInt
+	stageParamScore
+)
+
+// validateStageParams checks the stage parameters against the accepted ones:
+// scores are numbers between 0 and 1, integers are positive
+func validateStageParams(params types.PipelineStageParams, accepted map[string]stageParamKind) error {
+	for key, value := range params {
+		kind, ok := accepted[key]
+		if !ok {
+			names := make([]string, 0, len(accepted))
+			for name := range accepted {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			return fmt.Errorf("unknown parameter %q, accepted parameters are %v", key, names)
+		}
+		switch kind {
+		case stageParamString:
+			if s, ok := value.(string); !ok || s == "" {
+				return fmt.Errorf("parameter %s must be a non-empty string", key)
+			}
+		case stageParamInt:
+			if n, ok := value.(float64); !ok || n < 1 || n != math.Trunc(n) {
+				return fmt.Errorf("parameter %s must be a positive integer", key)
+			}
+		case stageParamScore:
+			if n, ok := value.(float64); !ok || n < 0 || n > 1 {
+				return fmt.Errorf("parameter %s must be a number between 0 and 1", key)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package chatpipline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// newTestPipelineManager registers the stages of a minimal answering pipeline
+func newTestPipelineManager() *EventManager {
+	manager := &EventManager{}
+	manager.Register(&PluginFilterTopK{})
+	manager.Register(&PluginRerank{})
+	manager.Register(&testPlugin{
+		name:   "answer",
+		events: []types.EventType{types.CHUNK_SEARCH, types.INTO_CHAT_MESSAGE, types.CHAT_COMPLETION_STREAM},
+	})
+	return manager
+}
+
+func TestRunPassesStageParams(t *testing.T) {
+	ctx := context.Background()
+	manager := &EventManager{}
+	var seen []int
+	manager.RegisterFunc("custom", func(ctx context.Context,
+		chatManage *types.ChatManage, next func() *PluginError,
+	) *PluginError {
+		seen = append(seen, chatManage.StageParams.Int("top_k", 0))
+		return next()
+	})
+
+	chatManage := &types.ChatManage{}
+	event, err := manager.Run(ctx, []types.PipelineStage{
+		{Event: "custom", Params: types.PipelineStageParams{"top_k": float64(20)}},
+		{Event: "custom"},
+		{Event: "custom", Params: types.PipelineStageParams{"top_k": float64(5)}},
+	}, chatManage)
+	if err != nil {
+		t.Fatalf("Expected nil error, got %v from %s", err, event)
+	}
+	if len(seen) != 3 || seen[0] != 20 || seen[1] != 0 || seen[2] != 5 {
+		t.Errorf("Expected stage params [20 0 5], got %v", seen)
+	}
+	if chatManage.StageParams != nil {
+		t.Errorf("Expected stage params to be reset, got %v", chatManage.StageParams)
+	}
+}
+
+func TestRunStopsAtFirstError(t *testing.T) {
+	ctx := context.Background()
+	manager := &EventManager{}
+	triggered := 0
+	manager.RegisterFunc("first", func(ctx context.Context,
+		chatManage *types.ChatManage, next func() *PluginError,
+	) *PluginError {
+		triggered++
+		return ErrSearchNothing
+	})
+	manager.RegisterFunc("second", func(ctx context.Context,
+		chatManage *types.ChatManage, next func() *PluginError,
+	) *PluginError {
+		triggered++
+		return next()
+	})
+
+	event, err := manager.Run(ctx, types.NewPipelineStages([]types.EventType{"first", "second"}), &types.ChatManage{})
+	if err != ErrSearchNothing || event != "first" {
+		t.Errorf("Expected ErrSearchNothing from first, got %v from %s", err, event)
+	}
+	if triggered != 1 {
+		t.Errorf("Expected 1 triggered stage, got %d", triggered)
+	}
+}
+
+func TestFilterTopKStageParams(t *testing.T) {
+	ctx := context.Background()
+	manager := newTestPipelineManager()
+	chatManage := &types.ChatManage{
+		RerankTopK:   3,
+		SearchResult: []*types.SearchResult{{ID: "1"}, {ID: "2"}, {ID: "3"}, {ID: "4"}},
+	}
+	if _, err := manager.Run(ctx, []types.PipelineStage{
+		{Event: types.FILTER_TOP_K, Params: types.PipelineStageParams{"top_k": float64(2)}},
+	}, chatManage); err != nil {
+		t.Fatalf("Expected nil error, got %v", err)
+	}
+	if len(chatManage.SearchResult) != 2 {
+		t.Errorf("Expected 2 results, got %d", len(chatManage.SearchResult))
+	}
+}
+
+func TestValidatePipeline(t *testing.T) {
+	manager := newTestPipelineManager()
+	manager.RegisterFunc("custom", func(ctx context.Context,
+		chatManage *types.ChatManage, next func() *PluginError,
+	) *PluginError {
+		return next()
+	})
+
+	tests := []struct {
+		name    string
+		stages  []types.PipelineStage
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			stages: []types.PipelineStage{
+				{Event: types.CHUNK_SEARCH},
+				{Event: types.CHUNK_RERANK, Params: types.PipelineStageParams{"model_id": "m1", "top_k": float64(50)}},
+				{Event: types.CHUNK_RERANK, Params: types.PipelineStageParams{"model_id": "m2", "threshold": 0.5}},
+				{Event: types.FILTER_TOP_K, Params: types.PipelineStageParams{"top_k": float64(5)}},
+				{Event: "custom"},
+				{Event: types.INTO_CHAT_MESSAGE},
+				{Event: types.CHAT_COMPLETION_STREAM},
+			},
+		},
+		{
+			name:    "empty",
+			wantErr: true,
+		},
+		{
+			name: "unknown stage",
+			stages: []types.PipelineStage{
+				{Event: "unknown"},
+				{Event: types.INTO_CHAT_MESSAGE},
+				{Event: types.CHAT_COMPLETION_STREAM},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing completion",
+			stages: []types.PipelineStage{
+				{Event: types.CHUNK_SEARCH},
+				{Event: types.INTO_CHAT_MESSAGE},
+			},
+			wantErr: true,
+		},
+		{
+			name: "completion before chat message",
+			stages: []types.PipelineStage{
+				{Event: types.CHAT_COMPLETION_STREAM},
+				{Event: types.INTO_CHAT_MESSAGE},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown parameter",
+			stages: []types.PipelineStage{
+				{Event: types.CHUNK_RERANK, Params: types.PipelineStageParams{"topk": float64(5)}},
+				{Event: types.INTO_CHAT_MESSAGE},
+				{Event: types.CHAT_COMPLETION_STREAM},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid threshold",
+			stages: []types.PipelineStage{
+				{Event: types.CHUNK_RERANK, Params: types.PipelineStageParams{"threshold": 1.5}},
+				{Event: types.INTO_CHAT_MESSAGE},
+				{Event: types.CHAT_COMPLETION_STREAM},
+			},
+			wantErr: true,
+		},
+		{
+			name: "parameters of a stage without parameters",
+			stages: []types.PipelineStage{
+				{Event: "custom", Params: types.PipelineStageParams{"top_k": float64(5)}},
+				{Event: types.INTO_CHAT_MESSAGE},
+				{Event: types.CHAT_COMPLETION_STREAM},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := manager.ValidatePipeline(&types.PipelineConfig{Stages: tt.stages})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePipeline() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -36,14 +36,26 @@ func (p *PluginRerank) ActivationEvents() []types.EventType {
 func (p *PluginRerank) OnEvent(ctx context.Context,
 	eventType types.EventType, chatManage *types.ChatManage, next func() *PluginError,
 ) *PluginError {
+	if len(chatManage.StageParams) > 0 {
+		defer restoreRerankSettings(chatManage)()
+		chatManage.RerankModelID = chatManage.StageParams.String("model_id", chatManage.RerankModelID)
+		chatManage.RerankTopK = chatManage.StageParams.Int("top_k", chatManage.RerankTopK)
+		chatManage.RerankThreshold = chatManage.StageParams.Float("threshold", chatManage.RerankThreshold)
+	}
+	// A rerank stage following another one reranks the results of the previous stage
+	input := chatManage.SearchResult
+	if len(chatManage.RerankResult) > 0 {
+		input = chatManage.RerankResult
+	}
+
 	pipelineInfo(ctx, "Rerank", "input", map[string]interface{}{
 		"session_id":    chatManage.SessionID,
-		"candidate_cnt": len(chatManage.SearchResult),
+		"candidate_cnt": len(input),
 		"rerank_model":  chatManage.RerankModelID,
 		"rerank_thresh": chatManage.RerankThreshold,
 		"rewrite_query": chatManage.RewriteQuery,
 	})
-	if len(chatManage.SearchResult) == 0 {
+	if len(input) == 0 {
 		pipelineInfo(ctx, "Rerank", "skip", map[string]interface{}{
 			"reason": "empty_search_result",
 		})
@@ -71,7 +83,7 @@ func (p *PluginRerank) OnEvent(ctx context.Context,
 	var candidatesToRerank []*types.SearchResult
 	var directLoadResults []*types.SearchResult
 
-	for _, result := range chatManage.SearchResult {
+	for _, result := range input {
 		if result.MatchType == types.MatchTypeDirectLoad {
 			directLoadResults = append(directLoadResults, result)
 			pipelineInfo(ctx, "Rerank", "direct_load_skip", map[string]interface{}{
@@ -86,7 +98,7 @@ func (p *PluginRerank) OnEvent(ctx context.Context,
 	}
 
 	pipelineInfo(ctx, "Rerank", "build_passages", map[string]interface{}{
-		"total_cnt":     len(chatManage.SearchResult),
+		"total_cnt":     len(input),
 		"candidate_cnt": len(candidatesToRerank),
 		"direct_cnt":    len(directLoadResults),
 	})
@@ -121,7 +133,7 @@ func (p *PluginRerank) OnEvent(ctx context.Context,
 	})
 
 	// Log input scores before reranking for debugging
-	for i, sr := range chatManage.SearchResult {
+	for i, sr := range input {
 		pipelineInfo(ctx, "Rerank", "input_score", map[string]interface{}{
 			"index":      i,
 			"chunk_id":   sr.ID,
@@ -130,8 +142,8 @@ func (p *PluginRerank) OnEvent(ctx context.Context,
 		})
 	}
 
-	for i := range chatManage.SearchResult {
-		chatManage.SearchResult[i].Metadata = ensureMetadata(chatManage.SearchResult[i].Metadata)
+	for i := range input {
+		input[i].Metadata = ensureMetadata(input[i].Metadata)
 	}
 	reranked := make([]*types.SearchResult, 0, len(rerankResp)+len(directLoadResults))
 
@@ -198,6 +210,23 @@ func (p *PluginRerank) OnEvent(ctx context.Context,
 	return next()
 }
 
+// ValidateStageParams checks the parameters of a rerank stage
+func (p *PluginRerank) ValidateStageParams(eventType types.EventType, params types.PipelineStageParams) error {
+	return validateStageParams(params, map[string]stageParamKind{
+		"model_id":  stageParamString,
+		"top_k":     stageParamInt,
+		"threshold": stageParamScore,
+	})
+}
+
+// restoreRerankSettings returns a function restoring the rerank settings overridden by a stage
+func restoreRerankSettings(chatManage *types.ChatManage) func() {
+	modelID, topK, threshold := chatManage.RerankModelID, chatManage.RerankTopK, chatManage.RerankThreshold
+	return func() {
+		chatManage.RerankModelID, chatManage.RerankTopK, chatManage.RerankThreshold = modelID, topK, threshold
+	}
+}
+
 // rerank performs the actual reranking operation with given query and passages
 func (p *PluginRerank) rerank(ctx context.Context,
 	chatManage *types.ChatManage, rerankModel rerank.Reranker, query string, passages []string,
@@ -57,6 +57,18 @@ func (p *PluginSearch) ActivationEvents() []types.EventType {
 func (p *PluginSearch) OnEvent(ctx context.Context,
 	eventType types.EventType, chatManage *types.ChatManage, next func() *PluginError,
 ) *PluginError {
+	if len(chatManage.StageParams) > 0 {
+		embeddingTopK, vectorThreshold, keywordThreshold :=
+			chatManage.EmbeddingTopK, chatManage.VectorThreshold, chatManage.KeywordThreshold
+		defer func() {
+			chatManage.EmbeddingTopK, chatManage.VectorThreshold, chatManage.KeywordThreshold =
+				embeddingTopK, vectorThreshold, keywordThreshold
+		}()
+		chatManage.EmbeddingTopK = chatManage.StageParams.Int("top_k", embeddingTopK)
+		chatManage.VectorThreshold = chatManage.StageParams.Float("vector_threshold", vectorThreshold)
+		chatManage.KeywordThreshold = chatManage.StageParams.Float("keyword_threshold", keywordThreshold)
+	}
+
 	// Check if we have search targets or web search enabled
 	hasKBTargets := len(chatManage.SearchTargets) > 0 || len(chatManage.KnowledgeBaseIDs) > 0 || len(chatManage.KnowledgeIDs) > 0
 	if !hasKBTargets && !chatManage.WebSearchEnabled {
@@ -84,12 +96,15 @@ func (p *PluginSearch) OnEvent(ctx context.Context,
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	allResults := make([]*types.SearchResult, 0)
+	// Knowledge base each hybrid search result comes from
+	var resultKBIDs map[*types.SearchResult]string
 
 	wg.Add(2)
 	// Goroutine 1: Knowledge base search using SearchTargets
 	go func() {
 		defer wg.Done()
-		kbResults := p.searchByTargets(ctx, chatManage)
+		kbResults, kbIDs := p.searchByTargets(ctx, chatManage)
+		resultKBIDs = kbIDs
 		if len(kbResults) > 0 {
 			mu.Lock()
 			allResults = append(allResults, kbResults...)
@@ -166,6 +181,7 @@ func (p *PluginSearch) OnEvent(ctx context.Context,
 							MatchCount:           expTopK,
 							DisableVectorMatch:   true,
 							DisableKeywordsMatch: false,
+							Filter:               chatManage.RetrievalFilter,
 						}
 						// Apply knowledge ID filter if this is a partial KB search
 						if t.Type == types.SearchTargetTypeKnowledge {
@@ -187,6 +203,9 @@ func (p *PluginSearch) OnEvent(ctx context.Context,
 							})
 							muExp.Lock()
 							expResults = append(expResults, res...)
+							for _, r := range res {
+								resultKBIDs[r] = t.KnowledgeBaseID
+							}
 							muExp.Unlock()
 						}
 					}(q, target)
@@ -194,7 +213,6 @@ func (p *PluginSearch) OnEvent(ctx context.Context,
 			}
 			wgExp.Wait()
 			if len(expResults) > 0 {
-				// Scores already normalized in HybridSearch
 				pipelineInfo(ctx, "Search", "expansion_done", map[string]interface{}{
 					"added": len(expResults),
 				})
@@ -203,6 +221,16 @@ func (p *PluginSearch) OnEvent(ctx context.Context,
 		}
 	}
 
+	// The scale of the fused scores depends on the fusion mode of each knowledge base
+	// (RRF scores are in range [0, ~0.033], weighted and max scores in [0, 1]),
+	// so they are normalized per knowledge base before the results of several knowledge bases are merged.
+	// Directly loaded chunks and web search results keep their scores
+	searchutil.NormalizeScoresByGroup(chatManage.SearchResult,
+		func(r *types.SearchResult) string { return resultKBIDs[r] },
+		func(r *types.SearchResult) float64 { return r.Score },
+		func(r *types.SearchResult, score float64) { r.Score = score },
+	)
+
 	// Add relevant results from chat history
 	historyResult := p.getSearchResultFromHistory(chatManage)
 	if historyResult != nil {
@@ -246,6 +274,15 @@ func (p *PluginSearch) OnEvent(ctx context.Context,
 	return ErrSearchNothing
 }
 
+// ValidateStageParams checks the parameters of a search stage
+func (p *PluginSearch) ValidateStageParams(eventType types.EventType, params types.PipelineStageParams) error {
+	return validateStageParams(params, map[string]stageParamKind{
+		"top_k":             stageParamInt,
+		"vector_threshold":  stageParamScore,
+		"keyword_threshold": stageParamScore,
+	})
+}
+
 // getSearchResultFromHistory retrieves relevant knowledge references from chat history
 func (p *PluginSearch) getSearchResultFromHistory(chatManage *types.ChatManage) []*types.SearchResult {
 	if len(chatManage.History) == 0 {
@@ -306,14 +343,16 @@ func buildContentSignature(content string) string {
 	return searchutil.BuildContentSignature(content)
 }
 
-// searchByTargets performs KB searches using pre-computed SearchTargets
+// searchByTargets performs KB searches using pre-computed SearchTargets.
+// Returns the results and the knowledge base each hybrid search result comes from
 // This is the main search method that uses the unified search targets
 func (p *PluginSearch) searchByTargets(
 	ctx context.Context,
 	chatManage *types.ChatManage,
-) []*types.SearchResult {
+) ([]*types.SearchResult, map[*types.SearchResult]string) {
+	kbIDs := make(map[*types.SearchResult]string)
 	if len(chatManage.SearchTargets) == 0 {
-		return nil
+		return nil, kbIDs
 	}
 
 	var wg sync.WaitGroup
@@ -330,8 +369,9 @@ func (p *PluginSearch) searchByTargets(
 			// Default to all IDs in the target
 			searchKnowledgeIDs := t.KnowledgeIDs
 
-			// Try direct loading for specific knowledge targets
-			if t.Type == types.SearchTargetTypeKnowledge {
+			// Try direct loading for specific knowledge targets,
+			// files are searched when the request filters the documents
+			if t.Type == types.SearchTargetTypeKnowledge && chatManage.RetrievalFilter == nil {
 				directResults, skippedIDs := p.tryDirectChunkLoading(ctx, chatManage.TenantID, t.KnowledgeIDs)
 				
 				if len(directResults) > 0 {
@@ -365,6 +405,7 @@ func (p *PluginSearch) searchByTargets(
 				VectorThreshold:  chatManage.VectorThreshold,
 				KeywordThreshold: chatManage.KeywordThreshold,
 				MatchCount:       chatManage.EmbeddingTopK,
+				Filter:           chatManage.RetrievalFilter,
 			}
 			// Apply knowledge ID filter if this is a partial KB search
 			if t.Type == types.SearchTargetTypeKnowledge {
@@ -387,6 +428,9 @@ func (p *PluginSearch) searchByTargets(
 			})
 			mu.Lock()
 			results = append(results, res...)
+			for _, r := range res {
+				kbIDs[r] = t.KnowledgeBaseID
+			}
 			mu.Unlock()
 		}(target)
 	}
@@ -396,7 +440,7 @@ func (p *PluginSearch) searchByTargets(
 	pipelineInfo(ctx, "Search", "kb_result_summary", map[string]interface{}{
 		"total_hits": len(results),
 	})
-	return results
+	return results, kbIDs
 }
 
 // tryDirectChunkLoading attempts to load chunks for given knowledge IDs directly
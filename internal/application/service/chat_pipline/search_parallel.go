@@ -83,6 +83,11 @@ func (p *PluginSearchParallel) ActivationEvents() []types.EventType {
 	return []types.EventType{types.CHUNK_SEARCH_PARALLEL}
 }
 
+// ValidateStageParams checks the parameters of a parallel search stage, they apply to the chunk search
+func (p *PluginSearchParallel) ValidateStageParams(eventType types.EventType, params types.PipelineStageParams) error {
+	return p.searchPlugin.ValidateStageParams(types.CHUNK_SEARCH, params)
+}
+
 // OnEvent handles parallel search events - runs chunk search and entity search concurrently
 func (p *PluginSearchParallel) OnEvent(ctx context.Context,
 	eventType types.EventType, chatManage *types.ChatManage, next func() *PluginError,
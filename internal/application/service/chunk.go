@@ -21,6 +21,7 @@ type chunkService struct {
 	kbRepository    interfaces.KnowledgeBaseRepository
 	modelService    interfaces.ModelService
 	retrieveEngine  interfaces.RetrieveEngineRegistry
+	answerCache     interfaces.AnswerCacheService
 }
 
 // NewChunkService creates a new chunk service
@@ -35,12 +36,14 @@ func NewChunkService(
 	kbRepository interfaces.KnowledgeBaseRepository,
 	modelService interfaces.ModelService,
 	retrieveEngine interfaces.RetrieveEngineRegistry,
+	answerCache interfaces.AnswerCacheService,
 ) interfaces.ChunkService {
 	return &chunkService{
 		chunkRepository: chunkRepository,
 		kbRepository:    kbRepository,
 		modelService:    modelService,
 		retrieveEngine:  retrieveEngine,
+		answerCache:     answerCache,
 	}
 }
 
@@ -188,6 +191,7 @@ func (s *chunkService) UpdateChunk(ctx context.Context, chunk *types.Chunk) erro
 		})
 		return err
 	}
+	s.invalidateAnswerCache(ctx, chunk.KnowledgeBaseID)
 
 	logger.Info(ctx, "Chunk updated successfully")
 	return nil
@@ -433,7 +437,15 @@ func (s *chunkService) DeleteGeneratedQuestion(ctx context.Context, chunkID stri
 		})
 		return fmt.Errorf("failed to update chunk: %w", err)
 	}
+	s.invalidateAnswerCache(ctx, chunk.KnowledgeBaseID)
 
 	logger.Infof(ctx, "Successfully deleted generated question %s from chunk %s", questionID, chunkID)
 	return nil
 }
+
+// invalidateAnswerCache drops the cached answers of a knowledge base after one of its chunks changed
+func (s *chunkService) invalidateAnswerCache(ctx context.Context, kbID string) {
+	if err := s.answerCache.InvalidateKnowledgeBase(ctx, kbID); err != nil {
+		logger.Warnf(ctx, "Failed to invalidate answer cache of knowledge base %s: %v", kbID, err)
+	}
+}
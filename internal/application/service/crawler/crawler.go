@@ -0,0 +1,274 @@
+// Package crawler discovers the pages of a website within a crawl scope
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	secutils "github.com/Tencent/WeKnora/internal/utils"
+)
+
+const (
+	// UserAgent identifies the crawler to websites and robots.txt
+	UserAgent = "WeKnoraBot/1.0"
+	// maxBodySize bounds the size of a fetched page
+	maxBodySize = 10 << 20
+	// maxSitemaps bounds the number of sitemap files read, nested sitemap indexes included
+	maxSitemaps = 20
+)
+
+// Page is a page found by a crawl
+type Page struct {
+	// URL of the page without fragment
+	URL string
+	// Title of the HTML document
+	Title string
+	// Number of links followed from the start page, sitemap pages count as linked from it
+	Depth int
+	// Hash of the page body
+	ContentHash string
+}
+
+// Crawler fetches pages over HTTP
+type Crawler struct {
+	client *http.Client
+}
+
+// NewCrawler creates a new crawler, a nil client uses a client with a 30 second timeout
+func NewCrawler(client *http.Client) *Crawler {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Crawler{client: client}
+}
+
+// Fetch downloads a page and returns its final URL, media type and body
+func (c *Crawler) Fetch(ctx context.Context, pageURL string) (string, string, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return "", "", nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", nil, fmt.Errorf("fetch %s: unexpected status %d", pageURL, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return "", "", nil, err
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return resp.Request.URL.String(), mediaType, body, nil
+}
+
+// ContentHash returns the hash used to detect changed page content
+func ContentHash(body []byte) string {
+	hash := sha256.Sum256(body)
+	return hex.EncodeToString(hash[:])
+}
+
+// scope decides which URLs a crawl may visit
+type scope struct {
+	start  *url.URL
+	config types.URLCrawlConfig
+	robots map[string]*Robots
+}
+
+// Crawl visits the HTML pages reachable from the start URL breadth first within the crawl scope.
+// The start page is always the first page returned, the crawl stops after config.MaxPages pages.
+func (c *Crawler) Crawl(ctx context.Context, startURL string, config types.URLCrawlConfig) ([]*Page, error) {
+	config = config.WithDefaults()
+	start, err := normalizeURL(nil, startURL)
+	if err != nil {
+		return nil, err
+	}
+	s := &scope{start: start, config: config, robots: make(map[string]*Robots)}
+
+	type queued struct {
+		url   string
+		depth int
+	}
+	queue := []queued{{url: start.String()}}
+	seen := map[string]bool{start.String(): true}
+	enqueue := func(link *url.URL, depth int) {
+		key := link.String()
+		if seen[key] || depth > config.MaxDepth || !s.allowed(ctx, c, link) {
+			return
+		}
+		seen[key] = true
+		queue = append(queue, queued{url: key, depth: depth})
+	}
+
+	if config.UseSitemap {
+		for _, page := range c.sitemapPages(ctx, s) {
+			if link, err := normalizeURL(nil, page); err == nil {
+				enqueue(link, 1)
+			}
+		}
+	}
+
+	pages := make([]*Page, 0)
+	for len(queue) > 0 && len(pages) < config.MaxPages {
+		if err := ctx.Err(); err != nil {
+			return pages, err
+		}
+		current := queue[0]
+		queue = queue[1:]
+
+		finalURL, mediaType, body, err := c.Fetch(ctx, current.url)
+		if err != nil {
+			if current.depth == 0 {
+				return nil, err
+			}
+			logger.Warnf(ctx, "Crawler failed to fetch %s: %v", current.url, err)
+			continue
+		}
+		if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+			if current.depth == 0 {
+				return nil, fmt.Errorf("start page %s is not an HTML page: %s", current.url, mediaType)
+			}
+			continue
+		}
+		base, err := url.Parse(finalURL)
+		if err != nil {
+			continue
+		}
+		// A redirect may leave the scope, the start page is kept regardless
+		if current.depth > 0 && !s.allowed(ctx, c, base) {
+			continue
+		}
+
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+		if err != nil {
+			logger.Warnf(ctx, "Crawler failed to parse %s: %v", current.url, err)
+			continue
+		}
+		pages = append(pages, &Page{
+			URL:         current.url,
+			Title:       strings.TrimSpace(doc.Find("title").First().Text()),
+			Depth:       current.depth,
+			ContentHash: ContentHash(body),
+		})
+
+		if current.depth >= config.MaxDepth {
+			continue
+		}
+		doc.Find("a[href]").Each(func(_ int, a *goquery.Selection) {
+			if rel, _ := a.Attr("rel"); strings.Contains(strings.ToLower(rel), "nofollow") {
+				return
+			}
+			href, _ := a.Attr("href")
+			if link, err := normalizeURL(base, href); err == nil {
+				enqueue(link, current.depth+1)
+			}
+		})
+	}
+	logger.Infof(ctx, "Crawl of %s finished, pages: %d, unvisited: %d", start.String(), len(pages), len(queue))
+	return pages, nil
+}
+
+// allowed checks the crawl scope and robots.txt
+func (s *scope) allowed(ctx context.Context, c *Crawler, link *url.URL) bool {
+	if link.Scheme != "http" && link.Scheme != "https" {
+		return false
+	}
+	if !secutils.IsValidURL(link.String()) {
+		return false
+	}
+	if s.config.SameDomain && !strings.EqualFold(link.Hostname(), s.start.Hostname()) {
+		return false
+	}
+	if s.config.PathPrefix != "" && !strings.HasPrefix(link.Path, s.config.PathPrefix) {
+		return false
+	}
+	if !s.config.RespectRobotsTxt {
+		return true
+	}
+	return s.robotsOf(ctx, c, link).Allowed(link.RequestURI())
+}
+
+// robotsOf returns the robots.txt rules of the host of the link, a missing robots.txt allows everything
+func (s *scope) robotsOf(ctx context.Context, c *Crawler, link *url.URL) *Robots {
+	origin := link.Scheme + "://" + link.Host
+	if robots, ok := s.robots[origin]; ok {
+		return robots
+	}
+	robots := &Robots{}
+	if _, _, body, err := c.Fetch(ctx, origin+"/robots.txt"); err == nil {
+		robots = ParseRobots(string(body), UserAgent)
+	}
+	s.robots[origin] = robots
+	return robots
+}
+
+// sitemapPages reads the sitemaps declared by robots.txt, or /sitemap.xml of the start host
+func (c *Crawler) sitemapPages(ctx context.Context, s *scope) []string {
+	origin := s.start.Scheme + "://" + s.start.Host
+	sitemaps := []string{origin + "/sitemap.xml"}
+	if s.config.RespectRobotsTxt {
+		if declared := s.robotsOf(ctx, c, s.start).Sitemaps; len(declared) > 0 {
+			sitemaps = declared
+		}
+	}
+
+	var pages []string
+	visited := make(map[string]bool)
+	for len(sitemaps) > 0 && len(visited) < maxSitemaps {
+		sitemap := sitemaps[0]
+		sitemaps = sitemaps[1:]
+		if visited[sitemap] || !secutils.IsValidURL(sitemap) {
+			continue
+		}
+		visited[sitemap] = true
+		_, _, body, err := c.Fetch(ctx, sitemap)
+		if err != nil {
+			continue
+		}
+		found, nested, err := ParseSitemap(body)
+		if err != nil {
+			logger.Warnf(ctx, "Crawler failed to parse sitemap %s: %v", sitemap, err)
+			continue
+		}
+		pages = append(pages, found...)
+		sitemaps = append(sitemaps, nested...)
+	}
+	return pages
+}
+
+// normalizeURL resolves a link against its page and drops the fragment
+func normalizeURL(base *url.URL, link string) (*url.URL, error) {
+	parsed, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return nil, err
+	}
+	if base != nil {
+		parsed = base.ResolveReference(parsed)
+	}
+	if !parsed.IsAbs() || parsed.Host == "" {
+		return nil, fmt.Errorf("not an absolute URL: %s", link)
+	}
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	if parsed.Path == "" {
+		parsed.Path = "/"
+	}
+	return parsed, nil
+}
@@ -0,0 +1,200 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+func TestParseRobots(t *testing.T) {
+	content := `
+# comment
+User-agent: other
+Disallow: /
+
+User-agent: *
+Disallow: /private/
+Allow: /private/public
+Disallow: /*.pdf$
+
+Sitemap: https://example.com/sitemap_index.xml
+`
+	robots := ParseRobots(content, UserAgent)
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/", true},
+		{"/docs/page", true},
+		{"/private/secret", false},
+		{"/private/public/page", true},
+		{"/files/manual.pdf", false},
+		{"/files/manual.pdf?download=1", true},
+	}
+	for _, tt := range tests {
+		if got := robots.Allowed(tt.path); got != tt.want {
+			t.Errorf("Allowed(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	if !slices.Equal(robots.Sitemaps, []string{"https://example.com/sitemap_index.xml"}) {
+		t.Errorf("Sitemaps = %v", robots.Sitemaps)
+	}
+}
+
+func TestParseRobots_SpecificAgent(t *testing.T) {
+	content := "User-agent: *\nDisallow: /\n\nUser-agent: googlebot\nUser-agent: weknorabot\nDisallow: /admin\n"
+	robots := ParseRobots(content, UserAgent)
+	if !robots.Allowed("/docs") || robots.Allowed("/admin/users") {
+		t.Errorf("rules of the weknorabot group not applied")
+	}
+}
+
+func TestParseSitemap(t *testing.T) {
+	index := `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://example.com/sitemap-docs.xml</loc></sitemap>
+</sitemapindex>`
+	pages, sitemaps, err := ParseSitemap([]byte(index))
+	if err != nil {
+		t.Fatalf("ParseSitemap() error = %v", err)
+	}
+	if len(pages) != 0 || !slices.Equal(sitemaps, []string{"https://example.com/sitemap-docs.xml"}) {
+		t.Errorf("ParseSitemap() = %v, %v", pages, sitemaps)
+	}
+
+	urlset := `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc> https://example.com/a </loc></url>
+  <url><loc>https://example.com/b</loc></url>
+</urlset>`
+	pages, _, err = ParseSitemap([]byte(urlset))
+	if err != nil {
+		t.Fatalf("ParseSitemap() error = %v", err)
+	}
+	if !slices.Equal(pages, []string{"https://example.com/a", "https://example.com/b"}) {
+		t.Errorf("pages = %v", pages)
+	}
+}
+
+// newTestSite serves HTML pages linking to each other, a robots.txt and a sitemap
+func newTestSite(t *testing.T) *httptest.Server {
+	t.Helper()
+	pages := map[string]string{
+		"/docs/":          `<a href="/docs/a">A</a> <a href="b#section">B</a> <a href="/blog/post">Blog</a>`,
+		"/docs/a":         `<a href="/docs/a/deep">Deep</a> <a href="https://external.example/">External</a>`,
+		"/docs/b":         `<a href="/docs/private/x">Private</a> <a rel="nofollow" href="/docs/nofollow">No</a>`,
+		"/docs/a/deep":    `<a href="/docs/a/deeper">Deeper</a>`,
+		"/docs/a/deeper":  `deeper`,
+		"/docs/private/x": `private`,
+		"/docs/nofollow":  `nofollow`,
+		"/docs/sitemap":   `only in sitemap`,
+		"/blog/post":      `blog`,
+	}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintf(w, "User-agent: *\nDisallow: /docs/private/\nSitemap: %s/sitemap.xml\n", server.URL)
+			return
+		case "/sitemap.xml":
+			w.Header().Set("Content-Type", "application/xml")
+			fmt.Fprintf(w, `<urlset><url><loc>%s/docs/sitemap</loc></url></urlset>`, server.URL)
+			return
+		}
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<html><head><title>%s</title></head><body>%s</body></html>", r.URL.Path, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func crawledPaths(t *testing.T, server *httptest.Server, config types.URLCrawlConfig) []string {
+	t.Helper()
+	pages, err := NewCrawler(server.Client()).Crawl(context.Background(), server.URL+"/docs/", config)
+	if err != nil {
+		t.Fatalf("Crawl() error = %v", err)
+	}
+	paths := make([]string, 0, len(pages))
+	for _, page := range pages {
+		paths = append(paths, strings.TrimPrefix(page.URL, server.URL))
+		if page.Title != strings.TrimPrefix(page.URL, server.URL) || page.ContentHash == "" {
+			t.Errorf("page %s has title %q, hash %q", page.URL, page.Title, page.ContentHash)
+		}
+	}
+	return paths
+}
+
+func TestCrawl_Scope(t *testing.T) {
+	server := newTestSite(t)
+	tests := []struct {
+		name   string
+		config types.URLCrawlConfig
+		want   []string
+	}{
+		{
+			name: "path prefix, robots and sitemap",
+			config: types.URLCrawlConfig{
+				SameDomain: true, PathPrefix: "/docs/", MaxDepth: 2, RespectRobotsTxt: true, UseSitemap: true,
+			},
+			want: []string{"/docs/", "/docs/sitemap", "/docs/a", "/docs/b", "/docs/a/deep"},
+		},
+		{
+			name:   "depth one without sitemap",
+			config: types.URLCrawlConfig{SameDomain: true, MaxDepth: 1, RespectRobotsTxt: true},
+			want:   []string{"/docs/", "/docs/a", "/docs/b", "/blog/post"},
+		},
+		{
+			name:   "robots ignored",
+			config: types.URLCrawlConfig{SameDomain: true, PathPrefix: "/docs/", MaxDepth: 2},
+			want:   []string{"/docs/", "/docs/a", "/docs/b", "/docs/a/deep", "/docs/private/x"},
+		},
+		{
+			name:   "page limit",
+			config: types.URLCrawlConfig{SameDomain: true, MaxDepth: 5, MaxPages: 2},
+			want:   []string{"/docs/", "/docs/a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := crawledPaths(t, server, tt.config); !slices.Equal(got, tt.want) {
+				t.Errorf("Crawl() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrawl_StartPageMustBeHTML(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte("%PDF"))
+	}))
+	defer server.Close()
+
+	if _, err := NewCrawler(server.Client()).Crawl(context.Background(), server.URL, types.URLCrawlConfig{}); err == nil {
+		t.Errorf("Crawl() of a PDF start page succeeded")
+	}
+}
+
+func TestURLCrawlConfig_UnmarshalDefaults(t *testing.T) {
+	var config types.URLCrawlConfig
+	if err := config.UnmarshalJSON([]byte(`{"max_pages": 5, "use_sitemap": false}`)); err != nil {
+		t.Fatalf("UnmarshalJSON() error = %v", err)
+	}
+	config = config.WithDefaults()
+	want := types.URLCrawlConfig{
+		SameDomain: true, MaxDepth: types.DefaultURLCrawlMaxDepth, MaxPages: 5, RespectRobotsTxt: true,
+	}
+	if config != want {
+		t.Errorf("config = %+v, want %+v", config, want)
+	}
+}
@@ -0,0 +1,139 @@
+package crawler
+
+import (
+	"bufio"
+	"strings"
+)
+
+// robotsRule is an Allow or Disallow line of robots.txt
+type robotsRule struct {
+	pattern string
+	allow   bool
+}
+
+// robotsGroup holds the rules of the user agents sharing a group
+type robotsGroup struct {
+	agents []string
+	rules  []robotsRule
+}
+
+// Robots holds the rules of robots.txt that apply to the crawler
+type Robots struct {
+	rules []robotsRule
+	// Sitemaps declared by robots.txt
+	Sitemaps []string
+}
+
+// ParseRobots parses robots.txt and keeps the rules of the group matching the user agent,
+// falling back to the rules of the "*" group.
+func ParseRobots(content string, userAgent string) *Robots {
+	robots := &Robots{}
+	var groups []*robotsGroup
+	var current *robotsGroup
+	// Consecutive user-agent lines share the rules that follow them
+	lastWasAgent := false
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if current == nil || !lastWasAgent {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			lastWasAgent = true
+		case "allow", "disallow":
+			lastWasAgent = false
+			if current == nil || value == "" {
+				// An empty Disallow allows everything
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{pattern: value, allow: key == "allow"})
+		case "sitemap":
+			if value != "" {
+				robots.Sitemaps = append(robots.Sitemaps, value)
+			}
+		default:
+			lastWasAgent = false
+		}
+	}
+
+	agent := strings.ToLower(userAgent)
+	var fallback *robotsGroup
+	for _, group := range groups {
+		for _, name := range group.agents {
+			if name == "*" {
+				if fallback == nil {
+					fallback = group
+				}
+				continue
+			}
+			if name != "" && strings.Contains(agent, name) {
+				robots.rules = group.rules
+				return robots
+			}
+		}
+	}
+	if fallback != nil {
+		robots.rules = fallback.rules
+	}
+	return robots
+}
+
+// Allowed reports whether the path (with query) may be crawled.
+// The longest matching rule wins, Allow wins a tie.
+func (r *Robots) Allowed(path string) bool {
+	if r == nil {
+		return true
+	}
+	if path == "" {
+		path = "/"
+	}
+	allowed, matched := true, -1
+	for _, rule := range r.rules {
+		if !matchRobotsPattern(rule.pattern, path) {
+			continue
+		}
+		length := len(rule.pattern)
+		if length > matched || (length == matched && rule.allow) {
+			allowed, matched = rule.allow, length
+		}
+	}
+	return allowed
+}
+
+// matchRobotsPattern matches a path against a robots.txt pattern supporting "*" and a trailing "$"
+func matchRobotsPattern(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = strings.TrimSuffix(pattern, "$")
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, part := range parts[1:] {
+		if i == len(parts)-2 && anchored {
+			return strings.HasSuffix(rest, part)
+		}
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+	return !anchored || rest == ""
+}
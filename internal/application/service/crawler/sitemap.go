@@ -0,0 +1,32 @@
+package crawler
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// sitemapDocument covers both a urlset and a sitemap index
+type sitemapDocument struct {
+	XMLName  xml.Name `xml:""`
+	URLs     []string `xml:"url>loc"`
+	Sitemaps []string `xml:"sitemap>loc"`
+}
+
+// ParseSitemap returns the page URLs and the nested sitemap URLs listed by a sitemap
+func ParseSitemap(content []byte) (pages []string, sitemaps []string, err error) {
+	var doc sitemapDocument
+	if err := xml.Unmarshal(content, &doc); err != nil {
+		return nil, nil, err
+	}
+	for _, loc := range doc.URLs {
+		if loc = strings.TrimSpace(loc); loc != "" {
+			pages = append(pages, loc)
+		}
+	}
+	for _, loc := range doc.Sitemaps {
+		if loc = strings.TrimSpace(loc); loc != "" {
+			sitemaps = append(sitemaps, loc)
+		}
+	}
+	return pages, sitemaps, nil
+}
@@ -1,22 +1,33 @@
 package service
 
 import (
+	"bufio"
 	"context"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"slices"
+	"strings"
 
+	werrors "github.com/Tencent/WeKnora/internal/errors"
 	"github.com/Tencent/WeKnora/internal/logger"
 	"github.com/Tencent/WeKnora/internal/types"
 	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"github.com/google/uuid"
 	"github.com/parquet-go/parquet-go"
+	"gorm.io/gorm"
 )
 
 // DatasetService provides operations for working with datasets
-type DatasetService struct{}
+type DatasetService struct {
+	repo interfaces.EvaluationRepository
+}
 
 // NewDatasetService creates a new DatasetService instance
-func NewDatasetService() interfaces.DatasetService {
-	return &DatasetService{}
+func NewDatasetService(repo interfaces.EvaluationRepository) interfaces.DatasetService {
+	return &DatasetService{repo: repo}
 }
 
 // TextInfo represents text data with ID in parquet format
@@ -38,10 +49,24 @@ type QaInfo struct {
 }
 
 // GetDatasetByID retrieves QA pairs from dataset by ID
+// The built-in sample dataset is used for the default ID, otherwise an uploaded dataset of the tenant
 func (d *DatasetService) GetDatasetByID(ctx context.Context, datasetID string) ([]*types.QAPair, error) {
 	logger.Info(ctx, "Start getting dataset by ID")
 	logger.Infof(ctx, "Getting dataset with ID: %s", datasetID)
 
+	if datasetID != "" && datasetID != types.DefaultDatasetID {
+		tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+		uploaded, err := d.repo.GetDataset(ctx, tenantID, datasetID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, werrors.NewNotFoundError("Dataset not found")
+			}
+			return nil, err
+		}
+		logger.Infof(ctx, "Retrieved %d QA pairs from uploaded dataset", len(uploaded.QAPairs))
+		return uploaded.QAPairs, nil
+	}
+
 	dataset := DefaultDataset()
 	dataset.PrintStats(ctx)
 	qaPairs := dataset.Iterate()
@@ -50,6 +75,204 @@ func (d *DatasetService) GetDatasetByID(ctx context.Context, datasetID string) (
 	return qaPairs, nil
 }
 
+// CreateDataset parses an uploaded JSONL or CSV file into a new dataset
+func (d *DatasetService) CreateDataset(ctx context.Context,
+	name string, description string, format string, reader io.Reader,
+) (*types.EvaluationDataset, error) {
+	var qaPairs []*types.QAPair
+	var err error
+	switch format {
+	case types.DatasetFormatJSONL:
+		qaPairs, err = parseJSONLDataset(reader)
+	case types.DatasetFormatCSV:
+		qaPairs, err = parseCSVDataset(reader)
+	default:
+		return nil, werrors.NewBadRequestError(fmt.Sprintf("Unsupported dataset format: %s", format))
+	}
+	if err != nil {
+		return nil, werrors.NewBadRequestError("Invalid dataset file").WithDetails(err.Error())
+	}
+	if len(qaPairs) == 0 {
+		return nil, werrors.NewBadRequestError("Dataset contains no questions")
+	}
+	passageCount := assignPassageIDs(qaPairs)
+	if passageCount == 0 {
+		return nil, werrors.NewBadRequestError("Dataset contains no relevant passages")
+	}
+
+	dataset := &types.EvaluationDataset{
+		ID:           uuid.New().String(),
+		TenantID:     ctx.Value(types.TenantIDContextKey).(uint64),
+		Name:         name,
+		Description:  description,
+		Format:       format,
+		QACount:      len(qaPairs),
+		PassageCount: passageCount,
+		QAPairs:      qaPairs,
+	}
+	if err := d.repo.CreateDataset(ctx, dataset); err != nil {
+		logger.Errorf(ctx, "Failed to create dataset: %v", err)
+		return nil, err
+	}
+	logger.Infof(ctx, "Dataset created, ID: %s, questions: %d, passages: %d",
+		dataset.ID, dataset.QACount, dataset.PassageCount)
+	return dataset, nil
+}
+
+// ListDatasets lists the uploaded datasets of the current tenant
+func (d *DatasetService) ListDatasets(ctx context.Context) ([]*types.EvaluationDataset, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	return d.repo.ListDatasets(ctx, tenantID)
+}
+
+// DeleteDataset deletes an uploaded dataset
+func (d *DatasetService) DeleteDataset(ctx context.Context, datasetID string) error {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	return d.repo.DeleteDataset(ctx, tenantID, datasetID)
+}
+
+// datasetRecord is one question of an uploaded dataset
+type datasetRecord struct {
+	Question         string   `json:"question"`
+	Answer           string   `json:"answer"`
+	Passages         []string `json:"passages"`
+	RelevantPassages []string `json:"relevant_passages"`
+}
+
+// parseJSONLDataset parses one JSON object per line:
+// {"question": "...", "answer": "...", "passages": ["...", "..."]}
+func parseJSONLDataset(reader io.Reader) ([]*types.QAPair, error) {
+	var qaPairs []*types.QAPair
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var record datasetRecord
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		qaPair, err := newQAPair(len(qaPairs), record.Question, record.Answer,
+			append(record.Passages, record.RelevantPassages...))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		qaPairs = append(qaPairs, qaPair)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return qaPairs, nil
+}
+
+// parseCSVDataset parses a CSV file with a header row containing question and answer columns.
+// Every column whose name starts with "passage" or "relevant_passage" holds a relevant passage,
+// a cell may also hold a JSON array of passages.
+func parseCSVDataset(reader io.Reader) ([]*types.QAPair, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	questionCol, answerCol := -1, -1
+	var passageCols []int
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		switch {
+		case name == "question":
+			questionCol = i
+		case name == "answer":
+			answerCol = i
+		case strings.HasPrefix(name, "passage") || strings.HasPrefix(name, "relevant_passage"):
+			passageCols = append(passageCols, i)
+		}
+	}
+	if questionCol < 0 {
+		return nil, errors.New("missing question column")
+	}
+
+	var qaPairs []*types.QAPair
+	for line := 2; ; line++ {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		cell := func(i int) string {
+			if i < 0 || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+
+		var passages []string
+		for _, col := range passageCols {
+			value := cell(col)
+			var list []string
+			if strings.HasPrefix(value, "[") && json.Unmarshal([]byte(value), &list) == nil {
+				passages = append(passages, list...)
+				continue
+			}
+			passages = append(passages, value)
+		}
+		if cell(questionCol) == "" && cell(answerCol) == "" && len(strings.Join(passages, "")) == 0 {
+			continue
+		}
+		qaPair, err := newQAPair(len(qaPairs), cell(questionCol), cell(answerCol), passages)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		qaPairs = append(qaPairs, qaPair)
+	}
+	return qaPairs, nil
+}
+
+// newQAPair builds a QA pair, empty passages are dropped
+func newQAPair(qid int, question string, answer string, passages []string) (*types.QAPair, error) {
+	question = strings.TrimSpace(question)
+	if question == "" {
+		return nil, errors.New("question cannot be empty")
+	}
+	qaPair := &types.QAPair{
+		QID:      qid,
+		Question: question,
+		AID:      qid,
+		Answer:   strings.TrimSpace(answer),
+	}
+	for _, passage := range passages {
+		if passage = strings.TrimSpace(passage); passage != "" {
+			qaPair.Passages = append(qaPair.Passages, passage)
+		}
+	}
+	return qaPair, nil
+}
+
+// assignPassageIDs gives identical passages the same passage ID across the dataset
+// and returns the number of distinct passages
+func assignPassageIDs(qaPairs []*types.QAPair) int {
+	pids := make(map[string]int)
+	for _, qaPair := range qaPairs {
+		qaPair.PIDs = make([]int, len(qaPair.Passages))
+		for i, passage := range qaPair.Passages {
+			pid, ok := pids[passage]
+			if !ok {
+				pid = len(pids)
+				pids[passage] = pid
+			}
+			qaPair.PIDs[i] = pid
+		}
+	}
+	return len(pids)
+}
+
 // DefaultDataset loads and initializes the default dataset from parquet files
 func DefaultDataset() dataset {
 	datasetDir := "./dataset/samples"
@@ -108,7 +331,8 @@ type dataset struct {
 	qas     map[int64]int64   // qid -> aid
 }
 
-// Iterate generates QA pairs from the dataset
+// Iterate generates QA pairs from the dataset ordered by question ID,
+// so question indexes stay stable across resumed and compared evaluations
 func (d *dataset) Iterate() []*types.QAPair {
 	var pairs []*types.QAPair
 
@@ -141,6 +365,9 @@ func (d *dataset) Iterate() []*types.QAPair {
 		})
 	}
 
+	slices.SortFunc(pairs, func(a, b *types.QAPair) int {
+		return a.QID - b.QID
+	})
 	return pairs
 }
 
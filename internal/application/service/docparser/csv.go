@@ -0,0 +1,84 @@
+package docparser
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Tencent/WeKnora/docreader/proto"
+)
+
+// CSVParser parses CSV and TSV files into one chunk per row, formatted as
+// "column1: value1,column2: value2\n" like the docreader CSV parser
+type CSVParser struct{}
+
+// FileTypes implements DocumentParser
+func (p *CSVParser) FileTypes() []string {
+	return []string{"csv", "tsv"}
+}
+
+// Parse implements DocumentParser
+func (p *CSVParser) Parse(ctx context.Context, content []byte, config *proto.ReadConfig) ([]*proto.Chunk, error) {
+	text, err := decodeText(content)
+	if err != nil {
+		return nil, err
+	}
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	if isTSV(text) {
+		reader.Comma = '\t'
+	}
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read csv header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	chunks := make([]*proto.Chunk, 0)
+	start := 0
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// Skip malformed lines like docreader does
+			continue
+		}
+		// Rows with a different number of fields are skipped as malformed as well
+		if len(record) != len(header) {
+			continue
+		}
+		fields := make([]string, len(header))
+		for i, column := range header {
+			fields[i] = column + ": " + strings.TrimSpace(record[i])
+		}
+		row := strings.Join(fields, ",") + "\n"
+		end := start + utf8.RuneCountInString(row)
+		chunks = append(chunks, &proto.Chunk{
+			Content: row,
+			Seq:     int32(len(chunks)),
+			Start:   int32(start),
+			End:     int32(end),
+		})
+		start = end
+	}
+	return chunks, nil
+}
+
+// isTSV guesses the delimiter from the first line
+func isTSV(text string) bool {
+	firstLine, _, _ := strings.Cut(text, "\n")
+	return strings.Count(firstLine, "\t") > strings.Count(firstLine, ",")
+}
@@ -0,0 +1,116 @@
+package docparser
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/Tencent/WeKnora/docreader/proto"
+)
+
+// HTMLParser parses HTML files into Markdown-like text. Images with absolute http(s) URLs
+// are kept as Markdown images and attached to their chunks.
+type HTMLParser struct{}
+
+// FileTypes implements DocumentParser
+func (p *HTMLParser) FileTypes() []string {
+	return []string{"html", "htm"}
+}
+
+// Parse implements DocumentParser
+func (p *HTMLParser) Parse(ctx context.Context, content []byte, config *proto.ReadConfig) ([]*proto.Chunk, error) {
+	text, err := decodeText(content)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(text))
+	if err != nil {
+		return nil, err
+	}
+	doc.Find("script, style, noscript, template, head, iframe, svg").Remove()
+
+	var builder strings.Builder
+	writeHTML(&builder, doc.Find("body").Contents())
+	chunks := NewTextSplitter(config).Split(cleanBlankLines(builder.String()))
+	attachMarkdownImages(chunks)
+	return chunks, nil
+}
+
+var (
+	spacePattern     = regexp.MustCompile(`[ \t\r\n\f]+`)
+	blankLinePattern = regexp.MustCompile(`\n[ \t]*(?:\n[ \t]*)+`)
+)
+
+// cleanBlankLines trims the lines and collapses runs of blank lines into one
+func cleanBlankLines(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.TrimSpace(blankLinePattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")) + "\n"
+}
+
+// writeHTML writes the Markdown-like text of the nodes
+func writeHTML(builder *strings.Builder, nodes *goquery.Selection) {
+	nodes.Each(func(_ int, node *goquery.Selection) {
+		name := goquery.NodeName(node)
+		switch name {
+		case "#text":
+			builder.WriteString(spacePattern.ReplaceAllString(node.Text(), " "))
+		case "h1", "h2", "h3", "h4", "h5", "h6":
+			builder.WriteString("\n\n" + strings.Repeat("#", int(name[1]-'0')) + " ")
+			builder.WriteString(strings.TrimSpace(spacePattern.ReplaceAllString(node.Text(), " ")))
+			builder.WriteString("\n\n")
+		case "br":
+			builder.WriteString("\n")
+		case "li":
+			builder.WriteString("\n- ")
+			writeHTML(builder, node.Contents())
+			builder.WriteString("\n")
+		case "pre":
+			builder.WriteString("\n\n```\n" + strings.Trim(node.Text(), "\n") + "\n```\n\n")
+		case "img":
+			src, _ := node.Attr("src")
+			if isAbsoluteHTTPURL(src) {
+				alt, _ := node.Attr("alt")
+				builder.WriteString("![" + strings.TrimSpace(alt) + "](" + src + ")")
+			}
+		case "a":
+			href, _ := node.Attr("href")
+			text := strings.TrimSpace(spacePattern.ReplaceAllString(node.Text(), " "))
+			if isAbsoluteHTTPURL(href) && text != "" && node.Find("img").Length() == 0 {
+				builder.WriteString("[" + text + "](" + href + ")")
+			} else {
+				writeHTML(builder, node.Contents())
+			}
+		case "table":
+			writeTable(builder, node)
+		case "p", "div", "section", "article", "header", "footer", "main", "nav", "aside",
+			"ul", "ol", "blockquote", "figure", "figcaption", "dl", "dt", "dd", "form", "hr":
+			builder.WriteString("\n\n")
+			writeHTML(builder, node.Contents())
+			builder.WriteString("\n\n")
+		default:
+			writeHTML(builder, node.Contents())
+		}
+	})
+}
+
+// writeTable writes a table as a Markdown table, the first row is the header
+func writeTable(builder *strings.Builder, table *goquery.Selection) {
+	builder.WriteString("\n\n")
+	table.Find("tr").Each(func(i int, row *goquery.Selection) {
+		cells := row.Find("th, td")
+		builder.WriteString("|")
+		cells.Each(func(_ int, cell *goquery.Selection) {
+			text := strings.TrimSpace(spacePattern.ReplaceAllString(cell.Text(), " "))
+			builder.WriteString(" " + strings.ReplaceAll(text, "|", "\\|") + " |")
+		})
+		builder.WriteString("\n")
+		if i == 0 {
+			builder.WriteString("|" + strings.Repeat(" --- |", cells.Length()) + "\n")
+		}
+	})
+	builder.WriteString("\n\n")
+}
@@ -0,0 +1,69 @@
+package docparser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Tencent/WeKnora/docreader/proto"
+)
+
+// JSONParser parses JSON and JSON Lines files. Every record, i.e. an element of a top-level array
+// or a line of a JSON Lines file, is written on a line of its own in compact form, so that records
+// are only split across chunks when they are longer than a chunk.
+type JSONParser struct{}
+
+// FileTypes implements DocumentParser
+func (p *JSONParser) FileTypes() []string {
+	return []string{"json", "jsonl"}
+}
+
+// Parse implements DocumentParser
+func (p *JSONParser) Parse(ctx context.Context, content []byte, config *proto.ReadConfig) ([]*proto.Chunk, error) {
+	text, err := decodeText(content)
+	if err != nil {
+		return nil, err
+	}
+	records, err := jsonRecords(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var builder strings.Builder
+	for _, record := range records {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, record); err != nil {
+			return nil, fmt.Errorf("compact json record: %w", err)
+		}
+		builder.Write(compact.Bytes())
+		builder.WriteByte('\n')
+	}
+	return NewTextSplitter(config).Split(builder.String()), nil
+}
+
+// jsonRecords returns the records of a JSON document or a stream of JSON values such as JSON Lines
+func jsonRecords(text string) ([]json.RawMessage, error) {
+	decoder := json.NewDecoder(strings.NewReader(text))
+	var values []json.RawMessage
+	for {
+		var value json.RawMessage
+		if err := decoder.Decode(&value); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("decode json: %w", err)
+		}
+		values = append(values, value)
+	}
+	// A single top-level array holds the records
+	if len(values) == 1 {
+		var elements []json.RawMessage
+		if err := json.Unmarshal(values[0], &elements); err == nil {
+			return elements, nil
+		}
+	}
+	return values, nil
+}
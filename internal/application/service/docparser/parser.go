@@ -0,0 +1,88 @@
+// Package docparser parses documents into chunks in Go, before falling back to docreader
+package docparser
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/Tencent/WeKnora/docreader/proto"
+)
+
+// ErrNotHandled is returned by a parser that leaves the document to docreader,
+// e.g. a Markdown file with images when multimodal processing is enabled
+var ErrNotHandled = errors.New("document not handled by parser")
+
+// DocumentParser parses the content of a file into chunks
+type DocumentParser interface {
+	// FileTypes returns the file extensions handled by the parser, without the dot
+	FileTypes() []string
+	// Parse splits the file content into chunks according to the read config.
+	// Chunk positions are character offsets into the parsed text.
+	Parse(ctx context.Context, content []byte, config *proto.ReadConfig) ([]*proto.Chunk, error)
+}
+
+// Registry maps file types to document parsers
+type Registry struct {
+	mu      sync.RWMutex
+	parsers map[string]DocumentParser
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{parsers: make(map[string]DocumentParser)}
+}
+
+// Register registers a parser for all its file types, replacing the parsers registered before
+func (r *Registry) Register(parser DocumentParser) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, fileType := range parser.FileTypes() {
+		r.parsers[normalizeFileType(fileType)] = parser
+	}
+}
+
+// Lookup returns the parser of a file type
+func (r *Registry) Lookup(fileType string) (DocumentParser, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	parser, ok := r.parsers[normalizeFileType(fileType)]
+	return parser, ok
+}
+
+// Supports reports whether a parser is registered for a file type
+func (r *Registry) Supports(fileType string) bool {
+	_, ok := r.Lookup(fileType)
+	return ok
+}
+
+func normalizeFileType(fileType string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(fileType)), ".")
+}
+
+// defaultRegistry holds the built-in parsers and the parsers registered by the application
+var defaultRegistry = NewRegistry()
+
+func init() {
+	defaultRegistry.Register(&TextParser{})
+	defaultRegistry.Register(&MarkdownParser{})
+	defaultRegistry.Register(&CSVParser{})
+	defaultRegistry.Register(&JSONParser{})
+	defaultRegistry.Register(&HTMLParser{})
+}
+
+// Register registers a parser in the default registry, replacing the built-in parser of its file types
+func Register(parser DocumentParser) {
+	defaultRegistry.Register(parser)
+}
+
+// Lookup returns the parser of a file type from the default registry
+func Lookup(fileType string) (DocumentParser, bool) {
+	return defaultRegistry.Lookup(fileType)
+}
+
+// Supports reports whether the default registry has a parser for a file type
+func Supports(fileType string) bool {
+	return defaultRegistry.Supports(fileType)
+}
@@ -0,0 +1,163 @@
+package docparser
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Tencent/WeKnora/docreader/proto"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+type fakeParser struct{}
+
+func (p *fakeParser) FileTypes() []string { return []string{".TXT", "fake"} }
+
+func (p *fakeParser) Parse(ctx context.Context, content []byte, config *proto.ReadConfig) ([]*proto.Chunk, error) {
+	return []*proto.Chunk{{Content: "fake"}}, nil
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(&TextParser{})
+	if !registry.Supports("TXT") || registry.Supports("fake") {
+		t.Fatalf("unexpected registry content")
+	}
+	registry.Register(&fakeParser{})
+	parser, ok := registry.Lookup("txt")
+	if !ok {
+		t.Fatalf("Lookup(txt) failed")
+	}
+	if _, isFake := parser.(*fakeParser); !isFake || !registry.Supports("fake") {
+		t.Errorf("custom parser did not replace the built-in parser")
+	}
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	for _, fileType := range []string{"txt", "md", "markdown", "csv", "tsv", "json", "jsonl", "html", "htm"} {
+		if !Supports(fileType) {
+			t.Errorf("no built-in parser for %s", fileType)
+		}
+	}
+	for _, fileType := range []string{"pdf", "docx", "png"} {
+		if Supports(fileType) {
+			t.Errorf("unexpected built-in parser for %s", fileType)
+		}
+	}
+}
+
+func TestTextParser_GB18030(t *testing.T) {
+	encoded, err := simplifiedchinese.GB18030.NewEncoder().Bytes([]byte("中文内容"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := (&TextParser{}).Parse(context.Background(), encoded, nil)
+	if err != nil || len(chunks) != 1 || chunks[0].Content != "中文内容" {
+		t.Errorf("Parse() = %+v, %v", chunks, err)
+	}
+}
+
+func TestMarkdownParser_Images(t *testing.T) {
+	content := []byte("# 标题\n\n![logo](https://example.com/logo.png) and ![local](./a.png)\n")
+	chunks, err := (&MarkdownParser{}).Parse(context.Background(), content, nil)
+	if err != nil || len(chunks) != 1 {
+		t.Fatalf("Parse() = %+v, %v", chunks, err)
+	}
+	images := chunks[0].Images
+	if len(images) != 1 || images[0].Url != "https://example.com/logo.png" {
+		t.Fatalf("images = %+v", images)
+	}
+	runes := []rune(chunks[0].Content)
+	if got := string(runes[images[0].Start:images[0].End]); got != "![logo](https://example.com/logo.png)" {
+		t.Errorf("image position covers %q", got)
+	}
+
+	_, err = (&MarkdownParser{}).Parse(context.Background(), content, &proto.ReadConfig{EnableMultimodal: true})
+	if !errors.Is(err, ErrNotHandled) {
+		t.Errorf("multimodal Parse() error = %v, want ErrNotHandled", err)
+	}
+}
+
+func TestCSVParser(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"csv", "name, age\nAlice,30\n\"Bob, Jr\",\"4\"\nbroken\n"},
+		{"tsv", "name\tage\nAlice\t30\nBob, Jr\t4\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := (&CSVParser{}).Parse(context.Background(), []byte(tt.content), nil)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			want := []string{"name: Alice,age: 30\n", "name: Bob, Jr,age: 4\n"}
+			if len(chunks) != len(want) {
+				t.Fatalf("Parse() = %+v", chunks)
+			}
+			for i, chunk := range chunks {
+				if chunk.Content != want[i] {
+					t.Errorf("chunk %d = %q, want %q", i, chunk.Content, want[i])
+				}
+			}
+			if chunks[1].Start != chunks[0].End || chunks[1].End != chunks[0].End+int32(len(want[1])) {
+				t.Errorf("positions = [%d:%d] [%d:%d]", chunks[0].Start, chunks[0].End, chunks[1].Start, chunks[1].End)
+			}
+		})
+	}
+}
+
+func TestJSONParser(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"array", "[\n  {\"a\": 1},\n  {\"b\": [1, 2]}\n]", "{\"a\":1}\n{\"b\":[1,2]}\n"},
+		{"object", "{\"a\": {\"b\": \"c\"}}", "{\"a\":{\"b\":\"c\"}}\n"},
+		{"jsonl", "{\"a\": 1}\n\n{\"a\": 2}\n", "{\"a\":1}\n{\"a\":2}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := (&JSONParser{}).Parse(context.Background(), []byte(tt.content), nil)
+			if err != nil || len(chunks) != 1 || chunks[0].Content != tt.want {
+				t.Errorf("Parse() = %+v, %v", chunks, err)
+			}
+		})
+	}
+	if _, err := (&JSONParser{}).Parse(context.Background(), []byte("{broken"), nil); err == nil {
+		t.Errorf("Parse() of invalid JSON succeeded")
+	}
+}
+
+func TestHTMLParser(t *testing.T) {
+	content := `<html><head><title>t</title><style>p{}</style></head><body>
+<h2>Title</h2>
+<script>alert(1)</script>
+<p>Some   <b>bold</b> text with a <a href="https://example.com/x">link</a>.</p>
+<ul><li>one</li><li>two</li></ul>
+<img src="https://example.com/i.png" alt="pic"><img src="/relative.png">
+<table><tr><th>k</th><th>v</th></tr><tr><td>a</td><td>1</td></tr></table>
+</body></html>`
+	chunks, err := (&HTMLParser{}).Parse(context.Background(), []byte(content), nil)
+	if err != nil || len(chunks) != 1 {
+		t.Fatalf("Parse() = %+v, %v", chunks, err)
+	}
+	text := chunks[0].Content
+	for _, want := range []string{
+		"## Title", "Some bold text with a [link](https://example.com/x).", "- one\n", "- two\n",
+		"![pic](https://example.com/i.png)", "| k | v |\n| --- | --- |\n| a | 1 |",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("parsed text %q does not contain %q", text, want)
+		}
+	}
+	if strings.Contains(text, "alert") || strings.Contains(text, "relative") || strings.Contains(text, "p{}") {
+		t.Errorf("parsed text %q contains removed content", text)
+	}
+	if len(chunks[0].Images) != 1 || chunks[0].Images[0].Url != "https://example.com/i.png" {
+		t.Errorf("images = %+v", chunks[0].Images)
+	}
+}
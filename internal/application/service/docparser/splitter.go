@@ -0,0 +1,264 @@
+package docparser
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Tencent/WeKnora/docreader/proto"
+)
+
+const (
+	// DefaultChunkSize is the chunk size used when the read config has none, as in docreader
+	DefaultChunkSize = 512
+	// DefaultChunkOverlap is the chunk overlap used when the read config has none, as in docreader
+	DefaultChunkOverlap = 100
+)
+
+// DefaultSeparators are the separators used when the read config has none, as in docreader
+var DefaultSeparators = []string{"\n", "。", " "}
+
+// protectedPatterns are kept in one piece when they fit in a chunk, as in docreader
+var protectedPatterns = []*regexp.Regexp{
+	// math formula - LaTeX style formulas enclosed in $$
+	regexp.MustCompile(`\$\$[\s\S]*?\$\$`),
+	// image - Markdown image syntax ![alt](url)
+	regexp.MustCompile(`!\[.*?\]\(.*?\)`),
+	// link - Markdown link syntax [text](url)
+	regexp.MustCompile(`\[.*?\]\(.*?\)`),
+	// table header - Markdown table header with separator line
+	regexp.MustCompile(`(?:\|[^|\n]*)+\|[\r\n]+\s*(?:\|\s*:?-{3,}:?\s*)+\|[\r\n]+`),
+	// table body - Markdown table rows
+	regexp.MustCompile(`(?:\|[^|\n]*)+\|[\r\n]+`),
+	// code header - Code block start with language identifier
+	regexp.MustCompile("```(?:\\w+)[\\r\\n]+[^\\r\\n]*"),
+}
+
+// TextSplitter splits text into overlapping chunks the way the docreader splitter does.
+// Sizes and positions are counted in characters (runes).
+type TextSplitter struct {
+	ChunkSize    int
+	ChunkOverlap int
+	Separators   []string
+}
+
+// NewTextSplitter creates a splitter from the chunking settings of a read config
+func NewTextSplitter(config *proto.ReadConfig) *TextSplitter {
+	splitter := &TextSplitter{
+		ChunkSize:    DefaultChunkSize,
+		ChunkOverlap: DefaultChunkOverlap,
+		Separators:   DefaultSeparators,
+	}
+	if config == nil {
+		return splitter
+	}
+	if config.ChunkSize > 0 {
+		splitter.ChunkSize = int(config.ChunkSize)
+	}
+	if config.ChunkOverlap >= 0 && int(config.ChunkOverlap) < splitter.ChunkSize {
+		splitter.ChunkOverlap = int(config.ChunkOverlap)
+	}
+	if len(config.Separators) > 0 {
+		splitter.Separators = config.Separators
+	}
+	return splitter
+}
+
+// span is a piece of text with its rune position in the document
+type span struct {
+	start, end int
+	text       []rune
+}
+
+// Split splits text into chunks, Start and End are rune offsets into the text
+func (s *TextSplitter) Split(text string) []*proto.Chunk {
+	if text == "" {
+		return nil
+	}
+	runes := []rune(text)
+	splits := s.join(s.split(runes), s.protected(text, runes))
+
+	chunks := make([]*proto.Chunk, 0)
+	for _, merged := range s.merge(splits) {
+		chunks = append(chunks, &proto.Chunk{
+			Content: string(merged.text),
+			Seq:     int32(len(chunks)),
+			Start:   int32(merged.start),
+			End:     int32(merged.end),
+		})
+	}
+	return chunks
+}
+
+// split breaks text into pieces no longer than the chunk size, trying the separators in order
+// and falling back to single characters. The pieces keep their separators.
+func (s *TextSplitter) split(text []rune) [][]rune {
+	if len(text) <= s.ChunkSize {
+		return [][]rune{text}
+	}
+	var splits [][]rune
+	for _, sep := range s.Separators {
+		if splits = splitKeepSeparator(text, sep); len(splits) > 1 {
+			break
+		}
+	}
+	if len(splits) <= 1 {
+		splits = make([][]rune, 0, len(text))
+		for i := range text {
+			splits = append(splits, text[i:i+1])
+		}
+	}
+
+	res := make([][]rune, 0, len(splits))
+	for _, piece := range splits {
+		if len(piece) <= s.ChunkSize {
+			res = append(res, piece)
+		} else {
+			res = append(res, s.split(piece)...)
+		}
+	}
+	return res
+}
+
+// splitKeepSeparator splits text on sep, every piece but the first starts with the separator
+func splitKeepSeparator(text []rune, sep string) [][]rune {
+	if sep == "" {
+		return [][]rune{text}
+	}
+	parts := strings.Split(string(text), sep)
+	res := make([][]rune, 0, len(parts))
+	for i, part := range parts {
+		if i > 0 {
+			part = sep + part
+		}
+		if part != "" {
+			res = append(res, []rune(part))
+		}
+	}
+	return res
+}
+
+// protected returns the non-overlapping protected pieces that fit in a chunk, ordered by position
+func (s *TextSplitter) protected(text string, runes []rune) []span {
+	// Regexp positions are byte offsets, map them to rune offsets
+	runeIndex := make([]int, len(text)+1)
+	r := 0
+	for i := range text {
+		runeIndex[i] = r
+		r++
+	}
+	runeIndex[len(text)] = r
+	for i := len(text) - 1; i >= 0; i-- {
+		if runeIndex[i] == 0 && i > 0 {
+			runeIndex[i] = runeIndex[i+1]
+		}
+	}
+
+	var matches [][2]int
+	for _, pattern := range protectedPatterns {
+		for _, loc := range pattern.FindAllStringIndex(text, -1) {
+			matches = append(matches, [2]int{runeIndex[loc[0]], runeIndex[loc[1]]})
+		}
+	}
+	// Earlier first, longer first on the same position
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i][0] != matches[j][0] {
+			return matches[i][0] < matches[j][0]
+		}
+		return matches[i][1] > matches[j][1]
+	})
+
+	res := make([]span, 0, len(matches))
+	last := -1
+	for _, m := range matches {
+		if m[0] >= last && m[1]-m[0] < s.ChunkSize {
+			res = append(res, span{start: m[0], end: m[1], text: runes[m[0]:m[1]]})
+		}
+		if m[1] > last {
+			last = m[1]
+		}
+	}
+	return res
+}
+
+// join re-cuts the splits so that every protected piece is a split of its own
+func (s *TextSplitter) join(splits [][]rune, protect []span) [][]rune {
+	j := 0
+	point, start := 0, 0
+	res := make([][]rune, 0, len(splits))
+	for _, piece := range splits {
+		end := start + len(piece)
+		if point >= end {
+			// Covered by a protected piece already emitted
+			start = end
+			continue
+		}
+		cur := piece[point-start:]
+		for j < len(protect) {
+			p := protect[j]
+			if end <= p.start {
+				break
+			}
+			if point < p.start {
+				local := p.start - point
+				res = append(res, cur[:local])
+				cur = cur[local:]
+				point = p.start
+			}
+			res = append(res, p.text)
+			j++
+			if point < p.end {
+				local := p.end - point
+				if local > len(cur) {
+					local = len(cur)
+				}
+				cur = cur[local:]
+				point = p.end
+			}
+			if len(cur) == 0 {
+				break
+			}
+		}
+		if len(cur) > 0 {
+			res = append(res, cur)
+			point = end
+		}
+		start = end
+	}
+	return res
+}
+
+// merge packs the splits into chunks, starting every chunk with the overlap of the previous one
+func (s *TextSplitter) merge(splits [][]rune) []span {
+	var chunks []span
+	var current []span
+	curLen, curStart := 0, 0
+	flush := func() {
+		text := make([]rune, 0, curLen)
+		for _, piece := range current {
+			text = append(text, piece.text...)
+		}
+		chunks = append(chunks, span{start: current[0].start, end: current[len(current)-1].end, text: text})
+	}
+
+	for _, piece := range splits {
+		curEnd := curStart + len(piece)
+		if curLen+len(piece) > s.ChunkSize {
+			if len(current) > 0 {
+				flush()
+			}
+			// Drop pieces from the front until the rest fits as overlap
+			for len(current) > 0 && (curLen > s.ChunkOverlap || curLen+len(piece) > s.ChunkSize) {
+				curLen -= len(current[0].text)
+				current = current[1:]
+			}
+		}
+		current = append(current, span{start: curStart, end: curEnd, text: piece})
+		curLen += len(piece)
+		curStart = curEnd
+	}
+	if len(current) > 0 {
+		flush()
+	}
+	return chunks
+}
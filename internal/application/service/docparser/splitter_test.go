@@ -0,0 +1,77 @@
+package docparser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Tencent/WeKnora/docreader/proto"
+)
+
+// checkPositions verifies that every chunk is the text between its start and end
+func checkPositions(t *testing.T, text string, chunks []*proto.Chunk) {
+	t.Helper()
+	runes := []rune(text)
+	for _, chunk := range chunks {
+		if got := string(runes[chunk.Start:chunk.End]); got != chunk.Content {
+			t.Errorf("chunk %d content %q, text at [%d:%d] %q", chunk.Seq, chunk.Content, chunk.Start, chunk.End, got)
+		}
+	}
+}
+
+func TestTextSplitter_Short(t *testing.T) {
+	chunks := NewTextSplitter(nil).Split("hello world")
+	if len(chunks) != 1 || chunks[0].Content != "hello world" || chunks[0].Start != 0 || chunks[0].End != 11 {
+		t.Fatalf("Split() = %+v", chunks)
+	}
+	if chunks := NewTextSplitter(nil).Split(""); len(chunks) != 0 {
+		t.Errorf("Split(\"\") = %+v", chunks)
+	}
+}
+
+func TestTextSplitter_Overlap(t *testing.T) {
+	text := "第一行内容。\n第二行内容。\n第三行内容。\n第四行内容。\n第五行内容。\n"
+	splitter := NewTextSplitter(&proto.ReadConfig{ChunkSize: 16, ChunkOverlap: 8})
+	chunks := splitter.Split(text)
+	if len(chunks) < 2 {
+		t.Fatalf("Split() = %d chunks, want several", len(chunks))
+	}
+	checkPositions(t, text, chunks)
+	for i, chunk := range chunks {
+		if n := len([]rune(chunk.Content)); n > 16 {
+			t.Errorf("chunk %d has %d characters", i, n)
+		}
+		if int(chunk.Seq) != i {
+			t.Errorf("chunk %d has seq %d", i, chunk.Seq)
+		}
+		if i > 0 && chunk.Start >= chunks[i-1].End {
+			t.Errorf("chunk %d does not overlap the previous chunk", i)
+		}
+	}
+	if last := chunks[len(chunks)-1]; int(last.End) != len([]rune(text)) {
+		t.Errorf("last chunk ends at %d, want %d", last.End, len([]rune(text)))
+	}
+}
+
+func TestTextSplitter_LongWord(t *testing.T) {
+	text := strings.Repeat("a", 25)
+	chunks := NewTextSplitter(&proto.ReadConfig{ChunkSize: 10, ChunkOverlap: 0}).Split(text)
+	if len(chunks) != 3 {
+		t.Fatalf("Split() = %d chunks, want 3", len(chunks))
+	}
+	checkPositions(t, text, chunks)
+}
+
+func TestTextSplitter_Protected(t *testing.T) {
+	text := "intro text here ![an image](https://example.com/a.png) trailing words follow"
+	chunks := NewTextSplitter(&proto.ReadConfig{ChunkSize: 45, ChunkOverlap: 0}).Split(text)
+	checkPositions(t, text, chunks)
+	found := false
+	for _, chunk := range chunks {
+		if strings.Contains(chunk.Content, "![an image](https://example.com/a.png)") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("image reference split across chunks: %+v", chunks)
+	}
+}
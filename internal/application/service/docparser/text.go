@@ -0,0 +1,98 @@
+package docparser
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/Tencent/WeKnora/docreader/proto"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// utf8BOM is stripped from the start of text files
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// decodeText decodes text file content as UTF-8, or GB18030 when it is not valid UTF-8
+func decodeText(content []byte) (string, error) {
+	content = bytes.TrimPrefix(content, utf8BOM)
+	if utf8.Valid(content) {
+		return string(content), nil
+	}
+	decoded, err := simplifiedchinese.GB18030.NewDecoder().Bytes(content)
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
+
+// TextParser parses plain text files
+type TextParser struct{}
+
+// FileTypes implements DocumentParser
+func (p *TextParser) FileTypes() []string {
+	return []string{"txt"}
+}
+
+// Parse implements DocumentParser
+func (p *TextParser) Parse(ctx context.Context, content []byte, config *proto.ReadConfig) ([]*proto.Chunk, error) {
+	text, err := decodeText(content)
+	if err != nil {
+		return nil, err
+	}
+	return NewTextSplitter(config).Split(text), nil
+}
+
+// markdownImagePattern matches Markdown images, the first group is the image URL
+var markdownImagePattern = regexp.MustCompile(`!\[[^\]]*\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+
+// MarkdownParser parses Markdown files. Files with images are left to docreader when
+// multimodal processing is enabled, so that the images are stored, captioned and recognized.
+type MarkdownParser struct{}
+
+// FileTypes implements DocumentParser
+func (p *MarkdownParser) FileTypes() []string {
+	return []string{"md", "markdown"}
+}
+
+// Parse implements DocumentParser
+func (p *MarkdownParser) Parse(ctx context.Context, content []byte, config *proto.ReadConfig) ([]*proto.Chunk, error) {
+	text, err := decodeText(content)
+	if err != nil {
+		return nil, err
+	}
+	if config != nil && config.EnableMultimodal && markdownImagePattern.MatchString(text) {
+		return nil, ErrNotHandled
+	}
+	chunks := NewTextSplitter(config).Split(text)
+	attachMarkdownImages(chunks)
+	return chunks, nil
+}
+
+// attachMarkdownImages adds the images with absolute http(s) URLs referenced by each chunk to the chunk
+func attachMarkdownImages(chunks []*proto.Chunk) {
+	for _, chunk := range chunks {
+		for _, loc := range markdownImagePattern.FindAllStringSubmatchIndex(chunk.Content, -1) {
+			imageURL := chunk.Content[loc[2]:loc[3]]
+			if !isAbsoluteHTTPURL(imageURL) {
+				continue
+			}
+			start := chunk.Start + int32(utf8.RuneCountInString(chunk.Content[:loc[0]]))
+			chunk.Images = append(chunk.Images, &proto.Image{
+				Url:         imageURL,
+				OriginalUrl: imageURL,
+				Start:       start,
+				End:         start + int32(utf8.RuneCountInString(chunk.Content[loc[0]:loc[1]])),
+			})
+		}
+	}
+}
+
+func isAbsoluteHTTPURL(link string) bool {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
@@ -2,18 +2,25 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"runtime"
+	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/Tencent/WeKnora/internal/config"
+	werrors "github.com/Tencent/WeKnora/internal/errors"
 	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/models/chat"
 	"github.com/Tencent/WeKnora/internal/types"
 	"github.com/Tencent/WeKnora/internal/types/interfaces"
 	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
 	"golang.org/x/sync/errgroup"
+	"gorm.io/gorm"
 )
 
 /*
@@ -32,8 +39,9 @@ type EvaluationService struct {
 	knowledgeService     interfaces.KnowledgeService     // Service for knowledge operations
 	sessionService       interfaces.SessionService       // Service for chat sessions
 	modelService         interfaces.ModelService         // Service for model operations
-
-	evaluationMemoryStorage *evaluationMemoryStorage // In-memory storage for evaluation tasks
+	repo                 interfaces.EvaluationRepository // Persistence of tasks and per-question results
+	tenantRepo           interfaces.TenantRepository     // Repository for tenant lookups in background tasks
+	task                 *asynq.Client                   // Client for enqueuing evaluation tasks
 }
 
 func NewEvaluationService(
@@ -43,173 +51,195 @@ func NewEvaluationService(
 	knowledgeService interfaces.KnowledgeService,
 	sessionService interfaces.SessionService,
 	modelService interfaces.ModelService,
+	repo interfaces.EvaluationRepository,
+	tenantRepo interfaces.TenantRepository,
+	task *asynq.Client,
 ) interfaces.EvaluationService {
-	evaluationMemoryStorage := newEvaluationMemoryStorage()
 	return &EvaluationService{
-		config:                  config,
-		dataset:                 dataset,
-		knowledgeBaseService:    knowledgeBaseService,
-		knowledgeService:        knowledgeService,
-		sessionService:          sessionService,
-		modelService:            modelService,
-		evaluationMemoryStorage: evaluationMemoryStorage,
-	}
-}
-
-// evaluationMemoryStorage stores evaluation tasks in memory with thread-safe access
-type evaluationMemoryStorage struct {
-	store map[string]*types.EvaluationDetail // Map of taskID to evaluation details
-	mu    *sync.RWMutex                      // Read-write lock for concurrent access
-}
-
-func newEvaluationMemoryStorage() *evaluationMemoryStorage {
-	res := &evaluationMemoryStorage{
-		store: make(map[string]*types.EvaluationDetail),
-		mu:    &sync.RWMutex{},
+		config:               config,
+		dataset:              dataset,
+		knowledgeBaseService: knowledgeBaseService,
+		knowledgeService:     knowledgeService,
+		sessionService:       sessionService,
+		modelService:         modelService,
+		repo:                 repo,
+		tenantRepo:           tenantRepo,
+		task:                 task,
 	}
-	return res
-}
-
-func (e *evaluationMemoryStorage) register(params *types.EvaluationDetail) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-	logger.Infof(context.Background(), "Registering evaluation task: %s", params.Task.ID)
-	e.store[params.Task.ID] = params
 }
 
-func (e *evaluationMemoryStorage) get(taskID string) (*types.EvaluationDetail, error) {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
-	logger.Infof(context.Background(), "Getting evaluation task: %s", taskID)
-	res, ok := e.store[taskID]
-	if !ok {
-		return nil, errors.New("task not found")
+// getTask retrieves an evaluation task of the current tenant
+func (e *EvaluationService) getTask(ctx context.Context, taskID string) (*types.EvaluationTask, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	task, err := e.repo.GetTask(ctx, tenantID, taskID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, werrors.NewNotFoundError("Evaluation task not found")
+		}
+		return nil, err
 	}
-	return res, nil
+	return task, nil
 }
 
-func (e *evaluationMemoryStorage) update(taskID string, fn func(params *types.EvaluationDetail)) error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-	params, ok := e.store[taskID]
-	if !ok {
-		return errors.New("task not found")
+// toDetail converts a persisted evaluation task to its detail view
+func toEvaluationDetail(task *types.EvaluationTask) (*types.EvaluationDetail, error) {
+	detail := &types.EvaluationDetail{Task: task, Metric: task.Metric}
+	if len(task.Params) > 0 {
+		detail.Params = &types.ChatManage{}
+		if err := json.Unmarshal(task.Params, detail.Params); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal evaluation params: %w", err)
+		}
 	}
-	fn(params)
-	return nil
+	return detail, nil
 }
 
 func (e *EvaluationService) EvaluationResult(ctx context.Context, taskID string) (*types.EvaluationDetail, error) {
 	logger.Info(ctx, "Start getting evaluation result")
 	logger.Infof(ctx, "Task ID: %s", taskID)
 
-	detail, err := e.evaluationMemoryStorage.get(taskID)
+	task, err := e.getTask(ctx, taskID)
 	if err != nil {
 		logger.Errorf(ctx, "Failed to get evaluation task: %v", err)
 		return nil, err
 	}
 
+	logger.Info(ctx, "Evaluation result retrieved successfully")
+	return toEvaluationDetail(task)
+}
+
+// ListEvaluations lists the evaluation tasks of the current tenant, newest first
+func (e *EvaluationService) ListEvaluations(ctx context.Context, page *types.Pagination) (*types.PageResult, error) {
+	if page == nil {
+		page = &types.Pagination{}
+	}
 	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
-	logger.Infof(
-		ctx,
-		"Checking tenant ID match, task tenant ID: %d, current tenant ID: %d",
-		detail.Task.TenantID, tenantID,
-	)
+	tasks, total, err := e.repo.ListTasks(ctx, tenantID, page)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to list evaluation tasks: %v", err)
+		return nil, err
+	}
 
-	if tenantID != detail.Task.TenantID {
-		logger.Error(ctx, "Tenant ID mismatch")
-		return nil, errors.New("tenant ID does not match")
+	details := make([]*types.EvaluationDetail, 0, len(tasks))
+	for _, task := range tasks {
+		detail, err := toEvaluationDetail(task)
+		if err != nil {
+			return nil, err
+		}
+		details = append(details, detail)
 	}
+	return types.NewPageResult(total, page, details), nil
+}
 
-	logger.Info(ctx, "Evaluation result retrieved successfully")
-	return detail, nil
+// ListQuestionResults lists the per-question results of an evaluation task
+func (e *EvaluationService) ListQuestionResults(ctx context.Context,
+	taskID string, page *types.Pagination,
+) (*types.PageResult, error) {
+	if page == nil {
+		page = &types.Pagination{}
+	}
+	if _, err := e.getTask(ctx, taskID); err != nil {
+		return nil, err
+	}
+	results, total, err := e.repo.ListQuestionResults(ctx, taskID, page)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to list evaluation question results: %v", err)
+		return nil, err
+	}
+	return types.NewPageResult(total, page, results), nil
+}
+
+// GetQuestionResult retrieves the result of one question of an evaluation task
+func (e *EvaluationService) GetQuestionResult(ctx context.Context,
+	taskID string, questionIndex int,
+) (*types.EvaluationQuestionResult, error) {
+	if _, err := e.getTask(ctx, taskID); err != nil {
+		return nil, err
+	}
+	result, err := e.repo.GetQuestionResult(ctx, taskID, questionIndex)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, werrors.NewNotFoundError("Question result not found")
+		}
+		return nil, err
+	}
+	return result, nil
 }
 
 // Evaluation starts a new evaluation task with given parameters
 // datasetID: ID of the dataset to evaluate against
-// knowledgeBaseID: ID of the knowledge base to use (empty to create new)
+// knowledgeBaseID: ID of the knowledge base whose settings are used (empty to use defaults)
 // chatModelID: ID of the chat model to evaluate
 // rerankModelID: ID of the rerank model to evaluate
+// judgeModelID: ID of the chat model grading the answers (empty to skip LLM-as-judge metrics)
 func (e *EvaluationService) Evaluation(ctx context.Context,
-	datasetID string, knowledgeBaseID string, chatModelID string, rerankModelID string,
+	datasetID string, knowledgeBaseID string, chatModelID string, rerankModelID string, judgeModelID string,
 ) (*types.EvaluationDetail, error) {
 	logger.Info(ctx, "Start evaluation")
-	logger.Infof(ctx, "Dataset ID: %s, Knowledge Base ID: %s, Chat Model ID: %s, Rerank Model ID: %s",
-		datasetID, knowledgeBaseID, chatModelID, rerankModelID)
-
-	// Get tenant ID from context for multi-tenancy support
-	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
-	logger.Infof(ctx, "Tenant ID: %d", tenantID)
-
-	// Handle knowledge base creation if not provided
-	if knowledgeBaseID == "" {
-		logger.Info(ctx, "No knowledge base ID provided, creating new knowledge base")
-		// Create new knowledge base with default evaluation settings
-		// 获取默认的嵌入模型和LLM模型
-		models, err := e.modelService.ListModels(ctx)
-		if err != nil {
-			logger.Errorf(ctx, "Failed to list models: %v", err)
-			return nil, err
-		}
-
-		var embeddingModelID, llmModelID string
-		for _, model := range models {
-			if model == nil {
-				continue
-			}
-			if model.Type == types.ModelTypeEmbedding {
-				embeddingModelID = model.ID
-			}
-			if model.Type == types.ModelTypeKnowledgeQA {
-				llmModelID = model.ID
-			}
-		}
+	logger.Infof(ctx,
+		"Dataset ID: %s, Knowledge Base ID: %s, Chat Model ID: %s, Rerank Model ID: %s, Judge Model ID: %s",
+		datasetID, knowledgeBaseID, chatModelID, rerankModelID, judgeModelID)
 
-		if embeddingModelID == "" || llmModelID == "" {
-			return nil, fmt.Errorf("no default models found for evaluation")
-		}
+	if err := e.validateJudgeModel(ctx, judgeModelID); err != nil {
+		return nil, err
+	}
 
-		kb, err := e.knowledgeBaseService.CreateKnowledgeBase(ctx, &types.KnowledgeBase{
-			Name:             "evaluation",
-			Description:      "evaluation",
-			EmbeddingModelID: embeddingModelID,
-			SummaryModelID:   llmModelID,
-		})
-		if err != nil {
-			logger.Errorf(ctx, "Failed to create knowledge base: %v", err)
-			return nil, err
-		}
-		knowledgeBaseID = kb.ID
-		logger.Infof(ctx, "Created new knowledge base with ID: %s", knowledgeBaseID)
-	} else {
-		logger.Infof(ctx, "Using existing knowledge base ID: %s", knowledgeBaseID)
-		// Create evaluation-specific knowledge base based on existing one
-		kb, err := e.knowledgeBaseService.GetKnowledgeBaseByID(ctx, knowledgeBaseID)
-		if err != nil {
+	// The evaluation knowledge base is created by the background task, only validate the source here
+	if knowledgeBaseID != "" {
+		if _, err := e.knowledgeBaseService.GetKnowledgeBaseByID(ctx, knowledgeBaseID); err != nil {
 			logger.Errorf(ctx, "Failed to get knowledge base: %v", err)
 			return nil, err
 		}
-
-		kb, err = e.knowledgeBaseService.CreateKnowledgeBase(ctx, &types.KnowledgeBase{
-			Name:             "evaluation",
-			Description:      "evaluation",
-			EmbeddingModelID: kb.EmbeddingModelID,
-			SummaryModelID:   kb.SummaryModelID,
-		})
-		if err != nil {
-			logger.Errorf(ctx, "Failed to create knowledge base: %v", err)
-			return nil, err
-		}
-		knowledgeBaseID = kb.ID
-		logger.Infof(ctx, "Created new knowledge base with ID: %s based on existing one", knowledgeBaseID)
 	}
 
 	// Set default values for optional parameters
 	if datasetID == "" {
-		datasetID = "default"
+		datasetID = types.DefaultDatasetID
 		logger.Info(ctx, "Using default dataset")
 	}
+	dataset, err := e.dataset.GetDatasetByID(ctx, datasetID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to get dataset: %v", err)
+		return nil, err
+	}
+
+	task, params, err := e.createEvaluationTask(ctx, datasetID, knowledgeBaseID, judgeModelID, len(dataset), "",
+		&types.EvaluationVariant{ChatModelID: chatModelID, RerankModelID: rerankModelID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.startEvaluation(ctx, task); err != nil {
+		return nil, err
+	}
+	return &types.EvaluationDetail{Task: task, Params: params}, nil
+}
+
+// validateJudgeModel checks that the judge model, when given, is a chat model
+func (e *EvaluationService) validateJudgeModel(ctx context.Context, judgeModelID string) error {
+	if judgeModelID == "" {
+		return nil
+	}
+	model, err := e.modelService.GetModelByID(ctx, judgeModelID)
+	if err != nil {
+		return werrors.NewBadRequestError("Judge model not found").WithDetails(judgeModelID)
+	}
+	if model.Type != types.ModelTypeKnowledgeQA {
+		return werrors.NewBadRequestError("Judge model must be a chat model").WithDetails(judgeModelID)
+	}
+	return nil
+}
+
+// createEvaluationTask persists the evaluation task of one pipeline variant, it is run by startEvaluation.
+// Models not set by the variant fall back to the first model of the matching type
+func (e *EvaluationService) createEvaluationTask(ctx context.Context,
+	datasetID string, knowledgeBaseID string, judgeModelID string,
+	total int, comparisonID string, variant *types.EvaluationVariant,
+) (*types.EvaluationTask, *types.ChatManage, error) {
+	// Get tenant ID from context for multi-tenancy support
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	logger.Infof(ctx, "Tenant ID: %d", tenantID)
 
+	rerankModelID := variant.RerankModelID
 	if rerankModelID == "" {
 		// 获取默认的重排模型
 		models, err := e.modelService.ListModels(ctx)
@@ -231,6 +261,7 @@ func (e *EvaluationService) Evaluation(ctx context.Context,
 		}
 	}
 
+	chatModelID := variant.ChatModelID
 	if chatModelID == "" {
 		// 获取默认的LLM模型
 		models, err := e.modelService.ListModels(ctx)
@@ -246,7 +277,7 @@ func (e *EvaluationService) Evaluation(ctx context.Context,
 			}
 		}
 		if chatModelID == "" {
-			return nil, fmt.Errorf("no default chat model found")
+			return nil, nil, fmt.Errorf("no default chat model found")
 		}
 		logger.Infof(ctx, "Using default chat model: %s", chatModelID)
 	}
@@ -256,130 +287,282 @@ func (e *EvaluationService) Evaluation(ctx context.Context,
 	taskID := uuid.New().String()
 	logger.Infof(ctx, "Generated task ID: %s", taskID)
 
-	// Prepare evaluation detail with all parameters
-	detail := &types.EvaluationDetail{
-		Task: &types.EvaluationTask{
-			ID:        taskID,
-			TenantID:  tenantID,
-			DatasetID: datasetID,
-			Status:    types.EvaluationStatuePending,
-			StartTime: time.Now(),
-		},
-		Params: &types.ChatManage{
-			VectorThreshold:  e.config.Conversation.VectorThreshold,
-			KeywordThreshold: e.config.Conversation.KeywordThreshold,
-			EmbeddingTopK:    e.config.Conversation.EmbeddingTopK,
-			MaxRounds:        e.config.Conversation.MaxRounds,
-			RerankModelID:    rerankModelID,
-			RerankTopK:       e.config.Conversation.RerankTopK,
-			RerankThreshold:  e.config.Conversation.RerankThreshold,
-			ChatModelID:      chatModelID,
-			SummaryConfig: types.SummaryConfig{
-				MaxTokens:           e.config.Conversation.Summary.MaxTokens,
-				RepeatPenalty:       e.config.Conversation.Summary.RepeatPenalty,
-				TopK:                e.config.Conversation.Summary.TopK,
-				TopP:                e.config.Conversation.Summary.TopP,
-				Prompt:              e.config.Conversation.Summary.Prompt,
-				ContextTemplate:     e.config.Conversation.Summary.ContextTemplate,
-				FrequencyPenalty:    e.config.Conversation.Summary.FrequencyPenalty,
-				PresencePenalty:     e.config.Conversation.Summary.PresencePenalty,
-				NoMatchPrefix:       e.config.Conversation.Summary.NoMatchPrefix,
-				Temperature:         e.config.Conversation.Summary.Temperature,
-				Seed:                e.config.Conversation.Summary.Seed,
-				MaxCompletionTokens: e.config.Conversation.Summary.MaxCompletionTokens,
-			},
-			FallbackResponse:    e.config.Conversation.FallbackResponse,
-			RewritePromptSystem: e.config.Conversation.RewritePromptSystem,
-			RewritePromptUser:   e.config.Conversation.RewritePromptUser,
+	// Prepare evaluation parameters shared by every question
+	params := &types.ChatManage{
+		VectorThreshold:  e.config.Conversation.VectorThreshold,
+		KeywordThreshold: e.config.Conversation.KeywordThreshold,
+		EmbeddingTopK:    e.config.Conversation.EmbeddingTopK,
+		MaxRounds:        e.config.Conversation.MaxRounds,
+		RerankModelID:    rerankModelID,
+		RerankTopK:       e.config.Conversation.RerankTopK,
+		RerankThreshold:  e.config.Conversation.RerankThreshold,
+		ChatModelID:      chatModelID,
+		SummaryConfig: types.SummaryConfig{
+			MaxTokens:           e.config.Conversation.Summary.MaxTokens,
+			RepeatPenalty:       e.config.Conversation.Summary.RepeatPenalty,
+			TopK:                e.config.Conversation.Summary.TopK,
+			TopP:                e.config.Conversation.Summary.TopP,
+			Prompt:              e.config.Conversation.Summary.Prompt,
+			ContextTemplate:     e.config.Conversation.Summary.ContextTemplate,
+			FrequencyPenalty:    e.config.Conversation.Summary.FrequencyPenalty,
+			PresencePenalty:     e.config.Conversation.Summary.PresencePenalty,
+			NoMatchPrefix:       e.config.Conversation.Summary.NoMatchPrefix,
+			Temperature:         e.config.Conversation.Summary.Temperature,
+			Seed:                e.config.Conversation.Summary.Seed,
+			MaxCompletionTokens: e.config.Conversation.Summary.MaxCompletionTokens,
 		},
+		FallbackResponse:    e.config.Conversation.FallbackResponse,
+		RewritePromptSystem: e.config.Conversation.RewritePromptSystem,
+		RewritePromptUser:   e.config.Conversation.RewritePromptUser,
+	}
+	// Apply the retrieval overrides of the variant
+	if variant.EmbeddingTopK != nil {
+		params.EmbeddingTopK = *variant.EmbeddingTopK
+	}
+	if variant.VectorThreshold != nil {
+		params.VectorThreshold = *variant.VectorThreshold
+	}
+	if variant.KeywordThreshold != nil {
+		params.KeywordThreshold = *variant.KeywordThreshold
+	}
+	if variant.RerankTopK != nil {
+		params.RerankTopK = *variant.RerankTopK
+	}
+	if variant.RerankThreshold != nil {
+		params.RerankThreshold = *variant.RerankThreshold
+	}
+	paramsBytes, err := json.Marshal(params)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal evaluation params: %w", err)
 	}
 
-	// Store evaluation task in memory storage
+	task := &types.EvaluationTask{
+		ID:              taskID,
+		TenantID:        tenantID,
+		DatasetID:       datasetID,
+		KnowledgeBaseID: knowledgeBaseID,
+		ComparisonID:    comparisonID,
+		VariantName:     variant.Name,
+		Variant:         variant,
+		JudgeModelID:    judgeModelID,
+		Status:          types.EvaluationStatuePending,
+		StartTime:       time.Now(),
+		Total:           total,
+		Params:          types.JSON(paramsBytes),
+	}
+
+	// Persist the evaluation task
 	logger.Info(ctx, "Registering evaluation task")
-	e.evaluationMemoryStorage.register(detail)
+	if err := e.repo.CreateTask(ctx, task); err != nil {
+		logger.Errorf(ctx, "Failed to create evaluation task: %v", err)
+		return nil, nil, err
+	}
 
-	// Start evaluation in background goroutine
-	logger.Info(ctx, "Starting evaluation in background")
-	go func() {
-		// Create new context with logger for background task
-		newCtx := logger.CloneContext(ctx)
-		logger.Infof(newCtx, "Background evaluation started for task ID: %s", taskID)
+	logger.Infof(ctx, "Evaluation task created successfully, task ID: %s", taskID)
+	return task, params, nil
+}
 
-		// Update task status to running
-		detail.Task.Status = types.EvaluationStatueRunning
-		logger.Info(newCtx, "Evaluation task status set to running")
+// ResumeEvaluation re-enqueues a failed evaluation task, questions that already have results are skipped
+func (e *EvaluationService) ResumeEvaluation(ctx context.Context, taskID string) (*types.EvaluationDetail, error) {
+	task, err := e.getTask(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task.Status != types.EvaluationStatueFailed {
+		return nil, werrors.NewBadRequestError("Only failed evaluation tasks can be resumed")
+	}
 
-		// Execute actual evaluation
-		if err := e.EvalDataset(newCtx, detail, knowledgeBaseID); err != nil {
-			detail.Task.Status = types.EvaluationStatueFailed
-			detail.Task.ErrMsg = err.Error()
-			logger.Errorf(newCtx, "Evaluation task failed: %v, task ID: %s", err, taskID)
-			return
-		}
+	task.Status = types.EvaluationStatuePending
+	task.ErrMsg = ""
+	task.EndTime = nil
+	if err := e.repo.UpdateTask(ctx, task); err != nil {
+		logger.Errorf(ctx, "Failed to update evaluation task: %v", err)
+		return nil, err
+	}
+	if err := e.enqueueEvaluation(ctx, task); err != nil {
+		return nil, err
+	}
 
-		// Mark task as completed successfully
-		logger.Infof(newCtx, "Evaluation task completed successfully, task ID: %s", taskID)
-		detail.Task.Status = types.EvaluationStatueSuccess
-	}()
+	logger.Infof(ctx, "Evaluation task resumed, task ID: %s, finished: %d/%d", task.ID, task.Finished, task.Total)
+	return toEvaluationDetail(task)
+}
 
-	logger.Infof(ctx, "Evaluation task created successfully, task ID: %s", taskID)
-	return detail, nil
+// startEvaluation enqueues the background evaluation of a created task.
+// A task which can't be enqueued is marked failed, so that it can be resumed instead of staying pending.
+func (e *EvaluationService) startEvaluation(ctx context.Context, task *types.EvaluationTask) error {
+	if err := e.enqueueEvaluation(ctx, task); err != nil {
+		e.failEvaluationTask(ctx, task, err)
+		return err
+	}
+	return nil
+}
+
+// failEvaluationTask marks an evaluation task failed with the error
+func (e *EvaluationService) failEvaluationTask(ctx context.Context, task *types.EvaluationTask, err error) {
+	task.Status = types.EvaluationStatueFailed
+	task.ErrMsg = err.Error()
+	endTime := time.Now()
+	task.EndTime = &endTime
+	if updateErr := e.repo.UpdateTask(ctx, task); updateErr != nil {
+		logger.Errorf(ctx, "Failed to update evaluation task %s: %v", task.ID, updateErr)
+	}
+}
+
+// enqueueEvaluation enqueues the background evaluation of a task
+func (e *EvaluationService) enqueueEvaluation(ctx context.Context, task *types.EvaluationTask) error {
+	payloadBytes, err := json.Marshal(types.EvaluationPayload{
+		TenantID: task.TenantID,
+		TaskID:   task.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal evaluation payload: %w", err)
+	}
+
+	info, err := e.task.Enqueue(
+		asynq.NewTask(types.TypeEvaluation, payloadBytes, asynq.Queue("low"), asynq.MaxRetry(3)),
+	)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to enqueue evaluation task: %v", err)
+		return fmt.Errorf("failed to enqueue task: %w", err)
+	}
+	logger.Infof(ctx, "Evaluation task enqueued: %s, asynq task ID: %s", task.ID, info.ID)
+	return nil
+}
+
+// ProcessEvaluation handles Asynq evaluation tasks.
+// Every answered question is persisted, so a retried or resumed task only evaluates the remaining questions.
+func (e *EvaluationService) ProcessEvaluation(ctx context.Context, t *asynq.Task) error {
+	var payload types.EvaluationPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal evaluation payload: %w", err)
+	}
+
+	ctx = context.WithValue(ctx, types.TenantIDContextKey, payload.TenantID)
+	tenantInfo, err := e.tenantRepo.GetTenantByID(ctx, payload.TenantID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to get tenant info: %v", err)
+		return fmt.Errorf("failed to get tenant info: %w", err)
+	}
+	ctx = context.WithValue(ctx, types.TenantInfoContextKey, tenantInfo)
+
+	retryCount, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	isLastRetry := retryCount >= maxRetry
+
+	task, err := e.repo.GetTask(ctx, payload.TenantID, payload.TaskID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to get evaluation task: %v", err)
+		return err
+	}
+	if task.Status == types.EvaluationStatueSuccess {
+		logger.Infof(ctx, "Evaluation task already finished: %s", task.ID)
+		return nil
+	}
+	logger.Infof(ctx, "Background evaluation started for task ID: %s, retry: %d/%d", task.ID, retryCount, maxRetry)
+
+	// Update task status to running
+	task.Status = types.EvaluationStatueRunning
+	task.ErrMsg = ""
+	if err := e.repo.UpdateTask(ctx, task); err != nil {
+		return err
+	}
+
+	// Execute actual evaluation
+	if err := e.EvalDataset(ctx, task); err != nil {
+		logger.Errorf(ctx, "Evaluation task failed: %v, task ID: %s", err, task.ID)
+		task.ErrMsg = err.Error()
+		if isLastRetry {
+			// Results are kept, only the temporary resources are dropped; a resume recreates them
+			task.Status = types.EvaluationStatueFailed
+			endTime := time.Now()
+			task.EndTime = &endTime
+			e.cleanupEvaluationResources(ctx, task)
+		}
+		if updateErr := e.repo.UpdateTask(ctx, task); updateErr != nil {
+			logger.Errorf(ctx, "Failed to update evaluation task: %v", updateErr)
+		}
+		return err
+	}
+
+	// Mark task as completed successfully
+	task.Status = types.EvaluationStatueSuccess
+	endTime := time.Now()
+	task.EndTime = &endTime
+	e.cleanupEvaluationResources(ctx, task)
+	if err := e.repo.UpdateTask(ctx, task); err != nil {
+		logger.Errorf(ctx, "Failed to update evaluation task: %v", err)
+		return err
+	}
+	logger.Infof(ctx, "Evaluation task completed successfully, task ID: %s", task.ID)
+	return nil
 }
 
 // EvalDataset performs the actual evaluation of a dataset
-// Processes each QA pair in parallel and records metrics
-func (e *EvaluationService) EvalDataset(ctx context.Context, detail *types.EvaluationDetail, knowledgeBaseID string) error {
+// Processes each remaining QA pair in parallel and persists its results and metrics
+func (e *EvaluationService) EvalDataset(ctx context.Context, task *types.EvaluationTask) error {
 	logger.Info(ctx, "Start evaluating dataset")
-	logger.Infof(ctx, "Task ID: %s, Dataset ID: %s", detail.Task.ID, detail.Task.DatasetID)
+	logger.Infof(ctx, "Task ID: %s, Dataset ID: %s", task.ID, task.DatasetID)
+
+	params := &types.ChatManage{}
+	if err := json.Unmarshal(task.Params, params); err != nil {
+		return fmt.Errorf("failed to unmarshal evaluation params: %w", err)
+	}
 
 	// Retrieve dataset from storage
-	dataset, err := e.dataset.GetDatasetByID(ctx, detail.Task.DatasetID)
+	dataset, err := e.dataset.GetDatasetByID(ctx, task.DatasetID)
 	if err != nil {
 		logger.Errorf(ctx, "Failed to get dataset: %v", err)
 		return err
 	}
 	logger.Infof(ctx, "Dataset retrieved successfully with %d QA pairs", len(dataset))
 
-	// Update total QA pairs count in task details
-	e.evaluationMemoryStorage.update(detail.Task.ID, func(params *types.EvaluationDetail) {
-		params.Task.Total = len(dataset)
-		logger.Infof(ctx, "Updated task total to %d QA pairs", params.Task.Total)
-	})
+	// Skip questions answered by a previous attempt
+	answered, err := e.repo.ListQuestionMetrics(ctx, task.ID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to list answered questions: %v", err)
+		return err
+	}
+	done := make(map[int]bool, len(answered))
+	metricList := &MetricList{}
+	for _, result := range answered {
+		done[result.QuestionIndex] = true
+		if result.Metric != nil {
+			metricList.results = append(metricList.results, result.Metric)
+		}
+	}
 
-	// Extract and organize passages from dataset
-	passages := getPassageList(dataset)
-	logger.Infof(ctx, "Creating knowledge from %d passages", len(passages))
+	task.Total = len(dataset)
+	task.Finished = len(done)
+	if err := e.repo.UpdateTask(ctx, task); err != nil {
+		return err
+	}
+	if len(done) >= len(dataset) {
+		logger.Infof(ctx, "All %d questions already evaluated", len(dataset))
+		task.Metric = metricList.Avg()
+		return nil
+	}
+	logger.Infof(ctx, "Evaluating %d remaining questions of %d", len(dataset)-len(done), len(dataset))
 
-	// Create knowledge base from passages
-	knowledge, err := e.knowledgeService.CreateKnowledgeFromPassage(ctx, knowledgeBaseID, passages)
+	knowledgeBaseID, chunkToPassage, err := e.prepareEvaluationKnowledge(ctx, task, dataset)
 	if err != nil {
-		logger.Errorf(ctx, "Failed to create knowledge from passages: %v", err)
 		return err
 	}
-	logger.Infof(ctx, "Knowledge created successfully, ID: %s", knowledge.ID)
-
-	// Setup cleanup of temporary resources
-	defer func() {
-		logger.Infof(ctx, "Cleaning up resources - deleting knowledge: %s", knowledge.ID)
-		if err := e.knowledgeService.DeleteKnowledge(ctx, knowledge.ID); err != nil {
-			logger.Errorf(ctx, "Failed to delete knowledge: %v, knowledge ID: %s", err, knowledge.ID)
-		}
 
-		logger.Infof(ctx, "Cleaning up resources - deleting knowledge base: %s", knowledgeBaseID)
-		if err := e.knowledgeBaseService.DeleteKnowledgeBase(ctx, knowledgeBaseID); err != nil {
-			logger.Errorf(
-				ctx,
-				"Failed to delete knowledge base: %v, knowledge base ID: %s",
-				err, knowledgeBaseID,
-			)
+	// Answers are graded by the judge model in addition to the lexical and ranking metrics
+	var judgeModel chat.Chat
+	if task.JudgeModelID != "" {
+		judgeModel, err = e.modelService.GetChatModel(ctx, task.JudgeModelID)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to get judge model: %v", err)
+			return err
 		}
-	}()
+	}
 
 	// Initialize parallel evaluation metrics
-	var finished int
 	var mu sync.Mutex
 	var g errgroup.Group
 	metricHook := NewHookMetric(len(dataset))
+	metricHook.setPassageMapping(chunkToPassage)
 
 	// Set worker limit based on available CPUs
 	g.SetLimit(max(runtime.GOMAXPROCS(0)-1, 1))
@@ -387,13 +570,14 @@ func (e *EvaluationService) EvalDataset(ctx context.Context, detail *types.Evalu
 
 	// Process each QA pair in parallel
 	for i, qaPair := range dataset {
-		qaPair := qaPair
-		i := i
+		if done[i] {
+			continue
+		}
 		g.Go(func() error {
 			logger.Infof(ctx, "Processing QA pair %d, question: %s", i, qaPair.Question)
 
 			// Prepare chat management parameters for this QA pair
-			chatManage := detail.Params.Clone()
+			chatManage := params.Clone()
 			chatManage.Query = qaPair.Question
 			chatManage.RewriteQuery = qaPair.Question
 			// Set knowledge base ID and search targets for this evaluation
@@ -407,8 +591,7 @@ func (e *EvaluationService) EvalDataset(ctx context.Context, detail *types.Evalu
 
 			// Execute knowledge QA pipeline
 			logger.Infof(ctx, "Running knowledge QA for question: %s", qaPair.Question)
-			err = e.sessionService.KnowledgeQAByEvent(ctx, chatManage, types.Pipline["rag"])
-			if err != nil {
+			if err := e.sessionService.KnowledgeQAByEvent(ctx, chatManage, types.Pipline["rag"]); err != nil {
 				logger.Errorf(ctx, "Failed to process question %d: %v", i, err)
 				return err
 			}
@@ -419,19 +602,50 @@ func (e *EvaluationService) EvalDataset(ctx context.Context, detail *types.Evalu
 			metricHook.recordQaPair(i, qaPair)
 			metricHook.recordSearchResult(i, chatManage.SearchResult)
 			metricHook.recordRerankResult(i, chatManage.RerankResult)
+			metricHook.recordMergeResult(i, chatManage.MergeResult)
 			metricHook.recordChatResponse(i, chatManage.ChatResponse)
-			metricHook.recordFinish(i)
+			metricResult := metricHook.recordFinish(i)
+			judgeErrMsg := ""
+			if judgeModel != nil {
+				// A failed grading is kept on the question result instead of failing the whole task
+				if err := metricHook.recordJudge(ctx, i, judgeModel, metricResult); err != nil {
+					logger.Warnf(ctx, "Failed to grade QA pair %d with judge model: %v", i, err)
+					judgeErrMsg = fmt.Sprintf("judge: %v", err)
+				}
+			}
+
+			relevantPIDs, _ := json.Marshal(qaPair.PIDs)
+			result := &types.EvaluationQuestionResult{
+				TaskID:             task.ID,
+				TenantID:           task.TenantID,
+				QuestionIndex:      i,
+				QID:                qaPair.QID,
+				Question:           qaPair.Question,
+				ExpectedAnswer:     qaPair.Answer,
+				RelevantPassageIDs: types.JSON(relevantPIDs),
+				SearchResults:      chatManage.SearchResult,
+				RerankResults:      chatManage.RerankResult,
+				ErrMsg:             judgeErrMsg,
+				Metric:             metricResult,
+			}
+			if chatManage.ChatResponse != nil {
+				result.GeneratedAnswer = chatManage.ChatResponse.Content
+			}
+			if err := e.repo.SaveQuestionResult(ctx, result); err != nil {
+				logger.Errorf(ctx, "Failed to save result of question %d: %v", i, err)
+				return err
+			}
 
 			// Update progress metrics
 			mu.Lock()
-			finished += 1
-			metricResult := metricHook.MetricResult()
-			mu.Unlock()
-			e.evaluationMemoryStorage.update(detail.Task.ID, func(params *types.EvaluationDetail) {
-				params.Metric = metricResult
-				params.Task.Finished = finished
-				logger.Infof(ctx, "Updated task progress: %d/%d completed", finished, params.Task.Total)
-			})
+			defer mu.Unlock()
+			metricList.results = append(metricList.results, metricResult)
+			task.Finished += 1
+			task.Metric = metricList.Avg()
+			if err := e.repo.UpdateTask(ctx, task); err != nil {
+				logger.Warnf(ctx, "Failed to update evaluation progress: %v", err)
+			}
+			logger.Infof(ctx, "Updated task progress: %d/%d completed", task.Finished, task.Total)
 			return nil
 		})
 	}
@@ -444,15 +658,165 @@ func (e *EvaluationService) EvalDataset(ctx context.Context, detail *types.Evalu
 	}
 
 	// Final update of evaluation metrics
-	e.evaluationMemoryStorage.update(detail.Task.ID, func(params *types.EvaluationDetail) {
-		params.Metric = metricHook.MetricResult()
-		params.Task.Finished = finished
-	})
+	task.Metric = metricList.Avg()
 
-	logger.Infof(ctx, "Dataset evaluation completed successfully, task ID: %s", detail.Task.ID)
+	logger.Infof(ctx, "Dataset evaluation completed successfully, task ID: %s", task.ID)
 	return nil
 }
 
+// prepareEvaluationKnowledge makes sure the temporary knowledge base holding the dataset passages exists,
+// reusing the one created by a previous attempt when its passages were fully indexed.
+// When the variant chunks the passages, the returned map gives the passage ID of every chunk index.
+func (e *EvaluationService) prepareEvaluationKnowledge(ctx context.Context,
+	task *types.EvaluationTask, dataset []*types.QAPair,
+) (string, map[int]int, error) {
+	// Extract and organize passages from dataset
+	passages := getPassageList(dataset)
+	var chunkToPassage map[int]int
+	if task.Variant != nil && task.Variant.ChunkingConfig != nil && task.Variant.ChunkingConfig.ChunkSize > 0 {
+		passages, chunkToPassage = chunkPassages(passages, task.Variant.ChunkingConfig)
+	}
+
+	if task.EvalKnowledgeBaseID != "" {
+		if _, err := e.knowledgeBaseService.GetKnowledgeBaseByID(ctx, task.EvalKnowledgeBaseID); err != nil {
+			logger.Warnf(ctx, "Evaluation knowledge base %s is gone, recreating: %v", task.EvalKnowledgeBaseID, err)
+			task.EvalKnowledgeBaseID = ""
+			task.EvalKnowledgeID = ""
+		}
+	}
+	if task.EvalKnowledgeBaseID != "" && task.EvalKnowledgeID != "" {
+		knowledge, err := e.knowledgeService.GetKnowledgeByID(ctx, task.EvalKnowledgeID)
+		if err == nil && knowledge.ParseStatus == types.ParseStatusCompleted {
+			logger.Infof(ctx, "Reusing evaluation knowledge: %s", knowledge.ID)
+			return task.EvalKnowledgeBaseID, chunkToPassage, nil
+		}
+		if err == nil {
+			if err := e.knowledgeService.DeleteKnowledge(ctx, task.EvalKnowledgeID); err != nil {
+				logger.Warnf(ctx, "Failed to delete incomplete evaluation knowledge: %v", err)
+			}
+		}
+		task.EvalKnowledgeID = ""
+	}
+
+	if task.EvalKnowledgeBaseID == "" {
+		kb, err := e.createEvaluationKnowledgeBase(ctx, task)
+		if err != nil {
+			return "", nil, err
+		}
+		task.EvalKnowledgeBaseID = kb.ID
+		if err := e.repo.UpdateTask(ctx, task); err != nil {
+			return "", nil, err
+		}
+	}
+
+	logger.Infof(ctx, "Creating knowledge from %d passages", len(passages))
+
+	// Index the passages before asking any question
+	knowledge, err := e.knowledgeService.CreateKnowledgeFromPassageSync(ctx, task.EvalKnowledgeBaseID, passages)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to create knowledge from passages: %v", err)
+		return "", nil, err
+	}
+	task.EvalKnowledgeID = knowledge.ID
+	if err := e.repo.UpdateTask(ctx, task); err != nil {
+		return "", nil, err
+	}
+	logger.Infof(ctx, "Knowledge created successfully, ID: %s", knowledge.ID)
+	return task.EvalKnowledgeBaseID, chunkToPassage, nil
+}
+
+// createEvaluationKnowledgeBase creates the temporary knowledge base of an evaluation,
+// taking models and retrieval settings from the source knowledge base when one is given
+// and applying the embedding model and fusion overrides of the task variant
+func (e *EvaluationService) createEvaluationKnowledgeBase(ctx context.Context,
+	task *types.EvaluationTask,
+) (*types.KnowledgeBase, error) {
+	sourceKnowledgeBaseID := task.KnowledgeBaseID
+	evalKB := &types.KnowledgeBase{
+		Name:        "evaluation",
+		Description: "evaluation",
+		IsTemporary: true,
+	}
+
+	if sourceKnowledgeBaseID == "" {
+		logger.Info(ctx, "No knowledge base ID provided, creating new knowledge base")
+		// Create new knowledge base with default evaluation settings
+		// 获取默认的嵌入模型和LLM模型
+		models, err := e.modelService.ListModels(ctx)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to list models: %v", err)
+			return nil, err
+		}
+
+		for _, model := range models {
+			if model == nil {
+				continue
+			}
+			if model.Type == types.ModelTypeEmbedding {
+				evalKB.EmbeddingModelID = model.ID
+			}
+			if model.Type == types.ModelTypeKnowledgeQA {
+				evalKB.SummaryModelID = model.ID
+			}
+		}
+
+		if evalKB.EmbeddingModelID == "" || evalKB.SummaryModelID == "" {
+			return nil, fmt.Errorf("no default models found for evaluation")
+		}
+	} else {
+		logger.Infof(ctx, "Using existing knowledge base ID: %s", sourceKnowledgeBaseID)
+		// Create evaluation-specific knowledge base based on existing one
+		kb, err := e.knowledgeBaseService.GetKnowledgeBaseByID(ctx, sourceKnowledgeBaseID)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to get knowledge base: %v", err)
+			return nil, err
+		}
+		evalKB.EmbeddingModelID = kb.EmbeddingModelID
+		evalKB.SummaryModelID = kb.SummaryModelID
+		evalKB.RetrievalConfig = kb.RetrievalConfig
+	}
+
+	if variant := task.Variant; variant != nil {
+		if variant.EmbeddingModelID != "" {
+			evalKB.EmbeddingModelID = variant.EmbeddingModelID
+		}
+		if variant.RetrievalConfig != nil {
+			evalKB.RetrievalConfig = variant.RetrievalConfig
+		}
+	}
+
+	kb, err := e.knowledgeBaseService.CreateKnowledgeBase(ctx, evalKB)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to create knowledge base: %v", err)
+		return nil, err
+	}
+	logger.Infof(ctx, "Created evaluation knowledge base with ID: %s", kb.ID)
+	return kb, nil
+}
+
+// cleanupEvaluationResources deletes the temporary knowledge and knowledge base of an evaluation
+func (e *EvaluationService) cleanupEvaluationResources(ctx context.Context, task *types.EvaluationTask) {
+	if task.EvalKnowledgeID != "" {
+		logger.Infof(ctx, "Cleaning up resources - deleting knowledge: %s", task.EvalKnowledgeID)
+		if err := e.knowledgeService.DeleteKnowledge(ctx, task.EvalKnowledgeID); err != nil {
+			logger.Errorf(ctx, "Failed to delete knowledge: %v, knowledge ID: %s", err, task.EvalKnowledgeID)
+		}
+		task.EvalKnowledgeID = ""
+	}
+
+	if task.EvalKnowledgeBaseID != "" {
+		logger.Infof(ctx, "Cleaning up resources - deleting knowledge base: %s", task.EvalKnowledgeBaseID)
+		if err := e.knowledgeBaseService.DeleteKnowledgeBase(ctx, task.EvalKnowledgeBaseID); err != nil {
+			logger.Errorf(
+				ctx,
+				"Failed to delete knowledge base: %v, knowledge base ID: %s",
+				err, task.EvalKnowledgeBaseID,
+			)
+		}
+		task.EvalKnowledgeBaseID = ""
+	}
+}
+
 // getPassageList extracts and organizes passages from QA pairs
 // Returns a slice of passages indexed by their passage IDs
 func getPassageList(dataset []*types.QAPair) []string {
@@ -464,11 +828,69 @@ func getPassageList(dataset []*types.QAPair) []string {
 			maxPID = max(maxPID, qaPair.PIDs[i])
 		}
 	}
-	passages := make([]string, maxPID)
-	for i := 0; i < maxPID; i++ {
+	passages := make([]string, maxPID+1)
+	for i := 0; i <= maxPID; i++ {
 		if _, ok := pIDMap[i]; ok {
 			passages[i] = pIDMap[i]
 		}
 	}
 	return passages
 }
+
+// chunkPassages splits every passage into chunks of at most ChunkSize runes,
+// returning the chunks and the passage ID of every chunk index.
+// Empty passages keep an empty slot so passage IDs stay aligned for unchunked lookups.
+func chunkPassages(passages []string, chunking *types.ChunkingConfig) ([]string, map[int]int) {
+	chunks := make([]string, 0, len(passages))
+	chunkToPassage := make(map[int]int, len(passages))
+	for pid, passage := range passages {
+		for _, chunk := range splitPassage(passage, chunking.ChunkSize, chunking.ChunkOverlap, chunking.Separators) {
+			chunkToPassage[len(chunks)] = pid
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks, chunkToPassage
+}
+
+// splitPassage splits text into overlapping windows of at most size runes,
+// ending a window after the last separator in its second half when there is one
+func splitPassage(text string, size int, overlap int, separators []string) []string {
+	runes := []rune(text)
+	if len(runes) <= size {
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		return []string{text}
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	var chunks []string
+	for start := 0; start < len(runes); {
+		end := min(start+size, len(runes))
+		if end < len(runes) {
+			window := string(runes[start+size/2 : end])
+			best := -1
+			for _, sep := range separators {
+				if sep == "" {
+					continue
+				}
+				if idx := strings.LastIndex(window, sep); idx >= 0 {
+					best = max(best, utf8.RuneCountInString(window[:idx+len(sep)]))
+				}
+			}
+			if best > 0 {
+				end = start + size/2 + best
+			}
+		}
+		if chunk := strings.TrimSpace(string(runes[start:end])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		if end >= len(runes) {
+			break
+		}
+		start = max(end-overlap, start+1)
+	}
+	return chunks
+}
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	werrors "github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultComparisonMetric decides per-question wins when no metric is requested
+	defaultComparisonMetric = "ndcg10"
+	// defaultComparisonCaseLimit is the number of winning cases returned when no limit is requested
+	defaultComparisonCaseLimit = 20
+	// comparisonTieEpsilon is the score difference below which variants are considered tied
+	comparisonTieEpsilon = 1e-9
+)
+
+// CompareEvaluations starts one evaluation task per pipeline variant on the same dataset
+func (e *EvaluationService) CompareEvaluations(ctx context.Context,
+	request *types.EvaluationComparisonRequest,
+) (*types.EvaluationComparison, error) {
+	logger.Infof(ctx, "Start evaluation comparison, dataset ID: %s, knowledge base ID: %s, variants: %d",
+		request.DatasetID, request.KnowledgeBaseID, len(request.Variants))
+
+	if len(request.Variants) < 2 {
+		return nil, werrors.NewBadRequestError("At least two variants are required")
+	}
+	names := make(map[string]bool, len(request.Variants))
+	for i, variant := range request.Variants {
+		if variant == nil {
+			return nil, werrors.NewBadRequestError(fmt.Sprintf("Variant %d is empty", i))
+		}
+		if variant.Name == "" {
+			variant.Name = fmt.Sprintf("variant-%d", i+1)
+		}
+		if names[variant.Name] {
+			return nil, werrors.NewBadRequestError("Variant names must be unique").WithDetails(variant.Name)
+		}
+		names[variant.Name] = true
+		if err := e.validateVariant(ctx, variant); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := e.validateJudgeModel(ctx, request.JudgeModelID); err != nil {
+		return nil, err
+	}
+	if request.KnowledgeBaseID != "" {
+		if _, err := e.knowledgeBaseService.GetKnowledgeBaseByID(ctx, request.KnowledgeBaseID); err != nil {
+			logger.Errorf(ctx, "Failed to get knowledge base: %v", err)
+			return nil, err
+		}
+	}
+	datasetID := request.DatasetID
+	if datasetID == "" {
+		datasetID = types.DefaultDatasetID
+	}
+	dataset, err := e.dataset.GetDatasetByID(ctx, datasetID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to get dataset: %v", err)
+		return nil, err
+	}
+
+	comparison := &types.EvaluationComparison{
+		ID:              uuid.New().String(),
+		TenantID:        ctx.Value(types.TenantIDContextKey).(uint64),
+		Name:            request.Name,
+		DatasetID:       datasetID,
+		KnowledgeBaseID: request.KnowledgeBaseID,
+		Variants:        request.Variants,
+		TaskIDs:         make(types.StringArray, 0, len(request.Variants)),
+	}
+	// The tasks only run once the comparison is stored, the ones created before a failure are marked failed
+	tasks := make([]*types.EvaluationTask, 0, len(request.Variants))
+	failTasks := func(err error) {
+		for _, task := range tasks {
+			e.failEvaluationTask(ctx, task, err)
+		}
+	}
+	for _, variant := range request.Variants {
+		task, _, err := e.createEvaluationTask(ctx,
+			datasetID, request.KnowledgeBaseID, request.JudgeModelID, len(dataset), comparison.ID, variant,
+		)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to create evaluation task of variant %s: %v", variant.Name, err)
+			failTasks(err)
+			return nil, err
+		}
+		tasks = append(tasks, task)
+		comparison.TaskIDs = append(comparison.TaskIDs, task.ID)
+	}
+
+	if err := e.repo.CreateComparison(ctx, comparison); err != nil {
+		logger.Errorf(ctx, "Failed to create evaluation comparison: %v", err)
+		failTasks(err)
+		return nil, err
+	}
+	// Tasks which can't be enqueued are marked failed and can be resumed, the others run anyway
+	var startErr error
+	for _, task := range tasks {
+		if err := e.startEvaluation(ctx, task); err != nil && startErr == nil {
+			startErr = err
+		}
+	}
+	if startErr != nil {
+		return nil, startErr
+	}
+	logger.Infof(ctx, "Evaluation comparison created successfully, ID: %s", comparison.ID)
+	return comparison, nil
+}
+
+// validateVariant checks the models and chunking settings of a variant
+func (e *EvaluationService) validateVariant(ctx context.Context, variant *types.EvaluationVariant) error {
+	for _, modelID := range []string{variant.EmbeddingModelID, variant.ChatModelID, variant.RerankModelID} {
+		if modelID == "" {
+			continue
+		}
+		if _, err := e.modelService.GetModelByID(ctx, modelID); err != nil {
+			return werrors.NewBadRequestError("Model not found").WithDetails(modelID)
+		}
+	}
+	if chunking := variant.ChunkingConfig; chunking != nil {
+		if chunking.ChunkSize < 0 || chunking.ChunkOverlap < 0 {
+			return werrors.NewBadRequestError("Chunk size and overlap must not be negative")
+		}
+		if chunking.ChunkSize > 0 && chunking.ChunkOverlap >= chunking.ChunkSize {
+			return werrors.NewBadRequestError("Chunk overlap must be smaller than chunk size")
+		}
+	}
+	return nil
+}
+
+// ListComparisons lists the comparisons of the current tenant, newest first
+func (e *EvaluationService) ListComparisons(ctx context.Context, page *types.Pagination) (*types.PageResult, error) {
+	if page == nil {
+		page = &types.Pagination{}
+	}
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	comparisons, total, err := e.repo.ListComparisons(ctx, tenantID, page)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to list evaluation comparisons: %v", err)
+		return nil, err
+	}
+	return types.NewPageResult(total, page, comparisons), nil
+}
+
+// GetComparisonReport builds the side-by-side report of a comparison.
+// Only questions answered by every variant are compared, so the report of a running comparison is partial.
+func (e *EvaluationService) GetComparisonReport(ctx context.Context,
+	comparisonID string, metric string, limit int,
+) (*types.EvaluationComparisonReport, error) {
+	if metric == "" {
+		metric = defaultComparisonMetric
+	}
+	if !isMetricName(metric) {
+		return nil, werrors.NewBadRequestError("Unknown metric").WithDetails(metric)
+	}
+	if limit <= 0 {
+		limit = defaultComparisonCaseLimit
+	}
+
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	comparison, err := e.repo.GetComparison(ctx, tenantID, comparisonID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, werrors.NewNotFoundError("Evaluation comparison not found")
+		}
+		return nil, err
+	}
+
+	report := &types.EvaluationComparisonReport{
+		Comparison: comparison,
+		Variants:   make([]*types.EvaluationVariantReport, 0, len(comparison.TaskIDs)),
+		Metric:     metric,
+		Cases:      make([]*types.EvaluationComparisonCase, 0),
+	}
+
+	// Per-question scores of every variant, keyed by question index
+	scores := make([]map[int]float64, 0, len(comparison.TaskIDs))
+	questions := make(map[int]string)
+	for _, taskID := range comparison.TaskIDs {
+		task, err := e.repo.GetTask(ctx, tenantID, taskID)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to get evaluation task %s: %v", taskID, err)
+			return nil, err
+		}
+		results, err := e.repo.ListQuestionMetrics(ctx, taskID)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to list question metrics of task %s: %v", taskID, err)
+			return nil, err
+		}
+
+		variantScores := make(map[int]float64, len(results))
+		for _, result := range results {
+			// Questions without the metric, e.g. ungraded by the judge model, are not compared
+			score, ok := metricValues(result.Metric)[metric]
+			if !ok {
+				continue
+			}
+			variantScores[result.QuestionIndex] = score
+			questions[result.QuestionIndex] = result.Question
+		}
+		scores = append(scores, variantScores)
+
+		report.Variants = append(report.Variants, &types.EvaluationVariantReport{
+			Name:     task.VariantName,
+			TaskID:   task.ID,
+			Status:   task.Status,
+			Total:    task.Total,
+			Finished: task.Finished,
+			Metrics:  metricValues(task.Metric),
+			Deltas:   make(map[string]float64),
+		})
+	}
+	if len(report.Variants) == 0 {
+		return report, nil
+	}
+
+	// Metric differences to the baseline, the first variant
+	baseline := report.Variants[0].Metrics
+	for _, variant := range report.Variants {
+		for name, value := range variant.Metrics {
+			variant.Deltas[name] = value - baseline[name]
+		}
+	}
+
+	// Per-question winners over the questions answered by every variant
+	for index := range scores[0] {
+		questionScores := make(map[string]float64, len(scores))
+		best, winner, tied := math.Inf(-1), -1, false
+		complete := true
+		for i, variantScores := range scores {
+			score, ok := variantScores[index]
+			if !ok {
+				complete = false
+				break
+			}
+			questionScores[report.Variants[i].Name] = score
+			switch {
+			case score > best+comparisonTieEpsilon:
+				best, winner, tied = score, i, false
+			case math.Abs(score-best) <= comparisonTieEpsilon:
+				tied = true
+			}
+		}
+		if !complete {
+			continue
+		}
+		report.Compared++
+		if tied {
+			report.Ties++
+			continue
+		}
+		report.Variants[winner].Wins++
+		report.Cases = append(report.Cases, &types.EvaluationComparisonCase{
+			QuestionIndex: index,
+			Question:      questions[index],
+			Winner:        report.Variants[winner].Name,
+			Scores:        questionScores,
+		})
+	}
+
+	// Show the cases in question order, limited to the requested number
+	slices.SortFunc(report.Cases, func(a, b *types.EvaluationComparisonCase) int {
+		return a.QuestionIndex - b.QuestionIndex
+	})
+	if len(report.Cases) > limit {
+		report.Cases = report.Cases[:limit]
+	}
+	return report, nil
+}
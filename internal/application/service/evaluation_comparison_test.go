@@ -0,0 +1,187 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	werrors "github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testVariant is a variant of a test comparison with its averaged NDCG@10 and per-question scores
+type testVariant struct {
+	name   string
+	ndcg10 float64
+	scores map[int]float64
+}
+
+// createTestComparison stores a comparison of tenant 1 with one finished task per variant
+func createTestComparison(t *testing.T, s *EvaluationService, variants ...testVariant) {
+	t.Helper()
+	ctx := evaluationTestContext(1)
+	comparison := &types.EvaluationComparison{ID: "comparison1", TenantID: 1, DatasetID: "dataset1"}
+	for i, variant := range variants {
+		task := &types.EvaluationTask{
+			ID:           fmt.Sprintf("task%d", i+1),
+			TenantID:     1,
+			DatasetID:    "dataset1",
+			Status:       types.EvaluationStatueSuccess,
+			Params:       types.JSON(`{}`),
+			ComparisonID: comparison.ID,
+			VariantName:  variant.name,
+			Total:        len(variant.scores),
+			Finished:     len(variant.scores),
+			Metric:       &types.MetricResult{RetrievalMetrics: types.RetrievalMetrics{NDCG10: variant.ndcg10}},
+		}
+		require.NoError(t, s.repo.CreateTask(ctx, task))
+		for index, score := range variant.scores {
+			require.NoError(t, s.repo.SaveQuestionResult(ctx, &types.EvaluationQuestionResult{
+				TaskID:        task.ID,
+				TenantID:      1,
+				QuestionIndex: index,
+				Question:      fmt.Sprintf("question %d", index),
+				Metric:        &types.MetricResult{RetrievalMetrics: types.RetrievalMetrics{NDCG10: score}},
+			}))
+		}
+		comparison.TaskIDs = append(comparison.TaskIDs, task.ID)
+	}
+	require.NoError(t, s.repo.CreateComparison(ctx, comparison))
+}
+
+func TestGetComparisonReport(t *testing.T) {
+	tests := []struct {
+		name     string
+		variants []testVariant
+		limit    int
+		compared int
+		ties     int
+		wins     []int
+		// Deltas of the averaged NDCG@10 to the first variant
+		deltas []float64
+		// Question indexes and winners of the returned cases
+		cases   []int
+		winners []string
+	}{
+		{
+			name: "win and delta",
+			variants: []testVariant{
+				{name: "base", ndcg10: 0.5, scores: map[int]float64{0: 0.5, 1: 0.8}},
+				{name: "rerank", ndcg10: 0.75, scores: map[int]float64{0: 0.9, 1: 0.6}},
+			},
+			compared: 2,
+			wins:     []int{1, 1},
+			deltas:   []float64{0, 0.25},
+			cases:    []int{0, 1},
+			winners:  []string{"rerank", "base"},
+		},
+		{
+			name: "tie within epsilon",
+			variants: []testVariant{
+				{name: "base", ndcg10: 0.5, scores: map[int]float64{0: 0.5}},
+				{name: "rerank", ndcg10: 0.5, scores: map[int]float64{0: 0.5 + comparisonTieEpsilon/2}},
+			},
+			compared: 1,
+			ties:     1,
+			wins:     []int{0, 0},
+			deltas:   []float64{0, 0},
+		},
+		{
+			name: "no tie beyond epsilon",
+			variants: []testVariant{
+				{name: "base", ndcg10: 0.5, scores: map[int]float64{0: 0.5}},
+				{name: "rerank", ndcg10: 0.5, scores: map[int]float64{0: 0.5 + 10*comparisonTieEpsilon}},
+			},
+			compared: 1,
+			wins:     []int{0, 1},
+			deltas:   []float64{0, 0},
+			cases:    []int{0},
+			winners:  []string{"rerank"},
+		},
+		{
+			name: "question missing in one variant",
+			variants: []testVariant{
+				{name: "base", ndcg10: 0.4, scores: map[int]float64{0: 0.2, 1: 0.6}},
+				{name: "rerank", ndcg10: 0.3, scores: map[int]float64{1: 0.3}},
+			},
+			compared: 1,
+			wins:     []int{1, 0},
+			deltas:   []float64{0, -0.1},
+			cases:    []int{1},
+			winners:  []string{"base"},
+		},
+		{
+			name: "deltas to the first variant",
+			variants: []testVariant{
+				{name: "base", ndcg10: 0.5, scores: map[int]float64{0: 0.1}},
+				{name: "small chunks", ndcg10: 0.3, scores: map[int]float64{0: 0.2}},
+				{name: "rerank", ndcg10: 0.9, scores: map[int]float64{0: 0.2}},
+			},
+			compared: 1,
+			ties:     1,
+			wins:     []int{0, 0, 0},
+			deltas:   []float64{0, -0.2, 0.4},
+		},
+		{
+			name: "limited cases",
+			variants: []testVariant{
+				{name: "base", ndcg10: 0.5, scores: map[int]float64{0: 0.1, 1: 0.1, 2: 0.9}},
+				{name: "rerank", ndcg10: 0.5, scores: map[int]float64{0: 0.9, 1: 0.9, 2: 0.1}},
+			},
+			limit:    2,
+			compared: 3,
+			wins:     []int{1, 2},
+			deltas:   []float64{0, 0},
+			cases:    []int{0, 1},
+			winners:  []string{"rerank", "rerank"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestEvaluationService(t, &fakeDatasetService{})
+			createTestComparison(t, s, tt.variants...)
+
+			report, err := s.GetComparisonReport(evaluationTestContext(1), "comparison1", "", tt.limit)
+			require.NoError(t, err)
+			assert.Equal(t, defaultComparisonMetric, report.Metric)
+			assert.Equal(t, tt.compared, report.Compared)
+			assert.Equal(t, tt.ties, report.Ties)
+
+			require.Len(t, report.Variants, len(tt.variants))
+			for i, variant := range report.Variants {
+				assert.Equal(t, tt.variants[i].name, variant.Name)
+				assert.Equal(t, tt.wins[i], variant.Wins, variant.Name)
+				assert.InDelta(t, tt.variants[i].ndcg10, variant.Metrics["ndcg10"], 1e-9, variant.Name)
+				assert.InDelta(t, tt.deltas[i], variant.Deltas["ndcg10"], 1e-9, variant.Name)
+			}
+
+			cases := make([]int, 0, len(report.Cases))
+			winners := make([]string, 0, len(report.Cases))
+			for _, c := range report.Cases {
+				cases = append(cases, c.QuestionIndex)
+				winners = append(winners, c.Winner)
+				assert.Equal(t, fmt.Sprintf("question %d", c.QuestionIndex), c.Question)
+				assert.Len(t, c.Scores, len(tt.variants))
+			}
+			assert.Equal(t, append([]int{}, tt.cases...), cases)
+			assert.Equal(t, append([]string{}, tt.winners...), winners)
+		})
+	}
+}
+
+func TestGetComparisonReportErrors(t *testing.T) {
+	s, _ := newTestEvaluationService(t, &fakeDatasetService{})
+	createTestComparison(t, s,
+		testVariant{name: "base", scores: map[int]float64{0: 0.5}},
+		testVariant{name: "rerank", scores: map[int]float64{0: 0.5}},
+	)
+
+	_, err := s.GetComparisonReport(evaluationTestContext(1), "comparison1", "unknown", 0)
+	assertAppError(t, err, werrors.ErrBadRequest)
+	_, err = s.GetComparisonReport(evaluationTestContext(1), "missing", "", 0)
+	assertAppError(t, err, werrors.ErrNotFound)
+	// Comparisons of other tenants are not found
+	_, err = s.GetComparisonReport(evaluationTestContext(2), "comparison1", "", 0)
+	assertAppError(t, err, werrors.ErrNotFound)
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/application/repository"
+	werrors "github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// fakeDatasetService serves the same dataset for every ID, the other methods are not implemented
+type fakeDatasetService struct {
+	interfaces.DatasetService
+	dataset []*types.QAPair
+	err     error
+}
+
+func (s *fakeDatasetService) GetDatasetByID(ctx context.Context, datasetID string) ([]*types.QAPair, error) {
+	return s.dataset, s.err
+}
+
+// fakeTenantRepository returns an empty tenant for every ID, the other methods are not implemented
+type fakeTenantRepository struct {
+	interfaces.TenantRepository
+}
+
+func (r *fakeTenantRepository) GetTenantByID(ctx context.Context, id uint64) (*types.Tenant, error) {
+	return &types.Tenant{ID: id}, nil
+}
+
+// newTestEvaluationService creates an evaluation service storing its tasks in an in-memory SQLite database
+// and enqueuing them in a miniredis server
+func newTestEvaluationService(t *testing.T, dataset *fakeDatasetService) (*EvaluationService, *asynq.Inspector) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(
+		&types.EvaluationTask{}, &types.EvaluationQuestionResult{}, &types.EvaluationComparison{},
+	))
+
+	mr := miniredis.RunT(t)
+	client := asynq.NewClient(asynq.RedisClientOpt{Addr: mr.Addr()})
+	inspector := asynq.NewInspector(asynq.RedisClientOpt{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = inspector.Close()
+	})
+	return &EvaluationService{
+		dataset:    dataset,
+		repo:       repository.NewEvaluationRepository(db),
+		tenantRepo: &fakeTenantRepository{},
+		task:       client,
+	}, inspector
+}
+
+func evaluationTestContext(tenantID uint64) context.Context {
+	return context.WithValue(context.Background(), types.TenantIDContextKey, tenantID)
+}
+
+// createTestEvaluationTask stores a task of tenant 1 with the results of the given questions
+func createTestEvaluationTask(
+	t *testing.T, s *EvaluationService, status types.EvaluationStatue, answered ...int,
+) *types.EvaluationTask {
+	t.Helper()
+	ctx := evaluationTestContext(1)
+	task := &types.EvaluationTask{
+		ID:        "task1",
+		TenantID:  1,
+		DatasetID: "dataset1",
+		Status:    status,
+		Params:    types.JSON(`{}`),
+	}
+	require.NoError(t, s.repo.CreateTask(ctx, task))
+	for _, index := range answered {
+		require.NoError(t, s.repo.SaveQuestionResult(ctx, &types.EvaluationQuestionResult{
+			TaskID:        task.ID,
+			TenantID:      1,
+			QuestionIndex: index,
+			Metric:        &types.MetricResult{},
+		}))
+	}
+	return task
+}
+
+func processTestEvaluation(t *testing.T, s *EvaluationService, taskID string) error {
+	t.Helper()
+	payload, err := json.Marshal(types.EvaluationPayload{TenantID: 1, TaskID: taskID})
+	require.NoError(t, err)
+	return s.ProcessEvaluation(context.Background(), asynq.NewTask(types.TypeEvaluation, payload))
+}
+
+func assertAppError(t *testing.T, err error, code werrors.ErrorCode) {
+	t.Helper()
+	var appErr *werrors.AppError
+	require.True(t, errors.As(err, &appErr), "unexpected error %v", err)
+	assert.Equal(t, code, appErr.Code)
+}
+
+func TestEvaluationTaskTenantIsolation(t *testing.T) {
+	s, _ := newTestEvaluationService(t, &fakeDatasetService{})
+	createTestEvaluationTask(t, s, types.EvaluationStatueFailed, 0)
+
+	owner := evaluationTestContext(1)
+	detail, err := s.EvaluationResult(owner, "task1")
+	require.NoError(t, err)
+	assert.Equal(t, "task1", detail.Task.ID)
+	_, err = s.GetQuestionResult(owner, "task1", 0)
+	require.NoError(t, err)
+	_, err = s.GetQuestionResult(owner, "task1", 1)
+	assertAppError(t, err, werrors.ErrNotFound)
+
+	// The tasks of other tenants are not found, nor are their question results
+	other := evaluationTestContext(2)
+	_, err = s.EvaluationResult(other, "task1")
+	assertAppError(t, err, werrors.ErrNotFound)
+	_, err = s.ListQuestionResults(other, "task1", nil)
+	assertAppError(t, err, werrors.ErrNotFound)
+	_, err = s.GetQuestionResult(other, "task1", 0)
+	assertAppError(t, err, werrors.ErrNotFound)
+	_, err = s.ResumeEvaluation(other, "task1")
+	assertAppError(t, err, werrors.ErrNotFound)
+
+	page, err := s.ListEvaluations(other, nil)
+	require.NoError(t, err)
+	assert.Zero(t, page.Total)
+}
+
+func TestProcessEvaluationAnsweredQuestions(t *testing.T) {
+	dataset := &fakeDatasetService{dataset: []*types.QAPair{{QID: 1}, {QID: 2}}}
+	s, _ := newTestEvaluationService(t, dataset)
+	createTestEvaluationTask(t, s, types.EvaluationStatuePending, 0, 1)
+	ctx := evaluationTestContext(1)
+
+	// Questions answered by a previous attempt are not evaluated again
+	require.NoError(t, processTestEvaluation(t, s, "task1"))
+	task, err := s.getTask(ctx, "task1")
+	require.NoError(t, err)
+	assert.Equal(t, types.EvaluationStatueSuccess, task.Status)
+	assert.Equal(t, 2, task.Total)
+	assert.Equal(t, 2, task.Finished)
+	assert.NotNil(t, task.EndTime)
+	assert.Empty(t, task.ErrMsg)
+
+	// A finished task is left as it is
+	dataset.err = errors.New("dataset unavailable")
+	require.NoError(t, processTestEvaluation(t, s, "task1"))
+	task, err = s.getTask(ctx, "task1")
+	require.NoError(t, err)
+	assert.Equal(t, types.EvaluationStatueSuccess, task.Status)
+}
+
+func TestProcessEvaluationFailedAndResumed(t *testing.T) {
+	dataset := &fakeDatasetService{err: errors.New("dataset unavailable")}
+	s, inspector := newTestEvaluationService(t, dataset)
+	createTestEvaluationTask(t, s, types.EvaluationStatuePending, 0)
+	ctx := evaluationTestContext(1)
+
+	// Only failed tasks can be resumed
+	_, err := s.ResumeEvaluation(ctx, "task1")
+	assertAppError(t, err, werrors.ErrBadRequest)
+
+	// Without asynq retries left the task fails
+	require.Error(t, processTestEvaluation(t, s, "task1"))
+	task, err := s.getTask(ctx, "task1")
+	require.NoError(t, err)
+	assert.Equal(t, types.EvaluationStatueFailed, task.Status)
+	assert.Equal(t, "dataset unavailable", task.ErrMsg)
+	assert.NotNil(t, task.EndTime)
+
+	detail, err := s.ResumeEvaluation(ctx, "task1")
+	require.NoError(t, err)
+	assert.Equal(t, types.EvaluationStatuePending, detail.Task.Status)
+	task, err = s.getTask(ctx, "task1")
+	require.NoError(t, err)
+	assert.Equal(t, types.EvaluationStatuePending, task.Status)
+	assert.Empty(t, task.ErrMsg)
+	assert.Nil(t, task.EndTime)
+	queue, err := inspector.GetQueueInfo("low")
+	require.NoError(t, err)
+	assert.Equal(t, 1, queue.Pending)
+
+	// The resumed task keeps the results of the answered questions
+	dataset.err = nil
+	dataset.dataset = []*types.QAPair{{QID: 1}}
+	require.NoError(t, processTestEvaluation(t, s, "task1"))
+	task, err = s.getTask(ctx, "task1")
+	require.NoError(t, err)
+	assert.Equal(t, types.EvaluationStatueSuccess, task.Status)
+	assert.Equal(t, 1, task.Finished)
+}
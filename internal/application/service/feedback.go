@@ -0,0 +1,365 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/Tencent/WeKnora/internal/application/repository"
+	werrors "github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/event"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"gorm.io/gorm"
+)
+
+const (
+	// feedbackExportBatchSize is the number of feedback read at once when exporting
+	feedbackExportBatchSize = 200
+	// maxFeedbackReasonLength is the maximum number of characters of the reason of a rating
+	maxFeedbackReasonLength = 64
+	// maxFeedbackCommentLength is the maximum number of characters of the comment of a rating
+	maxFeedbackCommentLength = 2000
+)
+
+// feedbackService collects the feedback on answers and aggregates it
+type feedbackService struct {
+	repo          interfaces.FeedbackRepository
+	sessionRepo   interfaces.SessionRepository
+	messageRepo   interfaces.MessageRepository
+	knowledgeRepo interfaces.KnowledgeRepository
+}
+
+// NewFeedbackService creates a new feedback service
+func NewFeedbackService(repo interfaces.FeedbackRepository,
+	sessionRepo interfaces.SessionRepository,
+	messageRepo interfaces.MessageRepository,
+	knowledgeRepo interfaces.KnowledgeRepository,
+) interfaces.FeedbackService {
+	return &feedbackService{
+		repo:          repo,
+		sessionRepo:   sessionRepo,
+		messageRepo:   messageRepo,
+		knowledgeRepo: knowledgeRepo,
+	}
+}
+
+// feedbackUserID returns the user giving feedback, empty for API keys
+func feedbackUserID(ctx context.Context) string {
+	if principal := types.PrincipalFromContext(ctx); principal != nil {
+		return principal.UserID
+	}
+	return ""
+}
+
+// getAnswer gets an assistant message of a session of the tenant
+func (s *feedbackService) getAnswer(
+	ctx context.Context, tenantID uint64, sessionID string, messageID string,
+) (*types.Message, error) {
+	if _, err := s.sessionRepo.Get(ctx, tenantID, sessionID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, werrors.NewNotFoundError("会话不存在")
+		}
+		return nil, err
+	}
+	message, err := s.messageRepo.GetMessage(ctx, sessionID, messageID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, werrors.NewNotFoundError("消息不存在")
+		}
+		return nil, err
+	}
+	if message.Role != "assistant" {
+		return nil, werrors.NewBadRequestError("只能评价回答消息")
+	}
+	return message, nil
+}
+
+// RateMessage sets the feedback of the caller on an assistant message, replacing the previous one.
+// The first downvote of an answer is published as a lifecycle event.
+func (s *feedbackService) RateMessage(ctx context.Context,
+	sessionID string, messageID string, request *types.MessageFeedbackRequest,
+) (*types.MessageFeedback, error) {
+	switch request.Rating {
+	case types.FeedbackRatingUp, types.FeedbackRatingDown:
+	case "":
+		if len(request.IrrelevantChunkIDs) == 0 {
+			return nil, werrors.NewBadRequestError("评价和不相关引用不能同时为空")
+		}
+	default:
+		return nil, werrors.NewBadRequestError("评价只能为up或down").WithDetails(string(request.Rating))
+	}
+	if utf8.RuneCountInString(request.Reason) > maxFeedbackReasonLength {
+		return nil, werrors.NewBadRequestError("评价原因过长")
+	}
+	if utf8.RuneCountInString(request.Comment) > maxFeedbackCommentLength {
+		return nil, werrors.NewBadRequestError("评价内容过长")
+	}
+
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	message, err := s.getAnswer(ctx, tenantID, sessionID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	references, err := s.feedbackReferences(ctx, tenantID, message.KnowledgeReferences, request.IrrelevantChunkIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	feedback := &types.MessageFeedback{
+		TenantID:    tenantID,
+		SessionID:   sessionID,
+		MessageID:   messageID,
+		UserID:      feedbackUserID(ctx),
+		Rating:      request.Rating,
+		Reason:      request.Reason,
+		Comment:     request.Comment,
+		Query:       s.questionOf(ctx, message),
+		Answer:      message.Content,
+		ChatModelID: message.ChatModelID,
+		References:  references,
+	}
+	previous, err := s.repo.Get(ctx, tenantID, messageID, feedback.UserID)
+	if err != nil && !errors.Is(err, repository.ErrFeedbackNotFound) {
+		return nil, err
+	}
+	if err := s.repo.Upsert(ctx, feedback); err != nil {
+		logger.Errorf(ctx, "Failed to save feedback on message %s: %v", messageID, err)
+		return nil, err
+	}
+	logger.Infof(ctx, "Feedback %d saved on message %s, rating: %s, irrelevant references: %d",
+		feedback.ID, messageID, feedback.Rating, len(request.IrrelevantChunkIDs))
+
+	if feedback.Rating == types.FeedbackRatingDown && (previous == nil || previous.Rating != types.FeedbackRatingDown) {
+		if err := event.Emit(ctx, event.Event{
+			Type:      event.EventMessageNegativeRating,
+			SessionID: sessionID,
+			RequestID: message.RequestID,
+			Data: event.MessageRatingData{
+				SessionID: sessionID,
+				MessageID: messageID,
+				UserID:    feedback.UserID,
+				Rating:    string(feedback.Rating),
+				Reason:    feedback.Reason,
+				Comment:   feedback.Comment,
+			},
+		}); err != nil {
+			logger.Warnf(ctx, "Failed to publish negative rating event: %v", err)
+		}
+	}
+	return feedback, nil
+}
+
+// questionOf returns the question an answer replies to, the user message preceding it
+func (s *feedbackService) questionOf(ctx context.Context, answer *types.Message) string {
+	messages, err := s.messageRepo.GetMessagesBySessionBeforeTime(ctx, answer.SessionID, answer.CreatedAt, 1)
+	if err != nil {
+		logger.Warnf(ctx, "Failed to get the question of message %s: %v", answer.ID, err)
+		return ""
+	}
+	if len(messages) == 0 || messages[0].Role != "user" {
+		return ""
+	}
+	return messages[0].Content
+}
+
+// feedbackReferences copies the references of an answer with their knowledge bases,
+// marking the irrelevant ones. Every irrelevant chunk must be a reference of the answer.
+func (s *feedbackService) feedbackReferences(ctx context.Context,
+	tenantID uint64, references types.References, irrelevantChunkIDs []string,
+) ([]*types.FeedbackReference, error) {
+	result := make([]*types.FeedbackReference, 0, len(references))
+	var knowledgeIDs []string
+	for _, reference := range references {
+		if reference == nil || slices.ContainsFunc(result, func(r *types.FeedbackReference) bool {
+			return r.ChunkID == reference.ID
+		}) {
+			continue
+		}
+		result = append(result, &types.FeedbackReference{
+			ChunkID:        reference.ID,
+			KnowledgeID:    reference.KnowledgeID,
+			KnowledgeTitle: reference.KnowledgeTitle,
+			Content:        reference.Content,
+			Irrelevant:     slices.Contains(irrelevantChunkIDs, reference.ID),
+		})
+		if reference.KnowledgeID != "" && !slices.Contains(knowledgeIDs, reference.KnowledgeID) {
+			knowledgeIDs = append(knowledgeIDs, reference.KnowledgeID)
+		}
+	}
+	for _, chunkID := range irrelevantChunkIDs {
+		if !slices.ContainsFunc(result, func(r *types.FeedbackReference) bool { return r.ChunkID == chunkID }) {
+			return nil, werrors.NewBadRequestError("不相关的引用不属于该回答").WithDetails(chunkID)
+		}
+	}
+
+	if len(knowledgeIDs) == 0 {
+		return result, nil
+	}
+	knowledgeList, err := s.knowledgeRepo.GetKnowledgeBatch(ctx, tenantID, knowledgeIDs)
+	if err != nil {
+		// The feedback is kept, the references are left out of the knowledge base analytics
+		logger.Warnf(ctx, "Failed to get the knowledge bases of the references: %v", err)
+		return result, nil
+	}
+	knowledgeBases := make(map[string]string, len(knowledgeList))
+	for _, knowledge := range knowledgeList {
+		knowledgeBases[knowledge.ID] = knowledge.KnowledgeBaseID
+	}
+	for _, reference := range result {
+		reference.KnowledgeBaseID = knowledgeBases[reference.KnowledgeID]
+	}
+	return result, nil
+}
+
+// GetMessageFeedback gets the feedback of the caller on an assistant message
+func (s *feedbackService) GetMessageFeedback(ctx context.Context,
+	sessionID string, messageID string,
+) (*types.MessageFeedback, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	if _, err := s.getAnswer(ctx, tenantID, sessionID, messageID); err != nil {
+		return nil, err
+	}
+	feedback, err := s.repo.Get(ctx, tenantID, messageID, feedbackUserID(ctx))
+	if err != nil {
+		if errors.Is(err, repository.ErrFeedbackNotFound) {
+			return nil, werrors.NewNotFoundError("尚未评价该回答")
+		}
+		return nil, err
+	}
+	return feedback, nil
+}
+
+// DeleteMessageFeedback withdraws the feedback of the caller on an assistant message
+func (s *feedbackService) DeleteMessageFeedback(ctx context.Context, sessionID string, messageID string) error {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	if _, err := s.getAnswer(ctx, tenantID, sessionID, messageID); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, tenantID, messageID, feedbackUserID(ctx)); err != nil {
+		if errors.Is(err, repository.ErrFeedbackNotFound) {
+			return werrors.NewNotFoundError("尚未评价该回答")
+		}
+		return err
+	}
+	logger.Infof(ctx, "Feedback on message %s withdrawn", messageID)
+	return nil
+}
+
+// GetFeedbackSummary aggregates the feedback of the current tenant, by knowledge base when groupBy is empty
+func (s *feedbackService) GetFeedbackSummary(ctx context.Context,
+	filter *types.FeedbackFilter, groupBy string,
+) (*types.FeedbackSummary, error) {
+	switch groupBy {
+	case "":
+		groupBy = types.FeedbackGroupByKnowledgeBase
+	case types.FeedbackGroupByKnowledgeBase, types.FeedbackGroupByKnowledge,
+		types.FeedbackGroupByModel, types.FeedbackGroupByDay:
+	default:
+		return nil, werrors.NewBadRequestError("不支持的分组方式").WithDetails(groupBy)
+	}
+
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	totals, err := s.repo.Summary(ctx, tenantID, filter, "")
+	if err != nil {
+		logger.Errorf(ctx, "Failed to summarize the feedback of tenant %d: %v", tenantID, err)
+		return nil, err
+	}
+	groups, err := s.repo.Summary(ctx, tenantID, filter, groupBy)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to summarize the feedback of tenant %d by %s: %v", tenantID, groupBy, err)
+		return nil, err
+	}
+	for _, stats := range slices.Concat(groups, totals) {
+		if rated := stats.Up + stats.Down; rated > 0 {
+			stats.Satisfaction = float64(stats.Up) / float64(rated)
+		}
+	}
+	return &types.FeedbackSummary{GroupBy: groupBy, Totals: totals[0], Groups: groups}, nil
+}
+
+// ListWorstQueries lists the questions of the current tenant whose answers were rated down the most
+func (s *feedbackService) ListWorstQueries(ctx context.Context,
+	filter *types.FeedbackFilter, limit int,
+) ([]*types.FeedbackQueryStats, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	queries, err := s.repo.WorstQueries(ctx, tenantID, filter, limit)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to list the worst queries of tenant %d: %v", tenantID, err)
+		return nil, err
+	}
+	return queries, nil
+}
+
+// ListDownvotedChunks lists the chunks of the current tenant most often referenced by downvoted answers
+// or marked not relevant
+func (s *feedbackService) ListDownvotedChunks(ctx context.Context,
+	filter *types.FeedbackFilter, limit int,
+) ([]*types.FeedbackChunkStats, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	chunks, err := s.repo.DownvotedChunks(ctx, tenantID, filter, limit)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to list the downvoted chunks of tenant %d: %v", tenantID, err)
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// ExportNegativeFeedback writes the answers of the current tenant rated down to w as JSON lines
+// of an evaluation dataset, oldest first
+func (s *feedbackService) ExportNegativeFeedback(ctx context.Context,
+	filter *types.FeedbackFilter, w io.Writer,
+) error {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	encoder := json.NewEncoder(w)
+	var afterID uint64
+	for {
+		feedbackList, err := s.repo.ListNegativeAfter(ctx, tenantID, filter, afterID, feedbackExportBatchSize)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to export the negative feedback of tenant %d: %v", tenantID, err)
+			return err
+		}
+		for _, feedback := range feedbackList {
+			if feedback.Query == "" {
+				continue
+			}
+			if err := encoder.Encode(newFeedbackEvaluationRecord(feedback)); err != nil {
+				return err
+			}
+		}
+		if len(feedbackList) < feedbackExportBatchSize {
+			return nil
+		}
+		afterID = feedbackList[len(feedbackList)-1].ID
+	}
+}
+
+// newFeedbackEvaluationRecord builds the evaluation dataset line of a downvoted answer
+func newFeedbackEvaluationRecord(feedback *types.MessageFeedback) *types.FeedbackEvaluationRecord {
+	record := &types.FeedbackEvaluationRecord{
+		Question:    feedback.Query,
+		Passages:    []string{},
+		RatedAnswer: feedback.Answer,
+		Reason:      feedback.Reason,
+		Comment:     feedback.Comment,
+		SessionID:   feedback.SessionID,
+		MessageID:   feedback.MessageID,
+		ChatModelID: feedback.ChatModelID,
+		RatedAt:     feedback.UpdatedAt,
+	}
+	for _, reference := range feedback.References {
+		if reference.Content == "" {
+			continue
+		}
+		if reference.Irrelevant {
+			record.IrrelevantPassages = append(record.IrrelevantPassages, reference.Content)
+			continue
+		}
+		record.Passages = append(record.Passages, reference.Content)
+	}
+	return record
+}
@@ -18,6 +18,8 @@ import (
 
 	"github.com/Tencent/WeKnora/docreader/client"
 	"github.com/Tencent/WeKnora/docreader/proto"
+	"github.com/Tencent/WeKnora/internal/application/repository"
+	"github.com/Tencent/WeKnora/internal/application/service/docparser"
 	"github.com/Tencent/WeKnora/internal/application/service/retriever"
 	"github.com/Tencent/WeKnora/internal/config"
 	werrors "github.com/Tencent/WeKnora/internal/errors"
@@ -69,6 +71,7 @@ type knowledgeService struct {
 	task            *asynq.Client
 	graphEngine     interfaces.RetrieveGraphRepository
 	redisClient     *redis.Client
+	answerCache     interfaces.AnswerCacheService
 }
 
 const (
@@ -94,6 +97,7 @@ func NewKnowledgeService(
 	graphEngine interfaces.RetrieveGraphRepository,
 	retrieveEngine interfaces.RetrieveEngineRegistry,
 	redisClient *redis.Client,
+	answerCache interfaces.AnswerCacheService,
 ) (interfaces.KnowledgeService, error) {
 	return &knowledgeService{
 		config:          config,
@@ -111,6 +115,7 @@ func NewKnowledgeService(
 		graphEngine:     graphEngine,
 		retrieveEngine:  retrieveEngine,
 		redisClient:     redisClient,
+		answerCache:     answerCache,
 	}, nil
 }
 
@@ -354,7 +359,7 @@ func (s *knowledgeService) CreateKnowledgeFromFile(ctx context.Context,
 
 // CreateKnowledgeFromURL creates a knowledge entry from a URL source
 func (s *knowledgeService) CreateKnowledgeFromURL(ctx context.Context,
-	kbID string, url string, enableMultimodel *bool, title string,
+	kbID string, url string, enableMultimodel *bool, title string, options *types.URLKnowledgeOptions,
 ) (*types.Knowledge, error) {
 	logger.Info(ctx, "Start creating knowledge from URL")
 	logger.Infof(ctx, "Knowledge base ID: %s, URL: %s", kbID, url)
@@ -373,6 +378,12 @@ func (s *knowledgeService) CreateKnowledgeFromURL(ctx context.Context,
 		logger.Error(ctx, "Invalid or unsafe URL format")
 		return nil, ErrInvalidURL
 	}
+	if options == nil {
+		options = &types.URLKnowledgeOptions{}
+	}
+	if err := validateRefreshSchedule(options.RefreshSchedule); err != nil {
+		return nil, err
+	}
 
 	// Check if URL already exists in the knowledge base
 	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
@@ -421,6 +432,11 @@ func (s *knowledgeService) CreateKnowledgeFromURL(ctx context.Context,
 		CreatedAt:        time.Now(),
 		UpdatedAt:        time.Now(),
 		EmbeddingModelID: kb.EmbeddingModelID,
+		RefreshSchedule:  options.RefreshSchedule,
+	}
+	if options.Crawl != nil {
+		crawlConfig := options.Crawl.WithDefaults()
+		knowledge.CrawlConfig = &crawlConfig
 	}
 
 	// Save knowledge record
@@ -438,40 +454,17 @@ func (s *knowledgeService) CreateKnowledgeFromURL(ctx context.Context,
 	} else {
 		enableMultimodelValue = kb.IsMultimodalEnabled()
 	}
-
-	// Check question generation config
-	enableQuestionGeneration := false
-	questionCount := 3 // default
-	if kb.QuestionGenerationConfig != nil && kb.QuestionGenerationConfig.Enabled {
-		enableQuestionGeneration = true
-		if kb.QuestionGenerationConfig.QuestionCount > 0 {
-			questionCount = kb.QuestionGenerationConfig.QuestionCount
-		}
-	}
-
-	taskPayload := types.DocumentProcessPayload{
-		TenantID:                 tenantID,
-		KnowledgeID:              knowledge.ID,
-		KnowledgeBaseID:          kbID,
-		URL:                      url,
-		EnableMultimodel:         enableMultimodelValue,
-		EnableQuestionGeneration: enableQuestionGeneration,
-		QuestionCount:            questionCount,
-	}
-
-	payloadBytes, err := json.Marshal(taskPayload)
-	if err != nil {
-		logger.Errorf(ctx, "Failed to marshal URL process task payload: %v", err)
+	if err := s.enqueueURLProcessTask(ctx, kb, knowledge, enableMultimodelValue, false); err != nil {
+		logger.Errorf(ctx, "Failed to enqueue URL process task: %v", err)
 		return knowledge, nil
 	}
 
-	task := asynq.NewTask(types.TypeDocumentProcess, payloadBytes, asynq.Queue("default"))
-	info, err := s.task.Enqueue(task)
-	if err != nil {
-		logger.Errorf(ctx, "Failed to enqueue URL process task: %v", err)
-		return knowledge, nil
+	// The pages linked from the URL are imported by a crawl task
+	if knowledge.CrawlConfig != nil {
+		if err := s.enqueueURLCrawlTask(ctx, knowledge); err != nil {
+			logger.Errorf(ctx, "Failed to enqueue URL crawl task: %v", err)
+		}
 	}
-	logger.Infof(ctx, "Enqueued URL process task: id=%s queue=%s knowledge_id=%s", info.ID, info.Queue, knowledge.ID)
 
 	logger.Infof(ctx, "Knowledge from URL created successfully, ID: %s", knowledge.ID)
 	return knowledge, nil
@@ -799,6 +792,7 @@ func (s *knowledgeService) DeleteKnowledge(ctx context.Context, id string) error
 	if err = wg.Wait(); err != nil {
 		return err
 	}
+	s.invalidateAnswerCache(ctx, knowledge.KnowledgeBaseID)
 	// Delete the knowledge entry itself from the database
 	return s.repo.DeleteKnowledge(ctx, ctx.Value(types.TenantIDContextKey).(uint64), id)
 }
@@ -911,6 +905,9 @@ func (s *knowledgeService) DeleteKnowledgeList(ctx context.Context, ids []string
 	if err = wg.Wait(); err != nil {
 		return err
 	}
+	for _, knowledge := range knowledgeList {
+		s.invalidateAnswerCache(ctx, knowledge.KnowledgeBaseID)
+	}
 	// 5. Delete the knowledge entry itself from the database
 	return s.repo.DeleteKnowledgeList(ctx, tenantInfo.ID, ids)
 }
@@ -1129,159 +1126,10 @@ func (s *knowledgeService) processChunks(ctx context.Context,
 	logger.Infof(ctx, "[DocReader] ========== 解析结果概览结束 ==========")
 
 	// Create chunk objects from proto chunks
-	maxSeq := 0
-
-	// 统计图片相关的子Chunk数量，用于扩展insertChunks的容量
-	imageChunkCount := 0
-	for _, chunkData := range chunks {
-		if len(chunkData.Images) > 0 {
-			// 为每个图片的OCR和Caption分别创建一个Chunk
-			imageChunkCount += len(chunkData.Images) * 2
-		}
-		if int(chunkData.Seq) > maxSeq {
-			maxSeq = int(chunkData.Seq)
-		}
-	}
-
-	// 重新分配容量，考虑图片相关的Chunk
-	insertChunks := make([]*types.Chunk, 0, len(chunks)+imageChunkCount)
-
-	for _, chunkData := range chunks {
-		if strings.TrimSpace(chunkData.Content) == "" {
-			continue
-		}
-
-		// 创建主文本Chunk
-		textChunk := &types.Chunk{
-			ID:              uuid.New().String(),
-			TenantID:        knowledge.TenantID,
-			KnowledgeID:     knowledge.ID,
-			KnowledgeBaseID: knowledge.KnowledgeBaseID,
-			Content:         chunkData.Content,
-			ChunkIndex:      int(chunkData.Seq),
-			IsEnabled:       true,
-			CreatedAt:       time.Now(),
-			UpdatedAt:       time.Now(),
-			StartAt:         int(chunkData.Start),
-			EndAt:           int(chunkData.End),
-			ChunkType:       types.ChunkTypeText,
-		}
-		var chunkImages []types.ImageInfo
-		insertChunks = append(insertChunks, textChunk)
-
-		// 处理图片信息
-		if len(chunkData.Images) > 0 {
-			logger.GetLogger(ctx).Infof("Processing %d images in chunk #%d", len(chunkData.Images), chunkData.Seq)
-
-			for i, img := range chunkData.Images {
-				// 保存图片信息到文本Chunk
-				imageInfo := types.ImageInfo{
-					URL:         img.Url,
-					OriginalURL: img.OriginalUrl,
-					StartPos:    int(img.Start),
-					EndPos:      int(img.End),
-					OCRText:     img.OcrText,
-					Caption:     img.Caption,
-				}
-				chunkImages = append(chunkImages, imageInfo)
-
-				// 将ImageInfo序列化为JSON
-				imageInfoJSON, err := json.Marshal([]types.ImageInfo{imageInfo})
-				if err != nil {
-					logger.GetLogger(ctx).WithField("error", err).Errorf("Failed to marshal image info to JSON")
-					continue
-				}
-
-				// 如果有OCR文本，创建OCR Chunk
-				if img.OcrText != "" {
-					ocrChunk := &types.Chunk{
-						ID:              uuid.New().String(),
-						TenantID:        knowledge.TenantID,
-						KnowledgeID:     knowledge.ID,
-						KnowledgeBaseID: knowledge.KnowledgeBaseID,
-						Content:         img.OcrText,
-						ChunkIndex:      maxSeq + i*100 + 1, // 使用不冲突的索引方式
-						IsEnabled:       true,
-						CreatedAt:       time.Now(),
-						UpdatedAt:       time.Now(),
-						StartAt:         int(img.Start),
-						EndAt:           int(img.End),
-						ChunkType:       types.ChunkTypeImageOCR,
-						ParentChunkID:   textChunk.ID,
-						ImageInfo:       string(imageInfoJSON),
-					}
-					insertChunks = append(insertChunks, ocrChunk)
-					logger.GetLogger(ctx).Infof("Created OCR chunk for image %d in chunk #%d", i, chunkData.Seq)
-				}
-
-				// 如果有图片描述，创建Caption Chunk
-				if img.Caption != "" {
-					captionChunk := &types.Chunk{
-						ID:              uuid.New().String(),
-						TenantID:        knowledge.TenantID,
-						KnowledgeID:     knowledge.ID,
-						KnowledgeBaseID: knowledge.KnowledgeBaseID,
-						Content:         img.Caption,
-						ChunkIndex:      maxSeq + i*100 + 2, // 使用不冲突的索引方式
-						IsEnabled:       true,
-						CreatedAt:       time.Now(),
-						UpdatedAt:       time.Now(),
-						StartAt:         int(img.Start),
-						EndAt:           int(img.End),
-						ChunkType:       types.ChunkTypeImageCaption,
-						ParentChunkID:   textChunk.ID,
-						ImageInfo:       string(imageInfoJSON),
-					}
-					insertChunks = append(insertChunks, captionChunk)
-					logger.GetLogger(ctx).Infof("Created caption chunk for image %d in chunk #%d", i, chunkData.Seq)
-				}
-			}
-
-			imageInfoJSON, err := json.Marshal(chunkImages)
-			if err != nil {
-				logger.GetLogger(ctx).WithField("error", err).Errorf("Failed to marshal image info to JSON")
-				continue
-			}
-			textChunk.ImageInfo = string(imageInfoJSON)
-		}
-	}
-
-	// Sort chunks by index for proper ordering
-	sort.Slice(insertChunks, func(i, j int) bool {
-		return insertChunks[i].ChunkIndex < insertChunks[j].ChunkIndex
-	})
-
-	// 仅为文本类型的Chunk设置前后关系
-	textChunks := make([]*types.Chunk, 0, len(chunks))
-	for _, chunk := range insertChunks {
-		if chunk.ChunkType == types.ChunkTypeText {
-			textChunks = append(textChunks, chunk)
-		}
-	}
-
-	// 设置文本Chunk之间的前后关系
-	for i, chunk := range textChunks {
-		if i > 0 {
-			textChunks[i-1].NextChunkID = chunk.ID
-		}
-		if i < len(textChunks)-1 {
-			textChunks[i+1].PreChunkID = chunk.ID
-		}
-	}
+	insertChunks, textChunks := s.buildKnowledgeChunks(ctx, knowledge, chunks)
 
 	// Create index information for each chunk (without generated questions for now)
-	indexInfoList := make([]*types.IndexInfo, 0, len(insertChunks))
-	for _, chunk := range insertChunks {
-		// Add original chunk content to index
-		indexInfoList = append(indexInfoList, &types.IndexInfo{
-			Content:         chunk.Content,
-			SourceID:        chunk.ID,
-			SourceType:      types.ChunkSourceType,
-			ChunkID:         chunk.ID,
-			KnowledgeID:     knowledge.ID,
-			KnowledgeBaseID: knowledge.KnowledgeBaseID,
-		})
-	}
+	indexInfoList := chunkIndexInfoList(knowledge, insertChunks)
 
 	// Initialize retrieval engine
 
@@ -1406,6 +1254,7 @@ func (s *knowledgeService) processChunks(ctx context.Context,
 	if err := s.repo.UpdateKnowledge(ctx, knowledge); err != nil {
 		logger.GetLogger(ctx).WithField("error", err).Errorf("processChunks update knowledge failed")
 	}
+	s.invalidateAnswerCache(ctx, knowledge.KnowledgeBaseID)
 
 	// Enqueue question generation task if enabled (async, non-blocking)
 	if options.EnableQuestionGeneration && len(textChunks) > 0 {
@@ -1416,7 +1265,7 @@ func (s *knowledgeService) processChunks(ctx context.Context,
 		if questionCount > 10 {
 			questionCount = 10
 		}
-		s.enqueueQuestionGenerationTask(ctx, knowledge.KnowledgeBaseID, knowledge.ID, questionCount)
+		s.enqueueQuestionGenerationTask(ctx, knowledge.KnowledgeBaseID, knowledge.ID, questionCount, nil)
 	}
 
 	// Enqueue summary generation task (async, non-blocking)
@@ -1432,6 +1281,158 @@ func (s *knowledgeService) processChunks(ctx context.Context,
 	logger.GetLogger(ctx).Infof("processChunks successfully")
 }
 
+// buildKnowledgeChunks converts the docreader chunks of a knowledge into chunk rows.
+// Images get OCR and caption child chunks, and text chunks are linked to their neighbours.
+// It returns all chunks sorted by index together with the text chunks.
+func (s *knowledgeService) buildKnowledgeChunks(ctx context.Context,
+	knowledge *types.Knowledge, chunks []*proto.Chunk,
+) ([]*types.Chunk, []*types.Chunk) {
+	maxSeq := 0
+
+	// 统计图片相关的子Chunk数量，用于扩展insertChunks的容量
+	imageChunkCount := 0
+	for _, chunkData := range chunks {
+		if len(chunkData.Images) > 0 {
+			// 为每个图片的OCR和Caption分别创建一个Chunk
+			imageChunkCount += len(chunkData.Images) * 2
+		}
+		if int(chunkData.Seq) > maxSeq {
+			maxSeq = int(chunkData.Seq)
+		}
+	}
+
+	// 重新分配容量，考虑图片相关的Chunk
+	insertChunks := make([]*types.Chunk, 0, len(chunks)+imageChunkCount)
+
+	for _, chunkData := range chunks {
+		if strings.TrimSpace(chunkData.Content) == "" {
+			continue
+		}
+
+		// 创建主文本Chunk
+		textChunk := &types.Chunk{
+			ID:              uuid.New().String(),
+			TenantID:        knowledge.TenantID,
+			KnowledgeID:     knowledge.ID,
+			KnowledgeBaseID: knowledge.KnowledgeBaseID,
+			Content:         chunkData.Content,
+			ChunkIndex:      int(chunkData.Seq),
+			IsEnabled:       true,
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
+			StartAt:         int(chunkData.Start),
+			EndAt:           int(chunkData.End),
+			ChunkType:       types.ChunkTypeText,
+		}
+		var chunkImages []types.ImageInfo
+		insertChunks = append(insertChunks, textChunk)
+
+		// 处理图片信息
+		if len(chunkData.Images) > 0 {
+			logger.GetLogger(ctx).Infof("Processing %d images in chunk #%d", len(chunkData.Images), chunkData.Seq)
+
+			for i, img := range chunkData.Images {
+				// 保存图片信息到文本Chunk
+				imageInfo := types.ImageInfo{
+					URL:         img.Url,
+					OriginalURL: img.OriginalUrl,
+					StartPos:    int(img.Start),
+					EndPos:      int(img.End),
+					OCRText:     img.OcrText,
+					Caption:     img.Caption,
+				}
+				chunkImages = append(chunkImages, imageInfo)
+
+				// 将ImageInfo序列化为JSON
+				imageInfoJSON, err := json.Marshal([]types.ImageInfo{imageInfo})
+				if err != nil {
+					logger.GetLogger(ctx).WithField("error", err).Errorf("Failed to marshal image info to JSON")
+					continue
+				}
+
+				// 如果有OCR文本，创建OCR Chunk
+				if img.OcrText != "" {
+					ocrChunk := &types.Chunk{
+						ID:              uuid.New().String(),
+						TenantID:        knowledge.TenantID,
+						KnowledgeID:     knowledge.ID,
+						KnowledgeBaseID: knowledge.KnowledgeBaseID,
+						Content:         img.OcrText,
+						ChunkIndex:      maxSeq + i*100 + 1, // 使用不冲突的索引方式
+						IsEnabled:       true,
+						CreatedAt:       time.Now(),
+						UpdatedAt:       time.Now(),
+						StartAt:         int(img.Start),
+						EndAt:           int(img.End),
+						ChunkType:       types.ChunkTypeImageOCR,
+						ParentChunkID:   textChunk.ID,
+						ImageInfo:       string(imageInfoJSON),
+					}
+					insertChunks = append(insertChunks, ocrChunk)
+					logger.GetLogger(ctx).Infof("Created OCR chunk for image %d in chunk #%d", i, chunkData.Seq)
+				}
+
+				// 如果有图片描述，创建Caption Chunk
+				if img.Caption != "" {
+					captionChunk := &types.Chunk{
+						ID:              uuid.New().String(),
+						TenantID:        knowledge.TenantID,
+						KnowledgeID:     knowledge.ID,
+						KnowledgeBaseID: knowledge.KnowledgeBaseID,
+						Content:         img.Caption,
+						ChunkIndex:      maxSeq + i*100 + 2, // 使用不冲突的索引方式
+						IsEnabled:       true,
+						CreatedAt:       time.Now(),
+						UpdatedAt:       time.Now(),
+						StartAt:         int(img.Start),
+						EndAt:           int(img.End),
+						ChunkType:       types.ChunkTypeImageCaption,
+						ParentChunkID:   textChunk.ID,
+						ImageInfo:       string(imageInfoJSON),
+					}
+					insertChunks = append(insertChunks, captionChunk)
+					logger.GetLogger(ctx).Infof("Created caption chunk for image %d in chunk #%d", i, chunkData.Seq)
+				}
+			}
+
+			imageInfoJSON, err := json.Marshal(chunkImages)
+			if err != nil {
+				logger.GetLogger(ctx).WithField("error", err).Errorf("Failed to marshal image info to JSON")
+				continue
+			}
+			textChunk.ImageInfo = string(imageInfoJSON)
+		}
+	}
+
+	// Sort chunks by index for proper ordering
+	sort.Slice(insertChunks, func(i, j int) bool {
+		return insertChunks[i].ChunkIndex < insertChunks[j].ChunkIndex
+	})
+
+	// 仅为文本类型的Chunk设置前后关系
+	textChunks := make([]*types.Chunk, 0, len(chunks))
+	for _, chunk := range insertChunks {
+		if chunk.ChunkType == types.ChunkTypeText {
+			textChunks = append(textChunks, chunk)
+		}
+	}
+
+	// 设置文本Chunk之间的前后关系
+	for i, chunk := range textChunks {
+		if i > 0 {
+			textChunks[i-1].NextChunkID = chunk.ID
+		}
+		if i < len(textChunks)-1 {
+			textChunks[i+1].PreChunkID = chunk.ID
+		}
+	}
+
+	for _, chunk := range insertChunks {
+		chunk.ContentHash = types.CalculateChunkContentHash(chunk.ChunkType, chunk.Content)
+	}
+	return insertChunks, textChunks
+}
+
 // GetSummary generates a summary for knowledge content using an AI model
 func (s *knowledgeService) getSummary(ctx context.Context,
 	summaryModel chat.Chat, knowledge *types.Knowledge, chunks []*types.Chunk,
@@ -1531,7 +1532,7 @@ func (s *knowledgeService) getSummary(ctx context.Context,
 
 // enqueueQuestionGenerationTask enqueues an async task for question generation
 func (s *knowledgeService) enqueueQuestionGenerationTask(ctx context.Context,
-	kbID, knowledgeID string, questionCount int,
+	kbID, knowledgeID string, questionCount int, chunkIDs []string,
 ) {
 	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
 	payload := types.QuestionGenerationPayload{
@@ -1539,6 +1540,7 @@ func (s *knowledgeService) enqueueQuestionGenerationTask(ctx context.Context,
 		KnowledgeBaseID: kbID,
 		KnowledgeID:     knowledgeID,
 		QuestionCount:   questionCount,
+		ChunkIDs:        chunkIDs,
 	}
 
 	payloadBytes, err := json.Marshal(payload)
@@ -1737,14 +1739,7 @@ func (s *knowledgeService) ProcessSummaryGeneration(ctx context.Context, t *asyn
 			return fmt.Errorf("failed to get embedding model: %w", err)
 		}
 
-		indexInfo := []*types.IndexInfo{{
-			Content:         summaryChunk.Content,
-			SourceID:        summaryChunk.ID,
-			SourceType:      types.ChunkSourceType,
-			ChunkID:         summaryChunk.ID,
-			KnowledgeID:     knowledge.ID,
-			KnowledgeBaseID: knowledge.KnowledgeBaseID,
-		}}
+		indexInfo := chunkIndexInfoList(knowledge, []*types.Chunk{summaryChunk})
 
 		if err := retrieveEngine.BatchIndex(ctx, embeddingModel, indexInfo); err != nil {
 			logger.Errorf(ctx, "Failed to index summary chunk: %v", err)
@@ -1848,9 +1843,19 @@ func (s *knowledgeService) ProcessQuestionGeneration(ctx context.Context, t *asy
 		questionCount = 10
 	}
 
+	// Only the requested chunks get questions, the others still serve as context
+	requested := make(map[string]bool, len(payload.ChunkIDs))
+	for _, id := range payload.ChunkIDs {
+		requested[id] = true
+	}
+
 	// Generate questions for each chunk with context
 	var indexInfoList []*types.IndexInfo
+	filterFields := types.NewIndexFilterFields(knowledge)
 	for i, chunk := range textChunks {
+		if len(requested) > 0 && !requested[chunk.ID] {
+			continue
+		}
 		// Build context from adjacent chunks
 		var prevContent, nextContent string
 		if i > 0 {
@@ -1911,6 +1916,7 @@ func (s *knowledgeService) ProcessQuestionGeneration(ctx context.Context, t *asy
 				ChunkID:         chunk.ID,
 				KnowledgeID:     knowledge.ID,
 				KnowledgeBaseID: knowledge.KnowledgeBaseID,
+				FilterFields:    filterFields,
 			})
 		}
 		logger.Debugf(ctx, "Generated %d questions for chunk %s", len(questions), chunk.ID)
@@ -2175,7 +2181,8 @@ func isValidFileType(filename string) bool {
 	case "pdf", "txt", "docx", "doc", "md", "markdown", "png", "jpg", "jpeg", "gif", "csv", "xlsx", "xls":
 		return true
 	default:
-		return false
+		// 注册了Go解析器的文件类型
+		return docparser.Supports(getFileType(filename))
 	}
 }
 
@@ -2261,7 +2268,7 @@ func (s *knowledgeService) CloneKnowledgeBase(ctx context.Context, srcID, dstID
 		g.Go(func() error {
 			err := s.DeleteKnowledgeList(gctx, ids)
 			if err != nil {
-				logger.Errorf(gctx, "delete partial knowledge %v: %w", ids, err)
+				logger.Errorf(gctx, "delete partial knowledge %v: %v", ids, err)
 				return err
 			}
 			return nil
@@ -2280,12 +2287,12 @@ func (s *knowledgeService) CloneKnowledgeBase(ctx context.Context, srcID, dstID
 		g.Go(func() error {
 			srcKn, err := s.repo.GetKnowledgeByID(gctx, srcKB.TenantID, knowledge)
 			if err != nil {
-				logger.Errorf(gctx, "get knowledge %s: %w", knowledge, err)
+				logger.Errorf(gctx, "get knowledge %s: %v", knowledge, err)
 				return err
 			}
 			err = s.cloneKnowledge(gctx, srcKn, dstKB)
 			if err != nil {
-				logger.Errorf(gctx, "clone knowledge %s: %w", knowledge, err)
+				logger.Errorf(gctx, "clone knowledge %s: %v", knowledge, err)
 				return err
 			}
 			return nil
@@ -2330,6 +2337,9 @@ func (s *knowledgeService) updateChunkVector(ctx context.Context, kbID string, c
 	}
 
 	tenantInfo := ctx.Value(types.TenantInfoContextKey).(*types.Tenant)
+	if err := s.setIndexFilterFields(ctx, tenantInfo.ID, indexInfo); err != nil {
+		return err
+	}
 	retrieveEngine, err := retriever.NewCompositeRetrieveEngine(s.retrieveEngine, tenantInfo.GetEffectiveEngines())
 	if err != nil {
 		return err
@@ -2346,7 +2356,59 @@ func (s *knowledgeService) updateChunkVector(ctx context.Context, kbID string, c
 	if err != nil {
 		return err
 	}
-	return nil
+	s.invalidateAnswerCache(ctx, kbID)
+	return nil
+}
+
+// setIndexFilterFields sets the filter fields of the knowledge of each index entry, so that the
+// retrieval engines filter on them
+func (s *knowledgeService) setIndexFilterFields(ctx context.Context,
+	tenantID uint64, indexInfoList []*types.IndexInfo,
+) error {
+	knowledgeIDs := make([]string, 0)
+	for _, info := range indexInfoList {
+		if !slices.Contains(knowledgeIDs, info.KnowledgeID) {
+			knowledgeIDs = append(knowledgeIDs, info.KnowledgeID)
+		}
+	}
+	if len(knowledgeIDs) == 0 {
+		return nil
+	}
+	knowledgeList, err := s.repo.GetKnowledgeBatch(ctx, tenantID, knowledgeIDs)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to get knowledge of index entries: %v", err)
+		return err
+	}
+	filterFields := make(map[string]*types.IndexFilterFields, len(knowledgeList))
+	for _, knowledge := range knowledgeList {
+		filterFields[knowledge.ID] = types.NewIndexFilterFields(knowledge)
+	}
+	for _, info := range indexInfoList {
+		info.FilterFields = filterFields[info.KnowledgeID]
+	}
+	return nil
+}
+
+// updateIndexFilterFields updates the filter fields stored on the indices of knowledge after its
+// tag or metadata changed
+func (s *knowledgeService) updateIndexFilterFields(ctx context.Context, knowledgeList ...*types.Knowledge) error {
+	tenantInfo := ctx.Value(types.TenantInfoContextKey).(*types.Tenant)
+	retrieveEngine, err := retriever.NewCompositeRetrieveEngine(s.retrieveEngine, tenantInfo.GetEffectiveEngines())
+	if err != nil {
+		return err
+	}
+	filterFields := make(map[string]*types.IndexFilterFields, len(knowledgeList))
+	for _, knowledge := range knowledgeList {
+		filterFields[knowledge.ID] = types.NewIndexFilterFields(knowledge)
+	}
+	return retrieveEngine.BatchUpdateKnowledgeFilterFields(ctx, filterFields)
+}
+
+// invalidateAnswerCache drops the cached answers of a knowledge base after its content changed
+func (s *knowledgeService) invalidateAnswerCache(ctx context.Context, kbID string) {
+	if err := s.answerCache.InvalidateKnowledgeBase(ctx, kbID); err != nil {
+		logger.Warnf(ctx, "Failed to invalidate answer cache of knowledge base %s: %v", kbID, err)
+	}
 }
 
 func (s *knowledgeService) UpdateImageInfo(
@@ -2639,7 +2701,10 @@ func (s *knowledgeService) CloneChunk(ctx context.Context, src, dst *types.Knowl
 	); err != nil {
 		return err
 	}
-	return nil
+	// The copies keep the filter fields of the source knowledge
+	return retrieveEngine.BatchUpdateKnowledgeFilterFields(ctx, map[string]*types.IndexFilterFields{
+		dst.ID: types.NewIndexFilterFields(dst),
+	})
 }
 
 // ListFAQEntries lists FAQ entries under a FAQ knowledge base.
@@ -3702,7 +3767,10 @@ func (s *knowledgeService) UpdateKnowledgeTag(ctx context.Context, knowledgeID s
 	}
 
 	knowledge.TagID = resolvedTagID
-	return s.repo.UpdateKnowledge(ctx, knowledge)
+	if err := s.repo.UpdateKnowledge(ctx, knowledge); err != nil {
+		return err
+	}
+	return s.updateIndexFilterFields(ctx, knowledge)
 }
 
 // UpdateKnowledgeTagBatch updates tags for document knowledge items in batch.
@@ -3771,7 +3839,10 @@ func (s *knowledgeService) UpdateKnowledgeTagBatch(ctx context.Context, updates
 	}
 
 	if len(knowledgeToUpdate) > 0 {
-		return s.repo.UpdateKnowledgeBatch(ctx, knowledgeToUpdate)
+		if err := s.repo.UpdateKnowledgeBatch(ctx, knowledgeToUpdate); err != nil {
+			return err
+		}
+		return s.updateIndexFilterFields(ctx, knowledgeToUpdate...)
 	}
 
 	return nil
@@ -4254,7 +4325,11 @@ func (s *knowledgeService) updateFAQImportProgressStatus(
 		}
 	}
 
-	return s.saveFAQImportProgress(ctx, existingProgress)
+	if err := s.saveFAQImportProgress(ctx, existingProgress); err != nil {
+		return err
+	}
+	publishFAQImportResult(ctx, existingProgress)
+	return nil
 }
 
 // getRunningFAQImportTaskID checks if there's a running FAQ import task for the given KB
@@ -4586,6 +4661,12 @@ func (s *knowledgeService) indexFAQChunks(ctx context.Context,
 		indexInfo = append(indexInfo, infoList...)
 		chunkIDs = append(chunkIDs, chunk.ID)
 	}
+	if knowledge != nil {
+		filterFields := types.NewIndexFilterFields(knowledge)
+		for _, info := range indexInfo {
+			info.FilterFields = filterFields
+		}
+	}
 	buildIndexInfoDuration := time.Since(buildIndexInfoStartTime)
 	logger.Debugf(
 		ctx,
@@ -4624,6 +4705,7 @@ func (s *knowledgeService) indexFAQChunks(ctx context.Context,
 	if err := retrieveEngine.BatchIndex(ctx, embeddingModel, indexInfo); err != nil {
 		return err
 	}
+	s.invalidateAnswerCache(ctx, kb.ID)
 	batchIndexDuration := time.Since(batchIndexStartTime)
 	logger.Debugf(ctx, "indexFAQChunks: batch indexed %d index info entries in %v (avg: %v per entry)",
 		len(indexInfo), batchIndexDuration, batchIndexDuration/time.Duration(len(indexInfo)))
@@ -4696,6 +4778,7 @@ func (s *knowledgeService) deleteFAQChunkVectors(ctx context.Context,
 	if err := retrieveEngine.DeleteByChunkIDList(ctx, chunkIDs, embeddingModel.GetDimensions(), types.KnowledgeTypeFAQ); err != nil {
 		return err
 	}
+	s.invalidateAnswerCache(ctx, kb.ID)
 	if size > 0 {
 		if err := s.tenantRepo.AdjustStorageUsed(ctx, tenantInfo.ID, -size); err == nil {
 			tenantInfo.StorageUsed -= size
@@ -4759,8 +4842,8 @@ func (s *knowledgeService) triggerManualProcessing(ctx context.Context,
 		vlmConfig = cfg
 	}
 
-	// 调用 docreader 解析 markdown 内容
-	resp, err := s.docReaderClient.ReadFromFile(ctx, &proto.ReadFromFileRequest{
+	// 解析 markdown 内容，必要时交由 docreader 处理
+	chunks, err := s.readFileChunks(ctx, &proto.ReadFromFileRequest{
 		FileContent: contentBytes,
 		FileName:    fileName,
 		FileType:    fileType,
@@ -4795,12 +4878,12 @@ func (s *knowledgeService) triggerManualProcessing(ctx context.Context,
 	}
 
 	if sync {
-		s.processChunks(ctx, kb, knowledge, resp.Chunks)
+		s.processChunks(ctx, kb, knowledge, chunks)
 		return
 	}
 
 	newCtx := logger.CloneContext(ctx)
-	go s.processChunks(newCtx, kb, knowledge, resp.Chunks)
+	go s.processChunks(newCtx, kb, knowledge, chunks)
 }
 
 func (s *knowledgeService) cleanupKnowledgeResources(ctx context.Context, knowledge *types.Knowledge) error {
@@ -4977,6 +5060,9 @@ func (s *knowledgeService) ProcessDocument(ctx context.Context, t *asynq.Task) e
 		logger.Warnf(ctx, "Unexpected parse status: %s for knowledge: %s", knowledge.ParseStatus, payload.KnowledgeID)
 	}
 
+	// 处理结束后发布解析结果事件（解析成功或最终失败）
+	defer s.publishKnowledgeParseResult(ctx, payload.TenantID, payload.KnowledgeID)
+
 	// 获取知识库信息
 	kb, err := s.kbService.GetKnowledgeBaseByID(ctx, payload.KnowledgeBaseID)
 	if err != nil {
@@ -5109,8 +5195,8 @@ func (s *knowledgeService) ProcessDocument(ctx context.Context, t *asynq.Task) e
 			return fmt.Errorf("failed to read file: %w", err)
 		}
 
-		// 调用docReader处理文件
-		fileResp, err := s.docReaderClient.ReadFromFile(ctx, &proto.ReadFromFileRequest{
+		// 优先使用Go解析器，否则调用docReader处理文件
+		fileChunks, err := s.readFileChunks(ctx, &proto.ReadFromFileRequest{
 			FileContent: contentBytes,
 			FileName:    payload.FileName,
 			FileType:    payload.FileType,
@@ -5144,14 +5230,19 @@ func (s *knowledgeService) ProcessDocument(ctx context.Context, t *asynq.Task) e
 			}
 			return fmt.Errorf("failed to read file from docreader: %w", err)
 		}
-		chunks = fileResp.Chunks
+		chunks = fileChunks
 	}
 
-	// 处理chunks（这会更新状态为completed）
-	s.processChunks(ctx, kb, knowledge, chunks, ProcessChunksOptions{
+	options := ProcessChunksOptions{
 		EnableQuestionGeneration: payload.EnableQuestionGeneration,
 		QuestionCount:            payload.QuestionCount,
-	})
+	}
+	// 增量更新只处理变化的分块，否则全量处理（这会更新状态为completed）
+	if payload.Incremental {
+		s.processChunksIncremental(ctx, kb, knowledge, chunks, options)
+	} else {
+		s.processChunks(ctx, kb, knowledge, chunks, options)
+	}
 
 	return nil
 }
@@ -5778,7 +5869,11 @@ func (s *knowledgeService) saveKBCloneProgress(ctx context.Context, progress *ty
 	if err != nil {
 		return fmt.Errorf("failed to marshal progress: %w", err)
 	}
-	return s.redisClient.Set(ctx, key, data, kbCloneProgressTTL).Err()
+	if err := s.redisClient.Set(ctx, key, data, kbCloneProgressTTL).Err(); err != nil {
+		return err
+	}
+	publishKBCloneResult(ctx, progress)
+	return nil
 }
 
 // SaveKBCloneProgress saves the KB clone progress to Redis (public method for handler use)
@@ -5804,6 +5899,496 @@ func (s *knowledgeService) GetKBCloneProgress(ctx context.Context, taskID string
 	return &progress, nil
 }
 
+const (
+	kbReembedProgressKeyPrefix = "kb_reembed_progress:"
+	kbReembedRunningKeyPrefix  = "kb_reembed_running:"
+	kbReembedProgressTTL       = 24 * time.Hour
+	kbReembedShadowPrefix      = "reembed-"
+	kbReembedPageSize          = 100
+)
+
+// reembedDocumentChunkTypes are the chunk types indexed for document knowledge bases
+var reembedDocumentChunkTypes = []types.ChunkType{
+	types.ChunkTypeText, types.ChunkTypeImageOCR, types.ChunkTypeImageCaption, types.ChunkTypeSummary,
+}
+
+// getKBReembedProgressKey returns the Redis key for storing KB re-embedding progress
+func getKBReembedProgressKey(taskID string) string {
+	return kbReembedProgressKeyPrefix + taskID
+}
+
+// getKBReembedRunningKey returns the Redis key holding the running re-embedding task of a KB
+func getKBReembedRunningKey(kbID string) string {
+	return kbReembedRunningKeyPrefix + kbID
+}
+
+// getKBReembedShadowID returns the knowledge base ID the shadow index of a KB is written under
+func getKBReembedShadowID(kbID string) string {
+	return kbReembedShadowPrefix + kbID
+}
+
+// ReembedKnowledgeBase starts re-embedding every chunk of a knowledge base with another embedding model.
+// Returns the task ID used to query the progress
+func (s *knowledgeService) ReembedKnowledgeBase(ctx context.Context, kbID string, modelID string) (string, error) {
+	kb, err := s.kbService.GetKnowledgeBaseByID(ctx, kbID)
+	if err != nil {
+		return "", err
+	}
+	if kb.EmbeddingModelID == modelID {
+		return "", werrors.NewBadRequestError("The knowledge base already uses this embedding model")
+	}
+
+	model, err := s.modelService.GetModelByID(ctx, modelID)
+	if err != nil {
+		return "", werrors.NewBadRequestError("Embedding model not found").WithDetails(err.Error())
+	}
+	if model.Type != types.ModelTypeEmbedding {
+		return "", werrors.NewBadRequestError("The model is not an embedding model")
+	}
+
+	// Only one re-embedding task may run per knowledge base since they share the shadow index
+	taskID := uuid.New().String()
+	acquired, err := s.redisClient.SetNX(ctx, getKBReembedRunningKey(kbID), taskID, kbReembedProgressTTL).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to check running re-embedding task: %w", err)
+	}
+	if !acquired {
+		runningTaskID, _ := s.redisClient.Get(ctx, getKBReembedRunningKey(kbID)).Result()
+		return "", werrors.NewBadRequestError(
+			fmt.Sprintf("A re-embedding task is already running for this knowledge base (task ID: %s)", runningTaskID),
+		)
+	}
+
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	progress := &types.KBReembedProgress{
+		TaskID:          taskID,
+		KnowledgeBaseID: kbID,
+		SourceModelID:   kb.EmbeddingModelID,
+		TargetModelID:   modelID,
+		Status:          types.KBCloneStatusPending,
+		Message:         "Task queued, waiting to start...",
+		CreatedAt:       time.Now().Unix(),
+		UpdatedAt:       time.Now().Unix(),
+	}
+	if err := s.saveKBReembedProgress(ctx, progress); err != nil {
+		logger.Warnf(ctx, "Failed to save initial KB re-embedding progress: %v", err)
+	}
+
+	payloadBytes, err := json.Marshal(types.KBReembedPayload{
+		TenantID:         tenantID,
+		TaskID:           taskID,
+		KnowledgeBaseID:  kbID,
+		EmbeddingModelID: modelID,
+	})
+	if err != nil {
+		s.redisClient.Del(ctx, getKBReembedRunningKey(kbID))
+		return "", fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+
+	task := asynq.NewTask(types.TypeKBReembed, payloadBytes, asynq.Queue("low"), asynq.MaxRetry(3))
+	info, err := s.task.Enqueue(task)
+	if err != nil {
+		s.redisClient.Del(ctx, getKBReembedRunningKey(kbID))
+		logger.Errorf(ctx, "Failed to enqueue KB re-embedding task: %v", err)
+		return "", fmt.Errorf("failed to enqueue task: %w", err)
+	}
+	logger.Infof(ctx, "KB re-embedding task enqueued: %s, asynq task ID: %s, kb: %s, model: %s -> %s",
+		taskID, info.ID, kbID, kb.EmbeddingModelID, modelID)
+	return taskID, nil
+}
+
+// ProcessKBReembed handles Asynq knowledge base re-embedding tasks.
+// Chunks are embedded with the new model into a shadow index that shares chunk and knowledge IDs
+// with the live index. Deletes made meanwhile only reach the index of the current model, so the
+// chunks deleted from the database are dropped from the shadow index before it replaces the live
+// one and the embedding model is switched. The shadow index is dropped if the knowledge base is
+// deleted while re-embedding.
+func (s *knowledgeService) ProcessKBReembed(ctx context.Context, t *asynq.Task) error {
+	var payload types.KBReembedPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal KB re-embedding payload: %w", err)
+	}
+
+	ctx = context.WithValue(ctx, types.TenantIDContextKey, payload.TenantID)
+	tenantInfo, err := s.tenantRepo.GetTenantByID(ctx, payload.TenantID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to get tenant info: %v", err)
+		return fmt.Errorf("failed to get tenant info: %w", err)
+	}
+	ctx = context.WithValue(ctx, types.TenantInfoContextKey, tenantInfo)
+
+	retryCount, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	isLastRetry := retryCount >= maxRetry
+
+	logger.Infof(ctx, "Processing KB re-embedding task: %s, kb: %s, model: %s, retry: %d/%d",
+		payload.TaskID, payload.KnowledgeBaseID, payload.EmbeddingModelID, retryCount, maxRetry)
+
+	progress := &types.KBReembedProgress{
+		TaskID:          payload.TaskID,
+		KnowledgeBaseID: payload.KnowledgeBaseID,
+		TargetModelID:   payload.EmbeddingModelID,
+		Status:          types.KBCloneStatusProcessing,
+		Message:         "Starting knowledge base re-embedding...",
+		CreatedAt:       time.Now().Unix(),
+		UpdatedAt:       time.Now().Unix(),
+	}
+	if existing, err := s.GetKBReembedProgress(ctx, payload.TaskID); err == nil {
+		progress.CreatedAt = existing.CreatedAt
+	}
+	if err := s.saveKBReembedProgress(ctx, progress); err != nil {
+		logger.Errorf(ctx, "Failed to update KB re-embedding progress: %v", err)
+	}
+
+	var retrieveEngine *retriever.CompositeRetrieveEngine
+	var targetModel embedding.Embedder
+	shadowKBID := getKBReembedShadowID(payload.KnowledgeBaseID)
+
+	// Helper function to handle errors - only mark as failed and drop the shadow index on last retry
+	handleError := func(err error, message string) error {
+		logger.Errorf(ctx, "%s: %v", message, err)
+		if !isLastRetry {
+			return err
+		}
+		progress.Status = types.KBCloneStatusFailed
+		progress.Error = err.Error()
+		progress.Message = message
+		progress.UpdatedAt = time.Now().Unix()
+		_ = s.saveKBReembedProgress(ctx, progress)
+		if retrieveEngine != nil && targetModel != nil {
+			if err := retrieveEngine.DeleteByKnowledgeBaseID(ctx, shadowKBID, targetModel.GetDimensions(), ""); err != nil {
+				logger.Warnf(ctx, "Failed to delete shadow index %s: %v", shadowKBID, err)
+			}
+		}
+		s.redisClient.Del(ctx, getKBReembedRunningKey(payload.KnowledgeBaseID))
+		return err
+	}
+
+	kb, err := s.kbService.GetKnowledgeBaseByID(ctx, payload.KnowledgeBaseID)
+	if err != nil {
+		return handleError(err, "Failed to get knowledge base")
+	}
+	progress.SourceModelID = kb.EmbeddingModelID
+
+	sourceModel, err := s.modelService.GetEmbeddingModel(ctx, kb.EmbeddingModelID)
+	if err != nil {
+		return handleError(err, "Failed to get current embedding model")
+	}
+	targetModel, err = s.modelService.GetEmbeddingModel(ctx, payload.EmbeddingModelID)
+	if err != nil {
+		return handleError(err, "Failed to get target embedding model")
+	}
+	retrieveEngine, err = retriever.NewCompositeRetrieveEngine(s.retrieveEngine, tenantInfo.GetEffectiveEngines())
+	if err != nil {
+		return handleError(err, "Failed to init retrieve engine")
+	}
+
+	// Drop whatever a previous attempt left in the shadow index
+	if err := retrieveEngine.DeleteByKnowledgeBaseID(ctx, shadowKBID, targetModel.GetDimensions(), kb.Type); err != nil {
+		return handleError(err, "Failed to reset shadow index")
+	}
+
+	total, err := s.chunkRepo.CountChunksByKnowledgeBaseID(ctx, kb.TenantID, kb.ID)
+	if err != nil {
+		return handleError(err, "Failed to count chunks")
+	}
+	progress.Total = int(total)
+	progress.Message = fmt.Sprintf("Re-embedding %d chunks", total)
+	progress.UpdatedAt = time.Now().Unix()
+	_ = s.saveKBReembedProgress(ctx, progress)
+
+	startedAt := time.Now()
+	embedded := make(map[string]bool)
+	reembedAll := func(indexKBID string, replaceDimensions []int, filter func(chunk *types.Chunk) bool) error {
+		knowledgeList, err := s.repo.ListKnowledgeByKnowledgeBaseID(ctx, kb.TenantID, kb.ID)
+		if err != nil {
+			return err
+		}
+		for _, knowledge := range knowledgeList {
+			err := s.reembedKnowledgeChunks(ctx, kb, knowledge, indexKBID, replaceDimensions,
+				retrieveEngine, targetModel, filter, func(chunks []*types.Chunk) {
+					for _, chunk := range chunks {
+						embedded[chunk.ID] = true
+					}
+					progress.Processed = min(len(embedded), progress.Total)
+					if progress.Total > 0 {
+						progress.Progress = progress.Processed * 99 / progress.Total
+					}
+					progress.Message = fmt.Sprintf("Re-embedded %d/%d chunks", progress.Processed, progress.Total)
+					progress.UpdatedAt = time.Now().Unix()
+					_ = s.saveKBReembedProgress(ctx, progress)
+				})
+			if err != nil {
+				return fmt.Errorf("knowledge %s: %w", knowledge.ID, err)
+			}
+		}
+		return nil
+	}
+
+	// Deletes only reach the index of the model the knowledge base uses, drop the re-embedded chunks
+	// deleted since then from the given indices so that the promotion doesn't bring them back
+	dropDeletedChunks := func(dimensions []int) error {
+		ids := make([]string, 0, len(embedded))
+		for id := range embedded {
+			ids = append(ids, id)
+		}
+		deleted := make([]string, 0)
+		for start := 0; start < len(ids); start += kbReembedPageSize {
+			batch := ids[start:min(start+kbReembedPageSize, len(ids))]
+			chunks, err := s.chunkRepo.ListChunksByID(ctx, kb.TenantID, batch)
+			if err != nil {
+				return err
+			}
+			existing := make(map[string]bool, len(chunks))
+			for _, chunk := range chunks {
+				existing[chunk.ID] = true
+			}
+			for _, id := range batch {
+				if !existing[id] {
+					deleted = append(deleted, id)
+					delete(embedded, id)
+				}
+			}
+		}
+		if len(deleted) == 0 {
+			return nil
+		}
+		for _, dimension := range dimensions {
+			if err := retrieveEngine.DeleteByChunkIDList(ctx, deleted, dimension, kb.Type); err != nil {
+				return err
+			}
+		}
+		logger.Infof(ctx, "Dropped %d chunks deleted while re-embedding from the index", len(deleted))
+		return nil
+	}
+	// The deletion of a knowledge base doesn't know about the shadow index, nor about the index
+	// of the new model until it is switched
+	kbDeleted := func() (bool, error) {
+		_, err := s.kbService.GetRepository().GetKnowledgeBaseByID(ctx, kb.ID)
+		if errors.Is(err, repository.ErrKnowledgeBaseNotFound) {
+			return true, nil
+		}
+		return false, err
+	}
+	abortDeleted := func(indexKBID string, dimensions []int) error {
+		logger.Infof(ctx, "Knowledge base %s was deleted while re-embedding, dropping index %s", kb.ID, indexKBID)
+		for _, dimension := range dimensions {
+			if err := retrieveEngine.DeleteByKnowledgeBaseID(ctx, indexKBID, dimension, kb.Type); err != nil {
+				logger.Warnf(ctx, "Failed to delete index %s: %v", indexKBID, err)
+			}
+		}
+		s.redisClient.Del(ctx, getKBReembedRunningKey(kb.ID))
+		progress.Status = types.KBCloneStatusFailed
+		progress.Message = "Knowledge base was deleted"
+		progress.UpdatedAt = time.Now().Unix()
+		_ = s.saveKBReembedProgress(ctx, progress)
+		return nil
+	}
+
+	if err := reembedAll(shadowKBID, nil, func(*types.Chunk) bool { return true }); err != nil {
+		return handleError(err, "Failed to re-embed chunks")
+	}
+	// Catch up with chunks created or edited while the first pass was running
+	catchUpStartedAt := time.Now()
+	if err := reembedAll(shadowKBID, nil, func(chunk *types.Chunk) bool {
+		return !embedded[chunk.ID] || chunk.UpdatedAt.After(startedAt)
+	}); err != nil {
+		return handleError(err, "Failed to re-embed chunks")
+	}
+	if err := dropDeletedChunks([]int{targetModel.GetDimensions()}); err != nil {
+		return handleError(err, "Failed to drop deleted chunks from shadow index")
+	}
+	if deleted, err := kbDeleted(); err != nil {
+		return handleError(err, "Failed to get knowledge base")
+	} else if deleted {
+		return abortDeleted(shadowKBID, []int{targetModel.GetDimensions()})
+	}
+
+	progress.Message = "Switching to the new embedding model..."
+	progress.UpdatedAt = time.Now().Unix()
+	_ = s.saveKBReembedProgress(ctx, progress)
+
+	if err := retrieveEngine.PromoteShadowIndices(
+		ctx, shadowKBID, kb.ID, targetModel.GetDimensions(), sourceModel.GetDimensions(),
+	); err != nil {
+		return handleError(err, "Failed to promote shadow index")
+	}
+	if err := s.kbService.GetRepository().UpdateEmbeddingModel(ctx, kb.ID, payload.EmbeddingModelID); err != nil {
+		return handleError(err, "Failed to switch embedding model")
+	}
+	// Chunks created or edited since the catch-up pass were indexed with the previous model, or not at
+	// all if the promotion deleted them. New chunks use the new model from now on, so re-index these
+	// in place, replacing whatever they have in the index of either model.
+	replaceDimensions := []int{sourceModel.GetDimensions()}
+	if targetModel.GetDimensions() != sourceModel.GetDimensions() {
+		replaceDimensions = append(replaceDimensions, targetModel.GetDimensions())
+	}
+	if err := reembedAll(kb.ID, replaceDimensions, func(chunk *types.Chunk) bool {
+		return chunk.UpdatedAt.After(catchUpStartedAt)
+	}); err != nil {
+		return handleError(err, "Failed to re-embed chunks changed during the promotion")
+	}
+	// Chunks deleted during the promotion may have been deleted from the index of the previous model
+	if err := dropDeletedChunks(replaceDimensions); err != nil {
+		return handleError(err, "Failed to drop deleted chunks from index")
+	}
+	if deleted, err := kbDeleted(); err != nil {
+		return handleError(err, "Failed to get knowledge base")
+	} else if deleted {
+		return abortDeleted(kb.ID, replaceDimensions)
+	}
+	s.redisClient.Del(ctx, getKBReembedRunningKey(kb.ID))
+	s.invalidateAnswerCache(ctx, kb.ID)
+
+	progress.Status = types.KBCloneStatusCompleted
+	progress.Progress = 100
+	progress.Processed = progress.Total
+	progress.Message = "Knowledge base re-embedding completed successfully"
+	progress.UpdatedAt = time.Now().Unix()
+	if err := s.saveKBReembedProgress(ctx, progress); err != nil {
+		logger.Errorf(ctx, "Failed to update KB re-embedding progress to completed: %v", err)
+	}
+
+	logger.Infof(ctx, "KB re-embedding task completed: %s, kb: %s now uses model %s",
+		payload.TaskID, kb.ID, payload.EmbeddingModelID)
+	return nil
+}
+
+// reembedKnowledgeChunks indexes the chunks of a knowledge accepted by filter under indexKBID, the
+// shadow index or the knowledge base itself, calling onBatch after each indexed page. The indices the
+// chunks already have in the replaceDimensions are deleted first.
+func (s *knowledgeService) reembedKnowledgeChunks(ctx context.Context,
+	kb *types.KnowledgeBase, knowledge *types.Knowledge, indexKBID string, replaceDimensions []int,
+	retrieveEngine *retriever.CompositeRetrieveEngine, embeddingModel embedding.Embedder,
+	filter func(chunk *types.Chunk) bool, onBatch func(chunks []*types.Chunk),
+) error {
+	chunkTypes := reembedDocumentChunkTypes
+	if kb.Type == types.KnowledgeBaseTypeFAQ {
+		chunkTypes = []types.ChunkType{types.ChunkTypeFAQ}
+	}
+	filterFields := types.NewIndexFilterFields(knowledge)
+
+	for page := 1; ; page++ {
+		chunks, _, err := s.chunkRepo.ListPagedChunksByKnowledgeID(ctx, kb.TenantID, knowledge.ID,
+			&types.Pagination{Page: page, PageSize: kbReembedPageSize}, chunkTypes, "", "", "", "asc")
+		if err != nil {
+			return err
+		}
+
+		selected := make([]*types.Chunk, 0, len(chunks))
+		indexInfoList := make([]*types.IndexInfo, 0, len(chunks))
+		disabled := make(map[string]bool)
+		for _, chunk := range chunks {
+			if !filter(chunk) {
+				continue
+			}
+			infoList, err := s.buildReembedIndexInfoList(ctx, kb, chunk)
+			if err != nil {
+				logger.Warnf(ctx, "Failed to build index info for chunk %s: %v", chunk.ID, err)
+				continue
+			}
+			for _, info := range infoList {
+				info.KnowledgeBaseID = indexKBID
+				info.FilterFields = filterFields
+			}
+			indexInfoList = append(indexInfoList, infoList...)
+			selected = append(selected, chunk)
+			if !chunk.IsEnabled {
+				disabled[chunk.ID] = false
+			}
+		}
+
+		if len(indexInfoList) > 0 {
+			chunkIDs := make([]string, 0, len(selected))
+			for _, chunk := range selected {
+				chunkIDs = append(chunkIDs, chunk.ID)
+			}
+			for _, dimension := range replaceDimensions {
+				if err := retrieveEngine.DeleteByChunkIDList(ctx, chunkIDs, dimension, kb.Type); err != nil {
+					return err
+				}
+			}
+			if err := retrieveEngine.BatchIndex(ctx, embeddingModel, indexInfoList); err != nil {
+				return err
+			}
+			// Indexing always stores chunks as enabled, restore the disabled ones
+			if len(disabled) > 0 {
+				if err := retrieveEngine.BatchUpdateChunkEnabledStatus(ctx, disabled); err != nil {
+					return err
+				}
+			}
+			onBatch(selected)
+		}
+
+		if len(chunks) < kbReembedPageSize {
+			return nil
+		}
+	}
+}
+
+// buildReembedIndexInfoList builds the index info of a chunk the same way it is indexed on ingestion
+func (s *knowledgeService) buildReembedIndexInfoList(ctx context.Context,
+	kb *types.KnowledgeBase, chunk *types.Chunk,
+) ([]*types.IndexInfo, error) {
+	if kb.Type == types.KnowledgeBaseTypeFAQ {
+		return s.buildFAQIndexInfoList(ctx, kb, chunk)
+	}
+
+	indexInfoList := []*types.IndexInfo{{
+		Content:         chunk.Content,
+		SourceID:        chunk.ID,
+		SourceType:      types.ChunkSourceType,
+		ChunkID:         chunk.ID,
+		KnowledgeID:     chunk.KnowledgeID,
+		KnowledgeBaseID: chunk.KnowledgeBaseID,
+	}}
+	meta, err := chunk.DocumentMetadata()
+	if err != nil {
+		return nil, err
+	}
+	if meta != nil {
+		for _, gq := range meta.GeneratedQuestions {
+			indexInfoList = append(indexInfoList, &types.IndexInfo{
+				Content:         gq.Question,
+				SourceID:        fmt.Sprintf("%s-%s", chunk.ID, gq.ID),
+				SourceType:      types.ChunkSourceType,
+				ChunkID:         chunk.ID,
+				KnowledgeID:     chunk.KnowledgeID,
+				KnowledgeBaseID: chunk.KnowledgeBaseID,
+			})
+		}
+	}
+	return indexInfoList, nil
+}
+
+// saveKBReembedProgress saves the KB re-embedding progress to Redis
+func (s *knowledgeService) saveKBReembedProgress(ctx context.Context, progress *types.KBReembedProgress) error {
+	key := getKBReembedProgressKey(progress.TaskID)
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return fmt.Errorf("failed to marshal progress: %w", err)
+	}
+	return s.redisClient.Set(ctx, key, data, kbReembedProgressTTL).Err()
+}
+
+// GetKBReembedProgress retrieves the progress of a knowledge base re-embedding task
+func (s *knowledgeService) GetKBReembedProgress(ctx context.Context, taskID string) (*types.KBReembedProgress, error) {
+	key := getKBReembedProgressKey(taskID)
+	data, err := s.redisClient.Get(ctx, key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, werrors.NewNotFoundError("KB re-embedding task not found")
+		}
+		return nil, fmt.Errorf("failed to get progress from Redis: %w", err)
+	}
+
+	var progress types.KBReembedProgress
+	if err := json.Unmarshal(data, &progress); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal progress: %w", err)
+	}
+	return &progress, nil
+}
+
 // getOrCreateTagInTarget finds or creates a tag in the target knowledge base based on the source tag.
 // It looks up the source tag by ID, then tries to find a tag with the same name in the target KB.
 // If not found, it creates a new tag with the same properties.
@@ -5858,6 +6443,17 @@ func (s *knowledgeService) SearchKnowledge(ctx context.Context, keyword string,
 	if !ok {
 		return nil, false, werrors.NewUnauthorizedError("Tenant ID not found in context")
 	}
-	return s.repo.SearchKnowledge(ctx, tenantID, keyword, offset, limit)
+	// 仅搜索调用者可读的知识库
+	var kbIDs []string
+	if principal := types.PrincipalFromContext(ctx); principal != nil {
+		readable, all := principal.ReadableKnowledgeBaseIDs()
+		if !all {
+			if len(readable) == 0 {
+				return []*types.Knowledge{}, false, nil
+			}
+			kbIDs = readable
+		}
+	}
+	return s.repo.SearchKnowledge(ctx, tenantID, kbIDs, keyword, offset, limit)
 }
 
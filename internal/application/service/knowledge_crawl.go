@@ -0,0 +1,412 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/application/service/crawler"
+	werrors "github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/robfig/cron/v3"
+)
+
+// validateRefreshSchedule checks that a refresh schedule is empty or a standard cron expression
+func validateRefreshSchedule(schedule string) error {
+	if schedule == "" {
+		return nil
+	}
+	if _, err := cron.ParseStandard(schedule); err != nil {
+		return werrors.NewValidationError("刷新计划不是有效的 cron 表达式").WithDetails(err.Error())
+	}
+	return nil
+}
+
+// enqueueURLProcessTask enqueues the document process task of URL knowledge.
+// Incremental processing keeps the chunks whose content did not change.
+func (s *knowledgeService) enqueueURLProcessTask(ctx context.Context,
+	kb *types.KnowledgeBase, knowledge *types.Knowledge, enableMultimodel bool, incremental bool,
+) error {
+	// Check question generation config
+	enableQuestionGeneration := false
+	questionCount := 3 // default
+	if kb.QuestionGenerationConfig != nil && kb.QuestionGenerationConfig.Enabled {
+		enableQuestionGeneration = true
+		if kb.QuestionGenerationConfig.QuestionCount > 0 {
+			questionCount = kb.QuestionGenerationConfig.QuestionCount
+		}
+	}
+
+	payloadBytes, err := json.Marshal(types.DocumentProcessPayload{
+		TenantID:                 knowledge.TenantID,
+		KnowledgeID:              knowledge.ID,
+		KnowledgeBaseID:          knowledge.KnowledgeBaseID,
+		URL:                      knowledge.Source,
+		EnableMultimodel:         enableMultimodel,
+		EnableQuestionGeneration: enableQuestionGeneration,
+		QuestionCount:            questionCount,
+		Incremental:              incremental,
+	})
+	if err != nil {
+		return err
+	}
+	info, err := s.task.Enqueue(asynq.NewTask(types.TypeDocumentProcess, payloadBytes, asynq.Queue("default")))
+	if err != nil {
+		return err
+	}
+	logger.Infof(ctx, "Enqueued URL process task: id=%s queue=%s knowledge_id=%s incremental=%v",
+		info.ID, info.Queue, knowledge.ID, incremental)
+	return nil
+}
+
+// enqueueURLCrawlTask enqueues the crawl of the pages linked from URL knowledge
+func (s *knowledgeService) enqueueURLCrawlTask(ctx context.Context, knowledge *types.Knowledge) error {
+	payloadBytes, err := json.Marshal(types.URLCrawlPayload{
+		RequestId:       uuid.New().String(),
+		TenantID:        knowledge.TenantID,
+		KnowledgeID:     knowledge.ID,
+		KnowledgeBaseID: knowledge.KnowledgeBaseID,
+	})
+	if err != nil {
+		return err
+	}
+	// A crawl already queued for the knowledge covers this one
+	info, err := s.task.Enqueue(asynq.NewTask(types.TypeURLCrawl, payloadBytes),
+		asynq.Queue("low"), asynq.MaxRetry(3), asynq.Unique(time.Hour))
+	if errors.Is(err, asynq.ErrDuplicateTask) {
+		logger.Infof(ctx, "URL crawl of knowledge %s is already queued", knowledge.ID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	logger.Infof(ctx, "Enqueued URL crawl task: id=%s knowledge_id=%s", info.ID, knowledge.ID)
+	return nil
+}
+
+// newURLRefreshTask creates the refresh task of URL knowledge
+func newURLRefreshTask(tenantID uint64, knowledgeID string) (*asynq.Task, error) {
+	payloadBytes, err := json.Marshal(types.URLRefreshPayload{TenantID: tenantID, KnowledgeID: knowledgeID})
+	if err != nil {
+		return nil, err
+	}
+	return asynq.NewTask(types.TypeURLRefresh, payloadBytes, asynq.Queue("low"), asynq.MaxRetry(3)), nil
+}
+
+// getURLKnowledge returns URL knowledge of the current tenant
+func (s *knowledgeService) getURLKnowledge(ctx context.Context, knowledgeID string) (*types.Knowledge, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	knowledge, err := s.repo.GetKnowledgeByID(ctx, tenantID, knowledgeID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to get knowledge: %v", err)
+		return nil, err
+	}
+	if knowledge.Type != "url" {
+		return nil, werrors.NewBadRequestError("仅支持 URL 类型的知识")
+	}
+	return knowledge, nil
+}
+
+// UpdateURLRefreshSchedule sets the cron refresh schedule of URL knowledge, empty disables refreshing.
+// The periodic task manager picks the schedule up on its next sync.
+func (s *knowledgeService) UpdateURLRefreshSchedule(ctx context.Context,
+	knowledgeID string, schedule string,
+) (*types.Knowledge, error) {
+	if err := validateRefreshSchedule(schedule); err != nil {
+		return nil, err
+	}
+	knowledge, err := s.getURLKnowledge(ctx, knowledgeID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateKnowledgeColumn(ctx, knowledge.ID, "refresh_schedule", schedule); err != nil {
+		logger.Errorf(ctx, "Failed to update refresh schedule: %v", err)
+		return nil, err
+	}
+	knowledge.RefreshSchedule = schedule
+	logger.Infof(ctx, "Refresh schedule of knowledge %s set to %q", knowledge.ID, schedule)
+	return knowledge, nil
+}
+
+// RefreshURLKnowledge re-fetches URL knowledge now and re-indexes it when the content changed
+func (s *knowledgeService) RefreshURLKnowledge(ctx context.Context, knowledgeID string) error {
+	knowledge, err := s.getURLKnowledge(ctx, knowledgeID)
+	if err != nil {
+		return err
+	}
+	switch knowledge.ParseStatus {
+	case types.ParseStatusPending, types.ParseStatusProcessing, types.ParseStatusDeleting:
+		return werrors.NewConflictError("知识正在处理中，请稍后再试")
+	}
+	task, err := newURLRefreshTask(knowledge.TenantID, knowledge.ID)
+	if err != nil {
+		return err
+	}
+	info, err := s.task.Enqueue(task)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to enqueue URL refresh task: %v", err)
+		return err
+	}
+	logger.Infof(ctx, "Enqueued URL refresh task: id=%s knowledge_id=%s", info.ID, knowledge.ID)
+	return nil
+}
+
+// prepareURLTaskContext loads the tenant of an Asynq URL task into the context
+func (s *knowledgeService) prepareURLTaskContext(ctx context.Context, tenantID uint64) (context.Context, error) {
+	ctx = context.WithValue(ctx, types.TenantIDContextKey, tenantID)
+	tenantInfo, err := s.tenantRepo.GetTenantByID(ctx, tenantID)
+	if err != nil {
+		return ctx, err
+	}
+	return context.WithValue(ctx, types.TenantInfoContextKey, tenantInfo), nil
+}
+
+// ProcessURLRefresh handles Asynq URL refresh tasks.
+// The page is re-fetched and re-indexed incrementally when its content hash changed,
+// and a crawl root re-crawls its scope to refresh its pages and import new ones.
+func (s *knowledgeService) ProcessURLRefresh(ctx context.Context, t *asynq.Task) error {
+	var payload types.URLRefreshPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		logger.Errorf(ctx, "failed to unmarshal URL refresh task payload: %v", err)
+		return nil
+	}
+	ctx = logger.WithField(ctx, "url_refresh", payload.KnowledgeID)
+	ctx, err := s.prepareURLTaskContext(ctx, payload.TenantID)
+	if err != nil {
+		logger.Errorf(ctx, "failed to get tenant: %v", err)
+		return nil
+	}
+
+	knowledge, err := s.repo.GetKnowledgeByID(ctx, payload.TenantID, payload.KnowledgeID)
+	if err != nil || knowledge == nil {
+		logger.Warnf(ctx, "URL knowledge to refresh not found: %s", payload.KnowledgeID)
+		return nil
+	}
+	if knowledge.Type != "url" {
+		return nil
+	}
+	switch knowledge.ParseStatus {
+	case types.ParseStatusPending, types.ParseStatusProcessing, types.ParseStatusDeleting:
+		logger.Infof(ctx, "Knowledge %s is %s, skipping refresh", knowledge.ID, knowledge.ParseStatus)
+		return nil
+	}
+	kb, err := s.kbService.GetKnowledgeBaseByID(ctx, knowledge.KnowledgeBaseID)
+	if err != nil {
+		logger.Errorf(ctx, "failed to get knowledge base: %v", err)
+		return nil
+	}
+
+	_, _, body, err := crawler.NewCrawler(nil).Fetch(ctx, knowledge.Source)
+	if err != nil {
+		// Let Asynq retry, the page may be down temporarily
+		return fmt.Errorf("failed to fetch %s: %w", knowledge.Source, err)
+	}
+	if err := s.refreshURLContent(ctx, kb, knowledge, crawler.ContentHash(body)); err != nil {
+		return err
+	}
+
+	if knowledge.CrawlConfig != nil {
+		if err := s.enqueueURLCrawlTask(ctx, knowledge); err != nil {
+			logger.Errorf(ctx, "Failed to enqueue URL crawl task: %v", err)
+		}
+	}
+	return nil
+}
+
+// refreshURLContent re-indexes URL knowledge whose fetched content hash changed,
+// failed knowledge is processed again even when the content is the same.
+func (s *knowledgeService) refreshURLContent(ctx context.Context,
+	kb *types.KnowledgeBase, knowledge *types.Knowledge, contentHash string,
+) error {
+	now := time.Now()
+	if contentHash == knowledge.ContentHash && knowledge.ParseStatus == types.ParseStatusCompleted {
+		logger.Infof(ctx, "Content of URL knowledge %s is unchanged", knowledge.ID)
+		return s.repo.UpdateKnowledgeColumn(ctx, knowledge.ID, "last_refreshed_at", now)
+	}
+
+	// Failed knowledge has no reliable chunks to diff against
+	incremental := knowledge.ParseStatus == types.ParseStatusCompleted
+	knowledge.ContentHash = contentHash
+	knowledge.LastRefreshedAt = &now
+	knowledge.ParseStatus = types.ParseStatusPending
+	knowledge.ErrorMessage = ""
+	knowledge.UpdatedAt = now
+	if err := s.repo.UpdateKnowledge(ctx, knowledge); err != nil {
+		logger.Errorf(ctx, "Failed to update refreshed knowledge: %v", err)
+		return err
+	}
+	logger.Infof(ctx, "Content of URL knowledge %s changed, re-indexing", knowledge.ID)
+	return s.enqueueURLProcessTask(ctx, kb, knowledge, kb.IsMultimodalEnabled(), incremental)
+}
+
+// ProcessURLCrawl handles Asynq URL crawl tasks.
+// Pages found within the crawl scope are imported as URL knowledge linked to the crawl root,
+// pages imported by an earlier crawl are re-indexed when their content changed.
+func (s *knowledgeService) ProcessURLCrawl(ctx context.Context, t *asynq.Task) error {
+	var payload types.URLCrawlPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		logger.Errorf(ctx, "failed to unmarshal URL crawl task payload: %v", err)
+		return nil
+	}
+	ctx = logger.WithRequestID(ctx, payload.RequestId)
+	ctx = logger.WithField(ctx, "url_crawl", payload.KnowledgeID)
+	ctx, err := s.prepareURLTaskContext(ctx, payload.TenantID)
+	if err != nil {
+		logger.Errorf(ctx, "failed to get tenant: %v", err)
+		return nil
+	}
+
+	root, err := s.repo.GetKnowledgeByID(ctx, payload.TenantID, payload.KnowledgeID)
+	if err != nil || root == nil {
+		logger.Warnf(ctx, "URL knowledge to crawl not found: %s", payload.KnowledgeID)
+		return nil
+	}
+	if root.Type != "url" || root.CrawlConfig == nil || root.ParseStatus == types.ParseStatusDeleting {
+		return nil
+	}
+	kb, err := s.kbService.GetKnowledgeBaseByID(ctx, root.KnowledgeBaseID)
+	if err != nil {
+		logger.Errorf(ctx, "failed to get knowledge base: %v", err)
+		return nil
+	}
+
+	pages, err := crawler.NewCrawler(nil).Crawl(ctx, root.Source, *root.CrawlConfig)
+	if err != nil {
+		return fmt.Errorf("failed to crawl %s: %w", root.Source, err)
+	}
+
+	var created, refreshed int
+	for _, page := range pages {
+		// The start page is the crawl root itself
+		if page.Depth == 0 {
+			continue
+		}
+		imported, err := s.importCrawledPage(ctx, kb, root, page)
+		if err != nil {
+			if _, ok := err.(*types.StorageQuotaExceededError); ok {
+				logger.Warnf(ctx, "Storage quota exceeded, stopping crawl of %s", root.Source)
+				break
+			}
+			logger.Errorf(ctx, "Failed to import crawled page %s: %v", page.URL, err)
+			continue
+		}
+		if imported {
+			created++
+		} else {
+			refreshed++
+		}
+	}
+	if err := s.repo.UpdateKnowledgeColumn(ctx, root.ID, "last_refreshed_at", time.Now()); err != nil {
+		logger.Warnf(ctx, "Failed to update crawl time of %s: %v", root.ID, err)
+	}
+	logger.Infof(ctx, "Crawl of %s done, pages: %d, imported: %d, checked: %d",
+		root.Source, len(pages), created, refreshed)
+	return nil
+}
+
+// importCrawledPage imports a crawled page as URL knowledge, or refreshes the knowledge of the page
+// imported by an earlier crawl of the same root. It reports whether new knowledge was created.
+func (s *knowledgeService) importCrawledPage(ctx context.Context,
+	kb *types.KnowledgeBase, root *types.Knowledge, page *crawler.Page,
+) (bool, error) {
+	exists, existing, err := s.repo.CheckKnowledgeExists(ctx, root.TenantID, root.KnowledgeBaseID,
+		&types.KnowledgeCheckParams{
+			Type:     "url",
+			URL:      page.URL,
+			FileHash: calculateStr(page.URL),
+		})
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		// Pages added by hand or by another crawl keep their own refresh settings
+		if existing.CrawlRootID != root.ID {
+			return false, nil
+		}
+		switch existing.ParseStatus {
+		case types.ParseStatusPending, types.ParseStatusProcessing, types.ParseStatusDeleting:
+			return false, nil
+		}
+		return false, s.refreshURLContent(ctx, kb, existing, page.ContentHash)
+	}
+
+	tenantInfo, err := s.tenantRepo.GetTenantByID(ctx, root.TenantID)
+	if err != nil {
+		return false, err
+	}
+	if tenantInfo.StorageQuota > 0 && tenantInfo.StorageUsed >= tenantInfo.StorageQuota {
+		return false, types.NewStorageQuotaExceededError()
+	}
+
+	now := time.Now()
+	knowledge := &types.Knowledge{
+		ID:               uuid.New().String(),
+		TenantID:         root.TenantID,
+		KnowledgeBaseID:  root.KnowledgeBaseID,
+		TagID:            root.TagID,
+		Type:             "url",
+		Title:            page.Title,
+		Source:           page.URL,
+		FileHash:         calculateStr(page.URL),
+		ParseStatus:      types.ParseStatusPending,
+		EnableStatus:     "disabled",
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		EmbeddingModelID: kb.EmbeddingModelID,
+		CrawlRootID:      root.ID,
+		ContentHash:      page.ContentHash,
+		LastRefreshedAt:  &now,
+	}
+	if err := s.repo.CreateKnowledge(ctx, knowledge); err != nil {
+		return false, err
+	}
+	if err := s.enqueueURLProcessTask(ctx, kb, knowledge, kb.IsMultimodalEnabled(), false); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// urlRefreshTaskConfigProvider provides the periodic refresh tasks of scheduled URL knowledge
+type urlRefreshTaskConfigProvider struct {
+	repo interfaces.KnowledgeRepository
+}
+
+// NewURLRefreshTaskConfigProvider creates the periodic task config provider of URL refresh schedules
+func NewURLRefreshTaskConfigProvider(repo interfaces.KnowledgeRepository) asynq.PeriodicTaskConfigProvider {
+	return &urlRefreshTaskConfigProvider{repo: repo}
+}
+
+// GetConfigs returns one periodic refresh task per scheduled URL knowledge
+func (p *urlRefreshTaskConfigProvider) GetConfigs() ([]*asynq.PeriodicTaskConfig, error) {
+	ctx := context.Background()
+	knowledges, err := p.repo.ListRefreshScheduledKnowledge(ctx)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to list scheduled URL knowledge: %v", err)
+		return nil, err
+	}
+	configs := make([]*asynq.PeriodicTaskConfig, 0, len(knowledges))
+	for _, knowledge := range knowledges {
+		// Schedules are validated on write, skip anything that slipped through
+		if _, err := cron.ParseStandard(knowledge.RefreshSchedule); err != nil {
+			logger.Warnf(ctx, "Invalid refresh schedule %q of knowledge %s", knowledge.RefreshSchedule, knowledge.ID)
+			continue
+		}
+		task, err := newURLRefreshTask(knowledge.TenantID, knowledge.ID)
+		if err != nil {
+			return nil, err
+		}
+		// Every server replica runs the scheduler, only one refresh per tick is enqueued
+		configs = append(configs, &asynq.PeriodicTaskConfig{
+			Cronspec: knowledge.RefreshSchedule,
+			Task:     task,
+			Opts:     []asynq.Option{asynq.Unique(time.Minute)},
+		})
+	}
+	return configs, nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+
+	"github.com/Tencent/WeKnora/internal/event"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// publishKnowledgeParseResult publishes the final parse status of a knowledge on the global event bus.
+// Nothing is published while the knowledge is still being processed, e.g. when the task will be retried.
+func (s *knowledgeService) publishKnowledgeParseResult(ctx context.Context, tenantID uint64, knowledgeID string) {
+	knowledge, err := s.repo.GetKnowledgeByID(ctx, tenantID, knowledgeID)
+	if err != nil || knowledge == nil {
+		return
+	}
+	var eventType event.EventType
+	switch knowledge.ParseStatus {
+	case types.ParseStatusCompleted:
+		eventType = event.EventKnowledgeParsed
+	case types.ParseStatusFailed:
+		eventType = event.EventKnowledgeFailed
+	default:
+		return
+	}
+	publishLifecycleEvent(ctx, eventType, event.KnowledgeParseData{
+		KnowledgeID:     knowledge.ID,
+		KnowledgeBaseID: knowledge.KnowledgeBaseID,
+		Title:           knowledge.Title,
+		FileName:        knowledge.FileName,
+		Source:          knowledge.Source,
+		ParseStatus:     knowledge.ParseStatus,
+		ErrorMessage:    knowledge.ErrorMessage,
+	})
+}
+
+// publishFAQImportResult publishes the end of an FAQ import task on the global event bus
+func publishFAQImportResult(ctx context.Context, progress *types.FAQImportProgress) {
+	if progress.Status != types.FAQImportStatusCompleted && progress.Status != types.FAQImportStatusFailed {
+		return
+	}
+	publishLifecycleEvent(ctx, event.EventFAQImportCompleted, event.FAQImportData{
+		TaskID:          progress.TaskID,
+		KnowledgeBaseID: progress.KBID,
+		KnowledgeID:     progress.KnowledgeID,
+		Status:          string(progress.Status),
+		Total:           progress.Total,
+		Processed:       progress.Processed,
+		Error:           progress.Error,
+	})
+}
+
+// publishKBCloneResult publishes the end of a knowledge base clone task on the global event bus
+func publishKBCloneResult(ctx context.Context, progress *types.KBCloneProgress) {
+	if progress.Status != types.KBCloneStatusCompleted && progress.Status != types.KBCloneStatusFailed {
+		return
+	}
+	publishLifecycleEvent(ctx, event.EventKBCloneCompleted, event.KBCloneData{
+		TaskID:   progress.TaskID,
+		SourceID: progress.SourceID,
+		TargetID: progress.TargetID,
+		Status:   string(progress.Status),
+		Total:    progress.Total,
+		Error:    progress.Error,
+	})
+}
+
+// publishLifecycleEvent publishes an event of the tenant in the context on the global event bus
+func publishLifecycleEvent(ctx context.Context, eventType event.EventType, data interface{}) {
+	requestID, _ := ctx.Value(types.RequestIDContextKey).(string)
+	if err := event.Emit(ctx, event.Event{Type: eventType, Data: data, RequestID: requestID}); err != nil {
+		logger.Warnf(ctx, "Failed to publish %s event: %v", eventType, err)
+	}
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Tencent/WeKnora/docreader/client"
+	"github.com/Tencent/WeKnora/docreader/proto"
+	"github.com/Tencent/WeKnora/internal/application/service/docparser"
+	"github.com/Tencent/WeKnora/internal/logger"
+)
+
+// readFileChunks parses a file of a knowledge into chunks
+func (s *knowledgeService) readFileChunks(ctx context.Context, req *proto.ReadFromFileRequest) ([]*proto.Chunk, error) {
+	return parseFileChunks(ctx, s.docReaderClient, req)
+}
+
+// parseFileChunks parses a file into chunks with the Go document parser registered for its file type,
+// falling back to docreader when there is none or the parser leaves the file to docreader
+func parseFileChunks(
+	ctx context.Context, docReaderClient *client.Client, req *proto.ReadFromFileRequest,
+) ([]*proto.Chunk, error) {
+	if parser, ok := docparser.Lookup(req.FileType); ok {
+		startTime := time.Now()
+		chunks, err := parser.Parse(ctx, req.FileContent, req.ReadConfig)
+		if err == nil {
+			logger.Infof(ctx, "Parsed file %s with Go parser, chunks: %d, duration: %v",
+				req.FileName, len(chunks), time.Since(startTime))
+			return chunks, nil
+		}
+		if !errors.Is(err, docparser.ErrNotHandled) {
+			return nil, err
+		}
+		logger.Infof(ctx, "Go parser left file %s to docreader", req.FileName)
+	}
+
+	if docReaderClient == nil {
+		return nil, errors.New("docreader service is not configured")
+	}
+	resp, err := docReaderClient.ReadFromFile(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Chunks, nil
+}
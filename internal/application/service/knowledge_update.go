@@ -0,0 +1,485 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"sort"
+	"time"
+
+	"github.com/Tencent/WeKnora/docreader/proto"
+	"github.com/Tencent/WeKnora/internal/application/service/retriever"
+	werrors "github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/tracing"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/hibiken/asynq"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// UpdateKnowledgeFromFile replaces the file of a file knowledge and re-ingests it incrementally.
+// Chunks whose content is unchanged keep their IDs and vectors, only new or changed chunks are embedded.
+func (s *knowledgeService) UpdateKnowledgeFromFile(ctx context.Context,
+	knowledgeID string, file *multipart.FileHeader, metadata map[string]string,
+) (*types.Knowledge, error) {
+	logger.Infof(ctx, "Start updating knowledge file, knowledge ID: %s", knowledgeID)
+
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	knowledge, err := s.repo.GetKnowledgeByID(ctx, tenantID, knowledgeID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to get knowledge: %v", err)
+		return nil, err
+	}
+	if knowledge.Type != "file" {
+		return nil, werrors.NewBadRequestError("仅支持更新文件类型的知识")
+	}
+	switch knowledge.ParseStatus {
+	case types.ParseStatusPending, types.ParseStatusProcessing, types.ParseStatusDeleting:
+		return nil, werrors.NewConflictError("知识正在处理中，请稍后再试")
+	}
+
+	// The chunking of the docreader depends on the file type, so it must not change
+	if !isValidFileType(file.Filename) {
+		return nil, werrors.NewBadRequestError("不支持的文件类型")
+	}
+	if getFileType(file.Filename) != knowledge.FileType {
+		return nil, werrors.NewBadRequestError("文件类型必须与原文件一致").
+			WithDetails(knowledge.FileType)
+	}
+
+	hash, err := calculateFileHash(file)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to calculate file hash: %v", err)
+		return nil, err
+	}
+	if hash == knowledge.FileHash && metadata == nil {
+		logger.Infof(ctx, "File content is unchanged, skipping update: %s", knowledgeID)
+		return knowledge, nil
+	}
+
+	tenantInfo := ctx.Value(types.TenantInfoContextKey).(*types.Tenant)
+	if tenantInfo.StorageQuota > 0 && tenantInfo.StorageUsed >= tenantInfo.StorageQuota {
+		logger.Error(ctx, "Storage quota exceeded")
+		return nil, types.NewStorageQuotaExceededError()
+	}
+
+	kb, err := s.kbService.GetKnowledgeBaseByID(ctx, knowledge.KnowledgeBaseID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to get knowledge base: %v", err)
+		return nil, err
+	}
+
+	if metadata != nil {
+		metadataBytes, err := json.Marshal(metadata)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to marshal metadata: %v", err)
+			return nil, err
+		}
+		knowledge.Metadata = types.JSON(metadataBytes)
+	}
+	if hash == knowledge.FileHash {
+		// Only the metadata changed, the chunks stay as they are
+		knowledge.UpdatedAt = time.Now()
+		if err := s.repo.UpdateKnowledge(ctx, knowledge); err != nil {
+			return nil, err
+		}
+		if err := s.updateIndexFilterFields(ctx, knowledge); err != nil {
+			logger.Errorf(ctx, "Failed to update filter fields of knowledge %s: %v", knowledge.ID, err)
+			return nil, err
+		}
+		return knowledge, nil
+	}
+
+	filePath, err := s.fileSvc.SaveFile(ctx, file, knowledge.TenantID, knowledge.ID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to save file, knowledge ID: %s, error: %v", knowledge.ID, err)
+		return nil, err
+	}
+	oldFilePath := knowledge.FilePath
+	deleteNewFile := func() {
+		if filePath == oldFilePath {
+			return
+		}
+		if err := s.fileSvc.DeleteFile(ctx, filePath); err != nil {
+			logger.Warnf(ctx, "Failed to delete new file %s: %v", filePath, err)
+		}
+	}
+
+	enableQuestionGeneration := false
+	questionCount := 3 // default
+	if kb.QuestionGenerationConfig != nil && kb.QuestionGenerationConfig.Enabled {
+		enableQuestionGeneration = true
+		if kb.QuestionGenerationConfig.QuestionCount > 0 {
+			questionCount = kb.QuestionGenerationConfig.QuestionCount
+		}
+	}
+	payloadBytes, err := json.Marshal(types.DocumentProcessPayload{
+		TenantID:                 tenantID,
+		KnowledgeID:              knowledge.ID,
+		KnowledgeBaseID:          knowledge.KnowledgeBaseID,
+		FilePath:                 filePath,
+		FileName:                 knowledge.FileName,
+		FileType:                 knowledge.FileType,
+		EnableMultimodel:         kb.IsMultimodalEnabled(),
+		EnableQuestionGeneration: enableQuestionGeneration,
+		QuestionCount:            questionCount,
+		Incremental:              true,
+	})
+	if err != nil {
+		logger.Errorf(ctx, "Failed to marshal document process task payload: %v", err)
+		deleteNewFile()
+		return nil, err
+	}
+
+	previous := *knowledge
+	knowledge.FilePath = filePath
+	knowledge.FileSize = file.Size
+	knowledge.FileHash = hash
+	knowledge.ParseStatus = types.ParseStatusPending
+	knowledge.ErrorMessage = ""
+	knowledge.UpdatedAt = time.Now()
+	if err := s.repo.UpdateKnowledge(ctx, knowledge); err != nil {
+		logger.Errorf(ctx, "Failed to update knowledge file, ID: %s, error: %v", knowledge.ID, err)
+		deleteNewFile()
+		return nil, err
+	}
+
+	info, err := s.task.Enqueue(asynq.NewTask(types.TypeDocumentProcess, payloadBytes, asynq.Queue("default")))
+	if err != nil {
+		logger.Errorf(ctx, "Failed to enqueue document process task: %v", err)
+		// Restore the previous file, otherwise the knowledge stays pending and can't be updated again
+		previous.UpdatedAt = time.Now()
+		if err := s.repo.UpdateKnowledge(ctx, &previous); err != nil {
+			logger.Errorf(ctx, "Failed to restore knowledge file, ID: %s, error: %v", knowledge.ID, err)
+			return nil, err
+		}
+		deleteNewFile()
+		return nil, err
+	}
+	// The previous file is only deleted once the new one is sure to be processed
+	if oldFilePath != "" && oldFilePath != filePath {
+		if err := s.fileSvc.DeleteFile(ctx, oldFilePath); err != nil {
+			logger.Warnf(ctx, "Failed to delete previous file %s: %v", oldFilePath, err)
+		}
+	}
+	logger.Infof(ctx, "Enqueued incremental document process task: id=%s knowledge_id=%s", info.ID, knowledge.ID)
+	return knowledge, nil
+}
+
+// chunkDiff is the result of comparing the re-parsed chunks of a knowledge with its stored chunks
+type chunkDiff struct {
+	// Stored chunks with unchanged content whose position or links changed
+	moved []*types.Chunk
+	// Number of stored chunks kept as they are
+	unchanged int
+	// Parsed chunks with new or changed content, they need to be embedded
+	added []*types.Chunk
+	// Stored chunks whose content is gone
+	removed []*types.Chunk
+	// Stored summary chunks, they describe the previous content
+	summaries []*types.Chunk
+}
+
+// changed reports whether the content of the knowledge changed
+func (d *chunkDiff) changed() bool {
+	return len(d.added) > 0 || len(d.removed) > 0
+}
+
+// diffKnowledgeChunks matches parsed chunks to stored chunks by content hash.
+// Matched parsed chunks take over the IDs of the stored chunks, and the links between
+// parsed chunks (parent, previous, next) are rewritten to the reused IDs.
+func diffKnowledgeChunks(stored []*types.Chunk, parsed []*types.Chunk) *chunkDiff {
+	diff := &chunkDiff{}
+	pool := make(map[string][]*types.Chunk)
+	for _, chunk := range stored {
+		if chunk.ChunkType == types.ChunkTypeSummary {
+			diff.summaries = append(diff.summaries, chunk)
+			continue
+		}
+		key := types.CalculateChunkContentHash(chunk.ChunkType, chunk.Content)
+		pool[key] = append(pool[key], chunk)
+	}
+
+	// Parsed chunk ID -> matched stored chunk, duplicated contents are matched in order
+	matches := make(map[string]*types.Chunk)
+	for _, chunk := range parsed {
+		key := types.CalculateChunkContentHash(chunk.ChunkType, chunk.Content)
+		if candidates := pool[key]; len(candidates) > 0 {
+			matches[chunk.ID] = candidates[0]
+			pool[key] = candidates[1:]
+		}
+	}
+	remap := func(id string) string {
+		if match, ok := matches[id]; ok {
+			return match.ID
+		}
+		return id
+	}
+
+	now := time.Now()
+	for _, chunk := range parsed {
+		match, ok := matches[chunk.ID]
+		chunk.ID = remap(chunk.ID)
+		chunk.ParentChunkID = remap(chunk.ParentChunkID)
+		chunk.PreChunkID = remap(chunk.PreChunkID)
+		chunk.NextChunkID = remap(chunk.NextChunkID)
+		if !ok {
+			diff.added = append(diff.added, chunk)
+			continue
+		}
+		if match.ChunkIndex == chunk.ChunkIndex && match.StartAt == chunk.StartAt && match.EndAt == chunk.EndAt &&
+			match.ParentChunkID == chunk.ParentChunkID && match.PreChunkID == chunk.PreChunkID &&
+			match.NextChunkID == chunk.NextChunkID && match.ImageInfo == chunk.ImageInfo &&
+			match.ContentHash == chunk.ContentHash {
+			diff.unchanged++
+			continue
+		}
+		// Keep everything attached to the stored chunk (generated questions, tags, flags), refresh its position
+		match.ChunkIndex = chunk.ChunkIndex
+		match.StartAt = chunk.StartAt
+		match.EndAt = chunk.EndAt
+		match.ParentChunkID = chunk.ParentChunkID
+		match.PreChunkID = chunk.PreChunkID
+		match.NextChunkID = chunk.NextChunkID
+		match.ImageInfo = chunk.ImageInfo
+		match.ContentHash = chunk.ContentHash
+		match.UpdatedAt = now
+		diff.moved = append(diff.moved, match)
+	}
+
+	for _, candidates := range pool {
+		diff.removed = append(diff.removed, candidates...)
+	}
+	sort.Slice(diff.removed, func(i, j int) bool {
+		return diff.removed[i].ChunkIndex < diff.removed[j].ChunkIndex
+	})
+	return diff
+}
+
+// chunkIndexInfoList builds the index information of chunk contents
+func chunkIndexInfoList(knowledge *types.Knowledge, chunks []*types.Chunk) []*types.IndexInfo {
+	filterFields := types.NewIndexFilterFields(knowledge)
+	indexInfoList := make([]*types.IndexInfo, 0, len(chunks))
+	for _, chunk := range chunks {
+		indexInfoList = append(indexInfoList, &types.IndexInfo{
+			Content:         chunk.Content,
+			SourceID:        chunk.ID,
+			SourceType:      types.ChunkSourceType,
+			ChunkID:         chunk.ID,
+			KnowledgeID:     knowledge.ID,
+			KnowledgeBaseID: knowledge.KnowledgeBaseID,
+			FilterFields:    filterFields,
+		})
+	}
+	return indexInfoList
+}
+
+// processChunksIncremental applies re-parsed chunks to a knowledge that was processed before.
+// Unchanged chunks are kept with their IDs, vectors and generated questions, new or changed chunks
+// are stored and embedded, and vanished chunks are removed from the database and every retrieval engine.
+func (s *knowledgeService) processChunksIncremental(ctx context.Context,
+	kb *types.KnowledgeBase, knowledge *types.Knowledge, chunks []*proto.Chunk, options ProcessChunksOptions,
+) {
+	ctx, span := tracing.ContextWithSpan(ctx, "knowledgeService.processChunksIncremental")
+	defer span.End()
+	span.SetAttributes(
+		attribute.Int("tenant_id", int(knowledge.TenantID)),
+		attribute.String("knowledge_base_id", knowledge.KnowledgeBaseID),
+		attribute.String("knowledge_id", knowledge.ID),
+		attribute.Int("chunk_count", len(chunks)),
+	)
+
+	markFailed := func(err error) {
+		knowledge.ParseStatus = types.ParseStatusFailed
+		knowledge.ErrorMessage = err.Error()
+		knowledge.UpdatedAt = time.Now()
+		s.repo.UpdateKnowledge(ctx, knowledge)
+		span.RecordError(err)
+	}
+
+	if s.isKnowledgeDeleting(ctx, knowledge.TenantID, knowledge.ID) {
+		logger.Infof(ctx, "Knowledge is being deleted, aborting incremental processing: %s", knowledge.ID)
+		return
+	}
+
+	embeddingModel, err := s.modelService.GetEmbeddingModel(ctx, kb.EmbeddingModelID)
+	if err != nil {
+		logger.GetLogger(ctx).WithField("error", err).Errorf("processChunksIncremental get embedding model failed")
+		markFailed(err)
+		return
+	}
+	tenantInfo := ctx.Value(types.TenantInfoContextKey).(*types.Tenant)
+	retrieveEngine, err := retriever.NewCompositeRetrieveEngine(s.retrieveEngine, tenantInfo.GetEffectiveEngines())
+	if err != nil {
+		markFailed(err)
+		return
+	}
+
+	stored, err := s.chunkRepo.ListChunksByKnowledgeIDAndTypes(ctx, knowledge.TenantID, knowledge.ID, []types.ChunkType{
+		types.ChunkTypeText, types.ChunkTypeImageOCR, types.ChunkTypeImageCaption, types.ChunkTypeSummary,
+	})
+	if err != nil {
+		logger.GetLogger(ctx).WithField("error", err).Errorf("processChunksIncremental list chunks failed")
+		markFailed(err)
+		return
+	}
+
+	parsed, textChunks := s.buildKnowledgeChunks(ctx, knowledge, chunks)
+	diff := diffKnowledgeChunks(stored, parsed)
+	if diff.changed() {
+		// The summary describes the previous content and is generated again
+		diff.removed = append(diff.removed, diff.summaries...)
+	}
+	logger.Infof(ctx, "Incremental chunk diff of knowledge %s: unchanged %d, moved %d, added %d, removed %d",
+		knowledge.ID, diff.unchanged, len(diff.moved), len(diff.added), len(diff.removed))
+	span.SetAttributes(
+		attribute.Int("added_count", len(diff.added)),
+		attribute.Int("removed_count", len(diff.removed)),
+	)
+
+	addedIndexInfo := chunkIndexInfoList(knowledge, diff.added)
+	addedSize := retrieveEngine.EstimateStorageSize(ctx, embeddingModel, addedIndexInfo)
+	removedSize := retrieveEngine.EstimateStorageSize(ctx, embeddingModel, chunkIndexInfoList(knowledge, diff.removed))
+	storageDelta := addedSize - removedSize
+	if tenantInfo.StorageQuota > 0 && storageDelta > 0 {
+		tenantInfo, err = s.tenantRepo.GetTenantByID(ctx, tenantInfo.ID)
+		if err != nil {
+			markFailed(err)
+			return
+		}
+		if tenantInfo.StorageUsed+storageDelta > tenantInfo.StorageQuota {
+			markFailed(errors.New("存储空间不足"))
+			return
+		}
+	}
+
+	if s.isKnowledgeDeleting(ctx, knowledge.TenantID, knowledge.ID) {
+		logger.Infof(ctx, "Knowledge is being deleted, aborting before saving chunks: %s", knowledge.ID)
+		return
+	}
+
+	// Store and embed the new chunks first, the previous content stays searchable until they are indexed
+	addedIDs := make([]string, 0, len(diff.added))
+	for _, chunk := range diff.added {
+		addedIDs = append(addedIDs, chunk.ID)
+	}
+	if len(diff.added) > 0 {
+		if err := s.chunkService.CreateChunks(ctx, diff.added); err != nil {
+			markFailed(err)
+			return
+		}
+		if err := retrieveEngine.BatchIndex(ctx, embeddingModel, addedIndexInfo); err != nil {
+			if err := s.chunkService.DeleteChunks(ctx, addedIDs); err != nil {
+				logger.Errorf(ctx, "Delete added chunks failed: %v", err)
+			}
+			if err := retrieveEngine.DeleteByChunkIDList(
+				ctx, addedIDs, embeddingModel.GetDimensions(), knowledge.Type,
+			); err != nil {
+				logger.Errorf(ctx, "Delete added index failed: %v", err)
+			}
+			markFailed(err)
+			return
+		}
+	}
+	for _, chunk := range diff.moved {
+		if err := s.chunkRepo.UpdateChunk(ctx, chunk); err != nil {
+			logger.GetLogger(ctx).WithField("error", err).Errorf("processChunksIncremental update chunk %s failed", chunk.ID)
+			markFailed(err)
+			return
+		}
+	}
+
+	// Remove the vanished chunks, their generated questions are indexed under the same chunk IDs
+	if len(diff.removed) > 0 {
+		removedIDs := make([]string, 0, len(diff.removed))
+		for _, chunk := range diff.removed {
+			removedIDs = append(removedIDs, chunk.ID)
+		}
+		if err := retrieveEngine.DeleteByChunkIDList(
+			ctx, removedIDs, embeddingModel.GetDimensions(), knowledge.Type,
+		); err != nil {
+			logger.GetLogger(ctx).WithField("error", err).Errorf("processChunksIncremental delete index failed")
+			markFailed(err)
+			return
+		}
+		if err := s.chunkService.DeleteChunks(ctx, removedIDs); err != nil {
+			logger.GetLogger(ctx).WithField("error", err).Errorf("processChunksIncremental delete chunks failed")
+			markFailed(err)
+			return
+		}
+	}
+
+	// The kept chunks still carry the filter fields of the previous metadata
+	if err := retrieveEngine.BatchUpdateKnowledgeFilterFields(ctx, map[string]*types.IndexFilterFields{
+		knowledge.ID: types.NewIndexFilterFields(knowledge),
+	}); err != nil {
+		logger.GetLogger(ctx).WithField("error", err).Errorf("processChunksIncremental update filter fields failed")
+		markFailed(err)
+		return
+	}
+
+	// The graph is stored per knowledge, rebuild it from all text chunks
+	if diff.changed() && kb.ExtractConfig != nil && kb.ExtractConfig.Enabled {
+		namespace := types.NameSpace{KnowledgeBase: knowledge.KnowledgeBaseID, Knowledge: knowledge.ID}
+		if err := s.graphEngine.DelGraph(ctx, []types.NameSpace{namespace}); err != nil {
+			logger.Warnf(ctx, "Failed to delete existing graph data: %v", err)
+		}
+		for _, chunk := range textChunks {
+			if err := NewChunkExtractTask(ctx, s.task, chunk.TenantID, chunk.ID, kb.SummaryModelID); err != nil {
+				logger.GetLogger(ctx).WithField("error", err).Errorf("processChunksIncremental create chunk extract task failed")
+			}
+		}
+	}
+
+	knowledge.ParseStatus = types.ParseStatusCompleted
+	knowledge.EnableStatus = "enabled"
+	knowledge.StorageSize += storageDelta
+	if knowledge.StorageSize < 0 {
+		knowledge.StorageSize = 0
+	}
+	now := time.Now()
+	knowledge.ProcessedAt = &now
+	knowledge.UpdatedAt = now
+	regenerateSummary := diff.changed() && len(textChunks) > 0
+	if regenerateSummary {
+		knowledge.SummaryStatus = types.SummaryStatusPending
+	}
+	if err := s.repo.UpdateKnowledge(ctx, knowledge); err != nil {
+		logger.GetLogger(ctx).WithField("error", err).Errorf("processChunksIncremental update knowledge failed")
+	}
+	if diff.changed() {
+		s.invalidateAnswerCache(ctx, knowledge.KnowledgeBaseID)
+	}
+
+	// Only the new text chunks need generated questions, the kept ones still have theirs
+	if options.EnableQuestionGeneration {
+		addedTextIDs := make([]string, 0, len(diff.added))
+		for _, chunk := range diff.added {
+			if chunk.ChunkType == types.ChunkTypeText {
+				addedTextIDs = append(addedTextIDs, chunk.ID)
+			}
+		}
+		if len(addedTextIDs) > 0 {
+			questionCount := options.QuestionCount
+			if questionCount <= 0 {
+				questionCount = 3
+			}
+			if questionCount > 10 {
+				questionCount = 10
+			}
+			s.enqueueQuestionGenerationTask(ctx, knowledge.KnowledgeBaseID, knowledge.ID, questionCount, addedTextIDs)
+		}
+	}
+	if regenerateSummary {
+		s.enqueueSummaryGenerationTask(ctx, knowledge.KnowledgeBaseID, knowledge.ID)
+	}
+
+	if storageDelta != 0 {
+		tenantInfo.StorageUsed += storageDelta
+		if err := s.tenantRepo.AdjustStorageUsed(ctx, tenantInfo.ID, storageDelta); err != nil {
+			logger.GetLogger(ctx).WithField("error", err).Errorf("processChunksIncremental update tenant storage used failed")
+		}
+	}
+	logger.Infof(ctx, "processChunksIncremental successfully, knowledge: %s", knowledge.ID)
+}
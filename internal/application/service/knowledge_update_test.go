@@ -0,0 +1,173 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testChunk creates a text chunk at the given index with its content hash
+func testChunk(id, content string, index int) *types.Chunk {
+	return &types.Chunk{
+		ID:          id,
+		ChunkType:   types.ChunkTypeText,
+		Content:     content,
+		ChunkIndex:  index,
+		ContentHash: types.CalculateChunkContentHash(types.ChunkTypeText, content),
+	}
+}
+
+// linkChunks links the chunks to their previous and next chunks
+func linkChunks(chunks ...*types.Chunk) []*types.Chunk {
+	for i, chunk := range chunks {
+		if i > 0 {
+			chunk.PreChunkID = chunks[i-1].ID
+		}
+		if i < len(chunks)-1 {
+			chunk.NextChunkID = chunks[i+1].ID
+		}
+	}
+	return chunks
+}
+
+func chunkIDs(chunks []*types.Chunk) []string {
+	var ids []string
+	for _, chunk := range chunks {
+		ids = append(ids, chunk.ID)
+	}
+	return ids
+}
+
+func TestDiffKnowledgeChunks(t *testing.T) {
+	summary := testChunk("s-summary", "summary", 0)
+	summary.ChunkType = types.ChunkTypeSummary
+	ocr := testChunk("p1", "a", 0)
+	ocr.ChunkType = types.ChunkTypeImageOCR
+	ocr.ContentHash = types.CalculateChunkContentHash(types.ChunkTypeImageOCR, "a")
+
+	tests := []struct {
+		name      string
+		stored    []*types.Chunk
+		parsed    []*types.Chunk
+		unchanged int
+		moved     []string
+		added     []string
+		removed   []string
+		summaries []string
+		// IDs of the parsed chunks after the diff
+		parsedIDs []string
+		changed   bool
+	}{
+		{
+			name:      "unchanged",
+			stored:    linkChunks(testChunk("s1", "a", 0), testChunk("s2", "b", 1)),
+			parsed:    linkChunks(testChunk("p1", "a", 0), testChunk("p2", "b", 1)),
+			unchanged: 2,
+			parsedIDs: []string{"s1", "s2"},
+		},
+		{
+			name:      "moved after an inserted chunk",
+			stored:    linkChunks(testChunk("s1", "a", 0), testChunk("s2", "b", 1)),
+			parsed:    linkChunks(testChunk("p0", "new", 0), testChunk("p1", "a", 1), testChunk("p2", "b", 2)),
+			moved:     []string{"s1", "s2"},
+			added:     []string{"p0"},
+			parsedIDs: []string{"p0", "s1", "s2"},
+			changed:   true,
+		},
+		{
+			name:      "changed content",
+			stored:    []*types.Chunk{testChunk("s1", "a", 0), testChunk("s2", "b", 1)},
+			parsed:    []*types.Chunk{testChunk("p1", "a", 0), testChunk("p2", "b changed", 1)},
+			unchanged: 1,
+			added:     []string{"p2"},
+			removed:   []string{"s2"},
+			parsedIDs: []string{"s1", "p2"},
+			changed:   true,
+		},
+		{
+			name:      "removed",
+			stored:    []*types.Chunk{testChunk("s1", "a", 0), testChunk("s2", "b", 1), testChunk("s3", "c", 2)},
+			parsed:    []*types.Chunk{testChunk("p1", "a", 0), testChunk("p3", "c", 1)},
+			unchanged: 1,
+			moved:     []string{"s3"},
+			removed:   []string{"s2"},
+			parsedIDs: []string{"s1", "s3"},
+			changed:   true,
+		},
+		{
+			name:      "duplicate content matched in order",
+			stored:    []*types.Chunk{testChunk("s1", "x", 0), testChunk("s2", "x", 1)},
+			parsed:    []*types.Chunk{testChunk("p1", "x", 0), testChunk("p2", "x", 1), testChunk("p3", "x", 2)},
+			unchanged: 2,
+			added:     []string{"p3"},
+			parsedIDs: []string{"s1", "s2", "p3"},
+			changed:   true,
+		},
+		{
+			name:      "fewer duplicates",
+			stored:    []*types.Chunk{testChunk("s1", "x", 0), testChunk("s2", "x", 1), testChunk("s3", "x", 2)},
+			parsed:    []*types.Chunk{testChunk("p1", "x", 0), testChunk("p2", "x", 1)},
+			unchanged: 2,
+			removed:   []string{"s3"},
+			parsedIDs: []string{"s1", "s2"},
+			changed:   true,
+		},
+		{
+			name:      "same content of another chunk type",
+			stored:    []*types.Chunk{testChunk("s1", "a", 0)},
+			parsed:    []*types.Chunk{ocr},
+			added:     []string{"p1"},
+			removed:   []string{"s1"},
+			parsedIDs: []string{"p1"},
+			changed:   true,
+		},
+		{
+			name:      "summaries are kept apart",
+			stored:    []*types.Chunk{summary, testChunk("s1", "a", 0)},
+			parsed:    []*types.Chunk{testChunk("p1", "a", 0)},
+			unchanged: 1,
+			summaries: []string{"s-summary"},
+			parsedIDs: []string{"s1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := diffKnowledgeChunks(tt.stored, tt.parsed)
+
+			assert.Equal(t, tt.unchanged, diff.unchanged)
+			assert.ElementsMatch(t, tt.moved, chunkIDs(diff.moved))
+			assert.ElementsMatch(t, tt.added, chunkIDs(diff.added))
+			// Removed chunks are sorted by their position
+			assert.Equal(t, tt.removed, chunkIDs(diff.removed))
+			assert.ElementsMatch(t, tt.summaries, chunkIDs(diff.summaries))
+			assert.Equal(t, tt.parsedIDs, chunkIDs(tt.parsed))
+			assert.Equal(t, tt.changed, diff.changed())
+		})
+	}
+}
+
+func TestDiffKnowledgeChunksMoved(t *testing.T) {
+	stored := linkChunks(testChunk("s1", "a", 0), testChunk("s2", "b", 1))
+	stored[0].TagID = "tag1"
+	parsed := linkChunks(testChunk("p0", "new", 0), testChunk("p1", "a", 1), testChunk("p2", "b", 2))
+	parsed[1].StartAt, parsed[1].EndAt = 3, 4
+
+	diff := diffKnowledgeChunks(stored, parsed)
+
+	// Moved chunks keep what is attached to the stored chunk and take the new position and links
+	require.Len(t, diff.moved, 2)
+	require.Len(t, diff.added, 1)
+	moved := diff.moved[0]
+	assert.Equal(t, "s1", moved.ID)
+	assert.Equal(t, "tag1", moved.TagID)
+	assert.Equal(t, 1, moved.ChunkIndex)
+	assert.Equal(t, 3, moved.StartAt)
+	assert.Equal(t, 4, moved.EndAt)
+	assert.Equal(t, "p0", moved.PreChunkID)
+	assert.Equal(t, "s2", moved.NextChunkID)
+	assert.False(t, moved.UpdatedAt.IsZero())
+	// Links of the added chunk point to the reused IDs
+	assert.Equal(t, "s1", diff.added[0].NextChunkID)
+}
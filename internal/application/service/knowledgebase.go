@@ -15,6 +15,7 @@ import (
 	"github.com/Tencent/WeKnora/internal/types/interfaces"
 	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
+	"gorm.io/gorm"
 )
 
 // ErrInvalidTenantID represents an error for invalid tenant ID
@@ -31,6 +32,7 @@ type knowledgeBaseService struct {
 	fileSvc        interfaces.FileService
 	graphEngine    interfaces.RetrieveGraphRepository
 	asynqClient    *asynq.Client
+	tagRepo        interfaces.KnowledgeTagRepository
 }
 
 // NewKnowledgeBaseService creates a new knowledge base service
@@ -43,6 +45,7 @@ func NewKnowledgeBaseService(repo interfaces.KnowledgeBaseRepository,
 	fileSvc interfaces.FileService,
 	graphEngine interfaces.RetrieveGraphRepository,
 	asynqClient *asynq.Client,
+	tagRepo interfaces.KnowledgeTagRepository,
 ) interfaces.KnowledgeBaseService {
 	return &knowledgeBaseService{
 		repo:           repo,
@@ -54,6 +57,7 @@ func NewKnowledgeBaseService(repo interfaces.KnowledgeBaseRepository,
 		fileSvc:        fileSvc,
 		graphEngine:    graphEngine,
 		asynqClient:    asynqClient,
+		tagRepo:        tagRepo,
 	}
 }
 
@@ -201,6 +205,21 @@ func (s *knowledgeBaseService) UpdateKnowledgeBase(ctx context.Context,
 	if config.FAQConfig != nil {
 		kb.FAQConfig = config.FAQConfig
 	}
+	// Update retrieval config if provided
+	if config.RetrievalConfig != nil {
+		kb.RetrievalConfig = config.RetrievalConfig
+	}
+	// Update answer cache config if provided
+	if config.AnswerCacheConfig != nil {
+		kb.AnswerCacheConfig = config.AnswerCacheConfig
+	}
+	// Update pipeline config if provided, an empty pipeline restores the default one
+	if config.PipelineConfig != nil {
+		kb.PipelineConfig = config.PipelineConfig
+		if len(kb.PipelineConfig.Stages) == 0 {
+			kb.PipelineConfig = nil
+		}
+	}
 	kb.UpdatedAt = time.Now()
 	kb.EnsureDefaults()
 
@@ -461,6 +480,21 @@ func (s *knowledgeBaseService) CopyKnowledgeBase(ctx context.Context,
 			cfg := *sourceKB.FAQConfig
 			faqConfig = &cfg
 		}
+		var retrievalConfig *types.RetrievalConfig
+		if sourceKB.RetrievalConfig != nil {
+			cfg := *sourceKB.RetrievalConfig
+			retrievalConfig = &cfg
+		}
+		var answerCacheConfig *types.AnswerCacheConfig
+		if sourceKB.AnswerCacheConfig != nil {
+			cfg := *sourceKB.AnswerCacheConfig
+			answerCacheConfig = &cfg
+		}
+		var pipelineConfig *types.PipelineConfig
+		if sourceKB.PipelineConfig != nil {
+			cfg := types.PipelineConfig{Stages: slices.Clone(sourceKB.PipelineConfig.Stages)}
+			pipelineConfig = &cfg
+		}
 		targetKB = &types.KnowledgeBase{
 			ID:                    uuid.New().String(),
 			Name:                  sourceKB.Name,
@@ -474,6 +508,9 @@ func (s *knowledgeBaseService) CopyKnowledgeBase(ctx context.Context,
 			VLMConfig:             sourceKB.VLMConfig,
 			StorageConfig:         sourceKB.StorageConfig,
 			FAQConfig:             faqConfig,
+			RetrievalConfig:       retrievalConfig,
+			AnswerCacheConfig:     answerCacheConfig,
+			PipelineConfig:        pipelineConfig,
 		}
 		targetKB.EnsureDefaults()
 		if err := s.repo.CreateKnowledgeBase(ctx, targetKB); err != nil {
@@ -483,6 +520,30 @@ func (s *knowledgeBaseService) CopyKnowledgeBase(ctx context.Context,
 	return sourceKB, targetKB, nil
 }
 
+// resolveFilterTags replaces the conditions on tag names of a search filter by conditions on tag IDs,
+// which the retrieval engines index
+func (s *knowledgeBaseService) resolveFilterTags(ctx context.Context,
+	tenantID uint64, kbID string, filter *types.RetrievalFilter,
+) (*types.RetrievalFilter, error) {
+	if filter == nil {
+		return nil, nil
+	}
+	return filter.ResolveTagNames(func(names []string) ([]string, error) {
+		ids := make([]string, 0, len(names))
+		for _, name := range names {
+			tag, err := s.tagRepo.GetByName(ctx, tenantID, kbID, name)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			ids = append(ids, tag.ID)
+		}
+		return ids, nil
+	})
+}
+
 // HybridSearch performs hybrid search, including vector retrieval and keyword retrieval
 func (s *knowledgeBaseService) HybridSearch(ctx context.Context,
 	id string,
@@ -511,6 +572,15 @@ func (s *knowledgeBaseService) HybridSearch(ctx context.Context,
 		return nil, err
 	}
 
+	// The engines index the tag IDs of the documents, not the tag names
+	filter, err := s.resolveFilterTags(ctx, kb.TenantID, id, params.Filter)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, map[string]interface{}{
+			"knowledge_base_id": id,
+		})
+		return nil, err
+	}
+
 	matchCount := params.MatchCount * 3
 
 	// Add vector retrieval params if supported
@@ -542,6 +612,7 @@ func (s *knowledgeBaseService) HybridSearch(ctx context.Context,
 			Threshold:        params.VectorThreshold,
 			RetrieverType:    types.VectorRetrieverType,
 			KnowledgeIDs:     params.KnowledgeIDs,
+			Filter:           filter,
 		}
 
 		// For FAQ knowledge base, use FAQ index
@@ -564,6 +635,7 @@ func (s *knowledgeBaseService) HybridSearch(ctx context.Context,
 			Threshold:        params.KeywordThreshold,
 			RetrieverType:    types.KeywordsRetrieverType,
 			KnowledgeIDs:     params.KnowledgeIDs,
+			Filter:           filter,
 		})
 		logger.Info(ctx, "Keyword retrieval parameters setup completed")
 	}
@@ -587,7 +659,7 @@ func (s *knowledgeBaseService) HybridSearch(ctx context.Context,
 	// Collect all results from different retrievers and deduplicate by chunk ID
 	logger.Infof(ctx, "Processing retrieval results")
 
-	// Separate results by retriever type for RRF fusion
+	// Separate results by retriever type for fusion
 	var vectorResults []*types.IndexWithScore
 	var keywordResults []*types.IndexWithScore
 	for _, retrieveResult := range retrieveResults {
@@ -636,80 +708,22 @@ func (s *knowledgeBaseService) HybridSearch(ctx context.Context,
 		})
 		logger.Infof(ctx, "Result count after deduplication: %d", len(deduplicatedChunks))
 	} else {
-		// Use RRF (Reciprocal Rank Fusion) to merge results from multiple retrievers
-		// RRF score = sum(1 / (k + rank)) for each retriever where the chunk appears
-		// k=60 is a common choice that works well in practice
-		const rrfK = 60
-
-		// Build rank maps for each retriever (already sorted by score from retriever)
-		vectorRanks := make(map[string]int)
-		for i, r := range vectorResults {
-			if _, exists := vectorRanks[r.ChunkID]; !exists {
-				vectorRanks[r.ChunkID] = i + 1 // 1-indexed rank
-			}
-		}
-		keywordRanks := make(map[string]int)
-		for i, r := range keywordResults {
-			if _, exists := keywordRanks[r.ChunkID]; !exists {
-				keywordRanks[r.ChunkID] = i + 1 // 1-indexed rank
-			}
-		}
-
-		// Collect all unique chunks and compute RRF scores
-		chunkInfoMap := make(map[string]*types.IndexWithScore)
-		rrfScores := make(map[string]float64)
-
-		// Process vector results
-		for _, r := range vectorResults {
-			if _, exists := chunkInfoMap[r.ChunkID]; !exists {
-				chunkInfoMap[r.ChunkID] = r
-			}
-		}
-		// Process keyword results
-		for _, r := range keywordResults {
-			if _, exists := chunkInfoMap[r.ChunkID]; !exists {
-				chunkInfoMap[r.ChunkID] = r
-			}
-		}
-
-		// Compute RRF scores
-		for chunkID := range chunkInfoMap {
-			rrfScore := 0.0
-			if rank, ok := vectorRanks[chunkID]; ok {
-				rrfScore += 1.0 / float64(rrfK+rank)
-			}
-			if rank, ok := keywordRanks[chunkID]; ok {
-				rrfScore += 1.0 / float64(rrfK+rank)
-			}
-			rrfScores[chunkID] = rrfScore
+		// Fuse results from multiple retrievers with the configured fusion mode,
+		// the search params may override the knowledge base configuration
+		fusionConfig := kb.RetrievalConfig.WithDefaults()
+		if params.Fusion != nil {
+			fusionConfig = params.Fusion.WithDefaults()
 		}
+		deduplicatedChunks = retriever.FuseResults(fusionConfig, vectorResults, keywordResults)
+		logger.Infof(ctx, "Result count after %s fusion: %d", fusionConfig.FusionMode, len(deduplicatedChunks))
 
-		// Convert to slice and sort by RRF score
-		deduplicatedChunks = make([]*types.IndexWithScore, 0, len(chunkInfoMap))
-		for chunkID, info := range chunkInfoMap {
-			// Store RRF score in the Score field for downstream processing
-			info.Score = rrfScores[chunkID]
-			deduplicatedChunks = append(deduplicatedChunks, info)
-		}
-		slices.SortFunc(deduplicatedChunks, func(a, b *types.IndexWithScore) int {
-			if a.Score > b.Score {
-				return -1
-			} else if a.Score < b.Score {
-				return 1
-			}
-			return 0
-		})
-
-		logger.Infof(ctx, "Result count after RRF fusion: %d", len(deduplicatedChunks))
-
-		// Log top results after RRF fusion for debugging
+		// Log top results after fusion for debugging
 		for i, chunk := range deduplicatedChunks {
-			if i < 15 {
-				vRank, vOk := vectorRanks[chunk.ChunkID]
-				kRank, kOk := keywordRanks[chunk.ChunkID]
-				logger.Debugf(ctx, "RRF rank %d: chunk_id=%s, rrf_score=%.6f, vector_rank=%v(%v), keyword_rank=%v(%v)",
-					i, chunk.ChunkID, chunk.Score, vRank, vOk, kRank, kOk)
+			if i >= 15 {
+				break
 			}
+			logger.Debugf(ctx, "Fusion rank %d: chunk_id=%s, fused_score=%.6f, match_type=%v",
+				i, chunk.ChunkID, chunk.Score, chunk.MatchType)
 		}
 	}
 
@@ -0,0 +1,387 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/application/repository"
+	"github.com/Tencent/WeKnora/internal/common"
+	werrors "github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/models/chat"
+	"github.com/Tencent/WeKnora/internal/models/embedding"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxUserMemories bounds the memories kept per user, the least recently updated ones are forgotten first
+	maxUserMemories = 200
+	// memoryRecallTopK is the number of memories recalled into the prompt of the agent
+	memoryRecallTopK = 5
+	// memoryRecallThreshold is the cosine similarity a memory needs to be recalled for a query
+	memoryRecallThreshold = 0.3
+	// memoryDuplicateThreshold is the cosine similarity above which an extracted memory supersedes an existing one
+	memoryDuplicateThreshold = 0.9
+	// memoryExtractionContextSize is the number of related existing memories shown to the chat model on extraction
+	memoryExtractionContextSize = 10
+	// memoryAnswerMaxRunes bounds the part of the answer sent to the chat model on extraction
+	memoryAnswerMaxRunes = 2000
+)
+
+// memoryExtractionPrompt asks the chat model for the durable facts and preferences of the user in a conversation
+const memoryExtractionPrompt = `You maintain the long-term memory of an assistant about one user.
+Read the user's message and the assistant's answer, and extract the durable facts and preferences about the user
+that will still be useful in future, unrelated conversations, for example the products they work on,
+their environment and versions, their role, or how they like answers to be written.
+
+Rules:
+- Only extract what the user stated or clearly implied about themselves, never facts from the answer or documents.
+- Skip the question itself, one-off details and anything only relevant to the current task.
+- Write each memory as one self-contained sentence about "the user", in the language of the user.
+- If a memory updates one of the known memories below, write the updated sentence.
+- Do not repeat known memories that did not change.
+- Use the category "preference" for how the user wants to be answered and "fact" for everything else.
+
+Known memories:
+%s
+
+Answer with a JSON array only, such as [{"content": "The user runs WeKnora 0.3 on Kubernetes", "category": "fact"}],
+or [] when there is nothing worth remembering.`
+
+// memoryService implements interfaces.MemoryService
+type memoryService struct {
+	repo         interfaces.MemoryRepository
+	modelService interfaces.ModelService
+}
+
+// NewMemoryService creates a new user memory service
+func NewMemoryService(
+	repo interfaces.MemoryRepository,
+	modelService interfaces.ModelService,
+) interfaces.MemoryService {
+	return &memoryService{
+		repo:         repo,
+		modelService: modelService,
+	}
+}
+
+// memoryOwner returns the tenant and user of the request, memories belong to users and
+// requests authenticated with an API key alone have none
+func memoryOwner(ctx context.Context) (uint64, string, bool) {
+	principal := types.PrincipalFromContext(ctx)
+	if principal == nil || principal.UserID == "" {
+		return 0, "", false
+	}
+	tenantID, ok := ctx.Value(types.TenantIDContextKey).(uint64)
+	return tenantID, principal.UserID, ok
+}
+
+// RecallMemories returns the memories of the current user most relevant to the query
+func (s *memoryService) RecallMemories(
+	ctx context.Context, embeddingModelID string, query string,
+) ([]*types.UserMemory, error) {
+	tenantID, userID, ok := memoryOwner(ctx)
+	if !ok || strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+	memories, err := s.repo.ListByUser(ctx, tenantID, userID)
+	if err != nil || len(memories) == 0 {
+		return nil, err
+	}
+
+	embedder, err := s.getEmbedder(ctx, embeddingModelID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.refreshEmbeddings(ctx, embedder, memories); err != nil {
+		return nil, err
+	}
+	queryEmbedding, err := embedder.Embed(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	recalled := topMemories(memories, queryEmbedding, memoryRecallTopK, memoryRecallThreshold)
+	logger.Infof(ctx, "Recalled %d of %d memories of user %s", len(recalled), len(memories), userID)
+	return recalled, nil
+}
+
+// ExtractMemories extracts the durable facts and preferences of the current user from a question and its answer
+func (s *memoryService) ExtractMemories(
+	ctx context.Context, chatModel chat.Chat, embeddingModelID string,
+	sessionID string, query string, answer string,
+) error {
+	tenantID, userID, ok := memoryOwner(ctx)
+	if !ok || strings.TrimSpace(query) == "" {
+		return nil
+	}
+	memories, err := s.repo.ListByUser(ctx, tenantID, userID)
+	if err != nil {
+		return err
+	}
+	embedder, err := s.getEmbedder(ctx, embeddingModelID)
+	if err != nil {
+		return err
+	}
+	if err := s.refreshEmbeddings(ctx, embedder, memories); err != nil {
+		return err
+	}
+
+	// Show the chat model the memories related to the question, so that it updates them instead of repeating them
+	known := memories
+	if len(memories) > memoryExtractionContextSize {
+		queryEmbedding, err := embedder.Embed(ctx, query)
+		if err != nil {
+			return fmt.Errorf("failed to embed query: %w", err)
+		}
+		known = topMemories(memories, queryEmbedding, memoryExtractionContextSize, 0)
+	}
+	knownList := "(none)"
+	if len(known) > 0 {
+		lines := make([]string, 0, len(known))
+		for _, memory := range known {
+			lines = append(lines, "- "+memory.Content)
+		}
+		knownList = strings.Join(lines, "\n")
+	}
+
+	if runes := []rune(answer); len(runes) > memoryAnswerMaxRunes {
+		answer = string(runes[:memoryAnswerMaxRunes]) + "..."
+	}
+	thinking := false
+	resp, err := chatModel.Chat(ctx, []chat.Message{
+		{Role: "system", Content: fmt.Sprintf(memoryExtractionPrompt, knownList)},
+		{Role: "user", Content: fmt.Sprintf("User message:\n%s\n\nAssistant answer:\n%s", query, answer)},
+	}, &chat.ChatOptions{
+		Temperature: DefaultLLMTemperature,
+		Thinking:    &thinking,
+	})
+	if err != nil {
+		return fmt.Errorf("memory extraction failed: %w", err)
+	}
+	var extracted []types.ExtractedMemory
+	if err := common.ParseLLMJsonResponse(resp.Content, &extracted); err != nil {
+		return fmt.Errorf("failed to parse memory extraction response: %w", err)
+	}
+
+	contents := make([]string, 0, len(extracted))
+	categories := make([]types.MemoryCategory, 0, len(extracted))
+	for _, item := range extracted {
+		content := strings.TrimSpace(item.Content)
+		if content == "" {
+			continue
+		}
+		if item.Category != types.MemoryCategoryPreference {
+			item.Category = types.MemoryCategoryFact
+		}
+		contents = append(contents, content)
+		categories = append(categories, item.Category)
+	}
+	if len(contents) == 0 {
+		logger.Infof(ctx, "No memories extracted for user %s from session %s", userID, sessionID)
+		return nil
+	}
+	embeddings, err := embedder.BatchEmbed(ctx, contents)
+	if err != nil {
+		return fmt.Errorf("failed to embed memories: %w", err)
+	}
+
+	created, updated := 0, 0
+	// Each memory is matched once, so that two extracted memories never overwrite the same one
+	matched := make(map[string]bool)
+	for i, content := range contents {
+		if i >= len(embeddings) {
+			break
+		}
+		now := time.Now()
+		// A memory close to an existing one is a restatement or an update of it
+		unmatched := slices.DeleteFunc(slices.Clone(memories), func(m *types.UserMemory) bool {
+			return matched[m.ID]
+		})
+		if existing := topMemories(unmatched, embeddings[i], 1, memoryDuplicateThreshold); len(existing) > 0 {
+			memory := existing[0]
+			matched[memory.ID] = true
+			memory.Content = content
+			memory.Category = categories[i]
+			memory.SourceSessionID = sessionID
+			memory.Embedding = embeddings[i]
+			memory.UpdatedAt = now
+			if err := s.repo.Update(ctx, memory); err != nil {
+				return err
+			}
+			updated++
+			continue
+		}
+		memory := &types.UserMemory{
+			ID:               uuid.New().String(),
+			TenantID:         tenantID,
+			UserID:           userID,
+			Content:          content,
+			Category:         categories[i],
+			SourceSessionID:  sessionID,
+			EmbeddingModelID: embedder.GetModelID(),
+			Embedding:        embeddings[i],
+			CreatedAt:        now,
+			UpdatedAt:        now,
+		}
+		if err := s.repo.Create(ctx, memory); err != nil {
+			return err
+		}
+		memories = append(memories, memory)
+		matched[memory.ID] = true
+		created++
+	}
+	logger.Infof(ctx, "Extracted memories of user %s from session %s, created: %d, updated: %d",
+		userID, sessionID, created, updated)
+
+	return s.forgetOldest(ctx, tenantID, userID, memories)
+}
+
+// forgetOldest deletes the least recently updated memories of a user beyond maxUserMemories
+func (s *memoryService) forgetOldest(
+	ctx context.Context, tenantID uint64, userID string, memories []*types.UserMemory,
+) error {
+	if len(memories) <= maxUserMemories {
+		return nil
+	}
+	slices.SortFunc(memories, func(a, b *types.UserMemory) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	for _, memory := range memories[maxUserMemories:] {
+		if err := s.repo.Delete(ctx, tenantID, userID, memory.ID); err != nil {
+			return err
+		}
+	}
+	logger.Infof(ctx, "Forgot %d old memories of user %s", len(memories)-maxUserMemories, userID)
+	return nil
+}
+
+// ListMemories lists the memories of the current user, newest first
+func (s *memoryService) ListMemories(ctx context.Context) ([]*types.UserMemory, error) {
+	tenantID, userID, ok := memoryOwner(ctx)
+	if !ok {
+		return nil, werrors.NewForbiddenError("记忆属于用户，请使用用户账号登录")
+	}
+	return s.repo.ListByUser(ctx, tenantID, userID)
+}
+
+// DeleteMemory deletes a memory of the current user
+func (s *memoryService) DeleteMemory(ctx context.Context, id string) error {
+	tenantID, userID, ok := memoryOwner(ctx)
+	if !ok {
+		return werrors.NewForbiddenError("记忆属于用户，请使用用户账号登录")
+	}
+	if _, err := s.repo.GetByID(ctx, tenantID, userID, id); err != nil {
+		if errors.Is(err, repository.ErrMemoryNotFound) {
+			return werrors.NewNotFoundError("记忆不存在")
+		}
+		return err
+	}
+	if err := s.repo.Delete(ctx, tenantID, userID, id); err != nil {
+		return err
+	}
+	logger.Infof(ctx, "Memory %s of user %s deleted", id, userID)
+	return nil
+}
+
+// DeleteAllMemories deletes all memories of the current user
+func (s *memoryService) DeleteAllMemories(ctx context.Context) error {
+	tenantID, userID, ok := memoryOwner(ctx)
+	if !ok {
+		return werrors.NewForbiddenError("记忆属于用户，请使用用户账号登录")
+	}
+	if err := s.repo.DeleteByUser(ctx, tenantID, userID); err != nil {
+		return err
+	}
+	logger.Infof(ctx, "All memories of user %s deleted", userID)
+	return nil
+}
+
+// getEmbedder returns the embedding model of the memories, the first embedding model of the tenant when not configured
+func (s *memoryService) getEmbedder(ctx context.Context, modelID string) (embedding.Embedder, error) {
+	if modelID == "" {
+		models, err := s.modelService.ListModels(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list models: %w", err)
+		}
+		for _, model := range models {
+			if model != nil && model.Type == types.ModelTypeEmbedding {
+				modelID = model.ID
+				break
+			}
+		}
+		if modelID == "" {
+			return nil, errors.New("no embedding model available for memories")
+		}
+	}
+	return s.modelService.GetEmbeddingModel(ctx, modelID)
+}
+
+// refreshEmbeddings embeds again the memories embedded by another model, so that all of them compare to the query
+func (s *memoryService) refreshEmbeddings(
+	ctx context.Context, embedder embedding.Embedder, memories []*types.UserMemory,
+) error {
+	stale := make([]*types.UserMemory, 0)
+	contents := make([]string, 0)
+	for _, memory := range memories {
+		if memory.EmbeddingModelID != embedder.GetModelID() || len(memory.Embedding) == 0 {
+			stale = append(stale, memory)
+			contents = append(contents, memory.Content)
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	embeddings, err := embedder.BatchEmbed(ctx, contents)
+	if err != nil {
+		return fmt.Errorf("failed to embed memories: %w", err)
+	}
+	for i, memory := range stale {
+		if i >= len(embeddings) {
+			break
+		}
+		memory.EmbeddingModelID = embedder.GetModelID()
+		memory.Embedding = embeddings[i]
+		if err := s.repo.Update(ctx, memory); err != nil {
+			return err
+		}
+	}
+	logger.Infof(ctx, "Embedded %d memories with model %s", len(stale), embedder.GetModelID())
+	return nil
+}
+
+// topMemories returns up to limit memories most similar to the embedding, with a similarity of at least threshold
+func topMemories(
+	memories []*types.UserMemory, queryEmbedding []float32, limit int, threshold float64,
+) []*types.UserMemory {
+	type scoredMemory struct {
+		memory *types.UserMemory
+		score  float64
+	}
+	scored := make([]scoredMemory, 0, len(memories))
+	for _, memory := range memories {
+		if score := cosineSimilarity(memory.Embedding, queryEmbedding); score >= threshold {
+			scored = append(scored, scoredMemory{memory: memory, score: score})
+		}
+	}
+	slices.SortFunc(scored, func(a, b scoredMemory) int {
+		switch {
+		case a.score > b.score:
+			return -1
+		case a.score < b.score:
+			return 1
+		}
+		return 0
+	})
+	result := make([]*types.UserMemory, 0, min(limit, len(scored)))
+	for _, item := range scored[:min(limit, len(scored))] {
+		result = append(result, item.memory)
+	}
+	return result
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryIDs returns the IDs of memories, in order
+func memoryIDs(memories []*types.UserMemory) []string {
+	ids := make([]string, 0, len(memories))
+	for _, memory := range memories {
+		ids = append(ids, memory.ID)
+	}
+	return ids
+}
+
+func TestTopMemories(t *testing.T) {
+	memories := []*types.UserMemory{
+		// cosine similarities to the query [1, 0]: 0.6, 1, 0, 0.8, -1
+		{ID: "m1", Embedding: []float32{3, 4}},
+		{ID: "m2", Embedding: []float32{2, 0}},
+		{ID: "m3", Embedding: []float32{0, 1}},
+		{ID: "m4", Embedding: []float32{4, 3}},
+		{ID: "m5", Embedding: []float32{-1, 0}},
+		// Memories embedded with another model are never similar
+		{ID: "m6", Embedding: []float32{1, 0, 0}},
+		{ID: "m7"},
+	}
+	query := []float32{1, 0}
+	tests := []struct {
+		name      string
+		limit     int
+		threshold float64
+		expected  []string
+	}{
+		{"most similar first", 10, 0.5, []string{"m2", "m4", "m1"}},
+		{"limit", 2, 0.5, []string{"m2", "m4"}},
+		{"threshold is inclusive", 10, 0.8, []string{"m2", "m4"}},
+		{"nothing above the threshold", 10, 1.1, []string{}},
+		{"no threshold", 3, 0, []string{"m2", "m4", "m1"}},
+		{"no limit", 0, 0.5, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, memoryIDs(topMemories(memories, query, tt.limit, tt.threshold)))
+		})
+	}
+
+	// Without a threshold every memory is kept, the least similar last
+	all := topMemories(memories, query, 10, -1)
+	assert.Len(t, all, len(memories))
+	assert.Equal(t, "m5", all[len(all)-1].ID)
+}
@@ -0,0 +1,236 @@
+package metric
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Tencent/WeKnora/internal/common"
+	"github.com/Tencent/WeKnora/internal/models/chat"
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// judgeSystemPrompt is shared by all judge metrics, the texts may be Chinese or English
+const judgeSystemPrompt = "You are a strict evaluator of retrieval augmented question answering. " +
+	"The texts may be written in Chinese or English, judge them in their own language. " +
+	"Reply with JSON only, without explanations."
+
+const faithfulnessPrompt = `Break the answer into short self-contained factual statements, ` +
+	`then decide for each statement whether it can be inferred from the context.
+
+Context:
+%s
+
+Question: %s
+
+Answer: %s
+
+Reply in this format: {"statements": [{"statement": "...", "verdict": 1}]}
+where verdict is 1 if the statement is supported by the context and 0 otherwise.`
+
+const answerRelevancePrompt = `Rate how directly and completely the answer addresses the question, ` +
+	`ignoring whether it is factually correct. Evasive, off-topic or non-committal answers get a low score.
+
+Question: %s
+
+Answer: %s
+
+Reply in this format: {"score": 7} with an integer score from 0 (irrelevant) to 10 (fully relevant).`
+
+const contextPrecisionPrompt = `For each numbered context chunk decide whether it was useful ` +
+	`for arriving at the reference answer of the question.
+
+Question: %s
+
+Reference answer: %s
+
+Context chunks:
+%s
+
+Reply in this format: {"verdicts": [1, 0]} with one verdict per chunk in the given order, ` +
+	`1 if the chunk is useful and 0 otherwise.`
+
+const contextRecallPrompt = `Break the reference answer into short self-contained factual statements, ` +
+	`then decide for each statement whether it can be attributed to the context.
+
+Context:
+%s
+
+Question: %s
+
+Reference answer: %s
+
+Reply in this format: {"statements": [{"statement": "...", "verdict": 1}]}
+where verdict is 1 if the statement is found in the context and 0 otherwise.`
+
+// statementVerdicts is the judge reply listing statements and whether each one holds
+type statementVerdicts struct {
+	Statements []struct {
+		Statement string `json:"statement"`
+		Verdict   int    `json:"verdict"`
+	} `json:"statements"`
+}
+
+// score returns the share of statements with a positive verdict
+func (s *statementVerdicts) score() float64 {
+	if len(s.Statements) == 0 {
+		return 0
+	}
+	hits := 0
+	for _, statement := range s.Statements {
+		if statement.Verdict > 0 {
+			hits++
+		}
+	}
+	return float64(hits) / float64(len(s.Statements))
+}
+
+// judge sends a grading prompt to the judge model and decodes its JSON reply into target
+func judge(ctx context.Context, chatModel chat.Chat, prompt string, target interface{}) error {
+	thinking := false
+	response, err := chatModel.Chat(ctx, []chat.Message{
+		{Role: "system", Content: judgeSystemPrompt},
+		{Role: "user", Content: prompt},
+	}, &chat.ChatOptions{
+		Temperature: 0,
+		Thinking:    &thinking,
+	})
+	if err != nil {
+		return fmt.Errorf("judge model request failed: %w", err)
+	}
+	if err := common.ParseLLMJsonResponse(strings.TrimSpace(response.Content), target); err != nil {
+		return fmt.Errorf("failed to parse judge reply: %w", err)
+	}
+	return nil
+}
+
+// formatContexts joins the context chunks into one numbered block
+func formatContexts(contexts []string) string {
+	var builder strings.Builder
+	for i, c := range contexts {
+		fmt.Fprintf(&builder, "[%d] %s\n", i+1, strings.TrimSpace(c))
+	}
+	return builder.String()
+}
+
+// FaithfulnessMetric grades how well the generated answer is grounded in the retrieved context
+type FaithfulnessMetric struct {
+	chatModel chat.Chat
+}
+
+// NewFaithfulnessMetric creates a new FaithfulnessMetric instance
+func NewFaithfulnessMetric(chatModel chat.Chat) *FaithfulnessMetric {
+	return &FaithfulnessMetric{chatModel: chatModel}
+}
+
+// Compute returns the share of answer statements supported by the context
+func (m *FaithfulnessMetric) Compute(ctx context.Context, metricInput *types.MetricInput) (float64, error) {
+	if strings.TrimSpace(metricInput.GeneratedTexts) == "" || len(metricInput.Contexts) == 0 {
+		return 0, nil
+	}
+	var reply statementVerdicts
+	prompt := fmt.Sprintf(faithfulnessPrompt,
+		formatContexts(metricInput.Contexts), metricInput.Question, metricInput.GeneratedTexts)
+	if err := judge(ctx, m.chatModel, prompt, &reply); err != nil {
+		return 0, err
+	}
+	return reply.score(), nil
+}
+
+// AnswerRelevanceMetric grades how directly the generated answer addresses the question
+type AnswerRelevanceMetric struct {
+	chatModel chat.Chat
+}
+
+// NewAnswerRelevanceMetric creates a new AnswerRelevanceMetric instance
+func NewAnswerRelevanceMetric(chatModel chat.Chat) *AnswerRelevanceMetric {
+	return &AnswerRelevanceMetric{chatModel: chatModel}
+}
+
+// Compute returns the judge rating of the answer scaled to [0, 1]
+func (m *AnswerRelevanceMetric) Compute(ctx context.Context, metricInput *types.MetricInput) (float64, error) {
+	if strings.TrimSpace(metricInput.GeneratedTexts) == "" {
+		return 0, nil
+	}
+	var reply struct {
+		Score float64 `json:"score"`
+	}
+	prompt := fmt.Sprintf(answerRelevancePrompt, metricInput.Question, metricInput.GeneratedTexts)
+	if err := judge(ctx, m.chatModel, prompt, &reply); err != nil {
+		return 0, err
+	}
+	switch {
+	case reply.Score < 0:
+		return 0, nil
+	case reply.Score > 10:
+		return 1, nil
+	}
+	return reply.Score / 10, nil
+}
+
+// ContextPrecisionMetric grades whether the useful context chunks are ranked first
+type ContextPrecisionMetric struct {
+	chatModel chat.Chat
+}
+
+// NewContextPrecisionMetric creates a new ContextPrecisionMetric instance
+func NewContextPrecisionMetric(chatModel chat.Chat) *ContextPrecisionMetric {
+	return &ContextPrecisionMetric{chatModel: chatModel}
+}
+
+// Compute returns the average precision of the chunks judged useful for the reference answer
+func (m *ContextPrecisionMetric) Compute(ctx context.Context, metricInput *types.MetricInput) (float64, error) {
+	if len(metricInput.Contexts) == 0 {
+		return 0, nil
+	}
+	var reply struct {
+		Verdicts []int `json:"verdicts"`
+	}
+	prompt := fmt.Sprintf(contextPrecisionPrompt,
+		metricInput.Question, metricInput.GeneratedGT, formatContexts(metricInput.Contexts))
+	if err := judge(ctx, m.chatModel, prompt, &reply); err != nil {
+		return 0, err
+	}
+	return averagePrecision(reply.Verdicts), nil
+}
+
+// averagePrecision computes the mean of precision@k over the ranks k holding a useful chunk
+func averagePrecision(verdicts []int) float64 {
+	hits := 0
+	sumPrecision := 0.0
+	for i, verdict := range verdicts {
+		if verdict <= 0 {
+			continue
+		}
+		hits++
+		sumPrecision += float64(hits) / float64(i+1)
+	}
+	if hits == 0 {
+		return 0
+	}
+	return sumPrecision / float64(hits)
+}
+
+// ContextRecallMetric grades how much of the reference answer is covered by the retrieved context
+type ContextRecallMetric struct {
+	chatModel chat.Chat
+}
+
+// NewContextRecallMetric creates a new ContextRecallMetric instance
+func NewContextRecallMetric(chatModel chat.Chat) *ContextRecallMetric {
+	return &ContextRecallMetric{chatModel: chatModel}
+}
+
+// Compute returns the share of reference answer statements attributable to the context
+func (m *ContextRecallMetric) Compute(ctx context.Context, metricInput *types.MetricInput) (float64, error) {
+	if strings.TrimSpace(metricInput.GeneratedGT) == "" || len(metricInput.Contexts) == 0 {
+		return 0, nil
+	}
+	var reply statementVerdicts
+	prompt := fmt.Sprintf(contextRecallPrompt,
+		formatContexts(metricInput.Contexts), metricInput.Question, metricInput.GeneratedGT)
+	if err := judge(ctx, m.chatModel, prompt, &reply); err != nil {
+		return 0, err
+	}
+	return reply.score(), nil
+}
@@ -0,0 +1,127 @@
+package metric
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/models/chat"
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// fakeJudge replies with a canned response and records the last prompt
+type fakeJudge struct {
+	reply  string
+	err    error
+	prompt string
+}
+
+func (f *fakeJudge) Chat(ctx context.Context,
+	messages []chat.Message, opts *chat.ChatOptions,
+) (*types.ChatResponse, error) {
+	f.prompt = messages[len(messages)-1].Content
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &types.ChatResponse{Content: f.reply}, nil
+}
+
+func (f *fakeJudge) ChatStream(ctx context.Context,
+	messages []chat.Message, opts *chat.ChatOptions,
+) (<-chan types.StreamResponse, error) {
+	return nil, errors.New("not supported")
+}
+
+func (f *fakeJudge) GetModelName() string { return "fake" }
+
+func (f *fakeJudge) GetModelID() string { return "fake" }
+
+func judgeInput() *types.MetricInput {
+	return &types.MetricInput{
+		Question:       "公司成立于哪一年？",
+		GeneratedTexts: "公司成立于2008年，总部位于深圳。",
+		GeneratedGT:    "2008年",
+		Contexts:       []string{"公司成立于2008年。", "天气晴朗。", "总部位于深圳。"},
+	}
+}
+
+func TestFaithfulnessMetric_Compute(t *testing.T) {
+	judge := &fakeJudge{reply: "```json\n" +
+		`{"statements": [{"statement": "成立于2008年", "verdict": 1}, {"statement": "总部位于北京", "verdict": 0}]}` +
+		"\n```"}
+	score, err := NewFaithfulnessMetric(judge).Compute(context.Background(), judgeInput())
+	if err != nil {
+		t.Fatalf("Compute() error = %v", err)
+	}
+	if score != 0.5 {
+		t.Errorf("Compute() = %v, want 0.5", score)
+	}
+	if !strings.Contains(judge.prompt, "[2] 天气晴朗。") {
+		t.Errorf("prompt does not number the contexts: %s", judge.prompt)
+	}
+}
+
+func TestAnswerRelevanceMetric_Compute(t *testing.T) {
+	tests := []struct {
+		name     string
+		reply    string
+		expected float64
+	}{
+		{name: "scaled", reply: `{"score": 8}`, expected: 0.8},
+		{name: "clamped", reply: `{"score": 12}`, expected: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, err := NewAnswerRelevanceMetric(&fakeJudge{reply: tt.reply}).
+				Compute(context.Background(), judgeInput())
+			if err != nil {
+				t.Fatalf("Compute() error = %v", err)
+			}
+			if score != tt.expected {
+				t.Errorf("Compute() = %v, want %v", score, tt.expected)
+			}
+		})
+	}
+}
+
+func TestContextPrecisionMetric_Compute(t *testing.T) {
+	score, err := NewContextPrecisionMetric(&fakeJudge{reply: `{"verdicts": [1, 0, 1]}`}).
+		Compute(context.Background(), judgeInput())
+	if err != nil {
+		t.Fatalf("Compute() error = %v", err)
+	}
+	// (1/1 + 2/3) / 2
+	if !almostEqual(score, 0.8333333333333334, 1e-6) {
+		t.Errorf("Compute() = %v, want 0.8333", score)
+	}
+}
+
+func TestContextRecallMetric_Compute(t *testing.T) {
+	score, err := NewContextRecallMetric(&fakeJudge{reply: `{"statements": [{"statement": "2008年", "verdict": 1}]}`}).
+		Compute(context.Background(), judgeInput())
+	if err != nil {
+		t.Fatalf("Compute() error = %v", err)
+	}
+	if score != 1 {
+		t.Errorf("Compute() = %v, want 1", score)
+	}
+}
+
+func TestJudgeMetrics_Errors(t *testing.T) {
+	if _, err := NewFaithfulnessMetric(&fakeJudge{reply: "not json"}).
+		Compute(context.Background(), judgeInput()); err == nil {
+		t.Error("expected an error for an unparsable reply")
+	}
+	if _, err := NewContextRecallMetric(&fakeJudge{err: errors.New("timeout")}).
+		Compute(context.Background(), judgeInput()); err == nil {
+		t.Error("expected an error when the judge model fails")
+	}
+
+	// Nothing to grade without an answer, no request is sent
+	judge := &fakeJudge{}
+	score, err := NewFaithfulnessMetric(judge).Compute(context.Background(), &types.MetricInput{})
+	if err != nil || score != 0 || judge.prompt != "" {
+		t.Errorf("Compute() = %v, %v, prompt %q, want 0 without a request", score, err, judge.prompt)
+	}
+}
@@ -2,10 +2,12 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"sync"
 
 	"github.com/Tencent/WeKnora/internal/application/service/metric"
 	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/models/chat"
 	"github.com/Tencent/WeKnora/internal/types"
 	"github.com/Tencent/WeKnora/internal/types/interfaces"
 )
@@ -17,40 +19,92 @@ type MetricList struct {
 
 // metricCalculators defines all metrics to be calculated
 var metricCalculators = []struct {
+	name     string                             // Metric name, matches the JSON field of the result
 	calc     interfaces.Metrics                 // Metric calculator implementation
 	getField func(*types.MetricResult) *float64 // Field accessor for result
 }{
 	// Retrieval Metrics
-	{metric.NewPrecisionMetric(), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.Precision }},
-	{metric.NewRecallMetric(), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.Recall }},
-	{metric.NewNDCGMetric(3), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.NDCG3 }},
-	{metric.NewNDCGMetric(10), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.NDCG10 }},
-	{metric.NewMRRMetric(), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.MRR }},
-	{metric.NewMAPMetric(), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.MAP }},
+	{"precision", metric.NewPrecisionMetric(), func(r *types.MetricResult) *float64 {
+		return &r.RetrievalMetrics.Precision
+	}},
+	{"recall", metric.NewRecallMetric(), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.Recall }},
+	{"ndcg3", metric.NewNDCGMetric(3), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.NDCG3 }},
+	{"ndcg10", metric.NewNDCGMetric(10), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.NDCG10 }},
+	{"mrr", metric.NewMRRMetric(), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.MRR }},
+	{"map", metric.NewMAPMetric(), func(r *types.MetricResult) *float64 { return &r.RetrievalMetrics.MAP }},
 
 	// Generation Metrics
-	{metric.NewBLEUMetric(true, metric.BLEU1Gram), func(r *types.MetricResult) *float64 {
+	{"bleu1", metric.NewBLEUMetric(true, metric.BLEU1Gram), func(r *types.MetricResult) *float64 {
 		return &r.GenerationMetrics.BLEU1
 	}},
-	{metric.NewBLEUMetric(true, metric.BLEU2Gram), func(r *types.MetricResult) *float64 {
+	{"bleu2", metric.NewBLEUMetric(true, metric.BLEU2Gram), func(r *types.MetricResult) *float64 {
 		return &r.GenerationMetrics.BLEU2
 	}},
-	{metric.NewBLEUMetric(true, metric.BLEU4Gram), func(r *types.MetricResult) *float64 {
+	{"bleu4", metric.NewBLEUMetric(true, metric.BLEU4Gram), func(r *types.MetricResult) *float64 {
 		return &r.GenerationMetrics.BLEU4
 	}},
-	{metric.NewRougeMetric(true, "rouge-1", "f"), func(r *types.MetricResult) *float64 {
+	{"rouge1", metric.NewRougeMetric(true, "rouge-1", "f"), func(r *types.MetricResult) *float64 {
 		return &r.GenerationMetrics.ROUGE1
 	}},
-	{metric.NewRougeMetric(true, "rouge-2", "f"), func(r *types.MetricResult) *float64 {
+	{"rouge2", metric.NewRougeMetric(true, "rouge-2", "f"), func(r *types.MetricResult) *float64 {
 		return &r.GenerationMetrics.ROUGE2
 	}},
-	{metric.NewRougeMetric(true, "rouge-l", "f"), func(r *types.MetricResult) *float64 {
+	{"rougel", metric.NewRougeMetric(true, "rouge-l", "f"), func(r *types.MetricResult) *float64 {
 		return &r.GenerationMetrics.ROUGEL
 	}},
 }
 
-// Append calculates and stores metrics for given input
-func (m *MetricList) Append(metricInput *types.MetricInput) {
+// judgeCalculators defines the metrics graded by a judge model
+var judgeCalculators = []struct {
+	name     string                                            // Metric name, matches the JSON field of the result
+	newCalc  func(chatModel chat.Chat) interfaces.JudgeMetrics // Creates the calculator for a judge model
+	getField func(*types.JudgeMetrics) *float64                // Field accessor for result
+}{
+	{"faithfulness", func(c chat.Chat) interfaces.JudgeMetrics { return metric.NewFaithfulnessMetric(c) },
+		func(r *types.JudgeMetrics) *float64 { return &r.Faithfulness }},
+	{"answer_relevance", func(c chat.Chat) interfaces.JudgeMetrics { return metric.NewAnswerRelevanceMetric(c) },
+		func(r *types.JudgeMetrics) *float64 { return &r.AnswerRelevance }},
+	{"context_precision", func(c chat.Chat) interfaces.JudgeMetrics { return metric.NewContextPrecisionMetric(c) },
+		func(r *types.JudgeMetrics) *float64 { return &r.ContextPrecision }},
+	{"context_recall", func(c chat.Chat) interfaces.JudgeMetrics { return metric.NewContextRecallMetric(c) },
+		func(r *types.JudgeMetrics) *float64 { return &r.ContextRecall }},
+}
+
+// metricValues returns the metrics of a result by metric name,
+// judge metrics are only included when the result was graded
+func metricValues(result *types.MetricResult) map[string]float64 {
+	values := make(map[string]float64, len(metricCalculators)+len(judgeCalculators))
+	if result == nil {
+		return values
+	}
+	for _, c := range metricCalculators {
+		values[c.name] = *c.getField(result)
+	}
+	if result.JudgeMetrics != nil {
+		for _, c := range judgeCalculators {
+			values[c.name] = *c.getField(result.JudgeMetrics)
+		}
+	}
+	return values
+}
+
+// isMetricName reports whether name is one of the computed metrics
+func isMetricName(name string) bool {
+	for _, c := range metricCalculators {
+		if c.name == name {
+			return true
+		}
+	}
+	for _, c := range judgeCalculators {
+		if c.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Append calculates and stores metrics for given input, returning the metrics of this input
+func (m *MetricList) Append(metricInput *types.MetricInput) *types.MetricResult {
 	result := &types.MetricResult{}
 	// Calculate all configured metrics
 	for _, c := range metricCalculators {
@@ -59,6 +113,7 @@ func (m *MetricList) Append(metricInput *types.MetricInput) {
 	}
 	logger.Infof(context.Background(), "metric: %v", result)
 	m.results = append(m.results, result)
+	return result
 }
 
 // Avg calculates average of all stored metric results
@@ -78,6 +133,25 @@ func (m *MetricList) Avg() *types.MetricResult {
 		}
 		*config.getField(avgResult) = sum / count
 	}
+
+	// Judge metrics are averaged over the graded results only
+	graded := 0
+	judgeSum := &types.JudgeMetrics{}
+	for _, r := range m.results {
+		if r.JudgeMetrics == nil {
+			continue
+		}
+		graded++
+		for _, config := range judgeCalculators {
+			*config.getField(judgeSum) += *config.getField(r.JudgeMetrics)
+		}
+	}
+	if graded > 0 {
+		for _, config := range judgeCalculators {
+			*config.getField(judgeSum) /= float64(graded)
+		}
+		avgResult.JudgeMetrics = judgeSum
+	}
 	return avgResult
 }
 
@@ -86,6 +160,8 @@ type HookMetric struct {
 	qaPairMetricList []*qaPairMetric // Per-QA pair metrics
 	metricResults    *MetricList     // Aggregated results
 	mu               *sync.RWMutex   // Thread safety
+	// Maps chunk indexes to passage IDs when passages were split into several chunks
+	chunkToPassage map[int]int
 }
 
 // qaPairMetric stores metrics for a single QA pair
@@ -93,6 +169,7 @@ type qaPairMetric struct {
 	qaPair       *types.QAPair
 	searchResult []*types.SearchResult
 	rerankResult []*types.SearchResult
+	mergeResult  []*types.SearchResult
 	chatResponse *types.ChatResponse
 }
 
@@ -105,6 +182,11 @@ func NewHookMetric(capacity int) *HookMetric {
 	}
 }
 
+// setPassageMapping sets the chunk index to passage ID mapping used for retrieval metrics
+func (h *HookMetric) setPassageMapping(chunkToPassage map[int]int) {
+	h.chunkToPassage = chunkToPassage
+}
+
 // recordInit initializes metric tracking for a QA pair
 func (h *HookMetric) recordInit(index int) {
 	h.qaPairMetricList[index] = &qaPairMetric{}
@@ -125,37 +207,91 @@ func (h *HookMetric) recordRerankResult(index int, rerankResult []*types.SearchR
 	h.qaPairMetricList[index].rerankResult = rerankResult
 }
 
+// recordMergeResult records the final context passed to the chat model for a QA pair
+func (h *HookMetric) recordMergeResult(index int, mergeResult []*types.SearchResult) {
+	h.qaPairMetricList[index].mergeResult = mergeResult
+}
+
 // recordChatResponse records the generated chat response
 func (h *HookMetric) recordChatResponse(index int, chatResponse *types.ChatResponse) {
 	h.qaPairMetricList[index].chatResponse = chatResponse
 }
 
-// recordFinish finalizes metrics for a QA pair
-func (h *HookMetric) recordFinish(index int) {
+// metricInput builds the metric input of a QA pair from its recorded results
+func (h *HookMetric) metricInput(index int) *types.MetricInput {
+	record := h.qaPairMetricList[index]
+
 	// Prepare retrieval IDs from rerank results
-	retrievalIDs := make([]int, len(h.qaPairMetricList[index].rerankResult))
-	for i, r := range h.qaPairMetricList[index].rerankResult {
-		retrievalIDs[i] = r.ChunkIndex
+	retrievalIDs := make([]int, 0, len(record.rerankResult))
+	seen := make(map[int]bool)
+	for _, r := range record.rerankResult {
+		id := r.ChunkIndex
+		if h.chunkToPassage != nil {
+			// Several chunks of the same passage count as one hit at the best rank
+			id = h.chunkToPassage[r.ChunkIndex]
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+		}
+		retrievalIDs = append(retrievalIDs, id)
 	}
 
 	// Get generated text if available
 	generatedTexts := ""
-	if h.qaPairMetricList[index].chatResponse != nil {
-		generatedTexts = h.qaPairMetricList[index].chatResponse.Content
+	if record.chatResponse != nil {
+		generatedTexts = record.chatResponse.Content
 	}
 
-	// Prepare metric input data
-	metricInput := &types.MetricInput{
-		RetrievalGT:    [][]int{h.qaPairMetricList[index].qaPair.PIDs},
+	// The judge grades against what the chat model saw, the rerank output when nothing was merged
+	contextResults := record.mergeResult
+	if len(contextResults) == 0 {
+		contextResults = record.rerankResult
+	}
+	contexts := make([]string, 0, len(contextResults))
+	for _, r := range contextResults {
+		contexts = append(contexts, r.Content)
+	}
+
+	return &types.MetricInput{
+		RetrievalGT:    [][]int{record.qaPair.PIDs},
 		RetrievalIDs:   retrievalIDs,
 		GeneratedTexts: generatedTexts,
-		GeneratedGT:    h.qaPairMetricList[index].qaPair.Answer,
+		GeneratedGT:    record.qaPair.Answer,
+		Question:       record.qaPair.Question,
+		Contexts:       contexts,
 	}
+}
+
+// recordFinish finalizes metrics for a QA pair and returns them
+func (h *HookMetric) recordFinish(index int) *types.MetricResult {
+	metricInput := h.metricInput(index)
 
 	// Thread-safe append of metrics
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	h.metricResults.Append(metricInput)
+	return h.metricResults.Append(metricInput)
+}
+
+// recordJudge grades a finished QA pair with the judge model and stores the scores in its result.
+// The result is left without judge metrics when any of them fails, so averages only cover fully graded pairs.
+func (h *HookMetric) recordJudge(ctx context.Context,
+	index int, judgeModel chat.Chat, result *types.MetricResult,
+) error {
+	metricInput := h.metricInput(index)
+	judgeMetrics := &types.JudgeMetrics{}
+	for _, c := range judgeCalculators {
+		score, err := c.newCalc(judgeModel).Compute(ctx, metricInput)
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.name, err)
+		}
+		*c.getField(judgeMetrics) = score
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	result.JudgeMetrics = judgeMetrics
+	return nil
 }
 
 // MetricResult returns the averaged metric results
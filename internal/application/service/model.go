@@ -20,13 +20,19 @@ var ErrModelNotFound = errors.New("model not found")
 type modelService struct {
 	repo          interfaces.ModelRepository
 	ollamaService *ollama.OllamaService
+	usageService  interfaces.UsageService
 }
 
-// NewModelService creates a new model service instance
-func NewModelService(repo interfaces.ModelRepository, ollamaService *ollama.OllamaService) interfaces.ModelService {
+// NewModelService creates a new model service instance, the model instances it returns are metered
+func NewModelService(
+	repo interfaces.ModelRepository,
+	ollamaService *ollama.OllamaService,
+	usageService interfaces.UsageService,
+) interfaces.ModelService {
 	return &modelService{
 		repo:          repo,
 		ollamaService: ollamaService,
+		usageService:  usageService,
 	}
 }
 
@@ -262,7 +268,7 @@ func (s *modelService) GetEmbeddingModel(ctx context.Context, modelId string) (e
 	}
 
 	logger.Info(ctx, "Embedding model initialized successfully")
-	return embedder, nil
+	return s.usageService.WrapEmbedder(embedder), nil
 }
 
 // GetRerankModel retrieves and initializes a reranking model instance
@@ -296,7 +302,7 @@ func (s *modelService) GetRerankModel(ctx context.Context, modelId string) (rera
 	}
 
 	logger.Info(ctx, "Rerank model initialized successfully")
-	return reranker, nil
+	return s.usageService.WrapReranker(reranker), nil
 }
 
 // GetChatModel retrieves and initializes a chat model instance
@@ -343,7 +349,7 @@ func (s *modelService) GetChatModel(ctx context.Context, modelId string) (chat.C
 		return nil, err
 	}
 
-	return chatModel, nil
+	return s.usageService.WrapChat(chatModel), nil
 }
 
 // Note: default model selection logic has been removed; models no longer
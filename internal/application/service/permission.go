@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Tencent/WeKnora/internal/application/repository"
+	werrors "github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// permissionService implements interfaces.PermissionService
+type permissionService struct {
+	repo          interfaces.PermissionRepository
+	userRepo      interfaces.UserRepository
+	tenantService interfaces.TenantService
+	kbService     interfaces.KnowledgeBaseService
+}
+
+// NewPermissionService creates a new permission service
+func NewPermissionService(
+	repo interfaces.PermissionRepository,
+	userRepo interfaces.UserRepository,
+	tenantService interfaces.TenantService,
+	kbService interfaces.KnowledgeBaseService,
+) interfaces.PermissionService {
+	return &permissionService{
+		repo:          repo,
+		userRepo:      userRepo,
+		tenantService: tenantService,
+		kbService:     kbService,
+	}
+}
+
+// ResolvePrincipal returns the role and knowledge base permissions of a user in a tenant.
+// Users own the tenant created for them, other tenants require a membership.
+func (s *permissionService) ResolvePrincipal(
+	ctx context.Context, user *types.User, tenantID uint64,
+) (*types.Principal, error) {
+	if user.TenantID == tenantID {
+		return &types.Principal{UserID: user.ID, Role: types.TenantRoleOwner}, nil
+	}
+	member, err := s.repo.GetMember(ctx, tenantID, user.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTenantMemberNotFound) {
+			return nil, werrors.NewForbiddenError("无权访问该租户")
+		}
+		return nil, err
+	}
+	principal := &types.Principal{UserID: user.ID, Role: member.Role}
+	if principal.Role == types.TenantRoleOwner {
+		return principal, nil
+	}
+	grants, err := s.repo.ListGrantsByUser(ctx, tenantID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	principal.Grants = make(map[string]types.KBPermission, len(grants))
+	for _, grant := range grants {
+		principal.Grants[grant.KnowledgeBaseID] = grant.Permission
+	}
+	return principal, nil
+}
+
+// ListJoinedTenants lists the tenants of the current user, its own tenant first
+func (s *permissionService) ListJoinedTenants(ctx context.Context) ([]*types.TenantMembership, error) {
+	user, ok := ctx.Value("user").(*types.User)
+	if !ok {
+		return nil, werrors.NewUnauthorizedError("仅登录用户可查看所属租户")
+	}
+	memberships := make([]*types.TenantMembership, 0)
+	if tenant, err := s.tenantService.GetTenantByID(ctx, user.TenantID); err == nil {
+		memberships = append(memberships, &types.TenantMembership{Tenant: tenant, Role: types.TenantRoleOwner})
+	}
+	members, err := s.repo.ListMembershipsByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		tenant, err := s.tenantService.GetTenantByID(ctx, member.TenantID)
+		if err != nil {
+			logger.Warnf(ctx, "Tenant %d of membership %s not found: %v", member.TenantID, member.ID, err)
+			continue
+		}
+		memberships = append(memberships, &types.TenantMembership{Tenant: tenant, Role: member.Role})
+	}
+	return memberships, nil
+}
+
+// ListMembers lists the members of the current tenant
+func (s *permissionService) ListMembers(ctx context.Context) ([]*types.TenantMember, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	members, err := s.repo.ListMembers(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		if user, err := s.userRepo.GetUserByID(ctx, member.UserID); err == nil {
+			member.User = user.ToUserInfo()
+		}
+	}
+	return members, nil
+}
+
+// AddMember adds the user with the given email to the current tenant
+func (s *permissionService) AddMember(
+	ctx context.Context, email string, role types.TenantRole,
+) (*types.TenantMember, error) {
+	if !role.IsValid() {
+		return nil, werrors.NewValidationError("无效的角色")
+	}
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, werrors.NewNotFoundError("用户不存在")
+		}
+		return nil, err
+	}
+	if user.TenantID == tenantID {
+		return nil, werrors.NewConflictError("用户已是该租户的所有者")
+	}
+	if _, err := s.repo.GetMember(ctx, tenantID, user.ID); err == nil {
+		return nil, werrors.NewConflictError("用户已是该租户的成员")
+	} else if !errors.Is(err, repository.ErrTenantMemberNotFound) {
+		return nil, err
+	}
+
+	member := &types.TenantMember{
+		ID:       uuid.New().String(),
+		TenantID: tenantID,
+		UserID:   user.ID,
+		Role:     role,
+	}
+	if err := s.repo.CreateMember(ctx, member); err != nil {
+		return nil, err
+	}
+	member.User = user.ToUserInfo()
+	logger.Infof(ctx, "User %s added to tenant %d as %s", user.ID, tenantID, role)
+	return member, nil
+}
+
+// UpdateMemberRole changes the role of a member of the current tenant
+func (s *permissionService) UpdateMemberRole(
+	ctx context.Context, userID string, role types.TenantRole,
+) (*types.TenantMember, error) {
+	if !role.IsValid() {
+		return nil, werrors.NewValidationError("无效的角色")
+	}
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	member, err := s.getMember(ctx, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	member.Role = role
+	if err := s.repo.UpdateMember(ctx, member); err != nil {
+		return nil, err
+	}
+	logger.Infof(ctx, "Role of user %s in tenant %d changed to %s", userID, tenantID, role)
+	return member, nil
+}
+
+// RemoveMember removes a member and its knowledge base permissions from the current tenant
+func (s *permissionService) RemoveMember(ctx context.Context, userID string) error {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	if _, err := s.getMember(ctx, tenantID, userID); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteMember(ctx, tenantID, userID); err != nil {
+		return err
+	}
+	logger.Infof(ctx, "User %s removed from tenant %d", userID, tenantID)
+	return nil
+}
+
+// ListKnowledgeBaseGrants lists the permissions granted on a knowledge base
+func (s *permissionService) ListKnowledgeBaseGrants(
+	ctx context.Context, kbID string,
+) ([]*types.KnowledgeBaseGrant, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	grants, err := s.repo.ListGrantsByKnowledgeBase(ctx, tenantID, kbID)
+	if err != nil {
+		return nil, err
+	}
+	for _, grant := range grants {
+		if user, err := s.userRepo.GetUserByID(ctx, grant.UserID); err == nil {
+			grant.User = user.ToUserInfo()
+		}
+	}
+	return grants, nil
+}
+
+// GrantKnowledgeBase grants a member a permission on a knowledge base, replacing its previous permission
+func (s *permissionService) GrantKnowledgeBase(
+	ctx context.Context, kbID string, userID string, permission types.KBPermission,
+) (*types.KnowledgeBaseGrant, error) {
+	if !permission.IsValid() {
+		return nil, werrors.NewValidationError("无效的知识库权限")
+	}
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	if _, err := s.getTenantKnowledgeBase(ctx, tenantID, kbID); err != nil {
+		return nil, err
+	}
+	if _, err := s.getMember(ctx, tenantID, userID); err != nil {
+		return nil, err
+	}
+	return s.saveGrant(ctx, tenantID, kbID, userID, permission)
+}
+
+// RevokeKnowledgeBase removes the permission of a member on a knowledge base
+func (s *permissionService) RevokeKnowledgeBase(ctx context.Context, kbID string, userID string) error {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	if err := s.repo.DeleteGrant(ctx, tenantID, kbID, userID); err != nil {
+		return err
+	}
+	logger.Infof(ctx, "Permission of user %s on knowledge base %s revoked", userID, kbID)
+	return nil
+}
+
+// GrantCreator gives the caller admin permission on a knowledge base it created.
+// Owners already administer every knowledge base and need no grant.
+func (s *permissionService) GrantCreator(ctx context.Context, kbID string) error {
+	principal := types.PrincipalFromContext(ctx)
+	if principal == nil || principal.UserID == "" || principal.Role == types.TenantRoleOwner {
+		return nil
+	}
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	if _, err := s.saveGrant(ctx, tenantID, kbID, principal.UserID, types.KBPermissionAdmin); err != nil {
+		return err
+	}
+	if principal.Grants == nil {
+		principal.Grants = make(map[string]types.KBPermission)
+	}
+	principal.Grants[kbID] = types.KBPermissionAdmin
+	return nil
+}
+
+// DeleteKnowledgeBaseGrants removes all permissions granted on a knowledge base
+func (s *permissionService) DeleteKnowledgeBaseGrants(ctx context.Context, kbID string) error {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	return s.repo.DeleteGrantsByKnowledgeBase(ctx, tenantID, kbID)
+}
+
+// saveGrant creates or replaces the grant of a user on a knowledge base
+func (s *permissionService) saveGrant(
+	ctx context.Context, tenantID uint64, kbID string, userID string, permission types.KBPermission,
+) (*types.KnowledgeBaseGrant, error) {
+	grant, err := s.repo.GetGrant(ctx, tenantID, kbID, userID)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		grant = &types.KnowledgeBaseGrant{
+			ID:              uuid.New().String(),
+			TenantID:        tenantID,
+			KnowledgeBaseID: kbID,
+			UserID:          userID,
+		}
+	}
+	grant.Permission = permission
+	if err := s.repo.SaveGrant(ctx, grant); err != nil {
+		return nil, err
+	}
+	logger.Infof(ctx, "User %s granted %s permission on knowledge base %s", userID, permission, kbID)
+	return grant, nil
+}
+
+// getMember returns a member of the tenant, a NotFound error when the user is not a member
+func (s *permissionService) getMember(
+	ctx context.Context, tenantID uint64, userID string,
+) (*types.TenantMember, error) {
+	member, err := s.repo.GetMember(ctx, tenantID, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTenantMemberNotFound) {
+			return nil, werrors.NewNotFoundError("该用户不是租户成员")
+		}
+		return nil, err
+	}
+	return member, nil
+}
+
+// getTenantKnowledgeBase returns a knowledge base of the tenant
+func (s *permissionService) getTenantKnowledgeBase(
+	ctx context.Context, tenantID uint64, kbID string,
+) (*types.KnowledgeBase, error) {
+	kb, err := s.kbService.GetKnowledgeBaseByID(ctx, kbID)
+	if err != nil || kb.TenantID != tenantID {
+		return nil, werrors.NewNotFoundError("知识库不存在")
+	}
+	return kb, nil
+}
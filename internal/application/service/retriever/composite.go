@@ -115,6 +115,19 @@ func (c *CompositeRetrieveEngine) BatchUpdateChunkEnabledStatus(
 	})
 }
 
+// BatchUpdateKnowledgeFilterFields replaces the filter fields stored on the indices of knowledge
+func (c *CompositeRetrieveEngine) BatchUpdateKnowledgeFilterFields(
+	ctx context.Context,
+	fields map[string]*types.IndexFilterFields,
+) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	return c.concurrentExecWithError(ctx, func(ctx context.Context, engineInfo *engineInfo) error {
+		return engineInfo.retrieveEngine.BatchUpdateKnowledgeFilterFields(ctx, fields)
+	})
+}
+
 // concurrentRetrieve is a helper function for concurrent processing of retrieval parameters
 // and collecting results
 func concurrentRetrieve(
@@ -301,6 +314,37 @@ func (c *CompositeRetrieveEngine) DeleteByKnowledgeIDList(ctx context.Context,
 	})
 }
 
+// DeleteByKnowledgeBaseID deletes all vector embeddings of a knowledge base from all registered repositories
+func (c *CompositeRetrieveEngine) DeleteByKnowledgeBaseID(ctx context.Context,
+	knowledgeBaseID string, dimension int, knowledgeType string,
+) error {
+	return c.concurrentExecWithError(ctx, func(ctx context.Context, engineInfo *engineInfo) error {
+		if err := engineInfo.retrieveEngine.DeleteByKnowledgeBaseID(ctx, knowledgeBaseID, dimension, knowledgeType); err != nil {
+			logger.GetLogger(ctx).Errorf("Repository %s failed to delete knowledge base %s: %v",
+				engineInfo.retrieveEngine.EngineType(), knowledgeBaseID, err)
+			return err
+		}
+		return nil
+	})
+}
+
+// PromoteShadowIndices replaces the vector embeddings of a knowledge base with its shadow embeddings
+// in all registered repositories
+func (c *CompositeRetrieveEngine) PromoteShadowIndices(ctx context.Context,
+	shadowKnowledgeBaseID string, targetKnowledgeBaseID string, shadowDimension int, targetDimension int,
+) error {
+	return c.concurrentExecWithError(ctx, func(ctx context.Context, engineInfo *engineInfo) error {
+		if err := engineInfo.retrieveEngine.PromoteShadowIndices(
+			ctx, shadowKnowledgeBaseID, targetKnowledgeBaseID, shadowDimension, targetDimension,
+		); err != nil {
+			logger.GetLogger(ctx).Errorf("Repository %s failed to promote shadow indices: %v",
+				engineInfo.retrieveEngine.EngineType(), err)
+			return err
+		}
+		return nil
+	})
+}
+
 // EstimateStorageSize estimates the storage size required for the provided index information
 func (c *CompositeRetrieveEngine) EstimateStorageSize(ctx context.Context,
 	embedder embedding.Embedder, indexInfoList []*types.IndexInfo,
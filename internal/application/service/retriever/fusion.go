@@ -0,0 +1,132 @@
+package retriever
+
+import (
+	"slices"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// FuseResults merges vector and keyword retrieval results into one list deduplicated by chunk ID,
+// scored by the fusion mode of the config and sorted by that score (highest first).
+// Raw BM25 and cosine scores live on different scales, so the weighted and max modes
+// min-max normalize each retriever's scores to [0, 1] before combining them.
+func FuseResults(config types.RetrievalConfig,
+	vectorResults []*types.IndexWithScore,
+	keywordResults []*types.IndexWithScore,
+) []*types.IndexWithScore {
+	config = config.WithDefaults()
+
+	vectorScores, vectorRanks := scoreAndRank(vectorResults)
+	keywordScores, keywordRanks := scoreAndRank(keywordResults)
+
+	// Keep the first occurrence of each chunk, vector results take precedence
+	chunkInfoMap := make(map[string]*types.IndexWithScore)
+	order := make([]string, 0, len(vectorResults)+len(keywordResults))
+	for _, list := range [][]*types.IndexWithScore{vectorResults, keywordResults} {
+		for _, r := range list {
+			if _, exists := chunkInfoMap[r.ChunkID]; !exists {
+				chunkInfoMap[r.ChunkID] = r
+				order = append(order, r.ChunkID)
+			}
+		}
+	}
+
+	var fusedScores map[string]float64
+	switch config.FusionMode {
+	case types.FusionModeWeighted:
+		alpha := *config.Alpha
+		vectorNorm, keywordNorm := normalizeScores(vectorScores), normalizeScores(keywordScores)
+		fusedScores = make(map[string]float64, len(order))
+		for _, chunkID := range order {
+			fusedScores[chunkID] = alpha*vectorNorm[chunkID] + (1-alpha)*keywordNorm[chunkID]
+		}
+	case types.FusionModeMax:
+		vectorNorm, keywordNorm := normalizeScores(vectorScores), normalizeScores(keywordScores)
+		fusedScores = make(map[string]float64, len(order))
+		for _, chunkID := range order {
+			fusedScores[chunkID] = max(vectorNorm[chunkID], keywordNorm[chunkID])
+		}
+	default:
+		fusedScores = make(map[string]float64, len(order))
+		for _, chunkID := range order {
+			score := 0.0
+			if rank, ok := vectorRanks[chunkID]; ok {
+				score += 1.0 / float64(config.RRFK+rank)
+			}
+			if rank, ok := keywordRanks[chunkID]; ok {
+				score += 1.0 / float64(config.RRFK+rank)
+			}
+			fusedScores[chunkID] = score
+		}
+	}
+
+	fused := make([]*types.IndexWithScore, 0, len(order))
+	for _, chunkID := range order {
+		info := chunkInfoMap[chunkID]
+		// Store the fused score in the Score field for downstream processing
+		info.Score = fusedScores[chunkID]
+		fused = append(fused, info)
+	}
+	slices.SortStableFunc(fused, func(a, b *types.IndexWithScore) int {
+		if a.Score > b.Score {
+			return -1
+		} else if a.Score < b.Score {
+			return 1
+		}
+		return 0
+	})
+	return fused
+}
+
+// scoreAndRank returns the best score of each chunk and its 1-indexed rank within the results
+func scoreAndRank(results []*types.IndexWithScore) (map[string]float64, map[string]int) {
+	sorted := slices.Clone(results)
+	// Results of several engines are concatenated, so the input is not necessarily in score order
+	slices.SortStableFunc(sorted, func(a, b *types.IndexWithScore) int {
+		if a.Score > b.Score {
+			return -1
+		} else if a.Score < b.Score {
+			return 1
+		}
+		return 0
+	})
+
+	scores := make(map[string]float64, len(sorted))
+	ranks := make(map[string]int, len(sorted))
+	for _, r := range sorted {
+		if _, exists := ranks[r.ChunkID]; exists {
+			continue
+		}
+		ranks[r.ChunkID] = len(ranks) + 1
+		scores[r.ChunkID] = r.Score
+	}
+	return scores, ranks
+}
+
+// normalizeScores min-max normalizes scores to [0, 1], all scores become 1 when they are equal
+func normalizeScores(scores map[string]float64) map[string]float64 {
+	if len(scores) == 0 {
+		return scores
+	}
+	minScore, maxScore := 0.0, 0.0
+	first := true
+	for _, score := range scores {
+		if first {
+			minScore, maxScore = score, score
+			first = false
+			continue
+		}
+		minScore = min(minScore, score)
+		maxScore = max(maxScore, score)
+	}
+
+	normalized := make(map[string]float64, len(scores))
+	for chunkID, score := range scores {
+		if maxScore == minScore {
+			normalized[chunkID] = 1
+			continue
+		}
+		normalized[chunkID] = (score - minScore) / (maxScore - minScore)
+	}
+	return normalized
+}
@@ -0,0 +1,71 @@
+package retriever
+
+import (
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func fusionInput() ([]*types.IndexWithScore, []*types.IndexWithScore) {
+	vector := []*types.IndexWithScore{
+		{ChunkID: "a", Score: 0.9},
+		{ChunkID: "b", Score: 0.8},
+		{ChunkID: "c", Score: 0.5},
+	}
+	keyword := []*types.IndexWithScore{
+		{ChunkID: "c", Score: 12},
+		{ChunkID: "d", Score: 4},
+		{ChunkID: "a", Score: 2},
+	}
+	return vector, keyword
+}
+
+func chunkIDs(results []*types.IndexWithScore) []string {
+	ids := make([]string, 0, len(results))
+	for _, r := range results {
+		ids = append(ids, r.ChunkID)
+	}
+	return ids
+}
+
+func TestFuseResults_RRF(t *testing.T) {
+	vector, keyword := fusionInput()
+	fused := FuseResults(types.RetrievalConfig{FusionMode: types.FusionModeRRF, RRFK: 1}, vector, keyword)
+
+	// a: 1/2 + 1/4, c: 1/4 + 1/2, b: 1/3, d: 1/3
+	assert.Equal(t, []string{"a", "c", "b", "d"}, chunkIDs(fused))
+	assert.InDelta(t, 0.75, fused[0].Score, 1e-9)
+	assert.InDelta(t, 1.0/3, fused[3].Score, 1e-9)
+}
+
+func TestFuseResults_Weighted(t *testing.T) {
+	vector, keyword := fusionInput()
+	alpha := 0.25
+	fused := FuseResults(types.RetrievalConfig{FusionMode: types.FusionModeWeighted, Alpha: &alpha}, vector, keyword)
+
+	// Normalized vector: a=1, b=0.75, c=0; keyword: c=1, d=0.2, a=0
+	assert.Equal(t, []string{"c", "a", "b", "d"}, chunkIDs(fused))
+	assert.InDelta(t, 0.75, fused[0].Score, 1e-9)
+	assert.InDelta(t, 0.25, fused[1].Score, 1e-9)
+	assert.InDelta(t, 0.1875, fused[2].Score, 1e-9)
+	assert.InDelta(t, 0.15, fused[3].Score, 1e-9)
+}
+
+func TestFuseResults_Max(t *testing.T) {
+	vector, keyword := fusionInput()
+	fused := FuseResults(types.RetrievalConfig{FusionMode: types.FusionModeMax}, vector, keyword)
+
+	assert.Equal(t, []string{"a", "c", "b", "d"}, chunkIDs(fused))
+	assert.InDelta(t, 1.0, fused[1].Score, 1e-9)
+	assert.InDelta(t, 0.2, fused[3].Score, 1e-9)
+}
+
+func TestFuseResults_DefaultsToRRF(t *testing.T) {
+	vector, keyword := fusionInput()
+	var config *types.RetrievalConfig
+	fused := FuseResults(config.WithDefaults(), vector, keyword)
+
+	assert.Len(t, fused, 4)
+	assert.InDelta(t, 1.0/61+1.0/63, fused[0].Score, 1e-9)
+}
@@ -132,6 +132,23 @@ func (v *KeywordsVectorHybridRetrieveEngineService) DeleteByKnowledgeIDList(ctx
 	return v.indexRepository.DeleteByKnowledgeIDList(ctx, knowledgeIDList, dimension, knowledgeType)
 }
 
+// DeleteByKnowledgeBaseID deletes all vectors of a knowledge base
+func (v *KeywordsVectorHybridRetrieveEngineService) DeleteByKnowledgeBaseID(ctx context.Context,
+	knowledgeBaseID string, dimension int, knowledgeType string,
+) error {
+	return v.indexRepository.DeleteByKnowledgeBaseID(ctx, knowledgeBaseID, dimension, knowledgeType)
+}
+
+// PromoteShadowIndices replaces the vectors of a knowledge base with its shadow vectors
+func (v *KeywordsVectorHybridRetrieveEngineService) PromoteShadowIndices(ctx context.Context,
+	shadowKnowledgeBaseID string, targetKnowledgeBaseID string, shadowDimension int, targetDimension int,
+) error {
+	logger.Infof(ctx, "Promote shadow indices of knowledge base %s to %s", shadowKnowledgeBaseID, targetKnowledgeBaseID)
+	return v.indexRepository.PromoteShadowIndices(
+		ctx, shadowKnowledgeBaseID, targetKnowledgeBaseID, shadowDimension, targetDimension,
+	)
+}
+
 // Support returns the retriever types supported by this engine
 func (v *KeywordsVectorHybridRetrieveEngineService) Support() []types.RetrieverType {
 	return v.indexRepository.Support()
@@ -181,3 +198,11 @@ func (v *KeywordsVectorHybridRetrieveEngineService) BatchUpdateChunkEnabledStatu
 ) error {
 	return v.indexRepository.BatchUpdateChunkEnabledStatus(ctx, chunkStatusMap)
 }
+
+// BatchUpdateKnowledgeFilterFields replaces the filter fields stored on the indices of knowledge
+func (v *KeywordsVectorHybridRetrieveEngineService) BatchUpdateKnowledgeFilterFields(
+	ctx context.Context,
+	fields map[string]*types.IndexFilterFields,
+) error {
+	return v.indexRepository.BatchUpdateKnowledgeFilterFields(ctx, fields)
+}
@@ -45,6 +45,7 @@ type sessionService struct {
 	knowledgeService     interfaces.KnowledgeService     // Service for knowledge operations
 	chunkService         interfaces.ChunkService         // Service for chunk operations
 	redisClient          *redis.Client                   // Redis client for temp KB state
+	memoryService        interfaces.MemoryService        // Service for long-term user memories
 }
 
 // NewSessionService creates a new session service instance with all required dependencies
@@ -60,6 +61,7 @@ func NewSessionService(cfg *config.Config,
 	agentService interfaces.AgentService,
 	sessionStorage llmcontext.ContextStorage,
 	redisClient *redis.Client,
+	memoryService interfaces.MemoryService,
 ) interfaces.SessionService {
 	return &sessionService{
 		cfg:                  cfg,
@@ -74,6 +76,7 @@ func NewSessionService(cfg *config.Config,
 		agentService:         agentService,
 		sessionStorage:       sessionStorage,
 		redisClient:          redisClient,
+		memoryService:        memoryService,
 	}
 }
 
@@ -398,17 +401,21 @@ func (s *sessionService) KnowledgeQA(
 	query string,
 	knowledgeBaseIDs []string,
 	knowledgeIDs []string,
+	filter *types.RetrievalFilter,
 	assistantMessageID string,
 	summaryModelID string,
 	webSearchEnabled bool,
+	attachments types.MessageAttachments,
 	eventBus *event.EventBus,
 ) error {
 	logger.Infof(
 		ctx,
-		"Knowledge base question answering parameters, session ID: %s, query: %s, webSearchEnabled: %v",
+		"Knowledge base question answering parameters, session ID: %s, query: %s, "+
+			"webSearchEnabled: %v, attachments: %d",
 		session.ID,
 		query,
 		webSearchEnabled,
+		len(attachments),
 	)
 
 	// If no knowledge base IDs provided, fall back to session's default
@@ -421,6 +428,8 @@ func (s *sessionService) KnowledgeQA(
 		}
 	}
 
+	// Only search the knowledge bases the caller may read
+	knowledgeBaseIDs = types.FilterReadableKnowledgeBaseIDs(ctx, knowledgeBaseIDs)
 	logger.Infof(ctx, "Using knowledge bases: %v", knowledgeBaseIDs)
 
 	// Determine chat model ID: prioritize request's summaryModelID, then Remote models
@@ -428,6 +437,8 @@ func (s *sessionService) KnowledgeQA(
 	if err != nil {
 		return err
 	}
+	s.recordChatModel(ctx, session.ID, assistantMessageID, chatModelID)
+	images := s.questionImages(ctx, chatModelID, attachments)
 
 	rewritePromptSystem := s.cfg.Conversation.RewritePromptSystem
 	rewritePromptUser := s.cfg.Conversation.RewritePromptUser
@@ -529,14 +540,17 @@ func (s *sessionService) KnowledgeQA(
 		chatModelID,
 		len(searchTargets),
 	)
+	// The knowledge bases are searched for what the attached images show as well
+	question := questionWithCaptions(query, attachments)
 	chatManage := &types.ChatManage{
-		Query:                query,
-		RewriteQuery:         query,
+		Query:                question,
+		RewriteQuery:         question,
 		SessionID:            session.ID,
 		MessageID:            assistantMessageID, // NEW: For event emission in pipeline
 		KnowledgeBaseIDs:     knowledgeBaseIDs,   // Multi-KB support
 		KnowledgeIDs:         knowledgeIDs,       // Specific knowledge (file) IDs
 		SearchTargets:        searchTargets,      // Pre-computed search targets
+		RetrievalFilter:      filter,
 		VectorThreshold:      vectorThreshold,
 		KeywordThreshold:     keywordThreshold,
 		EmbeddingTopK:        embeddingTopK,
@@ -556,29 +570,31 @@ func (s *sessionService) KnowledgeQA(
 		RewritePromptUser:    rewritePromptUser,
 		EnableRewrite:        enableRewrite,
 		EnableQueryExpansion: enableQueryExpansion,
+		Images:               images,
+		AttachmentContent:    attachmentContent(attachments),
 	}
 
 	// Determine pipeline based on knowledge bases availability and web search setting
 	// If no knowledge bases are selected AND web search is disabled, use pure chat pipeline
 	// Otherwise use rag_stream pipeline (which handles both KB search and web search)
-	var pipeline []types.EventType
+	var pipeline []types.PipelineStage
 	if len(knowledgeBaseIDs) == 0 && len(knowledgeIDs) == 0 && !webSearchEnabled {
 		logger.Info(ctx, "No knowledge bases selected and web search disabled, using chat_stream pipeline")
-		pipeline = types.Pipline["chat_stream"]
+		pipeline = types.NewPipelineStages(types.Pipline["chat_stream"])
 		// For pure chat, UserContent is the Query (since INTO_CHAT_MESSAGE is skipped)
-		chatManage.UserContent = query
+		chatManage.UserContent = question
 	} else {
 		if webSearchEnabled && len(knowledgeBaseIDs) == 0 && len(knowledgeIDs) == 0 {
 			logger.Info(ctx, "Web search enabled without knowledge bases, using rag_stream pipeline for web search only")
 		} else {
 			logger.Info(ctx, "Knowledge bases selected, using rag_stream pipeline")
 		}
-		pipeline = types.Pipline["rag_stream"]
+		pipeline = s.resolvePipeline(ctx, searchTargets, tenantConv)
 	}
 
 	// Start knowledge QA event processing
 	logger.Info(ctx, "Triggering question answering event")
-	err = s.KnowledgeQAByEvent(ctx, chatManage, pipeline)
+	err = s.runPipeline(ctx, chatManage, pipeline)
 	if err != nil {
 		logger.ErrorWithFields(ctx, err, map[string]interface{}{
 			"session_id":        session.ID,
@@ -602,6 +618,32 @@ func (s *sessionService) KnowledgeQA(
 		}
 	}
 
+	// Replay the cached answer after its references, as the stream handler completes the message on Done.
+	// The cached answer cites its references in the order they were numbered in the prompt.
+	if chatManage.AnswerCacheHit && chatManage.ChatResponse != nil {
+		answer, citations := chatpipline.VerifyCitations(chatManage.ChatResponse.Content,
+			chatManage.MergeResult, chatManage.MergeResult, types.CitationModeStrip)
+		if err := eventBus.Emit(ctx, event.Event{
+			ID:        generateEventID("citations"),
+			Type:      event.EventAgentCitations,
+			SessionID: session.ID,
+			Data:      event.AgentCitationsData{Citations: citations},
+		}); err != nil {
+			logger.Errorf(ctx, "Failed to emit cached answer citations event: %v", err)
+		}
+		if err := eventBus.Emit(ctx, event.Event{
+			ID:        generateEventID("cache"),
+			Type:      event.EventAgentFinalAnswer,
+			SessionID: session.ID,
+			Data: event.AgentFinalAnswerData{
+				Content: answer,
+				Done:    true,
+			},
+		}); err != nil {
+			logger.Errorf(ctx, "Failed to emit cached answer event: %v", err)
+		}
+	}
+
 	// Note: Answer events are now emitted directly by chat_completion_stream plugin
 	// Completion event will be emitted when the last answer event has Done=true
 	// We can optionally add a completion watcher here if needed, but for now
@@ -611,6 +653,42 @@ func (s *sessionService) KnowledgeQA(
 	return nil
 }
 
+// questionImages returns the images attached to a question when the chat model accepts images.
+// Other chat models only read the captions of the images.
+func (s *sessionService) questionImages(
+	ctx context.Context, chatModelID string, attachments types.MessageAttachments,
+) []string {
+	images := attachments.ImageURLs()
+	if len(images) == 0 {
+		return nil
+	}
+	model, err := s.modelService.GetModelByID(ctx, chatModelID)
+	if err != nil {
+		logger.Warnf(ctx, "Failed to get chat model %s, images are not sent: %v", chatModelID, err)
+		return nil
+	}
+	if !model.Parameters.SupportsVision {
+		logger.Infof(ctx, "Chat model %s does not support vision, sending only the captions of %d images",
+			chatModelID, len(images))
+		return nil
+	}
+	return images
+}
+
+// recordChatModel records the chat model answering on the assistant message, for the feedback analytics
+func (s *sessionService) recordChatModel(ctx context.Context, sessionID string, messageID string, chatModelID string) {
+	if messageID == "" || chatModelID == "" {
+		return
+	}
+	if err := s.messageRepo.UpdateMessage(ctx, &types.Message{
+		ID:          messageID,
+		SessionID:   sessionID,
+		ChatModelID: chatModelID,
+	}); err != nil {
+		logger.Warnf(ctx, "Failed to record the chat model of message %s: %v", messageID, err)
+	}
+}
+
 // selectChatModelIDWithOverride selects the appropriate chat model ID with priority for request override
 // Priority order:
 // 1. Request's summaryModelID (if provided and valid)
@@ -782,6 +860,11 @@ func (s *sessionService) buildSearchTargets(
 			if fullKBSet[k.KnowledgeBaseID] {
 				continue
 			}
+			// Skip documents of knowledge bases the caller may not read
+			if principal := types.PrincipalFromContext(ctx); principal != nil &&
+				!principal.CanAccessKnowledgeBase(k.KnowledgeBaseID, types.KBPermissionRead) {
+				continue
+			}
 			kbToKnowledgeIDs[k.KnowledgeBaseID] = append(kbToKnowledgeIDs[k.KnowledgeBaseID], k.ID)
 		}
 
@@ -804,6 +887,13 @@ func (s *sessionService) buildSearchTargets(
 // KnowledgeQAByEvent processes knowledge QA through a series of events in the pipeline
 func (s *sessionService) KnowledgeQAByEvent(ctx context.Context,
 	chatManage *types.ChatManage, eventList []types.EventType,
+) error {
+	return s.runPipeline(ctx, chatManage, types.NewPipelineStages(eventList))
+}
+
+// runPipeline processes knowledge QA through the stages of the pipeline
+func (s *sessionService) runPipeline(ctx context.Context,
+	chatManage *types.ChatManage, stages []types.PipelineStage,
 ) error {
 	ctx, span := tracing.ContextWithSpan(ctx, "SessionService.KnowledgeQAByEvent")
 	defer span.End()
@@ -814,8 +904,8 @@ func (s *sessionService) KnowledgeQAByEvent(ctx context.Context,
 
 	// Prepare method list for logging and tracing
 	methods := []string{}
-	for _, event := range eventList {
-		methods = append(methods, string(event))
+	for _, stage := range stages {
+		methods = append(methods, string(stage.Event))
 	}
 
 	// Set up tracing attributes
@@ -826,39 +916,76 @@ func (s *sessionService) KnowledgeQAByEvent(ctx context.Context,
 		attribute.String("method", strings.Join(methods, ",")),
 	)
 
-	// Process each event in sequence
-	for _, eventType := range eventList {
-		logger.Infof(ctx, "Starting to trigger event: %v", eventType)
-		err := s.eventManager.Trigger(ctx, eventType, chatManage)
+	// Process each stage in sequence
+	eventType, err := s.eventManager.Run(ctx, stages, chatManage)
+
+	// Handle case where search returns no results
+	if err == chatpipline.ErrSearchNothing {
+		logger.Warnf(
+			ctx,
+			"Event %v triggered, search result is empty, using fallback response, strategy: %v",
+			eventType,
+			chatManage.FallbackStrategy,
+		)
+		s.handleFallbackResponse(ctx, chatManage)
+		return nil
+	}
 
-		// Handle case where search returns no results
-		if err == chatpipline.ErrSearchNothing {
-			logger.Warnf(
-				ctx,
-				"Event %v triggered, search result is empty, using fallback response, strategy: %v",
-				eventType,
-				chatManage.FallbackStrategy,
-			)
-			s.handleFallbackResponse(ctx, chatManage)
-			return nil
-		}
+	// A cached answer replaces the remaining events, the caller replays it
+	if err == chatpipline.ErrAnswerCacheHit {
+		logger.Infof(ctx, "Event %v triggered, answer replayed from cache", eventType)
+		return nil
+	}
 
-		// Handle other errors
-		if err != nil {
-			logger.Errorf(ctx, "Event triggering failed, event: %v, error type: %s, description: %s, error: %v",
-				eventType, err.ErrorType, err.Description, err.Err)
-			span.RecordError(err.Err)
-			span.SetStatus(codes.Error, err.Description)
-			span.SetAttributes(attribute.String("error_type", err.ErrorType))
-			return err.Err
-		}
-		logger.Infof(ctx, "Event %v triggered successfully", eventType)
+	// Handle other errors
+	if err != nil {
+		logger.Errorf(ctx, "Event triggering failed, event: %v, error type: %s, description: %s, error: %v",
+			eventType, err.ErrorType, err.Description, err.Err)
+		span.RecordError(err.Err)
+		span.SetStatus(codes.Error, err.Description)
+		span.SetAttributes(attribute.String("error_type", err.ErrorType))
+		return err.Err
 	}
 
 	logger.Info(ctx, "All events triggered successfully")
 	return nil
 }
 
+// resolvePipeline returns the pipeline declared by the searched knowledge bases when they all declare the same one,
+// then the pipeline of the tenant, and the default rag_stream pipeline otherwise
+func (s *sessionService) resolvePipeline(ctx context.Context,
+	searchTargets types.SearchTargets, tenantConv *types.ConversationConfig,
+) []types.PipelineStage {
+	var pipeline *types.PipelineConfig
+	checked := make(map[string]bool)
+	for _, target := range searchTargets {
+		if checked[target.KnowledgeBaseID] {
+			continue
+		}
+		checked[target.KnowledgeBaseID] = true
+		kb, err := s.knowledgeBaseService.GetKnowledgeBaseByID(ctx, target.KnowledgeBaseID)
+		if err != nil {
+			logger.Warnf(ctx, "Failed to get knowledge base %s for its pipeline: %v", target.KnowledgeBaseID, err)
+			pipeline = nil
+			break
+		}
+		if kb.PipelineConfig == nil || (pipeline != nil && !pipeline.Equal(kb.PipelineConfig)) {
+			pipeline = nil
+			break
+		}
+		pipeline = kb.PipelineConfig
+	}
+	if pipeline != nil {
+		logger.Info(ctx, "Using the pipeline declared by the knowledge bases")
+		return pipeline.Stages
+	}
+	if tenantConv != nil && tenantConv.Pipeline != nil && len(tenantConv.Pipeline.Stages) > 0 {
+		logger.Info(ctx, "Using the pipeline declared by the tenant")
+		return tenantConv.Pipeline.Stages
+	}
+	return types.NewPipelineStages(types.Pipline["rag_stream"])
+}
+
 func getTenantConversationConfig(ctx context.Context) (*types.ConversationConfig, error) {
 	tenant := ctx.Value(types.TenantInfoContextKey).(*types.Tenant)
 	if tenant == nil {
@@ -873,8 +1000,9 @@ func getTenantConversationConfig(ctx context.Context) (*types.ConversationConfig
 // SearchKnowledge performs knowledge base search without LLM summarization
 // knowledgeBaseIDs: list of knowledge base IDs to search (supports multi-KB)
 // knowledgeIDs: list of specific knowledge (file) IDs to search
+// filter: optional filter on the tags, file type, creation time or metadata of the searched documents
 func (s *sessionService) SearchKnowledge(ctx context.Context,
-	knowledgeBaseIDs []string, knowledgeIDs []string, query string,
+	knowledgeBaseIDs []string, knowledgeIDs []string, query string, filter *types.RetrievalFilter,
 ) ([]*types.SearchResult, error) {
 	logger.Info(ctx, "Start knowledge base search without LLM summary")
 	logger.Infof(ctx, "Knowledge base search parameters, knowledge base IDs: %v, knowledge IDs: %v, query: %s",
@@ -886,6 +1014,7 @@ func (s *sessionService) SearchKnowledge(ctx context.Context,
 		logger.Error(ctx, "Failed to get tenant ID from context")
 		return nil, fmt.Errorf("tenant ID not found in context")
 	}
+	knowledgeBaseIDs = types.FilterReadableKnowledgeBaseIDs(ctx, knowledgeBaseIDs)
 
 	// Build unified search targets (computed once, used throughout pipeline)
 	searchTargets, err := s.buildSearchTargets(ctx, tenantID, knowledgeBaseIDs, knowledgeIDs)
@@ -905,6 +1034,7 @@ func (s *sessionService) SearchKnowledge(ctx context.Context,
 		KnowledgeBaseIDs: knowledgeBaseIDs,
 		KnowledgeIDs:     knowledgeIDs,
 		SearchTargets:    searchTargets,
+		RetrievalFilter:  filter,
 		VectorThreshold:  s.cfg.Conversation.VectorThreshold,  // Use default configuration
 		KeywordThreshold: s.cfg.Conversation.KeywordThreshold, // Use default configuration
 		EmbeddingTopK:    s.cfg.Conversation.EmbeddingTopK,    // Use default configuration
@@ -989,6 +1119,8 @@ func (s *sessionService) AgentQA(
 	ctx context.Context,
 	session *types.Session,
 	query string,
+	filter *types.RetrievalFilter,
+	attachments types.MessageAttachments,
 	assistantMessageID string,
 	eventBus *event.EventBus,
 ) error {
@@ -1038,8 +1170,17 @@ func (s *sessionService) AgentQA(
 		KnowledgeBases:    session.AgentConfig.KnowledgeBases,   // Use session's knowledge bases
 		KnowledgeIDs:      session.AgentConfig.KnowledgeIDs,     // Use session's knowledge IDs (individual documents)
 		WebSearchEnabled:  session.AgentConfig.WebSearchEnabled, // Web search enabled from session config
+		Profiles:          tenantInfo.AgentConfig.Profiles,      // Sub-agents the agent can delegate to
 	}
 
+	// Memories of the user recalled into the prompt and extracted from the answer
+	agentConfig.MemoryEnabled = tenantInfo.AgentConfig.MemoryEnabled
+	agentConfig.MemoryEmbeddingModelID = tenantInfo.AgentConfig.MemoryEmbeddingModelID
+
+	// Tool policies decide which tool calls wait for the approval of the user
+	agentConfig.ToolPolicies = tenantInfo.AgentConfig.ToolPolicies
+	agentConfig.ToolApprovalTimeout = tenantInfo.AgentConfig.ToolApprovalTimeout
+
 	agentConfig.UseCustomSystemPrompt = tenantInfo.AgentConfig.UseCustomSystemPrompt
 	if agentConfig.UseCustomSystemPrompt {
 		agentConfig.SystemPromptWebEnabled = tenantInfo.AgentConfig.ResolveSystemPrompt(true)
@@ -1081,6 +1222,8 @@ func (s *sessionService) AgentQA(
 			len(agentConfig.KnowledgeBases), agentConfig.KnowledgeBases)
 	}
 
+	agentConfig.KnowledgeBases = types.FilterReadableKnowledgeBaseIDs(ctx, agentConfig.KnowledgeBases)
+
 	// Build search targets for agent (pre-compute once to avoid repeated queries)
 	searchTargets, err := s.buildSearchTargets(ctx, tenantInfo.ID, agentConfig.KnowledgeBases, agentConfig.KnowledgeIDs)
 	if err != nil {
@@ -1088,8 +1231,17 @@ func (s *sessionService) AgentQA(
 		// Continue without search targets, the tool will handle empty targets
 	}
 	agentConfig.SearchTargets = searchTargets
+	agentConfig.RetrievalFilter = filter
 	logger.Infof(ctx, "Agent search targets built: %d targets", len(searchTargets))
 
+	if agentConfig.MemoryEnabled {
+		memories, err := s.memoryService.RecallMemories(ctx, agentConfig.MemoryEmbeddingModelID, query)
+		if err != nil {
+			logger.Warnf(ctx, "Failed to recall user memories: %v, continuing without memories", err)
+		}
+		agentConfig.Memories = memories
+	}
+
 	summaryModelID := session.SummaryModelID
 	if summaryModelID == "" && tenantInfo.ConversationConfig != nil {
 		summaryModelID = tenantInfo.ConversationConfig.SummaryModelID
@@ -1104,6 +1256,8 @@ func (s *sessionService) AgentQA(
 		logger.Warnf(ctx, "Failed to get chat model: %v", err)
 		return fmt.Errorf("failed to get chat model: %w", err)
 	}
+	s.recordChatModel(ctx, sessionID, assistantMessageID, summaryModelID)
+	agentConfig.QueryImages = s.questionImages(ctx, summaryModelID, attachments)
 
 	rerankModelID := session.RerankModelID
 	if rerankModelID == "" && tenantInfo.ConversationConfig != nil {
@@ -1150,7 +1304,10 @@ func (s *sessionService) AgentQA(
 	// Execute agent with streaming (asynchronously)
 	// Events will be emitted to EventBus and handled by the Handler layer
 	logger.Info(ctx, "Executing agent with streaming")
-	if _, err := engine.Execute(ctx, sessionID, assistantMessageID, query, llmContext); err != nil {
+	// The agent reads the captions of the attached images and the text of the attached documents with the query
+	agentQuery := questionWithCaptions(query, attachments) + attachmentContent(attachments)
+	state, err := engine.Execute(ctx, sessionID, assistantMessageID, agentQuery, llmContext)
+	if err != nil {
 		logger.Errorf(ctx, "Agent execution failed: %v", err)
 		// Emit error event to the EventBus used by this agent
 		eventBus.Emit(ctx, event.Event{
@@ -1162,6 +1319,15 @@ func (s *sessionService) AgentQA(
 				SessionID: sessionID,
 			},
 		})
+	} else if agentConfig.MemoryEnabled && state != nil && state.FinalAnswer != "" {
+		// Remember what the user told about themselves, without delaying the end of the answer
+		memoryCtx := logger.CloneContext(ctx)
+		go func() {
+			if err := s.memoryService.ExtractMemories(memoryCtx, summaryModel,
+				agentConfig.MemoryEmbeddingModelID, sessionID, query, state.FinalAnswer); err != nil {
+				logger.Warnf(memoryCtx, "Failed to extract user memories: %v", err)
+			}
+		}()
 	}
 	// Return empty - events will be handled by Handler via EventBus subscription
 	return nil
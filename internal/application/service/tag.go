@@ -143,6 +143,9 @@ func (s *knowledgeTagService) UpdateTag(
 	if err != nil {
 		return nil, err
 	}
+	if err := checkTagPermission(ctx, tag); err != nil {
+		return nil, err
+	}
 
 	if name != nil {
 		newName := strings.TrimSpace(*name)
@@ -175,6 +178,9 @@ func (s *knowledgeTagService) DeleteTag(ctx context.Context, id string, force bo
 	if err != nil {
 		return err
 	}
+	if err := checkTagPermission(ctx, tag); err != nil {
+		return err
+	}
 
 	// Get KB info for embedding model
 	kb, err := s.kbService.GetKnowledgeBaseByID(ctx, tag.KnowledgeBaseID)
@@ -339,3 +345,12 @@ func (s *knowledgeTagService) FindOrCreateTagByName(ctx context.Context, kbID st
 	// 创建新标签
 	return s.CreateTag(ctx, kbID, name, "", 0)
 }
+
+// checkTagPermission checks that the caller may modify the knowledge base the tag belongs to
+func checkTagPermission(ctx context.Context, tag *types.KnowledgeTag) error {
+	principal := types.PrincipalFromContext(ctx)
+	if principal != nil && !principal.CanAccessKnowledgeBase(tag.KnowledgeBaseID, types.KBPermissionWrite) {
+		return werrors.NewForbiddenError("无权访问该知识库")
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/application/repository"
+	werrors "github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultToolApprovalTimeout is how long the agent waits for the approval of a tool call by default
+	DefaultToolApprovalTimeout = 10 * time.Minute
+	// MaxToolApprovalTimeout is the longest timeout of a tool approval the agent configuration can set
+	MaxToolApprovalTimeout = time.Hour
+	// toolApprovalPollInterval is how often the agent checks for a decision. Decisions are read from the
+	// database, so that they reach the agent whichever instance of the server receives them.
+	toolApprovalPollInterval = time.Second
+)
+
+// toolApprovalService implements interfaces.ToolApprovalService
+type toolApprovalService struct {
+	repo interfaces.ToolApprovalRepository
+}
+
+// NewToolApprovalService creates a new tool approval service
+func NewToolApprovalService(repo interfaces.ToolApprovalRepository) interfaces.ToolApprovalService {
+	return &toolApprovalService{repo: repo}
+}
+
+// CreateToolApproval creates a pending approval in the current tenant, expiring after the timeout
+func (s *toolApprovalService) CreateToolApproval(
+	ctx context.Context, approval *types.ToolApproval, timeout time.Duration,
+) (*types.ToolApproval, error) {
+	if timeout <= 0 {
+		timeout = DefaultToolApprovalTimeout
+	}
+	approval.ID = uuid.New().String()
+	approval.TenantID = ctx.Value(types.TenantIDContextKey).(uint64)
+	approval.Status = types.ToolApprovalPending
+	approval.ExpiresAt = time.Now().Add(min(timeout, MaxToolApprovalTimeout))
+	if err := s.repo.Create(ctx, approval); err != nil {
+		return nil, err
+	}
+	logger.Infof(ctx, "Tool call %s of tool %s waits for approval %s, session: %s",
+		approval.ToolCallID, approval.ToolName, approval.ID, approval.SessionID)
+	return approval, nil
+}
+
+// WaitForToolApproval waits until the approval is decided or expires, and returns it.
+// An approval still pending when ctx is done is marked expired.
+func (s *toolApprovalService) WaitForToolApproval(ctx context.Context, id string) (*types.ToolApproval, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	ticker := time.NewTicker(toolApprovalPollInterval)
+	defer ticker.Stop()
+
+	for {
+		approval, err := s.repo.GetByID(ctx, tenantID, id)
+		if err != nil && ctx.Err() == nil {
+			return nil, err
+		}
+		if approval != nil && approval.Status != types.ToolApprovalPending {
+			return approval, nil
+		}
+		if ctx.Err() != nil || (approval != nil && time.Now().After(approval.ExpiresAt)) {
+			return s.expire(context.WithoutCancel(ctx), tenantID, id)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+}
+
+// expire marks a pending approval expired and returns it, or the decision made in the meantime
+func (s *toolApprovalService) expire(ctx context.Context, tenantID uint64, id string) (*types.ToolApproval, error) {
+	now := time.Now()
+	if _, err := s.repo.Decide(ctx, &types.ToolApproval{
+		ID:        id,
+		TenantID:  tenantID,
+		Status:    types.ToolApprovalExpired,
+		DecidedAt: &now,
+	}); err != nil {
+		return nil, err
+	}
+	logger.Infof(ctx, "Tool approval %s expired", id)
+	return s.repo.GetByID(ctx, tenantID, id)
+}
+
+// DecideToolApproval approves or rejects a pending approval of a session of the current tenant
+func (s *toolApprovalService) DecideToolApproval(
+	ctx context.Context, sessionID string, id string, req *types.ToolApprovalDecisionRequest,
+) (*types.ToolApproval, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	approval, err := s.repo.GetByID(ctx, tenantID, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrToolApprovalNotFound) {
+			return nil, werrors.NewNotFoundError("工具调用审批不存在")
+		}
+		return nil, err
+	}
+	if approval.SessionID != sessionID {
+		return nil, werrors.NewNotFoundError("工具调用审批不存在")
+	}
+	if approval.Status == types.ToolApprovalPending && time.Now().After(approval.ExpiresAt) {
+		if approval, err = s.expire(ctx, tenantID, id); err != nil {
+			return nil, err
+		}
+	}
+	if approval.Status != types.ToolApprovalPending {
+		return nil, werrors.NewConflictError("工具调用审批已处理").WithDetails(string(approval.Status))
+	}
+
+	now := time.Now()
+	approval.Status = types.ToolApprovalRejected
+	if *req.Approved {
+		approval.Status = types.ToolApprovalApproved
+	}
+	approval.Reason = req.Reason
+	approval.DecidedAt = &now
+	if principal := types.PrincipalFromContext(ctx); principal != nil {
+		approval.DecidedBy = principal.UserID
+	}
+	decided, err := s.repo.Decide(ctx, approval)
+	if err != nil {
+		return nil, err
+	}
+	if !decided {
+		return nil, werrors.NewConflictError("工具调用审批已处理")
+	}
+	logger.Infof(ctx, "Tool approval %s of tool %s %s", approval.ID, approval.ToolName, approval.Status)
+	return approval, nil
+}
+
+// ListToolApprovals lists the approvals of a session of the current tenant, newest first,
+// optionally only those with the given status
+func (s *toolApprovalService) ListToolApprovals(
+	ctx context.Context, sessionID string, status types.ToolApprovalStatus,
+) ([]*types.ToolApproval, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	return s.repo.ListBySession(ctx, tenantID, sessionID, status)
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/application/repository"
+	werrors "github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeToolApprovalRepository keeps the tool approvals in memory
+type fakeToolApprovalRepository struct {
+	mu        sync.Mutex
+	approvals map[string]*types.ToolApproval
+}
+
+func (r *fakeToolApprovalRepository) Create(ctx context.Context, approval *types.ToolApproval) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *approval
+	r.approvals[approval.ID] = &copied
+	return nil
+}
+
+func (r *fakeToolApprovalRepository) GetByID(
+	ctx context.Context, tenantID uint64, id string,
+) (*types.ToolApproval, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	approval, ok := r.approvals[id]
+	if !ok || approval.TenantID != tenantID {
+		return nil, repository.ErrToolApprovalNotFound
+	}
+	copied := *approval
+	return &copied, nil
+}
+
+func (r *fakeToolApprovalRepository) Decide(ctx context.Context, approval *types.ToolApproval) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.approvals[approval.ID]
+	if !ok || stored.Status != types.ToolApprovalPending {
+		return false, nil
+	}
+	stored.Status = approval.Status
+	stored.Reason = approval.Reason
+	stored.DecidedAt = approval.DecidedAt
+	stored.DecidedBy = approval.DecidedBy
+	return true, nil
+}
+
+func (r *fakeToolApprovalRepository) ListBySession(
+	ctx context.Context, tenantID uint64, sessionID string, status types.ToolApprovalStatus,
+) ([]*types.ToolApproval, error) {
+	return nil, nil
+}
+
+func (r *fakeToolApprovalRepository) status(id string) types.ToolApprovalStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.approvals[id].Status
+}
+
+// newTestToolApprovalService creates a tool approval service with a pending approval of session1
+// which expires after expiresIn
+func newTestToolApprovalService(expiresIn time.Duration) (*toolApprovalService, *fakeToolApprovalRepository) {
+	repo := &fakeToolApprovalRepository{approvals: map[string]*types.ToolApproval{
+		"approval1": {
+			ID:        "approval1",
+			TenantID:  1,
+			SessionID: "session1",
+			ToolName:  "mcp.tickets.create",
+			Status:    types.ToolApprovalPending,
+			ExpiresAt: time.Now().Add(expiresIn),
+		},
+	}}
+	return &toolApprovalService{repo: repo}, repo
+}
+
+func toolApprovalTestContext() context.Context {
+	return context.WithValue(context.Background(), types.TenantIDContextKey, uint64(1))
+}
+
+func TestWaitForToolApprovalExpired(t *testing.T) {
+	s, repo := newTestToolApprovalService(-time.Second)
+
+	approval, err := s.WaitForToolApproval(toolApprovalTestContext(), "approval1")
+	require.NoError(t, err)
+	assert.Equal(t, types.ToolApprovalExpired, approval.Status)
+	assert.NotNil(t, approval.DecidedAt)
+	assert.Equal(t, types.ToolApprovalExpired, repo.status("approval1"))
+}
+
+func TestWaitForToolApprovalExpiresWhileWaiting(t *testing.T) {
+	s, repo := newTestToolApprovalService(100 * time.Millisecond)
+
+	start := time.Now()
+	approval, err := s.WaitForToolApproval(toolApprovalTestContext(), "approval1")
+	require.NoError(t, err)
+	assert.Equal(t, types.ToolApprovalExpired, approval.Status)
+	assert.Equal(t, types.ToolApprovalExpired, repo.status("approval1"))
+	// The expiry is noticed on the next poll
+	assert.Less(t, time.Since(start), 2*toolApprovalPollInterval)
+}
+
+func TestWaitForToolApprovalCancelled(t *testing.T) {
+	s, repo := newTestToolApprovalService(time.Hour)
+	ctx, cancel := context.WithTimeout(toolApprovalTestContext(), 50*time.Millisecond)
+	defer cancel()
+
+	// An agent which stops waiting expires the approval, so that it can no longer be approved
+	approval, err := s.WaitForToolApproval(ctx, "approval1")
+	require.NoError(t, err)
+	assert.Equal(t, types.ToolApprovalExpired, approval.Status)
+	assert.Equal(t, types.ToolApprovalExpired, repo.status("approval1"))
+}
+
+func TestWaitForToolApprovalDecided(t *testing.T) {
+	s, _ := newTestToolApprovalService(time.Hour)
+	ctx := toolApprovalTestContext()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		approved := true
+		_, _ = s.DecideToolApproval(ctx, "session1", "approval1", &types.ToolApprovalDecisionRequest{
+			Approved: &approved,
+		})
+	}()
+	approval, err := s.WaitForToolApproval(ctx, "approval1")
+	require.NoError(t, err)
+	assert.Equal(t, types.ToolApprovalApproved, approval.Status)
+}
+
+func TestDecideToolApprovalAfterExpiry(t *testing.T) {
+	s, repo := newTestToolApprovalService(-time.Second)
+	approved := true
+
+	_, err := s.DecideToolApproval(toolApprovalTestContext(), "session1", "approval1",
+		&types.ToolApprovalDecisionRequest{Approved: &approved})
+	var appErr *werrors.AppError
+	require.True(t, errors.As(err, &appErr), "unexpected error %v", err)
+	assert.Equal(t, werrors.ErrConflict, appErr.Code)
+	assert.Equal(t, types.ToolApprovalExpired, repo.status("approval1"))
+
+	// Approvals of other sessions are not found
+	s, _ = newTestToolApprovalService(time.Hour)
+	_, err = s.DecideToolApproval(toolApprovalTestContext(), "session2", "approval1",
+		&types.ToolApprovalDecisionRequest{Approved: &approved})
+	require.True(t, errors.As(err, &appErr), "unexpected error %v", err)
+	assert.Equal(t, werrors.ErrNotFound, appErr.Code)
+}
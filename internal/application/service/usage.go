@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	werrors "github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/models/chat"
+	"github.com/Tencent/WeKnora/internal/models/embedding"
+	"github.com/Tencent/WeKnora/internal/models/rerank"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// usageRateKeyPrefix prefixes the per minute request counters
+	usageRateKeyPrefix = "usage:rpm:"
+	// usageTokensKeyPrefix prefixes the month to date token counters
+	usageTokensKeyPrefix = "usage:tokens:"
+	// usageTokensTTL keeps a month to date counter a bit longer than its month
+	usageTokensTTL = 35 * 24 * time.Hour
+	// usageReportMaxDays bounds the period of a usage report
+	usageReportMaxDays = 366
+)
+
+// usageIncrIfExists adds to a month to date counter only once it has been loaded from the ledger,
+// so a counter never misses the usage recorded before it existed
+var usageIncrIfExists = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return redis.call('INCRBY', KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+// usageService meters model calls in the usage ledger and enforces the limits of tenants and API keys.
+// Rate limits use fixed one minute windows in Redis, monthly budgets use month to date counters
+// loaded from the ledger. Limits are not enforced when Redis is unavailable.
+type usageService struct {
+	repo        interfaces.UsageRepository
+	tenantRepo  interfaces.TenantRepository
+	redisClient *redis.Client
+}
+
+// NewUsageService creates a new usage service
+func NewUsageService(
+	repo interfaces.UsageRepository,
+	tenantRepo interfaces.TenantRepository,
+	redisClient *redis.Client,
+) interfaces.UsageService {
+	return &usageService{
+		repo:        repo,
+		tenantRepo:  tenantRepo,
+		redisClient: redisClient,
+	}
+}
+
+// usageScope is a tenant or an API key whose usage is limited
+type usageScope struct {
+	name     string
+	tenantID uint64
+	apiKeyID string
+	limits   types.UsageLimits
+}
+
+// usageScopes returns the tenant and API key of the context which have limits configured
+func (s *usageService) usageScopes(ctx context.Context, tenantID uint64) []usageScope {
+	var scopes []usageScope
+	tenant, _ := ctx.Value(types.TenantInfoContextKey).(*types.Tenant)
+	if tenant == nil || tenant.ID != tenantID {
+		var err error
+		if tenant, err = s.tenantRepo.GetTenantByID(ctx, tenantID); err != nil {
+			logger.Warnf(ctx, "Failed to get tenant %d for usage limits: %v", tenantID, err)
+			tenant = nil
+		}
+	}
+	if tenant != nil && tenant.UsageLimits != nil {
+		scopes = append(scopes, usageScope{
+			name:     fmt.Sprintf("tenant:%d", tenantID),
+			tenantID: tenantID,
+			limits:   *tenant.UsageLimits,
+		})
+	}
+	if principal := types.PrincipalFromContext(ctx); principal != nil && principal.APIKey != nil {
+		key := principal.APIKey
+		scopes = append(scopes, usageScope{
+			name:     "key:" + key.ID,
+			tenantID: tenantID,
+			apiKeyID: key.ID,
+			limits:   key.UsageLimits(),
+		})
+	}
+	return scopes
+}
+
+// CheckQuota counts a model call against the limits of the tenant and API key in the context
+func (s *usageService) CheckQuota(ctx context.Context) error {
+	tenantID, _ := ctx.Value(types.TenantIDContextKey).(uint64)
+	if s.redisClient == nil || tenantID == 0 {
+		return nil
+	}
+	now := time.Now()
+	for _, scope := range s.usageScopes(ctx, tenantID) {
+		if scope.limits.RequestsPerMinute > 0 {
+			key := fmt.Sprintf("%s%s:%s", usageRateKeyPrefix, scope.name, now.Format("200601021504"))
+			count, err := s.redisClient.Incr(ctx, key).Result()
+			if err != nil {
+				logger.Warnf(ctx, "Failed to count model call of %s: %v", scope.name, err)
+				continue
+			}
+			if count == 1 {
+				s.redisClient.Expire(ctx, key, 2*time.Minute)
+			}
+			if count > int64(scope.limits.RequestsPerMinute) {
+				logger.Warnf(ctx, "Model call rate limit of %s exceeded", scope.name)
+				return werrors.NewUsageRateLimitedError(scope.limits.RequestsPerMinute)
+			}
+		}
+		if scope.limits.MonthlyTokenBudget > 0 {
+			used, err := s.monthTokens(ctx, scope, now)
+			if err != nil {
+				logger.Warnf(ctx, "Failed to get monthly token usage of %s: %v", scope.name, err)
+				continue
+			}
+			if used >= scope.limits.MonthlyTokenBudget {
+				logger.Warnf(ctx, "Monthly token budget of %s exceeded, used %d", scope.name, used)
+				return werrors.NewUsageBudgetExceededError(scope.limits.MonthlyTokenBudget)
+			}
+		}
+	}
+	return nil
+}
+
+// monthTokensKey returns the key of the month to date token counter of a scope
+func monthTokensKey(name string, now time.Time) string {
+	return fmt.Sprintf("%s%s:%s", usageTokensKeyPrefix, name, now.Format("200601"))
+}
+
+// monthTokens returns the tokens used by a scope in the current month, loading the counter from the ledger
+func (s *usageService) monthTokens(ctx context.Context, scope usageScope, now time.Time) (int64, error) {
+	key := monthTokensKey(scope.name, now)
+	used, err := s.redisClient.Get(ctx, key).Int64()
+	if err == nil {
+		return used, nil
+	}
+	if err != redis.Nil {
+		return 0, err
+	}
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	used, err = s.repo.SumTokens(ctx, scope.tenantID, scope.apiKeyID, monthStart)
+	if err != nil {
+		return 0, err
+	}
+	if ok, err := s.redisClient.SetNX(ctx, key, used, usageTokensTTL).Result(); err == nil && !ok {
+		// Another request loaded the counter first, it may already include newer usage
+		return s.redisClient.Get(ctx, key).Int64()
+	}
+	return used, nil
+}
+
+// RecordUsage writes a model call to the usage ledger and the month to date counters
+func (s *usageService) RecordUsage(ctx context.Context, record *types.UsageRecord) {
+	if record.TenantID == 0 {
+		record.TenantID, _ = ctx.Value(types.TenantIDContextKey).(uint64)
+		if record.TenantID == 0 {
+			return
+		}
+	}
+	if principal := types.PrincipalFromContext(ctx); principal != nil && principal.APIKey != nil {
+		record.APIKeyID = principal.APIKey.ID
+	}
+	if record.TotalTokens == 0 {
+		record.TotalTokens = record.PromptTokens + record.CompletionTokens
+	}
+	if record.Requests == 0 {
+		record.Requests = 1
+	}
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	// Streams finish after their request may have been cancelled, the usage is recorded regardless
+	ctx = context.WithoutCancel(ctx)
+	if err := s.repo.Create(ctx, record); err != nil {
+		logger.Errorf(ctx, "Failed to record usage of model %s: %v", record.ModelID, err)
+		return
+	}
+	if s.redisClient == nil || record.TotalTokens == 0 {
+		return
+	}
+	names := []string{fmt.Sprintf("tenant:%d", record.TenantID)}
+	if record.APIKeyID != "" {
+		names = append(names, "key:"+record.APIKeyID)
+	}
+	for _, name := range names {
+		key := monthTokensKey(name, record.CreatedAt)
+		if err := usageIncrIfExists.Run(ctx, s.redisClient, []string{key}, record.TotalTokens).Err(); err != nil {
+			logger.Warnf(ctx, "Failed to update monthly token usage of %s: %v", name, err)
+		}
+	}
+}
+
+// GetUsageReport reports the usage of the current tenant by day and model
+func (s *usageService) GetUsageReport(
+	ctx context.Context, from, to time.Time, apiKeyID string,
+) (*types.UsageReport, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	if to.Before(from) {
+		return nil, werrors.NewValidationError("结束日期不能早于开始日期")
+	}
+	end := to.AddDate(0, 0, 1)
+	if end.Sub(from) > usageReportMaxDays*24*time.Hour {
+		return nil, werrors.NewValidationError(fmt.Sprintf("统计区间不能超过%d天", usageReportMaxDays))
+	}
+
+	items, err := s.repo.Report(ctx, tenantID, apiKeyID, from, end)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to get usage report of tenant %d: %v", tenantID, err)
+		return nil, err
+	}
+	report := &types.UsageReport{
+		From:     from.Format(time.DateOnly),
+		To:       to.Format(time.DateOnly),
+		APIKeyID: apiKeyID,
+		Items:    items,
+	}
+	if report.Items == nil {
+		report.Items = []*types.UsageReportItem{}
+	}
+	for _, item := range items {
+		report.Totals.PromptTokens += item.PromptTokens
+		report.Totals.CompletionTokens += item.CompletionTokens
+		report.Totals.TotalTokens += item.TotalTokens
+		report.Totals.Requests += item.Requests
+	}
+	return report, nil
+}
+
+// WrapChat meters the calls of a chat model
+func (s *usageService) WrapChat(model chat.Chat) chat.Chat {
+	return &meteredChat{model: model, usage: s}
+}
+
+// WrapEmbedder meters the calls of an embedding model
+func (s *usageService) WrapEmbedder(model embedding.Embedder) embedding.Embedder {
+	return &meteredEmbedder{Embedder: model, usage: s}
+}
+
+// WrapReranker meters the calls of a rerank model
+func (s *usageService) WrapReranker(model rerank.Reranker) rerank.Reranker {
+	return &meteredReranker{Reranker: model, usage: s}
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/models/chat"
+	"github.com/Tencent/WeKnora/internal/models/embedding"
+	"github.com/Tencent/WeKnora/internal/models/rerank"
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// estimateTokens roughly estimates the tokens of texts, for models which do not report their usage
+func estimateTokens(texts ...string) int64 {
+	chars := 0
+	for _, text := range texts {
+		chars += len(text)
+	}
+	return int64((chars + 3) / 4)
+}
+
+// estimateMessageTokens roughly estimates the tokens of chat messages
+func estimateMessageTokens(messages []chat.Message) int64 {
+	var tokens int64
+	for _, msg := range messages {
+		tokens += estimateTokens(msg.Role, msg.Content)
+		for _, tc := range msg.ToolCalls {
+			tokens += estimateTokens(tc.Function.Name, tc.Function.Arguments)
+		}
+	}
+	return tokens
+}
+
+// newUsageRecord creates the usage record of a call to a model
+func newUsageRecord(callType types.UsageCallType, modelID, modelName string) *types.UsageRecord {
+	return &types.UsageRecord{
+		ModelID:   modelID,
+		ModelName: modelName,
+		CallType:  callType,
+		Requests:  1,
+		CreatedAt: time.Now(),
+	}
+}
+
+// meteredChat checks the usage limits before every call of a chat model and records its usage
+type meteredChat struct {
+	model chat.Chat
+	usage *usageService
+}
+
+// GetModelName returns the name of the metered model
+func (m *meteredChat) GetModelName() string {
+	return m.model.GetModelName()
+}
+
+// GetModelID returns the ID of the metered model
+func (m *meteredChat) GetModelID() string {
+	return m.model.GetModelID()
+}
+
+// Chat checks the usage limits, calls the model and records the reported or estimated usage
+func (m *meteredChat) Chat(
+	ctx context.Context, messages []chat.Message, opts *chat.ChatOptions,
+) (*types.ChatResponse, error) {
+	if err := m.usage.CheckQuota(ctx); err != nil {
+		return nil, err
+	}
+	resp, err := m.model.Chat(ctx, messages, opts)
+	if err != nil {
+		return nil, err
+	}
+	record := newUsageRecord(types.UsageCallTypeChat, m.GetModelID(), m.GetModelName())
+	if resp.Usage.TotalTokens > 0 {
+		record.PromptTokens = int64(resp.Usage.PromptTokens)
+		record.CompletionTokens = int64(resp.Usage.CompletionTokens)
+		record.TotalTokens = int64(resp.Usage.TotalTokens)
+	} else {
+		record.PromptTokens = estimateMessageTokens(messages)
+		record.CompletionTokens = estimateTokens(resp.Content)
+		record.Estimated = true
+	}
+	m.usage.RecordUsage(ctx, record)
+	return resp, nil
+}
+
+// ChatStream checks the usage limits and records the estimated usage once the stream is done
+func (m *meteredChat) ChatStream(
+	ctx context.Context, messages []chat.Message, opts *chat.ChatOptions,
+) (<-chan types.StreamResponse, error) {
+	if err := m.usage.CheckQuota(ctx); err != nil {
+		return nil, err
+	}
+	stream, err := m.model.ChatStream(ctx, messages, opts)
+	if err != nil {
+		return nil, err
+	}
+	record := newUsageRecord(types.UsageCallTypeChat, m.GetModelID(), m.GetModelName())
+	record.PromptTokens = estimateMessageTokens(messages)
+	record.Estimated = true
+
+	out := make(chan types.StreamResponse)
+	go func() {
+		defer close(out)
+		// Streamed tool calls accumulate, only the latest ones are counted
+		var toolCalls []types.LLMToolCall
+		defer func() {
+			for _, tc := range toolCalls {
+				record.CompletionTokens += estimateTokens(tc.Function.Name, tc.Function.Arguments)
+			}
+			m.usage.RecordUsage(ctx, record)
+		}()
+		for resp := range stream {
+			record.CompletionTokens += estimateTokens(resp.Content)
+			if len(resp.ToolCalls) > 0 {
+				toolCalls = resp.ToolCalls
+			}
+			select {
+			case out <- resp:
+			case <-ctx.Done():
+				// Keep draining so that the model stream can finish
+			}
+		}
+	}()
+	return out, nil
+}
+
+// meteredEmbedder checks the usage limits before every call of an embedding model and records its usage
+type meteredEmbedder struct {
+	embedding.Embedder
+	usage *usageService
+}
+
+// Embed checks the usage limits, embeds a text and records the estimated usage
+func (m *meteredEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	if err := m.usage.CheckQuota(ctx); err != nil {
+		return nil, err
+	}
+	vector, err := m.Embedder.Embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	m.record(ctx, text)
+	return vector, nil
+}
+
+// BatchEmbed checks the usage limits, embeds texts and records the estimated usage
+func (m *meteredEmbedder) BatchEmbed(ctx context.Context, texts []string) ([][]float32, error) {
+	if err := m.usage.CheckQuota(ctx); err != nil {
+		return nil, err
+	}
+	vectors, err := m.Embedder.BatchEmbed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	m.record(ctx, texts...)
+	return vectors, nil
+}
+
+// BatchEmbedWithPool embeds texts in batches through the metered model, each batch counting as a call
+func (m *meteredEmbedder) BatchEmbedWithPool(
+	ctx context.Context, model embedding.Embedder, texts []string,
+) ([][]float32, error) {
+	if model == m.Embedder {
+		model = m
+	}
+	return m.Embedder.BatchEmbedWithPool(ctx, model, texts)
+}
+
+func (m *meteredEmbedder) record(ctx context.Context, texts ...string) {
+	record := newUsageRecord(types.UsageCallTypeEmbedding, m.GetModelID(), m.GetModelName())
+	record.PromptTokens = estimateTokens(texts...)
+	record.Estimated = true
+	m.usage.RecordUsage(ctx, record)
+}
+
+// meteredReranker checks the usage limits before every call of a rerank model and records its usage
+type meteredReranker struct {
+	rerank.Reranker
+	usage *usageService
+}
+
+// Rerank checks the usage limits, reranks documents and records the estimated usage
+func (m *meteredReranker) Rerank(
+	ctx context.Context, query string, documents []string,
+) ([]rerank.RankResult, error) {
+	if err := m.usage.CheckQuota(ctx); err != nil {
+		return nil, err
+	}
+	results, err := m.Reranker.Rerank(ctx, query, documents)
+	if err != nil {
+		return nil, err
+	}
+	record := newUsageRecord(types.UsageCallTypeRerank, m.GetModelID(), m.GetModelName())
+	// Every document is scored together with the query
+	record.PromptTokens = estimateTokens(documents...) + int64(len(documents))*estimateTokens(query)
+	record.Estimated = true
+	m.usage.RecordUsage(ctx, record)
+	return results, nil
+}
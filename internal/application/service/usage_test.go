@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	werrors "github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/models/chat"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUsageRepository keeps the usage records in memory
+type fakeUsageRepository struct {
+	mu      sync.Mutex
+	records []*types.UsageRecord
+	ledger  int64
+	sums    int
+	// createCtxErr is the error of the context of the last Create call
+	createCtxErr error
+}
+
+func (r *fakeUsageRepository) Create(ctx context.Context, record *types.UsageRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.createCtxErr = ctx.Err()
+	r.records = append(r.records, record)
+	r.ledger += record.TotalTokens
+	return nil
+}
+
+func (r *fakeUsageRepository) SumTokens(
+	ctx context.Context, tenantID uint64, apiKeyID string, since time.Time,
+) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sums++
+	return r.ledger, nil
+}
+
+func (r *fakeUsageRepository) Report(
+	ctx context.Context, tenantID uint64, apiKeyID string, from, to time.Time,
+) ([]*types.UsageReportItem, error) {
+	return nil, nil
+}
+
+func (r *fakeUsageRepository) recorded() []*types.UsageRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*types.UsageRecord(nil), r.records...)
+}
+
+// newTestUsageService creates a usage service on an in-memory Redis
+func newTestUsageService(t *testing.T) (*usageService, *fakeUsageRepository, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	repo := &fakeUsageRepository{}
+	return &usageService{repo: repo, redisClient: client}, repo, mr
+}
+
+// usageTestContext returns the context of a request of tenant 1 with the given limits
+func usageTestContext(limits *types.UsageLimits, apiKey *types.APIKey) context.Context {
+	ctx := context.WithValue(context.Background(), types.TenantIDContextKey, uint64(1))
+	ctx = context.WithValue(ctx, types.TenantInfoContextKey, &types.Tenant{ID: 1, UsageLimits: limits})
+	if apiKey != nil {
+		ctx = context.WithValue(ctx, types.PrincipalContextKey, &types.Principal{
+			Role: types.TenantRoleOwner, APIKey: apiKey,
+		})
+	}
+	return ctx
+}
+
+// assertUsageError checks that err is the application error with the given code
+func assertUsageError(t *testing.T, err error, code werrors.ErrorCode) {
+	t.Helper()
+	var appErr *werrors.AppError
+	require.True(t, errors.As(err, &appErr), "unexpected error %v", err)
+	assert.Equal(t, code, appErr.Code)
+}
+
+func TestCheckQuotaRateWindow(t *testing.T) {
+	s, _, mr := newTestUsageService(t)
+	ctx := usageTestContext(&types.UsageLimits{RequestsPerMinute: 2}, nil)
+
+	require.NoError(t, s.CheckQuota(ctx))
+	require.NoError(t, s.CheckQuota(ctx))
+	assertUsageError(t, s.CheckQuota(ctx), werrors.ErrUsageRateLimited)
+
+	// The counter of a window expires after the window
+	keys := mr.Keys()
+	require.Len(t, keys, 1)
+	assert.Equal(t, 2*time.Minute, mr.TTL(keys[0]))
+	mr.FastForward(2 * time.Minute)
+	assert.NoError(t, s.CheckQuota(ctx))
+}
+
+func TestCheckQuotaAPIKeyRate(t *testing.T) {
+	s, _, _ := newTestUsageService(t)
+	key := &types.APIKey{ID: "key1", RequestsPerMinute: 1}
+	ctx := usageTestContext(nil, key)
+
+	require.NoError(t, s.CheckQuota(ctx))
+	assertUsageError(t, s.CheckQuota(ctx), werrors.ErrUsageRateLimited)
+
+	// Other keys of the tenant have their own window
+	other := usageTestContext(nil, &types.APIKey{ID: "key2", RequestsPerMinute: 1})
+	assert.NoError(t, s.CheckQuota(other))
+	// Requests without a key are not limited
+	assert.NoError(t, s.CheckQuota(usageTestContext(nil, nil)))
+}
+
+func TestCheckQuotaBudget(t *testing.T) {
+	s, repo, mr := newTestUsageService(t)
+	repo.ledger = 90
+	ctx := usageTestContext(&types.UsageLimits{MonthlyTokenBudget: 100}, nil)
+
+	// The first check loads the month to date counter from the ledger
+	require.NoError(t, s.CheckQuota(ctx))
+	assert.Equal(t, 1, repo.sums)
+	key := monthTokensKey("tenant:1", time.Now())
+	value, err := mr.Get(key)
+	require.NoError(t, err)
+	assert.Equal(t, "90", value)
+	assert.Equal(t, usageTokensTTL, mr.TTL(key))
+
+	// Usage is added to the loaded counter, which is not loaded again
+	s.RecordUsage(ctx, &types.UsageRecord{ModelID: "m1", PromptTokens: 15, CompletionTokens: 5})
+	value, err = mr.Get(key)
+	require.NoError(t, err)
+	assert.Equal(t, "110", value)
+	assertUsageError(t, s.CheckQuota(ctx), werrors.ErrUsageBudgetExceeded)
+	assert.Equal(t, 1, repo.sums)
+}
+
+func TestRecordUsageBeforeCounterLoaded(t *testing.T) {
+	s, repo, mr := newTestUsageService(t)
+	ctx := usageTestContext(&types.UsageLimits{MonthlyTokenBudget: 100}, &types.APIKey{ID: "key1"})
+
+	s.RecordUsage(ctx, &types.UsageRecord{ModelID: "m1", PromptTokens: 60, CompletionTokens: 40})
+	records := repo.recorded()
+	require.Len(t, records, 1)
+	assert.Equal(t, uint64(1), records[0].TenantID)
+	assert.Equal(t, "key1", records[0].APIKeyID)
+	assert.Equal(t, int64(100), records[0].TotalTokens)
+	assert.Equal(t, int64(1), records[0].Requests)
+	// Counters are only created from the ledger, so that they never miss earlier usage
+	assert.Empty(t, mr.Keys())
+
+	assertUsageError(t, s.CheckQuota(ctx), werrors.ErrUsageBudgetExceeded)
+	assert.Equal(t, 1, repo.sums)
+}
+
+func TestMonthTokensConcurrentLoad(t *testing.T) {
+	s, repo, mr := newTestUsageService(t)
+	repo.ledger = 10
+	scope := usageScope{name: "tenant:1", tenantID: 1}
+	now := time.Now()
+
+	// Another request loaded the counter and added usage after this one read the ledger
+	require.NoError(t, mr.Set(monthTokensKey(scope.name, now), "25"))
+	used, err := s.monthTokens(context.Background(), scope, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(25), used)
+	assert.Equal(t, 0, repo.sums)
+}
+
+func TestCheckQuotaWithoutRedis(t *testing.T) {
+	s := &usageService{repo: &fakeUsageRepository{}}
+	ctx := usageTestContext(&types.UsageLimits{RequestsPerMinute: 1, MonthlyTokenBudget: 1}, nil)
+	assert.NoError(t, s.CheckQuota(ctx))
+	assert.NoError(t, s.CheckQuota(ctx))
+}
+
+func TestCheckQuotaRedisUnavailable(t *testing.T) {
+	s, _, mr := newTestUsageService(t)
+	mr.Close()
+	ctx := usageTestContext(&types.UsageLimits{RequestsPerMinute: 1, MonthlyTokenBudget: 1}, nil)
+	// Limits are not enforced when Redis is unavailable
+	assert.NoError(t, s.CheckQuota(ctx))
+	assert.NoError(t, s.CheckQuota(ctx))
+}
+
+// streamingChat streams the given chunks, waiting for each to be received or the request to be cancelled
+type streamingChat struct {
+	chunks []string
+}
+
+func (m *streamingChat) Chat(
+	ctx context.Context, messages []chat.Message, opts *chat.ChatOptions,
+) (*types.ChatResponse, error) {
+	return &types.ChatResponse{Content: "answer"}, nil
+}
+
+func (m *streamingChat) ChatStream(
+	ctx context.Context, messages []chat.Message, opts *chat.ChatOptions,
+) (<-chan types.StreamResponse, error) {
+	stream := make(chan types.StreamResponse)
+	go func() {
+		defer close(stream)
+		for _, chunk := range m.chunks {
+			stream <- types.StreamResponse{Content: chunk}
+		}
+	}()
+	return stream, nil
+}
+
+func (m *streamingChat) GetModelName() string { return "test-model" }
+
+func (m *streamingChat) GetModelID() string { return "model1" }
+
+func TestMeteredChatStreamRecordsUsageWhenCancelled(t *testing.T) {
+	s, repo, _ := newTestUsageService(t)
+	model := s.WrapChat(&streamingChat{chunks: []string{"12345678", "abcdefgh", "ABCDEFGH"}})
+	ctx, cancel := context.WithCancel(usageTestContext(nil, nil))
+
+	stream, err := model.ChatStream(ctx, []chat.Message{{Role: "user", Content: "question"}}, nil)
+	require.NoError(t, err)
+	first := <-stream
+	assert.Equal(t, "12345678", first.Content)
+	// The client goes away after the first chunk
+	cancel()
+
+	require.Eventually(t, func() bool { return len(repo.recorded()) == 1 }, time.Second, 10*time.Millisecond)
+	record := repo.recorded()[0]
+	assert.Equal(t, types.UsageCallTypeChat, record.CallType)
+	assert.Equal(t, "model1", record.ModelID)
+	assert.True(t, record.Estimated)
+	assert.Equal(t, estimateTokens("user", "question"), record.PromptTokens)
+	// The chunks the client missed were still generated and count
+	assert.Equal(t, int64(6), record.CompletionTokens)
+	assert.Equal(t, record.PromptTokens+record.CompletionTokens, record.TotalTokens)
+	repo.mu.Lock()
+	assert.NoError(t, repo.createCtxErr)
+	repo.mu.Unlock()
+
+	// The output is closed once the model stream is drained
+	for range stream {
+	}
+}
+
+func TestMeteredChatStreamRateLimited(t *testing.T) {
+	s, repo, _ := newTestUsageService(t)
+	model := s.WrapChat(&streamingChat{chunks: []string{"a"}})
+	ctx := usageTestContext(&types.UsageLimits{RequestsPerMinute: 1}, nil)
+
+	stream, err := model.ChatStream(ctx, nil, nil)
+	require.NoError(t, err)
+	for range stream {
+	}
+	_, err = model.ChatStream(ctx, nil, nil)
+	assertUsageError(t, err, werrors.ErrUsageRateLimited)
+	require.Eventually(t, func() bool { return len(repo.recorded()) == 1 }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,523 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/application/repository"
+	werrors "github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/event"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+)
+
+const (
+	// webhookMaxRetry is the number of retries of a failed delivery, with the exponential backoff of asynq
+	webhookMaxRetry = 8
+	// webhookTimeout is the timeout of a delivery attempt
+	webhookTimeout = 10 * time.Second
+	// webhookResponseBodyLimit is the number of bytes of the response kept in the delivery log
+	webhookResponseBodyLimit = 1024
+	// webhookMinSecretLength is the minimum length of a signing secret chosen by the user
+	webhookMinSecretLength = 16
+
+	webhookEventHeader     = "X-WeKnora-Event"
+	webhookDeliveryHeader  = "X-WeKnora-Delivery"
+	webhookTimestampHeader = "X-WeKnora-Timestamp"
+	webhookSignatureHeader = "X-WeKnora-Signature"
+)
+
+// errWebhookAddressNotAllowed is returned when a webhook resolves to a loopback, private or link-local address
+var errWebhookAddressNotAllowed = errors.New("webhook address is not a public address")
+
+// webhookService implements interfaces.WebhookService
+type webhookService struct {
+	repo   interfaces.WebhookRepository
+	task   *asynq.Client
+	client *http.Client
+}
+
+// newWebhookTransport creates the transport of webhook deliveries. The address is checked again when dialing,
+// after DNS resolution, so that a host which resolved to a public address on validation cannot be
+// pointed to an internal one afterwards
+func newWebhookTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicWebhookIP(ip) {
+				return fmt.Errorf("%w: %s", errWebhookAddressNotAllowed, host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Deliveries are not sent through a proxy, which would dial the receiver without the address check
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+// NewWebhookService creates a new webhook service, subscribed to the lifecycle events of the global event bus
+func NewWebhookService(repo interfaces.WebhookRepository, task *asynq.Client) interfaces.WebhookService {
+	s := &webhookService{
+		repo: repo,
+		task: task,
+		client: &http.Client{
+			Timeout:   webhookTimeout,
+			Transport: newWebhookTransport(),
+			// Redirects are reported as failures, the receiver must answer at the configured URL
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+	for _, eventType := range event.LifecycleEvents {
+		event.On(eventType, s.handleEvent)
+	}
+	return s
+}
+
+// CreateWebhook creates a webhook in the current tenant, the signing secret is only returned here
+func (s *webhookService) CreateWebhook(
+	ctx context.Context, req *types.CreateWebhookRequest,
+) (*types.WebhookWithSecret, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, werrors.NewValidationError("Webhook 名称不能为空")
+	}
+	if err := validateWebhookURL(ctx, req.URL); err != nil {
+		return nil, err
+	}
+	events, err := normalizeWebhookEvents(req.Events)
+	if err != nil {
+		return nil, err
+	}
+	secret := req.Secret
+	if secret == "" {
+		if secret, err = generateWebhookSecret(); err != nil {
+			return nil, err
+		}
+	} else if len(secret) < webhookMinSecretLength {
+		return nil, werrors.NewValidationError(fmt.Sprintf("签名密钥长度不能少于%d个字符", webhookMinSecretLength))
+	}
+
+	webhook := &types.Webhook{
+		ID:       uuid.New().String(),
+		TenantID: tenantID,
+		Name:     name,
+		URL:      strings.TrimSpace(req.URL),
+		Secret:   secret,
+		Events:   events,
+		Enabled:  true,
+	}
+	if principal := types.PrincipalFromContext(ctx); principal != nil {
+		webhook.CreatedBy = principal.UserID
+	}
+	if err := s.repo.Create(ctx, webhook); err != nil {
+		return nil, err
+	}
+	logger.Infof(ctx, "Webhook %s created in tenant %d for events %v", webhook.ID, tenantID, events)
+	return &types.WebhookWithSecret{Webhook: webhook, Secret: secret}, nil
+}
+
+// GetWebhook gets a webhook of the current tenant
+func (s *webhookService) GetWebhook(ctx context.Context, id string) (*types.Webhook, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	webhook, err := s.repo.GetByID(ctx, tenantID, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrWebhookNotFound) {
+			return nil, werrors.NewNotFoundError("Webhook 不存在")
+		}
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// ListWebhooks lists the webhooks of the current tenant
+func (s *webhookService) ListWebhooks(ctx context.Context) ([]*types.Webhook, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	return s.repo.List(ctx, tenantID)
+}
+
+// UpdateWebhook updates a webhook of the current tenant, the secret is returned when it is rotated
+func (s *webhookService) UpdateWebhook(
+	ctx context.Context, id string, req *types.UpdateWebhookRequest,
+) (*types.WebhookWithSecret, error) {
+	webhook, err := s.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, werrors.NewValidationError("Webhook 名称不能为空")
+		}
+		webhook.Name = name
+	}
+	if req.URL != nil {
+		if err := validateWebhookURL(ctx, *req.URL); err != nil {
+			return nil, err
+		}
+		webhook.URL = strings.TrimSpace(*req.URL)
+	}
+	if req.Events != nil {
+		events, err := normalizeWebhookEvents(req.Events)
+		if err != nil {
+			return nil, err
+		}
+		webhook.Events = events
+	}
+	if req.Enabled != nil {
+		webhook.Enabled = *req.Enabled
+	}
+	result := &types.WebhookWithSecret{Webhook: webhook}
+	if req.RotateSecret {
+		if webhook.Secret, err = generateWebhookSecret(); err != nil {
+			return nil, err
+		}
+		result.Secret = webhook.Secret
+	}
+	if err := s.repo.Update(ctx, webhook); err != nil {
+		return nil, err
+	}
+	logger.Infof(ctx, "Webhook %s updated in tenant %d", webhook.ID, webhook.TenantID)
+	return result, nil
+}
+
+// DeleteWebhook deletes a webhook of the current tenant together with its deliveries
+func (s *webhookService) DeleteWebhook(ctx context.Context, id string) error {
+	webhook, err := s.GetWebhook(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, webhook.TenantID, webhook.ID); err != nil {
+		return err
+	}
+	logger.Infof(ctx, "Webhook %s deleted in tenant %d", webhook.ID, webhook.TenantID)
+	return nil
+}
+
+// ListDeliveries lists the deliveries of a webhook of the current tenant, newest first
+func (s *webhookService) ListDeliveries(
+	ctx context.Context, id string, page *types.Pagination,
+) (*types.PageResult, error) {
+	webhook, err := s.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	deliveries, total, err := s.repo.ListDeliveries(ctx, webhook.TenantID, webhook.ID, page)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewPageResult(total, page, deliveries), nil
+}
+
+// PingWebhook delivers a webhook.ping event to a webhook of the current tenant
+func (s *webhookService) PingWebhook(ctx context.Context, id string) (*types.WebhookDelivery, error) {
+	webhook, err := s.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := newWebhookPayload(ctx, webhook.TenantID, event.Event{
+		ID:   uuid.New().String(),
+		Type: event.EventWebhookPing,
+		Data: map[string]interface{}{"webhook_id": webhook.ID, "events": webhook.Events},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.createDelivery(ctx, webhook, string(event.EventWebhookPing), payload)
+}
+
+// RedeliverDelivery delivers the payload of a previous delivery again, as a new delivery
+func (s *webhookService) RedeliverDelivery(
+	ctx context.Context, id string, deliveryID string,
+) (*types.WebhookDelivery, error) {
+	webhook, err := s.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.repo.GetDelivery(ctx, webhook.TenantID, deliveryID)
+	if err != nil || previous.WebhookID != webhook.ID {
+		if err == nil || errors.Is(err, repository.ErrWebhookDeliveryNotFound) {
+			return nil, werrors.NewNotFoundError("投递记录不存在")
+		}
+		return nil, err
+	}
+	return s.createDelivery(ctx, webhook, previous.Event, previous.Payload)
+}
+
+// handleEvent creates a delivery of a lifecycle event for every enabled webhook of the tenant subscribed to it
+func (s *webhookService) handleEvent(ctx context.Context, evt event.Event) error {
+	tenantID, ok := ctx.Value(types.TenantIDContextKey).(uint64)
+	if !ok || tenantID == 0 {
+		return nil
+	}
+	// The event is recorded even if the operation which produced it has been cancelled
+	ctx = context.WithoutCancel(ctx)
+
+	webhooks, err := s.repo.List(ctx, tenantID)
+	if err != nil {
+		logger.Errorf(ctx, "Failed to list webhooks of tenant %d for %s event: %v", tenantID, evt.Type, err)
+		return nil
+	}
+	var payload types.JSON
+	for _, webhook := range webhooks {
+		if !webhook.Enabled || !slices.Contains(webhook.Events, string(evt.Type)) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = newWebhookPayload(ctx, tenantID, evt); err != nil {
+				logger.Errorf(ctx, "Failed to build webhook payload of %s event: %v", evt.Type, err)
+				return nil
+			}
+		}
+		if _, err := s.createDelivery(ctx, webhook, string(evt.Type), payload); err != nil {
+			logger.Errorf(ctx, "Failed to create delivery of %s event to webhook %s: %v", evt.Type, webhook.ID, err)
+		}
+	}
+	return nil
+}
+
+// createDelivery records a delivery and enqueues the task delivering it
+func (s *webhookService) createDelivery(
+	ctx context.Context, webhook *types.Webhook, eventType string, payload types.JSON,
+) (*types.WebhookDelivery, error) {
+	delivery := &types.WebhookDelivery{
+		ID:        uuid.New().String(),
+		TenantID:  webhook.TenantID,
+		WebhookID: webhook.ID,
+		Event:     eventType,
+		Payload:   payload,
+		Status:    types.WebhookDeliveryPending,
+	}
+	if err := s.repo.CreateDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+
+	taskPayload, err := json.Marshal(types.WebhookDeliveryPayload{TenantID: webhook.TenantID, DeliveryID: delivery.ID})
+	if err != nil {
+		return nil, err
+	}
+	task := asynq.NewTask(types.TypeWebhookDelivery, taskPayload,
+		asynq.Queue("default"), asynq.MaxRetry(webhookMaxRetry))
+	if _, err := s.task.Enqueue(task); err != nil {
+		delivery.Status = types.WebhookDeliveryFailed
+		delivery.Error = fmt.Sprintf("failed to enqueue delivery: %v", err)
+		if updateErr := s.repo.UpdateDelivery(ctx, delivery); updateErr != nil {
+			logger.Errorf(ctx, "Failed to update webhook delivery %s: %v", delivery.ID, updateErr)
+		}
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// ProcessWebhookDelivery handles Asynq webhook delivery tasks.
+// A failed attempt returns an error so that asynq retries it, the delivery fails after the last retry.
+func (s *webhookService) ProcessWebhookDelivery(ctx context.Context, t *asynq.Task) error {
+	var payload types.WebhookDeliveryPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		logger.Errorf(ctx, "failed to unmarshal webhook delivery task payload: %v", err)
+		return nil
+	}
+	ctx = logger.WithField(ctx, "webhook_delivery", payload.DeliveryID)
+	ctx = context.WithValue(ctx, types.TenantIDContextKey, payload.TenantID)
+
+	retryCount, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	return s.processDelivery(ctx, &payload, retryCount, maxRetry)
+}
+
+// processDelivery makes an attempt of a delivery. The delivery stays pending while it can be retried
+func (s *webhookService) processDelivery(
+	ctx context.Context, payload *types.WebhookDeliveryPayload, retryCount, maxRetry int,
+) error {
+	isLastRetry := retryCount >= maxRetry
+
+	delivery, err := s.repo.GetDelivery(ctx, payload.TenantID, payload.DeliveryID)
+	if err != nil {
+		if errors.Is(err, repository.ErrWebhookDeliveryNotFound) {
+			return nil
+		}
+		return err
+	}
+	if delivery.Status != types.WebhookDeliveryPending {
+		return nil
+	}
+	webhook, err := s.repo.GetByID(ctx, payload.TenantID, delivery.WebhookID)
+	if err != nil {
+		if errors.Is(err, repository.ErrWebhookNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	attemptErr := s.attemptDelivery(ctx, webhook, delivery)
+	switch {
+	case attemptErr == nil:
+		delivery.Status = types.WebhookDeliverySucceeded
+	case isLastRetry:
+		delivery.Status = types.WebhookDeliveryFailed
+	}
+	if err := s.repo.UpdateDelivery(ctx, delivery); err != nil {
+		logger.Errorf(ctx, "Failed to update webhook delivery %s: %v", delivery.ID, err)
+	}
+	if attemptErr != nil {
+		logger.Warnf(ctx, "Webhook delivery %s of %s to %s failed, attempt %d/%d: %v",
+			delivery.ID, delivery.Event, webhook.ID, retryCount+1, maxRetry+1, attemptErr)
+		return attemptErr
+	}
+	logger.Infof(ctx, "Webhook delivery %s of %s to %s succeeded", delivery.ID, delivery.Event, webhook.ID)
+	return nil
+}
+
+// attemptDelivery posts the payload of a delivery to a webhook and records the outcome in the delivery
+func (s *webhookService) attemptDelivery(
+	ctx context.Context, webhook *types.Webhook, delivery *types.WebhookDelivery,
+) error {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	delivery.Attempts++
+	delivery.ResponseStatus = 0
+	delivery.ResponseBody = ""
+	delivery.Error = ""
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "WeKnora-Webhook/1.0")
+	req.Header.Set(webhookEventHeader, delivery.Event)
+	req.Header.Set(webhookDeliveryHeader, delivery.ID)
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	req.Header.Set(webhookSignatureHeader, "sha256="+signWebhookPayload(webhook.Secret, timestamp, body))
+
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	delivery.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		delivery.Error = err.Error()
+		return err
+	}
+	defer resp.Body.Close()
+
+	responseBody, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseBodyLimit))
+	delivery.ResponseStatus = resp.StatusCode
+	delivery.ResponseBody = string(responseBody)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		delivery.Error = fmt.Sprintf("unexpected response status %d", resp.StatusCode)
+		return errors.New(delivery.Error)
+	}
+	now := time.Now()
+	delivery.DeliveredAt = &now
+	return nil
+}
+
+// newWebhookPayload builds the body posted to webhooks for an event
+func newWebhookPayload(ctx context.Context, tenantID uint64, evt event.Event) (types.JSON, error) {
+	requestID := evt.RequestID
+	if requestID == "" {
+		requestID, _ = ctx.Value(types.RequestIDContextKey).(string)
+	}
+	data, err := json.Marshal(types.WebhookPayload{
+		ID:        evt.ID,
+		Event:     string(evt.Type),
+		TenantID:  tenantID,
+		RequestID: requestID,
+		CreatedAt: time.Now(),
+		Data:      evt.Data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return types.JSON(data), nil
+}
+
+// signWebhookPayload returns the hex encoded HMAC-SHA256 of "{timestamp}.{body}" with the secret of a webhook
+func signWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// generateWebhookSecret generates a random signing secret
+func generateWebhookSecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return types.WebhookSecretPrefix + hex.EncodeToString(buf), nil
+}
+
+// validateWebhookURL checks that a webhook URL is an absolute http or https URL of a host which only resolves
+// to public addresses
+func validateWebhookURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return werrors.NewValidationError("Webhook 地址必须是 http 或 https URL").WithDetails(rawURL)
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return werrors.NewValidationError("无法解析 Webhook 地址").WithDetails(u.Hostname())
+	}
+	for _, addr := range addrs {
+		if !isPublicWebhookIP(addr.IP) {
+			return werrors.NewValidationError("Webhook 地址不能指向内网地址").WithDetails(u.Hostname())
+		}
+	}
+	return nil
+}
+
+// isPublicWebhookIP reports whether webhooks may be delivered to an IP address,
+// rejecting loopback, private, link-local and unspecified addresses
+func isPublicWebhookIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast()
+}
+
+// normalizeWebhookEvents checks that the events can be subscribed to and removes duplicates
+func normalizeWebhookEvents(events []string) (types.StringArray, error) {
+	if len(events) == 0 {
+		return nil, werrors.NewValidationError("至少需要订阅一个事件")
+	}
+	result := make(types.StringArray, 0, len(events))
+	for _, name := range events {
+		if !slices.Contains(event.LifecycleEvents, event.EventType(name)) {
+			return nil, werrors.NewValidationError("不支持的 Webhook 事件").WithDetails(name)
+		}
+		if !slices.Contains(result, name) {
+			result = append(result, name)
+		}
+	}
+	return result, nil
+}
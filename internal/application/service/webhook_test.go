@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/application/repository"
+	werrors "github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWebhookRepository keeps a webhook and its deliveries in memory
+type fakeWebhookRepository struct {
+	mu         sync.Mutex
+	webhook    *types.Webhook
+	deliveries map[string]*types.WebhookDelivery
+}
+
+func (r *fakeWebhookRepository) Create(ctx context.Context, webhook *types.Webhook) error { return nil }
+
+func (r *fakeWebhookRepository) GetByID(ctx context.Context, tenantID uint64, id string) (*types.Webhook, error) {
+	if r.webhook == nil || r.webhook.ID != id {
+		return nil, repository.ErrWebhookNotFound
+	}
+	return r.webhook, nil
+}
+
+func (r *fakeWebhookRepository) List(ctx context.Context, tenantID uint64) ([]*types.Webhook, error) {
+	return nil, nil
+}
+
+func (r *fakeWebhookRepository) Update(ctx context.Context, webhook *types.Webhook) error { return nil }
+
+func (r *fakeWebhookRepository) Delete(ctx context.Context, tenantID uint64, id string) error { return nil }
+
+func (r *fakeWebhookRepository) CreateDelivery(ctx context.Context, delivery *types.WebhookDelivery) error {
+	return nil
+}
+
+func (r *fakeWebhookRepository) GetDelivery(
+	ctx context.Context, tenantID uint64, id string,
+) (*types.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delivery, ok := r.deliveries[id]
+	if !ok {
+		return nil, repository.ErrWebhookDeliveryNotFound
+	}
+	copied := *delivery
+	return &copied, nil
+}
+
+func (r *fakeWebhookRepository) UpdateDelivery(ctx context.Context, delivery *types.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *delivery
+	r.deliveries[delivery.ID] = &copied
+	return nil
+}
+
+func (r *fakeWebhookRepository) ListDeliveries(
+	ctx context.Context, tenantID uint64, webhookID string, page *types.Pagination,
+) ([]*types.WebhookDelivery, int64, error) {
+	return nil, 0, nil
+}
+
+// newTestWebhookService creates a webhook service delivering a pending delivery to a webhook at url.
+// The HTTP client of the test can reach the loopback address of the test server
+func newTestWebhookService(url string) (*webhookService, *fakeWebhookRepository) {
+	repo := &fakeWebhookRepository{
+		webhook: &types.Webhook{ID: "webhook1", TenantID: 1, URL: url, Secret: "whsec_test"},
+		deliveries: map[string]*types.WebhookDelivery{
+			"delivery1": {
+				ID:        "delivery1",
+				TenantID:  1,
+				WebhookID: "webhook1",
+				Event:     "knowledge.parsed",
+				Payload:   types.JSON(`{"id":"event1"}`),
+				Status:    types.WebhookDeliveryPending,
+			},
+		},
+	}
+	return &webhookService{repo: repo, client: &http.Client{}}, repo
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	body := []byte(`{"id":"event1"}`)
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte("1700000000." + string(body)))
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	assert.Equal(t, expected, signWebhookPayload("whsec_test", "1700000000", body))
+	// The timestamp and the secret are part of the signature
+	assert.NotEqual(t, expected, signWebhookPayload("whsec_test", "1700000001", body))
+	assert.NotEqual(t, expected, signWebhookPayload("whsec_other", "1700000000", body))
+}
+
+func TestProcessDeliverySignsRequest(t *testing.T) {
+	var header http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	s, repo := newTestWebhookService(server.URL)
+
+	require.NoError(t, s.processDelivery(context.Background(),
+		&types.WebhookDeliveryPayload{TenantID: 1, DeliveryID: "delivery1"}, 0, webhookMaxRetry))
+
+	assert.Equal(t, "knowledge.parsed", header.Get(webhookEventHeader))
+	assert.Equal(t, "delivery1", header.Get(webhookDeliveryHeader))
+	timestamp := header.Get(webhookTimestampHeader)
+	assert.Equal(t, "sha256="+signWebhookPayload("whsec_test", timestamp, body), header.Get(webhookSignatureHeader))
+
+	delivery := repo.deliveries["delivery1"]
+	assert.Equal(t, types.WebhookDeliverySucceeded, delivery.Status)
+	assert.Equal(t, http.StatusNoContent, delivery.ResponseStatus)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.NotNil(t, delivery.DeliveredAt)
+}
+
+func TestProcessDeliveryRetryStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		retryCount int
+		expected   types.WebhookDeliveryStatus
+	}{
+		{"first attempt", 0, types.WebhookDeliveryPending},
+		{"retry", webhookMaxRetry - 1, types.WebhookDeliveryPending},
+		{"last retry", webhookMaxRetry, types.WebhookDeliveryFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, repo := newTestWebhookService(server.URL)
+			err := s.processDelivery(context.Background(),
+				&types.WebhookDeliveryPayload{TenantID: 1, DeliveryID: "delivery1"}, tt.retryCount, webhookMaxRetry)
+			// The error makes asynq retry the task
+			require.Error(t, err)
+
+			delivery := repo.deliveries["delivery1"]
+			assert.Equal(t, tt.expected, delivery.Status)
+			assert.Equal(t, http.StatusInternalServerError, delivery.ResponseStatus)
+			assert.Equal(t, "unexpected response status 500", delivery.Error)
+			assert.Nil(t, delivery.DeliveredAt)
+		})
+	}
+}
+
+func TestProcessDeliverySkipsFinishedDelivery(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+	s, repo := newTestWebhookService(server.URL)
+	repo.deliveries["delivery1"].Status = types.WebhookDeliveryFailed
+
+	assert.NoError(t, s.processDelivery(context.Background(),
+		&types.WebhookDeliveryPayload{TenantID: 1, DeliveryID: "delivery1"}, 0, webhookMaxRetry))
+	// A deleted delivery is not retried either
+	assert.NoError(t, s.processDelivery(context.Background(),
+		&types.WebhookDeliveryPayload{TenantID: 1, DeliveryID: "missing"}, 0, webhookMaxRetry))
+	assert.Equal(t, 0, requests)
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{"https://8.8.8.8/hooks/weknora", true},
+		{"http://[2001:4860:4860::8888]:8080/hook", true},
+		{"ftp://8.8.8.8/hook", false},
+		{"/hooks/weknora", false},
+		{"http://127.0.0.1:8080/hook", false},
+		{"http://localhost/hook", false},
+		{"http://[::1]/hook", false},
+		{"http://0.0.0.0/hook", false},
+		{"http://10.0.0.1/hook", false},
+		{"http://172.16.5.4/hook", false},
+		{"http://192.168.1.1/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://[fe80::1]/hook", false},
+		{"http://[::ffff:127.0.0.1]/hook", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := validateWebhookURL(context.Background(), tt.url)
+			if tt.valid {
+				assert.NoError(t, err)
+				return
+			}
+			var appErr *werrors.AppError
+			require.True(t, errors.As(err, &appErr), "unexpected error %v", err)
+			assert.Equal(t, werrors.ErrValidation, appErr.Code)
+		})
+	}
+}
+
+func TestWebhookTransportRejectsInternalAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newWebhookTransport()}
+	_, err := client.Get(server.URL)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, errWebhookAddressNotAllowed)
+}
+
+func TestIsPublicWebhookIP(t *testing.T) {
+	assert.True(t, isPublicWebhookIP(net.ParseIP("93.184.216.34")))
+	assert.False(t, isPublicWebhookIP(net.ParseIP("127.0.0.2")))
+	assert.False(t, isPublicWebhookIP(net.ParseIP("fd00::1")))
+	assert.False(t, isPublicWebhookIP(net.ParseIP("ff02::1")))
+}
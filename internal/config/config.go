@@ -59,6 +59,8 @@ type ConversationConfig struct {
 	ExtractRelationshipsPrompt string         `yaml:"extract_relationships_prompt"  json:"extract_relationships_prompt"`
 	// GenerateQuestionsPrompt is used to generate questions for document chunks to improve recall
 	GenerateQuestionsPrompt string `yaml:"generate_questions_prompt" json:"generate_questions_prompt"`
+	// ImageCaptionPrompt is used to describe the images attached to questions with the VLM model
+	ImageCaptionPrompt string `yaml:"image_caption_prompt" json:"image_caption_prompt"`
 }
 
 // SummaryConfig 摘要配置
@@ -27,6 +27,7 @@ import (
 	"github.com/Tencent/WeKnora/internal/application/repository"
 	elasticsearchRepoV7 "github.com/Tencent/WeKnora/internal/application/repository/retriever/elasticsearch/v7"
 	elasticsearchRepoV8 "github.com/Tencent/WeKnora/internal/application/repository/retriever/elasticsearch/v8"
+	localRepo "github.com/Tencent/WeKnora/internal/application/repository/retriever/local"
 	neo4jRepo "github.com/Tencent/WeKnora/internal/application/repository/retriever/neo4j"
 	postgresRepo "github.com/Tencent/WeKnora/internal/application/repository/retriever/postgres"
 	qdrantRepo "github.com/Tencent/WeKnora/internal/application/repository/retriever/qdrant"
@@ -42,6 +43,7 @@ import (
 	"github.com/Tencent/WeKnora/internal/handler/session"
 	"github.com/Tencent/WeKnora/internal/logger"
 	"github.com/Tencent/WeKnora/internal/mcp"
+	"github.com/Tencent/WeKnora/internal/mcpserver"
 	"github.com/Tencent/WeKnora/internal/models/embedding"
 	"github.com/Tencent/WeKnora/internal/models/utils/ollama"
 	"github.com/Tencent/WeKnora/internal/router"
@@ -54,12 +56,32 @@ import (
 // BuildContainer constructs the dependency injection container
 // Registers all components, services, repositories and handlers needed by the application
 // Creates a fully configured application container with proper dependency resolution
+// and starts the asynchronous task workers
 // Parameters:
 //   - container: Base dig container to add dependencies to
 //
 // Returns:
 //   - Configured container with all application dependencies registered
 func BuildContainer(container *dig.Container) *dig.Container {
+	BuildServiceContainer(container)
+
+	// Router configuration
+	must(container.Provide(router.NewRouter))
+	must(container.Invoke(router.RunAsynqServer))
+	must(container.Invoke(router.RunPeriodicTaskManager))
+
+	return container
+}
+
+// BuildServiceContainer registers the components, services, repositories and handlers of the application
+// without starting the asynchronous task workers, for processes that run next to the server, such as the
+// MCP stdio server, and leave the tasks they enqueue to the workers of the server
+// Parameters:
+//   - container: Base dig container to add dependencies to
+//
+// Returns:
+//   - Configured container with the application services registered
+func BuildServiceContainer(container *dig.Container) *dig.Container {
 	// Register resource cleaner for proper cleanup of resources
 	must(container.Provide(NewResourceCleaner, dig.As(new(interfaces.ResourceCleaner))))
 
@@ -97,6 +119,15 @@ func BuildContainer(container *dig.Container) *dig.Container {
 	must(container.Provide(repository.NewAuthTokenRepository))
 	must(container.Provide(neo4jRepo.NewNeo4jRepository))
 	must(container.Provide(repository.NewMCPServiceRepository))
+	must(container.Provide(repository.NewEvaluationRepository))
+	must(container.Provide(repository.NewPermissionRepository))
+	must(container.Provide(repository.NewAPIKeyRepository))
+	must(container.Provide(repository.NewUsageRepository))
+	must(container.Provide(repository.NewAuditRepository))
+	must(container.Provide(repository.NewWebhookRepository))
+	must(container.Provide(repository.NewToolApprovalRepository))
+	must(container.Provide(repository.NewMemoryRepository))
+	must(container.Provide(repository.NewFeedbackRepository))
 
 	// MCP manager for managing MCP client connections
 	must(container.Provide(mcp.NewMCPManager))
@@ -104,17 +135,27 @@ func BuildContainer(container *dig.Container) *dig.Container {
 	// Business service layer
 	must(container.Provide(service.NewTenantService))
 	must(container.Provide(service.NewKnowledgeBaseService))
+	must(container.Provide(service.NewAnswerCacheService))
 	must(container.Provide(service.NewKnowledgeService))
+	must(container.Provide(service.NewURLRefreshTaskConfigProvider))
 	must(container.Provide(service.NewChunkService))
 	must(container.Provide(service.NewKnowledgeTagService))
 	must(container.Provide(embedding.NewBatchEmbedder))
+	must(container.Provide(service.NewUsageService))
 	must(container.Provide(service.NewModelService))
 	must(container.Provide(service.NewDatasetService))
 	must(container.Provide(service.NewEvaluationService))
 	must(container.Provide(service.NewUserService))
+	must(container.Provide(service.NewPermissionService))
+	must(container.Provide(service.NewAPIKeyService))
+	must(container.Provide(service.NewAuditService))
 	must(container.Provide(service.NewChunkExtractService))
 	must(container.Provide(service.NewMessageService))
 	must(container.Provide(service.NewMCPServiceService))
+	must(container.Provide(service.NewToolApprovalService))
+	must(container.Provide(service.NewMemoryService))
+	must(container.Provide(service.NewFeedbackService))
+	must(container.Provide(service.NewAttachmentService))
 
 	// Web search service (needed by AgentService)
 	must(container.Provide(service.NewWebSearchService))
@@ -131,6 +172,9 @@ func BuildContainer(container *dig.Container) *dig.Container {
 	must(container.Provide(router.NewAsyncqClient))
 	must(container.Provide(router.NewAsynqServer))
 
+	// Webhook service, subscribed to the lifecycle events of the global event bus
+	must(container.Provide(service.NewWebhookService))
+
 	// Chat pipeline components for processing chat requests
 	must(container.Provide(chatpipline.NewEventManager))
 	must(container.Invoke(chatpipline.NewPluginTracing))
@@ -141,11 +185,13 @@ func BuildContainer(container *dig.Container) *dig.Container {
 	must(container.Invoke(chatpipline.NewPluginChatCompletion))
 	must(container.Invoke(chatpipline.NewPluginChatCompletionStream))
 	must(container.Invoke(chatpipline.NewPluginStreamFilter))
+	must(container.Invoke(chatpipline.NewPluginVerifyCitations))
 	must(container.Invoke(chatpipline.NewPluginFilterTopK))
 	must(container.Invoke(chatpipline.NewPluginRewrite))
 	must(container.Invoke(chatpipline.NewPluginExtractEntity))
 	must(container.Invoke(chatpipline.NewPluginSearchEntity))
 	must(container.Invoke(chatpipline.NewPluginSearchParallel))
+	must(container.Invoke(chatpipline.NewPluginAnswerCache))
 
 	// HTTP handlers layer
 	must(container.Provide(handler.NewTenantHandler))
@@ -163,10 +209,16 @@ func BuildContainer(container *dig.Container) *dig.Container {
 	must(container.Provide(handler.NewSystemHandler))
 	must(container.Provide(handler.NewMCPServiceHandler))
 	must(container.Provide(handler.NewWebSearchHandler))
+	must(container.Provide(handler.NewPermissionHandler))
+	must(container.Provide(handler.NewUsageHandler))
+	must(container.Provide(handler.NewAuditHandler))
+	must(container.Provide(handler.NewWebhookHandler))
+	must(container.Provide(handler.NewMemoryHandler))
+	must(container.Provide(handler.NewFeedbackHandler))
+	must(container.Provide(handler.NewAPIKeyHandler))
 
-	// Router configuration
-	must(container.Provide(router.NewRouter))
-	must(container.Invoke(router.RunAsynqServer))
+	// MCP server exposing the knowledge bases to MCP clients
+	must(container.Provide(mcpserver.NewServer))
 
 	return container
 }
@@ -375,7 +427,7 @@ func initFileService(cfg *config.Config) (interfaces.FileService, error) {
 
 // initRetrieveEngineRegistry initializes the retrieval engine registry
 // Sets up and configures various search engine backends based on configuration
-// Supports multiple retrieval engines (PostgreSQL, ElasticsearchV7, ElasticsearchV8)
+// Supports multiple retrieval engines (PostgreSQL, ElasticsearchV7, ElasticsearchV8, Qdrant, Local)
 // Parameters:
 //   - db: Database connection
 //   - cfg: Application configuration
@@ -489,6 +541,23 @@ func initRetrieveEngineRegistry(db *gorm.DB, cfg *config.Config) (interfaces.Ret
 			}
 		}
 	}
+
+	if slices.Contains(retrieveDriver, "local") {
+		localDir := os.Getenv("LOCAL_RETRIEVER_DIR")
+		if localDir == "" {
+			localDir = "./data/index"
+		}
+		localRepository, err := localRepo.NewLocalRetrieveEngineRepository(localDir)
+		if err != nil {
+			log.Errorf("Create local retrieve engine failed: %v", err)
+		} else if err := registry.Register(
+			retriever.NewKVHybridRetrieveEngine(localRepository, types.LocalRetrieverEngineType),
+		); err != nil {
+			log.Errorf("Register local retrieve engine failed: %v", err)
+		} else {
+			log.Infof("Register local retrieve engine success")
+		}
+	}
 	return registry, nil
 }
 
@@ -36,6 +36,10 @@ const (
 	ErrAgentInvalidMaxIterations ErrorCode = 2102
 	ErrAgentInvalidTemperature   ErrorCode = 2103
 
+	// Usage related error codes (2200-2299)
+	ErrUsageRateLimited    ErrorCode = 2200
+	ErrUsageBudgetExceeded ErrorCode = 2201
+
 	// Add more error codes here
 )
 
@@ -124,6 +128,15 @@ func NewValidationError(message string) *AppError {
 	}
 }
 
+// NewTooManyRequestsError creates a too many requests error
+func NewTooManyRequestsError(message string) *AppError {
+	return &AppError{
+		Code:     ErrTooManyRequests,
+		Message:  message,
+		HTTPCode: http.StatusTooManyRequests,
+	}
+}
+
 // Tenant related errors
 func NewTenantNotFoundError() *AppError {
 	return &AppError{
@@ -184,6 +197,24 @@ func NewAgentInvalidTemperatureError() *AppError {
 	}
 }
 
+// Usage related errors
+func NewUsageRateLimitedError(limit int) *AppError {
+	return &AppError{
+		Code:     ErrUsageRateLimited,
+		Message:  fmt.Sprintf("模型调用过于频繁，每分钟最多调用%d次，请稍后重试", limit),
+		HTTPCode: http.StatusTooManyRequests,
+	}
+}
+
+// NewUsageBudgetExceededError creates a monthly token budget exceeded error
+func NewUsageBudgetExceededError(budget int64) *AppError {
+	return &AppError{
+		Code:     ErrUsageBudgetExceeded,
+		Message:  fmt.Sprintf("本月模型调用Token用量已达上限%d", budget),
+		HTTPCode: http.StatusTooManyRequests,
+	}
+}
+
 // IsAppError checks if the error is an AppError type
 func IsAppError(err error) (*AppError, bool) {
 	appErr, ok := err.(*AppError)
@@ -53,6 +53,9 @@ const (
 	EventAgentReflection  EventType = "reflection"   // Agent 反思
 	EventAgentReferences  EventType = "references"   // 知识引用
 	EventAgentFinalAnswer EventType = "final_answer" // 最终答案
+	EventAgentCitations   EventType = "citations"    // 答案引用
+
+	EventAgentToolApprovalRequired EventType = "tool_approval_required" // 工具调用等待人工审批
 
 	// Error events
 	EventError EventType = "error" // 错误事件
@@ -62,8 +65,27 @@ const (
 
 	// Control events
 	EventStop EventType = "stop" // 停止对话生成
+
+	// Lifecycle events, published on the global event bus and delivered to webhooks
+	EventKnowledgeParsed       EventType = "knowledge.parsed"        // 知识解析完成
+	EventKnowledgeFailed       EventType = "knowledge.failed"        // 知识解析失败
+	EventFAQImportCompleted    EventType = "faq_import.completed"    // FAQ导入结束
+	EventKBCloneCompleted      EventType = "kb_clone.completed"      // 知识库复制结束
+	EventMessageCompleted      EventType = "message.completed"       // 会话回答完成
+	EventMessageNegativeRating EventType = "message.negative_rating" // 回答收到负面反馈
+	EventWebhookPing           EventType = "webhook.ping"            // Webhook连通性测试
 )
 
+// LifecycleEvents are the events which webhooks can subscribe to
+var LifecycleEvents = []EventType{
+	EventKnowledgeParsed,
+	EventKnowledgeFailed,
+	EventFAQImportCompleted,
+	EventKBCloneCompleted,
+	EventMessageCompleted,
+	EventMessageNegativeRating,
+}
+
 // Event represents an event in the system
 type Event struct {
 	ID        string                 // 事件ID (自动生成UUID，用于流式更新追踪)
@@ -1,5 +1,11 @@
 package event
 
+import (
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
 // EventData contains common event data structures for different stages
 
 // QueryData represents query-related event data
@@ -153,6 +159,7 @@ type AgentThoughtData struct {
 	Content   string `json:"content"`
 	Iteration int    `json:"iteration"`
 	Done      bool   `json:"done"`
+	DelegationData
 }
 
 // AgentToolCallData represents agent tool call notification data
@@ -161,6 +168,7 @@ type AgentToolCallData struct {
 	ToolName   string         `json:"tool_name"`
 	Arguments  map[string]any `json:"arguments,omitempty"`
 	Iteration  int            `json:"iteration"`
+	DelegationData
 }
 
 // AgentToolResultData represents agent tool execution result data
@@ -173,6 +181,18 @@ type AgentToolResultData struct {
 	Duration   int64                  `json:"duration_ms,omitempty"`
 	Iteration  int                    `json:"iteration"`
 	Data       map[string]interface{} `json:"data,omitempty"` // Structured data from tool result (e.g., display_type, formatted results)
+	DelegationData
+}
+
+// AgentToolApprovalData represents a tool call which waits for a person to approve it
+type AgentToolApprovalData struct {
+	ApprovalID string         `json:"approval_id"`  // Approval to decide on
+	ToolCallID string         `json:"tool_call_id"` // Tool call ID for tracking
+	ToolName   string         `json:"tool_name"`
+	Arguments  map[string]any `json:"arguments,omitempty"`
+	ExpiresAt  time.Time      `json:"expires_at"` // The tool call is skipped without a decision after this time
+	Iteration  int            `json:"iteration"`
+	DelegationData
 }
 
 // AgentReferencesData represents knowledge references data
@@ -187,12 +207,25 @@ type AgentFinalAnswerData struct {
 	Done    bool   `json:"done"`
 }
 
+// AgentCitationsData represents the verified citations of an answer
+type AgentCitationsData struct {
+	Citations []types.Citation `json:"citations"`
+}
+
 // AgentReflectionData represents agent reflection data
 type AgentReflectionData struct {
 	ToolCallID string `json:"tool_call_id"` // Tool call ID for tracking
 	Content    string `json:"content"`
 	Iteration  int    `json:"iteration"`
 	Done       bool   `json:"done"` // Whether streaming is complete
+	DelegationData
+}
+
+// DelegationData marks the streaming events of a sub-agent, which are nested under
+// the delegate_to_agent tool call of the agent that delegated the task to it
+type DelegationData struct {
+	ParentToolCallID string `json:"parent_tool_call_id,omitempty"` // Tool call of the delegating agent
+	AgentName        string `json:"agent_name,omitempty"`          // Name of the sub-agent profile
 }
 
 // SessionTitleData represents session title update data
@@ -207,3 +240,57 @@ type StopData struct {
 	MessageID string `json:"message_id"`
 	Reason    string `json:"reason,omitempty"` // Optional reason for stopping
 }
+
+// === Lifecycle Event Data Structures ===
+// These are published on the global event bus when asynchronous work finishes, the tenant is in the context
+
+// KnowledgeParseData represents the result of parsing a knowledge
+type KnowledgeParseData struct {
+	KnowledgeID     string `json:"knowledge_id"`
+	KnowledgeBaseID string `json:"knowledge_base_id"`
+	Title           string `json:"title"`
+	FileName        string `json:"file_name,omitempty"`
+	Source          string `json:"source,omitempty"`
+	ParseStatus     string `json:"parse_status"`
+	ErrorMessage    string `json:"error_message,omitempty"`
+}
+
+// FAQImportData represents the result of an FAQ import task
+type FAQImportData struct {
+	TaskID          string `json:"task_id"`
+	KnowledgeBaseID string `json:"knowledge_base_id"`
+	KnowledgeID     string `json:"knowledge_id"`
+	Status          string `json:"status"` // completed or failed
+	Total           int    `json:"total"`
+	Processed       int    `json:"processed"`
+	Error           string `json:"error,omitempty"`
+}
+
+// KBCloneData represents the result of a knowledge base clone task
+type KBCloneData struct {
+	TaskID   string `json:"task_id"`
+	SourceID string `json:"source_id"`
+	TargetID string `json:"target_id"`
+	Status   string `json:"status"` // completed or failed
+	Total    int    `json:"total"`
+	Error    string `json:"error,omitempty"`
+}
+
+// MessageCompletedData represents a completed answer of a session
+type MessageCompletedData struct {
+	SessionID      string `json:"session_id"`
+	MessageID      string `json:"message_id"`
+	RequestID      string `json:"request_id,omitempty"`
+	Content        string `json:"content"`
+	ReferenceCount int    `json:"reference_count"`
+}
+
+// MessageRatingData represents the rating of an answer by a user
+type MessageRatingData struct {
+	SessionID string `json:"session_id"`
+	MessageID string `json:"message_id"`
+	UserID    string `json:"user_id,omitempty"`
+	Rating    string `json:"rating"`
+	Reason    string `json:"reason,omitempty"`
+	Comment   string `json:"comment,omitempty"`
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	secutils "github.com/Tencent/WeKnora/internal/utils"
+)
+
+// APIKeyHandler handles the scoped API keys of a tenant
+type APIKeyHandler struct {
+	service interfaces.APIKeyService
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(service interfaces.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{service: service}
+}
+
+// CreateAPIKey godoc
+// @Summary      创建 API Key
+// @Description  创建带权限范围的 API Key，可限定知识库和过期时间，明文 Key 仅在创建时返回一次，仅所有者可操作
+// @Tags         API Key管理
+// @Accept       json
+// @Produce      json
+// @Param        request  body      types.CreateAPIKeyRequest  true  "API Key 信息"
+// @Success      200      {object}  map[string]interface{}     "新建的 API Key"
+// @Failure      400      {object}  errors.AppError            "请求参数错误"
+// @Failure      403      {object}  errors.AppError            "无权限"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /api-keys [post]
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+	var req types.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error(ctx, "Failed to bind create API key payload", err)
+		c.Error(errors.NewBadRequestError("请求参数不合法").WithDetails(err.Error()))
+		return
+	}
+	req.Name = secutils.SanitizeForLog(req.Name)
+	req.KnowledgeBaseIDs = secutils.SanitizeForLogArray(req.KnowledgeBaseIDs)
+
+	key, err := h.service.CreateAPIKey(ctx, &req)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	auditResourceID(c, key.ID)
+	auditChange(c, nil, key.APIKey)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    key,
+	})
+}
+
+// ListAPIKeys godoc
+// @Summary      获取 API Key 列表
+// @Description  获取当前租户的 API Key，包含已撤销和已过期的 Key，不返回明文 Key，仅所有者可操作
+// @Tags         API Key管理
+// @Produce      json
+// @Success      200  {object}  map[string]interface{}  "API Key 列表"
+// @Failure      403  {object}  errors.AppError         "无权限"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /api-keys [get]
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+	keys, err := h.service.ListAPIKeys(ctx)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    keys,
+	})
+}
+
+// RevokeAPIKey godoc
+// @Summary      撤销 API Key
+// @Description  撤销 API Key，撤销后使用该 Key 的请求将被拒绝，仅所有者可操作
+// @Tags         API Key管理
+// @Produce      json
+// @Param        id   path      string  true  "API Key ID"
+// @Success      200  {object}  map[string]interface{}  "撤销成功"
+// @Failure      403  {object}  errors.AppError         "无权限"
+// @Failure      404  {object}  errors.AppError         "API Key 不存在"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /api-keys/{id} [delete]
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+	if err := h.service.RevokeAPIKey(ctx, secutils.SanitizeForLog(c.Param("id"))); err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+	})
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+)
+
+// AuditHandler queries and exports the audit log of a tenant
+type AuditHandler struct {
+	service interfaces.AuditService
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(service interfaces.AuditService) *AuditHandler {
+	return &AuditHandler{service: service}
+}
+
+// auditChange reports the state of the target resource before and after a change to the audit log.
+// before is nil for created resources and after is nil for deleted ones.
+func auditChange(c *gin.Context, before, after any) {
+	if before != nil {
+		c.Set(types.AuditBeforeContextKey.String(), before)
+	}
+	if after != nil {
+		c.Set(types.AuditAfterContextKey.String(), after)
+	}
+}
+
+// auditResourceID reports the ID of the target resource, for resources created by the request
+func auditResourceID(c *gin.Context, id string) {
+	c.Set(types.AuditResourceIDContextKey.String(), id)
+}
+
+// auditRead records a read operation in the audit log, such as an export of the data of a tenant
+func auditRead(c *gin.Context) {
+	c.Set(types.AuditReadContextKey.String(), true)
+}
+
+// bindAuditLogFilter binds the audit log filter of the query string
+func bindAuditLogFilter(c *gin.Context) (*types.AuditLogFilter, bool) {
+	var filter types.AuditLogFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		logger.Error(c.Request.Context(), "Failed to bind audit log filter", err)
+		c.Error(errors.NewBadRequestError("查询参数不合法，时间需为RFC 3339格式").WithDetails(err.Error()))
+		return nil, false
+	}
+	return &filter, true
+}
+
+// ListAuditLogs godoc
+// @Summary      获取审计日志
+// @Description  分页获取当前租户的审计日志，按时间倒序排列，仅所有者可操作
+// @Tags         审计日志
+// @Produce      json
+// @Param        page           query     int     false  "页码"
+// @Param        page_size      query     int     false  "每页条数，最大100"
+// @Param        actor_id       query     string  false  "操作者的用户ID或API Key ID"
+// @Param        action         query     string  false  "操作，如 DELETE /knowledge-bases/:id"
+// @Param        resource_type  query     string  false  "资源类型，如 knowledge-bases"
+// @Param        resource_id    query     string  false  "资源ID"
+// @Param        request_id     query     string  false  "请求ID"
+// @Param        start_time     query     string  false  "开始时间（RFC 3339，包含）"
+// @Param        end_time       query     string  false  "结束时间（RFC 3339，不包含）"
+// @Success      200            {object}  map[string]interface{}  "审计日志列表"
+// @Failure      400            {object}  errors.AppError         "请求参数错误"
+// @Failure      403            {object}  errors.AppError         "无权限"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /audit-logs [get]
+func (h *AuditHandler) ListAuditLogs(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+	filter, ok := bindAuditLogFilter(c)
+	if !ok {
+		return
+	}
+	var page types.Pagination
+	if err := c.ShouldBindQuery(&page); err != nil {
+		logger.Error(ctx, "Failed to bind pagination query", err)
+		c.Error(errors.NewBadRequestError("分页参数不合法").WithDetails(err.Error()))
+		return
+	}
+
+	result, err := h.service.ListAuditLogs(ctx, filter, &page)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// ExportAuditLogs godoc
+// @Summary      导出审计日志
+// @Description  以JSONL格式导出当前租户的审计日志，每行一条，按时间正序排列，仅所有者可操作
+// @Tags         审计日志
+// @Produce      application/x-ndjson
+// @Param        actor_id       query     string  false  "操作者的用户ID或API Key ID"
+// @Param        action         query     string  false  "操作"
+// @Param        resource_type  query     string  false  "资源类型"
+// @Param        resource_id    query     string  false  "资源ID"
+// @Param        request_id     query     string  false  "请求ID"
+// @Param        start_time     query     string  false  "开始时间（RFC 3339，包含）"
+// @Param        end_time       query     string  false  "结束时间（RFC 3339，不包含）"
+// @Success      200            {file}    file    "JSONL文件"
+// @Failure      400            {object}  errors.AppError  "请求参数错误"
+// @Failure      403            {object}  errors.AppError  "无权限"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /audit-logs/export [get]
+func (h *AuditHandler) ExportAuditLogs(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+	filter, ok := bindAuditLogFilter(c)
+	if !ok {
+		return
+	}
+	auditRead(c)
+
+	filename := fmt.Sprintf("audit_logs_%s.jsonl", time.Now().Format("20060102150405"))
+	c.Header("Content-Type", "application/x-ndjson; charset=utf-8")
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Status(http.StatusOK)
+	if err := h.service.ExportAuditLogs(ctx, filter, c.Writer); err != nil {
+		// The lines written so far have been sent, the export is cut short
+		logger.ErrorWithFields(ctx, err, nil)
+	}
+}
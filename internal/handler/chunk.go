@@ -14,12 +14,13 @@ import (
 
 // ChunkHandler defines HTTP handlers for chunk operations
 type ChunkHandler struct {
-	service interfaces.ChunkService
+	service          interfaces.ChunkService
+	knowledgeService interfaces.KnowledgeService
 }
 
 // NewChunkHandler creates a new chunk handler
-func NewChunkHandler(service interfaces.ChunkService) *ChunkHandler {
-	return &ChunkHandler{service: service}
+func NewChunkHandler(service interfaces.ChunkService, knowledgeService interfaces.KnowledgeService) *ChunkHandler {
+	return &ChunkHandler{service: service, knowledgeService: knowledgeService}
 }
 
 // GetChunkByIDOnly godoc
@@ -79,6 +80,9 @@ func (h *ChunkHandler) GetChunkByIDOnly(c *gin.Context) {
 		c.Error(errors.NewForbiddenError("No permission to access this chunk"))
 		return
 	}
+	if !requireKnowledgeBasePermission(c, chunk.KnowledgeBaseID, types.KBPermissionRead) {
+		return
+	}
 
 	// 对 chunk 内容进行安全清理
 	if chunk.Content != "" {
@@ -115,6 +119,9 @@ func (h *ChunkHandler) ListKnowledgeChunks(c *gin.Context) {
 		c.Error(errors.NewBadRequestError("Knowledge ID cannot be empty"))
 		return
 	}
+	if !requireKnowledgePermission(c, h.knowledgeService, knowledgeID, types.KBPermissionRead) {
+		return
+	}
 
 	// Parse pagination parameters
 	var pagination types.Pagination
@@ -172,7 +179,7 @@ type UpdateChunkRequest struct {
 
 // validateAndGetChunk validates request parameters and retrieves the chunk
 // Returns chunk information, knowledge ID, and error
-func (h *ChunkHandler) validateAndGetChunk(c *gin.Context) (*types.Chunk, string, error) {
+func (h *ChunkHandler) validateAndGetChunk(c *gin.Context, required types.KBPermission) (*types.Chunk, string, error) {
 	ctx := c.Request.Context()
 
 	// Validate knowledge ID
@@ -222,6 +229,13 @@ func (h *ChunkHandler) validateAndGetChunk(c *gin.Context) (*types.Chunk, string
 		return nil, knowledgeID, errors.NewForbiddenError("No permission to access this chunk")
 	}
 
+	// Validate the permission of the caller on the knowledge base
+	if principal := types.PrincipalFromContext(ctx); principal != nil &&
+		!principal.CanAccessKnowledgeBase(chunk.KnowledgeBaseID, required) {
+		logger.Warnf(ctx, "User %s has no %s permission on chunk %s", principal.UserID, required, id)
+		return nil, knowledgeID, errors.NewForbiddenError("No permission to access this chunk")
+	}
+
 	return chunk, knowledgeID, nil
 }
 
@@ -245,7 +259,7 @@ func (h *ChunkHandler) UpdateChunk(c *gin.Context) {
 	logger.Info(ctx, "Start updating knowledge chunk")
 
 	// Validate parameters and get chunk
-	chunk, knowledgeID, err := h.validateAndGetChunk(c)
+	chunk, knowledgeID, err := h.validateAndGetChunk(c, types.KBPermissionWrite)
 	if err != nil {
 		c.Error(err)
 		return
@@ -297,7 +311,7 @@ func (h *ChunkHandler) DeleteChunk(c *gin.Context) {
 	logger.Info(ctx, "Start deleting knowledge chunk")
 
 	// Validate parameters and get chunk
-	chunk, _, err := h.validateAndGetChunk(c)
+	chunk, _, err := h.validateAndGetChunk(c, types.KBPermissionWrite)
 	if err != nil {
 		c.Error(err)
 		return
@@ -337,6 +351,9 @@ func (h *ChunkHandler) DeleteChunksByKnowledgeID(c *gin.Context) {
 		c.Error(errors.NewBadRequestError("Knowledge ID cannot be empty"))
 		return
 	}
+	if !requireKnowledgePermission(c, h.knowledgeService, knowledgeID, types.KBPermissionWrite) {
+		return
+	}
 
 	// Delete all chunks under the knowledge
 	err := h.service.DeleteChunksByKnowledgeID(ctx, knowledgeID)
@@ -412,6 +429,9 @@ func (h *ChunkHandler) DeleteGeneratedQuestion(c *gin.Context) {
 		c.Error(errors.NewForbiddenError("No permission to access this chunk"))
 		return
 	}
+	if !requireKnowledgeBasePermission(c, chunk.KnowledgeBaseID, types.KBPermissionWrite) {
+		return
+	}
 
 	// Delete the generated question by ID
 	if err := h.service.DeleteGeneratedQuestion(ctx, chunkID, req.QuestionID); err != nil {
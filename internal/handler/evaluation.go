@@ -2,6 +2,9 @@ package handler
 
 import (
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/Tencent/WeKnora/internal/errors"
 	"github.com/Tencent/WeKnora/internal/logger"
@@ -11,14 +14,21 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// maxDatasetFileSize is the maximum size of an uploaded evaluation dataset
+const maxDatasetFileSize = 50 << 20
+
 // EvaluationHandler handles evaluation related HTTP requests
 type EvaluationHandler struct {
 	evaluationService interfaces.EvaluationService // Service for evaluation operations
+	datasetService    interfaces.DatasetService    // Service for evaluation datasets
 }
 
 // NewEvaluationHandler creates a new EvaluationHandler instance
-func NewEvaluationHandler(evaluationService interfaces.EvaluationService) *EvaluationHandler {
-	return &EvaluationHandler{evaluationService: evaluationService}
+func NewEvaluationHandler(
+	evaluationService interfaces.EvaluationService,
+	datasetService interfaces.DatasetService,
+) *EvaluationHandler {
+	return &EvaluationHandler{evaluationService: evaluationService, datasetService: datasetService}
 }
 
 // EvaluationRequest contains parameters for evaluation request
@@ -27,6 +37,7 @@ type EvaluationRequest struct {
 	KnowledgeBaseID string `json:"knowledge_base_id"` // ID of knowledge base to use
 	ChatModelID     string `json:"chat_id"`           // ID of chat model to use
 	RerankModelID   string `json:"rerank_id"`         // ID of rerank model to use
+	JudgeModelID    string `json:"judge_id"`          // ID of chat model grading the answers, optional
 }
 
 // Evaluation godoc
@@ -60,12 +71,14 @@ func (e *EvaluationHandler) Evaluation(c *gin.Context) {
 		return
 	}
 
-	logger.Infof(ctx, "Executing evaluation, tenant: %v, dataset: %s, knowledge_base: %s, chat: %s, rerank: %s",
+	logger.Infof(ctx,
+		"Executing evaluation, tenant: %v, dataset: %s, knowledge_base: %s, chat: %s, rerank: %s, judge: %s",
 		tenantID,
 		secutils.SanitizeForLog(request.DatasetID),
 		secutils.SanitizeForLog(request.KnowledgeBaseID),
 		secutils.SanitizeForLog(request.ChatModelID),
 		secutils.SanitizeForLog(request.RerankModelID),
+		secutils.SanitizeForLog(request.JudgeModelID),
 	)
 
 	task, err := e.evaluationService.Evaluation(ctx,
@@ -73,10 +86,11 @@ func (e *EvaluationHandler) Evaluation(c *gin.Context) {
 		secutils.SanitizeForLog(request.KnowledgeBaseID),
 		secutils.SanitizeForLog(request.ChatModelID),
 		secutils.SanitizeForLog(request.RerankModelID),
+		secutils.SanitizeForLog(request.JudgeModelID),
 	)
 	if err != nil {
 		logger.ErrorWithFields(ctx, err, nil)
-		c.Error(errors.NewInternalServerError(err.Error()))
+		handleEvaluationError(c, err)
 		return
 	}
 
@@ -119,7 +133,7 @@ func (e *EvaluationHandler) GetEvaluationResult(c *gin.Context) {
 	result, err := e.evaluationService.EvaluationResult(ctx, secutils.SanitizeForLog(request.TaskID))
 	if err != nil {
 		logger.ErrorWithFields(ctx, err, nil)
-		c.Error(errors.NewInternalServerError(err.Error()))
+		handleEvaluationError(c, err)
 		return
 	}
 
@@ -129,3 +143,381 @@ func (e *EvaluationHandler) GetEvaluationResult(c *gin.Context) {
 		"data":    result,
 	})
 }
+
+// ListEvaluations godoc
+// @Summary      获取评估任务列表
+// @Description  分页获取当前租户的评估任务及其指标，用于比较不同检索配置的历史结果
+// @Tags         评估
+// @Accept       json
+// @Produce      json
+// @Param        page       query     int  false  "页码"
+// @Param        page_size  query     int  false  "每页数量"
+// @Success      200        {object}  map[string]interface{}  "评估任务列表"
+// @Failure      400        {object}  errors.AppError         "请求参数错误"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /evaluation/tasks [get]
+func (e *EvaluationHandler) ListEvaluations(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var page types.Pagination
+	if err := c.ShouldBindQuery(&page); err != nil {
+		logger.Error(ctx, "Failed to bind pagination query", err)
+		c.Error(errors.NewBadRequestError("Invalid pagination parameters").WithDetails(err.Error()))
+		return
+	}
+
+	result, err := e.evaluationService.ListEvaluations(ctx, &page)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		handleEvaluationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// ResumeEvaluation godoc
+// @Summary      恢复评估任务
+// @Description  重新执行失败的评估任务，已完成的问题会被跳过
+// @Tags         评估
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "评估任务ID"
+// @Success      200  {object}  map[string]interface{}  "评估任务"
+// @Failure      400  {object}  errors.AppError         "请求参数错误"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /evaluation/tasks/{id}/resume [post]
+func (e *EvaluationHandler) ResumeEvaluation(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	taskID := secutils.SanitizeForLog(c.Param("id"))
+	detail, err := e.evaluationService.ResumeEvaluation(ctx, taskID)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		handleEvaluationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    detail,
+	})
+}
+
+// ListQuestionResults godoc
+// @Summary      获取评估逐题结果
+// @Description  分页获取评估任务每个问题的检索命中、重排结果、生成答案和指标
+// @Tags         评估
+// @Accept       json
+// @Produce      json
+// @Param        id         path      string  true   "评估任务ID"
+// @Param        page       query     int     false  "页码"
+// @Param        page_size  query     int     false  "每页数量"
+// @Success      200        {object}  map[string]interface{}  "逐题结果"
+// @Failure      400        {object}  errors.AppError         "请求参数错误"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /evaluation/tasks/{id}/questions [get]
+func (e *EvaluationHandler) ListQuestionResults(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var page types.Pagination
+	if err := c.ShouldBindQuery(&page); err != nil {
+		logger.Error(ctx, "Failed to bind pagination query", err)
+		c.Error(errors.NewBadRequestError("Invalid pagination parameters").WithDetails(err.Error()))
+		return
+	}
+
+	taskID := secutils.SanitizeForLog(c.Param("id"))
+	result, err := e.evaluationService.ListQuestionResults(ctx, taskID, &page)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		handleEvaluationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// GetQuestionResult godoc
+// @Summary      获取评估单题结果
+// @Description  获取评估任务中单个问题的完整结果
+// @Tags         评估
+// @Accept       json
+// @Produce      json
+// @Param        id     path      string  true  "评估任务ID"
+// @Param        index  path      int     true  "问题序号"
+// @Success      200    {object}  map[string]interface{}  "单题结果"
+// @Failure      404    {object}  errors.AppError         "结果不存在"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /evaluation/tasks/{id}/questions/{index} [get]
+func (e *EvaluationHandler) GetQuestionResult(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 {
+		c.Error(errors.NewBadRequestError("Invalid question index"))
+		return
+	}
+
+	taskID := secutils.SanitizeForLog(c.Param("id"))
+	result, err := e.evaluationService.GetQuestionResult(ctx, taskID, index)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		handleEvaluationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// CompareEvaluations godoc
+// @Summary      对比评估
+// @Description  使用同一数据集对多个管线配置（嵌入模型、分块、融合方式、重排模型等）分别运行评估，第一个配置作为基线
+// @Tags         评估
+// @Accept       json
+// @Produce      json
+// @Param        request  body      types.EvaluationComparisonRequest  true  "对比请求"
+// @Success      200      {object}  map[string]interface{}             "创建的对比"
+// @Failure      400      {object}  errors.AppError                    "请求参数错误"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /evaluation/comparisons [post]
+func (e *EvaluationHandler) CompareEvaluations(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var request types.EvaluationComparisonRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logger.Error(ctx, "Failed to parse comparison request", err)
+		c.Error(errors.NewBadRequestError("Invalid request parameters").WithDetails(err.Error()))
+		return
+	}
+
+	comparison, err := e.evaluationService.CompareEvaluations(ctx, &request)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		handleEvaluationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    comparison,
+	})
+}
+
+// ListComparisons godoc
+// @Summary      获取对比评估列表
+// @Description  分页获取当前租户的对比评估，按创建时间倒序
+// @Tags         评估
+// @Accept       json
+// @Produce      json
+// @Param        page       query     int  false  "页码"
+// @Param        page_size  query     int  false  "每页数量"
+// @Success      200        {object}  map[string]interface{}  "对比评估列表"
+// @Failure      400        {object}  errors.AppError         "请求参数错误"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /evaluation/comparisons [get]
+func (e *EvaluationHandler) ListComparisons(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var page types.Pagination
+	if err := c.ShouldBindQuery(&page); err != nil {
+		logger.Error(ctx, "Failed to bind pagination query", err)
+		c.Error(errors.NewBadRequestError("Invalid pagination parameters").WithDetails(err.Error()))
+		return
+	}
+
+	result, err := e.evaluationService.ListComparisons(ctx, &page)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		handleEvaluationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// GetComparisonReport godoc
+// @Summary      获取对比评估报告
+// @Description  返回各配置的指标、相对基线的差值、逐题胜负统计以及胜出案例
+// @Tags         评估
+// @Accept       json
+// @Produce      json
+// @Param        id      path      string  true   "对比ID"
+// @Param        metric  query     string  false  "判定逐题胜负的指标，默认 ndcg10"
+// @Param        limit   query     int     false  "返回的胜出案例数量，默认 20"
+// @Success      200     {object}  map[string]interface{}  "对比报告"
+// @Failure      400     {object}  errors.AppError         "请求参数错误"
+// @Failure      404     {object}  errors.AppError         "对比不存在"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /evaluation/comparisons/{id}/report [get]
+func (e *EvaluationHandler) GetComparisonReport(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	limit := 0
+	if limitStr := c.Query("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 0 {
+			c.Error(errors.NewBadRequestError("Invalid limit"))
+			return
+		}
+	}
+
+	comparisonID := secutils.SanitizeForLog(c.Param("id"))
+	report, err := e.evaluationService.GetComparisonReport(ctx, comparisonID, c.Query("metric"), limit)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		handleEvaluationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    report,
+	})
+}
+
+// UploadDataset godoc
+// @Summary      上传评估数据集
+// @Description  上传 JSONL 或 CSV 格式的评估数据集，每条包含问题、答案和相关段落
+// @Tags         评估
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        file         formData  file    true   "数据集文件（.jsonl 或 .csv）"
+// @Param        name         formData  string  false  "数据集名称"
+// @Param        description  formData  string  false  "数据集描述"
+// @Param        format       formData  string  false  "文件格式（jsonl/csv），默认根据扩展名判断"
+// @Success      200          {object}  map[string]interface{}  "创建的数据集"
+// @Failure      400          {object}  errors.AppError         "请求参数错误"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /evaluation/datasets [post]
+func (e *EvaluationHandler) UploadDataset(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		logger.Error(ctx, "File upload failed", err)
+		c.Error(errors.NewBadRequestError("File upload failed").WithDetails(err.Error()))
+		return
+	}
+	if fileHeader.Size > maxDatasetFileSize {
+		c.Error(errors.NewBadRequestError("Dataset file is too large"))
+		return
+	}
+
+	format := strings.ToLower(strings.TrimSpace(c.PostForm("format")))
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(fileHeader.Filename)), ".")
+	}
+	name := strings.TrimSpace(c.PostForm("name"))
+	if name == "" {
+		name = strings.TrimSuffix(fileHeader.Filename, filepath.Ext(fileHeader.Filename))
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		logger.Error(ctx, "Failed to open uploaded file", err)
+		c.Error(errors.NewBadRequestError("File upload failed").WithDetails(err.Error()))
+		return
+	}
+	defer file.Close()
+
+	logger.Infof(ctx, "Uploading evaluation dataset, name: %s, format: %s, size: %.2f KB",
+		secutils.SanitizeForLog(name), secutils.SanitizeForLog(format), float64(fileHeader.Size)/1024)
+
+	dataset, err := e.datasetService.CreateDataset(ctx, name, c.PostForm("description"), format, file)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		handleEvaluationError(c, err)
+		return
+	}
+	// The QA pairs can be large, the summary is enough for the response
+	dataset.QAPairs = nil
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    dataset,
+	})
+}
+
+// ListDatasets godoc
+// @Summary      获取评估数据集列表
+// @Description  获取当前租户上传的评估数据集
+// @Tags         评估
+// @Accept       json
+// @Produce      json
+// @Success      200  {object}  map[string]interface{}  "数据集列表"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /evaluation/datasets [get]
+func (e *EvaluationHandler) ListDatasets(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	datasets, err := e.datasetService.ListDatasets(ctx)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		handleEvaluationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    datasets,
+	})
+}
+
+// DeleteDataset godoc
+// @Summary      删除评估数据集
+// @Description  删除上传的评估数据集，已有评估结果不受影响
+// @Tags         评估
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "数据集ID"
+// @Success      200  {object}  map[string]interface{}  "删除成功"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /evaluation/datasets/{id} [delete]
+func (e *EvaluationHandler) DeleteDataset(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	datasetID := secutils.SanitizeForLog(c.Param("id"))
+	if err := e.datasetService.DeleteDataset(ctx, datasetID); err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		handleEvaluationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Dataset deleted successfully",
+	})
+}
+
+// handleEvaluationError reports application errors as is and anything else as an internal error
+func handleEvaluationError(c *gin.Context, err error) {
+	if appErr, ok := errors.IsAppError(err); ok {
+		c.Error(appErr)
+		return
+	}
+	c.Error(errors.NewInternalServerError(err.Error()))
+}
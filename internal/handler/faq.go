@@ -42,6 +42,9 @@ func NewFAQHandler(knowledgeService interfaces.KnowledgeService) *FAQHandler {
 // @Router       /knowledge-bases/{id}/faq/entries [get]
 func (h *FAQHandler) ListEntries(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireKnowledgeBasePermission(c, secutils.SanitizeForLog(c.Param("id")), types.KBPermissionRead) {
+		return
+	}
 	var page types.Pagination
 	if err := c.ShouldBindQuery(&page); err != nil {
 		logger.Error(ctx, "Failed to bind pagination query", err)
@@ -82,6 +85,9 @@ func (h *FAQHandler) ListEntries(c *gin.Context) {
 // @Router       /knowledge-bases/{id}/faq/entries [post]
 func (h *FAQHandler) UpsertEntries(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireKnowledgeBasePermission(c, secutils.SanitizeForLog(c.Param("id")), types.KBPermissionWrite) {
+		return
+	}
 	var req types.FAQBatchUpsertPayload
 	if err := c.ShouldBindJSON(&req); err != nil {
 		logger.Error(ctx, "Failed to bind FAQ upsert payload", err)
@@ -119,6 +125,9 @@ func (h *FAQHandler) UpsertEntries(c *gin.Context) {
 // @Router       /knowledge-bases/{id}/faq/entry [post]
 func (h *FAQHandler) CreateEntry(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireKnowledgeBasePermission(c, secutils.SanitizeForLog(c.Param("id")), types.KBPermissionWrite) {
+		return
+	}
 	var req types.FAQEntryPayload
 	if err := c.ShouldBindJSON(&req); err != nil {
 		logger.Error(ctx, "Failed to bind FAQ entry payload", err)
@@ -155,6 +164,9 @@ func (h *FAQHandler) CreateEntry(c *gin.Context) {
 // @Router       /knowledge-bases/{id}/faq/entries/{entry_id} [put]
 func (h *FAQHandler) UpdateEntry(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireKnowledgeBasePermission(c, secutils.SanitizeForLog(c.Param("id")), types.KBPermissionWrite) {
+		return
+	}
 	var req types.FAQEntryPayload
 	if err := c.ShouldBindJSON(&req); err != nil {
 		logger.Error(ctx, "Failed to bind FAQ entry payload", err)
@@ -189,6 +201,9 @@ func (h *FAQHandler) UpdateEntry(c *gin.Context) {
 // @Router       /knowledge-bases/{id}/faq/entries/tags [put]
 func (h *FAQHandler) UpdateEntryTagBatch(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireKnowledgeBasePermission(c, secutils.SanitizeForLog(c.Param("id")), types.KBPermissionWrite) {
+		return
+	}
 	var req faqEntryTagBatchRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		logger.Error(ctx, "Failed to bind FAQ entry tag batch payload", err)
@@ -221,6 +236,9 @@ func (h *FAQHandler) UpdateEntryTagBatch(c *gin.Context) {
 // @Router       /knowledge-bases/{id}/faq/entries/fields [put]
 func (h *FAQHandler) UpdateEntryFieldsBatch(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireKnowledgeBasePermission(c, secutils.SanitizeForLog(c.Param("id")), types.KBPermissionWrite) {
+		return
+	}
 	var req types.FAQEntryFieldsBatchUpdate
 	if err := c.ShouldBindJSON(&req); err != nil {
 		logger.Error(ctx, "Failed to bind FAQ entry fields batch payload", err)
@@ -263,6 +281,9 @@ type faqEntryTagBatchRequest struct {
 // @Router       /knowledge-bases/{id}/faq/entries [delete]
 func (h *FAQHandler) DeleteEntries(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireKnowledgeBasePermission(c, secutils.SanitizeForLog(c.Param("id")), types.KBPermissionWrite) {
+		return
+	}
 	var req faqDeleteRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		logger.Errorf(ctx, "Failed to bind FAQ delete payload: %s", secutils.SanitizeForLog(err.Error()))
@@ -298,6 +319,9 @@ func (h *FAQHandler) DeleteEntries(c *gin.Context) {
 // @Router       /knowledge-bases/{id}/faq/search [post]
 func (h *FAQHandler) SearchFAQ(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireKnowledgeBasePermission(c, secutils.SanitizeForLog(c.Param("id")), types.KBPermissionRead) {
+		return
+	}
 	var req types.FAQSearchRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		logger.Error(ctx, "Failed to bind FAQ search payload", err)
@@ -338,6 +362,9 @@ func (h *FAQHandler) SearchFAQ(c *gin.Context) {
 // @Router       /knowledge-bases/{id}/faq/entries/export [get]
 func (h *FAQHandler) ExportEntries(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireKnowledgeBasePermission(c, secutils.SanitizeForLog(c.Param("id")), types.KBPermissionRead) {
+		return
+	}
 	kbID := secutils.SanitizeForLog(c.Param("id"))
 
 	csvData, err := h.knowledgeService.ExportFAQEntries(ctx, kbID)
@@ -347,6 +374,8 @@ func (h *FAQHandler) ExportEntries(c *gin.Context) {
 		return
 	}
 
+	auditRead(c)
+
 	// Set response headers for CSV download
 	c.Header("Content-Type", "text/csv; charset=utf-8")
 	c.Header("Content-Disposition", "attachment; filename=faq_export.csv")
@@ -371,6 +400,9 @@ func (h *FAQHandler) ExportEntries(c *gin.Context) {
 // @Router       /knowledge-bases/{id}/faq/entries/{entry_id} [get]
 func (h *FAQHandler) GetEntry(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireKnowledgeBasePermission(c, secutils.SanitizeForLog(c.Param("id")), types.KBPermissionRead) {
+		return
+	}
 	kbID := secutils.SanitizeForLog(c.Param("id"))
 	entryID := secutils.SanitizeForLog(c.Param("entry_id"))
 
@@ -0,0 +1,306 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	secutils "github.com/Tencent/WeKnora/internal/utils"
+)
+
+const (
+	// defaultFeedbackListLimit is the default number of queries or chunks listed by the analytics
+	defaultFeedbackListLimit = 20
+	// maxFeedbackListLimit is the maximum number of queries or chunks listed by the analytics
+	maxFeedbackListLimit = 100
+)
+
+// FeedbackHandler collects the feedback on answers and serves the retrieval-quality analytics
+type FeedbackHandler struct {
+	service interfaces.FeedbackService
+}
+
+// NewFeedbackHandler creates a new feedback handler
+func NewFeedbackHandler(service interfaces.FeedbackService) *FeedbackHandler {
+	return &FeedbackHandler{service: service}
+}
+
+// bindFeedbackFilter binds the feedback filter of the query string
+func bindFeedbackFilter(c *gin.Context) (*types.FeedbackFilter, bool) {
+	var filter types.FeedbackFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		logger.Error(c.Request.Context(), "Failed to bind feedback filter", err)
+		c.Error(errors.NewBadRequestError("Invalid query parameters, times must be in RFC 3339 format").
+			WithDetails(err.Error()))
+		return nil, false
+	}
+	return &filter, true
+}
+
+// feedbackListLimit parses the limit of the listed queries or chunks
+func feedbackListLimit(c *gin.Context) (int, bool) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultFeedbackListLimit)))
+	if err != nil || limit < 1 || limit > maxFeedbackListLimit {
+		c.Error(errors.NewBadRequestError(fmt.Sprintf("limit must be between 1 and %d", maxFeedbackListLimit)))
+		return 0, false
+	}
+	return limit, true
+}
+
+// RateMessage godoc
+// @Summary      评价回答
+// @Description  对助手回答点赞或点踩，可附带原因、评论，并标记不相关的引用；重复提交会覆盖当前用户之前的评价
+// @Tags         反馈
+// @Accept       json
+// @Produce      json
+// @Param        session_id  path      string                        true  "会话ID"
+// @Param        id          path      string                        true  "消息ID"
+// @Param        request     body      types.MessageFeedbackRequest  true  "评价内容"
+// @Success      200         {object}  map[string]interface{}        "评价结果"
+// @Failure      400         {object}  errors.AppError               "请求参数错误"
+// @Failure      404         {object}  errors.AppError               "会话或消息不存在"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /messages/{session_id}/{id}/feedback [put]
+func (h *FeedbackHandler) RateMessage(c *gin.Context) {
+	ctx := c.Request.Context()
+	var request types.MessageFeedbackRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logger.Error(ctx, "Failed to parse feedback request", err)
+		c.Error(errors.NewBadRequestError("Invalid request parameters").WithDetails(err.Error()))
+		return
+	}
+
+	feedback, err := h.service.RateMessage(ctx,
+		secutils.SanitizeForLog(c.Param("session_id")), secutils.SanitizeForLog(c.Param("id")), &request)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    feedback,
+	})
+}
+
+// GetMessageFeedback godoc
+// @Summary      获取回答评价
+// @Description  获取当前用户对助手回答的评价
+// @Tags         反馈
+// @Produce      json
+// @Param        session_id  path      string  true  "会话ID"
+// @Param        id          path      string  true  "消息ID"
+// @Success      200         {object}  map[string]interface{}  "评价"
+// @Failure      404         {object}  errors.AppError         "尚未评价"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /messages/{session_id}/{id}/feedback [get]
+func (h *FeedbackHandler) GetMessageFeedback(c *gin.Context) {
+	ctx := c.Request.Context()
+	feedback, err := h.service.GetMessageFeedback(ctx,
+		secutils.SanitizeForLog(c.Param("session_id")), secutils.SanitizeForLog(c.Param("id")))
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    feedback,
+	})
+}
+
+// DeleteMessageFeedback godoc
+// @Summary      撤销回答评价
+// @Description  撤销当前用户对助手回答的评价
+// @Tags         反馈
+// @Produce      json
+// @Param        session_id  path      string  true  "会话ID"
+// @Param        id          path      string  true  "消息ID"
+// @Success      200         {object}  map[string]interface{}  "撤销成功"
+// @Failure      404         {object}  errors.AppError         "尚未评价"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /messages/{session_id}/{id}/feedback [delete]
+func (h *FeedbackHandler) DeleteMessageFeedback(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := h.service.DeleteMessageFeedback(ctx,
+		secutils.SanitizeForLog(c.Param("session_id")), secutils.SanitizeForLog(c.Param("id"))); err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+	})
+}
+
+// GetFeedbackSummary godoc
+// @Summary      获取反馈统计
+// @Description  按知识库、知识、模型或天统计回答的点赞、点踩和不相关引用数，仅所有者可操作
+// @Tags         反馈
+// @Produce      json
+// @Param        group_by           query     string  false  "分组方式：knowledge_base（默认）、knowledge、model、day"
+// @Param        knowledge_base_id  query     string  false  "知识库ID"
+// @Param        knowledge_id       query     string  false  "知识ID"
+// @Param        model_id           query     string  false  "对话模型ID"
+// @Param        start_time         query     string  false  "开始时间（RFC 3339，包含）"
+// @Param        end_time           query     string  false  "结束时间（RFC 3339，不包含）"
+// @Success      200                {object}  map[string]interface{}  "反馈统计"
+// @Failure      400                {object}  errors.AppError         "请求参数错误"
+// @Failure      403                {object}  errors.AppError         "无权限"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /feedback/summary [get]
+func (h *FeedbackHandler) GetFeedbackSummary(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+	filter, ok := bindFeedbackFilter(c)
+	if !ok {
+		return
+	}
+
+	summary, err := h.service.GetFeedbackSummary(ctx, filter, secutils.SanitizeForLog(c.Query("group_by")))
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    summary,
+	})
+}
+
+// ListWorstQueries godoc
+// @Summary      获取表现最差的问题
+// @Description  列出回答被点踩最多的问题，大小写和首尾空格不同的问题合并统计，仅所有者可操作
+// @Tags         反馈
+// @Produce      json
+// @Param        limit              query     int     false  "返回数量，最大100"  default(20)
+// @Param        knowledge_base_id  query     string  false  "知识库ID"
+// @Param        knowledge_id       query     string  false  "知识ID"
+// @Param        model_id           query     string  false  "对话模型ID"
+// @Param        start_time         query     string  false  "开始时间（RFC 3339，包含）"
+// @Param        end_time           query     string  false  "结束时间（RFC 3339，不包含）"
+// @Success      200                {object}  map[string]interface{}  "问题列表"
+// @Failure      400                {object}  errors.AppError         "请求参数错误"
+// @Failure      403                {object}  errors.AppError         "无权限"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /feedback/worst-queries [get]
+func (h *FeedbackHandler) ListWorstQueries(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+	filter, ok := bindFeedbackFilter(c)
+	if !ok {
+		return
+	}
+	limit, ok := feedbackListLimit(c)
+	if !ok {
+		return
+	}
+
+	queries, err := h.service.ListWorstQueries(ctx, filter, limit)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    queries,
+	})
+}
+
+// ListDownvotedChunks godoc
+// @Summary      获取被点踩最多的分块
+// @Description  列出最常被点踩回答引用或被标记为不相关的分块，仅所有者可操作
+// @Tags         反馈
+// @Produce      json
+// @Param        limit              query     int     false  "返回数量，最大100"  default(20)
+// @Param        knowledge_base_id  query     string  false  "知识库ID"
+// @Param        knowledge_id       query     string  false  "知识ID"
+// @Param        model_id           query     string  false  "对话模型ID"
+// @Param        start_time         query     string  false  "开始时间（RFC 3339，包含）"
+// @Param        end_time           query     string  false  "结束时间（RFC 3339，不包含）"
+// @Success      200                {object}  map[string]interface{}  "分块列表"
+// @Failure      400                {object}  errors.AppError         "请求参数错误"
+// @Failure      403                {object}  errors.AppError         "无权限"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /feedback/downvoted-chunks [get]
+func (h *FeedbackHandler) ListDownvotedChunks(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+	filter, ok := bindFeedbackFilter(c)
+	if !ok {
+		return
+	}
+	limit, ok := feedbackListLimit(c)
+	if !ok {
+		return
+	}
+
+	chunks, err := h.service.ListDownvotedChunks(ctx, filter, limit)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    chunks,
+	})
+}
+
+// ExportNegativeFeedback godoc
+// @Summary      导出点踩问答
+// @Description  以评估数据集的JSONL格式导出被点踩的问答，每行一条，按时间正序排列，补充标准答案后可上传为评估数据集，仅所有者可操作
+// @Tags         反馈
+// @Produce      application/x-ndjson
+// @Param        knowledge_base_id  query     string  false  "知识库ID"
+// @Param        knowledge_id       query     string  false  "知识ID"
+// @Param        model_id           query     string  false  "对话模型ID"
+// @Param        start_time         query     string  false  "开始时间（RFC 3339，包含）"
+// @Param        end_time           query     string  false  "结束时间（RFC 3339，不包含）"
+// @Success      200                {file}    file    "JSONL文件"
+// @Failure      400                {object}  errors.AppError  "请求参数错误"
+// @Failure      403                {object}  errors.AppError  "无权限"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /feedback/export [get]
+func (h *FeedbackHandler) ExportNegativeFeedback(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+	filter, ok := bindFeedbackFilter(c)
+	if !ok {
+		return
+	}
+	auditRead(c)
+
+	filename := fmt.Sprintf("negative_feedback_%s.jsonl", time.Now().Format("20060102150405"))
+	c.Header("Content-Type", "application/x-ndjson; charset=utf-8")
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Status(http.StatusOK)
+	if err := h.service.ExportNegativeFeedback(ctx, filter, c.Writer); err != nil {
+		// The lines written so far have been sent, the export is cut short
+		logger.ErrorWithFields(ctx, err, nil)
+	}
+}
@@ -58,6 +58,7 @@ type InitializationHandler struct {
 	knowledgeService interfaces.KnowledgeService
 	ollamaService    *ollama.OllamaService
 	docReaderClient  *client.Client
+	usageService     interfaces.UsageService
 }
 
 // NewInitializationHandler 创建初始化处理器
@@ -70,6 +71,7 @@ func NewInitializationHandler(
 	knowledgeService interfaces.KnowledgeService,
 	ollamaService *ollama.OllamaService,
 	docReaderClient *client.Client,
+	usageService interfaces.UsageService,
 ) *InitializationHandler {
 	return &InitializationHandler{
 		config:           config,
@@ -80,6 +82,7 @@ func NewInitializationHandler(
 		knowledgeService: knowledgeService,
 		ollamaService:    ollamaService,
 		docReaderClient:  docReaderClient,
+		usageService:     usageService,
 	}
 }
 
@@ -222,6 +225,9 @@ type InitializationRequest struct {
 func (h *InitializationHandler) UpdateKBConfig(c *gin.Context) {
 	ctx := c.Request.Context()
 	kbIdStr := utils.SanitizeForLog(c.Param("kbId"))
+	if !requireKnowledgeBasePermission(c, kbIdStr, types.KBPermissionAdmin) {
+		return
+	}
 
 	var req KBModelConfigRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -403,6 +409,9 @@ func (h *InitializationHandler) UpdateKBConfig(c *gin.Context) {
 func (h *InitializationHandler) InitializeByKB(c *gin.Context) {
 	ctx := c.Request.Context()
 	kbIdStr := utils.SanitizeForLog(c.Param("kbId"))
+	if !requireKnowledgeBasePermission(c, kbIdStr, types.KBPermissionAdmin) {
+		return
+	}
 
 	req, err := h.bindInitializationRequest(ctx, c)
 	if err != nil {
@@ -1227,6 +1236,9 @@ func (h *InitializationHandler) updateTaskStatus(
 func (h *InitializationHandler) GetCurrentConfigByKB(c *gin.Context) {
 	ctx := c.Request.Context()
 	kbIdStr := utils.SanitizeForLog(c.Param("kbId"))
+	if !requireKnowledgeBasePermission(c, kbIdStr, types.KBPermissionRead) {
+		return
+	}
 
 	logger.Info(ctx, "Getting configuration for knowledge base")
 
@@ -1537,7 +1549,7 @@ func (h *InitializationHandler) TestEmbeddingModel(c *gin.Context) {
 
 	// 执行一次最小化 embedding 调用
 	sample := "hello"
-	vec, err := emb.Embed(ctx, sample)
+	vec, err := h.usageService.WrapEmbedder(emb).Embed(ctx, sample)
 	if err != nil {
 		logger.Error(ctx, "Failed to create embedder", err)
 		c.JSON(http.StatusOK, gin.H{
@@ -1590,7 +1602,7 @@ func (h *InitializationHandler) checkRemoteModelConnection(ctx context.Context,
 	}
 
 	// 使用聊天实例进行测试
-	_, err = chatInstance.Chat(ctx, testMessages, testOptions)
+	_, err = h.usageService.WrapChat(chatInstance).Chat(ctx, testMessages, testOptions)
 	if err != nil {
 		// 根据错误类型返回不同的错误信息
 		if strings.Contains(err.Error(), "401") || strings.Contains(err.Error(), "unauthorized") {
@@ -1635,7 +1647,7 @@ func (h *InitializationHandler) checkRerankModelConnection(ctx context.Context,
 	}
 
 	// 使用Reranker进行测试
-	results, err := reranker.Rerank(ctx, testQuery, testDocuments)
+	results, err := h.usageService.WrapReranker(reranker).Rerank(ctx, testQuery, testDocuments)
 	if err != nil {
 		return false, fmt.Sprintf("Rerank test failed: %v", err)
 	}
@@ -2073,6 +2085,7 @@ func (h *InitializationHandler) extractRelationsFromText(
 		logger.Error(ctx, "Failed to initialize model service", err)
 		return nil, err
 	}
+	chatModel = h.usageService.WrapChat(chatModel)
 
 	template := &types.PromptTemplateStructured{
 		Description: h.config.ExtractManager.ExtractGraph.Description,
@@ -2155,6 +2168,7 @@ func (h *InitializationHandler) fabriText(ctx context.Context, tags []string, ll
 		logger.Error(ctx, "Failed to initialize model service", err)
 		return "", err
 	}
+	chatModel = h.usageService.WrapChat(chatModel)
 
 	content := h.config.ExtractManager.FabriText.WithNoTag
 	if len(tags) > 0 {
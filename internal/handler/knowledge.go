@@ -29,9 +29,11 @@ func NewKnowledgeHandler(
 	return &KnowledgeHandler{kgService: kgService, kbService: kbService}
 }
 
-// validateKnowledgeBaseAccess validates access permissions to a knowledge base
+// validateKnowledgeBaseAccess validates that the caller has the required permission on a knowledge base
 // Returns the knowledge base, the knowledge base ID, and any errors encountered
-func (h *KnowledgeHandler) validateKnowledgeBaseAccess(c *gin.Context) (*types.KnowledgeBase, string, error) {
+func (h *KnowledgeHandler) validateKnowledgeBaseAccess(
+	c *gin.Context, required types.KBPermission,
+) (*types.KnowledgeBase, string, error) {
 	ctx := c.Request.Context()
 
 	// Get knowledge base ID from URL path parameter
@@ -60,6 +62,13 @@ func (h *KnowledgeHandler) validateKnowledgeBaseAccess(c *gin.Context) (*types.K
 		return nil, kbID, errors.NewForbiddenError("Permission denied to access this knowledge base")
 	}
 
+	// Verify the permission of the caller on the knowledge base
+	if principal := types.PrincipalFromContext(ctx); principal != nil &&
+		!principal.CanAccessKnowledgeBase(kbID, required) {
+		logger.Warnf(ctx, "User %s has no %s permission on knowledge base %s", principal.UserID, required, kbID)
+		return nil, kbID, errors.NewForbiddenError("Permission denied to access this knowledge base")
+	}
+
 	return kb, kbID, nil
 }
 
@@ -104,7 +113,7 @@ func (h *KnowledgeHandler) CreateKnowledgeFromFile(c *gin.Context) {
 	logger.Info(ctx, "Start creating knowledge from file")
 
 	// Validate access to the knowledge base
-	_, kbID, err := h.validateKnowledgeBaseAccess(c)
+	_, kbID, err := h.validateKnowledgeBaseAccess(c, types.KBPermissionWrite)
 	if err != nil {
 		c.Error(err)
 		return
@@ -190,7 +199,7 @@ func (h *KnowledgeHandler) CreateKnowledgeFromFile(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Param        id       path      string  true  "知识库ID"
-// @Param        request  body      object{url=string,enable_multimodel=bool,title=string}  true  "URL请求"
+// @Param        request  body      object{url=string,enable_multimodel=bool,title=string,crawl=types.URLCrawlConfig,refresh_schedule=string}  true  "URL请求"
 // @Success      201      {object}  map[string]interface{}  "创建的知识"
 // @Failure      400      {object}  errors.AppError         "请求参数错误"
 // @Failure      409      {object}  map[string]interface{}  "URL重复"
@@ -202,7 +211,7 @@ func (h *KnowledgeHandler) CreateKnowledgeFromURL(c *gin.Context) {
 	logger.Info(ctx, "Start creating knowledge from URL")
 
 	// Validate access to the knowledge base
-	_, kbID, err := h.validateKnowledgeBaseAccess(c)
+	_, kbID, err := h.validateKnowledgeBaseAccess(c, types.KBPermissionWrite)
 	if err != nil {
 		c.Error(err)
 		return
@@ -210,9 +219,11 @@ func (h *KnowledgeHandler) CreateKnowledgeFromURL(c *gin.Context) {
 
 	// Parse URL from request body
 	var req struct {
-		URL              string `json:"url" binding:"required"`
-		EnableMultimodel *bool  `json:"enable_multimodel"`
-		Title            string `json:"title"`
+		URL              string                `json:"url" binding:"required"`
+		EnableMultimodel *bool                 `json:"enable_multimodel"`
+		Title            string                `json:"title"`
+		Crawl            *types.URLCrawlConfig `json:"crawl"`
+		RefreshSchedule  string                `json:"refresh_schedule"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		logger.Error(ctx, "Failed to parse URL request", err)
@@ -229,12 +240,17 @@ func (h *KnowledgeHandler) CreateKnowledgeFromURL(c *gin.Context) {
 	)
 
 	// Create knowledge entry from the URL
-	knowledge, err := h.kgService.CreateKnowledgeFromURL(ctx, kbID, req.URL, req.EnableMultimodel, req.Title)
+	knowledge, err := h.kgService.CreateKnowledgeFromURL(ctx, kbID, req.URL, req.EnableMultimodel, req.Title,
+		&types.URLKnowledgeOptions{Crawl: req.Crawl, RefreshSchedule: req.RefreshSchedule})
 	// Check for duplicate knowledge error
 	if err != nil {
 		if h.handleDuplicateKnowledgeError(c, err, knowledge, "url") {
 			return
 		}
+		if appErr, ok := errors.IsAppError(err); ok {
+			c.Error(appErr)
+			return
+		}
 		logger.ErrorWithFields(ctx, err, nil)
 		c.Error(errors.NewInternalServerError(err.Error()))
 		return
@@ -269,7 +285,7 @@ func (h *KnowledgeHandler) CreateManualKnowledge(c *gin.Context) {
 	ctx := c.Request.Context()
 	logger.Info(ctx, "Start creating manual knowledge")
 
-	_, kbID, err := h.validateKnowledgeBaseAccess(c)
+	_, kbID, err := h.validateKnowledgeBaseAccess(c, types.KBPermissionWrite)
 	if err != nil {
 		c.Error(err)
 		return
@@ -336,6 +352,9 @@ func (h *KnowledgeHandler) GetKnowledge(c *gin.Context) {
 		c.Error(errors.NewInternalServerError(err.Error()))
 		return
 	}
+	if !requireKnowledgeBasePermission(c, knowledge.KnowledgeBaseID, types.KBPermissionRead) {
+		return
+	}
 
 	logger.Infof(
 		ctx,
@@ -378,6 +397,9 @@ func (h *KnowledgeHandler) ListKnowledge(c *gin.Context) {
 		c.Error(errors.NewBadRequestError("Knowledge base ID cannot be empty"))
 		return
 	}
+	if !requireKnowledgeBasePermission(c, kbID, types.KBPermissionRead) {
+		return
+	}
 
 	// Parse pagination parameters from query string
 	var pagination types.Pagination
@@ -449,15 +471,24 @@ func (h *KnowledgeHandler) DeleteKnowledge(c *gin.Context) {
 		c.Error(errors.NewBadRequestError("Knowledge ID cannot be empty"))
 		return
 	}
+	if !requireKnowledgePermission(c, h.kgService, id, types.KBPermissionWrite) {
+		return
+	}
 
 	logger.Infof(ctx, "Deleting knowledge, ID: %s", secutils.SanitizeForLog(id))
-	err := h.kgService.DeleteKnowledge(ctx, id)
+	before, err := h.kgService.GetKnowledgeByID(ctx, id)
+	if err != nil {
+		logger.Warnf(ctx, "Failed to get knowledge %s before deletion: %v", id, err)
+	}
+	err = h.kgService.DeleteKnowledge(ctx, id)
 	if err != nil {
 		logger.ErrorWithFields(ctx, err, nil)
 		c.Error(errors.NewInternalServerError(err.Error()))
 		return
 	}
 
+	auditChange(c, before, nil)
+
 	logger.Infof(ctx, "Knowledge deleted successfully, ID: %s", secutils.SanitizeForLog(id))
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -489,6 +520,9 @@ func (h *KnowledgeHandler) DownloadKnowledgeFile(c *gin.Context) {
 		c.Error(errors.NewBadRequestError("Knowledge ID cannot be empty"))
 		return
 	}
+	if !requireKnowledgePermission(c, h.kgService, id, types.KBPermissionRead) {
+		return
+	}
 
 	logger.Infof(ctx, "Retrieving knowledge file, ID: %s", secutils.SanitizeForLog(id))
 
@@ -508,6 +542,8 @@ func (h *KnowledgeHandler) DownloadKnowledgeFile(c *gin.Context) {
 		secutils.SanitizeForLog(filename),
 	)
 
+	auditRead(c)
+
 	// Set response headers for file download
 	c.Header("Content-Description", "File Transfer")
 	c.Header("Content-Transfer-Encoding", "binary")
@@ -578,6 +614,17 @@ func (h *KnowledgeHandler) GetKnowledgeBatch(c *gin.Context) {
 		return
 	}
 
+	// Only return the knowledge of knowledge bases the caller can read
+	if principal := types.PrincipalFromContext(ctx); principal != nil {
+		readable := make([]*types.Knowledge, 0, len(knowledges))
+		for _, knowledge := range knowledges {
+			if principal.CanAccessKnowledgeBase(knowledge.KnowledgeBaseID, types.KBPermissionRead) {
+				readable = append(readable, knowledge)
+			}
+		}
+		knowledges = readable
+	}
+
 	logger.Infof(
 		ctx,
 		"Batch knowledge retrieval successful, requested count: %d, returned count: %d",
@@ -613,6 +660,9 @@ func (h *KnowledgeHandler) UpdateKnowledge(c *gin.Context) {
 		c.Error(errors.NewBadRequestError("Knowledge ID cannot be empty"))
 		return
 	}
+	if !requireKnowledgePermission(c, h.kgService, id, types.KBPermissionWrite) {
+		return
+	}
 
 	var knowledge types.Knowledge
 	if err := c.ShouldBindJSON(&knowledge); err != nil {
@@ -657,6 +707,9 @@ func (h *KnowledgeHandler) UpdateManualKnowledge(c *gin.Context) {
 		c.Error(errors.NewBadRequestError("Knowledge ID cannot be empty"))
 		return
 	}
+	if !requireKnowledgePermission(c, h.kgService, id, types.KBPermissionWrite) {
+		return
+	}
 
 	var req types.ManualKnowledgePayload
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -685,6 +738,166 @@ func (h *KnowledgeHandler) UpdateManualKnowledge(c *gin.Context) {
 	})
 }
 
+// UpdateKnowledgeFile godoc
+// @Summary      更新知识文件
+// @Description  上传新版本文件替换原文件，仅对新增或变化的分块重新向量化，未变化的分块保留原有ID
+// @Tags         知识管理
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        id        path      string  true   "知识ID"
+// @Param        file      formData  file    true   "新版本文件"
+// @Param        metadata  formData  string  false  "元数据JSON"
+// @Success      200       {object}  map[string]interface{}  "更新后的知识"
+// @Failure      400       {object}  errors.AppError         "请求参数错误"
+// @Failure      409       {object}  errors.AppError         "知识正在处理中"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /knowledge/{id}/file [put]
+func (h *KnowledgeHandler) UpdateKnowledgeFile(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Info(ctx, "Start updating knowledge file")
+
+	id := secutils.SanitizeForLog(c.Param("id"))
+	if id == "" {
+		logger.Error(ctx, "Knowledge ID is empty")
+		c.Error(errors.NewBadRequestError("Knowledge ID cannot be empty"))
+		return
+	}
+	if !requireKnowledgePermission(c, h.kgService, id, types.KBPermissionWrite) {
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		logger.Error(ctx, "File upload failed", err)
+		c.Error(errors.NewBadRequestError("File upload failed").WithDetails(err.Error()))
+		return
+	}
+
+	var metadata map[string]string
+	if metadataStr := c.PostForm("metadata"); metadataStr != "" {
+		if err := json.Unmarshal([]byte(metadataStr), &metadata); err != nil {
+			logger.Error(ctx, "Failed to parse metadata", err)
+			c.Error(errors.NewBadRequestError("Invalid metadata format").WithDetails(err.Error()))
+			return
+		}
+	}
+
+	knowledge, err := h.kgService.UpdateKnowledgeFromFile(ctx, id, file, metadata)
+	if err != nil {
+		if appErr, ok := errors.IsAppError(err); ok {
+			c.Error(appErr)
+			return
+		}
+		logger.ErrorWithFields(ctx, err, map[string]interface{}{
+			"knowledge_id": id,
+		})
+		c.Error(errors.NewInternalServerError(err.Error()))
+		return
+	}
+
+	logger.Infof(ctx, "Knowledge file updated successfully, knowledge ID: %s", id)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    knowledge,
+	})
+}
+
+// UpdateURLRefreshSchedule godoc
+// @Summary      设置URL知识刷新计划
+// @Description  设置URL知识的定时刷新cron表达式，内容变化时重新索引，空字符串关闭定时刷新
+// @Tags         知识管理
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string                         true  "知识ID"
+// @Param        request  body      object{refresh_schedule=string} true  "刷新计划"
+// @Success      200      {object}  map[string]interface{}         "更新后的知识"
+// @Failure      400      {object}  errors.AppError                "请求参数错误"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /knowledge/{id}/refresh-schedule [put]
+func (h *KnowledgeHandler) UpdateURLRefreshSchedule(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := secutils.SanitizeForLog(c.Param("id"))
+	if id == "" {
+		logger.Error(ctx, "Knowledge ID is empty")
+		c.Error(errors.NewBadRequestError("Knowledge ID cannot be empty"))
+		return
+	}
+	if !requireKnowledgePermission(c, h.kgService, id, types.KBPermissionWrite) {
+		return
+	}
+
+	var req struct {
+		RefreshSchedule string `json:"refresh_schedule"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error(ctx, "Failed to parse refresh schedule request", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+
+	knowledge, err := h.kgService.UpdateURLRefreshSchedule(ctx, id, req.RefreshSchedule)
+	if err != nil {
+		if appErr, ok := errors.IsAppError(err); ok {
+			c.Error(appErr)
+			return
+		}
+		logger.ErrorWithFields(ctx, err, map[string]interface{}{
+			"knowledge_id": id,
+		})
+		c.Error(errors.NewInternalServerError(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    knowledge,
+	})
+}
+
+// RefreshURLKnowledge godoc
+// @Summary      立即刷新URL知识
+// @Description  重新抓取URL知识的页面，内容变化时增量重建索引；配置了爬取范围时同时重新爬取
+// @Tags         知识管理
+// @Produce      json
+// @Param        id   path      string  true  "知识ID"
+// @Success      202  {object}  map[string]interface{}  "刷新任务已提交"
+// @Failure      400  {object}  errors.AppError         "请求参数错误"
+// @Failure      409  {object}  errors.AppError         "知识正在处理中"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /knowledge/{id}/refresh [post]
+func (h *KnowledgeHandler) RefreshURLKnowledge(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := secutils.SanitizeForLog(c.Param("id"))
+	if id == "" {
+		logger.Error(ctx, "Knowledge ID is empty")
+		c.Error(errors.NewBadRequestError("Knowledge ID cannot be empty"))
+		return
+	}
+	if !requireKnowledgePermission(c, h.kgService, id, types.KBPermissionWrite) {
+		return
+	}
+
+	if err := h.kgService.RefreshURLKnowledge(ctx, id); err != nil {
+		if appErr, ok := errors.IsAppError(err); ok {
+			c.Error(appErr)
+			return
+		}
+		logger.ErrorWithFields(ctx, err, map[string]interface{}{
+			"knowledge_id": id,
+		})
+		c.Error(errors.NewInternalServerError(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"message": "Refresh task submitted",
+	})
+}
+
 type knowledgeTagBatchRequest struct {
 	Updates map[string]*string `json:"updates" binding:"required,min=1"`
 }
@@ -709,6 +922,11 @@ func (h *KnowledgeHandler) UpdateKnowledgeTagBatch(c *gin.Context) {
 		c.Error(errors.NewBadRequestError("请求参数不合法").WithDetails(err.Error()))
 		return
 	}
+	for knowledgeID := range req.Updates {
+		if !requireKnowledgePermission(c, h.kgService, knowledgeID, types.KBPermissionWrite) {
+			return
+		}
+	}
 	if err := h.kgService.UpdateKnowledgeTagBatch(ctx, req.Updates); err != nil {
 		logger.ErrorWithFields(ctx, err, nil)
 		c.Error(err)
@@ -744,6 +962,9 @@ func (h *KnowledgeHandler) UpdateImageInfo(c *gin.Context) {
 		c.Error(errors.NewBadRequestError("Knowledge ID cannot be empty"))
 		return
 	}
+	if !requireKnowledgePermission(c, h.kgService, id, types.KBPermissionWrite) {
+		return
+	}
 	chunkID := secutils.SanitizeForLog(c.Param("chunk_id"))
 	if chunkID == "" {
 		logger.Error(ctx, "Chunk ID is empty")
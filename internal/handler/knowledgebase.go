@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"time"
 
+	chatpipline "github.com/Tencent/WeKnora/internal/application/service/chat_pipline"
 	"github.com/Tencent/WeKnora/internal/errors"
 	"github.com/Tencent/WeKnora/internal/logger"
 	"github.com/Tencent/WeKnora/internal/types"
@@ -19,21 +20,27 @@ import (
 
 // KnowledgeBaseHandler defines the HTTP handler for knowledge base operations
 type KnowledgeBaseHandler struct {
-	service          interfaces.KnowledgeBaseService
-	knowledgeService interfaces.KnowledgeService
-	asynqClient      *asynq.Client
+	service           interfaces.KnowledgeBaseService
+	knowledgeService  interfaces.KnowledgeService
+	permissionService interfaces.PermissionService
+	asynqClient       *asynq.Client
+	eventManager      *chatpipline.EventManager
 }
 
 // NewKnowledgeBaseHandler creates a new knowledge base handler instance
 func NewKnowledgeBaseHandler(
 	service interfaces.KnowledgeBaseService,
 	knowledgeService interfaces.KnowledgeService,
+	permissionService interfaces.PermissionService,
 	asynqClient *asynq.Client,
+	eventManager *chatpipline.EventManager,
 ) *KnowledgeBaseHandler {
 	return &KnowledgeBaseHandler{
-		service:          service,
-		knowledgeService: knowledgeService,
-		asynqClient:      asynqClient,
+		service:           service,
+		knowledgeService:  knowledgeService,
+		permissionService: permissionService,
+		asynqClient:       asynqClient,
+		eventManager:      eventManager,
 	}
 }
 
@@ -62,6 +69,9 @@ func (h *KnowledgeBaseHandler) HybridSearch(c *gin.Context) {
 		c.Error(errors.NewBadRequestError("Knowledge base ID cannot be empty"))
 		return
 	}
+	if !requireKnowledgeBasePermission(c, id, types.KBPermissionRead) {
+		return
+	}
 
 	// Parse request body
 	var req types.SearchParams
@@ -70,6 +80,16 @@ func (h *KnowledgeBaseHandler) HybridSearch(c *gin.Context) {
 		c.Error(errors.NewBadRequestError("Invalid request parameters").WithDetails(err.Error()))
 		return
 	}
+	if err := validateRetrievalConfig(req.Fusion); err != nil {
+		c.Error(err)
+		return
+	}
+	if req.Filter != nil {
+		if err := req.Filter.Validate(); err != nil {
+			c.Error(errors.NewBadRequestError("Invalid filter").WithDetails(err.Error()))
+			return
+		}
+	}
 
 	logger.Infof(ctx, "Executing hybrid search, knowledge base ID: %s, query: %s",
 		secutils.SanitizeForLog(id), secutils.SanitizeForLog(req.QueryText))
@@ -107,6 +127,11 @@ func (h *KnowledgeBaseHandler) CreateKnowledgeBase(c *gin.Context) {
 
 	logger.Info(ctx, "Start creating knowledge base")
 
+	// Viewers cannot create knowledge bases
+	if !requireTenantRole(c, types.TenantRoleEditor) {
+		return
+	}
+
 	// Parse request body
 	var req types.KnowledgeBase
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -119,6 +144,24 @@ func (h *KnowledgeBaseHandler) CreateKnowledgeBase(c *gin.Context) {
 		c.Error(err)
 		return
 	}
+	if err := validateRetrievalConfig(req.RetrievalConfig); err != nil {
+		logger.Error(ctx, "Invalid retrieval configuration", err)
+		c.Error(err)
+		return
+	}
+	if err := validateAnswerCacheConfig(req.AnswerCacheConfig); err != nil {
+		logger.Error(ctx, "Invalid answer cache configuration", err)
+		c.Error(err)
+		return
+	}
+	if req.PipelineConfig != nil && len(req.PipelineConfig.Stages) == 0 {
+		req.PipelineConfig = nil
+	}
+	if err := h.validatePipelineConfig(req.PipelineConfig); err != nil {
+		logger.Error(ctx, "Invalid pipeline configuration", err)
+		c.Error(err)
+		return
+	}
 
 	logger.Infof(ctx, "Creating knowledge base, name: %s", secutils.SanitizeForLog(req.Name))
 	// Create knowledge base using the service
@@ -129,6 +172,14 @@ func (h *KnowledgeBaseHandler) CreateKnowledgeBase(c *gin.Context) {
 		return
 	}
 
+	// The creator administers the new knowledge base
+	if err := h.permissionService.GrantCreator(ctx, kb.ID); err != nil {
+		logger.Errorf(ctx, "Failed to grant the creator access to knowledge base %s: %v", kb.ID, err)
+	}
+
+	auditResourceID(c, kb.ID)
+	auditChange(c, nil, kb)
+
 	logger.Infof(ctx, "Knowledge base created successfully, ID: %s, name: %s",
 		secutils.SanitizeForLog(kb.ID), secutils.SanitizeForLog(kb.Name))
 	c.JSON(http.StatusCreated, gin.H{
@@ -138,8 +189,11 @@ func (h *KnowledgeBaseHandler) CreateKnowledgeBase(c *gin.Context) {
 }
 
 // validateAndGetKnowledgeBase validates request parameters and retrieves the knowledge base
+// the caller has the required permission on.
 // Returns the knowledge base, knowledge base ID, and any errors encountered
-func (h *KnowledgeBaseHandler) validateAndGetKnowledgeBase(c *gin.Context) (*types.KnowledgeBase, string, error) {
+func (h *KnowledgeBaseHandler) validateAndGetKnowledgeBase(
+	c *gin.Context, required types.KBPermission,
+) (*types.KnowledgeBase, string, error) {
 	ctx := c.Request.Context()
 
 	// Get tenant ID from context
@@ -174,6 +228,12 @@ func (h *KnowledgeBaseHandler) validateAndGetKnowledgeBase(c *gin.Context) (*typ
 		return nil, id, errors.NewForbiddenError("No permission to operate")
 	}
 
+	// Verify the permission of the caller on the knowledge base
+	if principal := types.PrincipalFromContext(ctx); principal != nil && !principal.CanAccessKnowledgeBase(id, required) {
+		logger.Warnf(ctx, "User %s has no %s permission on knowledge base %s", principal.UserID, required, id)
+		return nil, id, errors.NewForbiddenError("No permission to operate")
+	}
+
 	return kb, id, nil
 }
 
@@ -192,7 +252,7 @@ func (h *KnowledgeBaseHandler) validateAndGetKnowledgeBase(c *gin.Context) (*typ
 // @Router       /knowledge-bases/{id} [get]
 func (h *KnowledgeBaseHandler) GetKnowledgeBase(c *gin.Context) {
 	// Validate and get the knowledge base
-	kb, _, err := h.validateAndGetKnowledgeBase(c)
+	kb, _, err := h.validateAndGetKnowledgeBase(c, types.KBPermissionRead)
 	if err != nil {
 		c.Error(err)
 		return
@@ -225,6 +285,17 @@ func (h *KnowledgeBaseHandler) ListKnowledgeBases(c *gin.Context) {
 		return
 	}
 
+	// Only list the knowledge bases the caller can read
+	if principal := types.PrincipalFromContext(ctx); principal != nil {
+		readable := make([]*types.KnowledgeBase, 0, len(kbs))
+		for _, kb := range kbs {
+			if principal.CanAccessKnowledgeBase(kb.ID, types.KBPermissionRead) {
+				readable = append(readable, kb)
+			}
+		}
+		kbs = readable
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    kbs,
@@ -256,7 +327,7 @@ func (h *KnowledgeBaseHandler) UpdateKnowledgeBase(c *gin.Context) {
 	logger.Info(ctx, "Start updating knowledge base")
 
 	// Validate and get the knowledge base
-	_, id, err := h.validateAndGetKnowledgeBase(c)
+	before, id, err := h.validateAndGetKnowledgeBase(c, types.KBPermissionAdmin)
 	if err != nil {
 		c.Error(err)
 		return
@@ -270,6 +341,24 @@ func (h *KnowledgeBaseHandler) UpdateKnowledgeBase(c *gin.Context) {
 		return
 	}
 
+	if req.Config != nil {
+		if err := validateRetrievalConfig(req.Config.RetrievalConfig); err != nil {
+			logger.Error(ctx, "Invalid retrieval configuration", err)
+			c.Error(err)
+			return
+		}
+		if err := validateAnswerCacheConfig(req.Config.AnswerCacheConfig); err != nil {
+			logger.Error(ctx, "Invalid answer cache configuration", err)
+			c.Error(err)
+			return
+		}
+		if err := h.validatePipelineConfig(req.Config.PipelineConfig); err != nil {
+			logger.Error(ctx, "Invalid pipeline configuration", err)
+			c.Error(err)
+			return
+		}
+	}
+
 	logger.Infof(ctx, "Updating knowledge base, ID: %s, name: %s",
 		secutils.SanitizeForLog(id), secutils.SanitizeForLog(req.Name))
 
@@ -281,6 +370,8 @@ func (h *KnowledgeBaseHandler) UpdateKnowledgeBase(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, before, kb)
+
 	logger.Infof(ctx, "Knowledge base updated successfully, ID: %s",
 		secutils.SanitizeForLog(id))
 	c.JSON(http.StatusOK, gin.H{
@@ -306,7 +397,7 @@ func (h *KnowledgeBaseHandler) DeleteKnowledgeBase(c *gin.Context) {
 	logger.Info(ctx, "Start deleting knowledge base")
 
 	// Validate and get the knowledge base
-	kb, id, err := h.validateAndGetKnowledgeBase(c)
+	kb, id, err := h.validateAndGetKnowledgeBase(c, types.KBPermissionAdmin)
 	if err != nil {
 		c.Error(err)
 		return
@@ -321,6 +412,10 @@ func (h *KnowledgeBaseHandler) DeleteKnowledgeBase(c *gin.Context) {
 		c.Error(errors.NewInternalServerError(err.Error()))
 		return
 	}
+	if err := h.permissionService.DeleteKnowledgeBaseGrants(ctx, id); err != nil {
+		logger.Warnf(ctx, "Failed to delete the permissions of knowledge base %s: %v", id, err)
+	}
+	auditChange(c, kb, nil)
 
 	logger.Infof(ctx, "Knowledge base deleted successfully, ID: %s",
 		secutils.SanitizeForLog(id))
@@ -372,6 +467,18 @@ func (h *KnowledgeBaseHandler) CopyKnowledgeBase(c *gin.Context) {
 		return
 	}
 
+	// Copying requires reading the source and writing the target. The knowledge base created by
+	// a copy without target is only accessible to owners, so other roles have to name the target.
+	if !requireKnowledgeBasePermission(c, req.SourceID, types.KBPermissionRead) {
+		return
+	}
+	if req.TargetID == "" && !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+	if req.TargetID != "" && !requireKnowledgeBasePermission(c, req.TargetID, types.KBPermissionWrite) {
+		return
+	}
+
 	// Generate task ID
 	taskID := uuid.New().String()
 
@@ -464,6 +571,137 @@ func (h *KnowledgeBaseHandler) GetKBCloneProgress(c *gin.Context) {
 	})
 }
 
+// ReembedKnowledgeBaseRequest defines the request for re-embedding a knowledge base
+type ReembedKnowledgeBaseRequest struct {
+	EmbeddingModelID string `json:"embedding_model_id" binding:"required"`
+}
+
+// ReembedKnowledgeBase godoc
+// @Summary      重新向量化知识库
+// @Description  使用新的嵌入模型在线重建知识库索引（异步任务），完成前检索继续使用旧索引
+// @Tags         知识库
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string                       true  "知识库ID"
+// @Param        request  body      ReembedKnowledgeBaseRequest  true  "重新向量化请求"
+// @Success      200      {object}  map[string]interface{}       "任务ID"
+// @Failure      400      {object}  errors.AppError              "请求参数错误"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /knowledge-bases/{id}/reembed [post]
+func (h *KnowledgeBaseHandler) ReembedKnowledgeBase(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	_, id, err := h.validateAndGetKnowledgeBase(c, types.KBPermissionAdmin)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var req ReembedKnowledgeBaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error(ctx, "Failed to parse request parameters", err)
+		c.Error(errors.NewBadRequestError("Invalid request parameters").WithDetails(err.Error()))
+		return
+	}
+
+	taskID, err := h.knowledgeService.ReembedKnowledgeBase(ctx, id, req.EmbeddingModelID)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"task_id":            taskID,
+			"knowledge_base_id":  id,
+			"embedding_model_id": req.EmbeddingModelID,
+			"message":            "Knowledge base re-embedding task started",
+		},
+	})
+}
+
+// GetKBReembedProgress godoc
+// @Summary      获取知识库重新向量化进度
+// @Description  获取知识库重新向量化任务的进度
+// @Tags         知识库
+// @Accept       json
+// @Produce      json
+// @Param        task_id  path      string  true  "任务ID"
+// @Success      200      {object}  map[string]interface{}  "进度信息"
+// @Failure      404      {object}  errors.AppError         "任务不存在"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /knowledge-bases/reembed/progress/{task_id} [get]
+func (h *KnowledgeBaseHandler) GetKBReembedProgress(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	taskID := c.Param("task_id")
+	if taskID == "" {
+		logger.Error(ctx, "Task ID is empty")
+		c.Error(errors.NewBadRequestError("Task ID cannot be empty"))
+		return
+	}
+
+	progress, err := h.knowledgeService.GetKBReembedProgress(ctx, taskID)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    progress,
+	})
+}
+
+// validateRetrievalConfig validates the hybrid retrieval fusion parameters
+func validateRetrievalConfig(config *types.RetrievalConfig) error {
+	if config == nil {
+		return nil
+	}
+	switch config.FusionMode {
+	case "", types.FusionModeRRF, types.FusionModeWeighted, types.FusionModeMax:
+	default:
+		return errors.NewBadRequestError("unsupported fusion mode: " + string(config.FusionMode))
+	}
+	if config.RRFK < 0 {
+		return errors.NewBadRequestError("rrf_k cannot be negative")
+	}
+	if config.Alpha != nil && (*config.Alpha < 0 || *config.Alpha > 1) {
+		return errors.NewBadRequestError("alpha must be between 0 and 1")
+	}
+	return nil
+}
+
+// validateAnswerCacheConfig validates the semantic answer cache parameters
+func validateAnswerCacheConfig(config *types.AnswerCacheConfig) error {
+	if config == nil {
+		return nil
+	}
+	if config.SimilarityThreshold < 0 || config.SimilarityThreshold > 1 {
+		return errors.NewBadRequestError("similarity_threshold must be between 0 and 1")
+	}
+	if config.TTLSeconds < 0 {
+		return errors.NewBadRequestError("ttl_seconds cannot be negative")
+	}
+	return nil
+}
+
+// validatePipelineConfig validates the stages of a chat pipeline, an empty pipeline restores the default one
+func (h *KnowledgeBaseHandler) validatePipelineConfig(config *types.PipelineConfig) error {
+	if config == nil || len(config.Stages) == 0 {
+		return nil
+	}
+	if err := h.eventManager.ValidatePipeline(config); err != nil {
+		return errors.NewBadRequestError("Invalid pipeline_config").WithDetails(err.Error())
+	}
+	return nil
+}
+
 // validateExtractConfig validates the graph configuration parameters
 func validateExtractConfig(config *types.ExtractConfig) error {
 	logger.Errorf(context.Background(), "Validating extract configuration: %+v", config)
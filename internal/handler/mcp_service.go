@@ -37,6 +37,9 @@ func NewMCPServiceHandler(mcpServiceService interfaces.MCPServiceService) *MCPSe
 // @Router       /mcp-services [post]
 func (h *MCPServiceHandler) CreateMCPService(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
 
 	var service types.MCPService
 	if err := c.ShouldBindJSON(&service); err != nil {
@@ -150,6 +153,9 @@ func (h *MCPServiceHandler) GetMCPService(c *gin.Context) {
 // @Router       /mcp-services/{id} [put]
 func (h *MCPServiceHandler) UpdateMCPService(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
 	serviceID := secutils.SanitizeForLog(c.Param("id"))
 
 	tenantID := c.GetUint64(types.TenantIDContextKey.String())
@@ -254,12 +260,20 @@ func (h *MCPServiceHandler) UpdateMCPService(c *gin.Context) {
 		}
 	}
 
+	before, err := h.mcpServiceService.GetMCPServiceByID(ctx, tenantID, serviceID)
+	if err != nil {
+		logger.Warnf(ctx, "Failed to get MCP service %s before update: %v", secutils.SanitizeForLog(serviceID), err)
+	}
 	if err := h.mcpServiceService.UpdateMCPService(ctx, &service); err != nil {
 		logger.ErrorWithFields(ctx, err, map[string]interface{}{"service_id": secutils.SanitizeForLog(serviceID)})
 		c.Error(errors.NewInternalServerError("Failed to update MCP service: " + err.Error()))
 		return
 	}
 
+	if after, err := h.mcpServiceService.GetMCPServiceByID(ctx, tenantID, serviceID); err == nil {
+		auditChange(c, before, after)
+	}
+
 	logger.Infof(ctx, "MCP service updated successfully: %s", secutils.SanitizeForLog(serviceID))
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -281,6 +295,9 @@ func (h *MCPServiceHandler) UpdateMCPService(c *gin.Context) {
 // @Router       /mcp-services/{id} [delete]
 func (h *MCPServiceHandler) DeleteMCPService(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
 	serviceID := secutils.SanitizeForLog(c.Param("id"))
 
 	tenantID := c.GetUint64(types.TenantIDContextKey.String())
@@ -290,12 +307,18 @@ func (h *MCPServiceHandler) DeleteMCPService(c *gin.Context) {
 		return
 	}
 
+	before, err := h.mcpServiceService.GetMCPServiceByID(ctx, tenantID, serviceID)
+	if err != nil {
+		logger.Warnf(ctx, "Failed to get MCP service %s before deletion: %v", secutils.SanitizeForLog(serviceID), err)
+	}
 	if err := h.mcpServiceService.DeleteMCPService(ctx, tenantID, serviceID); err != nil {
 		logger.ErrorWithFields(ctx, err, map[string]interface{}{"service_id": secutils.SanitizeForLog(serviceID)})
 		c.Error(errors.NewInternalServerError("Failed to delete MCP service: " + err.Error()))
 		return
 	}
 
+	auditChange(c, before, nil)
+
 	logger.Infof(ctx, "MCP service deleted successfully: %s", secutils.SanitizeForLog(serviceID))
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -317,6 +340,9 @@ func (h *MCPServiceHandler) DeleteMCPService(c *gin.Context) {
 // @Router       /mcp-services/{id}/test [post]
 func (h *MCPServiceHandler) TestMCPService(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
 	serviceID := secutils.SanitizeForLog(c.Param("id"))
 
 	tenantID := c.GetUint64(types.TenantIDContextKey.String())
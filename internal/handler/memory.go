@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	secutils "github.com/Tencent/WeKnora/internal/utils"
+)
+
+// MemoryHandler handles the long-term memories the agent keeps about the current user
+type MemoryHandler struct {
+	service interfaces.MemoryService
+}
+
+// NewMemoryHandler creates a new memory handler
+func NewMemoryHandler(service interfaces.MemoryService) *MemoryHandler {
+	return &MemoryHandler{service: service}
+}
+
+// ListMemories godoc
+// @Summary      获取用户记忆列表
+// @Description  获取 Agent 从历史会话中记住的当前用户的事实和偏好，按更新时间倒序排列，仅限用户账号
+// @Tags         记忆
+// @Produce      json
+// @Success      200  {object}  map[string]interface{}  "记忆列表"
+// @Failure      403  {object}  errors.AppError         "非用户账号"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /memories [get]
+func (h *MemoryHandler) ListMemories(c *gin.Context) {
+	ctx := c.Request.Context()
+	memories, err := h.service.ListMemories(ctx)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    memories,
+	})
+}
+
+// DeleteMemory godoc
+// @Summary      删除用户记忆
+// @Description  删除当前用户的一条记忆，Agent 之后不再使用该记忆
+// @Tags         记忆
+// @Produce      json
+// @Param        id   path      string  true  "记忆ID"
+// @Success      200  {object}  map[string]interface{}  "删除成功"
+// @Failure      403  {object}  errors.AppError         "非用户账号"
+// @Failure      404  {object}  errors.AppError         "记忆不存在"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /memories/{id} [delete]
+func (h *MemoryHandler) DeleteMemory(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := h.service.DeleteMemory(ctx, secutils.SanitizeForLog(c.Param("id"))); err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+	})
+}
+
+// DeleteAllMemories godoc
+// @Summary      清空用户记忆
+// @Description  删除当前用户的全部记忆
+// @Tags         记忆
+// @Produce      json
+// @Success      200  {object}  map[string]interface{}  "删除成功"
+// @Failure      403  {object}  errors.AppError         "非用户账号"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /memories [delete]
+func (h *MemoryHandler) DeleteAllMemories(c *gin.Context) {
+	ctx := c.Request.Context()
+	if err := h.service.DeleteAllMemories(ctx); err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+	})
+}
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
 	"time"
@@ -16,17 +18,22 @@ import (
 // MessageHandler handles HTTP requests related to messages within chat sessions
 // It provides endpoints for loading and managing message history
 type MessageHandler struct {
-	MessageService interfaces.MessageService // Service that implements message business logic
+	MessageService    interfaces.MessageService    // Service that implements message business logic
+	AttachmentService interfaces.AttachmentService // Service that serves the attachments of questions
 }
 
-// NewMessageHandler creates a new message handler instance with the required service
+// NewMessageHandler creates a new message handler instance with the required services
 // Parameters:
 //   - messageService: Service that implements message business logic
+//   - attachmentService: Service that serves the attachments of questions
 //
 // Returns a pointer to a new MessageHandler
-func NewMessageHandler(messageService interfaces.MessageService) *MessageHandler {
+func NewMessageHandler(
+	messageService interfaces.MessageService, attachmentService interfaces.AttachmentService,
+) *MessageHandler {
 	return &MessageHandler{
-		MessageService: messageService,
+		MessageService:    messageService,
+		AttachmentService: attachmentService,
 	}
 }
 
@@ -156,3 +163,41 @@ func (h *MessageHandler) DeleteMessage(c *gin.Context) {
 		"message": "Message deleted successfully",
 	})
 }
+
+// GetAttachment godoc
+// @Summary      获取消息附件
+// @Description  获取用户提问时上传的图片或文档，index为附件在消息attachments中的序号
+// @Tags         消息
+// @Produce      application/octet-stream
+// @Param        session_id  path      string  true  "会话ID"
+// @Param        id          path      string  true  "消息ID"
+// @Param        index       path      int     true  "附件序号，从0开始"
+// @Success      200         {file}    file             "附件内容"
+// @Failure      404         {object}  errors.AppError  "会话、消息或附件不存在"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /messages/{session_id}/{id}/attachments/{index} [get]
+func (h *MessageHandler) GetAttachment(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		c.Error(errors.NewBadRequestError("Attachment index must be an integer"))
+		return
+	}
+	attachment, file, err := h.AttachmentService.GetAttachment(ctx,
+		secutils.SanitizeForLog(c.Param("session_id")), secutils.SanitizeForLog(c.Param("id")), index)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	defer file.Close()
+
+	c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": attachment.Name}))
+	c.Header("Content-Type", attachment.MimeType)
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, file); err != nil {
+		logger.Errorf(ctx, "Failed to send attachment: %v", err)
+	}
+}
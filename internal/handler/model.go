@@ -50,6 +50,7 @@ func hideSensitiveInfo(model *types.Model) *types.Model {
 			// Keep other parameters like embedding dimensions
 			EmbeddingParameters: model.Parameters.EmbeddingParameters,
 			ParameterSize:       model.Parameters.ParameterSize,
+			SupportsVision:      model.Parameters.SupportsVision,
 		},
 		IsBuiltin: model.IsBuiltin,
 		Status:    model.Status,
@@ -82,6 +83,9 @@ type CreateModelRequest struct {
 // @Router       /models [post]
 func (h *ModelHandler) CreateModel(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
 
 	logger.Info(ctx, "Start creating model")
 
@@ -116,6 +120,9 @@ func (h *ModelHandler) CreateModel(c *gin.Context) {
 		return
 	}
 
+	auditResourceID(c, model.ID)
+	auditChange(c, nil, model)
+
 	logger.Infof(
 		ctx,
 		"Model created successfully, ID: %s, Name: %s",
@@ -255,6 +262,9 @@ type UpdateModelRequest struct {
 // @Router       /models/{id} [put]
 func (h *ModelHandler) UpdateModel(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
 
 	logger.Info(ctx, "Start updating model")
 
@@ -285,6 +295,8 @@ func (h *ModelHandler) UpdateModel(c *gin.Context) {
 		return
 	}
 
+	before := *model
+
 	// Update model fields if they are provided in the request
 	if req.Name != "" {
 		model.Name = req.Name
@@ -303,6 +315,8 @@ func (h *ModelHandler) UpdateModel(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, &before, model)
+
 	logger.Infof(ctx, "Model updated successfully, ID: %s", id)
 
 	// Hide sensitive information for builtin models (though builtin models cannot be updated)
@@ -328,6 +342,9 @@ func (h *ModelHandler) UpdateModel(c *gin.Context) {
 // @Router       /models/{id} [delete]
 func (h *ModelHandler) DeleteModel(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
 
 	logger.Info(ctx, "Start deleting model")
 
@@ -339,6 +356,10 @@ func (h *ModelHandler) DeleteModel(c *gin.Context) {
 	}
 
 	logger.Infof(ctx, "Deleting model, ID: %s", id)
+	before, err := h.service.GetModelByID(ctx, id)
+	if err != nil && err != service.ErrModelNotFound {
+		logger.Warnf(ctx, "Failed to get model %s before deletion: %v", id, err)
+	}
 	if err := h.service.DeleteModel(ctx, id); err != nil {
 		if err == service.ErrModelNotFound {
 			logger.Warnf(ctx, "Model not found, ID: %s", id)
@@ -350,6 +371,8 @@ func (h *ModelHandler) DeleteModel(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, before, nil)
+
 	logger.Infof(ctx, "Model deleted successfully, ID: %s", id)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -0,0 +1,319 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	secutils "github.com/Tencent/WeKnora/internal/utils"
+)
+
+// requireTenantRole checks that the caller has at least the given role in the current tenant
+func requireTenantRole(c *gin.Context, role types.TenantRole) bool {
+	principal := types.PrincipalFromContext(c.Request.Context())
+	if principal == nil || principal.HasRole(role) {
+		return true
+	}
+	logger.Warnf(c.Request.Context(), "User %s with role %s denied, required role: %s",
+		principal.UserID, principal.Role, role)
+	c.Error(errors.NewForbiddenError("当前角色无权执行该操作"))
+	return false
+}
+
+// requireKnowledgeBasePermission checks that the caller has the required permission on a knowledge base
+func requireKnowledgeBasePermission(c *gin.Context, kbID string, required types.KBPermission) bool {
+	principal := types.PrincipalFromContext(c.Request.Context())
+	if principal == nil || principal.CanAccessKnowledgeBase(kbID, required) {
+		return true
+	}
+	logger.Warnf(c.Request.Context(), "User %s denied %s access to knowledge base %s",
+		principal.UserID, required, secutils.SanitizeForLog(kbID))
+	c.Error(errors.NewForbiddenError("无权访问该知识库"))
+	return false
+}
+
+// requireKnowledgePermission checks that the caller has the required permission on the knowledge base of a knowledge
+func requireKnowledgePermission(
+	c *gin.Context, knowledgeService interfaces.KnowledgeService, knowledgeID string, required types.KBPermission,
+) bool {
+	principal := types.PrincipalFromContext(c.Request.Context())
+	if principal == nil || principal.CanAccessAllKnowledgeBases(required) {
+		return true
+	}
+	knowledge, err := knowledgeService.GetKnowledgeByID(c.Request.Context(), knowledgeID)
+	if err != nil {
+		c.Error(errors.NewNotFoundError("知识不存在"))
+		return false
+	}
+	return requireKnowledgeBasePermission(c, knowledge.KnowledgeBaseID, required)
+}
+
+// PermissionHandler handles tenant members and knowledge base permissions
+type PermissionHandler struct {
+	service interfaces.PermissionService
+}
+
+// NewPermissionHandler creates a new permission handler
+func NewPermissionHandler(service interfaces.PermissionService) *PermissionHandler {
+	return &PermissionHandler{service: service}
+}
+
+// ListJoinedTenants godoc
+// @Summary      获取所属租户
+// @Description  获取当前用户可切换的租户及其角色，通过 X-Tenant-ID 请求头切换租户
+// @Tags         成员权限
+// @Produce      json
+// @Success      200  {object}  map[string]interface{}  "租户及角色列表"
+// @Failure      401  {object}  errors.AppError         "未登录"
+// @Security     Bearer
+// @Router       /tenants/joined [get]
+func (h *PermissionHandler) ListJoinedTenants(c *gin.Context) {
+	ctx := c.Request.Context()
+	memberships, err := h.service.ListJoinedTenants(ctx)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    memberships,
+	})
+}
+
+// ListMembers godoc
+// @Summary      获取租户成员
+// @Description  获取当前租户的成员及其角色，不包含租户创建者
+// @Tags         成员权限
+// @Produce      json
+// @Success      200  {object}  map[string]interface{}  "成员列表"
+// @Failure      403  {object}  errors.AppError         "无权限"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /tenants/members [get]
+func (h *PermissionHandler) ListMembers(c *gin.Context) {
+	ctx := c.Request.Context()
+	members, err := h.service.ListMembers(ctx)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    members,
+	})
+}
+
+type addMemberRequest struct {
+	Email string           `json:"email" binding:"required,email"`
+	Role  types.TenantRole `json:"role"  binding:"required"`
+}
+
+// AddMember godoc
+// @Summary      添加租户成员
+// @Description  将已注册用户加入当前租户，角色为 owner、editor 或 viewer，仅所有者可操作
+// @Tags         成员权限
+// @Accept       json
+// @Produce      json
+// @Param        request  body      object{email=string,role=string}  true  "成员信息"
+// @Success      200      {object}  map[string]interface{}            "新成员"
+// @Failure      400      {object}  errors.AppError                   "请求参数错误"
+// @Failure      403      {object}  errors.AppError                   "无权限"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /tenants/members [post]
+func (h *PermissionHandler) AddMember(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+	var req addMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error(ctx, "Failed to bind add member payload", err)
+		c.Error(errors.NewBadRequestError("请求参数不合法").WithDetails(err.Error()))
+		return
+	}
+	member, err := h.service.AddMember(ctx, req.Email, req.Role)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    member,
+	})
+}
+
+type updateMemberRequest struct {
+	Role types.TenantRole `json:"role" binding:"required"`
+}
+
+// UpdateMemberRole godoc
+// @Summary      修改成员角色
+// @Description  修改租户成员的角色，仅所有者可操作
+// @Tags         成员权限
+// @Accept       json
+// @Produce      json
+// @Param        user_id  path      string               true  "用户ID"
+// @Param        request  body      object{role=string}  true  "角色"
+// @Success      200      {object}  map[string]interface{}  "更新后的成员"
+// @Failure      403      {object}  errors.AppError         "无权限"
+// @Failure      404      {object}  errors.AppError         "成员不存在"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /tenants/members/{user_id} [put]
+func (h *PermissionHandler) UpdateMemberRole(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+	var req updateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error(ctx, "Failed to bind update member payload", err)
+		c.Error(errors.NewBadRequestError("请求参数不合法").WithDetails(err.Error()))
+		return
+	}
+	member, err := h.service.UpdateMemberRole(ctx, secutils.SanitizeForLog(c.Param("user_id")), req.Role)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    member,
+	})
+}
+
+// RemoveMember godoc
+// @Summary      移除租户成员
+// @Description  将成员移出当前租户并撤销其知识库权限，仅所有者可操作
+// @Tags         成员权限
+// @Produce      json
+// @Param        user_id  path      string  true  "用户ID"
+// @Success      200      {object}  map[string]interface{}  "移除成功"
+// @Failure      403      {object}  errors.AppError         "无权限"
+// @Failure      404      {object}  errors.AppError         "成员不存在"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /tenants/members/{user_id} [delete]
+func (h *PermissionHandler) RemoveMember(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+	if err := h.service.RemoveMember(ctx, secutils.SanitizeForLog(c.Param("user_id"))); err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+	})
+}
+
+// ListKnowledgeBaseGrants godoc
+// @Summary      获取知识库权限
+// @Description  获取授予成员的知识库权限，需要知识库管理权限
+// @Tags         成员权限
+// @Produce      json
+// @Param        id   path      string  true  "知识库ID"
+// @Success      200  {object}  map[string]interface{}  "权限列表"
+// @Failure      403  {object}  errors.AppError         "无权限"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /knowledge-bases/{id}/permissions [get]
+func (h *PermissionHandler) ListKnowledgeBaseGrants(c *gin.Context) {
+	ctx := c.Request.Context()
+	kbID := secutils.SanitizeForLog(c.Param("id"))
+	if !requireKnowledgeBasePermission(c, kbID, types.KBPermissionAdmin) {
+		return
+	}
+	grants, err := h.service.ListKnowledgeBaseGrants(ctx, kbID)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, map[string]interface{}{"kb_id": kbID})
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    grants,
+	})
+}
+
+type grantKnowledgeBaseRequest struct {
+	Permission types.KBPermission `json:"permission" binding:"required"`
+}
+
+// GrantKnowledgeBase godoc
+// @Summary      授予知识库权限
+// @Description  授予成员 read、write 或 admin 知识库权限并替换原有权限，需要知识库管理权限
+// @Tags         成员权限
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string                     true  "知识库ID"
+// @Param        user_id  path      string                     true  "用户ID"
+// @Param        request  body      object{permission=string}  true  "权限"
+// @Success      200      {object}  map[string]interface{}     "权限"
+// @Failure      403      {object}  errors.AppError            "无权限"
+// @Failure      404      {object}  errors.AppError            "成员或知识库不存在"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /knowledge-bases/{id}/permissions/{user_id} [put]
+func (h *PermissionHandler) GrantKnowledgeBase(c *gin.Context) {
+	ctx := c.Request.Context()
+	kbID := secutils.SanitizeForLog(c.Param("id"))
+	if !requireKnowledgeBasePermission(c, kbID, types.KBPermissionAdmin) {
+		return
+	}
+	var req grantKnowledgeBaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error(ctx, "Failed to bind grant payload", err)
+		c.Error(errors.NewBadRequestError("请求参数不合法").WithDetails(err.Error()))
+		return
+	}
+	grant, err := h.service.GrantKnowledgeBase(ctx, kbID, secutils.SanitizeForLog(c.Param("user_id")), req.Permission)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, map[string]interface{}{"kb_id": kbID})
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    grant,
+	})
+}
+
+// RevokeKnowledgeBase godoc
+// @Summary      撤销知识库权限
+// @Description  撤销成员的知识库权限，需要知识库管理权限
+// @Tags         成员权限
+// @Produce      json
+// @Param        id       path      string  true  "知识库ID"
+// @Param        user_id  path      string  true  "用户ID"
+// @Success      200      {object}  map[string]interface{}  "撤销成功"
+// @Failure      403      {object}  errors.AppError         "无权限"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /knowledge-bases/{id}/permissions/{user_id} [delete]
+func (h *PermissionHandler) RevokeKnowledgeBase(c *gin.Context) {
+	ctx := c.Request.Context()
+	kbID := secutils.SanitizeForLog(c.Param("id"))
+	if !requireKnowledgeBasePermission(c, kbID, types.KBPermissionAdmin) {
+		return
+	}
+	if err := h.service.RevokeKnowledgeBase(ctx, kbID, secutils.SanitizeForLog(c.Param("user_id"))); err != nil {
+		logger.ErrorWithFields(ctx, err, map[string]interface{}{"kb_id": kbID})
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+	})
+}
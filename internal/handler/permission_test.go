@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPermissionTestContext creates a gin context for a request made by principal, nil for internal calls
+func newPermissionTestContext(principal *types.Principal) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx := context.Background()
+	if principal != nil {
+		ctx = context.WithValue(ctx, types.PrincipalContextKey, principal)
+	}
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	return c
+}
+
+// assertForbidden checks that the request was denied with a 403 error
+func assertForbidden(t *testing.T, c *gin.Context) {
+	t.Helper()
+	require.Len(t, c.Errors, 1)
+	appErr, ok := c.Errors.Last().Err.(*errors.AppError)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusForbidden, appErr.HTTPCode)
+}
+
+func TestRequireTenantRole(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *types.Principal
+		role      types.TenantRole
+		allowed   bool
+	}{
+		{name: "internal call", role: types.TenantRoleOwner, allowed: true},
+		{
+			name:      "owner",
+			principal: &types.Principal{Role: types.TenantRoleOwner},
+			role:      types.TenantRoleOwner,
+			allowed:   true,
+		},
+		{
+			name:      "editor as viewer",
+			principal: &types.Principal{Role: types.TenantRoleEditor},
+			role:      types.TenantRoleViewer,
+			allowed:   true,
+		},
+		{
+			name:      "editor as owner",
+			principal: &types.Principal{Role: types.TenantRoleEditor},
+			role:      types.TenantRoleOwner,
+		},
+		{
+			name:      "viewer as editor",
+			principal: &types.Principal{Role: types.TenantRoleViewer},
+			role:      types.TenantRoleEditor,
+		},
+		{
+			name: "owner key without admin scope",
+			principal: &types.Principal{
+				Role: types.TenantRoleOwner, APIKey: &types.APIKey{Scopes: types.StringArray{"kb:write"}},
+			},
+			role: types.TenantRoleOwner,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newPermissionTestContext(tt.principal)
+			assert.Equal(t, tt.allowed, requireTenantRole(c, tt.role))
+			if tt.allowed {
+				assert.Empty(t, c.Errors)
+				return
+			}
+			assertForbidden(t, c)
+		})
+	}
+}
+
+func TestRequireKnowledgeBasePermission(t *testing.T) {
+	grants := map[string]types.KBPermission{"kb1": types.KBPermissionWrite, "kb2": types.KBPermissionRead}
+	tests := []struct {
+		name      string
+		principal *types.Principal
+		kbID      string
+		required  types.KBPermission
+		allowed   bool
+	}{
+		{name: "internal call", kbID: "kb1", required: types.KBPermissionAdmin, allowed: true},
+		{
+			name:      "owner",
+			principal: &types.Principal{Role: types.TenantRoleOwner},
+			kbID:      "kb3",
+			required:  types.KBPermissionAdmin,
+			allowed:   true,
+		},
+		{
+			name:      "grant allows write",
+			principal: &types.Principal{Role: types.TenantRoleEditor, Grants: grants},
+			kbID:      "kb1",
+			required:  types.KBPermissionWrite,
+			allowed:   true,
+		},
+		{
+			name:      "grant does not allow admin",
+			principal: &types.Principal{Role: types.TenantRoleEditor, Grants: grants},
+			kbID:      "kb1",
+			required:  types.KBPermissionAdmin,
+		},
+		{
+			name:      "read grant does not allow write",
+			principal: &types.Principal{Role: types.TenantRoleEditor, Grants: grants},
+			kbID:      "kb2",
+			required:  types.KBPermissionWrite,
+		},
+		{
+			name:      "viewer is capped at read",
+			principal: &types.Principal{Role: types.TenantRoleViewer, Grants: grants},
+			kbID:      "kb1",
+			required:  types.KBPermissionWrite,
+		},
+		{
+			name:      "no grant",
+			principal: &types.Principal{Role: types.TenantRoleEditor, Grants: grants},
+			kbID:      "kb3",
+			required:  types.KBPermissionRead,
+		},
+		{
+			name: "key restricted to other knowledge bases",
+			principal: &types.Principal{Role: types.TenantRoleOwner, APIKey: &types.APIKey{
+				Scopes: types.StringArray{"admin"}, KnowledgeBaseIDs: types.StringArray{"kb2"},
+			}},
+			kbID:     "kb1",
+			required: types.KBPermissionRead,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newPermissionTestContext(tt.principal)
+			assert.Equal(t, tt.allowed, requireKnowledgeBasePermission(c, tt.kbID, tt.required))
+			if tt.allowed {
+				assert.Empty(t, c.Errors)
+				return
+			}
+			assertForbidden(t, c)
+		})
+	}
+}
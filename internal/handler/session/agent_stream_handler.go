@@ -61,8 +61,10 @@ func (h *AgentStreamHandler) Subscribe() {
 	h.eventBus.On(event.EventAgentToolCall, h.handleToolCall)
 	h.eventBus.On(event.EventAgentToolResult, h.handleToolResult)
 	h.eventBus.On(event.EventAgentReferences, h.handleReferences)
+	h.eventBus.On(event.EventAgentCitations, h.handleCitations)
 	h.eventBus.On(event.EventAgentFinalAnswer, h.handleFinalAnswer)
 	h.eventBus.On(event.EventAgentReflection, h.handleReflection)
+	h.eventBus.On(event.EventAgentToolApprovalRequired, h.handleToolApprovalRequired)
 	h.eventBus.On(event.EventError, h.handleError)
 	h.eventBus.On(event.EventSessionTitle, h.handleSessionTitle)
 	h.eventBus.On(event.EventAgentComplete, h.handleComplete)
@@ -98,6 +100,7 @@ func (h *AgentStreamHandler) handleThought(ctx context.Context, evt event.Event)
 			"event_id": evt.ID,
 		}
 	}
+	addDelegationMetadata(metadata, data.DelegationData)
 
 	h.mu.Unlock()
 
@@ -133,6 +136,7 @@ func (h *AgentStreamHandler) handleToolCall(ctx context.Context, evt event.Event
 		"arguments":    data.Arguments,
 		"tool_call_id": data.ToolCallID,
 	}
+	addDelegationMetadata(metadata, data.DelegationData)
 
 	// Append event to stream
 	if err := h.streamManager.AppendEvent(h.ctx, h.sessionID, h.assistantMessageID, interfaces.StreamEvent{
@@ -194,6 +198,7 @@ func (h *AgentStreamHandler) handleToolResult(ctx context.Context, evt event.Eve
 			metadata[k] = v
 		}
 	}
+	addDelegationMetadata(metadata, data.DelegationData)
 
 	// Append event to stream
 	if err := h.streamManager.AppendEvent(h.ctx, h.sessionID, h.assistantMessageID, interfaces.StreamEvent{
@@ -210,6 +215,36 @@ func (h *AgentStreamHandler) handleToolResult(ctx context.Context, evt event.Eve
 	return nil
 }
 
+// handleToolApprovalRequired handles tool calls which wait for the user to approve them
+func (h *AgentStreamHandler) handleToolApprovalRequired(ctx context.Context, evt event.Event) error {
+	data, ok := evt.Data.(event.AgentToolApprovalData)
+	if !ok {
+		return nil
+	}
+
+	metadata := map[string]interface{}{
+		"approval_id":  data.ApprovalID,
+		"tool_name":    data.ToolName,
+		"arguments":    data.Arguments,
+		"tool_call_id": data.ToolCallID,
+		"expires_at":   data.ExpiresAt,
+	}
+	addDelegationMetadata(metadata, data.DelegationData)
+
+	if err := h.streamManager.AppendEvent(h.ctx, h.sessionID, h.assistantMessageID, interfaces.StreamEvent{
+		ID:        evt.ID,
+		Type:      types.ResponseTypeToolApprovalRequired,
+		Content:   fmt.Sprintf("Waiting for approval of tool: %s", data.ToolName),
+		Done:      false,
+		Timestamp: time.Now(),
+		Data:      metadata,
+	}); err != nil {
+		logger.GetLogger(h.ctx).Error("Append tool approval event to stream failed", "error", err)
+	}
+
+	return nil
+}
+
 // handleReferences handles knowledge references events
 func (h *AgentStreamHandler) handleReferences(ctx context.Context, evt event.Event) error {
 	data, ok := evt.Data.(event.AgentReferencesData)
@@ -275,6 +310,34 @@ func (h *AgentStreamHandler) handleReferences(ctx context.Context, evt event.Eve
 	return nil
 }
 
+// handleCitations handles the verified citations of the answer, they arrive before its last chunk
+func (h *AgentStreamHandler) handleCitations(ctx context.Context, evt event.Event) error {
+	data, ok := evt.Data.(event.AgentCitationsData)
+	if !ok {
+		return nil
+	}
+
+	h.mu.Lock()
+	h.assistantMessage.Citations = data.Citations
+	h.mu.Unlock()
+
+	// Append citations event to stream
+	if err := h.streamManager.AppendEvent(h.ctx, h.sessionID, h.assistantMessageID, interfaces.StreamEvent{
+		ID:        evt.ID,
+		Type:      types.ResponseTypeCitations,
+		Content:   "",
+		Done:      false,
+		Timestamp: time.Now(),
+		Data: map[string]interface{}{
+			"citations": data.Citations,
+		},
+	}); err != nil {
+		logger.GetLogger(h.ctx).Error("Append citations event to stream failed", "error", err)
+	}
+
+	return nil
+}
+
 // handleFinalAnswer handles final answer events
 func (h *AgentStreamHandler) handleFinalAnswer(ctx context.Context, evt event.Event) error {
 	data, ok := evt.Data.(event.AgentFinalAnswerData)
@@ -338,6 +401,7 @@ func (h *AgentStreamHandler) handleReflection(ctx context.Context, evt event.Eve
 		Content:   data.Content, // Just this chunk
 		Done:      data.Done,
 		Timestamp: time.Now(),
+		Data:      addDelegationMetadata(nil, data.DelegationData),
 	}); err != nil {
 		logger.GetLogger(h.ctx).Error("Append reflection event to stream failed", "error", err)
 	}
@@ -452,3 +516,17 @@ func (h *AgentStreamHandler) handleComplete(ctx context.Context, evt event.Event
 
 	return nil
 }
+
+// addDelegationMetadata nests the events of a sub-agent under the delegate_to_agent tool call
+// of the delegating agent, so that the frontend can render them inside that step
+func addDelegationMetadata(metadata map[string]interface{}, data event.DelegationData) map[string]interface{} {
+	if data.ParentToolCallID == "" {
+		return metadata
+	}
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+	}
+	metadata["parent_tool_call_id"] = data.ParentToolCallID
+	metadata["agent_name"] = data.AgentName
+	return metadata
+}
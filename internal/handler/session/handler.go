@@ -20,6 +20,9 @@ type Handler struct {
 	streamManager        interfaces.StreamManager        // Manager for handling streaming responses
 	config               *config.Config                  // Application configuration
 	knowledgebaseService interfaces.KnowledgeBaseService // Service for managing knowledge bases
+	modelService         interfaces.ModelService         // Service for managing models
+	toolApprovalService  interfaces.ToolApprovalService  // Service for approving agent tool calls
+	attachmentService    interfaces.AttachmentService    // Service for the attachments of questions
 }
 
 // NewHandler creates a new instance of Handler with all necessary dependencies
@@ -29,6 +32,9 @@ func NewHandler(
 	streamManager interfaces.StreamManager,
 	config *config.Config,
 	knowledgebaseService interfaces.KnowledgeBaseService,
+	modelService interfaces.ModelService,
+	toolApprovalService interfaces.ToolApprovalService,
+	attachmentService interfaces.AttachmentService,
 ) *Handler {
 	return &Handler{
 		sessionService:       sessionService,
@@ -36,6 +42,9 @@ func NewHandler(
 		streamManager:        streamManager,
 		config:               config,
 		knowledgebaseService: knowledgebaseService,
+		modelService:         modelService,
+		toolApprovalService:  toolApprovalService,
+		attachmentService:    attachmentService,
 	}
 }
 
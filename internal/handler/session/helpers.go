@@ -2,14 +2,19 @@ package session
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"mime/multipart"
+	"sync"
 	"time"
 
+	"github.com/Tencent/WeKnora/internal/errors"
 	"github.com/Tencent/WeKnora/internal/event"
 	"github.com/Tencent/WeKnora/internal/logger"
 	"github.com/Tencent/WeKnora/internal/types"
 	"github.com/Tencent/WeKnora/internal/types/interfaces"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 // convertMentionedItems converts MentionedItemRequest slice to types.MentionedItems
@@ -29,6 +34,17 @@ func convertMentionedItems(items []MentionedItemRequest) types.MentionedItems {
 	return result
 }
 
+// validateRetrievalFilter checks the optional retrieval filter of a request
+func validateRetrievalFilter(filter *types.RetrievalFilter) error {
+	if filter == nil {
+		return nil
+	}
+	if err := filter.Validate(); err != nil {
+		return errors.NewBadRequestError("Invalid filter").WithDetails(err.Error())
+	}
+	return nil
+}
+
 // setSSEHeaders sets the standard Server-Sent Events headers
 func setSSEHeaders(c *gin.Context) {
 	c.Header("Content-Type", "text/event-stream")
@@ -94,7 +110,9 @@ func createAgentQueryEvent(sessionID, assistantMessageID string) interfaces.Stre
 }
 
 // createUserMessage creates a user message
-func (h *Handler) createUserMessage(ctx context.Context, sessionID, query, requestID string, mentionedItems types.MentionedItems) error {
+func (h *Handler) createUserMessage(ctx context.Context, sessionID, query, requestID string,
+	mentionedItems types.MentionedItems, attachments types.MessageAttachments,
+) error {
 	_, err := h.messageService.CreateMessage(ctx, &types.Message{
 		SessionID:      sessionID,
 		Role:           "user",
@@ -103,10 +121,30 @@ func (h *Handler) createUserMessage(ctx context.Context, sessionID, query, reque
 		CreatedAt:      time.Now(),
 		IsCompleted:    true,
 		MentionedItems: mentionedItems,
+		Attachments:    attachments,
 	})
 	return err
 }
 
+// bindQARequest binds a question request. A multipart request carries the JSON request in the request field
+// and the attached images and documents in the attachments fields.
+func bindQARequest(c *gin.Context, request *CreateKnowledgeQARequest) ([]*multipart.FileHeader, error) {
+	if c.ContentType() != gin.MIMEMultipartPOSTForm {
+		return nil, c.ShouldBindJSON(request)
+	}
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(c.PostForm("request")), request); err != nil {
+		return nil, err
+	}
+	if err := binding.Validator.ValidateStruct(request); err != nil {
+		return nil, err
+	}
+	return form.File["attachments"], nil
+}
+
 // createAssistantMessage creates an assistant message
 func (h *Handler) createAssistantMessage(ctx context.Context, assistantMessage *types.Message) (*types.Message, error) {
 	assistantMessage.CreatedAt = time.Now()
@@ -134,12 +172,13 @@ func (h *Handler) setupStopEventHandler(
 	sessionID string,
 	assistantMessage *types.Message,
 	cancel context.CancelFunc,
+	completed *sync.Once,
 ) {
 	eventBus.On(event.EventStop, func(ctx context.Context, evt event.Event) error {
 		logger.Infof(ctx, "Received stop event, cancelling async operations for session: %s", sessionID)
 		cancel()
 		assistantMessage.Content = "用户停止了本次对话"
-		h.completeAssistantMessage(ctx, assistantMessage)
+		h.completeAssistantMessage(ctx, assistantMessage, completed)
 		return nil
 	})
 }
@@ -0,0 +1,649 @@
+package session
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/event"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	secutils "github.com/Tencent/WeKnora/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// openAIKBModelPrefix selects knowledge QA over a knowledge base, followed by its ID
+	openAIKBModelPrefix = "kb:"
+	// openAIAgentModel selects agent QA over every knowledge base the caller can read
+	openAIAgentModel = "agent"
+	// openAIAgentModelPrefix selects agent QA over a knowledge base, followed by its ID
+	openAIAgentModelPrefix = "agent:"
+	// openAIModelOwner is the owner of the models listed by the models API
+	openAIModelOwner = "weknora"
+	// maxEmbeddingInputs is the maximum number of inputs of an embeddings request
+	maxEmbeddingInputs = 256
+)
+
+// chatModelTarget is the QA flow run behind a model name of the chat completions API
+type chatModelTarget struct {
+	agent            bool
+	knowledgeBaseIDs []string
+}
+
+// ChatCompletions godoc
+// @Summary      OpenAI 兼容的对话补全
+// @Description  兼容 OpenAI Chat Completions 协议，model 为 kb:<知识库ID> 时执行知识库问答，为 agent 或 agent:<知识库ID> 时执行 Agent 问答。
+// @Description  支持 stream 流式响应，引用来源在 weknora 扩展字段中返回，传入 session_id 可在已有会话中继续对话
+// @Tags         OpenAI 兼容接口
+// @Accept       json
+// @Produce      json
+// @Produce      text/event-stream
+// @Param        request  body      ChatCompletionRequest   true  "对话补全请求"
+// @Success      200      {object}  ChatCompletionResponse  "对话补全结果，流式请求时为 SSE 流"
+// @Failure      400      {object}  errors.AppError         "请求参数错误"
+// @Failure      404      {object}  errors.AppError         "模型或会话不存在"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /v1/chat/completions [post]
+func (h *Handler) ChatCompletions(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var request ChatCompletionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logger.Error(ctx, "Failed to parse chat completion request", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+	completion, eventBus, err := h.startChatCompletion(logger.CloneContext(ctx), &request, getRequestID(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if request.Stream {
+		h.streamChatCompletion(c, completion, eventBus)
+		return
+	}
+	h.respondChatCompletion(c, completion, eventBus)
+}
+
+// Ask answers a chat completion request without streaming, for callers outside of the HTTP API such as
+// the MCP server. The answer is stored in a session like the answers of the chat completions API.
+func (h *Handler) Ask(ctx context.Context, request *ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	requestID, _ := ctx.Value(types.RequestIDContextKey).(string)
+	if requestID == "" {
+		requestID = uuid.New().String()
+		ctx = context.WithValue(ctx, types.RequestIDContextKey, requestID)
+	}
+	completion, eventBus, err := h.startChatCompletion(logger.CloneContext(ctx), request, requestID)
+	if err != nil {
+		return nil, err
+	}
+	finished, err := h.collectChatCompletion(ctx, completion, eventBus)
+	if err != nil {
+		return nil, err
+	}
+	if !finished {
+		return nil, ctx.Err()
+	}
+	return completion, nil
+}
+
+// startChatCompletion validates a chat completion request and starts its QA flow in the background.
+// It returns the completion to fill with the answer and the event bus of the QA flow.
+func (h *Handler) startChatCompletion(
+	ctx context.Context, request *ChatCompletionRequest, requestID string,
+) (*ChatCompletionResponse, *event.EventBus, error) {
+	model := secutils.SanitizeForLog(request.Model)
+	if len(request.Messages) == 0 || request.Messages[len(request.Messages)-1].Role != "user" {
+		return nil, nil, errors.NewBadRequestError("The last message must be a user message")
+	}
+	query := request.Messages[len(request.Messages)-1].Text()
+	if strings.TrimSpace(query) == "" {
+		return nil, nil, errors.NewBadRequestError("Query content cannot be empty")
+	}
+
+	target, err := h.resolveChatModel(ctx, model)
+	if err != nil {
+		return nil, nil, err
+	}
+	logger.Infof(ctx, "Chat completion request, model: %s, stream: %v, session ID: %s",
+		model, request.Stream, secutils.SanitizeForLog(request.SessionID))
+
+	session, err := h.prepareChatCompletionSession(ctx, request, target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	assistantMessage := &types.Message{
+		SessionID:   session.ID,
+		Role:        "assistant",
+		RequestID:   requestID,
+		IsCompleted: false,
+	}
+	var eventBus *event.EventBus
+	if target.agent {
+		// Chat completion clients have no way to approve tool calls
+		ctx = context.WithValue(ctx, types.ToolApprovalUnavailableContextKey, true)
+		eventBus, err = h.startAgentQA(ctx, session, query, nil, assistantMessage, requestID, nil, nil)
+	} else {
+		eventBus, err = h.startKnowledgeQA(ctx, session, query, target.knowledgeBaseIDs, nil, nil, assistantMessage,
+			requestID, true, "", false, nil, nil)
+	}
+	if err != nil {
+		return nil, nil, errors.NewInternalServerError(err.Error())
+	}
+
+	completion := &ChatCompletionResponse{
+		ID:      "chatcmpl-" + requestID,
+		Created: time.Now().Unix(),
+		Model:   request.Model,
+		WeKnora: &ChatCompletionExtension{SessionID: session.ID, MessageID: assistantMessage.ID},
+	}
+	return completion, eventBus, nil
+}
+
+// resolveChatModel maps a model name of the chat completions API to a QA flow
+func (h *Handler) resolveChatModel(ctx context.Context, model string) (*chatModelTarget, error) {
+	switch {
+	case model == openAIAgentModel:
+		kbs, err := h.readableKnowledgeBases(ctx)
+		if err != nil {
+			return nil, err
+		}
+		target := &chatModelTarget{agent: true}
+		for _, kb := range kbs {
+			target.knowledgeBaseIDs = append(target.knowledgeBaseIDs, kb.ID)
+		}
+		return target, nil
+	case strings.HasPrefix(model, openAIAgentModelPrefix):
+		kbID := strings.TrimPrefix(model, openAIAgentModelPrefix)
+		if err := h.checkModelKnowledgeBase(ctx, kbID); err != nil {
+			return nil, err
+		}
+		return &chatModelTarget{agent: true, knowledgeBaseIDs: []string{kbID}}, nil
+	case strings.HasPrefix(model, openAIKBModelPrefix):
+		kbID := strings.TrimPrefix(model, openAIKBModelPrefix)
+		if err := h.checkModelKnowledgeBase(ctx, kbID); err != nil {
+			return nil, err
+		}
+		return &chatModelTarget{knowledgeBaseIDs: []string{kbID}}, nil
+	}
+	return nil, errors.NewNotFoundError("Model not found").WithDetails(model)
+}
+
+// checkModelKnowledgeBase checks that the knowledge base of a model exists in the tenant and can be read by the caller
+func (h *Handler) checkModelKnowledgeBase(ctx context.Context, kbID string) error {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	kb, err := h.knowledgebaseService.GetKnowledgeBaseByID(ctx, kbID)
+	if err != nil || kb == nil || kb.TenantID != tenantID {
+		return errors.NewNotFoundError("Model not found").WithDetails(kbID)
+	}
+	if principal := types.PrincipalFromContext(ctx); principal != nil &&
+		!principal.CanAccessKnowledgeBase(kbID, types.KBPermissionRead) {
+		return errors.NewForbiddenError("No permission to access the knowledge base").WithDetails(kbID)
+	}
+	return nil
+}
+
+// readableKnowledgeBases lists the knowledge bases of the tenant the caller can read
+func (h *Handler) readableKnowledgeBases(ctx context.Context) ([]*types.KnowledgeBase, error) {
+	kbs, err := h.knowledgebaseService.ListKnowledgeBases(ctx)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		return nil, errors.NewInternalServerError(err.Error())
+	}
+	principal := types.PrincipalFromContext(ctx)
+	if principal == nil {
+		return kbs, nil
+	}
+	readable := make([]*types.KnowledgeBase, 0, len(kbs))
+	for _, kb := range kbs {
+		if principal.CanAccessKnowledgeBase(kb.ID, types.KBPermissionRead) {
+			readable = append(readable, kb)
+		}
+	}
+	return readable, nil
+}
+
+// prepareChatCompletionSession returns the session of a chat completion request.
+// An existing session keeps its own history, otherwise a new session is created and the earlier turns
+// of the request are stored in it, so that the QA pipeline sees them as the conversation history.
+func (h *Handler) prepareChatCompletionSession(
+	ctx context.Context, request *ChatCompletionRequest, target *chatModelTarget,
+) (*types.Session, error) {
+	agentConfig := &types.SessionAgentConfig{
+		AgentModeEnabled: target.agent,
+		KnowledgeBases:   target.knowledgeBaseIDs,
+	}
+
+	if request.SessionID != "" {
+		sessionID := secutils.SanitizeForLog(request.SessionID)
+		session, err := h.sessionService.GetSession(ctx, sessionID)
+		if err != nil {
+			logger.Errorf(ctx, "Failed to get session, session ID: %s, error: %v", sessionID, err)
+			return nil, errors.NewNotFoundError("Session not found")
+		}
+		if session.AgentConfig == nil || session.AgentConfig.AgentModeEnabled != agentConfig.AgentModeEnabled ||
+			!sameStrings(session.AgentConfig.KnowledgeBases, agentConfig.KnowledgeBases) {
+			logger.Warnf(ctx, "Model changed, clearing context for session: %s", sessionID)
+			if err := h.sessionService.ClearContext(ctx, sessionID); err != nil {
+				logger.Errorf(ctx, "Failed to clear context for session %s: %v", sessionID, err)
+			}
+			session.AgentConfig = agentConfig
+			if err := h.sessionService.UpdateSession(ctx, session); err != nil {
+				logger.Errorf(ctx, "Failed to update session %s: %v", sessionID, err)
+				return nil, errors.NewInternalServerError("Failed to update session configuration")
+			}
+		}
+		return session, nil
+	}
+
+	tenantInfo, _ := ctx.Value(types.TenantInfoContextKey).(*types.Tenant)
+	session := &types.Session{
+		TenantID:    ctx.Value(types.TenantIDContextKey).(uint64),
+		AgentConfig: agentConfig,
+	}
+	h.applyConversationDefaults(ctx, session, tenantInfo)
+	if !target.agent && session.SummaryModelID == "" {
+		if kb, err := h.knowledgebaseService.GetKnowledgeBaseByID(ctx, target.knowledgeBaseIDs[0]); err == nil {
+			session.KnowledgeBaseID = kb.ID
+			session.SummaryModelID = kb.SummaryModelID
+		}
+	}
+	session, err := h.sessionService.CreateSession(ctx, session)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		return nil, errors.NewInternalServerError(err.Error())
+	}
+	logger.Infof(ctx, "Session created for chat completion, ID: %s", session.ID)
+
+	if err := h.storeChatHistory(ctx, session.ID, request.Messages[:len(request.Messages)-1]); err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		return nil, errors.NewInternalServerError(err.Error())
+	}
+	return session, nil
+}
+
+// storeChatHistory stores the user and assistant turns of a chat completion request in a new session.
+// System and tool messages are skipped, the prompts of the session are configured in WeKnora.
+func (h *Handler) storeChatHistory(ctx context.Context, sessionID string, messages []ChatCompletionMessage) error {
+	createdAt := time.Now().Add(-time.Duration(len(messages)) * time.Millisecond)
+	var requestID string
+	for _, message := range messages {
+		if message.Role != "user" && message.Role != "assistant" {
+			continue
+		}
+		if message.Role == "user" || requestID == "" {
+			requestID = uuid.New().String()
+		}
+		createdAt = createdAt.Add(time.Millisecond)
+		if _, err := h.messageService.CreateMessage(ctx, &types.Message{
+			SessionID:   sessionID,
+			Role:        message.Role,
+			Content:     message.Text(),
+			RequestID:   requestID,
+			CreatedAt:   createdAt,
+			IsCompleted: true,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamChatCompletion streams the answer of a chat completion as OpenAI chat.completion.chunk events
+func (h *Handler) streamChatCompletion(c *gin.Context, completion *ChatCompletionResponse, eventBus *event.EventBus) {
+	setSSEHeaders(c)
+	completion.Object = "chat.completion.chunk"
+	extension := completion.WeKnora
+
+	writeChunk := func(delta *ChatCompletionDelta, finishReason *string, extension *ChatCompletionExtension) {
+		chunk := *completion
+		chunk.Choices = []ChatCompletionChoice{{Delta: delta, FinishReason: finishReason}}
+		chunk.WeKnora = extension
+		writeSSEData(c, chunk)
+	}
+	writeChunk(&ChatCompletionDelta{Role: "assistant"}, nil, extension)
+
+	failure, finished := h.pollAnswerEvents(c.Request.Context(), extension.SessionID, extension.MessageID, eventBus,
+		func(evt interfaces.StreamEvent) {
+			switch evt.Type {
+			case types.ResponseTypeAnswer:
+				if evt.Content != "" {
+					writeChunk(&ChatCompletionDelta{Content: evt.Content}, nil, nil)
+				}
+			case types.ResponseTypeThinking:
+				if evt.Content != "" {
+					writeChunk(&ChatCompletionDelta{ReasoningContent: evt.Content}, nil, nil)
+				}
+			case types.ResponseTypeReferences:
+				extension.References = decodeReferences(evt.Data["references"])
+				writeChunk(&ChatCompletionDelta{}, nil, extension)
+			}
+		})
+	if !finished {
+		return
+	}
+	if failure != "" {
+		writeSSEData(c, gin.H{"error": gin.H{"message": failure, "type": "server_error"}})
+	} else {
+		finishReason := "stop"
+		writeChunk(&ChatCompletionDelta{}, &finishReason, extension)
+	}
+	_, _ = io.WriteString(c.Writer, "data: [DONE]\n\n")
+	c.Writer.Flush()
+}
+
+// respondChatCompletion waits for the whole answer and responds with an OpenAI chat.completion object
+func (h *Handler) respondChatCompletion(c *gin.Context, completion *ChatCompletionResponse, eventBus *event.EventBus) {
+	finished, err := h.collectChatCompletion(c.Request.Context(), completion, eventBus)
+	if !finished {
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, completion)
+}
+
+// collectChatCompletion waits for the whole answer of a chat completion and fills it in as a chat.completion object.
+// finished is false when ctx is done before the end of the answer.
+func (h *Handler) collectChatCompletion(
+	ctx context.Context, completion *ChatCompletionResponse, eventBus *event.EventBus,
+) (finished bool, err error) {
+	completion.Object = "chat.completion"
+	var content, reasoning strings.Builder
+
+	failure, finished := h.pollAnswerEvents(ctx, completion.WeKnora.SessionID, completion.WeKnora.MessageID, eventBus,
+		func(evt interfaces.StreamEvent) {
+			switch evt.Type {
+			case types.ResponseTypeAnswer:
+				content.WriteString(evt.Content)
+			case types.ResponseTypeThinking:
+				reasoning.WriteString(evt.Content)
+			case types.ResponseTypeReferences:
+				completion.WeKnora.References = decodeReferences(evt.Data["references"])
+			}
+		})
+	if !finished {
+		return false, nil
+	}
+	if failure != "" {
+		return true, errors.NewInternalServerError(failure)
+	}
+	answer := &ChatCompletionAnswer{
+		Role:             "assistant",
+		Content:          content.String(),
+		ReasoningContent: reasoning.String(),
+	}
+	finishReason := "stop"
+	completion.Choices = []ChatCompletionChoice{{Message: answer, FinishReason: &finishReason}}
+	return true, nil
+}
+
+// pollAnswerEvents polls the events of an assistant message from the StreamManager and passes them to handle
+// until the answer is complete, failed or stopped. It returns the error of a failed answer,
+// and finished is false when ctx is done, e.g. the client disconnected, before the end of the answer.
+func (h *Handler) pollAnswerEvents(
+	ctx context.Context,
+	sessionID, assistantMessageID string,
+	eventBus *event.EventBus,
+	handle func(evt interfaces.StreamEvent),
+) (failure string, finished bool) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	lastOffset := 0
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Infof(ctx, "Client disconnected, stopping chat completion for session=%s, message=%s",
+				sessionID, assistantMessageID)
+			return "", false
+
+		case <-ticker.C:
+			events, newOffset, err := h.streamManager.GetEvents(ctx, sessionID, assistantMessageID, lastOffset)
+			if err != nil {
+				logger.Warnf(ctx, "Failed to get events from stream: %v", err)
+				continue
+			}
+			lastOffset = newOffset
+			for _, evt := range events {
+				switch {
+				case evt.Type == types.ResponseType(event.EventStop):
+					// The generation is stopped through the session stop API
+					eventBus.Emit(ctx, event.Event{
+						Type:      event.EventStop,
+						SessionID: sessionID,
+						Data: event.StopData{
+							SessionID: sessionID,
+							MessageID: assistantMessageID,
+							Reason:    "user_requested",
+						},
+					})
+					return "", true
+				case evt.Type == types.ResponseTypeError && evt.Done:
+					return evt.Content, true
+				case evt.Type == types.ResponseTypeComplete:
+					return "", true
+				default:
+					handle(evt)
+				}
+			}
+		}
+	}
+}
+
+// Embeddings godoc
+// @Summary      OpenAI 兼容的向量化
+// @Description  兼容 OpenAI Embeddings 协议，model 为 Embedding 模型的 ID 或名称，或 kb:<知识库ID> 表示使用该知识库的 Embedding 模型
+// @Tags         OpenAI 兼容接口
+// @Accept       json
+// @Produce      json
+// @Param        request  body      EmbeddingRequest   true  "向量化请求"
+// @Success      200      {object}  EmbeddingResponse  "向量化结果"
+// @Failure      400      {object}  errors.AppError    "请求参数错误"
+// @Failure      404      {object}  errors.AppError    "模型不存在"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /v1/embeddings [post]
+func (h *Handler) Embeddings(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var request EmbeddingRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logger.Error(ctx, "Failed to parse embedding request", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+	var inputs []string
+	var input string
+	if err := json.Unmarshal(request.Input, &input); err == nil {
+		inputs = []string{input}
+	} else if err := json.Unmarshal(request.Input, &inputs); err != nil {
+		c.Error(errors.NewBadRequestError("Input must be a string or an array of strings"))
+		return
+	}
+	if len(inputs) == 0 || len(inputs) > maxEmbeddingInputs {
+		c.Error(errors.NewBadRequestError(
+			fmt.Sprintf("Input must contain between 1 and %d items", maxEmbeddingInputs)))
+		return
+	}
+	if request.EncodingFormat != "" && request.EncodingFormat != "float" && request.EncodingFormat != "base64" {
+		c.Error(errors.NewBadRequestError("Encoding format must be float or base64"))
+		return
+	}
+
+	modelID, err := h.resolveEmbeddingModel(ctx, secutils.SanitizeForLog(request.Model))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	embedder, err := h.modelService.GetEmbeddingModel(ctx, modelID)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(errors.NewInternalServerError(err.Error()))
+		return
+	}
+	vectors, err := embedder.BatchEmbed(ctx, inputs)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(errors.NewInternalServerError(err.Error()))
+		return
+	}
+	logger.Infof(ctx, "Embedded %d inputs with model %s", len(inputs), modelID)
+
+	response := EmbeddingResponse{Object: "list", Model: request.Model, Data: make([]EmbeddingData, len(vectors))}
+	for i, vector := range vectors {
+		data := EmbeddingData{Object: "embedding", Index: i, Embedding: vector}
+		if request.EncodingFormat == "base64" {
+			data.Embedding = encodeEmbedding(vector)
+		}
+		response.Data[i] = data
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// resolveEmbeddingModel returns the ID of the embedding model of a model name of the embeddings API
+func (h *Handler) resolveEmbeddingModel(ctx context.Context, model string) (string, error) {
+	if kbID, ok := strings.CutPrefix(model, openAIKBModelPrefix); ok {
+		if err := h.checkModelKnowledgeBase(ctx, kbID); err != nil {
+			return "", err
+		}
+		kb, err := h.knowledgebaseService.GetKnowledgeBaseByID(ctx, kbID)
+		if err != nil {
+			return "", errors.NewInternalServerError(err.Error())
+		}
+		if kb.EmbeddingModelID == "" {
+			return "", errors.NewBadRequestError("The knowledge base has no embedding model").WithDetails(kbID)
+		}
+		return kb.EmbeddingModelID, nil
+	}
+	models, err := h.modelService.ListModels(ctx)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		return "", errors.NewInternalServerError(err.Error())
+	}
+	for _, m := range models {
+		if m.Type == types.ModelTypeEmbedding && (m.ID == model || m.Name == model) {
+			return m.ID, nil
+		}
+	}
+	return "", errors.NewNotFoundError("Model not found").WithDetails(model)
+}
+
+// ListOpenAIModels godoc
+// @Summary      OpenAI 兼容的模型列表
+// @Description  列出可用于对话补全的知识库与 Agent 模型，以及可用于向量化的 Embedding 模型
+// @Tags         OpenAI 兼容接口
+// @Produce      json
+// @Success      200  {object}  map[string]interface{}  "模型列表"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /v1/models [get]
+func (h *Handler) ListOpenAIModels(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	kbs, err := h.readableKnowledgeBases(ctx)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	models, err := h.modelService.ListModels(ctx)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(errors.NewInternalServerError(err.Error()))
+		return
+	}
+
+	data := []OpenAIModel{{ID: openAIAgentModel, Object: "model", OwnedBy: openAIModelOwner, Name: "Agent"}}
+	for _, kb := range kbs {
+		for _, prefix := range []string{openAIKBModelPrefix, openAIAgentModelPrefix} {
+			data = append(data, OpenAIModel{
+				ID: prefix + kb.ID, Object: "model", Created: kb.CreatedAt.Unix(),
+				OwnedBy: openAIModelOwner, Name: kb.Name,
+			})
+		}
+	}
+	for _, m := range models {
+		if m.Type == types.ModelTypeEmbedding {
+			data = append(data, OpenAIModel{
+				ID: m.ID, Object: "model", Created: m.CreatedAt.Unix(), OwnedBy: openAIModelOwner, Name: m.Name,
+			})
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object": "list",
+		"data":   data,
+	})
+}
+
+// writeSSEData writes an unnamed server-sent event, the framing used by OpenAI streams
+func writeSSEData(c *gin.Context, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		logger.Errorf(c.Request.Context(), "Failed to marshal stream data: %v", err)
+		return
+	}
+	_, _ = io.WriteString(c.Writer, "data: "+string(payload)+"\n\n")
+	c.Writer.Flush()
+}
+
+// decodeReferences converts the references of a stream event, which are decoded as generic values
+// when the stream is stored in Redis
+func decodeReferences(data interface{}) types.References {
+	if refs, ok := data.(types.References); ok {
+		return refs
+	}
+	if refs, ok := data.([]*types.SearchResult); ok {
+		return refs
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil
+	}
+	var refs types.References
+	if err := json.Unmarshal(raw, &refs); err != nil {
+		return nil
+	}
+	return refs
+}
+
+// encodeEmbedding encodes a vector as base64 little-endian float32, the base64 encoding format of OpenAI
+func encodeEmbedding(vector []float32) string {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// sameStrings reports whether two string slices contain the same elements in any order
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]int, len(a))
+	for _, s := range a {
+		seen[s]++
+	}
+	for _, s := range b {
+		if seen[s] == 0 {
+			return false
+		}
+		seen[s]--
+	}
+	return true
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/Tencent/WeKnora/internal/errors"
@@ -47,6 +48,10 @@ func (h *Handler) SearchKnowledge(c *gin.Context) {
 		c.Error(errors.NewBadRequestError("Query content cannot be empty"))
 		return
 	}
+	if err := validateRetrievalFilter(request.Filter); err != nil {
+		c.Error(err)
+		return
+	}
 
 	// Merge single knowledge_base_id into knowledge_base_ids for backward compatibility
 	knowledgeBaseIDs := request.KnowledgeBaseIDs
@@ -79,7 +84,9 @@ func (h *Handler) SearchKnowledge(c *gin.Context) {
 	)
 
 	// Directly call knowledge retrieval service without LLM summarization
-	searchResults, err := h.sessionService.SearchKnowledge(ctx, knowledgeBaseIDs, request.KnowledgeIDs, request.Query)
+	searchResults, err := h.sessionService.SearchKnowledge(
+		ctx, knowledgeBaseIDs, request.KnowledgeIDs, request.Query, request.Filter,
+	)
 	if err != nil {
 		logger.ErrorWithFields(ctx, err, nil)
 		c.Error(errors.NewInternalServerError(err.Error()))
@@ -123,7 +130,8 @@ func (h *Handler) KnowledgeQA(c *gin.Context) {
 
 	// Parse request body
 	var request CreateKnowledgeQARequest
-	if err := c.ShouldBindJSON(&request); err != nil {
+	files, err := bindQARequest(c, &request)
+	if err != nil {
 		logger.Error(ctx, "Failed to parse request data", err)
 		c.Error(errors.NewBadRequestError(err.Error()))
 		return
@@ -143,6 +151,10 @@ func (h *Handler) KnowledgeQA(c *gin.Context) {
 		c.Error(errors.NewBadRequestError("Query content cannot be empty"))
 		return
 	}
+	if err := validateRetrievalFilter(request.Filter); err != nil {
+		c.Error(err)
+		return
+	}
 
 	logger.Infof(
 		ctx,
@@ -161,6 +173,14 @@ func (h *Handler) KnowledgeQA(c *gin.Context) {
 
 	// Prepare knowledge base IDs
 	knowledgeBaseIDs := request.KnowledgeBaseIDs
+
+	// Store the attached images and documents, the VLM model of the knowledge bases captions the images
+	attachments, err := h.attachmentService.PrepareAttachments(ctx, sessionID, knowledgeBaseIDs, files)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
 	// if len(knowledgeBaseIDs) == 0 && session.KnowledgeBaseID != "" {
 	// 	knowledgeBaseIDs = []string{session.KnowledgeBaseID}
 	// 	logger.Infof(
@@ -173,9 +193,9 @@ func (h *Handler) KnowledgeQA(c *gin.Context) {
 	// Use shared function to handle KnowledgeQA request
 	h.handleKnowledgeQARequest(ctx, c, session, secutils.SanitizeForLog(request.Query),
 		secutils.SanitizeForLogArray(knowledgeBaseIDs),
-		secutils.SanitizeForLogArray(request.KnowledgeIds),
+		secutils.SanitizeForLogArray(request.KnowledgeIds), request.Filter,
 		assistantMessage, true, secutils.SanitizeForLog(request.SummaryModelID), request.WebSearchEnabled,
-		convertMentionedItems(request.MentionedItems))
+		convertMentionedItems(request.MentionedItems), attachments)
 }
 
 // AgentQA godoc
@@ -205,7 +225,8 @@ func (h *Handler) AgentQA(c *gin.Context) {
 
 	// Parse request body
 	var request CreateKnowledgeQARequest
-	if err := c.ShouldBindJSON(&request); err != nil {
+	files, err := bindQARequest(c, &request)
+	if err != nil {
 		logger.Error(ctx, "Failed to parse request data", err)
 		c.Error(errors.NewBadRequestError(err.Error()))
 		return
@@ -222,6 +243,10 @@ func (h *Handler) AgentQA(c *gin.Context) {
 		c.Error(errors.NewBadRequestError("Query content cannot be empty"))
 		return
 	}
+	if err := validateRetrievalFilter(request.Filter); err != nil {
+		c.Error(err)
+		return
+	}
 
 	tenantInfo := ctx.Value(types.TenantInfoContextKey).(*types.Tenant)
 
@@ -365,6 +390,18 @@ func (h *Handler) AgentQA(c *gin.Context) {
 		logger.Infof(ctx, "Session configuration updated successfully for session: %s", sessionID)
 	}
 
+	// Store the attached images and documents, the VLM model of the knowledge bases captions the images
+	captionKnowledgeBaseIDs := session.AgentConfig.KnowledgeBases
+	if len(captionKnowledgeBaseIDs) == 0 && session.KnowledgeBaseID != "" {
+		captionKnowledgeBaseIDs = []string{session.KnowledgeBaseID}
+	}
+	attachments, err := h.attachmentService.PrepareAttachments(ctx, sessionID, captionKnowledgeBaseIDs, files)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+
 	// If Agent mode is disabled, delegate to KnowledgeQA
 	if !request.AgentEnabled {
 		logger.Infof(ctx, "Agent mode disabled, delegating to KnowledgeQA for session: %s", sessionID)
@@ -403,47 +440,71 @@ func (h *Handler) AgentQA(c *gin.Context) {
 			secutils.SanitizeForLogArray(
 				request.KnowledgeIds,
 			),
+			request.Filter,
 			assistantMessage,
 			false,
 			secutils.SanitizeForLog(request.SummaryModelID),
 			request.WebSearchEnabled,
 			convertMentionedItems(request.MentionedItems),
+			attachments,
 		)
 		return
 	}
 
-	// Emit agent query event to create user message
 	requestID := secutils.SanitizeForLog(c.GetString(types.RequestIDContextKey.String()))
+	eventBus, err := h.startAgentQA(ctx, session, secutils.SanitizeForLog(request.Query), request.Filter,
+		assistantMessage, requestID, convertMentionedItems(request.MentionedItems), attachments)
+	if err != nil {
+		c.Error(errors.NewInternalServerError(err.Error()))
+		return
+	}
+
+	// Set headers for SSE
+	setSSEHeaders(c)
+
+	// Handle events for SSE (blocking until connection is done)
+	// Wait for title only if session has no title (first message in session)
+	h.handleAgentEventsForSSE(ctx, c, session.ID, assistantMessage.ID, requestID, eventBus, session.Title == "")
+}
+
+// startAgentQA stores the messages of an agent request and runs AgentQA in the background.
+// The answer is published to the StreamManager under the assistant message.
+func (h *Handler) startAgentQA(
+	ctx context.Context,
+	session *types.Session,
+	query string,
+	filter *types.RetrievalFilter,
+	assistantMessage *types.Message,
+	requestID string,
+	mentionedItems types.MentionedItems,
+	attachments types.MessageAttachments,
+) (*event.EventBus, error) {
+	sessionID := session.ID
+
+	// Emit agent query event to create user message
 	if err := event.Emit(ctx, event.Event{
 		Type:      event.EventAgentQuery,
 		SessionID: sessionID,
 		RequestID: requestID,
 		Data: event.AgentQueryData{
 			SessionID: sessionID,
-			Query:     secutils.SanitizeForLog(request.Query),
+			Query:     query,
 			RequestID: requestID,
 		},
 	}); err != nil {
 		logger.Errorf(ctx, "Failed to emit agent query event: %v", err)
-		return
+		return nil, err
 	}
 
-	// Set headers for SSE immediately
-	setSSEHeaders(c)
-
 	// Create user message
-	if err := h.createUserMessage(ctx, sessionID, secutils.SanitizeForLog(request.Query), requestID, convertMentionedItems(request.MentionedItems)); err != nil {
-		c.Error(errors.NewInternalServerError(err.Error()))
-		return
+	if err := h.createUserMessage(ctx, sessionID, query, requestID, mentionedItems, attachments); err != nil {
+		return nil, err
 	}
 
 	// Create assistant message (response)
-	assistantMessagePtr, err := h.createAssistantMessage(ctx, assistantMessage)
-	if err != nil {
-		c.Error(errors.NewInternalServerError(err.Error()))
-		return
+	if _, err := h.createAssistantMessage(ctx, assistantMessage); err != nil {
+		return nil, err
 	}
-	assistantMessage = assistantMessagePtr
 
 	logger.Infof(ctx, "Calling agent QA service, session ID: %s", sessionID)
 
@@ -459,11 +520,12 @@ func (h *Handler) AgentQA(c *gin.Context) {
 	// Start async title generation if session has no title
 	if session.Title == "" {
 		logger.Infof(ctx, "Session has no title, starting async title generation, session ID: %s", sessionID)
-		h.sessionService.GenerateTitleAsync(asyncCtx, session, secutils.SanitizeForLog(request.Query), eventBus)
+		h.sessionService.GenerateTitleAsync(asyncCtx, session, query, eventBus)
 	}
 
 	// Register stop event handler to cancel the context
-	h.setupStopEventHandler(eventBus, sessionID, assistantMessage, cancel)
+	completed := &sync.Once{}
+	h.setupStopEventHandler(eventBus, sessionID, assistantMessage, cancel, completed)
 
 	go func() {
 		defer func() {
@@ -476,13 +538,15 @@ func (h *Handler) AgentQA(c *gin.Context) {
 						"session_id": sessionID,
 					})
 			}
-			h.completeAssistantMessage(asyncCtx, assistantMessage)
+			h.completeAssistantMessage(asyncCtx, assistantMessage, completed)
 			logger.Infof(asyncCtx, "Agent QA service completed for session: %s", sessionID)
 		}()
 		err := h.sessionService.AgentQA(
 			asyncCtx,
 			session,
-			secutils.SanitizeForLog(request.Query),
+			query,
+			filter,
+			attachments,
 			assistantMessage.ID,
 			eventBus,
 		)
@@ -501,10 +565,7 @@ func (h *Handler) AgentQA(c *gin.Context) {
 			return
 		}
 	}()
-
-	// Handle events for SSE (blocking until connection is done)
-	// Wait for title only if session has no title (first message in session)
-	h.handleAgentEventsForSSE(ctx, c, sessionID, assistantMessage.ID, requestID, eventBus, session.Title == "")
+	return eventBus, nil
 }
 
 // handleKnowledgeQARequest handles a KnowledgeQA request with the given parameters
@@ -516,32 +577,61 @@ func (h *Handler) handleKnowledgeQARequest(
 	query string,
 	knowledgeBaseIDs []string,
 	knowledgeIDs []string,
+	filter *types.RetrievalFilter, // Optional filter on the searched documents
 	assistantMessage *types.Message,
 	generateTitle bool, // Whether to generate title if session has no title
 	summaryModelID string, // Optional summary model ID (overrides session default)
 	webSearchEnabled bool, // Whether web search is enabled
 	mentionedItems types.MentionedItems, // @mentioned knowledge bases and files
+	attachments types.MessageAttachments, // Images and documents attached to the question
 ) {
-	sessionID := session.ID
 	requestID := getRequestID(c)
-
-	// Create user message
-	if err := h.createUserMessage(ctx, sessionID, query, requestID, mentionedItems); err != nil {
+	eventBus, err := h.startKnowledgeQA(ctx, session, query, knowledgeBaseIDs, knowledgeIDs, filter, assistantMessage,
+		requestID, generateTitle, summaryModelID, webSearchEnabled, mentionedItems, attachments)
+	if err != nil {
 		c.Error(errors.NewInternalServerError(err.Error()))
 		return
 	}
 
+	// Set headers for SSE
+	setSSEHeaders(c)
+
+	// Handle events for SSE (blocking until connection is done)
+	// Wait for title only if session has no title (first message in session)
+	h.handleAgentEventsForSSE(ctx, c, session.ID, assistantMessage.ID, requestID, eventBus, session.Title == "")
+}
+
+// startKnowledgeQA stores the messages of a KnowledgeQA request and runs KnowledgeQA in the background.
+// The answer is published to the StreamManager under the assistant message.
+func (h *Handler) startKnowledgeQA(
+	ctx context.Context,
+	session *types.Session,
+	query string,
+	knowledgeBaseIDs []string,
+	knowledgeIDs []string,
+	filter *types.RetrievalFilter,
+	assistantMessage *types.Message,
+	requestID string,
+	generateTitle bool,
+	summaryModelID string,
+	webSearchEnabled bool,
+	mentionedItems types.MentionedItems,
+	attachments types.MessageAttachments,
+) (*event.EventBus, error) {
+	sessionID := session.ID
+
+	// Create user message
+	if err := h.createUserMessage(ctx, sessionID, query, requestID, mentionedItems, attachments); err != nil {
+		return nil, err
+	}
+
 	// Create assistant message (response)
 	if _, err := h.createAssistantMessage(ctx, assistantMessage); err != nil {
-		c.Error(errors.NewInternalServerError(err.Error()))
-		return
+		return nil, err
 	}
 
 	logger.Infof(ctx, "Using knowledge bases: %s", secutils.SanitizeForLog(fmt.Sprintf("%v", knowledgeBaseIDs)))
 
-	// Set headers for SSE
-	setSSEHeaders(c)
-
 	// Write initial agent_query event to StreamManager
 	h.writeAgentQueryEvent(ctx, sessionID, assistantMessage.ID)
 
@@ -551,7 +641,8 @@ func (h *Handler) handleKnowledgeQARequest(
 	asyncCtx, cancel := context.WithCancel(logger.CloneContext(ctx))
 
 	// Register stop event handler and setup stream handler
-	h.setupStopEventHandler(eventBus, sessionID, assistantMessage, cancel)
+	completed := &sync.Once{}
+	h.setupStopEventHandler(eventBus, sessionID, assistantMessage, cancel, completed)
 	h.setupStreamHandler(asyncCtx, sessionID, assistantMessage.ID, requestID, assistantMessage, eventBus)
 
 	// Generate title if needed
@@ -568,7 +659,7 @@ func (h *Handler) handleKnowledgeQARequest(
 		assistantMessage.Content += data.Content
 		if data.Done {
 			logger.Infof(asyncCtx, "Knowledge QA service completed for session: %s", sessionID)
-			h.completeAssistantMessage(asyncCtx, assistantMessage)
+			h.completeAssistantMessage(asyncCtx, assistantMessage, completed)
 			// Emit completion event when stream finishes
 			if err := eventBus.Emit(asyncCtx, event.Event{
 				Type:      event.EventAgentComplete,
@@ -604,9 +695,11 @@ func (h *Handler) handleKnowledgeQARequest(
 			query,
 			knowledgeBaseIDs,
 			knowledgeIDs,
+			filter,
 			assistantMessage.ID,
 			summaryModelID,
 			webSearchEnabled,
+			attachments,
 			eventBus,
 		)
 		if err != nil {
@@ -625,14 +718,29 @@ func (h *Handler) handleKnowledgeQARequest(
 		}
 	}()
 
-	// Handle events for SSE (blocking until connection is done)
-	// Wait for title only if session has no title (first message in session)
-	h.handleAgentEventsForSSE(ctx, c, sessionID, assistantMessage.ID, requestID, eventBus, session.Title == "")
+	return eventBus, nil
 }
 
-// completeAssistantMessage marks an assistant message as complete and updates it
-func (h *Handler) completeAssistantMessage(ctx context.Context, assistantMessage *types.Message) {
+// completeAssistantMessage marks an assistant message as complete and updates it.
+// It may be called again when the user stops the generation, completed publishes the completion only once.
+func (h *Handler) completeAssistantMessage(ctx context.Context, assistantMessage *types.Message, completed *sync.Once) {
 	assistantMessage.UpdatedAt = time.Now()
 	assistantMessage.IsCompleted = true
 	_ = h.messageService.UpdateMessage(ctx, assistantMessage)
+	completed.Do(func() {
+		if err := event.Emit(ctx, event.Event{
+			Type:      event.EventMessageCompleted,
+			SessionID: assistantMessage.SessionID,
+			RequestID: assistantMessage.RequestID,
+			Data: event.MessageCompletedData{
+				SessionID:      assistantMessage.SessionID,
+				MessageID:      assistantMessage.ID,
+				RequestID:      assistantMessage.RequestID,
+				Content:        assistantMessage.Content,
+				ReferenceCount: len(assistantMessage.KnowledgeReferences),
+			},
+		}); err != nil {
+			logger.Warnf(ctx, "Failed to publish message completed event: %v", err)
+		}
+	})
 }
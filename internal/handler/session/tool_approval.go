@@ -0,0 +1,129 @@
+package session
+
+import (
+	"net/http"
+
+	"github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	secutils "github.com/Tencent/WeKnora/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ListToolApprovals godoc
+// @Summary      获取工具调用审批列表
+// @Description  获取会话中 Agent 等待或已完成审批的工具调用，按创建时间倒序排列
+// @Tags         会话
+// @Accept       json
+// @Produce      json
+// @Param        session_id  path      string  true   "会话ID"
+// @Param        status      query     string  false  "审批状态：pending、approved、rejected、expired"
+// @Success      200         {object}  map[string]interface{}  "工具调用审批列表"
+// @Failure      400         {object}  errors.AppError         "请求参数错误"
+// @Failure      404         {object}  errors.AppError         "会话不存在"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /sessions/{session_id}/tool-approvals [get]
+func (h *Handler) ListToolApprovals(c *gin.Context) {
+	ctx := c.Request.Context()
+	sessionID := secutils.SanitizeForLog(c.Param("session_id"))
+
+	status := types.ToolApprovalStatus(c.Query("status"))
+	switch status {
+	case "", types.ToolApprovalPending, types.ToolApprovalApproved,
+		types.ToolApprovalRejected, types.ToolApprovalExpired:
+	default:
+		c.Error(errors.NewBadRequestError("Invalid tool approval status"))
+		return
+	}
+
+	if !h.checkSessionForToolApproval(c, sessionID) {
+		return
+	}
+
+	approvals, err := h.toolApprovalService.ListToolApprovals(ctx, sessionID, status)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, map[string]interface{}{
+			"session_id": sessionID,
+		})
+		c.Error(errors.NewInternalServerError(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    approvals,
+	})
+}
+
+// DecideToolApproval godoc
+// @Summary      审批工具调用
+// @Description  批准或拒绝 Agent 等待审批的工具调用，批准后 Agent 执行该工具，拒绝后 Agent 跳过该工具并继续回答
+// @Tags         会话
+// @Accept       json
+// @Produce      json
+// @Param        session_id   path      string                             true  "会话ID"
+// @Param        approval_id  path      string                             true  "审批ID"
+// @Param        request      body      types.ToolApprovalDecisionRequest  true  "审批决定"
+// @Success      200          {object}  map[string]interface{}  "审批后的工具调用"
+// @Failure      400          {object}  errors.AppError         "请求参数错误"
+// @Failure      404          {object}  errors.AppError         "会话或审批不存在"
+// @Failure      409          {object}  errors.AppError         "审批已处理"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /sessions/{session_id}/tool-approvals/{approval_id} [post]
+func (h *Handler) DecideToolApproval(c *gin.Context) {
+	ctx := c.Request.Context()
+	sessionID := secutils.SanitizeForLog(c.Param("session_id"))
+	approvalID := secutils.SanitizeForLog(c.Param("approval_id"))
+
+	var req types.ToolApprovalDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error(ctx, "Failed to parse tool approval decision", err)
+		c.Error(errors.NewBadRequestError(err.Error()))
+		return
+	}
+
+	if !h.checkSessionForToolApproval(c, sessionID) {
+		return
+	}
+
+	approval, err := h.toolApprovalService.DecideToolApproval(ctx, sessionID, approvalID, &req)
+	if err != nil {
+		if appErr, ok := errors.IsAppError(err); ok {
+			c.Error(appErr)
+			return
+		}
+		logger.ErrorWithFields(ctx, err, map[string]interface{}{
+			"session_id":  sessionID,
+			"approval_id": approvalID,
+		})
+		c.Error(errors.NewInternalServerError(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    approval,
+	})
+}
+
+// checkSessionForToolApproval verifies the session exists in the current tenant,
+// writing the error response and returning false otherwise
+func (h *Handler) checkSessionForToolApproval(c *gin.Context, sessionID string) bool {
+	ctx := c.Request.Context()
+	if sessionID == "" {
+		c.Error(errors.NewBadRequestError(errors.ErrInvalidSessionID.Error()))
+		return false
+	}
+	if _, err := h.sessionService.GetSession(ctx, sessionID); err != nil {
+		if err == errors.ErrSessionNotFound {
+			c.Error(errors.NewNotFoundError(err.Error()))
+		} else {
+			logger.ErrorWithFields(ctx, err, nil)
+			c.Error(errors.NewInternalServerError(err.Error()))
+		}
+		return false
+	}
+	return true
+}
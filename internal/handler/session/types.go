@@ -1,6 +1,9 @@
 package session
 
 import (
+	"encoding/json"
+	"strings"
+
 	"github.com/Tencent/WeKnora/internal/types"
 )
 
@@ -68,6 +71,8 @@ type CreateKnowledgeQARequest struct {
 	WebSearchEnabled bool                   `json:"web_search_enabled"`                    // Whether web search is enabled for this request
 	SummaryModelID   string                 `json:"summary_model_id"`                      // Optional summary model ID for this request (overrides session default)
 	MentionedItems   []MentionedItemRequest `json:"mentioned_items"`                       // @mentioned knowledge bases and files
+	// Filter narrows the search to the documents whose tags, file type, creation time or metadata match
+	Filter *types.RetrievalFilter `json:"filter"`
 }
 
 // SearchKnowledgeRequest defines the request structure for searching knowledge without LLM summarization
@@ -76,9 +81,124 @@ type SearchKnowledgeRequest struct {
 	KnowledgeBaseID  string   `json:"knowledge_base_id"`                     // Single knowledge base ID (for backward compatibility)
 	KnowledgeBaseIDs []string `json:"knowledge_base_ids"`                    // IDs of knowledge bases to search (multi-KB support)
 	KnowledgeIDs     []string `json:"knowledge_ids"`                         // IDs of specific knowledge (files) to search
+	// Filter narrows the search to the documents whose tags, file type, creation time or metadata match
+	Filter *types.RetrievalFilter `json:"filter"`
 }
 
 // StopSessionRequest represents the stop session request
 type StopSessionRequest struct {
 	MessageID string `json:"message_id" binding:"required"`
 }
+
+// ChatCompletionRequest defines the request structure of the OpenAI compatible chat completions API
+type ChatCompletionRequest struct {
+	// Model is kb:<knowledge base ID> for knowledge QA, agent or agent:<knowledge base ID> for agent QA
+	Model    string                  `json:"model"      binding:"required"`
+	Messages []ChatCompletionMessage `json:"messages"   binding:"required"`
+	Stream   bool                    `json:"stream"`
+	// SessionID continues an existing session instead of starting a new one (WeKnora extension)
+	SessionID string `json:"session_id"`
+}
+
+// ChatCompletionMessage is a message in the OpenAI chat format,
+// its content is either a string or an array of content parts
+type ChatCompletionMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// Text returns the text of the message, the text parts are joined when the content is an array
+func (m ChatCompletionMessage) Text() string {
+	var text string
+	if err := json.Unmarshal(m.Content, &text); err == nil {
+		return text
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return ""
+	}
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// ChatCompletionResponse is a chat completion, or a chunk of it when streaming
+type ChatCompletionResponse struct {
+	ID      string                   `json:"id"`
+	Object  string                   `json:"object"`
+	Created int64                    `json:"created"`
+	Model   string                   `json:"model"`
+	Choices []ChatCompletionChoice   `json:"choices"`
+	WeKnora *ChatCompletionExtension `json:"weknora,omitempty"`
+}
+
+// ChatCompletionChoice is the single choice of a chat completion,
+// Message is set for complete responses and Delta for streamed chunks
+type ChatCompletionChoice struct {
+	Index        int                   `json:"index"`
+	Message      *ChatCompletionAnswer `json:"message,omitempty"`
+	Delta        *ChatCompletionDelta  `json:"delta,omitempty"`
+	FinishReason *string               `json:"finish_reason"`
+}
+
+// ChatCompletionAnswer is the assistant message of a chat completion
+type ChatCompletionAnswer struct {
+	Role             string `json:"role"`
+	Content          string `json:"content"`
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+}
+
+// ChatCompletionDelta is a part of the assistant message of a streamed chat completion
+type ChatCompletionDelta struct {
+	Role             string `json:"role,omitempty"`
+	Content          string `json:"content,omitempty"`
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+}
+
+// ChatCompletionExtension carries the WeKnora session of a chat completion and the references of its answer
+type ChatCompletionExtension struct {
+	SessionID  string           `json:"session_id"`
+	MessageID  string           `json:"message_id"`
+	References types.References `json:"references,omitempty"`
+}
+
+// EmbeddingRequest defines the request structure of the OpenAI compatible embeddings API
+type EmbeddingRequest struct {
+	// Model is the ID or name of an embedding model, or kb:<knowledge base ID> for the model of a knowledge base
+	Model string `json:"model"           binding:"required"`
+	// Input is a string or an array of strings
+	Input json.RawMessage `json:"input"           binding:"required"`
+	// EncodingFormat is float (default) or base64
+	EncodingFormat string `json:"encoding_format"`
+}
+
+// EmbeddingResponse is the response of the embeddings API
+type EmbeddingResponse struct {
+	Object string          `json:"object"`
+	Data   []EmbeddingData `json:"data"`
+	Model  string          `json:"model"`
+}
+
+// EmbeddingData is the embedding of an input, a float array or a base64 string of little-endian float32
+type EmbeddingData struct {
+	Object    string      `json:"object"`
+	Index     int         `json:"index"`
+	Embedding interface{} `json:"embedding"`
+}
+
+// OpenAIModel is a model listed by the OpenAI compatible models API
+type OpenAIModel struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+	// Name is the name of the knowledge base or model behind the ID (WeKnora extension)
+	Name string `json:"name"`
+}
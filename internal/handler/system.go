@@ -98,7 +98,7 @@ func (h *SystemHandler) getKeywordIndexEngine() string {
 	keywordEngines := []string{}
 	for _, driver := range drivers {
 		driver = strings.TrimSpace(driver)
-		if driver == "postgres" || driver == "elasticsearch_v7" || driver == "elasticsearch_v8" {
+		if driver == "postgres" || driver == "elasticsearch_v7" || driver == "elasticsearch_v8" || driver == "local" {
 			keywordEngines = append(keywordEngines, driver)
 		}
 	}
@@ -127,7 +127,7 @@ func (h *SystemHandler) getVectorStoreEngine() string {
 	vectorEngines := []string{}
 	for _, driver := range drivers {
 		driver = strings.TrimSpace(driver)
-		if driver == "postgres" || driver == "elasticsearch_v8" {
+		if driver == "postgres" || driver == "elasticsearch_v8" || driver == "local" {
 			vectorEngines = append(vectorEngines, driver)
 		}
 	}
@@ -45,6 +45,9 @@ func NewTagHandler(tagService interfaces.KnowledgeTagService) *TagHandler {
 func (h *TagHandler) ListTags(c *gin.Context) {
 	ctx := c.Request.Context()
 	kbID := secutils.SanitizeForLog(c.Param("id"))
+	if !requireKnowledgeBasePermission(c, kbID, types.KBPermissionRead) {
+		return
+	}
 
 	var page types.Pagination
 	if err := c.ShouldBindQuery(&page); err != nil {
@@ -90,6 +93,9 @@ type createTagRequest struct {
 func (h *TagHandler) CreateTag(c *gin.Context) {
 	ctx := c.Request.Context()
 	kbID := secutils.SanitizeForLog(c.Param("id"))
+	if !requireKnowledgeBasePermission(c, kbID, types.KBPermissionWrite) {
+		return
+	}
 
 	var req createTagRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -136,6 +142,9 @@ type updateTagRequest struct {
 // @Router       /knowledge-bases/{id}/tags/{tag_id} [put]
 func (h *TagHandler) UpdateTag(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireKnowledgeBasePermission(c, secutils.SanitizeForLog(c.Param("id")), types.KBPermissionWrite) {
+		return
+	}
 
 	tagID := secutils.SanitizeForLog(c.Param("tag_id"))
 	var req updateTagRequest
@@ -178,6 +187,9 @@ func (h *TagHandler) UpdateTag(c *gin.Context) {
 // @Router       /knowledge-bases/{id}/tags/{tag_id} [delete]
 func (h *TagHandler) DeleteTag(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireKnowledgeBasePermission(c, secutils.SanitizeForLog(c.Param("id")), types.KBPermissionWrite) {
+		return
+	}
 	tagID := secutils.SanitizeForLog(c.Param("tag_id"))
 
 	force := c.Query("force") == "true"
@@ -1,13 +1,18 @@
 package handler
 
 import (
+	"fmt"
 	"net/http"
+	"path"
+	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/Tencent/WeKnora/internal/agent"
 	agenttools "github.com/Tencent/WeKnora/internal/agent/tools"
+	chatpipline "github.com/Tencent/WeKnora/internal/application/service/chat_pipline"
 	"github.com/Tencent/WeKnora/internal/config"
 	"github.com/Tencent/WeKnora/internal/errors"
 	"github.com/Tencent/WeKnora/internal/logger"
@@ -20,9 +25,10 @@ import (
 // Provides functionality for creating, retrieving, updating, and deleting tenants
 // through the REST API endpoints
 type TenantHandler struct {
-	service     interfaces.TenantService
-	userService interfaces.UserService
-	config      *config.Config
+	service      interfaces.TenantService
+	userService  interfaces.UserService
+	config       *config.Config
+	eventManager *chatpipline.EventManager
 }
 
 // NewTenantHandler creates a new tenant handler instance with the provided service
@@ -30,13 +36,17 @@ type TenantHandler struct {
 //   - service: An implementation of the TenantService interface for business logic
 //   - userService: An implementation of the UserService interface for user operations
 //   - config: Application configuration
+//   - eventManager: Chat pipeline event manager validating the declared pipelines
 //
 // Returns a pointer to the newly created TenantHandler
-func NewTenantHandler(service interfaces.TenantService, userService interfaces.UserService, config *config.Config) *TenantHandler {
+func NewTenantHandler(service interfaces.TenantService, userService interfaces.UserService, config *config.Config,
+	eventManager *chatpipline.EventManager,
+) *TenantHandler {
 	return &TenantHandler{
-		service:     service,
-		userService: userService,
-		config:      config,
+		service:      service,
+		userService:  userService,
+		config:       config,
+		eventManager: eventManager,
 	}
 }
 
@@ -147,6 +157,9 @@ func (h *TenantHandler) GetTenant(c *gin.Context) {
 // @Router       /tenants/{id} [put]
 func (h *TenantHandler) UpdateTenant(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
 
 	logger.Info(ctx, "Start updating tenant")
 
@@ -166,6 +179,10 @@ func (h *TenantHandler) UpdateTenant(c *gin.Context) {
 
 	logger.Infof(ctx, "Updating tenant, ID: %d, Name: %s", id, secutils.SanitizeForLog(tenantData.Name))
 
+	before, err := h.service.GetTenantByID(ctx, id)
+	if err != nil {
+		logger.Warnf(ctx, "Failed to get tenant %d before update: %v", id, err)
+	}
 	tenantData.ID = id
 	updatedTenant, err := h.service.UpdateTenant(ctx, &tenantData)
 	if err != nil {
@@ -180,6 +197,8 @@ func (h *TenantHandler) UpdateTenant(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, before, updatedTenant)
+
 	logger.Infof(
 		ctx,
 		"Tenant updated successfully, ID: %d, Name: %s",
@@ -205,6 +224,9 @@ func (h *TenantHandler) UpdateTenant(c *gin.Context) {
 // @Router       /tenants/{id} [delete]
 func (h *TenantHandler) DeleteTenant(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
 
 	logger.Info(ctx, "Start deleting tenant")
 
@@ -217,6 +239,10 @@ func (h *TenantHandler) DeleteTenant(c *gin.Context) {
 
 	logger.Infof(ctx, "Deleting tenant, ID: %d", id)
 
+	before, err := h.service.GetTenantByID(ctx, id)
+	if err != nil {
+		logger.Warnf(ctx, "Failed to get tenant %d before deletion: %v", id, err)
+	}
 	if err := h.service.DeleteTenant(ctx, id); err != nil {
 		// Check if this is an application-specific error
 		if appErr, ok := errors.IsAppError(err); ok {
@@ -229,6 +255,8 @@ func (h *TenantHandler) DeleteTenant(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, before, nil)
+
 	logger.Infof(ctx, "Tenant deleted successfully, ID: %d", id)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -417,6 +445,9 @@ func (h *TenantHandler) SearchTenants(c *gin.Context) {
 	})
 }
 
+// agentProfileNamePattern matches the valid names of agent profiles
+var agentProfileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
 // AgentConfigRequest represents the request body for updating agent configuration
 type AgentConfigRequest struct {
 	MaxIterations           int      `json:"max_iterations"`
@@ -426,6 +457,16 @@ type AgentConfigRequest struct {
 	SystemPromptWebEnabled  string   `json:"system_prompt_web_enabled,omitempty"`
 	SystemPromptWebDisabled string   `json:"system_prompt_web_disabled,omitempty"`
 	UseCustomPrompt         *bool    `json:"use_custom_system_prompt"`
+	// Profiles replaces the agent profiles when set, the existing profiles are kept when omitted
+	Profiles *[]types.AgentProfile `json:"profiles,omitempty"`
+	// ToolPolicies replaces the tool policies when set, the existing policies are kept when omitted
+	ToolPolicies map[string]types.ToolPolicy `json:"tool_policies,omitempty"`
+	// ToolApprovalTimeout is the approval timeout in seconds, the existing timeout is kept when omitted
+	ToolApprovalTimeout *int `json:"tool_approval_timeout,omitempty"`
+	// MemoryEnabled switches the long-term user memories, the existing setting is kept when omitted
+	MemoryEnabled *bool `json:"memory_enabled,omitempty"`
+	// MemoryEmbeddingModelID is the embedding model of the memories, the existing model is kept when omitted
+	MemoryEmbeddingModelID *string `json:"memory_embedding_model_id,omitempty"`
 }
 
 // GetTenantAgentConfig godoc
@@ -480,6 +521,11 @@ func (h *TenantHandler) GetTenantAgentConfig(c *gin.Context) {
 				"system_prompt_web_enabled":  agent.ProgressiveRAGSystemPromptWithWeb,
 				"system_prompt_web_disabled": agent.ProgressiveRAGSystemPromptWithoutWeb,
 				"use_custom_system_prompt":   false,
+				"profiles":                   []types.AgentProfile{},
+				"tool_policies":              map[string]types.ToolPolicy{},
+				"tool_approval_timeout":      0,
+				"memory_enabled":             false,
+				"memory_embedding_model_id":  "",
 				"available_tools":            availableTools,
 				"available_placeholders":     availablePlaceholders,
 			},
@@ -498,6 +544,14 @@ func (h *TenantHandler) GetTenantAgentConfig(c *gin.Context) {
 	}
 
 	useCustomPrompt := tenant.AgentConfig.UseCustomSystemPrompt
+	profiles := tenant.AgentConfig.Profiles
+	if profiles == nil {
+		profiles = []types.AgentProfile{}
+	}
+	toolPolicies := tenant.AgentConfig.ToolPolicies
+	if toolPolicies == nil {
+		toolPolicies = map[string]types.ToolPolicy{}
+	}
 
 	logger.Infof(ctx, "Retrieved tenant agent config successfully, Tenant ID: %d", tenant.ID)
 	c.JSON(http.StatusOK, gin.H{
@@ -510,6 +564,11 @@ func (h *TenantHandler) GetTenantAgentConfig(c *gin.Context) {
 			"system_prompt_web_enabled":  systemPromptWithWeb,
 			"system_prompt_web_disabled": systemPromptWithoutWeb,
 			"use_custom_system_prompt":   useCustomPrompt,
+			"profiles":                   profiles,
+			"tool_policies":              toolPolicies,
+			"tool_approval_timeout":      tenant.AgentConfig.ToolApprovalTimeout,
+			"memory_enabled":             tenant.AgentConfig.MemoryEnabled,
+			"memory_embedding_model_id":  tenant.AgentConfig.MemoryEmbeddingModelID,
 			"available_tools":            availableTools,
 			"available_placeholders":     availablePlaceholders,
 		},
@@ -537,6 +596,21 @@ func (h *TenantHandler) updateTenantAgentConfigInternal(c *gin.Context) {
 		c.Error(errors.NewAgentInvalidTemperatureError())
 		return
 	}
+	if req.Profiles != nil {
+		if err := validateAgentProfiles(*req.Profiles); err != nil {
+			c.Error(errors.NewValidationError("Invalid agent profiles").WithDetails(err.Error()))
+			return
+		}
+	}
+	if err := validateToolPolicies(req.ToolPolicies); err != nil {
+		c.Error(errors.NewValidationError("Invalid tool policies").WithDetails(err.Error()))
+		return
+	}
+	if req.ToolApprovalTimeout != nil && (*req.ToolApprovalTimeout < 0 || *req.ToolApprovalTimeout > 3600) {
+		c.Error(errors.NewValidationError("Invalid tool approval timeout").
+			WithDetails("tool_approval_timeout must be between 0 and 3600 seconds"))
+		return
+	}
 
 	// Get existing tenant
 	tenant := ctx.Value(types.TenantInfoContextKey).(*types.Tenant)
@@ -546,13 +620,39 @@ func (h *TenantHandler) updateTenantAgentConfigInternal(c *gin.Context) {
 		return
 	}
 	// Update agent configuration
+	before := tenant.AgentConfig
 	useCustomPrompt := false
+	var profiles []types.AgentProfile
+	var toolPolicies map[string]types.ToolPolicy
+	toolApprovalTimeout := 0
+	memoryEnabled := false
+	memoryEmbeddingModelID := ""
 	if tenant.AgentConfig != nil {
 		useCustomPrompt = tenant.AgentConfig.UseCustomSystemPrompt
+		profiles = tenant.AgentConfig.Profiles
+		toolPolicies = tenant.AgentConfig.ToolPolicies
+		toolApprovalTimeout = tenant.AgentConfig.ToolApprovalTimeout
+		memoryEnabled = tenant.AgentConfig.MemoryEnabled
+		memoryEmbeddingModelID = tenant.AgentConfig.MemoryEmbeddingModelID
 	}
 	if req.UseCustomPrompt != nil {
 		useCustomPrompt = *req.UseCustomPrompt
 	}
+	if req.Profiles != nil {
+		profiles = *req.Profiles
+	}
+	if req.ToolPolicies != nil {
+		toolPolicies = req.ToolPolicies
+	}
+	if req.ToolApprovalTimeout != nil {
+		toolApprovalTimeout = *req.ToolApprovalTimeout
+	}
+	if req.MemoryEnabled != nil {
+		memoryEnabled = *req.MemoryEnabled
+	}
+	if req.MemoryEmbeddingModelID != nil {
+		memoryEmbeddingModelID = strings.TrimSpace(*req.MemoryEmbeddingModelID)
+	}
 
 	tenant.AgentConfig = &types.AgentConfig{
 		MaxIterations:           req.MaxIterations,
@@ -562,6 +662,11 @@ func (h *TenantHandler) updateTenantAgentConfigInternal(c *gin.Context) {
 		SystemPromptWebEnabled:  req.SystemPromptWebEnabled,
 		SystemPromptWebDisabled: req.SystemPromptWebDisabled,
 		UseCustomSystemPrompt:   useCustomPrompt,
+		Profiles:                profiles,
+		ToolPolicies:            toolPolicies,
+		ToolApprovalTimeout:     toolApprovalTimeout,
+		MemoryEnabled:           memoryEnabled,
+		MemoryEmbeddingModelID:  memoryEmbeddingModelID,
 	}
 
 	updatedTenant, err := h.service.UpdateTenant(ctx, tenant)
@@ -576,6 +681,8 @@ func (h *TenantHandler) updateTenantAgentConfigInternal(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, before, updatedTenant.AgentConfig)
+
 	logger.Infof(ctx, "Tenant agent config updated successfully, Tenant ID: %d", tenant.ID)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -584,9 +691,58 @@ func (h *TenantHandler) updateTenantAgentConfigInternal(c *gin.Context) {
 	})
 }
 
+// validateAgentProfiles validates the agent profiles of a tenant.
+// The knowledge bases of the profiles are checked when the sub-agents run,
+// the knowledge bases of other tenants or which the caller cannot read are skipped.
+func validateAgentProfiles(profiles []types.AgentProfile) error {
+	allowedTools := map[string]bool{"web_search": true, "web_fetch": true}
+	for _, t := range agenttools.AvailableToolDefinitions() {
+		allowedTools[t.Name] = true
+	}
+	names := make(map[string]bool, len(profiles))
+	for _, profile := range profiles {
+		if !agentProfileNamePattern.MatchString(profile.Name) {
+			return fmt.Errorf("invalid agent name %q: use 1-64 letters, digits, '_' or '-'", profile.Name)
+		}
+		if names[profile.Name] {
+			return fmt.Errorf("duplicate agent name %q", profile.Name)
+		}
+		names[profile.Name] = true
+		if strings.TrimSpace(profile.Description) == "" {
+			return fmt.Errorf("agent %s: description is required", profile.Name)
+		}
+		if profile.MaxIterations < 0 || profile.MaxIterations > 30 {
+			return fmt.Errorf("agent %s: max_iterations must be between 0 and 30", profile.Name)
+		}
+		for _, tool := range profile.AllowedTools {
+			if !allowedTools[tool] {
+				return fmt.Errorf("agent %s: unknown tool %q", profile.Name, tool)
+			}
+		}
+	}
+	return nil
+}
+
+// validateToolPolicies validates the tool policies of a tenant. The keys are tool names
+// or glob patterns such as "mcp.*", so that they also cover MCP tools added later.
+func validateToolPolicies(policies map[string]types.ToolPolicy) error {
+	for pattern, policy := range policies {
+		if strings.TrimSpace(pattern) == "" {
+			return fmt.Errorf("tool name is empty")
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid tool pattern %q: %v", pattern, err)
+		}
+		if !policy.IsValid() {
+			return fmt.Errorf("tool %s: unknown policy %q, use auto, require_approval or deny", pattern, policy)
+		}
+	}
+	return nil
+}
+
 // GetTenantKV godoc
 // @Summary      获取租户KV配置
-// @Description  获取租户级别的KV配置（支持agent-config、web-search-config、conversation-config）
+// @Description  获取租户级别的KV配置（支持agent-config、web-search-config、conversation-config、usage-limits）
 // @Tags         租户管理
 // @Accept       json
 // @Produce      json
@@ -610,6 +766,9 @@ func (h *TenantHandler) GetTenantKV(c *gin.Context) {
 	case "conversation-config":
 		h.GetTenantConversationConfig(c)
 		return
+	case "usage-limits":
+		h.getTenantUsageLimitsInternal(c)
+		return
 	default:
 		logger.Info(ctx, "KV key not supported", "key", key)
 		c.Error(errors.NewBadRequestError("unsupported key"))
@@ -619,7 +778,7 @@ func (h *TenantHandler) GetTenantKV(c *gin.Context) {
 
 // UpdateTenantKV godoc
 // @Summary      更新租户KV配置
-// @Description  更新租户级别的KV配置（支持agent-config、web-search-config、conversation-config）
+// @Description  更新租户级别的KV配置（支持agent-config、web-search-config、conversation-config、usage-limits）
 // @Tags         租户管理
 // @Accept       json
 // @Produce      json
@@ -632,6 +791,9 @@ func (h *TenantHandler) GetTenantKV(c *gin.Context) {
 // @Router       /tenants/kv/{key} [put]
 func (h *TenantHandler) UpdateTenantKV(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
 	key := secutils.SanitizeForLog(c.Param("key"))
 
 	switch key {
@@ -644,6 +806,9 @@ func (h *TenantHandler) UpdateTenantKV(c *gin.Context) {
 	case "conversation-config":
 		h.updateTenantConversationInternal(c)
 		return
+	case "usage-limits":
+		h.updateTenantUsageLimitsInternal(c)
+		return
 	default:
 		logger.Info(ctx, "KV key not supported", "key", key)
 		c.Error(errors.NewBadRequestError("unsupported key"))
@@ -676,6 +841,7 @@ func (h *TenantHandler) updateTenantWebSearchConfigInternal(c *gin.Context) {
 		return
 	}
 
+	before := tenant.WebSearchConfig
 	tenant.WebSearchConfig = &cfg
 	updatedTenant, err := h.service.UpdateTenant(ctx, tenant)
 	if err != nil {
@@ -688,6 +854,7 @@ func (h *TenantHandler) updateTenantWebSearchConfigInternal(c *gin.Context) {
 		}
 		return
 	}
+	auditChange(c, before, updatedTenant.WebSearchConfig)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    updatedTenant.WebSearchConfig,
@@ -884,6 +1051,7 @@ func (h *TenantHandler) GetTenantConversationConfig(c *gin.Context) {
 		if tc.RewritePromptUser != "" {
 			defaultCfg.RewritePromptUser = tc.RewritePromptUser
 		}
+		defaultCfg.Pipeline = tc.Pipeline
 
 		response = defaultCfg
 	}
@@ -912,6 +1080,15 @@ func (h *TenantHandler) updateTenantConversationInternal(c *gin.Context) {
 		c.Error(err)
 		return
 	}
+	// An empty pipeline restores the default one
+	if req.Pipeline != nil && len(req.Pipeline.Stages) == 0 {
+		req.Pipeline = nil
+	}
+	if err := h.eventManager.ValidatePipeline(req.Pipeline); err != nil {
+		logger.Warnf(ctx, "Invalid pipeline: %v", err)
+		c.Error(errors.NewBadRequestError("Invalid pipeline").WithDetails(err.Error()))
+		return
+	}
 
 	// Get existing tenant
 	tenant := ctx.Value(types.TenantInfoContextKey).(*types.Tenant)
@@ -922,6 +1099,7 @@ func (h *TenantHandler) updateTenantConversationInternal(c *gin.Context) {
 	}
 
 	// Update conversation configuration
+	before := tenant.ConversationConfig
 	tenant.ConversationConfig = &req
 
 	updatedTenant, err := h.service.UpdateTenant(ctx, tenant)
@@ -936,6 +1114,8 @@ func (h *TenantHandler) updateTenantConversationInternal(c *gin.Context) {
 		return
 	}
 
+	auditChange(c, before, updatedTenant.ConversationConfig)
+
 	logger.Infof(ctx, "Tenant conversation config updated successfully, Tenant ID: %d", tenant.ID)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -943,3 +1123,69 @@ func (h *TenantHandler) updateTenantConversationInternal(c *gin.Context) {
 		"message": "Conversation configuration updated successfully",
 	})
 }
+
+// getTenantUsageLimitsInternal returns the model usage limits of the tenant, zero values mean unlimited
+func (h *TenantHandler) getTenantUsageLimitsInternal(c *gin.Context) {
+	ctx := c.Request.Context()
+	tenant := ctx.Value(types.TenantInfoContextKey).(*types.Tenant)
+	if tenant == nil {
+		logger.Error(ctx, "Tenant is empty")
+		c.Error(errors.NewBadRequestError("Tenant is empty"))
+		return
+	}
+
+	limits := types.UsageLimits{}
+	if tenant.UsageLimits != nil {
+		limits = *tenant.UsageLimits
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    limits,
+	})
+}
+
+// updateTenantUsageLimitsInternal updates the model usage limits of the tenant
+func (h *TenantHandler) updateTenantUsageLimitsInternal(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req types.UsageLimits
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error(ctx, "Failed to parse request parameters", err)
+		c.Error(errors.NewValidationError("Invalid request data").WithDetails(err.Error()))
+		return
+	}
+	if req.RequestsPerMinute < 0 || req.MonthlyTokenBudget < 0 {
+		c.Error(errors.NewBadRequestError("usage limits must not be negative"))
+		return
+	}
+
+	tenant := ctx.Value(types.TenantInfoContextKey).(*types.Tenant)
+	if tenant == nil {
+		logger.Error(ctx, "Tenant is empty")
+		c.Error(errors.NewBadRequestError("Tenant is empty"))
+		return
+	}
+
+	before := tenant.UsageLimits
+	tenant.UsageLimits = &req
+	updatedTenant, err := h.service.UpdateTenant(ctx, tenant)
+	if err != nil {
+		if appErr, ok := errors.IsAppError(err); ok {
+			logger.Error(ctx, "Failed to update tenant: application error", appErr)
+			c.Error(appErr)
+		} else {
+			logger.ErrorWithFields(ctx, err, nil)
+			c.Error(errors.NewInternalServerError("Failed to update tenant usage limits").WithDetails(err.Error()))
+		}
+		return
+	}
+
+	auditChange(c, before, updatedTenant.UsageLimits)
+
+	logger.Infof(ctx, "Tenant usage limits updated successfully, Tenant ID: %d", tenant.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    updatedTenant.UsageLimits,
+		"message": "Usage limits updated successfully",
+	})
+}
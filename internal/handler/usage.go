@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	secutils "github.com/Tencent/WeKnora/internal/utils"
+)
+
+// UsageHandler reports the model usage of a tenant
+type UsageHandler struct {
+	service interfaces.UsageService
+}
+
+// NewUsageHandler creates a new usage handler
+func NewUsageHandler(service interfaces.UsageService) *UsageHandler {
+	return &UsageHandler{service: service}
+}
+
+// GetUsageReport godoc
+// @Summary      获取模型用量报表
+// @Description  按天和模型统计当前租户的模型调用用量，默认统计本月，仅所有者可操作
+// @Tags         用量统计
+// @Produce      json
+// @Param        from        query     string  false  "开始日期（YYYY-MM-DD），默认本月第一天"
+// @Param        to          query     string  false  "结束日期（YYYY-MM-DD），默认今天"
+// @Param        api_key_id  query     string  false  "只统计指定 API Key 的用量"
+// @Success      200         {object}  map[string]interface{}  "用量报表"
+// @Failure      400         {object}  errors.AppError         "请求参数错误"
+// @Failure      403         {object}  errors.AppError         "无权限"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /usage/report [get]
+func (h *UsageHandler) GetUsageReport(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var err error
+	if value := c.Query("from"); value != "" {
+		if from, err = time.ParseInLocation(time.DateOnly, value, now.Location()); err != nil {
+			c.Error(errors.NewBadRequestError("开始日期格式不正确，应为YYYY-MM-DD"))
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if to, err = time.ParseInLocation(time.DateOnly, value, now.Location()); err != nil {
+			c.Error(errors.NewBadRequestError("结束日期格式不正确，应为YYYY-MM-DD"))
+			return
+		}
+	}
+
+	report, err := h.service.GetUsageReport(ctx, from, to, secutils.SanitizeForLog(c.Query("api_key_id")))
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    report,
+	})
+}
@@ -0,0 +1,302 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	secutils "github.com/Tencent/WeKnora/internal/utils"
+)
+
+// WebhookHandler handles the webhooks of a tenant and their delivery log
+type WebhookHandler struct {
+	service interfaces.WebhookService
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(service interfaces.WebhookService) *WebhookHandler {
+	return &WebhookHandler{service: service}
+}
+
+// CreateWebhook godoc
+// @Summary      创建 Webhook
+// @Description  创建接收事件通知的 Webhook，签名密钥仅在创建时返回一次，仅所有者可操作
+// @Tags         Webhook
+// @Accept       json
+// @Produce      json
+// @Param        request  body      types.CreateWebhookRequest  true  "Webhook 信息"
+// @Success      200      {object}  map[string]interface{}      "新建的 Webhook"
+// @Failure      400      {object}  errors.AppError             "请求参数错误"
+// @Failure      403      {object}  errors.AppError             "无权限"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /webhooks [post]
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+	var req types.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error(ctx, "Failed to bind create webhook payload", err)
+		c.Error(errors.NewBadRequestError("请求参数不合法").WithDetails(err.Error()))
+		return
+	}
+	req.Name = secutils.SanitizeForLog(req.Name)
+
+	webhook, err := h.service.CreateWebhook(ctx, &req)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	auditResourceID(c, webhook.ID)
+	auditChange(c, nil, webhook.Webhook)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    webhook,
+	})
+}
+
+// ListWebhooks godoc
+// @Summary      获取 Webhook 列表
+// @Description  获取当前租户的 Webhook，不返回签名密钥，仅所有者可操作
+// @Tags         Webhook
+// @Produce      json
+// @Success      200  {object}  map[string]interface{}  "Webhook 列表"
+// @Failure      403  {object}  errors.AppError         "无权限"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /webhooks [get]
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+	webhooks, err := h.service.ListWebhooks(ctx)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    webhooks,
+	})
+}
+
+// GetWebhook godoc
+// @Summary      获取 Webhook 详情
+// @Description  获取 Webhook 详情，不返回签名密钥，仅所有者可操作
+// @Tags         Webhook
+// @Produce      json
+// @Param        id   path      string  true  "Webhook ID"
+// @Success      200  {object}  map[string]interface{}  "Webhook 详情"
+// @Failure      403  {object}  errors.AppError         "无权限"
+// @Failure      404  {object}  errors.AppError         "Webhook 不存在"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+	webhook, err := h.service.GetWebhook(ctx, secutils.SanitizeForLog(c.Param("id")))
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    webhook,
+	})
+}
+
+// UpdateWebhook godoc
+// @Summary      更新 Webhook
+// @Description  更新 Webhook 的名称、地址、订阅事件和启用状态，可轮换签名密钥，新密钥仅在本次返回，仅所有者可操作
+// @Tags         Webhook
+// @Accept       json
+// @Produce      json
+// @Param        id       path      string                      true  "Webhook ID"
+// @Param        request  body      types.UpdateWebhookRequest  true  "更新内容"
+// @Success      200      {object}  map[string]interface{}      "更新后的 Webhook"
+// @Failure      400      {object}  errors.AppError             "请求参数错误"
+// @Failure      403      {object}  errors.AppError             "无权限"
+// @Failure      404      {object}  errors.AppError             "Webhook 不存在"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /webhooks/{id} [put]
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+	id := secutils.SanitizeForLog(c.Param("id"))
+	var req types.UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Error(ctx, "Failed to bind update webhook payload", err)
+		c.Error(errors.NewBadRequestError("请求参数不合法").WithDetails(err.Error()))
+		return
+	}
+	if req.Name != nil {
+		name := secutils.SanitizeForLog(*req.Name)
+		req.Name = &name
+	}
+
+	before, err := h.service.GetWebhook(ctx, id)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	webhook, err := h.service.UpdateWebhook(ctx, id, &req)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	auditChange(c, before, webhook.Webhook)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    webhook,
+	})
+}
+
+// DeleteWebhook godoc
+// @Summary      删除 Webhook
+// @Description  删除 Webhook 及其投递记录，仅所有者可操作
+// @Tags         Webhook
+// @Produce      json
+// @Param        id   path      string  true  "Webhook ID"
+// @Success      200  {object}  map[string]interface{}  "删除成功"
+// @Failure      403  {object}  errors.AppError         "无权限"
+// @Failure      404  {object}  errors.AppError         "Webhook 不存在"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+	id := secutils.SanitizeForLog(c.Param("id"))
+	before, err := h.service.GetWebhook(ctx, id)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	if err := h.service.DeleteWebhook(ctx, id); err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	auditChange(c, before, nil)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+	})
+}
+
+// ListWebhookDeliveries godoc
+// @Summary      获取 Webhook 投递记录
+// @Description  分页获取 Webhook 的投递记录，按时间倒序排列，仅所有者可操作
+// @Tags         Webhook
+// @Produce      json
+// @Param        id         path      string  true   "Webhook ID"
+// @Param        page       query     int     false  "页码"
+// @Param        page_size  query     int     false  "每页条数，最大100"
+// @Success      200        {object}  map[string]interface{}  "投递记录列表"
+// @Failure      403        {object}  errors.AppError         "无权限"
+// @Failure      404        {object}  errors.AppError         "Webhook 不存在"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListWebhookDeliveries(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+	var page types.Pagination
+	if err := c.ShouldBindQuery(&page); err != nil {
+		logger.Error(ctx, "Failed to bind pagination query", err)
+		c.Error(errors.NewBadRequestError("分页参数不合法").WithDetails(err.Error()))
+		return
+	}
+	result, err := h.service.ListDeliveries(ctx, secutils.SanitizeForLog(c.Param("id")), &page)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// PingWebhook godoc
+// @Summary      测试 Webhook
+// @Description  向 Webhook 投递一个 webhook.ping 事件，投递结果可在投递记录中查看，仅所有者可操作
+// @Tags         Webhook
+// @Produce      json
+// @Param        id   path      string  true  "Webhook ID"
+// @Success      200  {object}  map[string]interface{}  "新建的投递记录"
+// @Failure      403  {object}  errors.AppError         "无权限"
+// @Failure      404  {object}  errors.AppError         "Webhook 不存在"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /webhooks/{id}/ping [post]
+func (h *WebhookHandler) PingWebhook(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+	delivery, err := h.service.PingWebhook(ctx, secutils.SanitizeForLog(c.Param("id")))
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    delivery,
+	})
+}
+
+// RedeliverWebhookDelivery godoc
+// @Summary      重新投递
+// @Description  将一条投递记录的内容重新投递到 Webhook，生成新的投递记录，仅所有者可操作
+// @Tags         Webhook
+// @Produce      json
+// @Param        id           path      string  true  "Webhook ID"
+// @Param        delivery_id  path      string  true  "投递记录ID"
+// @Success      200          {object}  map[string]interface{}  "新建的投递记录"
+// @Failure      403          {object}  errors.AppError         "无权限"
+// @Failure      404          {object}  errors.AppError         "Webhook 或投递记录不存在"
+// @Security     Bearer
+// @Security     ApiKeyAuth
+// @Router       /webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func (h *WebhookHandler) RedeliverWebhookDelivery(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireTenantRole(c, types.TenantRoleOwner) {
+		return
+	}
+	delivery, err := h.service.RedeliverDelivery(ctx,
+		secutils.SanitizeForLog(c.Param("id")), secutils.SanitizeForLog(c.Param("delivery_id")))
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    delivery,
+	})
+}
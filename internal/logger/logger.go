@@ -270,6 +270,8 @@ func CloneContext(ctx context.Context) context.Context {
 		types.TenantIDContextKey,
 		types.RequestIDContextKey,
 		types.TenantInfoContextKey,
+		types.PrincipalContextKey,
+		types.ToolApprovalUnavailableContextKey,
 	} {
 		if v := ctx.Value(k); v != nil {
 			newCtx = context.WithValue(newCtx, k, v)
@@ -0,0 +1,119 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	secutils "github.com/Tencent/WeKnora/internal/utils"
+)
+
+const (
+	// documentURIPrefix is the prefix of the URI of a document resource, followed by the knowledge ID
+	documentURIPrefix = "weknora://knowledge/"
+	// documentURITemplate is the URI template of the document resources
+	documentURITemplate = documentURIPrefix + "{knowledge_id}"
+	// documentListURITemplate is the URI template of the document lists of the knowledge bases
+	documentListURITemplate = "weknora://knowledge-bases/{knowledge_base_id}/documents"
+)
+
+// documentInfo is a document listed by the document list resource of a knowledge base
+type documentInfo struct {
+	ID          string `json:"id"`
+	URI         string `json:"uri"`
+	Title       string `json:"title"`
+	FileName    string `json:"file_name,omitempty"`
+	FileType    string `json:"file_type,omitempty"`
+	Source      string `json:"source,omitempty"`
+	ParseStatus string `json:"parse_status"`
+}
+
+// registerResources registers the resource templates of the server
+func (s *Server) registerResources() {
+	s.mcp.AddResourceTemplate(mcp.NewResourceTemplate(documentURITemplate, "Document",
+		mcp.WithTemplateDescription("Full text of a document of a knowledge base"),
+		mcp.WithTemplateMIMEType("text/markdown"),
+	), s.readDocument)
+
+	s.mcp.AddResourceTemplate(mcp.NewResourceTemplate(documentListURITemplate, "Knowledge base documents",
+		mcp.WithTemplateDescription("Documents of a knowledge base, with the URIs of their document resources"),
+		mcp.WithTemplateMIMEType("application/json"),
+	), s.readDocumentList)
+}
+
+// readDocument reads a document resource
+func (s *Server) readDocument(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	if err := checkScope(ctx, types.APIKeyScopeKBRead); err != nil {
+		return nil, err
+	}
+	knowledge, err := s.readableKnowledge(ctx, secutils.SanitizeForLog(uriArgument(request, "knowledge_id")))
+	if err != nil {
+		return nil, err
+	}
+	text, err := s.documentText(ctx, knowledge)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		return nil, fmt.Errorf("failed to read document %s", knowledge.ID)
+	}
+	return []mcp.ResourceContents{mcp.TextResourceContents{
+		URI:      request.Params.URI,
+		MIMEType: "text/markdown",
+		Text:     text,
+	}}, nil
+}
+
+// readDocumentList reads the document list resource of a knowledge base
+func (s *Server) readDocumentList(
+	ctx context.Context, request mcp.ReadResourceRequest,
+) ([]mcp.ResourceContents, error) {
+	if err := checkScope(ctx, types.APIKeyScopeKBRead); err != nil {
+		return nil, err
+	}
+	kb, err := s.readableKnowledgeBase(ctx, secutils.SanitizeForLog(uriArgument(request, "knowledge_base_id")))
+	if err != nil {
+		return nil, err
+	}
+	knowledges, err := s.knowledgeService.ListKnowledgeByKnowledgeBaseID(ctx, kb.ID)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		return nil, fmt.Errorf("failed to list the documents of knowledge base %s", kb.ID)
+	}
+	documents := make([]documentInfo, 0, len(knowledges))
+	for _, knowledge := range knowledges {
+		documents = append(documents, documentInfo{
+			ID:          knowledge.ID,
+			URI:         documentURIPrefix + knowledge.ID,
+			Title:       knowledge.Title,
+			FileName:    knowledge.FileName,
+			FileType:    knowledge.FileType,
+			Source:      knowledge.Source,
+			ParseStatus: knowledge.ParseStatus,
+		})
+	}
+	payload, err := json.Marshal(documents)
+	if err != nil {
+		return nil, err
+	}
+	return []mcp.ResourceContents{mcp.TextResourceContents{
+		URI:      request.Params.URI,
+		MIMEType: "application/json",
+		Text:     string(payload),
+	}}, nil
+}
+
+// uriArgument returns a variable of the URI template matched by a resource request
+func uriArgument(request mcp.ReadResourceRequest, name string) string {
+	switch value := request.Params.Arguments[name].(type) {
+	case string:
+		return value
+	case []string:
+		if len(value) > 0 {
+			return value[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,135 @@
+// Package mcpserver serves the knowledge bases of WeKnora over the Model Context Protocol,
+// so that MCP clients such as IDE agents and desktop assistants can search and query them.
+// The server is mounted on the HTTP API as a streamable HTTP endpoint and can also run over stdio.
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/Tencent/WeKnora/internal/handler"
+	"github.com/Tencent/WeKnora/internal/handler/session"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+)
+
+// serverName is the name the server reports to MCP clients
+const serverName = "weknora"
+
+// serverInstructions tells MCP clients how to use the tools of the server
+const serverInstructions = "WeKnora knowledge bases. Call list_knowledge_bases to find the knowledge bases, " +
+	"knowledge_search or faq_search to retrieve relevant passages, get_document to read a whole document, " +
+	"and ask to get an answer generated from the knowledge bases."
+
+// Server exposes the knowledge bases of the tenant of each request as MCP tools and resources.
+// Requests must carry the tenant and the principal of an API key in their context,
+// every tool checks the permissions of the principal like the REST API does.
+type Server struct {
+	mcp              *server.MCPServer
+	kbService        interfaces.KnowledgeBaseService
+	knowledgeService interfaces.KnowledgeService
+	chunkService     interfaces.ChunkService
+	sessionService   interfaces.SessionService
+	sessionHandler   *session.Handler
+}
+
+// NewServer creates the MCP server and registers its tools and resources
+func NewServer(
+	kbService interfaces.KnowledgeBaseService,
+	knowledgeService interfaces.KnowledgeService,
+	chunkService interfaces.ChunkService,
+	sessionService interfaces.SessionService,
+	sessionHandler *session.Handler,
+) *Server {
+	s := &Server{
+		mcp: server.NewMCPServer(serverName, handler.Version,
+			server.WithToolCapabilities(false),
+			server.WithResourceCapabilities(false, false),
+			server.WithInstructions(serverInstructions),
+			server.WithRecovery(),
+			server.WithResourceRecovery(),
+		),
+		kbService:        kbService,
+		knowledgeService: knowledgeService,
+		chunkService:     chunkService,
+		sessionService:   sessionService,
+		sessionHandler:   sessionHandler,
+	}
+	s.registerTools()
+	s.registerResources()
+	return s
+}
+
+// HTTPHandler returns the streamable HTTP transport of the server.
+// The transport is stateless, each request is authenticated by the auth middleware of the HTTP API.
+func (s *Server) HTTPHandler() http.Handler {
+	return server.NewStreamableHTTPServer(s.mcp, server.WithStateLess(true))
+}
+
+// ServeStdio serves a single MCP client over stdin and stdout until ctx is done or stdin is closed.
+// All the requests are made with the tenant and the principal carried by ctx.
+func (s *Server) ServeStdio(ctx context.Context, stdin io.Reader, stdout io.Writer, errLogger *log.Logger) error {
+	stdio := server.NewStdioServer(s.mcp)
+	stdio.SetErrorLogger(errLogger)
+	return stdio.Listen(ctx, stdin, stdout)
+}
+
+// checkScope checks that a scoped API key grants a scope, other principals are checked by their permissions
+func checkScope(ctx context.Context, scope types.APIKeyScope) error {
+	principal := types.PrincipalFromContext(ctx)
+	if principal != nil && principal.APIKey != nil && !principal.APIKey.HasScope(scope) {
+		return fmt.Errorf("API key lacks scope %s", scope)
+	}
+	return nil
+}
+
+// readableKnowledgeBase returns a knowledge base of the tenant which the caller can read
+func (s *Server) readableKnowledgeBase(ctx context.Context, kbID string) (*types.KnowledgeBase, error) {
+	tenantID, _ := ctx.Value(types.TenantIDContextKey).(uint64)
+	kb, err := s.kbService.GetKnowledgeBaseByID(ctx, kbID)
+	if err != nil || kb == nil || kb.TenantID != tenantID {
+		return nil, fmt.Errorf("knowledge base %s not found", kbID)
+	}
+	if principal := types.PrincipalFromContext(ctx); principal != nil &&
+		!principal.CanAccessKnowledgeBase(kbID, types.KBPermissionRead) {
+		return nil, fmt.Errorf("no permission to access knowledge base %s", kbID)
+	}
+	return kb, nil
+}
+
+// readableKnowledgeBases lists the knowledge bases of the tenant which the caller can read
+func (s *Server) readableKnowledgeBases(ctx context.Context) ([]*types.KnowledgeBase, error) {
+	kbs, err := s.kbService.ListKnowledgeBases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	principal := types.PrincipalFromContext(ctx)
+	if principal == nil {
+		return kbs, nil
+	}
+	readable := make([]*types.KnowledgeBase, 0, len(kbs))
+	for _, kb := range kbs {
+		if principal.CanAccessKnowledgeBase(kb.ID, types.KBPermissionRead) {
+			readable = append(readable, kb)
+		}
+	}
+	return readable, nil
+}
+
+// readableKnowledge returns a knowledge of the tenant whose knowledge base the caller can read
+func (s *Server) readableKnowledge(ctx context.Context, knowledgeID string) (*types.Knowledge, error) {
+	knowledge, err := s.knowledgeService.GetKnowledgeByID(ctx, knowledgeID)
+	if err != nil || knowledge == nil {
+		return nil, fmt.Errorf("document %s not found", knowledgeID)
+	}
+	if principal := types.PrincipalFromContext(ctx); principal != nil &&
+		!principal.CanAccessKnowledgeBase(knowledge.KnowledgeBaseID, types.KBPermissionRead) {
+		return nil, fmt.Errorf("no permission to access document %s", knowledgeID)
+	}
+	return knowledge, nil
+}
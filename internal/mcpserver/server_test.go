@@ -0,0 +1,257 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+)
+
+// fakeKnowledgeBaseService serves a list of knowledge bases, the other methods are not implemented
+type fakeKnowledgeBaseService struct {
+	interfaces.KnowledgeBaseService
+	knowledgeBases []*types.KnowledgeBase
+}
+
+func (s *fakeKnowledgeBaseService) GetKnowledgeBaseByID(ctx context.Context, id string) (*types.KnowledgeBase, error) {
+	for _, kb := range s.knowledgeBases {
+		if kb.ID == id {
+			return kb, nil
+		}
+	}
+	return nil, errors.New("knowledge base not found")
+}
+
+func (s *fakeKnowledgeBaseService) ListKnowledgeBases(ctx context.Context) ([]*types.KnowledgeBase, error) {
+	tenantID := ctx.Value(types.TenantIDContextKey).(uint64)
+	kbs := make([]*types.KnowledgeBase, 0, len(s.knowledgeBases))
+	for _, kb := range s.knowledgeBases {
+		if kb.TenantID == tenantID {
+			kbs = append(kbs, kb)
+		}
+	}
+	return kbs, nil
+}
+
+// fakeKnowledgeService serves the knowledge of the tenant of the request, the other methods are not implemented
+type fakeKnowledgeService struct {
+	interfaces.KnowledgeService
+	knowledge map[string]*types.Knowledge
+}
+
+func (s *fakeKnowledgeService) GetKnowledgeByID(ctx context.Context, id string) (*types.Knowledge, error) {
+	knowledge, ok := s.knowledge[id]
+	if !ok || knowledge.TenantID != ctx.Value(types.TenantIDContextKey).(uint64) {
+		return nil, errors.New("knowledge not found")
+	}
+	return knowledge, nil
+}
+
+// newTestServer creates a server whose tenant 1 has the knowledge bases kb1 and kb2, and tenant 2 has kb3.
+// Each knowledge base has a document doc<n>.
+func newTestServer() *Server {
+	return &Server{
+		kbService: &fakeKnowledgeBaseService{knowledgeBases: []*types.KnowledgeBase{
+			{ID: "kb1", TenantID: 1, Name: "kb1"},
+			{ID: "kb2", TenantID: 1, Name: "kb2"},
+			{ID: "kb3", TenantID: 2, Name: "kb3"},
+		}},
+		knowledgeService: &fakeKnowledgeService{knowledge: map[string]*types.Knowledge{
+			"doc1": {ID: "doc1", TenantID: 1, KnowledgeBaseID: "kb1"},
+			"doc2": {ID: "doc2", TenantID: 1, KnowledgeBaseID: "kb2"},
+			"doc3": {ID: "doc3", TenantID: 2, KnowledgeBaseID: "kb3"},
+		}},
+	}
+}
+
+// testContext returns the context of a request of tenant 1 made by principal
+func testContext(principal *types.Principal) context.Context {
+	ctx := context.WithValue(context.Background(), types.TenantIDContextKey, uint64(1))
+	if principal != nil {
+		ctx = context.WithValue(ctx, types.PrincipalContextKey, principal)
+	}
+	return ctx
+}
+
+// scopedKey returns the principal of a scoped API key of the tenant owner
+func scopedKey(kbIDs []string, scopes ...types.APIKeyScope) *types.Principal {
+	key := &types.APIKey{ID: "key1", KnowledgeBaseIDs: kbIDs}
+	for _, scope := range scopes {
+		key.Scopes = append(key.Scopes, string(scope))
+	}
+	return &types.Principal{Role: types.TenantRoleOwner, APIKey: key}
+}
+
+func resultText(t *testing.T, result *mcp.CallToolResult) string {
+	t.Helper()
+	require.NotNil(t, result)
+	require.Len(t, result.Content, 1)
+	content, ok := result.Content[0].(mcp.TextContent)
+	require.True(t, ok)
+	return content.Text
+}
+
+func TestCheckScope(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *types.Principal
+		scope     types.APIKeyScope
+		allowed   bool
+	}{
+		{"tenant API key", nil, types.APIKeyScopeKBRead, true},
+		{"user", &types.Principal{UserID: "u1", Role: types.TenantRoleViewer}, types.APIKeyScopeChatWrite, true},
+		{"granted scope", scopedKey(nil, types.APIKeyScopeKBRead), types.APIKeyScopeKBRead, true},
+		{"write implies read", scopedKey(nil, types.APIKeyScopeKBWrite), types.APIKeyScopeKBRead, true},
+		{"admin", scopedKey(nil, types.APIKeyScopeAdmin), types.APIKeyScopeChatWrite, true},
+		{"missing kb:read", scopedKey(nil, types.APIKeyScopeChatWrite), types.APIKeyScopeKBRead, false},
+		{"missing chat:write", scopedKey(nil, types.APIKeyScopeKBRead), types.APIKeyScopeChatWrite, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkScope(testContext(tt.principal), tt.scope)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, string(tt.scope))
+			}
+		})
+	}
+}
+
+func TestReadableKnowledgeBase(t *testing.T) {
+	viewer := &types.Principal{
+		UserID: "u1",
+		Role:   types.TenantRoleViewer,
+		Grants: map[string]types.KBPermission{"kb1": types.KBPermissionRead},
+	}
+	tests := []struct {
+		name      string
+		principal *types.Principal
+		kbID      string
+		err       string
+	}{
+		{name: "tenant API key", kbID: "kb1"},
+		{name: "knowledge base of another tenant", kbID: "kb3", err: "not found"},
+		{name: "unknown knowledge base", kbID: "missing", err: "not found"},
+		{
+			name:      "granted knowledge base",
+			principal: viewer,
+			kbID:      "kb1",
+		},
+		{
+			name:      "knowledge base without a grant",
+			principal: viewer,
+			kbID:      "kb2",
+			err:       "no permission",
+		},
+		{
+			name:      "knowledge base outside a restricted key",
+			principal: scopedKey([]string{"kb1"}, types.APIKeyScopeKBRead),
+			kbID:      "kb2",
+			err:       "no permission",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kb, err := newTestServer().readableKnowledgeBase(testContext(tt.principal), tt.kbID)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				assert.Nil(t, kb)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.kbID, kb.ID)
+		})
+	}
+}
+
+func TestReadableKnowledge(t *testing.T) {
+	s := newTestServer()
+	restricted := testContext(scopedKey([]string{"kb1"}, types.APIKeyScopeKBRead))
+
+	knowledge, err := s.readableKnowledge(restricted, "doc1")
+	require.NoError(t, err)
+	assert.Equal(t, "doc1", knowledge.ID)
+
+	_, err = s.readableKnowledge(restricted, "doc2")
+	assert.ErrorContains(t, err, "no permission")
+	// Documents of other tenants are not found
+	_, err = s.readableKnowledge(testContext(nil), "doc3")
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestListKnowledgeBases(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *types.Principal
+		expected  []string
+	}{
+		{name: "tenant API key", expected: []string{"kb1", "kb2"}},
+		{
+			name:      "restricted key",
+			principal: scopedKey([]string{"kb2"}, types.APIKeyScopeKBRead),
+			expected:  []string{"kb2"},
+		},
+		{
+			name: "user with a grant",
+			principal: &types.Principal{
+				UserID: "u1",
+				Role:   types.TenantRoleEditor,
+				Grants: map[string]types.KBPermission{"kb1": types.KBPermissionRead},
+			},
+			expected: []string{"kb1"},
+		},
+		{
+			name:      "viewer without grants",
+			principal: &types.Principal{UserID: "u2", Role: types.TenantRoleViewer},
+			expected:  []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := newTestServer().listKnowledgeBases(testContext(tt.principal), mcp.CallToolRequest{})
+			require.NoError(t, err)
+			assert.False(t, result.IsError)
+
+			var listed struct {
+				KnowledgeBases []knowledgeBaseInfo `json:"knowledge_bases"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &listed))
+			ids := make([]string, 0, len(listed.KnowledgeBases))
+			for _, kb := range listed.KnowledgeBases {
+				ids = append(ids, kb.ID)
+			}
+			assert.Equal(t, tt.expected, ids)
+		})
+	}
+}
+
+func TestListKnowledgeBasesRequiresKBRead(t *testing.T) {
+	result, err := newTestServer().listKnowledgeBases(
+		testContext(scopedKey(nil, types.APIKeyScopeChatWrite)), mcp.CallToolRequest{})
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+	assert.Contains(t, resultText(t, result), "kb:read")
+}
+
+func TestAskRequiresChatWrite(t *testing.T) {
+	s := newTestServer()
+
+	result, err := s.ask(testContext(scopedKey(nil, types.APIKeyScopeKBRead)), mcp.CallToolRequest{})
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+	assert.Contains(t, resultText(t, result), "chat:write")
+
+	// With the scope the request goes on to the question
+	result, err = s.ask(testContext(scopedKey(nil, types.APIKeyScopeChatWrite)), mcp.CallToolRequest{})
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+	assert.Equal(t, "question is required", resultText(t, result))
+}
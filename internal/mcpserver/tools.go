@@ -0,0 +1,293 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/Tencent/WeKnora/internal/handler/session"
+	"github.com/Tencent/WeKnora/internal/logger"
+	"github.com/Tencent/WeKnora/internal/types"
+	secutils "github.com/Tencent/WeKnora/internal/utils"
+)
+
+const (
+	// defaultFAQMatchCount is the number of FAQ entries returned by faq_search by default
+	defaultFAQMatchCount = 10
+	// maxFAQMatchCount is the maximum number of FAQ entries returned by faq_search
+	maxFAQMatchCount = 50
+	// defaultAskModel is the chat completions model used by ask by default
+	defaultAskModel = "agent"
+)
+
+// knowledgeBaseInfo is a knowledge base listed by list_knowledge_bases
+type knowledgeBaseInfo struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	Type           string `json:"type"`
+	KnowledgeCount int64  `json:"knowledge_count"`
+}
+
+// searchHit is a passage returned by knowledge_search
+type searchHit struct {
+	KnowledgeID    string  `json:"knowledge_id"`
+	KnowledgeTitle string  `json:"knowledge_title"`
+	ChunkIndex     int     `json:"chunk_index"`
+	Content        string  `json:"content"`
+	Score          float64 `json:"score"`
+}
+
+// faqHit is a FAQ entry returned by faq_search
+type faqHit struct {
+	ID               string   `json:"id"`
+	StandardQuestion string   `json:"standard_question"`
+	SimilarQuestions []string `json:"similar_questions,omitempty"`
+	Answers          []string `json:"answers"`
+	Score            float64  `json:"score"`
+}
+
+// askResult is the answer returned by ask
+type askResult struct {
+	Answer     string      `json:"answer"`
+	SessionID  string      `json:"session_id"`
+	References []searchHit `json:"references,omitempty"`
+}
+
+// registerTools registers the tools of the server
+func (s *Server) registerTools() {
+	s.mcp.AddTool(mcp.NewTool("list_knowledge_bases",
+		mcp.WithDescription("List the knowledge bases that can be searched, with their IDs, names and descriptions."),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), s.listKnowledgeBases)
+
+	s.mcp.AddTool(mcp.NewTool("knowledge_search",
+		mcp.WithDescription("Search the knowledge bases with hybrid vector and keyword retrieval and return "+
+			"the most relevant passages of their documents."),
+		mcp.WithString("query", mcp.Required(), mcp.Description("The search query")),
+		mcp.WithArray("knowledge_base_ids", mcp.WithStringItems(),
+			mcp.Description("IDs of the knowledge bases to search, all readable knowledge bases when omitted")),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), s.knowledgeSearch)
+
+	s.mcp.AddTool(mcp.NewTool("faq_search",
+		mcp.WithDescription("Search the question and answer entries of an FAQ knowledge base."),
+		mcp.WithString("knowledge_base_id", mcp.Required(), mcp.Description("ID of the FAQ knowledge base")),
+		mcp.WithString("query", mcp.Required(), mcp.Description("The question to match")),
+		mcp.WithNumber("match_count", mcp.Min(1), mcp.Max(maxFAQMatchCount),
+			mcp.DefaultNumber(defaultFAQMatchCount), mcp.Description("Maximum number of entries to return")),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), s.faqSearch)
+
+	s.mcp.AddTool(mcp.NewTool("get_document",
+		mcp.WithDescription("Get the full text of a document of a knowledge base, "+
+			"e.g. a document found by knowledge_search."),
+		mcp.WithString("knowledge_id", mcp.Required(), mcp.Description("ID of the document")),
+		mcp.WithReadOnlyHintAnnotation(true),
+	), s.getDocument)
+
+	s.mcp.AddTool(mcp.NewTool("ask",
+		mcp.WithDescription("Ask a question and get an answer generated from the knowledge bases, "+
+			"with the passages it is based on. Pass the returned session_id to ask a follow-up question."),
+		mcp.WithString("question", mcp.Required(), mcp.Description("The question")),
+		mcp.WithString("model", mcp.DefaultString(defaultAskModel),
+			mcp.Description("agent to let the agent search all readable knowledge bases, "+
+				"agent:<knowledge base ID> or kb:<knowledge base ID> to answer from one knowledge base")),
+		mcp.WithString("session_id", mcp.Description("Session of an earlier answer to continue")),
+		mcp.WithReadOnlyHintAnnotation(false),
+		mcp.WithDestructiveHintAnnotation(false),
+	), s.ask)
+}
+
+// listKnowledgeBases handles the list_knowledge_bases tool
+func (s *Server) listKnowledgeBases(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if err := checkScope(ctx, types.APIKeyScopeKBRead); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	kbs, err := s.readableKnowledgeBases(ctx)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		return mcp.NewToolResultErrorFromErr("failed to list knowledge bases", err), nil
+	}
+	infos := make([]knowledgeBaseInfo, 0, len(kbs))
+	for _, kb := range kbs {
+		infos = append(infos, knowledgeBaseInfo{
+			ID:             kb.ID,
+			Name:           kb.Name,
+			Description:    kb.Description,
+			Type:           kb.Type,
+			KnowledgeCount: kb.KnowledgeCount,
+		})
+	}
+	return mcp.NewToolResultJSON(map[string]interface{}{"knowledge_bases": infos})
+}
+
+// knowledgeSearch handles the knowledge_search tool
+func (s *Server) knowledgeSearch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if err := checkScope(ctx, types.APIKeyScopeKBRead); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	query, err := request.RequireString("query")
+	if err != nil || strings.TrimSpace(query) == "" {
+		return mcp.NewToolResultError("query is required"), nil
+	}
+	kbIDs := request.GetStringSlice("knowledge_base_ids", nil)
+	for i, kbID := range kbIDs {
+		kbIDs[i] = secutils.SanitizeForLog(kbID)
+		if _, err := s.readableKnowledgeBase(ctx, kbIDs[i]); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	}
+	if len(kbIDs) == 0 {
+		kbs, err := s.readableKnowledgeBases(ctx)
+		if err != nil {
+			logger.ErrorWithFields(ctx, err, nil)
+			return mcp.NewToolResultErrorFromErr("failed to list knowledge bases", err), nil
+		}
+		for _, kb := range kbs {
+			kbIDs = append(kbIDs, kb.ID)
+		}
+		if len(kbIDs) == 0 {
+			return mcp.NewToolResultError("no knowledge base can be searched"), nil
+		}
+	}
+
+	results, err := s.sessionService.SearchKnowledge(ctx, kbIDs, nil, secutils.SanitizeForLog(query), nil)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		return mcp.NewToolResultErrorFromErr("failed to search knowledge bases", err), nil
+	}
+	return mcp.NewToolResultJSON(map[string]interface{}{"results": searchHits(results)})
+}
+
+// faqSearch handles the faq_search tool
+func (s *Server) faqSearch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if err := checkScope(ctx, types.APIKeyScopeKBRead); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	kbID, err := request.RequireString("knowledge_base_id")
+	if err != nil {
+		return mcp.NewToolResultError("knowledge_base_id is required"), nil
+	}
+	query, err := request.RequireString("query")
+	if err != nil || strings.TrimSpace(query) == "" {
+		return mcp.NewToolResultError("query is required"), nil
+	}
+	kb, err := s.readableKnowledgeBase(ctx, secutils.SanitizeForLog(kbID))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if kb.Type != types.KnowledgeBaseTypeFAQ {
+		return mcp.NewToolResultErrorf("knowledge base %s is not an FAQ knowledge base", kb.ID), nil
+	}
+	matchCount := request.GetInt("match_count", defaultFAQMatchCount)
+	if matchCount < 1 || matchCount > maxFAQMatchCount {
+		matchCount = defaultFAQMatchCount
+	}
+
+	entries, err := s.knowledgeService.SearchFAQEntries(ctx, kb.ID, &types.FAQSearchRequest{
+		QueryText:  secutils.SanitizeForLog(query),
+		MatchCount: matchCount,
+	})
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		return mcp.NewToolResultErrorFromErr("failed to search FAQ entries", err), nil
+	}
+	hits := make([]faqHit, 0, len(entries))
+	for _, entry := range entries {
+		hits = append(hits, faqHit{
+			ID:               entry.ID,
+			StandardQuestion: entry.StandardQuestion,
+			SimilarQuestions: entry.SimilarQuestions,
+			Answers:          entry.Answers,
+			Score:            entry.Score,
+		})
+	}
+	return mcp.NewToolResultJSON(map[string]interface{}{"entries": hits})
+}
+
+// getDocument handles the get_document tool
+func (s *Server) getDocument(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if err := checkScope(ctx, types.APIKeyScopeKBRead); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	knowledgeID, err := request.RequireString("knowledge_id")
+	if err != nil {
+		return mcp.NewToolResultError("knowledge_id is required"), nil
+	}
+	knowledge, err := s.readableKnowledge(ctx, secutils.SanitizeForLog(knowledgeID))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	text, err := s.documentText(ctx, knowledge)
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		return mcp.NewToolResultErrorFromErr("failed to read document", err), nil
+	}
+	return mcp.NewToolResultText(text), nil
+}
+
+// ask handles the ask tool
+func (s *Server) ask(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if err := checkScope(ctx, types.APIKeyScopeChatWrite); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	question, err := request.RequireString("question")
+	if err != nil || strings.TrimSpace(question) == "" {
+		return mcp.NewToolResultError("question is required"), nil
+	}
+	content, _ := json.Marshal(question)
+	completion, err := s.sessionHandler.Ask(ctx, &session.ChatCompletionRequest{
+		Model:     request.GetString("model", defaultAskModel),
+		Messages:  []session.ChatCompletionMessage{{Role: "user", Content: content}},
+		SessionID: request.GetString("session_id", ""),
+	})
+	if err != nil {
+		logger.ErrorWithFields(ctx, err, nil)
+		return mcp.NewToolResultErrorFromErr("failed to answer the question", err), nil
+	}
+
+	result := askResult{SessionID: completion.WeKnora.SessionID, References: searchHits(completion.WeKnora.References)}
+	if len(completion.Choices) > 0 && completion.Choices[0].Message != nil {
+		result.Answer = completion.Choices[0].Message.Content
+	}
+	return mcp.NewToolResultJSON(result)
+}
+
+// documentText returns the text of a document, made of its text chunks in order
+func (s *Server) documentText(ctx context.Context, knowledge *types.Knowledge) (string, error) {
+	chunks, err := s.chunkService.ListChunksByKnowledgeID(ctx, knowledge.ID)
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].ChunkIndex < chunks[j].ChunkIndex })
+
+	var text strings.Builder
+	text.WriteString("# " + knowledge.Title + "\n\n")
+	for _, chunk := range chunks {
+		if chunk.ChunkType != types.ChunkTypeText {
+			continue
+		}
+		text.WriteString(chunk.Content)
+		text.WriteString("\n\n")
+	}
+	return strings.TrimSpace(text.String()), nil
+}
+
+// searchHits converts search results to the passages returned by the tools
+func searchHits(results []*types.SearchResult) []searchHit {
+	hits := make([]searchHit, 0, len(results))
+	for _, result := range results {
+		hits = append(hits, searchHit{
+			KnowledgeID:    result.KnowledgeID,
+			KnowledgeTitle: result.KnowledgeTitle,
+			ChunkIndex:     result.ChunkIndex,
+			Content:        result.Content,
+			Score:          result.Score,
+		})
+	}
+	return hits
+}
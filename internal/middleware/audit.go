@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/Tencent/WeKnora/internal/types/interfaces"
+	"github.com/gin-gonic/gin"
+)
+
+// 不记录审计日志的非 GET 接口，这些接口只读取数据或测试连接
+var noAuditAPI = map[string][]string{
+	"/api/v1/auth/register":                      {"POST"},
+	"/api/v1/auth/login":                         {"POST"},
+	"/api/v1/auth/refresh":                       {"POST"},
+	"/api/v1/knowledge-search":                   {"POST"},
+	"/api/v1/knowledge-bases/:id/faq/search":     {"POST"},
+	"/api/v1/knowledge-chat/:session_id":         {"POST"},
+	"/api/v1/agent-chat/:session_id":             {"POST"},
+	"/api/v1/sessions/:session_id/stop":          {"POST"},
+	"/v1/chat/completions":                       {"POST"},
+	"/v1/embeddings":                             {"POST"},
+	"/mcp":                                       {"POST", "DELETE"},
+	"/api/v1/evaluation/comparisons":             {"POST"},
+	"/api/v1/mcp-services/:id/test":              {"POST"},
+	"/api/v1/initialization/ollama/models/check": {"POST"},
+	"/api/v1/initialization/remote/check":        {"POST"},
+	"/api/v1/initialization/embedding/test":      {"POST"},
+	"/api/v1/initialization/rerank/check":        {"POST"},
+	"/api/v1/initialization/multimodal/test":     {"POST"},
+	"/api/v1/initialization/extract/*":           {"POST"},
+}
+
+// isNoAuditAPI checks whether a route is excluded from the audit log
+func isNoAuditAPI(route string, method string) bool {
+	for api, methods := range noAuditAPI {
+		if strings.HasSuffix(api, "*") {
+			if strings.HasPrefix(route, strings.TrimSuffix(api, "*")) && slices.Contains(methods, method) {
+				return true
+			}
+		} else if route == api && slices.Contains(methods, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// Audit records every authenticated data-changing request in the audit log, once it has been handled.
+// Handlers report the state of the target resource before and after the change, and mark the reads
+// which must be audited as well, such as exports, through the audit keys of the gin context.
+func Audit(auditService interfaces.AuditService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		route := c.FullPath()
+		tenantID := c.GetUint64(types.TenantIDContextKey.String())
+		if route == "" || tenantID == 0 {
+			return
+		}
+		method := c.Request.Method
+		switch method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			if !c.GetBool(types.AuditReadContextKey.String()) {
+				return
+			}
+		default:
+			if isNoAuditAPI(route, method) {
+				return
+			}
+		}
+
+		resourceType, resourceID := auditResource(c, route)
+		entry := &types.AuditLog{
+			TenantID:     tenantID,
+			Action:       method + " " + strings.TrimPrefix(route, "/api/v1"),
+			ResourceType: resourceType,
+			ResourceID:   resourceID,
+			Path:         c.Request.URL.Path,
+			StatusCode:   auditStatus(c),
+			RequestID:    c.GetString(types.RequestIDContextKey.String()),
+			ClientIP:     c.ClientIP(),
+		}
+		setAuditActor(c, entry)
+
+		before, _ := c.Get(types.AuditBeforeContextKey.String())
+		after, _ := c.Get(types.AuditAfterContextKey.String())
+		// Failures are logged by the audit service, the response has already been sent
+		_ = auditService.Record(c.Request.Context(), entry, before, after)
+	}
+}
+
+// auditStatus returns the status of the response, the error handler only writes errors after this middleware
+func auditStatus(c *gin.Context) int {
+	if c.Writer.Written() || len(c.Errors) == 0 {
+		return c.Writer.Status()
+	}
+	if appErr, ok := errors.IsAppError(c.Errors.Last().Err); ok {
+		return appErr.HTTPCode
+	}
+	return http.StatusInternalServerError
+}
+
+// auditResource derives the type of the target resource from the static segments of the route
+// and its ID from the route parameters, unless the handler reported the ID
+func auditResource(c *gin.Context, route string) (string, string) {
+	var segments, params []string
+	for _, segment := range strings.Split(strings.TrimPrefix(route, "/api/v1"), "/") {
+		switch {
+		case segment == "":
+		case strings.HasPrefix(segment, ":"):
+			params = append(params, c.Param(segment[1:]))
+		case strings.HasPrefix(segment, "*"):
+			params = append(params, strings.TrimPrefix(c.Param(segment[1:]), "/"))
+		default:
+			segments = append(segments, segment)
+		}
+	}
+	resourceID := strings.Join(params, "/")
+	if id := c.GetString(types.AuditResourceIDContextKey.String()); id != "" {
+		resourceID = id
+	}
+	return strings.Join(segments, "/"), resourceID
+}
+
+// setAuditActor records who performed the request
+func setAuditActor(c *gin.Context, entry *types.AuditLog) {
+	principal := types.PrincipalFromContext(c.Request.Context())
+	switch {
+	case principal != nil && principal.APIKey != nil:
+		entry.ActorType = types.AuditActorAPIKey
+		entry.ActorID = principal.APIKey.ID
+		entry.ActorName = principal.APIKey.Name
+	case principal != nil && principal.UserID != "":
+		entry.ActorType = types.AuditActorUser
+		entry.ActorID = principal.UserID
+		if value, ok := c.Get("user"); ok {
+			if user, ok := value.(*types.User); ok {
+				entry.ActorName = user.Username
+			}
+		}
+	default:
+		entry.ActorType = types.AuditActorTenantAPIKey
+	}
+}
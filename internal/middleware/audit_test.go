@@ -0,0 +1,319 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/errors"
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAuditService keeps the recorded audit logs in memory
+type fakeAuditService struct {
+	entries []*types.AuditLog
+	before  []any
+	after   []any
+}
+
+func (s *fakeAuditService) Record(ctx context.Context, entry *types.AuditLog, before, after any) error {
+	s.entries = append(s.entries, entry)
+	s.before = append(s.before, before)
+	s.after = append(s.after, after)
+	return nil
+}
+
+func (s *fakeAuditService) ListAuditLogs(
+	ctx context.Context, filter *types.AuditLogFilter, page *types.Pagination,
+) (*types.PageResult, error) {
+	return nil, nil
+}
+
+func (s *fakeAuditService) ExportAuditLogs(ctx context.Context, filter *types.AuditLogFilter, w io.Writer) error {
+	return nil
+}
+
+// newAuditTestRouter serves routes behind the error handler and the audit middleware,
+// for requests of tenant 1 made by principal
+func newAuditTestRouter(
+	auditService *fakeAuditService, principal *types.Principal, user *types.User,
+) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ErrorHandler())
+	r.Use(func(c *gin.Context) {
+		c.Set(types.TenantIDContextKey.String(), uint64(1))
+		c.Set(types.RequestIDContextKey.String(), "request1")
+		if user != nil {
+			c.Set("user", user)
+		}
+		if principal != nil {
+			ctx := context.WithValue(c.Request.Context(), types.PrincipalContextKey, principal)
+			c.Request = c.Request.WithContext(ctx)
+		}
+		c.Next()
+	})
+	r.Use(Audit(auditService))
+	return r
+}
+
+func serveAudit(r *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestAuditResource(t *testing.T) {
+	tests := []struct {
+		name         string
+		route        string
+		path         string
+		action       string
+		resourceType string
+		resourceID   string
+	}{
+		{
+			name:         "collection",
+			route:        "/api/v1/knowledge-bases",
+			path:         "/api/v1/knowledge-bases",
+			action:       "POST /knowledge-bases",
+			resourceType: "knowledge-bases",
+		},
+		{
+			name:         "resource",
+			route:        "/api/v1/knowledge-bases/:id",
+			path:         "/api/v1/knowledge-bases/kb1",
+			action:       "POST /knowledge-bases/:id",
+			resourceType: "knowledge-bases",
+			resourceID:   "kb1",
+		},
+		{
+			name:         "nested resource",
+			route:        "/api/v1/knowledge-bases/:id/members/:user_id",
+			path:         "/api/v1/knowledge-bases/kb1/members/u2",
+			action:       "POST /knowledge-bases/:id/members/:user_id",
+			resourceType: "knowledge-bases/members",
+			resourceID:   "kb1/u2",
+		},
+		{
+			name:         "wildcard",
+			route:        "/api/v1/files/*filepath",
+			path:         "/api/v1/files/a/b.png",
+			action:       "POST /files/*filepath",
+			resourceType: "files",
+			resourceID:   "a/b.png",
+		},
+		{
+			name:         "route outside /api/v1",
+			route:        "/v1/files",
+			path:         "/v1/files",
+			action:       "POST /v1/files",
+			resourceType: "v1/files",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditService := &fakeAuditService{}
+			r := newAuditTestRouter(auditService, nil, nil)
+			r.POST(tt.route, func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+			serveAudit(r, http.MethodPost, tt.path)
+			require.Len(t, auditService.entries, 1)
+			entry := auditService.entries[0]
+			assert.Equal(t, tt.action, entry.Action)
+			assert.Equal(t, tt.resourceType, entry.ResourceType)
+			assert.Equal(t, tt.resourceID, entry.ResourceID)
+			assert.Equal(t, tt.path, entry.Path)
+			assert.Equal(t, uint64(1), entry.TenantID)
+			assert.Equal(t, "request1", entry.RequestID)
+			assert.Equal(t, http.StatusCreated, entry.StatusCode)
+		})
+	}
+}
+
+func TestAuditReportedResourceAndChanges(t *testing.T) {
+	auditService := &fakeAuditService{}
+	r := newAuditTestRouter(auditService, nil, nil)
+	r.POST("/api/v1/knowledge-bases", func(c *gin.Context) {
+		// The ID of a created resource is only known to the handler
+		c.Set(types.AuditResourceIDContextKey.String(), "kb1")
+		c.Set(types.AuditBeforeContextKey.String(), map[string]any{"name": "old"})
+		c.Set(types.AuditAfterContextKey.String(), map[string]any{"name": "new"})
+		c.Status(http.StatusCreated)
+	})
+
+	serveAudit(r, http.MethodPost, "/api/v1/knowledge-bases")
+	require.Len(t, auditService.entries, 1)
+	assert.Equal(t, "kb1", auditService.entries[0].ResourceID)
+	assert.Equal(t, map[string]any{"name": "old"}, auditService.before[0])
+	assert.Equal(t, map[string]any{"name": "new"}, auditService.after[0])
+}
+
+func TestIsNoAuditAPI(t *testing.T) {
+	tests := []struct {
+		route    string
+		method   string
+		excluded bool
+	}{
+		{"/api/v1/auth/login", http.MethodPost, true},
+		{"/api/v1/knowledge-search", http.MethodPost, true},
+		{"/mcp", http.MethodDelete, true},
+		{"/api/v1/initialization/extract/text-relation", http.MethodPost, true},
+		{"/api/v1/initialization/extract/fabri-tag", http.MethodPost, true},
+		// Only the listed methods are excluded
+		{"/api/v1/knowledge-search", http.MethodPut, false},
+		{"/api/v1/initialization/extract/text-relation", http.MethodDelete, false},
+		{"/api/v1/knowledge-bases", http.MethodPost, false},
+		{"/api/v1/knowledge-bases/:id", http.MethodDelete, false},
+		// Routes are matched exactly unless the excluded route ends with *
+		{"/api/v1/auth/login/extra", http.MethodPost, false},
+		{"/api/v1/initialization/extract", http.MethodPost, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.route, func(t *testing.T) {
+			assert.Equal(t, tt.excluded, isNoAuditAPI(tt.route, tt.method))
+		})
+	}
+}
+
+func TestAuditSkippedRequests(t *testing.T) {
+	auditService := &fakeAuditService{}
+	r := newAuditTestRouter(auditService, nil, nil)
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.POST("/api/v1/knowledge-search", ok)
+	r.GET("/api/v1/knowledge-bases/:id", ok)
+	r.HEAD("/api/v1/knowledge-bases/:id", ok)
+
+	serveAudit(r, http.MethodPost, "/api/v1/knowledge-search")
+	serveAudit(r, http.MethodGet, "/api/v1/knowledge-bases/kb1")
+	serveAudit(r, http.MethodHead, "/api/v1/knowledge-bases/kb1")
+	// Unmatched routes have no resource to record
+	serveAudit(r, http.MethodPost, "/api/v1/unknown")
+	assert.Empty(t, auditService.entries)
+
+	// Requests without a tenant are not recorded either
+	gin.SetMode(gin.TestMode)
+	anonymous := gin.New()
+	anonymous.Use(Audit(auditService))
+	anonymous.POST("/api/v1/auth/register", ok)
+	serveAudit(anonymous, http.MethodPost, "/api/v1/auth/register")
+	assert.Empty(t, auditService.entries)
+}
+
+func TestAuditMarkedRead(t *testing.T) {
+	auditService := &fakeAuditService{}
+	r := newAuditTestRouter(auditService, nil, nil)
+	r.GET("/api/v1/audit-logs/export", func(c *gin.Context) {
+		c.Set(types.AuditReadContextKey.String(), true)
+		c.Status(http.StatusOK)
+	})
+
+	serveAudit(r, http.MethodGet, "/api/v1/audit-logs/export")
+	require.Len(t, auditService.entries, 1)
+	assert.Equal(t, "GET /audit-logs/export", auditService.entries[0].Action)
+	assert.Equal(t, "audit-logs/export", auditService.entries[0].ResourceType)
+}
+
+func TestAuditStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+		status  int
+	}{
+		{
+			name:    "written response",
+			handler: func(c *gin.Context) { c.JSON(http.StatusAccepted, gin.H{}) },
+			status:  http.StatusAccepted,
+		},
+		{
+			name:    "application error",
+			handler: func(c *gin.Context) { _ = c.Error(errors.NewForbiddenError("forbidden")) },
+			status:  http.StatusForbidden,
+		},
+		{
+			name:    "other error",
+			handler: func(c *gin.Context) { _ = c.Error(io.ErrUnexpectedEOF) },
+			status:  http.StatusInternalServerError,
+		},
+		{
+			name: "error after the response was written",
+			handler: func(c *gin.Context) {
+				c.Status(http.StatusOK)
+				c.Writer.WriteHeaderNow()
+				_ = c.Error(errors.NewForbiddenError("forbidden"))
+			},
+			status: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditService := &fakeAuditService{}
+			r := newAuditTestRouter(auditService, nil, nil)
+			r.DELETE("/api/v1/knowledge-bases/:id", tt.handler)
+
+			w := serveAudit(r, http.MethodDelete, "/api/v1/knowledge-bases/kb1")
+			require.Len(t, auditService.entries, 1)
+			assert.Equal(t, tt.status, auditService.entries[0].StatusCode)
+			// The recorded status is the status of the response
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestAuditActor(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *types.Principal
+		user      *types.User
+		actorType types.AuditActorType
+		actorID   string
+		actorName string
+	}{
+		{
+			name:      "user",
+			principal: &types.Principal{UserID: "u1", Role: types.TenantRoleEditor},
+			user:      &types.User{ID: "u1", Username: "alice"},
+			actorType: types.AuditActorUser,
+			actorID:   "u1",
+			actorName: "alice",
+		},
+		{
+			name: "scoped API key",
+			principal: &types.Principal{
+				Role:   types.TenantRoleOwner,
+				APIKey: &types.APIKey{ID: "key1", Name: "ci"},
+			},
+			actorType: types.AuditActorAPIKey,
+			actorID:   "key1",
+			actorName: "ci",
+		},
+		{
+			name:      "tenant API key",
+			principal: &types.Principal{Role: types.TenantRoleOwner},
+			actorType: types.AuditActorTenantAPIKey,
+		},
+		{
+			name:      "no principal",
+			actorType: types.AuditActorTenantAPIKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditService := &fakeAuditService{}
+			r := newAuditTestRouter(auditService, tt.principal, tt.user)
+			r.PUT("/api/v1/knowledge-bases/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			serveAudit(r, http.MethodPut, "/api/v1/knowledge-bases/kb1")
+			require.Len(t, auditService.entries, 1)
+			entry := auditService.entries[0]
+			assert.Equal(t, tt.actorType, entry.ActorType)
+			assert.Equal(t, tt.actorID, entry.ActorID)
+			assert.Equal(t, tt.actorName, entry.ActorName)
+		})
+	}
+}
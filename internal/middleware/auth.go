@@ -15,6 +15,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// errInvalidAPIKey is returned for an API key that does not match a tenant or a scoped API key
+var errInvalidAPIKey = errors.New("Unauthorized: invalid API key")
+
 // 无需认证的API列表
 var noAuthAPI = map[string][]string{
 	"/health":               {"GET"},
@@ -60,6 +63,8 @@ func canAccessTenant(user *types.User, targetTenantID uint64, cfg *config.Config
 func Auth(
 	tenantService interfaces.TenantService,
 	userService interfaces.UserService,
+	permissionService interfaces.PermissionService,
+	apiKeyService interfaces.APIKeyService,
 	cfg *config.Config,
 ) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -84,17 +89,20 @@ func Auth(
 				// JWT Token认证成功
 				// 检查是否有跨租户访问请求
 				targetTenantID := user.TenantID
+				var principal *types.Principal
 				tenantHeader := c.GetHeader("X-Tenant-ID")
 				if tenantHeader != "" {
 					// 解析目标租户ID
 					parsedTenantID, err := strconv.ParseUint(tenantHeader, 10, 64)
-					if err == nil {
+					if err == nil && parsedTenantID != user.TenantID {
 						// 检查用户是否有跨租户访问权限
 						if canAccessTenant(user, parsedTenantID, cfg) {
 							// 验证目标租户是否存在
 							targetTenant, err := tenantService.GetTenantByID(c.Request.Context(), parsedTenantID)
 							if err == nil && targetTenant != nil {
 								targetTenantID = parsedTenantID
+								// 跨租户管理员在目标租户中拥有所有者权限
+								principal = &types.Principal{UserID: user.ID, Role: types.TenantRoleOwner}
 								log.Printf("User %s switching to tenant %d", user.ID, targetTenantID)
 							} else {
 								log.Printf("Error getting target tenant by ID: %v, tenantID: %d", err, parsedTenantID)
@@ -104,6 +112,13 @@ func Auth(
 								c.Abort()
 								return
 							}
+						} else if memberPrincipal, err := permissionService.ResolvePrincipal(
+							c.Request.Context(), user, parsedTenantID,
+						); err == nil {
+							// 租户成员按其角色访问目标租户
+							targetTenantID = parsedTenantID
+							principal = memberPrincipal
+							log.Printf("User %s switching to tenant %d as %s", user.ID, targetTenantID, principal.Role)
 						} else {
 							// 用户没有权限访问目标租户
 							log.Printf("User %s attempted to access tenant %d without permission", user.ID, parsedTenantID)
@@ -127,15 +142,32 @@ func Auth(
 					return
 				}
 
+				// 解析用户在租户中的角色和知识库权限
+				if principal == nil {
+					principal, err = permissionService.ResolvePrincipal(c.Request.Context(), user, targetTenantID)
+					if err != nil {
+						log.Printf("Error resolving permissions of user %s in tenant %d: %v", user.ID, targetTenantID, err)
+						c.JSON(http.StatusForbidden, gin.H{
+							"error": "Forbidden: insufficient permissions to access target tenant",
+						})
+						c.Abort()
+						return
+					}
+				}
+
 				// 存储用户和租户信息到上下文
 				c.Set(types.TenantIDContextKey.String(), targetTenantID)
 				c.Set(types.TenantInfoContextKey.String(), tenant)
+				c.Set(types.PrincipalContextKey.String(), principal)
 				c.Set("user", user)
 				c.Request = c.Request.WithContext(
 					context.WithValue(
 						context.WithValue(
-							context.WithValue(c.Request.Context(), types.TenantIDContextKey, targetTenantID),
-							types.TenantInfoContextKey, tenant,
+							context.WithValue(
+								context.WithValue(c.Request.Context(), types.TenantIDContextKey, targetTenantID),
+								types.TenantInfoContextKey, tenant,
+							),
+							types.PrincipalContextKey, principal,
 						),
 						"user", user,
 					),
@@ -147,45 +179,22 @@ func Auth(
 
 		// 尝试X-API-Key认证（兼容模式）
 		apiKey := c.GetHeader("X-API-Key")
+		if apiKey == "" {
+			// OpenAI 兼容客户端通过 Authorization: Bearer 传递 API Key
+			apiKey = bearerAPIKey(authHeader)
+		}
 		if apiKey != "" {
-			// Get tenant information
-			tenantID, err := tenantService.ExtractTenantIDFromAPIKey(apiKey)
+			tenantID, t, principal, err := AuthenticateAPIKey(c.Request.Context(), tenantService, apiKeyService, apiKey)
 			if err != nil {
 				c.JSON(http.StatusUnauthorized, gin.H{
-					"error": "Unauthorized: invalid API key format",
-				})
-				c.Abort()
-				return
-			}
-
-			// Verify API key validity (matches the one in database)
-			t, err := tenantService.GetTenantByID(c.Request.Context(), tenantID)
-			if err != nil {
-				log.Printf("Error getting tenant by ID: %v, tenantID: %d", err, tenantID)
-				c.JSON(http.StatusUnauthorized, gin.H{
-					"error": "Unauthorized: invalid API key",
-				})
-				c.Abort()
-				return
-			}
-
-			if t == nil || t.APIKey != apiKey {
-				c.JSON(http.StatusUnauthorized, gin.H{
-					"error": "Unauthorized: invalid API key",
+					"error": err.Error(),
 				})
 				c.Abort()
 				return
 			}
 
 			// Store tenant ID in context
-			c.Set(types.TenantIDContextKey.String(), tenantID)
-			c.Set(types.TenantInfoContextKey.String(), t)
-			c.Request = c.Request.WithContext(
-				context.WithValue(
-					context.WithValue(c.Request.Context(), types.TenantIDContextKey, tenantID),
-					types.TenantInfoContextKey, t,
-				),
-			)
+			setAPIKeyContext(c, tenantID, t, principal)
 			c.Next()
 			return
 		}
@@ -196,6 +205,79 @@ func Auth(
 	}
 }
 
+// bearerAPIKey returns the API key carried as a bearer token, as sent by OpenAI compatible clients
+func bearerAPIKey(authHeader string) string {
+	token, ok := strings.CutPrefix(authHeader, "Bearer ")
+	if !ok || !(strings.HasPrefix(token, types.APIKeyPrefix) || strings.HasPrefix(token, "sk-")) {
+		return ""
+	}
+	return token
+}
+
+// AuthenticateAPIKey authenticates a tenant API key or a scoped API key and returns the tenant and the principal
+// of its requests. The error message is the one returned to the client.
+func AuthenticateAPIKey(
+	ctx context.Context,
+	tenantService interfaces.TenantService,
+	apiKeyService interfaces.APIKeyService,
+	apiKey string,
+) (uint64, *types.Tenant, *types.Principal, error) {
+	if strings.HasPrefix(apiKey, types.APIKeyPrefix) {
+		// Scoped API key, restricted to its scopes and knowledge bases
+		key, err := apiKeyService.Authenticate(ctx, apiKey)
+		if err != nil {
+			log.Printf("Error authenticating scoped API key: %v", err)
+			return 0, nil, nil, errInvalidAPIKey
+		}
+		t, err := tenantService.GetTenantByID(ctx, key.TenantID)
+		if err != nil || t == nil {
+			log.Printf("Error getting tenant by ID: %v, tenantID: %d", err, key.TenantID)
+			return 0, nil, nil, errInvalidAPIKey
+		}
+		return key.TenantID, t, &types.Principal{Role: types.TenantRoleOwner, APIKey: key}, nil
+	}
+
+	// Get tenant information
+	tenantID, err := tenantService.ExtractTenantIDFromAPIKey(apiKey)
+	if err != nil {
+		return 0, nil, nil, errors.New("Unauthorized: invalid API key format")
+	}
+
+	// Verify API key validity (matches the one in database)
+	t, err := tenantService.GetTenantByID(ctx, tenantID)
+	if err != nil {
+		log.Printf("Error getting tenant by ID: %v, tenantID: %d", err, tenantID)
+		return 0, nil, nil, errInvalidAPIKey
+	}
+	if t == nil || t.APIKey != apiKey {
+		return 0, nil, nil, errInvalidAPIKey
+	}
+
+	// The tenant API key has the rights of the tenant owner
+	return tenantID, t, &types.Principal{Role: types.TenantRoleOwner}, nil
+}
+
+// setAPIKeyContext stores the tenant and the principal of a request authenticated with an API key
+func setAPIKeyContext(c *gin.Context, tenantID uint64, tenant *types.Tenant, principal *types.Principal) {
+	c.Set(types.TenantIDContextKey.String(), tenantID)
+	c.Set(types.TenantInfoContextKey.String(), tenant)
+	c.Set(types.PrincipalContextKey.String(), principal)
+	c.Request = c.Request.WithContext(WithAPIKeyContext(c.Request.Context(), tenantID, tenant, principal))
+}
+
+// WithAPIKeyContext returns a context carrying the tenant and the principal of an API key
+func WithAPIKeyContext(
+	ctx context.Context, tenantID uint64, tenant *types.Tenant, principal *types.Principal,
+) context.Context {
+	return context.WithValue(
+		context.WithValue(
+			context.WithValue(ctx, types.TenantIDContextKey, tenantID),
+			types.TenantInfoContextKey, tenant,
+		),
+		types.PrincipalContextKey, principal,
+	)
+}
+
 // GetTenantIDFromContext helper function to get tenant ID from context
 func GetTenantIDFromContext(ctx context.Context) (uint64, error) {
 	tenantID, ok := ctx.Value("tenantID").(uint64)
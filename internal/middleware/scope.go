@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScope restricts a route group to scoped API keys granting the given scope.
+// Requests authenticated otherwise are governed by the role of the caller only.
+func RequireScope(scope types.APIKeyScope) gin.HandlerFunc {
+	return RequireScopes(scope, scope)
+}
+
+// RequireScopes restricts a route group to scoped API keys granting readScope for GET and HEAD requests
+// and writeScope for other requests
+func RequireScopes(readScope, writeScope types.APIKeyScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := types.PrincipalFromContext(c.Request.Context())
+		if principal == nil || principal.APIKey == nil {
+			c.Next()
+			return
+		}
+		scope := writeScope
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			scope = readScope
+		}
+		if !principal.APIKey.HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Forbidden: API key lacks scope " + string(scope),
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// newScopeTestRouter serves /kb behind RequireScopes(kb:read, kb:write) for requests made by principal
+func newScopeTestRouter(principal *types.Principal) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if principal != nil {
+			ctx := context.WithValue(c.Request.Context(), types.PrincipalContextKey, principal)
+			c.Request = c.Request.WithContext(ctx)
+		}
+		c.Next()
+	})
+	kb := r.Group("/kb", RequireScopes(types.APIKeyScopeKBRead, types.APIKeyScopeKBWrite))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	kb.GET("", ok)
+	kb.HEAD("", ok)
+	kb.POST("", ok)
+	kb.DELETE("", ok)
+	return r
+}
+
+func TestRequireScopes(t *testing.T) {
+	scopedKey := func(scopes ...string) *types.Principal {
+		return &types.Principal{Role: types.TenantRoleOwner, APIKey: &types.APIKey{Scopes: scopes}}
+	}
+	tests := []struct {
+		name      string
+		principal *types.Principal
+		method    string
+		status    int
+	}{
+		{name: "internal call", method: http.MethodPost, status: http.StatusOK},
+		{
+			name:      "JWT user",
+			principal: &types.Principal{UserID: "u1", Role: types.TenantRoleViewer},
+			method:    http.MethodPost,
+			status:    http.StatusOK,
+		},
+		{
+			name:      "tenant API key",
+			principal: &types.Principal{Role: types.TenantRoleOwner},
+			method:    http.MethodDelete,
+			status:    http.StatusOK,
+		},
+		{
+			name:      "GET checks the read scope",
+			principal: scopedKey("kb:read"),
+			method:    http.MethodGet,
+			status:    http.StatusOK,
+		},
+		{
+			name:      "HEAD checks the read scope",
+			principal: scopedKey("kb:read"),
+			method:    http.MethodHead,
+			status:    http.StatusOK,
+		},
+		{
+			name:      "POST checks the write scope",
+			principal: scopedKey("kb:read"),
+			method:    http.MethodPost,
+			status:    http.StatusForbidden,
+		},
+		{
+			name:      "DELETE checks the write scope",
+			principal: scopedKey("kb:read"),
+			method:    http.MethodDelete,
+			status:    http.StatusForbidden,
+		},
+		{name: "write scope reads", principal: scopedKey("kb:write"), method: http.MethodGet, status: http.StatusOK},
+		{name: "write scope writes", principal: scopedKey("kb:write"), method: http.MethodPost, status: http.StatusOK},
+		{name: "admin scope writes", principal: scopedKey("admin"), method: http.MethodPost, status: http.StatusOK},
+		{
+			name:      "key without the scope",
+			principal: scopedKey("chat:write"),
+			method:    http.MethodGet,
+			status:    http.StatusForbidden,
+		},
+		{name: "key without scopes", principal: scopedKey(), method: http.MethodGet, status: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newScopeTestRouter(tt.principal).ServeHTTP(w, httptest.NewRequest(tt.method, "/kb", nil))
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusForbidden && tt.method != http.MethodHead {
+				assert.Contains(t, w.Body.String(), "API key lacks scope")
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		principal := &types.Principal{
+			Role: types.TenantRoleOwner, APIKey: &types.APIKey{Scopes: types.StringArray{"kb:write"}},
+		}
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), types.PrincipalContextKey, principal))
+		c.Next()
+	})
+	r.GET("/webhooks", RequireScope(types.APIKeyScopeAdmin), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// Reads need the scope too
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/webhooks", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "admin")
+}
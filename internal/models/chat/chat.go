@@ -44,6 +44,7 @@ type Message struct {
 	Name       string     `json:"name,omitempty"`         // Function/tool name (for tool role)
 	ToolCallID string     `json:"tool_call_id,omitempty"` // Tool call ID (for tool role)
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // Tool calls (for assistant role)
+	Images     []string   `json:"images,omitempty"`       // 图片 URL 或 data URL（用于 user 角色，需模型支持视觉）
 }
 
 // ToolCall represents a tool call in a message
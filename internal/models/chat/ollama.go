@@ -2,7 +2,9 @@ package chat
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"strings"
 
 	"github.com/Tencent/WeKnora/internal/logger"
 	"github.com/Tencent/WeKnora/internal/models/utils/ollama"
@@ -27,26 +29,46 @@ func NewOllamaChat(config *ChatConfig, ollamaService *ollama.OllamaService) (*Ol
 }
 
 // convertMessages 转换消息格式为Ollama API格式
-func (c *OllamaChat) convertMessages(messages []Message) []ollamaapi.Message {
+// Ollama 只接受图片原始数据，非 data URL 的图片会被忽略
+func (c *OllamaChat) convertMessages(ctx context.Context, messages []Message) []ollamaapi.Message {
 	ollamaMessages := make([]ollamaapi.Message, len(messages))
 	for i, msg := range messages {
 		ollamaMessages[i] = ollamaapi.Message{
 			Role:    msg.Role,
 			Content: msg.Content,
 		}
+		for _, image := range msg.Images {
+			data, err := decodeDataURL(image)
+			if err != nil {
+				logger.GetLogger(ctx).Warnf("忽略模型 %s 不支持的图片: %v", c.modelName, err)
+				continue
+			}
+			ollamaMessages[i].Images = append(ollamaMessages[i].Images, ollamaapi.ImageData(data))
+		}
 	}
 	return ollamaMessages
 }
 
+// decodeDataURL 解码 base64 编码的 data URL（data:<mime>;base64,<data>）
+func decodeDataURL(url string) ([]byte, error) {
+	header, data, ok := strings.Cut(url, ",")
+	if !ok || !strings.HasPrefix(header, "data:") || !strings.HasSuffix(header, ";base64") {
+		return nil, fmt.Errorf("not a base64 data URL")
+	}
+	return base64.StdEncoding.DecodeString(data)
+}
+
 // buildChatRequest 构建聊天请求参数
-func (c *OllamaChat) buildChatRequest(messages []Message, opts *ChatOptions, isStream bool) *ollamaapi.ChatRequest {
+func (c *OllamaChat) buildChatRequest(
+	ctx context.Context, messages []Message, opts *ChatOptions, isStream bool,
+) *ollamaapi.ChatRequest {
 	// 设置流式标志
 	streamFlag := isStream
 
 	// 构建请求参数
 	chatReq := &ollamaapi.ChatRequest{
 		Model:    c.modelName,
-		Messages: c.convertMessages(messages),
+		Messages: c.convertMessages(ctx, messages),
 		Stream:   &streamFlag,
 		Options:  make(map[string]interface{}),
 	}
@@ -80,7 +102,7 @@ func (c *OllamaChat) Chat(ctx context.Context, messages []Message, opts *ChatOpt
 	}
 
 	// 构建请求参数
-	chatReq := c.buildChatRequest(messages, opts, false)
+	chatReq := c.buildChatRequest(ctx, messages, opts, false)
 
 	// 记录请求日志
 	logger.GetLogger(ctx).Infof("发送聊天请求到模型 %s", c.modelName)
@@ -131,7 +153,7 @@ func (c *OllamaChat) ChatStream(
 	}
 
 	// 构建请求参数
-	chatReq := c.buildChatRequest(messages, opts, true)
+	chatReq := c.buildChatRequest(ctx, messages, opts, true)
 
 	// 记录请求日志
 	logger.GetLogger(ctx).Infof("发送流式聊天请求到模型 %s", c.modelName)
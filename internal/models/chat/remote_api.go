@@ -65,7 +65,11 @@ func (c *RemoteAPIChat) convertMessages(messages []Message) []openai.ChatComplet
 		case "system":
 			openaiMessages = append(openaiMessages, openai.SystemMessage(msg.Content))
 		case "user":
-			openaiMessages = append(openaiMessages, openai.UserMessage(msg.Content))
+			if len(msg.Images) > 0 {
+				openaiMessages = append(openaiMessages, openai.UserMessage(userContentParts(msg)))
+			} else {
+				openaiMessages = append(openaiMessages, openai.UserMessage(msg.Content))
+			}
 		case "assistant":
 			if len(msg.ToolCalls) > 0 {
 				toolCalls := make([]openai.ChatCompletionMessageToolCallUnionParam, 0, len(msg.ToolCalls))
@@ -99,6 +103,16 @@ func (c *RemoteAPIChat) convertMessages(messages []Message) []openai.ChatComplet
 	return openaiMessages
 }
 
+// userContentParts 将带图片的用户消息转换为文本和图片内容片段
+func userContentParts(msg Message) []openai.ChatCompletionContentPartUnionParam {
+	parts := make([]openai.ChatCompletionContentPartUnionParam, 0, len(msg.Images)+1)
+	parts = append(parts, openai.TextContentPart(msg.Content))
+	for _, image := range msg.Images {
+		parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: image}))
+	}
+	return parts
+}
+
 // convertMessagesToRaw 转换消息格式为原始 map 格式（用于自定义请求）
 func (c *RemoteAPIChat) convertMessagesToRaw(messages []Message) []map[string]any {
 	rawMessages := make([]map[string]any, 0, len(messages))
@@ -107,6 +121,16 @@ func (c *RemoteAPIChat) convertMessagesToRaw(messages []Message) []map[string]an
 			"role":    msg.Role,
 			"content": msg.Content,
 		}
+		if len(msg.Images) > 0 {
+			content := []map[string]any{{"type": "text", "text": msg.Content}}
+			for _, image := range msg.Images {
+				content = append(content, map[string]any{
+					"type":      "image_url",
+					"image_url": map[string]any{"url": image},
+				})
+			}
+			rawMsg["content"] = content
+		}
 		if len(msg.ToolCalls) > 0 {
 			toolCalls := make([]map[string]any, 0, len(msg.ToolCalls))
 			for _, tc := range msg.ToolCalls {
@@ -2,6 +2,7 @@ package chat
 
 import (
 	"context"
+	"encoding/json"
 	"os"
 	"testing"
 	"time"
@@ -125,3 +126,30 @@ func TestRemoteAPIChat(t *testing.T) {
 		})
 	}
 }
+
+// TestRemoteAPIChatImageMessages 测试带图片的用户消息转换为文本和图片内容片段
+func TestRemoteAPIChatImageMessages(t *testing.T) {
+	chat, err := NewRemoteAPIChat(&ChatConfig{ModelName: "gpt-4o"})
+	require.NoError(t, err)
+	messages := []Message{
+		{Role: "system", Content: "你是一个助手"},
+		{Role: "user", Content: "这个报错是什么意思？", Images: []string{"data:image/png;base64,iVBORw0KGgo="}},
+	}
+
+	body, err := json.Marshal(chat.convertMessages(messages))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"role": "system", "content": "你是一个助手"},
+		{"role": "user", "content": [
+			{"type": "text", "text": "这个报错是什么意思？"},
+			{"type": "image_url", "image_url": {"url": "data:image/png;base64,iVBORw0KGgo="}}
+		]}
+	]`, string(body))
+
+	raw := chat.convertMessagesToRaw(messages)
+	assert.Equal(t, "你是一个助手", raw[0]["content"])
+	assert.Equal(t, []map[string]any{
+		{"type": "text", "text": "这个报错是什么意思？"},
+		{"type": "image_url", "image_url": map[string]any{"url": "data:image/png;base64,iVBORw0KGgo="}},
+	}, raw[1]["content"])
+}
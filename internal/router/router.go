@@ -12,7 +12,9 @@ import (
 	"github.com/Tencent/WeKnora/internal/config"
 	"github.com/Tencent/WeKnora/internal/handler"
 	"github.com/Tencent/WeKnora/internal/handler/session"
+	"github.com/Tencent/WeKnora/internal/mcpserver"
 	"github.com/Tencent/WeKnora/internal/middleware"
+	"github.com/Tencent/WeKnora/internal/types"
 	"github.com/Tencent/WeKnora/internal/types/interfaces"
 
 	_ "github.com/Tencent/WeKnora/docs" // swagger docs
@@ -35,6 +37,9 @@ type RouterParams struct {
 	KnowledgeHandler      *handler.KnowledgeHandler
 	TenantHandler         *handler.TenantHandler
 	TenantService         interfaces.TenantService
+	PermissionService     interfaces.PermissionService
+	APIKeyService         interfaces.APIKeyService
+	AuditService          interfaces.AuditService
 	ChunkHandler          *handler.ChunkHandler
 	SessionHandler        *session.Handler
 	MessageHandler        *handler.MessageHandler
@@ -47,6 +52,14 @@ type RouterParams struct {
 	WebSearchHandler      *handler.WebSearchHandler
 	FAQHandler            *handler.FAQHandler
 	TagHandler            *handler.TagHandler
+	PermissionHandler     *handler.PermissionHandler
+	APIKeyHandler         *handler.APIKeyHandler
+	UsageHandler          *handler.UsageHandler
+	AuditHandler          *handler.AuditHandler
+	WebhookHandler        *handler.WebhookHandler
+	MemoryHandler         *handler.MemoryHandler
+	FeedbackHandler       *handler.FeedbackHandler
+	MCPServer             *mcpserver.Server
 }
 
 // NewRouter 创建新的路由
@@ -86,7 +99,12 @@ func NewRouter(params RouterParams) *gin.Engine {
 	}
 
 	// 认证中间件
-	r.Use(middleware.Auth(params.TenantService, params.UserService, params.Config))
+	r.Use(middleware.Auth(
+		params.TenantService, params.UserService, params.PermissionService, params.APIKeyService, params.Config,
+	))
+
+	// 审计日志中间件，记录认证后的修改操作
+	r.Use(middleware.Audit(params.AuditService))
 
 	// 添加OpenTelemetry追踪中间件
 	r.Use(middleware.TracingMiddleware())
@@ -96,6 +114,11 @@ func NewRouter(params RouterParams) *gin.Engine {
 	{
 		RegisterAuthRoutes(v1, params.AuthHandler)
 		RegisterTenantRoutes(v1, params.TenantHandler)
+		RegisterPermissionRoutes(v1, params.PermissionHandler)
+		RegisterAPIKeyRoutes(v1, params.APIKeyHandler)
+		RegisterUsageRoutes(v1, params.UsageHandler)
+		RegisterAuditRoutes(v1, params.AuditHandler)
+		RegisterWebhookRoutes(v1, params.WebhookHandler)
 		RegisterKnowledgeBaseRoutes(v1, params.KBHandler)
 		RegisterKnowledgeTagRoutes(v1, params.TagHandler)
 		RegisterKnowledgeRoutes(v1, params.KnowledgeHandler)
@@ -104,6 +127,8 @@ func NewRouter(params RouterParams) *gin.Engine {
 		RegisterSessionRoutes(v1, params.SessionHandler)
 		RegisterChatRoutes(v1, params.SessionHandler)
 		RegisterMessageRoutes(v1, params.MessageHandler)
+		RegisterMemoryRoutes(v1, params.MemoryHandler)
+		RegisterFeedbackRoutes(v1, params.FeedbackHandler)
 		RegisterModelRoutes(v1, params.ModelHandler)
 		RegisterEvaluationRoutes(v1, params.EvaluationHandler)
 		RegisterInitializationRoutes(v1, params.InitializationHandler)
@@ -112,13 +137,24 @@ func NewRouter(params RouterParams) *gin.Engine {
 		RegisterWebSearchRoutes(v1, params.WebSearchHandler)
 	}
 
+	// OpenAI 兼容接口，供 OpenAI SDK 等客户端直接接入
+	RegisterOpenAIRoutes(r.Group("/v1"), params.SessionHandler)
+
+	// MCP 服务端，供 IDE Agent、桌面助手等 MCP 客户端检索知识库
+	RegisterMCPServerRoutes(r, params.MCPServer)
+
 	return r
 }
 
+// kbScopes 要求 API Key 读取知识库时具有 kb:read 权限，修改知识库时具有 kb:write 权限
+func kbScopes() gin.HandlerFunc {
+	return middleware.RequireScopes(types.APIKeyScopeKBRead, types.APIKeyScopeKBWrite)
+}
+
 // RegisterChunkRoutes 注册分块相关的路由
 func RegisterChunkRoutes(r *gin.RouterGroup, handler *handler.ChunkHandler) {
 	// 分块路由组
-	chunks := r.Group("/chunks")
+	chunks := r.Group("/chunks", kbScopes())
 	{
 		// 获取分块列表
 		chunks.GET("/:knowledge_id", handler.ListKnowledgeChunks)
@@ -138,7 +174,7 @@ func RegisterChunkRoutes(r *gin.RouterGroup, handler *handler.ChunkHandler) {
 // RegisterKnowledgeRoutes 注册知识相关的路由
 func RegisterKnowledgeRoutes(r *gin.RouterGroup, handler *handler.KnowledgeHandler) {
 	// 知识库下的知识路由组
-	kb := r.Group("/knowledge-bases/:id/knowledge")
+	kb := r.Group("/knowledge-bases/:id/knowledge", kbScopes())
 	{
 		// 从文件创建知识
 		kb.POST("/file", handler.CreateKnowledgeFromFile)
@@ -151,7 +187,7 @@ func RegisterKnowledgeRoutes(r *gin.RouterGroup, handler *handler.KnowledgeHandl
 	}
 
 	// 知识路由组
-	k := r.Group("/knowledge")
+	k := r.Group("/knowledge", kbScopes())
 	{
 		// 批量获取知识
 		k.GET("/batch", handler.GetKnowledgeBatch)
@@ -163,6 +199,12 @@ func RegisterKnowledgeRoutes(r *gin.RouterGroup, handler *handler.KnowledgeHandl
 		k.PUT("/:id", handler.UpdateKnowledge)
 		// 更新手工 Markdown 知识
 		k.PUT("/manual/:id", handler.UpdateManualKnowledge)
+		// 更新知识文件并增量重建分块
+		k.PUT("/:id/file", handler.UpdateKnowledgeFile)
+		// 设置 URL 知识定时刷新计划
+		k.PUT("/:id/refresh-schedule", handler.UpdateURLRefreshSchedule)
+		// 立即刷新 URL 知识
+		k.POST("/:id/refresh", handler.RefreshURLKnowledge)
 		// 获取知识文件
 		k.GET("/:id/download", handler.DownloadKnowledgeFile)
 		// 更新图像分块信息
@@ -179,7 +221,7 @@ func RegisterFAQRoutes(r *gin.RouterGroup, handler *handler.FAQHandler) {
 	if handler == nil {
 		return
 	}
-	faq := r.Group("/knowledge-bases/:id/faq")
+	faq := r.Group("/knowledge-bases/:id/faq", kbScopes())
 	{
 		faq.GET("/entries", handler.ListEntries)
 		faq.GET("/entries/export", handler.ExportEntries)
@@ -191,10 +233,11 @@ func RegisterFAQRoutes(r *gin.RouterGroup, handler *handler.FAQHandler) {
 		faq.PUT("/entries/fields", handler.UpdateEntryFieldsBatch)
 		faq.PUT("/entries/tags", handler.UpdateEntryTagBatch)
 		faq.DELETE("/entries", handler.DeleteEntries)
-		faq.POST("/search", handler.SearchFAQ)
 	}
+	// FAQ search only reads the knowledge base
+	r.POST("/knowledge-bases/:id/faq/search", middleware.RequireScope(types.APIKeyScopeKBRead), handler.SearchFAQ)
 	// FAQ import progress route (outside of knowledge-base scope)
-	faqImport := r.Group("/faq/import")
+	faqImport := r.Group("/faq/import", kbScopes())
 	{
 		faqImport.GET("/progress/:task_id", handler.GetImportProgress)
 	}
@@ -203,7 +246,7 @@ func RegisterFAQRoutes(r *gin.RouterGroup, handler *handler.FAQHandler) {
 // RegisterKnowledgeBaseRoutes 注册知识库相关的路由
 func RegisterKnowledgeBaseRoutes(r *gin.RouterGroup, handler *handler.KnowledgeBaseHandler) {
 	// 知识库路由组
-	kb := r.Group("/knowledge-bases")
+	kb := r.Group("/knowledge-bases", kbScopes())
 	{
 		// 创建知识库
 		kb.POST("", handler.CreateKnowledgeBase)
@@ -221,6 +264,10 @@ func RegisterKnowledgeBaseRoutes(r *gin.RouterGroup, handler *handler.KnowledgeB
 		kb.POST("/copy", handler.CopyKnowledgeBase)
 		// 获取知识库复制进度
 		kb.GET("/copy/progress/:task_id", handler.GetKBCloneProgress)
+		// 使用新的嵌入模型重新向量化知识库
+		kb.POST("/:id/reembed", handler.ReembedKnowledgeBase)
+		// 获取知识库重新向量化进度
+		kb.GET("/reembed/progress/:task_id", handler.GetKBReembedProgress)
 	}
 }
 
@@ -229,7 +276,7 @@ func RegisterKnowledgeTagRoutes(r *gin.RouterGroup, tagHandler *handler.TagHandl
 	if tagHandler == nil {
 		return
 	}
-	kbTags := r.Group("/knowledge-bases/:id/tags")
+	kbTags := r.Group("/knowledge-bases/:id/tags", kbScopes())
 	{
 		kbTags.GET("", tagHandler.ListTags)
 		kbTags.POST("", tagHandler.CreateTag)
@@ -241,18 +288,20 @@ func RegisterKnowledgeTagRoutes(r *gin.RouterGroup, tagHandler *handler.TagHandl
 // RegisterMessageRoutes 注册消息相关的路由
 func RegisterMessageRoutes(r *gin.RouterGroup, handler *handler.MessageHandler) {
 	// 消息路由组
-	messages := r.Group("/messages")
+	messages := r.Group("/messages", middleware.RequireScope(types.APIKeyScopeChatWrite))
 	{
 		// 加载更早的消息，用于向上滚动加载
 		messages.GET("/:session_id/load", handler.LoadMessages)
 		// 删除消息
 		messages.DELETE("/:session_id/:id", handler.DeleteMessage)
+		// 获取提问时上传的附件
+		messages.GET("/:session_id/:id/attachments/:index", handler.GetAttachment)
 	}
 }
 
 // RegisterSessionRoutes 注册路由
 func RegisterSessionRoutes(r *gin.RouterGroup, handler *session.Handler) {
-	sessions := r.Group("/sessions")
+	sessions := r.Group("/sessions", middleware.RequireScope(types.APIKeyScopeChatWrite))
 	{
 		sessions.POST("", handler.CreateSession)
 		sessions.GET("/:id", handler.GetSession)
@@ -261,6 +310,9 @@ func RegisterSessionRoutes(r *gin.RouterGroup, handler *session.Handler) {
 		sessions.DELETE("/:id", handler.DeleteSession)
 		sessions.POST("/:session_id/generate_title", handler.GenerateTitle)
 		sessions.POST("/:session_id/stop", handler.StopSession)
+		// Agent 工具调用审批
+		sessions.GET("/:session_id/tool-approvals", handler.ListToolApprovals)
+		sessions.POST("/:session_id/tool-approvals/:approval_id", handler.DecideToolApproval)
 		// 继续接收活跃流
 		sessions.GET("/continue-stream/:session_id", handler.ContinueStream)
 	}
@@ -268,32 +320,50 @@ func RegisterSessionRoutes(r *gin.RouterGroup, handler *session.Handler) {
 
 // RegisterChatRoutes 注册路由
 func RegisterChatRoutes(r *gin.RouterGroup, handler *session.Handler) {
-	knowledgeChat := r.Group("/knowledge-chat")
+	knowledgeChat := r.Group("/knowledge-chat", middleware.RequireScope(types.APIKeyScopeChatWrite))
 	{
 		knowledgeChat.POST("/:session_id", handler.KnowledgeQA)
 	}
 
 	// Agent-based chat
-	agentChat := r.Group("/agent-chat")
+	agentChat := r.Group("/agent-chat", middleware.RequireScope(types.APIKeyScopeChatWrite))
 	{
 		agentChat.POST("/:session_id", handler.AgentQA)
 	}
 
 	// 新增知识检索接口，不需要session_id
-	knowledgeSearch := r.Group("/knowledge-search")
+	knowledgeSearch := r.Group("/knowledge-search", middleware.RequireScope(types.APIKeyScopeKBRead))
 	{
 		knowledgeSearch.POST("", handler.SearchKnowledge)
 	}
 }
 
+// RegisterOpenAIRoutes 注册 OpenAI 兼容的对话补全、向量化与模型列表路由
+func RegisterOpenAIRoutes(r *gin.RouterGroup, handler *session.Handler) {
+	openai := r.Group("", middleware.RequireScope(types.APIKeyScopeChatWrite))
+	{
+		openai.GET("/models", handler.ListOpenAIModels)
+		openai.POST("/chat/completions", handler.ChatCompletions)
+		openai.POST("/embeddings", handler.Embeddings)
+	}
+}
+
+// RegisterMCPServerRoutes 注册 MCP 协议的 Streamable HTTP 端点，工具内部按 API Key 的权限范围校验
+func RegisterMCPServerRoutes(r *gin.Engine, server *mcpserver.Server) {
+	mcpHandler := gin.WrapH(server.HTTPHandler())
+	r.POST("/mcp", mcpHandler)
+	r.GET("/mcp", mcpHandler)
+	r.DELETE("/mcp", mcpHandler)
+}
+
 // RegisterTenantRoutes 注册租户相关的路由
 func RegisterTenantRoutes(r *gin.RouterGroup, handler *handler.TenantHandler) {
 	// 添加获取所有租户的路由（需要跨租户权限）
-	r.GET("/tenants/all", handler.ListAllTenants)
+	r.GET("/tenants/all", middleware.RequireScope(types.APIKeyScopeAdmin), handler.ListAllTenants)
 	// 添加搜索租户的路由（需要跨租户权限，支持分页和搜索）
-	r.GET("/tenants/search", handler.SearchTenants)
+	r.GET("/tenants/search", middleware.RequireScope(types.APIKeyScopeAdmin), handler.SearchTenants)
 	// 租户路由组
-	tenantRoutes := r.Group("/tenants")
+	tenantRoutes := r.Group("/tenants", middleware.RequireScope(types.APIKeyScopeAdmin))
 	{
 		tenantRoutes.POST("", handler.CreateTenant)
 		tenantRoutes.GET("/:id", handler.GetTenant)
@@ -308,10 +378,102 @@ func RegisterTenantRoutes(r *gin.RouterGroup, handler *handler.TenantHandler) {
 	}
 }
 
+// RegisterPermissionRoutes 注册成员和知识库权限相关的路由
+func RegisterPermissionRoutes(r *gin.RouterGroup, handler *handler.PermissionHandler) {
+	// 当前用户可切换的租户
+	r.GET("/tenants/joined", handler.ListJoinedTenants)
+	// 租户成员管理
+	members := r.Group("/tenants/members", middleware.RequireScope(types.APIKeyScopeAdmin))
+	{
+		members.GET("", handler.ListMembers)
+		members.POST("", handler.AddMember)
+		members.PUT("/:user_id", handler.UpdateMemberRole)
+		members.DELETE("/:user_id", handler.RemoveMember)
+	}
+	// 知识库权限管理
+	permissions := r.Group("/knowledge-bases/:id/permissions", middleware.RequireScope(types.APIKeyScopeAdmin))
+	{
+		permissions.GET("", handler.ListKnowledgeBaseGrants)
+		permissions.PUT("/:user_id", handler.GrantKnowledgeBase)
+		permissions.DELETE("/:user_id", handler.RevokeKnowledgeBase)
+	}
+}
+
+// RegisterAPIKeyRoutes 注册 API Key 管理相关的路由
+func RegisterAPIKeyRoutes(r *gin.RouterGroup, handler *handler.APIKeyHandler) {
+	apiKeys := r.Group("/api-keys", middleware.RequireScope(types.APIKeyScopeAdmin))
+	{
+		apiKeys.POST("", handler.CreateAPIKey)
+		apiKeys.GET("", handler.ListAPIKeys)
+		apiKeys.DELETE("/:id", handler.RevokeAPIKey)
+	}
+}
+
+// RegisterUsageRoutes 注册用量统计相关的路由
+func RegisterUsageRoutes(r *gin.RouterGroup, handler *handler.UsageHandler) {
+	usage := r.Group("/usage", middleware.RequireScope(types.APIKeyScopeAdmin))
+	{
+		usage.GET("/report", handler.GetUsageReport)
+	}
+}
+
+// RegisterAuditRoutes 注册审计日志相关的路由
+func RegisterAuditRoutes(r *gin.RouterGroup, handler *handler.AuditHandler) {
+	auditLogs := r.Group("/audit-logs", middleware.RequireScope(types.APIKeyScopeAdmin))
+	{
+		auditLogs.GET("", handler.ListAuditLogs)
+		auditLogs.GET("/export", handler.ExportAuditLogs)
+	}
+}
+
+// RegisterWebhookRoutes 注册 Webhook 相关的路由
+func RegisterWebhookRoutes(r *gin.RouterGroup, handler *handler.WebhookHandler) {
+	webhooks := r.Group("/webhooks", middleware.RequireScope(types.APIKeyScopeAdmin))
+	{
+		webhooks.POST("", handler.CreateWebhook)
+		webhooks.GET("", handler.ListWebhooks)
+		webhooks.GET("/:id", handler.GetWebhook)
+		webhooks.PUT("/:id", handler.UpdateWebhook)
+		webhooks.DELETE("/:id", handler.DeleteWebhook)
+		webhooks.GET("/:id/deliveries", handler.ListWebhookDeliveries)
+		webhooks.POST("/:id/ping", handler.PingWebhook)
+		webhooks.POST("/:id/deliveries/:delivery_id/redeliver", handler.RedeliverWebhookDelivery)
+	}
+}
+
+// RegisterMemoryRoutes 注册用户记忆相关的路由
+func RegisterMemoryRoutes(r *gin.RouterGroup, handler *handler.MemoryHandler) {
+	memories := r.Group("/memories", middleware.RequireScope(types.APIKeyScopeChatWrite))
+	{
+		memories.GET("", handler.ListMemories)
+		memories.DELETE("", handler.DeleteAllMemories)
+		memories.DELETE("/:id", handler.DeleteMemory)
+	}
+}
+
+// RegisterFeedbackRoutes 注册回答反馈和检索质量分析相关的路由
+func RegisterFeedbackRoutes(r *gin.RouterGroup, handler *handler.FeedbackHandler) {
+	// 用户对回答的评价
+	messages := r.Group("/messages", middleware.RequireScope(types.APIKeyScopeChatWrite))
+	{
+		messages.GET("/:session_id/:id/feedback", handler.GetMessageFeedback)
+		messages.PUT("/:session_id/:id/feedback", handler.RateMessage)
+		messages.DELETE("/:session_id/:id/feedback", handler.DeleteMessageFeedback)
+	}
+	// 反馈统计和导出
+	feedback := r.Group("/feedback", middleware.RequireScope(types.APIKeyScopeAdmin))
+	{
+		feedback.GET("/summary", handler.GetFeedbackSummary)
+		feedback.GET("/worst-queries", handler.ListWorstQueries)
+		feedback.GET("/downvoted-chunks", handler.ListDownvotedChunks)
+		feedback.GET("/export", handler.ExportNegativeFeedback)
+	}
+}
+
 // RegisterModelRoutes 注册模型相关的路由
 func RegisterModelRoutes(r *gin.RouterGroup, handler *handler.ModelHandler) {
 	// 模型路由组
-	models := r.Group("/models")
+	models := r.Group("/models", middleware.RequireScopes(types.APIKeyScopeKBRead, types.APIKeyScopeAdmin))
 	{
 		// 创建模型
 		models.POST("", handler.CreateModel)
@@ -327,10 +489,25 @@ func RegisterModelRoutes(r *gin.RouterGroup, handler *handler.ModelHandler) {
 }
 
 func RegisterEvaluationRoutes(r *gin.RouterGroup, handler *handler.EvaluationHandler) {
-	evaluationRoutes := r.Group("/evaluation")
+	evaluationRoutes := r.Group("/evaluation", middleware.RequireScope(types.APIKeyScopeAdmin))
 	{
 		evaluationRoutes.POST("/", handler.Evaluation)
 		evaluationRoutes.GET("/", handler.GetEvaluationResult)
+		// 评估任务列表
+		evaluationRoutes.GET("/tasks", handler.ListEvaluations)
+		// 恢复失败的评估任务
+		evaluationRoutes.POST("/tasks/:id/resume", handler.ResumeEvaluation)
+		// 评估任务的逐题结果
+		evaluationRoutes.GET("/tasks/:id/questions", handler.ListQuestionResults)
+		evaluationRoutes.GET("/tasks/:id/questions/:index", handler.GetQuestionResult)
+		// 多配置对比评估
+		evaluationRoutes.POST("/comparisons", handler.CompareEvaluations)
+		evaluationRoutes.GET("/comparisons", handler.ListComparisons)
+		evaluationRoutes.GET("/comparisons/:id/report", handler.GetComparisonReport)
+		// 评估数据集
+		evaluationRoutes.POST("/datasets", handler.UploadDataset)
+		evaluationRoutes.GET("/datasets", handler.ListDatasets)
+		evaluationRoutes.DELETE("/datasets/:id", handler.DeleteDataset)
 	}
 }
 
@@ -347,32 +524,35 @@ func RegisterAuthRoutes(r *gin.RouterGroup, handler *handler.AuthHandler) {
 
 func RegisterInitializationRoutes(r *gin.RouterGroup, handler *handler.InitializationHandler) {
 	// 初始化接口
-	r.GET("/initialization/config/:kbId", handler.GetCurrentConfigByKB)
-	r.POST("/initialization/initialize/:kbId", handler.InitializeByKB)
-	r.PUT("/initialization/config/:kbId", handler.UpdateKBConfig) // 新的简化版接口，只传模型ID
+	kbInit := r.Group("/initialization", kbScopes())
+	kbInit.GET("/config/:kbId", handler.GetCurrentConfigByKB)
+	kbInit.POST("/initialize/:kbId", handler.InitializeByKB)
+	kbInit.PUT("/config/:kbId", handler.UpdateKBConfig) // 新的简化版接口，只传模型ID
+	// 模型检测和知识抽取接口需要管理权限
+	setup := r.Group("/initialization", middleware.RequireScope(types.APIKeyScopeAdmin))
 
 	// Ollama相关接口
-	r.GET("/initialization/ollama/status", handler.CheckOllamaStatus)
-	r.GET("/initialization/ollama/models", handler.ListOllamaModels)
-	r.POST("/initialization/ollama/models/check", handler.CheckOllamaModels)
-	r.POST("/initialization/ollama/models/download", handler.DownloadOllamaModel)
-	r.GET("/initialization/ollama/download/progress/:taskId", handler.GetDownloadProgress)
-	r.GET("/initialization/ollama/download/tasks", handler.ListDownloadTasks)
+	setup.GET("/ollama/status", handler.CheckOllamaStatus)
+	setup.GET("/ollama/models", handler.ListOllamaModels)
+	setup.POST("/ollama/models/check", handler.CheckOllamaModels)
+	setup.POST("/ollama/models/download", handler.DownloadOllamaModel)
+	setup.GET("/ollama/download/progress/:taskId", handler.GetDownloadProgress)
+	setup.GET("/ollama/download/tasks", handler.ListDownloadTasks)
 
 	// 远程API相关接口
-	r.POST("/initialization/remote/check", handler.CheckRemoteModel)
-	r.POST("/initialization/embedding/test", handler.TestEmbeddingModel)
-	r.POST("/initialization/rerank/check", handler.CheckRerankModel)
-	r.POST("/initialization/multimodal/test", handler.TestMultimodalFunction)
-
-	r.POST("/initialization/extract/text-relation", handler.ExtractTextRelations)
-	r.POST("/initialization/extract/fabri-tag", handler.FabriTag)
-	r.POST("/initialization/extract/fabri-text", handler.FabriText)
+	setup.POST("/remote/check", handler.CheckRemoteModel)
+	setup.POST("/embedding/test", handler.TestEmbeddingModel)
+	setup.POST("/rerank/check", handler.CheckRerankModel)
+	setup.POST("/multimodal/test", handler.TestMultimodalFunction)
+
+	setup.POST("/extract/text-relation", handler.ExtractTextRelations)
+	setup.POST("/extract/fabri-tag", handler.FabriTag)
+	setup.POST("/extract/fabri-text", handler.FabriText)
 }
 
 // RegisterSystemRoutes registers system information routes
 func RegisterSystemRoutes(r *gin.RouterGroup, handler *handler.SystemHandler) {
-	systemRoutes := r.Group("/system")
+	systemRoutes := r.Group("/system", middleware.RequireScope(types.APIKeyScopeKBRead))
 	{
 		systemRoutes.GET("/info", handler.GetSystemInfo)
 	}
@@ -380,7 +560,7 @@ func RegisterSystemRoutes(r *gin.RouterGroup, handler *handler.SystemHandler) {
 
 // RegisterMCPServiceRoutes registers MCP service routes
 func RegisterMCPServiceRoutes(r *gin.RouterGroup, handler *handler.MCPServiceHandler) {
-	mcpServices := r.Group("/mcp-services")
+	mcpServices := r.Group("/mcp-services", middleware.RequireScope(types.APIKeyScopeAdmin))
 	{
 		// Create MCP service
 		mcpServices.POST("", handler.CreateMCPService)
@@ -404,7 +584,7 @@ func RegisterMCPServiceRoutes(r *gin.RouterGroup, handler *handler.MCPServiceHan
 // RegisterWebSearchRoutes registers web search routes
 func RegisterWebSearchRoutes(r *gin.RouterGroup, webSearchHandler *handler.WebSearchHandler) {
 	// Web search providers
-	webSearch := r.Group("/web-search")
+	webSearch := r.Group("/web-search", middleware.RequireScope(types.APIKeyScopeKBRead))
 	{
 		// Get available providers
 		webSearch.GET("/providers", webSearchHandler.GetProviders)
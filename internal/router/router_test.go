@@ -0,0 +1,34 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSystemAndWebSearchRoutesRequireKBRead(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		principal := &types.Principal{
+			Role: types.TenantRoleOwner, APIKey: &types.APIKey{Scopes: types.StringArray{"chat:write"}},
+		}
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), types.PrincipalContextKey, principal))
+		c.Next()
+	})
+	// The handlers are never reached, the scope check rejects the requests first
+	RegisterSystemRoutes(&r.RouterGroup, nil)
+	RegisterWebSearchRoutes(&r.RouterGroup, nil)
+
+	for _, path := range []string{"/system/info", "/web-search/providers"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusForbidden, w.Code, path)
+		assert.Contains(t, w.Body.String(), "kb:read", path)
+	}
+}
@@ -19,6 +19,8 @@ type AsynqTaskParams struct {
 	KnowledgeService     interfaces.KnowledgeService
 	KnowledgeBaseService interfaces.KnowledgeBaseService
 	TagService           interfaces.KnowledgeTagService
+	EvaluationService    interfaces.EvaluationService
+	WebhookService       interfaces.WebhookService
 }
 
 func getAsynqRedisClientOpt() *asynq.RedisClientOpt {
@@ -66,6 +68,10 @@ func RunAsynqServer(params AsynqTaskParams) *asynq.ServeMux {
 	// Register document processing handler
 	mux.HandleFunc(types.TypeDocumentProcess, params.KnowledgeService.ProcessDocument)
 
+	// Register URL crawl and refresh handlers
+	mux.HandleFunc(types.TypeURLCrawl, params.KnowledgeService.ProcessURLCrawl)
+	mux.HandleFunc(types.TypeURLRefresh, params.KnowledgeService.ProcessURLRefresh)
+
 	// Register FAQ import handler
 	mux.HandleFunc(types.TypeFAQImport, params.KnowledgeService.ProcessFAQImport)
 
@@ -77,6 +83,7 @@ func RunAsynqServer(params AsynqTaskParams) *asynq.ServeMux {
 
 	// Register KB clone handler
 	mux.HandleFunc(types.TypeKBClone, params.KnowledgeService.ProcessKBClone)
+	mux.HandleFunc(types.TypeKBReembed, params.KnowledgeService.ProcessKBReembed)
 
 	// Register index delete handler
 	mux.HandleFunc(types.TypeIndexDelete, params.TagService.ProcessIndexDelete)
@@ -84,6 +91,12 @@ func RunAsynqServer(params AsynqTaskParams) *asynq.ServeMux {
 	// Register KB delete handler
 	mux.HandleFunc(types.TypeKBDelete, params.KnowledgeBaseService.ProcessKBDelete)
 
+	// Register evaluation handler
+	mux.HandleFunc(types.TypeEvaluation, params.EvaluationService.ProcessEvaluation)
+
+	// Register webhook delivery handler
+	mux.HandleFunc(types.TypeWebhookDelivery, params.WebhookService.ProcessWebhookDelivery)
+
 	go func() {
 		// Start the server
 		if err := params.Server.Run(mux); err != nil {
@@ -92,3 +105,17 @@ func RunAsynqServer(params AsynqTaskParams) *asynq.ServeMux {
 	}()
 	return mux
 }
+
+// RunPeriodicTaskManager starts the scheduler of periodic tasks, such as the URL knowledge refresh schedules.
+// The configs are synced from the provider, so schedule changes apply without a restart.
+func RunPeriodicTaskManager(provider asynq.PeriodicTaskConfigProvider) error {
+	manager, err := asynq.NewPeriodicTaskManager(asynq.PeriodicTaskManagerOpts{
+		RedisConnOpt:               getAsynqRedisClientOpt(),
+		PeriodicTaskConfigProvider: provider,
+		SyncInterval:               time.Minute,
+	})
+	if err != nil {
+		return err
+	}
+	return manager.Start()
+}
@@ -107,3 +107,27 @@ func NormalizeKeywordScores[T any](
 		setScore(r, 1.0)
 	}
 }
+
+// NormalizeScoresByGroup scales the scores of each group of results in-place by the best score of that group,
+// so that results ranked by different fusion modes can be compared once merged.
+// The best result of each group gets 1 and the relative scores within a group are kept.
+// Results without a group are left as they are.
+func NormalizeScoresByGroup[T any](
+	results []T,
+	getGroup func(T) string,
+	getScore func(T) float64,
+	setScore func(T, float64),
+) {
+	maxScores := make(map[string]float64)
+	for _, r := range results {
+		if group := getGroup(r); group != "" && getScore(r) > maxScores[group] {
+			maxScores[group] = getScore(r)
+		}
+	}
+	for _, r := range results {
+		group := getGroup(r)
+		if maxScore := maxScores[group]; group != "" && maxScore > 0 {
+			setScore(r, ClampFloat(getScore(r)/maxScore, 0, 1))
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package searchutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type groupedResult struct {
+	group string
+	score float64
+}
+
+func TestNormalizeScoresByGroup(t *testing.T) {
+	results := map[string]*groupedResult{
+		// RRF knowledge base, scores up to 2/(60+1)
+		"r1": {"kb-rrf", 0.0328},
+		"r2": {"kb-rrf", 0.0164},
+		// Weighted knowledge base, scores in [0, 1]
+		"w1": {"kb-weighted", 0.8},
+		"w2": {"kb-weighted", 0.4},
+		// Max knowledge base with a single result
+		"m1": {"kb-max", 0.5},
+		// Results without a score are left alone
+		"e1": {"kb-empty", 0},
+		// Results without a group, e.g. web search results, are left alone
+		"x1": {"", 0.3},
+	}
+	list := make([]*groupedResult, 0, len(results))
+	for _, r := range results {
+		list = append(list, r)
+	}
+
+	NormalizeScoresByGroup(list,
+		func(r *groupedResult) string { return r.group },
+		func(r *groupedResult) float64 { return r.score },
+		func(r *groupedResult, score float64) { r.score = score },
+	)
+
+	// The best result of each group ranks alike, whatever its fusion mode
+	assert.InDelta(t, 1, results["r1"].score, 1e-9)
+	assert.InDelta(t, 1, results["w1"].score, 1e-9)
+	assert.InDelta(t, 1, results["m1"].score, 1e-9)
+	// Relative scores within a group are kept
+	assert.InDelta(t, 0.5, results["r2"].score, 1e-9)
+	assert.InDelta(t, 0.5, results["w2"].score, 1e-9)
+	assert.Zero(t, results["e1"].score)
+	assert.InDelta(t, 0.3, results["x1"].score, 1e-9)
+}
@@ -22,6 +22,34 @@ type AgentConfig struct {
 	WebSearchEnabled        bool     `json:"web_search_enabled"`                   // Whether web search tool is enabled
 	WebSearchMaxResults     int      `json:"web_search_max_results"`               // Maximum number of web search results (default: 5)
 	SearchTargets           SearchTargets `json:"-"`                               // Pre-computed unified search targets (runtime only)
+	// Named sub-agents the agent can delegate tasks to with the delegate_to_agent tool
+	Profiles []AgentProfile `json:"profiles,omitempty"`
+	// Policies of the tools by tool name or glob pattern such as "mcp.*", tools without a policy run automatically
+	ToolPolicies map[string]ToolPolicy `json:"tool_policies,omitempty"`
+	// Seconds the agent waits for the approval of a tool call before skipping it, 0 for the default
+	ToolApprovalTimeout int `json:"tool_approval_timeout,omitempty"`
+	// Whether the agent remembers facts and preferences of the users across sessions
+	MemoryEnabled bool `json:"memory_enabled,omitempty"`
+	// Embedding model of the user memories, the first embedding model of the tenant if empty
+	MemoryEmbeddingModelID string `json:"memory_embedding_model_id,omitempty"`
+	// Memories of the user recalled for the current query (runtime only)
+	Memories []*UserMemory `json:"-"`
+	// Filter on the documents searched by the knowledge_search tool for the current query (runtime only)
+	RetrievalFilter *RetrievalFilter `json:"-"`
+	// Images attached to the current query as data URLs, shown to a vision-capable model (runtime only)
+	QueryImages []string `json:"-"`
+}
+
+// AgentProfile is a named sub-agent with its own prompt, tools, knowledge bases and model.
+// The agent delegates tasks to the profiles with the delegate_to_agent tool.
+type AgentProfile struct {
+	Name           string   `json:"name"`                      // Unique name of the sub-agent
+	Description    string   `json:"description"`               // What the sub-agent knows, shown to the agent
+	SystemPrompt   string   `json:"system_prompt,omitempty"`   // Prompt template, default prompt if empty
+	AllowedTools   []string `json:"allowed_tools,omitempty"`   // Tool names, default tools if empty
+	KnowledgeBases []string `json:"knowledge_bases,omitempty"` // Knowledge base IDs it searches
+	ModelID        string   `json:"model_id,omitempty"`        // Chat model, the agent's model if empty
+	MaxIterations  int      `json:"max_iterations,omitempty"`  // Iteration budget, 10 if unset
 }
 
 // SessionAgentConfig represents session-level agent configuration
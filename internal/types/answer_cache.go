@@ -0,0 +1,34 @@
+package types
+
+import "time"
+
+// AnswerCacheEntry is a cached answer of a knowledge QA question
+type AnswerCacheEntry struct {
+	// Rewritten query the answer was generated for
+	Query string `json:"query"`
+	// Embedding of the rewritten query
+	Embedding []float32 `json:"embedding"`
+	// Generated answer
+	Answer string `json:"answer"`
+	// References the answer was generated from
+	References []*SearchResult `json:"references"`
+	// Time the answer was cached
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AnswerCacheKey locates the cache entries of a question.
+// Entries are only shared between questions on the same knowledge base set and model configuration.
+type AnswerCacheKey struct {
+	// Tenant ID of the session
+	TenantID uint64
+	// Hash of the knowledge base set, their versions and the model configuration
+	Scope string
+	// Rewritten query
+	Query string
+	// Embedding of the rewritten query
+	Embedding []float32
+	// Strictest similarity threshold of the knowledge bases
+	SimilarityThreshold float64
+	// Shortest lifetime of the knowledge bases
+	TTL time.Duration
+}
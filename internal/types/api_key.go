@@ -0,0 +1,133 @@
+package types
+
+import (
+	"slices"
+	"time"
+)
+
+// APIKeyPrefix is the prefix of scoped API keys, the legacy tenant API key starts with "sk-"
+const APIKeyPrefix = "ak-"
+
+// APIKeyScope is an operation an API key is allowed to perform
+type APIKeyScope string
+
+const (
+	// APIKeyScopeChatWrite allows managing sessions and chatting with the knowledge bases of the key
+	APIKeyScopeChatWrite APIKeyScope = "chat:write"
+	// APIKeyScopeKBRead allows reading and searching knowledge bases, knowledge, chunks, tags and FAQ entries
+	APIKeyScopeKBRead APIKeyScope = "kb:read"
+	// APIKeyScopeKBWrite additionally allows creating, changing and deleting them
+	APIKeyScopeKBWrite APIKeyScope = "kb:write"
+	// APIKeyScopeAdmin allows everything, including the tenant, members, models, MCP services and API keys
+	APIKeyScopeAdmin APIKeyScope = "admin"
+)
+
+// IsValid checks whether the scope is a known API key scope
+func (s APIKeyScope) IsValid() bool {
+	switch s {
+	case APIKeyScopeChatWrite, APIKeyScopeKBRead, APIKeyScopeKBWrite, APIKeyScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// APIKey is a revocable API key of a tenant, restricted to scopes and optionally to some knowledge bases
+type APIKey struct {
+	// Unique identifier of the API key
+	ID string `json:"id" gorm:"type:varchar(36);primaryKey"`
+	// Tenant ID
+	TenantID uint64 `json:"tenant_id" gorm:"index"`
+	// Name describing the usage of the key
+	Name string `json:"name" gorm:"type:varchar(255)"`
+	// First characters of the key, to recognize it after creation
+	KeyPrefix string `json:"key_prefix" gorm:"type:varchar(32)"`
+	// SHA-256 hash of the key, the key itself is only returned on creation
+	KeyHash string `json:"-" gorm:"type:varchar(64);uniqueIndex"`
+	// Scopes granted to the key
+	Scopes StringArray `json:"scopes" gorm:"type:json"`
+	// Knowledge bases the key is restricted to, empty for all knowledge bases
+	KnowledgeBaseIDs StringArray `json:"knowledge_base_ids" gorm:"type:json"`
+	// Expiration time, nil when the key never expires
+	ExpiresAt *time.Time `json:"expires_at"`
+	// Revocation time, nil while the key is active
+	RevokedAt *time.Time `json:"revoked_at"`
+	// Last time the key authenticated a request
+	LastUsedAt *time.Time `json:"last_used_at"`
+	// Maximum number of model calls per minute made with the key, zero for unlimited
+	RequestsPerMinute int `json:"requests_per_minute"`
+	// Maximum number of tokens per calendar month used with the key, zero for unlimited
+	MonthlyTokenBudget int64 `json:"monthly_token_budget"`
+	// User who created the key
+	CreatedBy string `json:"created_by" gorm:"type:varchar(36)"`
+	// Creation time of the key
+	CreatedAt time.Time `json:"created_at"`
+	// Last updated time of the key
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// IsActive checks whether the key is neither revoked nor expired
+func (k *APIKey) IsActive(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// HasScope checks whether the key grants a scope, admin grants every scope and kb:write grants kb:read
+func (k *APIKey) HasScope(scope APIKeyScope) bool {
+	for _, s := range k.Scopes {
+		granted := APIKeyScope(s)
+		if granted == scope || granted == APIKeyScopeAdmin ||
+			(granted == APIKeyScopeKBWrite && scope == APIKeyScopeKBRead) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsKnowledgeBaseRestricted checks whether the key only applies to some knowledge bases
+func (k *APIKey) IsKnowledgeBaseRestricted() bool {
+	return len(k.KnowledgeBaseIDs) > 0
+}
+
+// KnowledgeBasePermission returns the highest permission the key allows on a knowledge base.
+// Chatting reads the knowledge bases the key may access.
+func (k *APIKey) KnowledgeBasePermission(kbID string) KBPermission {
+	if k.IsKnowledgeBaseRestricted() && !slices.Contains(k.KnowledgeBaseIDs, kbID) {
+		return KBPermissionNone
+	}
+	switch {
+	case k.HasScope(APIKeyScopeAdmin):
+		return KBPermissionAdmin
+	case k.HasScope(APIKeyScopeKBWrite):
+		return KBPermissionWrite
+	case k.HasScope(APIKeyScopeKBRead), k.HasScope(APIKeyScopeChatWrite):
+		return KBPermissionRead
+	}
+	return KBPermissionNone
+}
+
+// UsageLimits returns the model usage limits of the key
+func (k *APIKey) UsageLimits() UsageLimits {
+	return UsageLimits{RequestsPerMinute: k.RequestsPerMinute, MonthlyTokenBudget: k.MonthlyTokenBudget}
+}
+
+// CreateAPIKeyRequest is the request to create an API key
+type CreateAPIKeyRequest struct {
+	// Name describing the usage of the key
+	Name string `json:"name" binding:"required"`
+	// Scopes granted to the key
+	Scopes []APIKeyScope `json:"scopes" binding:"required,min=1"`
+	// Knowledge bases the key is restricted to, empty for all knowledge bases
+	KnowledgeBaseIDs []string `json:"knowledge_base_ids"`
+	// Expiration time, nil when the key never expires
+	ExpiresAt *time.Time `json:"expires_at"`
+	// Maximum number of model calls per minute, zero for unlimited
+	RequestsPerMinute int `json:"requests_per_minute" binding:"min=0"`
+	// Maximum number of tokens per calendar month, zero for unlimited
+	MonthlyTokenBudget int64 `json:"monthly_token_budget" binding:"min=0"`
+}
+
+// CreatedAPIKey is a newly created API key together with its plain value, which is only shown once
+type CreatedAPIKey struct {
+	*APIKey
+	// Plain API key
+	Key string `json:"key"`
+}
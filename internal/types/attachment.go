@@ -0,0 +1,97 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// Attachment types
+const (
+	// AttachmentTypeImage is an image shown to vision-capable chat models
+	AttachmentTypeImage = "image"
+	// AttachmentTypeFile is a document parsed into text for the question, it is not added to any knowledge base
+	AttachmentTypeFile = "file"
+)
+
+const (
+	// MaxMessageAttachments is the maximum number of attachments of a question
+	MaxMessageAttachments = 5
+	// MaxAttachmentSize is the maximum size of an attachment in bytes (20MB)
+	MaxAttachmentSize = 20 << 20
+	// MaxAttachmentContentLength is the maximum number of characters of the text kept from an attached document
+	MaxAttachmentContentLength = 20000
+)
+
+// MessageAttachment is an image or a document attached to a question
+type MessageAttachment struct {
+	// Type is image or file
+	Type string `json:"type"`
+	// Name is the original file name
+	Name string `json:"name"`
+	// MimeType is the detected content type
+	MimeType string `json:"mime_type"`
+	// Size is the size of the file in bytes
+	Size int64 `json:"size"`
+	// FilePath is where the file is stored by the file service
+	FilePath string `json:"file_path"`
+	// Caption describes an image, generated by the VLM model of the knowledge base
+	Caption string `json:"caption,omitempty"`
+	// Content is the text parsed from a document, truncated to MaxAttachmentContentLength characters
+	Content string `json:"content,omitempty"`
+	// Data is the content of the file while the question is answered (runtime only)
+	Data []byte `json:"-"`
+}
+
+// DataURL returns the content of the attachment as a base64 data URL
+func (a *MessageAttachment) DataURL() string {
+	return "data:" + a.MimeType + ";base64," + base64.StdEncoding.EncodeToString(a.Data)
+}
+
+// MessageAttachments is a slice of MessageAttachment for database storage
+type MessageAttachments []MessageAttachment
+
+// ImageURLs returns the images which have their content as data URLs
+func (m MessageAttachments) ImageURLs() []string {
+	var urls []string
+	for i := range m {
+		if m[i].Type == AttachmentTypeImage && len(m[i].Data) > 0 {
+			urls = append(urls, m[i].DataURL())
+		}
+	}
+	return urls
+}
+
+// Captions returns the captions of the images, one per line
+func (m MessageAttachments) Captions() string {
+	var captions []string
+	for _, attachment := range m {
+		if attachment.Type == AttachmentTypeImage && attachment.Caption != "" {
+			captions = append(captions, attachment.Caption)
+		}
+	}
+	return strings.Join(captions, "\n")
+}
+
+// Value implements the driver.Valuer interface for database serialization
+func (m MessageAttachments) Value() (driver.Value, error) {
+	if m == nil {
+		return json.Marshal([]MessageAttachment{})
+	}
+	return json.Marshal(m)
+}
+
+// Scan implements the sql.Scanner interface for database deserialization
+func (m *MessageAttachments) Scan(value interface{}) error {
+	if value == nil {
+		*m = make(MessageAttachments, 0)
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		*m = make(MessageAttachments, 0)
+		return nil
+	}
+	return json.Unmarshal(b, m)
+}
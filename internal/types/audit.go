@@ -0,0 +1,109 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+const (
+	// AuditBeforeContextKey is the gin context key for the state of the audited resource before the change
+	AuditBeforeContextKey ContextKey = "AuditBefore"
+	// AuditAfterContextKey is the gin context key for the state of the audited resource after the change
+	AuditAfterContextKey ContextKey = "AuditAfter"
+	// AuditResourceIDContextKey is the gin context key overriding the ID of the audited resource
+	AuditResourceIDContextKey ContextKey = "AuditResourceID"
+	// AuditReadContextKey is the gin context key marking a read operation, such as an export, to be audited
+	AuditReadContextKey ContextKey = "AuditRead"
+)
+
+// AuditActorType is the kind of caller recorded in the audit log
+type AuditActorType string
+
+const (
+	// AuditActorUser is a logged in user
+	AuditActorUser AuditActorType = "user"
+	// AuditActorAPIKey is a scoped API key
+	AuditActorAPIKey AuditActorType = "api_key"
+	// AuditActorTenantAPIKey is the default API key of the tenant
+	AuditActorTenantAPIKey AuditActorType = "tenant_api_key"
+)
+
+// AuditChange is the value of a field before and after a change
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditChanges are the changed fields of a resource, by field name
+type AuditChanges map[string]AuditChange
+
+// Value implements the driver.Valuer interface, used to convert AuditChanges to database value
+func (c AuditChanges) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return json.Marshal(c)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database value to AuditChanges
+func (c *AuditChanges) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, c)
+}
+
+// AuditLog is an append-only record of an administrative or data-changing operation
+type AuditLog struct {
+	// Unique identifier of the record, increasing with time
+	ID uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	// Tenant the operation was performed in
+	TenantID uint64 `json:"tenant_id" gorm:"index"`
+	// Kind of caller
+	ActorType AuditActorType `json:"actor_type" gorm:"type:varchar(32)"`
+	// User ID or API key ID of the caller, empty for the tenant API key
+	ActorID string `json:"actor_id" gorm:"type:varchar(36)"`
+	// Username or API key name of the caller
+	ActorName string `json:"actor_name" gorm:"type:varchar(255)"`
+	// Operation, the method and route of the request, e.g. "DELETE /knowledge-bases/:id"
+	Action string `json:"action" gorm:"type:varchar(255)"`
+	// Kind of the target resource, the static segments of the route, e.g. "knowledge-bases/faq/entries"
+	ResourceType string `json:"resource_type" gorm:"type:varchar(255)"`
+	// ID of the target resource, the route parameters joined with "/"
+	ResourceID string `json:"resource_id" gorm:"type:varchar(255)"`
+	// Requested path
+	Path string `json:"path" gorm:"type:varchar(1024)"`
+	// HTTP status of the response
+	StatusCode int `json:"status_code"`
+	// Changed fields of the target resource, when the handler reports them
+	Changes AuditChanges `json:"changes" gorm:"type:jsonb"`
+	// Request ID
+	RequestID string `json:"request_id" gorm:"type:varchar(64)"`
+	// IP address of the caller
+	ClientIP string `json:"client_ip" gorm:"type:varchar(64)"`
+	// Time of the operation
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// AuditLogFilter filters audit logs, empty fields match everything
+type AuditLogFilter struct {
+	// User ID or API key ID of the caller
+	ActorID string `form:"actor_id"`
+	// Operation
+	Action string `form:"action"`
+	// Kind of the target resource
+	ResourceType string `form:"resource_type"`
+	// ID of the target resource
+	ResourceID string `form:"resource_id"`
+	// Request ID
+	RequestID string `form:"request_id"`
+	// Earliest time of the operations, inclusive
+	StartTime *time.Time `form:"start_time" time_format:"2006-01-02T15:04:05Z07:00"`
+	// Latest time of the operations, exclusive
+	EndTime *time.Time `form:"end_time" time_format:"2006-01-02T15:04:05Z07:00"`
+}
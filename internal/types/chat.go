@@ -44,6 +44,8 @@ const (
 	ResponseTypeAnswer ResponseType = "answer"
 	// References response type
 	ResponseTypeReferences ResponseType = "references"
+	// Citations response type
+	ResponseTypeCitations ResponseType = "citations"
 	// Thinking response type (for agent thought process)
 	ResponseTypeThinking ResponseType = "thinking"
 	// Tool call response type (for agent tool invocations)
@@ -60,6 +62,8 @@ const (
 	ResponseTypeAgentQuery ResponseType = "agent_query"
 	// Complete response type (agent complete)
 	ResponseTypeComplete ResponseType = "complete"
+	// Tool approval required response type (agent waits for a person to approve a tool call)
+	ResponseTypeToolApprovalRequired ResponseType = "tool_approval_required"
 )
 
 // StreamResponse stream response
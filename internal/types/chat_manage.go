@@ -17,6 +17,8 @@ type ChatManage struct {
 	KeywordThreshold float64       `json:"keyword_threshold"` // Minimum score threshold for keyword search results
 	EmbeddingTopK    int           `json:"embedding_top_k"`   // Number of top results to retrieve from embedding search
 	VectorDatabase   string        `json:"vector_database"`   // Vector database type/name to use
+	// RetrievalFilter narrows the search to the documents whose tags, file type, creation time or metadata match
+	RetrievalFilter *RetrievalFilter `json:"retrieval_filter,omitempty"`
 
 	RerankModelID   string  `json:"rerank_model_id"`  // Model ID for reranking search results
 	RerankTopK      int     `json:"rerank_top_k"`     // Number of top results after reranking
@@ -53,6 +55,19 @@ type ChatManage struct {
 	// Web search configuration (internal use)
 	TenantID         uint64 `json:"-"` // Tenant ID for retrieving web search config
 	WebSearchEnabled bool   `json:"-"` // Whether web search is enabled for this request
+
+	// Images are the images attached to the question as data URLs, shown to vision-capable chat models
+	Images []string `json:"-"`
+	// AttachmentContent is the text of the documents attached to the question, appended to the user message
+	AttachmentContent string `json:"-"`
+
+	// AnswerCacheHit reports that the answer was replayed from the semantic answer cache
+	AnswerCacheHit bool `json:"-"`
+
+	// StageParams holds the parameters of the pipeline stage being run, they override the settings above
+	StageParams PipelineStageParams `json:"-"`
+	// Sources are the search results numbered in the prompt, source n is Sources[n-1]
+	Sources []*SearchResult `json:"-"`
 }
 
 // Clone creates a deep copy of the ChatManage object
@@ -86,6 +101,7 @@ func (c *ChatManage) Clone() *ChatManage {
 		KnowledgeBaseIDs: knowledgeBaseIDs,
 		KnowledgeIDs:     knowledgeIDs,
 		SearchTargets:    searchTargets,
+		RetrievalFilter:  c.RetrievalFilter,
 		VectorThreshold:  c.VectorThreshold,
 		KeywordThreshold: c.KeywordThreshold,
 		EmbeddingTopK:    c.EmbeddingTopK,
@@ -125,6 +141,7 @@ type EventType string
 
 const (
 	REWRITE_QUERY          EventType = "rewrite_query"          // Query rewriting for better retrieval
+	ANSWER_CACHE           EventType = "answer_cache"           // Replay a cached answer of a similar question
 	CHUNK_SEARCH           EventType = "chunk_search"           // Search for relevant chunks
 	CHUNK_SEARCH_PARALLEL  EventType = "chunk_search_parallel"  // Parallel search: chunks + entities
 	ENTITY_SEARCH          EventType = "entity_search"          // Search for relevant entities
@@ -134,6 +151,7 @@ const (
 	CHAT_COMPLETION        EventType = "chat_completion"        // Generate chat completion
 	CHAT_COMPLETION_STREAM EventType = "chat_completion_stream" // Stream chat completion
 	STREAM_FILTER          EventType = "stream_filter"          // Filter streaming output
+	VERIFY_CITATIONS       EventType = "verify_citations"       // Verify the citations of the streamed answer
 	FILTER_TOP_K           EventType = "filter_top_k"           // Keep only top K results
 )
 
@@ -155,11 +173,13 @@ var Pipline = map[string][]EventType{
 	},
 	"rag_stream": { // Streaming Retrieval Augmented Generation
 		REWRITE_QUERY,
+		ANSWER_CACHE,          // Replays a cached answer when the knowledge bases enable it
 		CHUNK_SEARCH_PARALLEL, // Parallel: CHUNK_SEARCH + ENTITY_SEARCH
 		CHUNK_RERANK,
 		CHUNK_MERGE,
 		FILTER_TOP_K,
 		INTO_CHAT_MESSAGE,
+		VERIFY_CITATIONS, // Wraps the answer stream of the completion that follows
 		CHAT_COMPLETION_STREAM,
 		STREAM_FILTER,
 	},
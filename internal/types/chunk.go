@@ -3,6 +3,8 @@
 package types
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"time"
 
 	"gorm.io/gorm"
@@ -146,3 +148,9 @@ type Chunk struct {
 	// Soft delete marker, supports data recovery
 	DeletedAt gorm.DeletedAt `json:"deleted_at"               gorm:"index"`
 }
+
+// CalculateChunkContentHash 计算文档 Chunk 的内容 hash，用于重新导入文件时比对未变化的 Chunk
+func CalculateChunkContentHash(chunkType ChunkType, content string) string {
+	hash := sha256.Sum256([]byte(string(chunkType) + "|" + content))
+	return hex.EncodeToString(hash[:])
+}
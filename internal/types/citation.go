@@ -0,0 +1,65 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// CitationMode decides what happens to the citations of an answer which do not match a source
+type CitationMode string
+
+const (
+	// CitationModeStrip removes the unverified citation markers from the answer
+	CitationModeStrip CitationMode = "strip"
+	// CitationModeFlag keeps the unverified citation markers and reports them as unverified
+	CitationModeFlag CitationMode = "flag"
+)
+
+// Citation links a sentence of an answer to the source chunk it cites.
+// Start and End are character offsets of the cited sentence in the answer, the citation marker excluded.
+type Citation struct {
+	// Source is the number of the cited source in the prompt, starting at 1
+	Source int `json:"source"`
+	// Start is the offset of the first character of the cited sentence
+	Start int `json:"start"`
+	// End is the offset after the last character of the cited sentence
+	End int `json:"end"`
+	// Text is the cited sentence
+	Text string `json:"text"`
+	// Verified reports whether the cited source is one of the references of the answer
+	Verified bool `json:"verified"`
+
+	// The cited chunk, empty when the citation is not verified
+	ChunkID        string `json:"chunk_id,omitempty"`
+	KnowledgeID    string `json:"knowledge_id,omitempty"`
+	KnowledgeTitle string `json:"knowledge_title,omitempty"`
+	ChunkIndex     int    `json:"chunk_index"`
+	// StartAt and EndAt locate the cited paragraph in the knowledge content
+	StartAt int `json:"start_at"`
+	EndAt   int `json:"end_at"`
+}
+
+// Citations is a slice of Citation for database storage
+type Citations []Citation
+
+// Value implements the driver.Valuer interface for database serialization
+func (c Citations) Value() (driver.Value, error) {
+	if c == nil {
+		return json.Marshal([]Citation{})
+	}
+	return json.Marshal(c)
+}
+
+// Scan implements the sql.Scanner interface for database deserialization
+func (c *Citations) Scan(value interface{}) error {
+	if value == nil {
+		*c = make(Citations, 0)
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		*c = make(Citations, 0)
+		return nil
+	}
+	return json.Unmarshal(b, c)
+}
@@ -12,6 +12,15 @@ const (
 	RequestIDContextKey ContextKey = "RequestID"
 	// LoggerContextKey is the context key for logger
 	LoggerContextKey ContextKey = "Logger"
+	// PrincipalContextKey is the context key for the caller and its permissions
+	PrincipalContextKey ContextKey = "Principal"
+	// ToolCallIDContextKey is the context key for the ID of the agent tool call being executed
+	ToolCallIDContextKey ContextKey = "ToolCallID"
+	// MessageIDContextKey is the context key for the ID of the assistant message the agent is answering
+	MessageIDContextKey ContextKey = "MessageID"
+	// ToolApprovalUnavailableContextKey marks requests whose caller cannot approve tool calls, such as the
+	// OpenAI-compatible API, so that tools requiring approval fail right away instead of waiting for a decision
+	ToolApprovalUnavailableContextKey ContextKey = "ToolApprovalUnavailable"
 )
 
 // String returns the string representation of the context key
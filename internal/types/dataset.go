@@ -1,11 +1,73 @@
 package types
 
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultDatasetID is the ID of the built-in sample dataset
+const DefaultDatasetID = "default"
+
+// Evaluation dataset upload formats
+const (
+	DatasetFormatJSONL = "jsonl"
+	DatasetFormatCSV   = "csv"
+)
+
 // QAPair represents a complete QA example with question, related passages and answer
 type QAPair struct {
-	QID      int      // Question ID
-	Question string   // Question text
-	PIDs     []int    // Related passage IDs
-	Passages []string // Passage texts
-	AID      int      // Answer ID
-	Answer   string   // Answer text
+	QID      int      `json:"qid"`      // Question ID
+	Question string   `json:"question"` // Question text
+	PIDs     []int    `json:"pids"`     // Related passage IDs
+	Passages []string `json:"passages"` // Passage texts
+	AID      int      `json:"aid"`      // Answer ID
+	Answer   string   `json:"answer"`   // Answer text
+}
+
+// QAPairs is a list of QA pairs stored as JSON
+type QAPairs []*QAPair
+
+// Value implements the driver.Valuer interface, used to convert QAPairs to database value
+func (q QAPairs) Value() (driver.Value, error) {
+	return json.Marshal(q)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database value to QAPairs
+func (q *QAPairs) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, q)
+}
+
+// EvaluationDataset represents a user uploaded evaluation dataset
+type EvaluationDataset struct {
+	ID          string `json:"id"          gorm:"type:varchar(36);primaryKey"`
+	TenantID    uint64 `json:"tenant_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Upload format, jsonl or csv
+	Format string `json:"format"`
+	// Number of questions in the dataset
+	QACount int `json:"qa_count"`
+	// Number of distinct relevant passages in the dataset
+	PassageCount int `json:"passage_count"`
+	// Parsed QA pairs, omitted when listing datasets
+	QAPairs QAPairs `json:"qa_pairs,omitempty" gorm:"type:json"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+}
+
+// TableName returns the table name of EvaluationDataset
+func (EvaluationDataset) TableName() string {
+	return "evaluation_datasets"
 }
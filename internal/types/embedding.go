@@ -35,4 +35,6 @@ type IndexInfo struct {
 	KnowledgeBaseID string     // ID of the knowledge base
 	KnowledgeType   string     // Type of the knowledge (e.g., "faq", "manual")
 	IsEnabled       bool       // Whether the chunk is enabled for retrieval
+	// Fields of the knowledge matched by retrieval filters, stored by the engines which filter natively
+	FilterFields *IndexFilterFields
 }
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"database/sql/driver"
 	"encoding/json"
 	"time"
 
@@ -22,16 +23,38 @@ const (
 
 // EvaluationTask contains information about an evaluation task
 type EvaluationTask struct {
-	ID        string `json:"id"`         // Unique task ID
-	TenantID  uint64 `json:"tenant_id"`  // Tenant/Organization ID
-	DatasetID string `json:"dataset_id"` // Dataset ID for evaluation
+	ID              string `json:"id"                          gorm:"type:varchar(36);primaryKey"` // Unique task ID
+	TenantID        uint64 `json:"tenant_id"`                                                      // Tenant/Organization ID
+	DatasetID       string `json:"dataset_id"`                                                     // Dataset ID for evaluation
+	KnowledgeBaseID string `json:"knowledge_base_id,omitempty"`                                    // Knowledge base the settings are taken from
+	// Comparison the task belongs to and the pipeline variant it evaluates, empty for standalone tasks
+	ComparisonID string             `json:"comparison_id,omitempty"`
+	VariantName  string             `json:"variant_name,omitempty"`
+	Variant      *EvaluationVariant `json:"variant,omitempty" gorm:"type:json"`
+	// Chat model grading the answers, LLM-as-judge metrics are skipped when empty
+	JudgeModelID string `json:"judge_model_id,omitempty"`
+	// Temporary knowledge base and knowledge holding the dataset passages, kept across retries
+	EvalKnowledgeBaseID string `json:"eval_knowledge_base_id,omitempty"`
+	EvalKnowledgeID     string `json:"-"`
 
-	StartTime time.Time        `json:"start_time"`        // Task start time
-	Status    EvaluationStatue `json:"status"`            // Current task status
-	ErrMsg    string           `json:"err_msg,omitempty"` // Error message if failed
+	StartTime time.Time        `json:"start_time"`                              // Task start time
+	EndTime   *time.Time       `json:"end_time,omitempty"`                      // Task end time
+	Status    EvaluationStatue `json:"status"`                                  // Current task status
+	ErrMsg    string           `json:"err_msg,omitempty" gorm:"column:err_msg"` // Error message if failed
 
 	Total    int `json:"total,omitempty"`    // Total items to evaluate
 	Finished int `json:"finished,omitempty"` // Completed items count
+
+	Params JSON          `json:"-" gorm:"type:json"` // Serialized ChatManage used for every question
+	Metric *MetricResult `json:"-" gorm:"type:json"` // Averaged metrics of the finished questions
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName returns the table name of EvaluationTask
+func (EvaluationTask) TableName() string {
+	return "evaluation_tasks"
 }
 
 // EvaluationDetail contains detailed evaluation information
@@ -41,6 +64,156 @@ type EvaluationDetail struct {
 	Metric *MetricResult   `json:"metric,omitempty"` // Evaluation metrics
 }
 
+// EvaluationVariant describes one pipeline configuration of an evaluation, unset fields keep the defaults
+type EvaluationVariant struct {
+	// Name identifies the variant in comparison reports
+	Name string `json:"name"`
+	// Embedding model of the evaluation knowledge base
+	EmbeddingModelID string `json:"embedding_model_id,omitempty"`
+	// Chat model generating the answers
+	ChatModelID string `json:"chat_model_id,omitempty"`
+	// Rerank model ordering the retrieved passages
+	RerankModelID string `json:"rerank_model_id,omitempty"`
+	// Retrieval parameters
+	EmbeddingTopK    *int     `json:"embedding_top_k,omitempty"`
+	VectorThreshold  *float64 `json:"vector_threshold,omitempty"`
+	KeywordThreshold *float64 `json:"keyword_threshold,omitempty"`
+	RerankTopK       *int     `json:"rerank_top_k,omitempty"`
+	RerankThreshold  *float64 `json:"rerank_threshold,omitempty"`
+	// Chunking applied to the dataset passages before indexing
+	ChunkingConfig *ChunkingConfig `json:"chunking_config,omitempty"`
+	// Fusion of keyword and vector results
+	RetrievalConfig *RetrievalConfig `json:"retrieval_config,omitempty"`
+}
+
+// Value implements the driver.Valuer interface, used to convert EvaluationVariant to database value
+func (v EvaluationVariant) Value() (driver.Value, error) {
+	return json.Marshal(v)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database value to EvaluationVariant
+func (v *EvaluationVariant) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, v)
+}
+
+// EvaluationVariants is a list of evaluation variants stored as JSON
+type EvaluationVariants []*EvaluationVariant
+
+// Value implements the driver.Valuer interface, used to convert EvaluationVariants to database value
+func (v EvaluationVariants) Value() (driver.Value, error) {
+	return json.Marshal(v)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database value to EvaluationVariants
+func (v *EvaluationVariants) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, v)
+}
+
+// EvaluationComparisonRequest submits one dataset against several pipeline variants
+type EvaluationComparisonRequest struct {
+	Name            string               `json:"name"`
+	DatasetID       string               `json:"dataset_id"`
+	KnowledgeBaseID string               `json:"knowledge_base_id"`
+	JudgeModelID    string               `json:"judge_model_id"`
+	Variants        []*EvaluationVariant `json:"variants" binding:"required,min=2"`
+}
+
+// EvaluationComparison groups the evaluation tasks of several variants run on the same dataset
+type EvaluationComparison struct {
+	ID              string             `json:"id"                gorm:"type:varchar(36);primaryKey"`
+	TenantID        uint64             `json:"tenant_id"`
+	Name            string             `json:"name"`
+	DatasetID       string             `json:"dataset_id"`
+	KnowledgeBaseID string             `json:"knowledge_base_id,omitempty"`
+	Variants        EvaluationVariants `json:"variants"          gorm:"type:json"`
+	// Evaluation task ID of each variant, in variant order
+	TaskIDs   StringArray `json:"task_ids"          gorm:"type:json"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// TableName returns the table name of EvaluationComparison
+func (EvaluationComparison) TableName() string {
+	return "evaluation_comparisons"
+}
+
+// EvaluationComparisonReport is the side-by-side report of a comparison
+type EvaluationComparisonReport struct {
+	Comparison *EvaluationComparison `json:"comparison"`
+	// Variants in request order, the first one is the baseline of the deltas
+	Variants []*EvaluationVariantReport `json:"variants"`
+	// Metric deciding which variant wins a question
+	Metric string `json:"metric"`
+	// Questions answered by every variant and how many of them ended in a tie
+	Compared int `json:"compared"`
+	Ties     int `json:"ties"`
+	// Questions won by a single variant
+	Cases []*EvaluationComparisonCase `json:"cases"`
+}
+
+// EvaluationVariantReport summarizes one variant of a comparison
+type EvaluationVariantReport struct {
+	Name     string           `json:"name"`
+	TaskID   string           `json:"task_id"`
+	Status   EvaluationStatue `json:"status"`
+	Total    int              `json:"total"`
+	Finished int              `json:"finished"`
+	// Averaged metrics by metric name
+	Metrics map[string]float64 `json:"metrics"`
+	// Metric differences to the baseline variant
+	Deltas map[string]float64 `json:"deltas"`
+	// Number of questions this variant wins
+	Wins int `json:"wins"`
+}
+
+// EvaluationComparisonCase is a question where one variant scores higher than all others
+type EvaluationComparisonCase struct {
+	QuestionIndex int                `json:"question_index"`
+	Question      string             `json:"question"`
+	Winner        string             `json:"winner"`
+	Scores        map[string]float64 `json:"scores"`
+}
+
+// EvaluationQuestionResult keeps everything produced for one question of an evaluation task
+type EvaluationQuestionResult struct {
+	ID            uint64 `json:"id"             gorm:"primaryKey;autoIncrement"`
+	TaskID        string `json:"task_id"        gorm:"type:varchar(36);uniqueIndex:idx_eval_results_task_question"`
+	TenantID      uint64 `json:"tenant_id"`
+	QuestionIndex int    `json:"question_index" gorm:"uniqueIndex:idx_eval_results_task_question"`
+
+	QID                int        `json:"qid"                  gorm:"column:qid"`
+	Question           string     `json:"question"`
+	ExpectedAnswer     string     `json:"expected_answer"`
+	RelevantPassageIDs JSON       `json:"relevant_passage_ids" gorm:"type:json"` // Ground truth passage IDs
+	SearchResults      References `json:"search_results"       gorm:"type:json"` // Retrieval hits
+	RerankResults      References `json:"rerank_results"       gorm:"type:json"` // Rerank output
+	GeneratedAnswer    string     `json:"generated_answer"`
+	// Error message when the question could not be answered
+	ErrMsg string `json:"err_msg,omitempty" gorm:"column:err_msg"`
+
+	Metric *MetricResult `json:"metric" gorm:"type:json"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName returns the table name of EvaluationQuestionResult
+func (EvaluationQuestionResult) TableName() string {
+	return "evaluation_question_results"
+}
+
 // String returns JSON representation of EvaluationTask
 func (e *EvaluationTask) String() string {
 	b, _ := json.Marshal(e)
@@ -54,12 +227,34 @@ type MetricInput struct {
 
 	GeneratedTexts string // Generated text for evaluation
 	GeneratedGT    string // Ground truth text for comparison
+
+	Question string   // Question the text was generated for
+	Contexts []string // Retrieved context passed to the chat model, in rank order
 }
 
 // MetricResult contains evaluation metrics
 type MetricResult struct {
 	RetrievalMetrics  RetrievalMetrics  `json:"retrieval_metrics"`  // Retrieval performance metrics
 	GenerationMetrics GenerationMetrics `json:"generation_metrics"` // Text generation quality metrics
+	// Model-graded metrics, only present when the evaluation has a judge model
+	JudgeMetrics *JudgeMetrics `json:"judge_metrics,omitempty"`
+}
+
+// Value implements the driver.Valuer interface, used to convert MetricResult to database value
+func (m MetricResult) Value() (driver.Value, error) {
+	return json.Marshal(m)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database value to MetricResult
+func (m *MetricResult) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, m)
 }
 
 // RetrievalMetrics contains metrics for retrieval evaluation
@@ -84,6 +279,14 @@ type GenerationMetrics struct {
 	ROUGEL float64 `json:"rougel"` // ROUGE-L score
 }
 
+// JudgeMetrics contains answer and context quality scores graded by a judge model, all in [0, 1]
+type JudgeMetrics struct {
+	Faithfulness     float64 `json:"faithfulness"`      // Share of answer statements supported by the context
+	AnswerRelevance  float64 `json:"answer_relevance"`  // How directly the answer addresses the question
+	ContextPrecision float64 `json:"context_precision"` // Rank-weighted share of useful context chunks
+	ContextRecall    float64 `json:"context_recall"`    // Share of reference answer statements found in the context
+}
+
 // EvalState represents different stages of evaluation process
 type EvalState int
 
@@ -7,8 +7,13 @@ const (
 	TypeQuestionGeneration  = "question:generation"  // 问题生成任务
 	TypeSummaryGeneration   = "summary:generation"   // 摘要生成任务
 	TypeKBClone             = "kb:clone"             // 知识库复制任务
+	TypeKBReembed           = "kb:reembed"           // 知识库重新向量化任务
+	TypeEvaluation          = "evaluation:run"       // 评估任务
 	TypeIndexDelete         = "index:delete"         // 索引删除任务
 	TypeKBDelete            = "kb:delete"            // 知识库删除任务
+	TypeURLCrawl            = "url:crawl"            // 网页爬取任务
+	TypeURLRefresh          = "url:refresh"          // 网页定时刷新任务
+	TypeWebhookDelivery     = "webhook:delivery"     // Webhook投递任务
 )
 
 // ExtractChunkPayload represents the extract chunk task payload
@@ -32,6 +37,7 @@ type DocumentProcessPayload struct {
 	EnableMultimodel         bool     `json:"enable_multimodel"`
 	EnableQuestionGeneration bool     `json:"enable_question_generation"` // 是否启用问题生成
 	QuestionCount            int      `json:"question_count,omitempty"`   // 每个chunk生成的问题数量
+	Incremental              bool     `json:"incremental,omitempty"`      // 增量更新：保留内容未变化的chunk，仅向量化新增和变化的chunk
 }
 
 // FAQImportPayload represents the FAQ import task payload
@@ -50,6 +56,8 @@ type QuestionGenerationPayload struct {
 	KnowledgeBaseID string `json:"knowledge_base_id"`
 	KnowledgeID     string `json:"knowledge_id"`
 	QuestionCount   int    `json:"question_count"`
+	// ChunkIDs limits the generation to these text chunks, all text chunks when empty
+	ChunkIDs []string `json:"chunk_ids,omitempty"`
 }
 
 // SummaryGenerationPayload represents the summary generation task payload
@@ -67,6 +75,14 @@ type KBClonePayload struct {
 	TargetID string `json:"target_id"`
 }
 
+// KBReembedPayload represents the knowledge base re-embedding task payload
+type KBReembedPayload struct {
+	TenantID         uint64 `json:"tenant_id"`
+	TaskID           string `json:"task_id"`
+	KnowledgeBaseID  string `json:"knowledge_base_id"`
+	EmbeddingModelID string `json:"embedding_model_id"` // 目标嵌入模型ID
+}
+
 // IndexDeletePayload represents the index delete task payload
 type IndexDeletePayload struct {
 	TenantID         uint64                  `json:"tenant_id"`
@@ -109,6 +125,28 @@ type KBCloneProgress struct {
 	UpdatedAt int64             `json:"updated_at"` // 最后更新时间
 }
 
+// EvaluationPayload represents the evaluation task payload
+type EvaluationPayload struct {
+	TenantID uint64 `json:"tenant_id"`
+	TaskID   string `json:"task_id"`
+}
+
+// KBReembedProgress represents the progress of a knowledge base re-embedding task
+type KBReembedProgress struct {
+	TaskID          string            `json:"task_id"`
+	KnowledgeBaseID string            `json:"knowledge_base_id"`
+	SourceModelID   string            `json:"source_model_id"` // 原嵌入模型ID
+	TargetModelID   string            `json:"target_model_id"` // 目标嵌入模型ID
+	Status          KBCloneTaskStatus `json:"status"`
+	Progress        int               `json:"progress"`   // 0-100
+	Total           int               `json:"total"`      // 总分块数
+	Processed       int               `json:"processed"`  // 已处理分块数
+	Message         string            `json:"message"`    // 状态消息
+	Error           string            `json:"error"`      // 错误信息
+	CreatedAt       int64             `json:"created_at"` // 任务创建时间
+	UpdatedAt       int64             `json:"updated_at"` // 最后更新时间
+}
+
 // ChunkContext represents chunk content with surrounding context
 type ChunkContext struct {
 	ChunkID      string `json:"chunk_id"`
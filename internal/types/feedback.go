@@ -0,0 +1,175 @@
+package types
+
+import "time"
+
+// FeedbackRating is the rating of an answer by a user
+type FeedbackRating string
+
+const (
+	// FeedbackRatingUp is a thumbs up
+	FeedbackRatingUp FeedbackRating = "up"
+	// FeedbackRatingDown is a thumbs down
+	FeedbackRatingDown FeedbackRating = "down"
+)
+
+// Groupings of the feedback summary
+const (
+	FeedbackGroupByKnowledgeBase = "knowledge_base"
+	FeedbackGroupByKnowledge     = "knowledge"
+	FeedbackGroupByModel         = "model"
+	FeedbackGroupByDay           = "day"
+)
+
+// MessageFeedback is the feedback of a user on an answer, one per user and assistant message.
+// The query, the answer and the references are copied when rating, so that the analytics outlive the messages.
+type MessageFeedback struct {
+	// Unique identifier of the feedback, increasing with time
+	ID uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	// Tenant of the rated message
+	TenantID uint64 `json:"tenant_id" gorm:"index"`
+	// Session of the rated message
+	SessionID string `json:"session_id" gorm:"type:varchar(36)"`
+	// Rated assistant message
+	MessageID string `json:"message_id" gorm:"type:varchar(36)"`
+	// User giving the feedback, empty for API keys
+	UserID string `json:"user_id" gorm:"type:varchar(36)"`
+	// Thumbs up or down, empty when only references are marked
+	Rating FeedbackRating `json:"rating" gorm:"type:varchar(16)"`
+	// Short reason of the rating, e.g. "incorrect" or "incomplete"
+	Reason string `json:"reason" gorm:"type:varchar(64)"`
+	// Free comment of the user
+	Comment string `json:"comment" gorm:"type:text"`
+	// Question of the user
+	Query string `json:"query" gorm:"type:text"`
+	// Rated answer
+	Answer string `json:"answer" gorm:"type:text"`
+	// Chat model which generated the answer
+	ChatModelID string `json:"chat_model_id" gorm:"type:varchar(64)"`
+	// References of the answer, with the ones marked not relevant
+	References []*FeedbackReference `json:"references" gorm:"foreignKey:FeedbackID"`
+	// Time of the first rating
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+	// Time of the last change of the feedback
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// FeedbackReference is a reference of a rated answer
+type FeedbackReference struct {
+	ID         uint64 `json:"-" gorm:"primaryKey;autoIncrement"`
+	FeedbackID uint64 `json:"-" gorm:"index"`
+	TenantID   uint64 `json:"-"`
+	// Referenced chunk
+	ChunkID         string `json:"chunk_id" gorm:"type:varchar(255)"`
+	KnowledgeID     string `json:"knowledge_id" gorm:"type:varchar(36)"`
+	KnowledgeBaseID string `json:"knowledge_base_id" gorm:"type:varchar(36)"`
+	KnowledgeTitle  string `json:"knowledge_title" gorm:"type:text"`
+	// Content of the chunk when the answer was rated
+	Content string `json:"content" gorm:"type:text"`
+	// Whether the user marked the reference as not relevant to the question
+	Irrelevant bool `json:"irrelevant"`
+}
+
+// TableName returns the table name of FeedbackReference
+func (FeedbackReference) TableName() string {
+	return "message_feedback_references"
+}
+
+// MessageFeedbackRequest rates an answer and marks its references which are not relevant
+type MessageFeedbackRequest struct {
+	// up or down, may be empty when references are marked
+	Rating FeedbackRating `json:"rating"`
+	// Short reason of the rating
+	Reason string `json:"reason"`
+	// Free comment
+	Comment string `json:"comment"`
+	// Chunk IDs of the references of the answer which are not relevant
+	IrrelevantChunkIDs []string `json:"irrelevant_chunk_ids"`
+}
+
+// FeedbackFilter filters the feedback of the analytics, empty fields match everything
+type FeedbackFilter struct {
+	// Knowledge base of the references
+	KnowledgeBaseID string `form:"knowledge_base_id"`
+	// Knowledge of the references
+	KnowledgeID string `form:"knowledge_id"`
+	// Chat model of the answers
+	ModelID string `form:"model_id"`
+	// Earliest time of the feedback, inclusive
+	StartTime *time.Time `form:"start_time" time_format:"2006-01-02T15:04:05Z07:00"`
+	// Latest time of the feedback, exclusive
+	EndTime *time.Time `form:"end_time" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// FeedbackStats counts the feedback of a group of answers
+type FeedbackStats struct {
+	// Knowledge base ID, knowledge ID, model ID or day (YYYY-MM-DD) of the group, empty for the totals
+	Key string `json:"key,omitempty"`
+	// Knowledge base name, knowledge title or model name of the group
+	Name string `json:"name,omitempty"`
+	// Rated answers
+	Total int64 `json:"total"`
+	// Answers rated up
+	Up int64 `json:"up"`
+	// Answers rated down
+	Down int64 `json:"down"`
+	// Share of the answers rated up among the rated ones, 0 without ratings
+	Satisfaction float64 `json:"satisfaction" gorm:"-"`
+	// References of the rated answers
+	ReferenceCount int64 `json:"reference_count"`
+	// References marked not relevant
+	IrrelevantReferences int64 `json:"irrelevant_references"`
+}
+
+// FeedbackSummary aggregates the feedback of a tenant
+type FeedbackSummary struct {
+	// Grouping of the groups
+	GroupBy string `json:"group_by"`
+	// Totals of the filtered feedback
+	Totals *FeedbackStats `json:"totals"`
+	// Feedback by group, most downvoted first, or by day
+	Groups []*FeedbackStats `json:"groups"`
+}
+
+// FeedbackQueryStats is the feedback on a question, questions differing in case and surrounding spaces are merged
+type FeedbackQueryStats struct {
+	Query string `json:"query"`
+	// Rated answers to the question
+	Total int64 `json:"total"`
+	Up    int64 `json:"up"`
+	Down  int64 `json:"down"`
+	// Time of the last feedback
+	LastRatedAt time.Time `json:"last_rated_at"`
+}
+
+// FeedbackChunkStats is the feedback on the answers referencing a chunk
+type FeedbackChunkStats struct {
+	ChunkID         string `json:"chunk_id"`
+	KnowledgeID     string `json:"knowledge_id"`
+	KnowledgeBaseID string `json:"knowledge_base_id"`
+	KnowledgeTitle  string `json:"knowledge_title"`
+	Content         string `json:"content"`
+	// Answers referencing the chunk rated down
+	Downvotes int64 `json:"downvotes"`
+	// Answers referencing the chunk rated up
+	Upvotes int64 `json:"upvotes"`
+	// Times the chunk was marked not relevant
+	IrrelevantMarks int64 `json:"irrelevant_marks"`
+}
+
+// FeedbackEvaluationRecord is a negatively rated answer exported as a line of an evaluation dataset.
+// The answer is left to the reviewer, the passages are the references not marked irrelevant.
+type FeedbackEvaluationRecord struct {
+	Question string   `json:"question"`
+	Answer   string   `json:"answer"`
+	Passages []string `json:"passages"`
+
+	// Context of the rating, ignored when uploading the dataset
+	RatedAnswer        string    `json:"rated_answer"`
+	IrrelevantPassages []string  `json:"irrelevant_passages,omitempty"`
+	Reason             string    `json:"reason,omitempty"`
+	Comment            string    `json:"comment,omitempty"`
+	SessionID          string    `json:"session_id"`
+	MessageID          string    `json:"message_id"`
+	ChatModelID        string    `json:"chat_model_id,omitempty"`
+	RatedAt            time.Time `json:"rated_at"`
+}
@@ -0,0 +1,18 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// AnswerCacheService defines operations of the semantic answer cache of knowledge QA
+type AnswerCacheService interface {
+	// Lookup embeds the rewritten query and returns the most similar cached answer above the threshold.
+	// The key is nil when caching is disabled for the request, the entry is nil on a cache miss.
+	Lookup(ctx context.Context, chatManage *types.ChatManage) (*types.AnswerCacheEntry, *types.AnswerCacheKey, error)
+	// Store caches an answer and its references under the key returned by Lookup
+	Store(ctx context.Context, key *types.AnswerCacheKey, answer string, references []*types.SearchResult) error
+	// InvalidateKnowledgeBase drops the cached answers of every knowledge base set containing the knowledge base
+	InvalidateKnowledgeBase(ctx context.Context, knowledgeBaseID string) error
+}
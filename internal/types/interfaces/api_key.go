@@ -0,0 +1,31 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// APIKeyService manages the scoped API keys of a tenant
+type APIKeyService interface {
+	// CreateAPIKey creates an API key in the current tenant, the plain key is only returned here
+	CreateAPIKey(ctx context.Context, req *types.CreateAPIKeyRequest) (*types.CreatedAPIKey, error)
+	// ListAPIKeys lists the API keys of the current tenant
+	ListAPIKeys(ctx context.Context) ([]*types.APIKey, error)
+	// RevokeAPIKey revokes an API key of the current tenant
+	RevokeAPIKey(ctx context.Context, id string) error
+	// Authenticate returns the active API key matching a plain key, an Unauthorized error otherwise
+	Authenticate(ctx context.Context, key string) (*types.APIKey, error)
+}
+
+// APIKeyRepository stores scoped API keys
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *types.APIKey) error
+	GetByID(ctx context.Context, tenantID uint64, id string) (*types.APIKey, error)
+	GetByHash(ctx context.Context, keyHash string) (*types.APIKey, error)
+	List(ctx context.Context, tenantID uint64) ([]*types.APIKey, error)
+	Update(ctx context.Context, key *types.APIKey) error
+	// UpdateLastUsedAt records when a key was last used without touching its other fields
+	UpdateLastUsedAt(ctx context.Context, id string, lastUsedAt time.Time) error
+}
@@ -0,0 +1,23 @@
+package interfaces
+
+import (
+	"context"
+	"io"
+	"mime/multipart"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// AttachmentService stores the images and documents attached to questions and extracts their text
+type AttachmentService interface {
+	// PrepareAttachments stores the files attached to a question of a session. Documents are parsed into text,
+	// images are captioned by the VLM model of the first of the knowledge bases enabling one.
+	// The returned attachments hold the content of the files to answer the question.
+	PrepareAttachments(
+		ctx context.Context, sessionID string, knowledgeBaseIDs []string, files []*multipart.FileHeader,
+	) (types.MessageAttachments, error)
+	// GetAttachment gets an attachment of a message with the content of its file
+	GetAttachment(
+		ctx context.Context, sessionID string, messageID string, index int,
+	) (*types.MessageAttachment, io.ReadCloser, error)
+}
@@ -0,0 +1,33 @@
+package interfaces
+
+import (
+	"context"
+	"io"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// AuditService records administrative and data-changing operations in the append-only audit log
+type AuditService interface {
+	// Record writes an operation to the audit log, with the changed fields between before and after
+	Record(ctx context.Context, entry *types.AuditLog, before, after any) error
+	// ListAuditLogs lists the audit logs of the current tenant, newest first
+	ListAuditLogs(
+		ctx context.Context, filter *types.AuditLogFilter, page *types.Pagination,
+	) (*types.PageResult, error)
+	// ExportAuditLogs writes the audit logs of the current tenant to w as JSON lines, oldest first
+	ExportAuditLogs(ctx context.Context, filter *types.AuditLogFilter, w io.Writer) error
+}
+
+// AuditRepository stores the audit log, it has no way to change or delete records
+type AuditRepository interface {
+	Create(ctx context.Context, entry *types.AuditLog) error
+	// List lists the audit logs of a tenant, newest first
+	List(
+		ctx context.Context, tenantID uint64, filter *types.AuditLogFilter, page *types.Pagination,
+	) ([]*types.AuditLog, int64, error)
+	// ListAfter lists up to limit audit logs of a tenant with an ID greater than afterID, oldest first
+	ListAfter(
+		ctx context.Context, tenantID uint64, filter *types.AuditLogFilter, afterID uint64, limit int,
+	) ([]*types.AuditLog, error)
+}
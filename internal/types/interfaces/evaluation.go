@@ -2,18 +2,82 @@ package interfaces
 
 import (
 	"context"
+	"io"
 
 	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/hibiken/asynq"
 )
 
 // EvaluationService defines operations for evaluation tasks
 type EvaluationService interface {
 	// Evaluation starts a new evaluation task
 	Evaluation(ctx context.Context, datasetID string, knowledgeBaseID string,
-		chatModelID string, rerankModelID string,
+		chatModelID string, rerankModelID string, judgeModelID string,
 	) (*types.EvaluationDetail, error)
 	// EvaluationResult retrieves evaluation result by task ID
 	EvaluationResult(ctx context.Context, taskID string) (*types.EvaluationDetail, error)
+	// ListEvaluations lists the evaluation tasks of the current tenant
+	ListEvaluations(ctx context.Context, page *types.Pagination) (*types.PageResult, error)
+	// ResumeEvaluation re-enqueues an unfinished evaluation task, answered questions are skipped
+	ResumeEvaluation(ctx context.Context, taskID string) (*types.EvaluationDetail, error)
+	// ListQuestionResults lists the per-question results of an evaluation task
+	ListQuestionResults(ctx context.Context, taskID string, page *types.Pagination) (*types.PageResult, error)
+	// GetQuestionResult retrieves the result of one question of an evaluation task
+	GetQuestionResult(ctx context.Context, taskID string, questionIndex int) (*types.EvaluationQuestionResult, error)
+	// CompareEvaluations starts one evaluation task per pipeline variant on the same dataset
+	CompareEvaluations(ctx context.Context,
+		request *types.EvaluationComparisonRequest,
+	) (*types.EvaluationComparison, error)
+	// ListComparisons lists the comparisons of the current tenant
+	ListComparisons(ctx context.Context, page *types.Pagination) (*types.PageResult, error)
+	// GetComparisonReport builds the side-by-side report of a comparison,
+	// per-question wins are decided by the given metric and at most limit cases are returned
+	GetComparisonReport(ctx context.Context,
+		comparisonID string, metric string, limit int,
+	) (*types.EvaluationComparisonReport, error)
+	// ProcessEvaluation handles Asynq evaluation tasks
+	ProcessEvaluation(ctx context.Context, t *asynq.Task) error
+}
+
+// EvaluationRepository defines persistence operations for evaluation tasks and datasets
+type EvaluationRepository interface {
+	// CreateTask creates an evaluation task
+	CreateTask(ctx context.Context, task *types.EvaluationTask) error
+	// UpdateTask saves an evaluation task
+	UpdateTask(ctx context.Context, task *types.EvaluationTask) error
+	// GetTask retrieves an evaluation task of a tenant by ID
+	GetTask(ctx context.Context, tenantID uint64, id string) (*types.EvaluationTask, error)
+	// ListTasks lists the evaluation tasks of a tenant, newest first
+	ListTasks(ctx context.Context, tenantID uint64, page *types.Pagination) ([]*types.EvaluationTask, int64, error)
+
+	// SaveQuestionResult saves the result of one question, replacing a previous result of the same question
+	SaveQuestionResult(ctx context.Context, result *types.EvaluationQuestionResult) error
+	// GetQuestionResult retrieves the result of one question of a task
+	GetQuestionResult(ctx context.Context, taskID string, questionIndex int) (*types.EvaluationQuestionResult, error)
+	// ListQuestionResults lists the question results of a task ordered by question index
+	ListQuestionResults(ctx context.Context,
+		taskID string, page *types.Pagination,
+	) ([]*types.EvaluationQuestionResult, int64, error)
+	// ListQuestionMetrics lists the question index, question and metric of every answered question of a task
+	ListQuestionMetrics(ctx context.Context, taskID string) ([]*types.EvaluationQuestionResult, error)
+
+	// CreateComparison creates an evaluation comparison
+	CreateComparison(ctx context.Context, comparison *types.EvaluationComparison) error
+	// GetComparison retrieves an evaluation comparison of a tenant by ID
+	GetComparison(ctx context.Context, tenantID uint64, id string) (*types.EvaluationComparison, error)
+	// ListComparisons lists the evaluation comparisons of a tenant, newest first
+	ListComparisons(ctx context.Context,
+		tenantID uint64, page *types.Pagination,
+	) ([]*types.EvaluationComparison, int64, error)
+
+	// CreateDataset creates an evaluation dataset
+	CreateDataset(ctx context.Context, dataset *types.EvaluationDataset) error
+	// GetDataset retrieves an evaluation dataset of a tenant including its QA pairs
+	GetDataset(ctx context.Context, tenantID uint64, id string) (*types.EvaluationDataset, error)
+	// ListDatasets lists the evaluation datasets of a tenant without their QA pairs
+	ListDatasets(ctx context.Context, tenantID uint64) ([]*types.EvaluationDataset, error)
+	// DeleteDataset deletes an evaluation dataset of a tenant
+	DeleteDataset(ctx context.Context, tenantID uint64, id string) error
 }
 
 // Metrics defines interface for computing evaluation metrics
@@ -22,6 +86,12 @@ type Metrics interface {
 	Compute(metricInput *types.MetricInput) float64
 }
 
+// JudgeMetrics defines interface for metrics graded by a judge model
+type JudgeMetrics interface {
+	// Compute asks the judge model to score the input, the score is in [0, 1]
+	Compute(ctx context.Context, metricInput *types.MetricInput) (float64, error)
+}
+
 // EvalHook defines interface for evaluation process hooks
 type EvalHook interface {
 	// Handle processes evaluation state change
@@ -32,4 +102,12 @@ type EvalHook interface {
 type DatasetService interface {
 	// GetDatasetByID retrieves QA pairs from dataset by ID
 	GetDatasetByID(ctx context.Context, datasetID string) ([]*types.QAPair, error)
+	// CreateDataset parses an uploaded JSONL or CSV file into a new dataset
+	CreateDataset(ctx context.Context,
+		name string, description string, format string, reader io.Reader,
+	) (*types.EvaluationDataset, error)
+	// ListDatasets lists the uploaded datasets of the current tenant
+	ListDatasets(ctx context.Context) ([]*types.EvaluationDataset, error)
+	// DeleteDataset deletes an uploaded dataset
+	DeleteDataset(ctx context.Context, datasetID string) error
 }
@@ -0,0 +1,61 @@
+package interfaces
+
+import (
+	"context"
+	"io"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// FeedbackService collects the feedback of users on answers and aggregates it into retrieval-quality analytics
+type FeedbackService interface {
+	// RateMessage sets the feedback of the caller on an assistant message, replacing the previous one
+	RateMessage(
+		ctx context.Context, sessionID string, messageID string, request *types.MessageFeedbackRequest,
+	) (*types.MessageFeedback, error)
+	// GetMessageFeedback gets the feedback of the caller on an assistant message
+	GetMessageFeedback(ctx context.Context, sessionID string, messageID string) (*types.MessageFeedback, error)
+	// DeleteMessageFeedback withdraws the feedback of the caller on an assistant message
+	DeleteMessageFeedback(ctx context.Context, sessionID string, messageID string) error
+	// GetFeedbackSummary aggregates the feedback of the current tenant, by group
+	GetFeedbackSummary(
+		ctx context.Context, filter *types.FeedbackFilter, groupBy string,
+	) (*types.FeedbackSummary, error)
+	// ListWorstQueries lists the questions of the current tenant whose answers were rated down the most
+	ListWorstQueries(ctx context.Context, filter *types.FeedbackFilter, limit int) ([]*types.FeedbackQueryStats, error)
+	// ListDownvotedChunks lists the chunks of the current tenant most often referenced by downvoted answers
+	// or marked not relevant
+	ListDownvotedChunks(
+		ctx context.Context, filter *types.FeedbackFilter, limit int,
+	) ([]*types.FeedbackChunkStats, error)
+	// ExportNegativeFeedback writes the answers of the current tenant rated down to w as JSON lines
+	// of an evaluation dataset, oldest first
+	ExportNegativeFeedback(ctx context.Context, filter *types.FeedbackFilter, w io.Writer) error
+}
+
+// FeedbackRepository stores the feedback on answers with the references of the answers
+type FeedbackRepository interface {
+	// Upsert creates the feedback of a user on a message or replaces it, with its references
+	Upsert(ctx context.Context, feedback *types.MessageFeedback) error
+	// Get gets the feedback of a user on a message with its references
+	Get(ctx context.Context, tenantID uint64, messageID string, userID string) (*types.MessageFeedback, error)
+	// Delete deletes the feedback of a user on a message with its references
+	Delete(ctx context.Context, tenantID uint64, messageID string, userID string) error
+	// Summary aggregates the feedback of a tenant by group, the totals without group
+	Summary(
+		ctx context.Context, tenantID uint64, filter *types.FeedbackFilter, groupBy string,
+	) ([]*types.FeedbackStats, error)
+	// WorstQueries lists up to limit questions of a tenant with downvoted answers, most downvoted first
+	WorstQueries(
+		ctx context.Context, tenantID uint64, filter *types.FeedbackFilter, limit int,
+	) ([]*types.FeedbackQueryStats, error)
+	// DownvotedChunks lists up to limit chunks of a tenant referenced by downvoted answers or marked not relevant
+	DownvotedChunks(
+		ctx context.Context, tenantID uint64, filter *types.FeedbackFilter, limit int,
+	) ([]*types.FeedbackChunkStats, error)
+	// ListNegativeAfter lists up to limit downvoted feedback of a tenant with an ID greater than afterID,
+	// oldest first, with their references
+	ListNegativeAfter(
+		ctx context.Context, tenantID uint64, filter *types.FeedbackFilter, afterID uint64, limit int,
+	) ([]*types.MessageFeedback, error)
+}
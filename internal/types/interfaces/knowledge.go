@@ -20,13 +20,14 @@ type KnowledgeService interface {
 		enableMultimodel *bool,
 		customFileName string,
 	) (*types.Knowledge, error)
-	// CreateKnowledgeFromURL creates knowledge from a URL.
+	// CreateKnowledgeFromURL creates knowledge from a URL, options may add a crawl scope and a refresh schedule.
 	CreateKnowledgeFromURL(
 		ctx context.Context,
 		kbID string,
 		url string,
 		enableMultimodel *bool,
 		title string,
+		options *types.URLKnowledgeOptions,
 	) (*types.Knowledge, error)
 	// CreateKnowledgeFromPassage creates knowledge from text passages.
 	CreateKnowledgeFromPassage(ctx context.Context, kbID string, passage []string) (*types.Knowledge, error)
@@ -68,6 +69,17 @@ type KnowledgeService interface {
 		knowledgeID string,
 		payload *types.ManualKnowledgePayload,
 	) (*types.Knowledge, error)
+	// UpdateKnowledgeFromFile replaces the file of a knowledge and re-ingests only the changed chunks.
+	UpdateKnowledgeFromFile(
+		ctx context.Context,
+		knowledgeID string,
+		file *multipart.FileHeader,
+		metadata map[string]string,
+	) (*types.Knowledge, error)
+	// UpdateURLRefreshSchedule sets the cron refresh schedule of URL knowledge, empty disables refreshing.
+	UpdateURLRefreshSchedule(ctx context.Context, knowledgeID string, schedule string) (*types.Knowledge, error)
+	// RefreshURLKnowledge re-fetches URL knowledge now and re-indexes it when the content changed.
+	RefreshURLKnowledge(ctx context.Context, knowledgeID string) error
 	// CloneKnowledgeBase clones knowledge to another knowledge base.
 	CloneKnowledgeBase(ctx context.Context, srcID, dstID string) error
 	// UpdateImageInfo updates image information for a knowledge chunk.
@@ -111,6 +123,10 @@ type KnowledgeService interface {
 	GetRepository() KnowledgeRepository
 	// ProcessDocument handles Asynq document processing tasks
 	ProcessDocument(ctx context.Context, t *asynq.Task) error
+	// ProcessURLCrawl handles Asynq URL crawl tasks
+	ProcessURLCrawl(ctx context.Context, t *asynq.Task) error
+	// ProcessURLRefresh handles Asynq URL refresh tasks
+	ProcessURLRefresh(ctx context.Context, t *asynq.Task) error
 	// ProcessFAQImport handles Asynq FAQ import tasks
 	ProcessFAQImport(ctx context.Context, t *asynq.Task) error
 	// ProcessQuestionGeneration handles Asynq question generation tasks
@@ -123,6 +139,12 @@ type KnowledgeService interface {
 	GetKBCloneProgress(ctx context.Context, taskID string) (*types.KBCloneProgress, error)
 	// SaveKBCloneProgress saves the progress of a knowledge base clone task
 	SaveKBCloneProgress(ctx context.Context, progress *types.KBCloneProgress) error
+	// ReembedKnowledgeBase re-embeds a knowledge base with another embedding model, returns the task ID
+	ReembedKnowledgeBase(ctx context.Context, kbID string, modelID string) (string, error)
+	// ProcessKBReembed handles Asynq knowledge base re-embedding tasks
+	ProcessKBReembed(ctx context.Context, t *asynq.Task) error
+	// GetKBReembedProgress retrieves the progress of a knowledge base re-embedding task
+	GetKBReembedProgress(ctx context.Context, taskID string) (*types.KBReembedProgress, error)
 	// GetFAQImportProgress retrieves the progress of an FAQ import task
 	GetFAQImportProgress(ctx context.Context, taskID string) (*types.FAQImportProgress, error)
 	// SearchKnowledge searches knowledge items by keyword across the tenant.
@@ -160,10 +182,15 @@ type KnowledgeRepository interface {
 	// AminusB returns the difference set of A and B.
 	AminusB(ctx context.Context, Atenant uint64, A string, Btenant uint64, B string) ([]string, error)
 	UpdateKnowledgeColumn(ctx context.Context, id string, column string, value interface{}) error
+	// ListRefreshScheduledKnowledge lists the URL knowledge of all tenants that has a refresh schedule.
+	ListRefreshScheduledKnowledge(ctx context.Context) ([]*types.Knowledge, error)
 	// CountKnowledgeByKnowledgeBaseID counts the number of knowledge items in a knowledge base.
 	CountKnowledgeByKnowledgeBaseID(ctx context.Context, tenantID uint64, kbID string) (int64, error)
 	// CountKnowledgeByStatus counts the number of knowledge items with the specified parse status.
 	CountKnowledgeByStatus(ctx context.Context, tenantID uint64, kbID string, parseStatuses []string) (int64, error)
-	// SearchKnowledge searches knowledge items by keyword across the tenant.
-	SearchKnowledge(ctx context.Context, tenantID uint64, keyword string, offset, limit int) ([]*types.Knowledge, bool, error)
+	// SearchKnowledge searches knowledge items by keyword across the tenant,
+	// limited to the given knowledge bases unless kbIDs is nil.
+	SearchKnowledge(
+		ctx context.Context, tenantID uint64, kbIDs []string, keyword string, offset, limit int,
+	) ([]*types.Knowledge, bool, error)
 }
@@ -154,6 +154,16 @@ type KnowledgeBaseRepository interface {
 	//   - Possible errors such as record not existing, database errors, etc.
 	UpdateKnowledgeBase(ctx context.Context, kb *types.KnowledgeBase) error
 
+	// UpdateEmbeddingModel switches the embedding model of a knowledge base and all of its knowledge
+	// in a single transaction
+	// Parameters:
+	//   - ctx: Context information
+	//   - id: Knowledge base ID
+	//   - modelID: New embedding model ID
+	// Returns:
+	//   - Possible errors such as database errors, etc.
+	UpdateEmbeddingModel(ctx context.Context, id string, modelID string) error
+
 	// DeleteKnowledgeBase deletes a knowledge base record
 	// Parameters:
 	//   - ctx: Context information
@@ -0,0 +1,38 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/Tencent/WeKnora/internal/models/chat"
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// MemoryService remembers facts and preferences of the users across sessions
+type MemoryService interface {
+	// RecallMemories returns the memories of the current user most relevant to the query,
+	// nothing when the request is not made by a user
+	RecallMemories(ctx context.Context, embeddingModelID string, query string) ([]*types.UserMemory, error)
+	// ExtractMemories extracts the durable facts and preferences of the current user from a question and its answer
+	// with the chat model, and stores them, updating the memories they supersede
+	ExtractMemories(
+		ctx context.Context, chatModel chat.Chat, embeddingModelID string,
+		sessionID string, query string, answer string,
+	) error
+	// ListMemories lists the memories of the current user, newest first
+	ListMemories(ctx context.Context) ([]*types.UserMemory, error)
+	// DeleteMemory deletes a memory of the current user
+	DeleteMemory(ctx context.Context, id string) error
+	// DeleteAllMemories deletes all memories of the current user
+	DeleteAllMemories(ctx context.Context) error
+}
+
+// MemoryRepository stores the user memories
+type MemoryRepository interface {
+	Create(ctx context.Context, memory *types.UserMemory) error
+	Update(ctx context.Context, memory *types.UserMemory) error
+	GetByID(ctx context.Context, tenantID uint64, userID string, id string) (*types.UserMemory, error)
+	// ListByUser lists the memories of a user, newest first
+	ListByUser(ctx context.Context, tenantID uint64, userID string) ([]*types.UserMemory, error)
+	Delete(ctx context.Context, tenantID uint64, userID string, id string) error
+	DeleteByUser(ctx context.Context, tenantID uint64, userID string) error
+}
@@ -0,0 +1,53 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// PermissionService manages tenant members, their roles and their knowledge base permissions
+type PermissionService interface {
+	// ResolvePrincipal returns the role and knowledge base permissions of a user in a tenant,
+	// a Forbidden error when the user may not access the tenant
+	ResolvePrincipal(ctx context.Context, user *types.User, tenantID uint64) (*types.Principal, error)
+	// ListJoinedTenants lists the tenants of the current user, its own tenant first
+	ListJoinedTenants(ctx context.Context) ([]*types.TenantMembership, error)
+	// ListMembers lists the members of the current tenant
+	ListMembers(ctx context.Context) ([]*types.TenantMember, error)
+	// AddMember adds the user with the given email to the current tenant
+	AddMember(ctx context.Context, email string, role types.TenantRole) (*types.TenantMember, error)
+	// UpdateMemberRole changes the role of a member of the current tenant
+	UpdateMemberRole(ctx context.Context, userID string, role types.TenantRole) (*types.TenantMember, error)
+	// RemoveMember removes a member and its knowledge base permissions from the current tenant
+	RemoveMember(ctx context.Context, userID string) error
+	// ListKnowledgeBaseGrants lists the permissions granted on a knowledge base
+	ListKnowledgeBaseGrants(ctx context.Context, kbID string) ([]*types.KnowledgeBaseGrant, error)
+	// GrantKnowledgeBase grants a member a permission on a knowledge base, replacing its previous permission
+	GrantKnowledgeBase(
+		ctx context.Context, kbID string, userID string, permission types.KBPermission,
+	) (*types.KnowledgeBaseGrant, error)
+	// RevokeKnowledgeBase removes the permission of a member on a knowledge base
+	RevokeKnowledgeBase(ctx context.Context, kbID string, userID string) error
+	// GrantCreator gives the caller admin permission on a knowledge base it created
+	GrantCreator(ctx context.Context, kbID string) error
+	// DeleteKnowledgeBaseGrants removes all permissions granted on a knowledge base
+	DeleteKnowledgeBaseGrants(ctx context.Context, kbID string) error
+}
+
+// PermissionRepository stores tenant members and knowledge base grants
+type PermissionRepository interface {
+	CreateMember(ctx context.Context, member *types.TenantMember) error
+	UpdateMember(ctx context.Context, member *types.TenantMember) error
+	GetMember(ctx context.Context, tenantID uint64, userID string) (*types.TenantMember, error)
+	ListMembers(ctx context.Context, tenantID uint64) ([]*types.TenantMember, error)
+	ListMembershipsByUser(ctx context.Context, userID string) ([]*types.TenantMember, error)
+	// DeleteMember deletes a membership together with the member's grants in the tenant
+	DeleteMember(ctx context.Context, tenantID uint64, userID string) error
+	GetGrant(ctx context.Context, tenantID uint64, kbID string, userID string) (*types.KnowledgeBaseGrant, error)
+	SaveGrant(ctx context.Context, grant *types.KnowledgeBaseGrant) error
+	ListGrantsByKnowledgeBase(ctx context.Context, tenantID uint64, kbID string) ([]*types.KnowledgeBaseGrant, error)
+	ListGrantsByUser(ctx context.Context, tenantID uint64, userID string) ([]*types.KnowledgeBaseGrant, error)
+	DeleteGrant(ctx context.Context, tenantID uint64, kbID string, userID string) error
+	DeleteGrantsByKnowledgeBase(ctx context.Context, tenantID uint64, kbID string) error
+}
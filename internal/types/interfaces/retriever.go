@@ -52,10 +52,30 @@ type RetrieveEngineRepository interface {
 	// DeleteByKnowledgeIDList deletes the index info by knowledge id list
 	DeleteByKnowledgeIDList(ctx context.Context, knowledgeIDList []string, dimension int, knowledgeType string) error
 
+	// DeleteByKnowledgeBaseID deletes all the index info of a knowledge base
+	DeleteByKnowledgeBaseID(ctx context.Context, knowledgeBaseID string, dimension int, knowledgeType string) error
+
+	// PromoteShadowIndices replaces the index info of targetKnowledgeBaseID with the shadow index info
+	// written under shadowKnowledgeBaseID, e.g. after re-embedding a knowledge base with another model
+	// The shadow index info is relabeled before the replaced index info is deleted, so that a failed
+	// promotion leaves the knowledge base searchable
+	// shadowDimension: dimension of the shadow index info
+	// targetDimension: dimension of the index info being replaced
+	PromoteShadowIndices(ctx context.Context,
+		shadowKnowledgeBaseID string,
+		targetKnowledgeBaseID string,
+		shadowDimension int,
+		targetDimension int,
+	) error
+
 	// BatchUpdateChunkEnabledStatus updates the enabled status of chunks in batch
 	// chunkStatusMap: map of chunk ID to enabled status (true = enabled, false = disabled)
 	BatchUpdateChunkEnabledStatus(ctx context.Context, chunkStatusMap map[string]bool) error
 
+	// BatchUpdateKnowledgeFilterFields replaces the filter fields stored on the indices of knowledge,
+	// after its tag or metadata changed. fields maps knowledge IDs to their new filter fields
+	BatchUpdateKnowledgeFilterFields(ctx context.Context, fields map[string]*types.IndexFilterFields) error
+
 	// RetrieveEngine retrieves the engine
 	RetrieveEngine
 }
@@ -115,10 +135,26 @@ type RetrieveEngineService interface {
 	// DeleteByKnowledgeIDList deletes the index info by knowledge id list
 	DeleteByKnowledgeIDList(ctx context.Context, knowledgeIDList []string, dimension int, knowledgeType string) error
 
+	// DeleteByKnowledgeBaseID deletes all the index info of a knowledge base
+	DeleteByKnowledgeBaseID(ctx context.Context, knowledgeBaseID string, dimension int, knowledgeType string) error
+
+	// PromoteShadowIndices replaces the index info of targetKnowledgeBaseID with the shadow index info
+	// written under shadowKnowledgeBaseID
+	PromoteShadowIndices(ctx context.Context,
+		shadowKnowledgeBaseID string,
+		targetKnowledgeBaseID string,
+		shadowDimension int,
+		targetDimension int,
+	) error
+
 	// BatchUpdateChunkEnabledStatus updates the enabled status of chunks in batch
 	// chunkStatusMap: map of chunk ID to enabled status (true = enabled, false = disabled)
 	BatchUpdateChunkEnabledStatus(ctx context.Context, chunkStatusMap map[string]bool) error
 
+	// BatchUpdateKnowledgeFilterFields replaces the filter fields stored on the indices of knowledge,
+	// after its tag or metadata changed. fields maps knowledge IDs to their new filter fields
+	BatchUpdateKnowledgeFilterFields(ctx context.Context, fields map[string]*types.IndexFilterFields) error
+
 	// RetrieveEngine retrieves the engine
 	RetrieveEngine
 }
@@ -29,25 +29,35 @@ type SessionService interface {
 	// KnowledgeQA performs knowledge-based question answering
 	// knowledgeBaseIDs: list of knowledge base IDs to search (supports multi-KB)
 	// knowledgeIDs: list of specific knowledge (file) IDs to search
+	// filter: optional filter on the tags, file type, creation time or metadata of the searched documents
 	// summaryModelID: optional summary model ID override (if empty, uses session/KB default)
 	// webSearchEnabled: whether to enable web search to supplement knowledge base results
+	// attachments: optional images and documents attached to the question, prepared by the AttachmentService
 	// Events are emitted through eventBus (references, answer chunks, completion)
 	KnowledgeQA(ctx context.Context,
 		session *types.Session, query string, knowledgeBaseIDs []string, knowledgeIDs []string,
-		assistantMessageID string, summaryModelID string, webSearchEnabled bool, eventBus *event.EventBus,
+		filter *types.RetrievalFilter, assistantMessageID string, summaryModelID string, webSearchEnabled bool,
+		attachments types.MessageAttachments, eventBus *event.EventBus,
 	) error
 	// KnowledgeQAByEvent performs knowledge-based question answering by event
 	KnowledgeQAByEvent(ctx context.Context, chatManage *types.ChatManage, eventList []types.EventType) error
 	// SearchKnowledge performs knowledge-based search, without summarization
 	// knowledgeBaseIDs: list of knowledge base IDs to search (supports multi-KB)
 	// knowledgeIDs: list of specific knowledge (file) IDs to search
-	SearchKnowledge(ctx context.Context, knowledgeBaseIDs []string, knowledgeIDs []string, query string) ([]*types.SearchResult, error)
+	// filter: optional filter on the tags, file type, creation time or metadata of the searched documents
+	SearchKnowledge(ctx context.Context, knowledgeBaseIDs []string, knowledgeIDs []string, query string,
+		filter *types.RetrievalFilter,
+	) ([]*types.SearchResult, error)
 	// AgentQA performs agent-based question answering with conversation history and streaming support
+	// filter is optional and narrows the documents searched by the knowledge_search tool
+	// attachments are the optional images and documents attached to the question
 	// eventBus is optional - if nil, uses service's default EventBus
 	AgentQA(
 		ctx context.Context,
 		session *types.Session,
 		query string,
+		filter *types.RetrievalFilter,
+		attachments types.MessageAttachments,
 		assistantMessageID string,
 		eventBus *event.EventBus,
 	) error
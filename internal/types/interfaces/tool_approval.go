@@ -0,0 +1,39 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// ToolApprovalService persists the tool calls of agents which wait for a person to approve them
+type ToolApprovalService interface {
+	// CreateToolApproval creates a pending approval in the current tenant, expiring after the timeout
+	CreateToolApproval(
+		ctx context.Context, approval *types.ToolApproval, timeout time.Duration,
+	) (*types.ToolApproval, error)
+	// WaitForToolApproval waits until the approval is decided or expires, and returns it.
+	// An approval still pending when ctx is done is marked expired.
+	WaitForToolApproval(ctx context.Context, id string) (*types.ToolApproval, error)
+	// DecideToolApproval approves or rejects a pending approval of a session of the current tenant
+	DecideToolApproval(
+		ctx context.Context, sessionID string, id string, req *types.ToolApprovalDecisionRequest,
+	) (*types.ToolApproval, error)
+	// ListToolApprovals lists the approvals of a session of the current tenant, newest first,
+	// optionally only those with the given status
+	ListToolApprovals(
+		ctx context.Context, sessionID string, status types.ToolApprovalStatus,
+	) ([]*types.ToolApproval, error)
+}
+
+// ToolApprovalRepository stores the tool approvals
+type ToolApprovalRepository interface {
+	Create(ctx context.Context, approval *types.ToolApproval) error
+	GetByID(ctx context.Context, tenantID uint64, id string) (*types.ToolApproval, error)
+	// Decide sets the decision of an approval if it is still pending, and reports whether it was
+	Decide(ctx context.Context, approval *types.ToolApproval) (bool, error)
+	ListBySession(
+		ctx context.Context, tenantID uint64, sessionID string, status types.ToolApprovalStatus,
+	) ([]*types.ToolApproval, error)
+}
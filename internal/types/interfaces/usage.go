@@ -0,0 +1,39 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/Tencent/WeKnora/internal/models/chat"
+	"github.com/Tencent/WeKnora/internal/models/embedding"
+	"github.com/Tencent/WeKnora/internal/models/rerank"
+	"github.com/Tencent/WeKnora/internal/types"
+)
+
+// UsageService meters model calls per tenant, model and API key and enforces usage limits
+type UsageService interface {
+	// CheckQuota counts a model call against the limits of the tenant and API key in the context,
+	// returning a TooManyRequests error once a rate limit or monthly token budget is exceeded
+	CheckQuota(ctx context.Context) error
+	// RecordUsage writes a model call to the usage ledger
+	RecordUsage(ctx context.Context, record *types.UsageRecord)
+	// GetUsageReport reports the usage of the current tenant by day and model, both days inclusive
+	GetUsageReport(ctx context.Context, from, to time.Time, apiKeyID string) (*types.UsageReport, error)
+	// WrapChat meters the calls of a chat model
+	WrapChat(model chat.Chat) chat.Chat
+	// WrapEmbedder meters the calls of an embedding model
+	WrapEmbedder(model embedding.Embedder) embedding.Embedder
+	// WrapReranker meters the calls of a rerank model
+	WrapReranker(model rerank.Reranker) rerank.Reranker
+}
+
+// UsageRepository stores the usage ledger
+type UsageRepository interface {
+	Create(ctx context.Context, record *types.UsageRecord) error
+	// SumTokens sums the tokens of a tenant since a time, restricted to an API key when apiKeyID is not empty
+	SumTokens(ctx context.Context, tenantID uint64, apiKeyID string, since time.Time) (int64, error)
+	// Report aggregates the usage of a tenant in [from, to) by day, model and call type
+	Report(
+		ctx context.Context, tenantID uint64, apiKeyID string, from, to time.Time,
+	) ([]*types.UsageReportItem, error)
+}
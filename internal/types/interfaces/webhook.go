@@ -0,0 +1,47 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/Tencent/WeKnora/internal/types"
+	"github.com/hibiken/asynq"
+)
+
+// WebhookService manages the webhooks of a tenant and delivers lifecycle events to them
+type WebhookService interface {
+	// CreateWebhook creates a webhook in the current tenant, the signing secret is only returned here
+	CreateWebhook(ctx context.Context, req *types.CreateWebhookRequest) (*types.WebhookWithSecret, error)
+	// GetWebhook gets a webhook of the current tenant
+	GetWebhook(ctx context.Context, id string) (*types.Webhook, error)
+	// ListWebhooks lists the webhooks of the current tenant
+	ListWebhooks(ctx context.Context) ([]*types.Webhook, error)
+	// UpdateWebhook updates a webhook of the current tenant, the secret is returned when it is rotated
+	UpdateWebhook(ctx context.Context, id string, req *types.UpdateWebhookRequest) (*types.WebhookWithSecret, error)
+	// DeleteWebhook deletes a webhook of the current tenant together with its deliveries
+	DeleteWebhook(ctx context.Context, id string) error
+	// ListDeliveries lists the deliveries of a webhook of the current tenant, newest first
+	ListDeliveries(ctx context.Context, id string, page *types.Pagination) (*types.PageResult, error)
+	// PingWebhook delivers a webhook.ping event to a webhook of the current tenant
+	PingWebhook(ctx context.Context, id string) (*types.WebhookDelivery, error)
+	// RedeliverDelivery delivers the payload of a previous delivery again, as a new delivery
+	RedeliverDelivery(ctx context.Context, id string, deliveryID string) (*types.WebhookDelivery, error)
+	// ProcessWebhookDelivery handles Asynq webhook delivery tasks
+	ProcessWebhookDelivery(ctx context.Context, t *asynq.Task) error
+}
+
+// WebhookRepository stores webhooks and their deliveries
+type WebhookRepository interface {
+	Create(ctx context.Context, webhook *types.Webhook) error
+	GetByID(ctx context.Context, tenantID uint64, id string) (*types.Webhook, error)
+	List(ctx context.Context, tenantID uint64) ([]*types.Webhook, error)
+	Update(ctx context.Context, webhook *types.Webhook) error
+	// Delete deletes a webhook and its deliveries
+	Delete(ctx context.Context, tenantID uint64, id string) error
+
+	CreateDelivery(ctx context.Context, delivery *types.WebhookDelivery) error
+	GetDelivery(ctx context.Context, tenantID uint64, id string) (*types.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *types.WebhookDelivery) error
+	ListDeliveries(
+		ctx context.Context, tenantID uint64, webhookID string, page *types.Pagination,
+	) ([]*types.WebhookDelivery, int64, error)
+}
@@ -101,6 +101,16 @@ type Knowledge struct {
 	ProcessedAt *time.Time `json:"processed_at"`
 	// Error message of the knowledge
 	ErrorMessage string `json:"error_message"`
+	// Crawl scope of URL knowledge, the pages found are imported as knowledge linked by CrawlRootID
+	CrawlConfig *URLCrawlConfig `json:"crawl_config,omitempty" gorm:"type:json"`
+	// ID of the URL knowledge whose crawl imported this page
+	CrawlRootID string `json:"crawl_root_id,omitempty" gorm:"type:varchar(36);index"`
+	// Cron expression of the URL refresh schedule, empty when the page is not refreshed
+	RefreshSchedule string `json:"refresh_schedule,omitempty" gorm:"type:varchar(64)"`
+	// Hash of the page content fetched by the last refresh
+	ContentHash string `json:"-" gorm:"type:varchar(64)"`
+	// Last time the URL was refreshed
+	LastRefreshedAt *time.Time `json:"last_refreshed_at,omitempty"`
 	// Deletion time of the knowledge
 	DeletedAt gorm.DeletedAt `json:"deleted_at"         gorm:"index"`
 	// Knowledge base name (not stored in database, populated on query)
@@ -67,6 +67,12 @@ type KnowledgeBase struct {
 	FAQConfig *FAQConfig `yaml:"faq_config"              json:"faq_config"              gorm:"column:faq_config;type:json"`
 	// QuestionGenerationConfig stores question generation configuration for document knowledge bases
 	QuestionGenerationConfig *QuestionGenerationConfig `yaml:"question_generation_config" json:"question_generation_config" gorm:"column:question_generation_config;type:json"`
+	// RetrievalConfig stores how hybrid retrieval results are fused
+	RetrievalConfig *RetrievalConfig `yaml:"retrieval_config"        json:"retrieval_config"        gorm:"column:retrieval_config;type:json"`
+	// AnswerCacheConfig stores the semantic answer cache configuration
+	AnswerCacheConfig *AnswerCacheConfig `yaml:"answer_cache_config"     json:"answer_cache_config"     gorm:"column:answer_cache_config;type:json"`
+	// PipelineConfig stores the chat pipeline of knowledge QA, the default pipeline is used when nil
+	PipelineConfig *PipelineConfig `yaml:"pipeline_config"         json:"pipeline_config"         gorm:"column:pipeline_config;type:json"`
 	// Creation time of the knowledge base
 	CreatedAt time.Time `yaml:"created_at"              json:"created_at"`
 	// Last updated time of the knowledge base
@@ -91,6 +97,12 @@ type KnowledgeBaseConfig struct {
 	ImageProcessingConfig ImageProcessingConfig `yaml:"image_processing_config" json:"image_processing_config"`
 	// FAQ configuration (only for FAQ type knowledge bases)
 	FAQConfig *FAQConfig `yaml:"faq_config"              json:"faq_config"`
+	// Retrieval configuration, keeps the current one when nil
+	RetrievalConfig *RetrievalConfig `yaml:"retrieval_config"        json:"retrieval_config"`
+	// Semantic answer cache configuration, keeps the current one when nil
+	AnswerCacheConfig *AnswerCacheConfig `yaml:"answer_cache_config"     json:"answer_cache_config"`
+	// Chat pipeline, keeps the current one when nil and resets to the default one when it has no stages
+	PipelineConfig *PipelineConfig `yaml:"pipeline_config"         json:"pipeline_config"`
 }
 
 // ChunkingConfig represents the document splitting configuration
@@ -301,6 +313,127 @@ func (f *FAQConfig) Scan(value interface{}) error {
 	return json.Unmarshal(b, f)
 }
 
+// FusionMode represents how keyword and vector retrieval results are combined
+type FusionMode string
+
+const (
+	// FusionModeRRF ranks results by reciprocal rank fusion: sum(1 / (k + rank))
+	FusionModeRRF FusionMode = "rrf"
+	// FusionModeWeighted ranks results by alpha*vector + (1-alpha)*keyword on min-max normalized scores
+	FusionModeWeighted FusionMode = "weighted"
+	// FusionModeMax ranks results by the highest min-max normalized score of any retriever
+	FusionModeMax FusionMode = "max"
+)
+
+const (
+	// DefaultRRFK is the RRF rank constant, 60 is a common choice that works well in practice
+	DefaultRRFK = 60
+	// DefaultFusionAlpha is the default vector weight of the weighted fusion mode
+	DefaultFusionAlpha = 0.5
+)
+
+// RetrievalConfig represents the hybrid retrieval configuration of a knowledge base
+type RetrievalConfig struct {
+	// Fusion mode, defaults to rrf
+	FusionMode FusionMode `yaml:"fusion_mode" json:"fusion_mode"`
+	// RRF rank constant k, only used by the rrf mode (default: 60)
+	RRFK int `yaml:"rrf_k"       json:"rrf_k,omitempty"`
+	// Weight of the vector scores in [0, 1], only used by the weighted mode (default: 0.5)
+	Alpha *float64 `yaml:"alpha"       json:"alpha,omitempty"`
+}
+
+// WithDefaults returns a copy of the config with unset fields filled, a nil config yields the defaults
+func (c *RetrievalConfig) WithDefaults() RetrievalConfig {
+	res := RetrievalConfig{FusionMode: FusionModeRRF, RRFK: DefaultRRFK}
+	if c == nil {
+		alpha := DefaultFusionAlpha
+		res.Alpha = &alpha
+		return res
+	}
+	if c.FusionMode != "" {
+		res.FusionMode = c.FusionMode
+	}
+	if c.RRFK > 0 {
+		res.RRFK = c.RRFK
+	}
+	alpha := DefaultFusionAlpha
+	if c.Alpha != nil {
+		alpha = *c.Alpha
+	}
+	res.Alpha = &alpha
+	return res
+}
+
+// Value implements driver.Valuer
+func (c RetrievalConfig) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+// Scan implements sql.Scanner
+func (c *RetrievalConfig) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, c)
+}
+
+const (
+	// DefaultAnswerCacheSimilarityThreshold is the default cosine similarity a cached query needs to be reused
+	DefaultAnswerCacheSimilarityThreshold = 0.95
+	// DefaultAnswerCacheTTLSeconds is the default lifetime of a cached answer (1 day)
+	DefaultAnswerCacheTTLSeconds = 86400
+)
+
+// AnswerCacheConfig represents the semantic answer cache configuration of a knowledge base
+type AnswerCacheConfig struct {
+	// Whether answers of questions on this knowledge base may be cached
+	Enabled bool `yaml:"enabled"              json:"enabled"`
+	// Minimum cosine similarity between the rewritten queries in (0, 1] (default: 0.95)
+	SimilarityThreshold float64 `yaml:"similarity_threshold" json:"similarity_threshold,omitempty"`
+	// Lifetime of a cached answer in seconds (default: 86400)
+	TTLSeconds int `yaml:"ttl_seconds"          json:"ttl_seconds,omitempty"`
+}
+
+// WithDefaults returns a copy of the config with unset fields filled, a nil config is disabled
+func (c *AnswerCacheConfig) WithDefaults() AnswerCacheConfig {
+	res := AnswerCacheConfig{
+		SimilarityThreshold: DefaultAnswerCacheSimilarityThreshold,
+		TTLSeconds:          DefaultAnswerCacheTTLSeconds,
+	}
+	if c == nil {
+		return res
+	}
+	res.Enabled = c.Enabled
+	if c.SimilarityThreshold > 0 {
+		res.SimilarityThreshold = c.SimilarityThreshold
+	}
+	if c.TTLSeconds > 0 {
+		res.TTLSeconds = c.TTLSeconds
+	}
+	return res
+}
+
+// Value implements driver.Valuer
+func (c AnswerCacheConfig) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+// Scan implements sql.Scanner
+func (c *AnswerCacheConfig) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, c)
+}
+
 // EnsureDefaults 确保类型与配置具备默认值
 func (kb *KnowledgeBase) EnsureDefaults() {
 	if kb == nil {
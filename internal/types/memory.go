@@ -0,0 +1,43 @@
+package types
+
+import "time"
+
+// MemoryCategory is the kind of a user memory
+type MemoryCategory string
+
+const (
+	// MemoryCategoryFact is a durable fact about the user, such as their product line or environment
+	MemoryCategoryFact MemoryCategory = "fact"
+	// MemoryCategoryPreference is a preference of the user, such as the language or format of the answers
+	MemoryCategoryPreference MemoryCategory = "preference"
+)
+
+// UserMemory is a fact or preference the agent remembers about a user across sessions
+type UserMemory struct {
+	// Unique identifier of the memory
+	ID string `json:"id" gorm:"type:varchar(36);primaryKey"`
+	// Tenant ID
+	TenantID uint64 `json:"tenant_id" gorm:"index"`
+	// User the memory is about
+	UserID string `json:"user_id" gorm:"type:varchar(36);index"`
+	// The remembered fact or preference, as a self-contained sentence
+	Content string `json:"content" gorm:"type:text"`
+	// Kind of the memory
+	Category MemoryCategory `json:"category" gorm:"type:varchar(32)"`
+	// Session the memory was last extracted from
+	SourceSessionID string `json:"source_session_id" gorm:"type:varchar(36)"`
+	// Embedding model of the embedding
+	EmbeddingModelID string `json:"-" gorm:"type:varchar(64)"`
+	// Embedding of the content, used to recall the memories relevant to a query
+	Embedding []float32 `json:"-" gorm:"type:jsonb;serializer:json"`
+	// Creation time of the memory
+	CreatedAt time.Time `json:"created_at"`
+	// Last updated time of the memory
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ExtractedMemory is a memory the chat model extracted from a conversation
+type ExtractedMemory struct {
+	Content  string         `json:"content"`
+	Category MemoryCategory `json:"category"`
+}
@@ -76,6 +76,12 @@ type Message struct {
 	// Mentioned knowledge bases and files (for user messages)
 	// Stores the @mentioned items when user sends a message
 	MentionedItems MentionedItems `json:"mentioned_items,omitempty" gorm:"type:jsonb,column:mentioned_items"`
+	// Images and documents attached to the question (for user messages)
+	Attachments MessageAttachments `json:"attachments,omitempty" gorm:"type:jsonb,column:attachments"`
+	// Citations link the sentences of the answer to the references supporting them (for assistant messages)
+	Citations Citations `json:"citations,omitempty" gorm:"type:jsonb,column:citations"`
+	// Chat model which generated the answer (for assistant messages)
+	ChatModelID string `json:"chat_model_id,omitempty" gorm:"type:varchar(64)"`
 	// Whether message generation is complete
 	IsCompleted bool `json:"is_completed"`
 	// Message creation timestamp
@@ -130,5 +136,11 @@ func (m *Message) BeforeCreate(tx *gorm.DB) (err error) {
 	if m.MentionedItems == nil {
 		m.MentionedItems = make(MentionedItems, 0)
 	}
+	if m.Citations == nil {
+		m.Citations = make(Citations, 0)
+	}
+	if m.Attachments == nil {
+		m.Attachments = make(MessageAttachments, 0)
+	}
 	return nil
 }
@@ -49,6 +49,8 @@ type ModelParameters struct {
 	InterfaceType       string              `yaml:"interface_type"       json:"interface_type"`
 	EmbeddingParameters EmbeddingParameters `yaml:"embedding_parameters" json:"embedding_parameters"`
 	ParameterSize       string              `yaml:"parameter_size"       json:"parameter_size"` // Ollama model parameter size (e.g., "7B", "13B", "70B")
+	// SupportsVision reports that the chat model accepts images in user messages
+	SupportsVision bool `yaml:"supports_vision"      json:"supports_vision"`
 }
 
 // Model represents the AI model
@@ -0,0 +1,228 @@
+package types
+
+import (
+	"context"
+	"time"
+)
+
+// TenantRole is the role of a user within a tenant
+type TenantRole string
+
+const (
+	// TenantRoleOwner manages the tenant, its members, models and MCP services, and administers every knowledge base
+	TenantRoleOwner TenantRole = "owner"
+	// TenantRoleEditor creates knowledge bases and works on the knowledge bases granted to it
+	TenantRoleEditor TenantRole = "editor"
+	// TenantRoleViewer reads the knowledge bases granted to it
+	TenantRoleViewer TenantRole = "viewer"
+)
+
+// tenantRoleLevels orders the tenant roles
+var tenantRoleLevels = map[TenantRole]int{
+	TenantRoleViewer: 1,
+	TenantRoleEditor: 2,
+	TenantRoleOwner:  3,
+}
+
+// IsValid checks whether the role is a known tenant role
+func (r TenantRole) IsValid() bool {
+	_, ok := tenantRoleLevels[r]
+	return ok
+}
+
+// Includes checks whether the role has at least the rights of another role
+func (r TenantRole) Includes(other TenantRole) bool {
+	return tenantRoleLevels[r] >= tenantRoleLevels[other]
+}
+
+// KBPermission is the permission of a user on a knowledge base
+type KBPermission string
+
+const (
+	// KBPermissionNone grants no access
+	KBPermissionNone KBPermission = ""
+	// KBPermissionRead allows searching and reading knowledge, chunks and FAQ entries
+	KBPermissionRead KBPermission = "read"
+	// KBPermissionWrite additionally allows adding, changing and deleting knowledge, chunks, FAQ entries and tags
+	KBPermissionWrite KBPermission = "write"
+	// KBPermissionAdmin additionally allows changing the settings of the knowledge base, deleting it and granting access
+	KBPermissionAdmin KBPermission = "admin"
+)
+
+// kbPermissionLevels orders the knowledge base permissions
+var kbPermissionLevels = map[KBPermission]int{
+	KBPermissionNone:  0,
+	KBPermissionRead:  1,
+	KBPermissionWrite: 2,
+	KBPermissionAdmin: 3,
+}
+
+// IsValid checks whether the permission is a grantable knowledge base permission
+func (p KBPermission) IsValid() bool {
+	return p != KBPermissionNone && kbPermissionLevels[p] > 0
+}
+
+// Includes checks whether the permission allows everything another permission allows
+func (p KBPermission) Includes(other KBPermission) bool {
+	return kbPermissionLevels[p] >= kbPermissionLevels[other]
+}
+
+// TenantMember is the membership of a user in a tenant other than the tenant created for the user.
+// Users are always owners of their own tenant.
+type TenantMember struct {
+	// Unique identifier of the membership
+	ID string `json:"id" gorm:"type:varchar(36);primaryKey"`
+	// Tenant the user is a member of
+	TenantID uint64 `json:"tenant_id" gorm:"index"`
+	// Member user ID
+	UserID string `json:"user_id" gorm:"type:varchar(36);index"`
+	// Role of the user in the tenant
+	Role TenantRole `json:"role" gorm:"type:varchar(32);not null"`
+	// Creation time of the membership
+	CreatedAt time.Time `json:"created_at"`
+	// Last updated time of the membership
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Association relationship, not stored in the database
+	User *UserInfo `json:"user,omitempty" gorm:"-"`
+}
+
+// KnowledgeBaseGrant grants a member a permission on a knowledge base
+type KnowledgeBaseGrant struct {
+	// Unique identifier of the grant
+	ID string `json:"id" gorm:"type:varchar(36);primaryKey"`
+	// Tenant ID
+	TenantID uint64 `json:"tenant_id" gorm:"index"`
+	// Knowledge base the permission applies to
+	KnowledgeBaseID string `json:"knowledge_base_id" gorm:"type:varchar(36);index"`
+	// User the permission is granted to
+	UserID string `json:"user_id" gorm:"type:varchar(36);index"`
+	// Granted permission
+	Permission KBPermission `json:"permission" gorm:"type:varchar(32);not null"`
+	// Creation time of the grant
+	CreatedAt time.Time `json:"created_at"`
+	// Last updated time of the grant
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Association relationship, not stored in the database
+	User *UserInfo `json:"user,omitempty" gorm:"-"`
+}
+
+// Principal is the caller of a request and its permissions in the current tenant
+type Principal struct {
+	// User ID, empty when the request is authenticated with an API key
+	UserID string
+	// Role in the current tenant
+	Role TenantRole
+	// Knowledge base permissions granted to the user, by knowledge base ID
+	Grants map[string]KBPermission
+	// Scoped API key the request is authenticated with, which caps the rights of the role
+	APIKey *APIKey
+}
+
+// HasRole checks whether the principal has at least the given tenant role.
+// Scoped API keys need the admin scope to act as owner and must not be restricted to knowledge bases
+// to act as editor, since knowledge bases they create would be out of their reach.
+func (p *Principal) HasRole(role TenantRole) bool {
+	if !p.Role.Includes(role) {
+		return false
+	}
+	if p.APIKey == nil {
+		return true
+	}
+	switch role {
+	case TenantRoleOwner:
+		return p.APIKey.HasScope(APIKeyScopeAdmin)
+	case TenantRoleEditor:
+		return !p.APIKey.IsKnowledgeBaseRestricted()
+	}
+	return true
+}
+
+// KnowledgeBasePermission returns the effective permission of the principal on a knowledge base.
+// Owners administer every knowledge base, viewers never get more than read access.
+func (p *Principal) KnowledgeBasePermission(kbID string) KBPermission {
+	permission := p.rolePermission(kbID)
+	if p.APIKey != nil {
+		if limit := p.APIKey.KnowledgeBasePermission(kbID); !limit.Includes(permission) {
+			return limit
+		}
+	}
+	return permission
+}
+
+// rolePermission returns the permission on a knowledge base given by the role and the grants
+func (p *Principal) rolePermission(kbID string) KBPermission {
+	if p.Role == TenantRoleOwner {
+		return KBPermissionAdmin
+	}
+	permission := p.Grants[kbID]
+	if p.Role == TenantRoleViewer && permission.Includes(KBPermissionRead) {
+		return KBPermissionRead
+	}
+	return permission
+}
+
+// CanAccessAllKnowledgeBases checks whether the principal has the required permission on every knowledge base
+func (p *Principal) CanAccessAllKnowledgeBases(required KBPermission) bool {
+	if p.Role != TenantRoleOwner {
+		return false
+	}
+	return p.APIKey == nil ||
+		(!p.APIKey.IsKnowledgeBaseRestricted() && p.APIKey.KnowledgeBasePermission("").Includes(required))
+}
+
+// CanAccessKnowledgeBase checks whether the principal has the required permission on a knowledge base
+func (p *Principal) CanAccessKnowledgeBase(kbID string, required KBPermission) bool {
+	return p.KnowledgeBasePermission(kbID).Includes(required)
+}
+
+// ReadableKnowledgeBaseIDs returns the knowledge bases granted to the principal for reading,
+// all is true when the principal can read every knowledge base of the tenant
+func (p *Principal) ReadableKnowledgeBaseIDs() (kbIDs []string, all bool) {
+	if p.CanAccessAllKnowledgeBases(KBPermissionRead) {
+		return nil, true
+	}
+	candidates := make([]string, 0, len(p.Grants))
+	if p.Role == TenantRoleOwner && p.APIKey != nil {
+		candidates = append(candidates, p.APIKey.KnowledgeBaseIDs...)
+	} else {
+		for kbID := range p.Grants {
+			candidates = append(candidates, kbID)
+		}
+	}
+	for _, kbID := range candidates {
+		if p.CanAccessKnowledgeBase(kbID, KBPermissionRead) {
+			kbIDs = append(kbIDs, kbID)
+		}
+	}
+	return kbIDs, false
+}
+
+// PrincipalFromContext returns the principal of the request, nil for internal calls such as async tasks,
+// which are not restricted
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(PrincipalContextKey).(*Principal)
+	return principal
+}
+
+// FilterReadableKnowledgeBaseIDs keeps the knowledge bases the caller of the request can read
+func FilterReadableKnowledgeBaseIDs(ctx context.Context, kbIDs []string) []string {
+	principal := PrincipalFromContext(ctx)
+	if principal == nil {
+		return kbIDs
+	}
+	readable := make([]string, 0, len(kbIDs))
+	for _, kbID := range kbIDs {
+		if principal.CanAccessKnowledgeBase(kbID, KBPermissionRead) {
+			readable = append(readable, kbID)
+		}
+	}
+	return readable
+}
+
+// TenantMembership is a tenant the current user can switch to, with the user's role in it
+type TenantMembership struct {
+	Tenant *Tenant    `json:"tenant"`
+	Role   TenantRole `json:"role"`
+}
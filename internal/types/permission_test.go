@@ -0,0 +1,202 @@
+package types
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantRoleIncludes(t *testing.T) {
+	roles := []TenantRole{TenantRoleViewer, TenantRoleEditor, TenantRoleOwner}
+	for i, role := range roles {
+		for j, other := range roles {
+			assert.Equal(t, i >= j, role.Includes(other), "%s includes %s", role, other)
+		}
+	}
+	assert.False(t, TenantRole("guest").IsValid())
+	assert.False(t, TenantRole("guest").Includes(TenantRoleViewer))
+}
+
+func TestPrincipalHasRole(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal Principal
+		role      TenantRole
+		want      bool
+	}{
+		{name: "owner acts as viewer", principal: Principal{Role: TenantRoleOwner}, role: TenantRoleViewer, want: true},
+		{name: "owner acts as owner", principal: Principal{Role: TenantRoleOwner}, role: TenantRoleOwner, want: true},
+		{
+			name:      "editor acts as editor",
+			principal: Principal{Role: TenantRoleEditor},
+			role:      TenantRoleEditor,
+			want:      true,
+		},
+		{name: "editor is not owner", principal: Principal{Role: TenantRoleEditor}, role: TenantRoleOwner},
+		{name: "viewer is not editor", principal: Principal{Role: TenantRoleViewer}, role: TenantRoleEditor},
+		{
+			name:      "grants do not raise the role",
+			principal: Principal{Role: TenantRoleViewer, Grants: map[string]KBPermission{"kb1": KBPermissionAdmin}},
+			role:      TenantRoleEditor,
+		},
+		{
+			name:      "owner key without admin scope",
+			principal: Principal{Role: TenantRoleOwner, APIKey: &APIKey{Scopes: StringArray{"kb:write"}}},
+			role:      TenantRoleOwner,
+		},
+		{
+			name:      "owner key with admin scope",
+			principal: Principal{Role: TenantRoleOwner, APIKey: &APIKey{Scopes: StringArray{"admin"}}},
+			role:      TenantRoleOwner,
+			want:      true,
+		},
+		{
+			name:      "unrestricted key acts as editor",
+			principal: Principal{Role: TenantRoleOwner, APIKey: &APIKey{Scopes: StringArray{"kb:read"}}},
+			role:      TenantRoleEditor,
+			want:      true,
+		},
+		{
+			name: "restricted key is not editor",
+			principal: Principal{Role: TenantRoleOwner, APIKey: &APIKey{
+				Scopes: StringArray{"admin"}, KnowledgeBaseIDs: StringArray{"kb1"},
+			}},
+			role: TenantRoleEditor,
+		},
+		{
+			name: "restricted key acts as viewer",
+			principal: Principal{Role: TenantRoleOwner, APIKey: &APIKey{
+				Scopes: StringArray{"kb:read"}, KnowledgeBaseIDs: StringArray{"kb1"},
+			}},
+			role: TenantRoleViewer,
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.principal.HasRole(tt.role))
+		})
+	}
+}
+
+func TestPrincipalKnowledgeBasePermission(t *testing.T) {
+	grants := map[string]KBPermission{"kb1": KBPermissionAdmin, "kb2": KBPermissionRead}
+	tests := []struct {
+		name      string
+		principal Principal
+		kbID      string
+		want      KBPermission
+	}{
+		{
+			name:      "owner administers every knowledge base",
+			principal: Principal{Role: TenantRoleOwner},
+			kbID:      "kb3",
+			want:      KBPermissionAdmin,
+		},
+		{
+			name:      "editor grant",
+			principal: Principal{Role: TenantRoleEditor, Grants: grants},
+			kbID:      "kb1",
+			want:      KBPermissionAdmin,
+		},
+		{
+			name:      "editor read grant",
+			principal: Principal{Role: TenantRoleEditor, Grants: grants},
+			kbID:      "kb2",
+			want:      KBPermissionRead,
+		},
+		{
+			name:      "editor without grant",
+			principal: Principal{Role: TenantRoleEditor, Grants: grants},
+			kbID:      "kb3",
+			want:      KBPermissionNone,
+		},
+		{
+			name:      "viewer grant is capped at read",
+			principal: Principal{Role: TenantRoleViewer, Grants: grants},
+			kbID:      "kb1",
+			want:      KBPermissionRead,
+		},
+		{
+			name:      "viewer without grant",
+			principal: Principal{Role: TenantRoleViewer, Grants: grants},
+			kbID:      "kb3",
+			want:      KBPermissionNone,
+		},
+		{
+			name:      "key scope caps the owner",
+			principal: Principal{Role: TenantRoleOwner, APIKey: &APIKey{Scopes: StringArray{"kb:read"}}},
+			kbID:      "kb1",
+			want:      KBPermissionRead,
+		},
+		{
+			name:      "chat key reads",
+			principal: Principal{Role: TenantRoleOwner, APIKey: &APIKey{Scopes: StringArray{"chat:write"}}},
+			kbID:      "kb1",
+			want:      KBPermissionRead,
+		},
+		{
+			name: "key outside its knowledge bases",
+			principal: Principal{Role: TenantRoleOwner, APIKey: &APIKey{
+				Scopes: StringArray{"admin"}, KnowledgeBaseIDs: StringArray{"kb1"},
+			}},
+			kbID: "kb2",
+			want: KBPermissionNone,
+		},
+		{
+			name: "key does not raise the grant",
+			principal: Principal{Role: TenantRoleEditor, Grants: grants, APIKey: &APIKey{
+				Scopes: StringArray{"admin"},
+			}},
+			kbID: "kb2",
+			want: KBPermissionRead,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.principal.KnowledgeBasePermission(tt.kbID))
+		})
+	}
+}
+
+func TestPrincipalReadableKnowledgeBaseIDs(t *testing.T) {
+	kbIDs, all := (&Principal{Role: TenantRoleOwner}).ReadableKnowledgeBaseIDs()
+	assert.True(t, all)
+	assert.Nil(t, kbIDs)
+
+	kbIDs, all = (&Principal{Role: TenantRoleOwner, APIKey: &APIKey{
+		Scopes: StringArray{"kb:read"}, KnowledgeBaseIDs: StringArray{"kb1", "kb2"},
+	}}).ReadableKnowledgeBaseIDs()
+	assert.False(t, all)
+	assert.ElementsMatch(t, []string{"kb1", "kb2"}, kbIDs)
+
+	kbIDs, all = (&Principal{Role: TenantRoleViewer, Grants: map[string]KBPermission{
+		"kb1": KBPermissionWrite, "kb2": KBPermissionNone,
+	}}).ReadableKnowledgeBaseIDs()
+	assert.False(t, all)
+	assert.Equal(t, []string{"kb1"}, kbIDs)
+}
+
+func TestFilterReadableKnowledgeBaseIDs(t *testing.T) {
+	kbIDs := []string{"kb1", "kb2", "kb3"}
+
+	// Internal calls such as async tasks have no principal and are not restricted
+	assert.Equal(t, kbIDs, FilterReadableKnowledgeBaseIDs(context.Background(), kbIDs))
+
+	owner := context.WithValue(context.Background(), PrincipalContextKey, &Principal{Role: TenantRoleOwner})
+	assert.Equal(t, kbIDs, FilterReadableKnowledgeBaseIDs(owner, kbIDs))
+
+	editor := context.WithValue(context.Background(), PrincipalContextKey, &Principal{
+		Role:   TenantRoleEditor,
+		Grants: map[string]KBPermission{"kb1": KBPermissionRead, "kb3": KBPermissionWrite},
+	})
+	assert.Equal(t, []string{"kb1", "kb3"}, FilterReadableKnowledgeBaseIDs(editor, kbIDs))
+
+	restricted := context.WithValue(context.Background(), PrincipalContextKey, &Principal{
+		Role:   TenantRoleOwner,
+		APIKey: &APIKey{Scopes: StringArray{"kb:read"}, KnowledgeBaseIDs: StringArray{"kb2"}},
+	})
+	assert.Equal(t, []string{"kb2"}, FilterReadableKnowledgeBaseIDs(restricted, kbIDs))
+	assert.Empty(t, FilterReadableKnowledgeBaseIDs(editor, nil))
+}
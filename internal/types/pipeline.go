@@ -0,0 +1,78 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+)
+
+// PipelineStageParams holds the parameters of a pipeline stage, they override the session settings for the stage
+type PipelineStageParams map[string]interface{}
+
+// PipelineStage is a stage of a chat pipeline, the event is triggered with the parameters of the stage
+type PipelineStage struct {
+	// Event triggered by the stage, such as chunk_search or a custom stage
+	Event EventType `yaml:"event"  json:"event"`
+	// Parameters of the stage, accepted parameters depend on the event
+	Params PipelineStageParams `yaml:"params" json:"params,omitempty"`
+}
+
+// PipelineConfig declares the chat pipeline of a knowledge base or tenant as an ordered list of stages
+type PipelineConfig struct {
+	Stages []PipelineStage `yaml:"stages" json:"stages"`
+}
+
+// NewPipelineStages creates the stages of a pipeline triggering the events without parameters
+func NewPipelineStages(events []EventType) []PipelineStage {
+	stages := make([]PipelineStage, 0, len(events))
+	for _, event := range events {
+		stages = append(stages, PipelineStage{Event: event})
+	}
+	return stages
+}
+
+// Equal reports whether two pipeline configurations declare the same stages
+func (c *PipelineConfig) Equal(other *PipelineConfig) bool {
+	return reflect.DeepEqual(c, other)
+}
+
+// Value implements driver.Valuer
+func (c PipelineConfig) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+// Scan implements sql.Scanner
+func (c *PipelineConfig) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, c)
+}
+
+// Float returns a number parameter, or the default when it is not set
+func (p PipelineStageParams) Float(key string, def float64) float64 {
+	if value, ok := p[key].(float64); ok {
+		return value
+	}
+	return def
+}
+
+// Int returns an integer parameter, or the default when it is not set
+func (p PipelineStageParams) Int(key string, def int) int {
+	if value, ok := p[key].(float64); ok {
+		return int(value)
+	}
+	return def
+}
+
+// String returns a string parameter, or the default when it is not set
+func (p PipelineStageParams) String(key string, def string) string {
+	if value, ok := p[key].(string); ok && value != "" {
+		return value
+	}
+	return def
+}
@@ -0,0 +1,334 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// FilterOp is the operator of a retrieval filter condition
+type FilterOp string
+
+const (
+	// FilterOpEq matches a field equal to the value
+	FilterOpEq FilterOp = "eq"
+	// FilterOpIn matches a field equal to one of the values
+	FilterOpIn FilterOp = "in"
+	// FilterOpRange matches a field within the gt, gte, lt and lte bounds
+	FilterOpRange FilterOp = "range"
+	// FilterOpExists matches documents which have the field
+	FilterOpExists FilterOp = "exists"
+)
+
+const (
+	// FilterFieldTagID is the ID of the tag of a document
+	FilterFieldTagID = "tag_id"
+	// FilterFieldTag is the name of the tag of a document
+	FilterFieldTag = "tag"
+	// FilterFieldFileType is the file type of a document, such as pdf
+	FilterFieldFileType = "file_type"
+	// FilterFieldCreatedAt is the creation time of a document
+	FilterFieldCreatedAt = "created_at"
+	// FilterFieldMetadataPrefix prefixes the metadata keys of a document, such as metadata.product
+	FilterFieldMetadataPrefix = "metadata."
+
+	// maxFilterDepth bounds the nesting of and, or and not
+	maxFilterDepth = 5
+	// maxFilterConditions bounds the number of conditions of a filter
+	maxFilterConditions = 50
+	// maxFilterValues bounds the number of values of an in condition
+	maxFilterValues = 100
+)
+
+// metadataKeyPattern matches a metadata key, dots separate the keys of nested objects
+var metadataKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+
+// RetrievalFilter narrows a search to the documents whose tags, file type, creation time or metadata match.
+// A filter is either a condition on a field, or combines filters with and, or or not.
+type RetrievalFilter struct {
+	// All filters must match
+	And []*RetrievalFilter `json:"and,omitempty"`
+	// At least one filter must match
+	Or []*RetrievalFilter `json:"or,omitempty"`
+	// The filter must not match
+	Not *RetrievalFilter `json:"not,omitempty"`
+
+	// Field of the condition: tag_id, tag, file_type, created_at or metadata.<key>
+	Field string `json:"field,omitempty"`
+	// Operator of the condition
+	Op FilterOp `json:"op,omitempty"`
+	// Value of eq, or the list of values of in
+	Value interface{} `json:"value,omitempty"`
+	// Bounds of range, numbers or strings, RFC3339 times or dates for created_at
+	Gt  interface{} `json:"gt,omitempty"`
+	Gte interface{} `json:"gte,omitempty"`
+	Lt  interface{} `json:"lt,omitempty"`
+	Lte interface{} `json:"lte,omitempty"`
+}
+
+// Validate checks the filter is well-formed, so that it can be rendered as a query
+func (f *RetrievalFilter) Validate() error {
+	conditions := 0
+	return f.validate(1, &conditions)
+}
+
+func (f *RetrievalFilter) validate(depth int, conditions *int) error {
+	if f == nil {
+		return fmt.Errorf("empty filter")
+	}
+	if depth > maxFilterDepth {
+		return fmt.Errorf("filter is nested deeper than %d levels", maxFilterDepth)
+	}
+	kinds := 0
+	for _, set := range []bool{len(f.And) > 0, len(f.Or) > 0, f.Not != nil, f.Field != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("a filter needs exactly one of and, or, not or field")
+	}
+
+	for _, children := range [][]*RetrievalFilter{f.And, f.Or} {
+		for _, child := range children {
+			if err := child.validate(depth+1, conditions); err != nil {
+				return err
+			}
+		}
+	}
+	if f.Not != nil {
+		return f.Not.validate(depth+1, conditions)
+	}
+	if f.Field == "" {
+		return nil
+	}
+
+	*conditions++
+	if *conditions > maxFilterConditions {
+		return fmt.Errorf("filter has more than %d conditions", maxFilterConditions)
+	}
+	return f.validateCondition()
+}
+
+// validateCondition checks the operator and values of a condition on a field
+func (f *RetrievalFilter) validateCondition() error {
+	var ops []FilterOp
+	switch {
+	case f.Field == FilterFieldTagID:
+		ops = []FilterOp{FilterOpEq, FilterOpIn, FilterOpExists}
+	case f.Field == FilterFieldTag || f.Field == FilterFieldFileType:
+		ops = []FilterOp{FilterOpEq, FilterOpIn}
+	case f.Field == FilterFieldCreatedAt:
+		ops = []FilterOp{FilterOpRange}
+	case strings.HasPrefix(f.Field, FilterFieldMetadataPrefix):
+		if !metadataKeyPattern.MatchString(strings.TrimPrefix(f.Field, FilterFieldMetadataPrefix)) {
+			return fmt.Errorf("invalid metadata key in field %q", f.Field)
+		}
+		ops = []FilterOp{FilterOpEq, FilterOpIn, FilterOpRange, FilterOpExists}
+	default:
+		return fmt.Errorf("unknown field %q, use tag_id, tag, file_type, created_at or metadata.<key>", f.Field)
+	}
+	supported := false
+	for _, op := range ops {
+		supported = supported || op == f.Op
+	}
+	if !supported {
+		return fmt.Errorf("field %s does not support operator %q", f.Field, f.Op)
+	}
+
+	textOnly := !strings.HasPrefix(f.Field, FilterFieldMetadataPrefix)
+	switch f.Op {
+	case FilterOpEq:
+		return validateFilterScalar(f.Field, f.Value, textOnly)
+	case FilterOpIn:
+		values, ok := f.Value.([]interface{})
+		if !ok || len(values) == 0 {
+			return fmt.Errorf("field %s: in needs a non-empty list of values", f.Field)
+		}
+		if len(values) > maxFilterValues {
+			return fmt.Errorf("field %s: in accepts at most %d values", f.Field, maxFilterValues)
+		}
+		for _, value := range values {
+			if err := validateFilterScalar(f.Field, value, textOnly); err != nil {
+				return err
+			}
+		}
+	case FilterOpRange:
+		return f.validateRange()
+	}
+	return nil
+}
+
+// validateRange checks the bounds of a range, which are all numbers or all strings
+func (f *RetrievalFilter) validateRange() error {
+	bounds := f.Bounds()
+	if len(bounds) == 0 {
+		return fmt.Errorf("field %s: range needs at least one of gt, gte, lt or lte", f.Field)
+	}
+	numeric := false
+	for i, bound := range bounds {
+		switch value := bound.Value.(type) {
+		case float64:
+			if f.Field == FilterFieldCreatedAt {
+				return fmt.Errorf("field %s: range bounds must be RFC3339 times or dates", f.Field)
+			}
+			numeric = numeric || i == 0
+			if !numeric {
+				return fmt.Errorf("field %s: range bounds must all be numbers or all be strings", f.Field)
+			}
+		case string:
+			if numeric {
+				return fmt.Errorf("field %s: range bounds must all be numbers or all be strings", f.Field)
+			}
+			if f.Field == FilterFieldCreatedAt {
+				if _, err := ParseFilterTime(value); err != nil {
+					return fmt.Errorf("field %s: %v", f.Field, err)
+				}
+			}
+		default:
+			return fmt.Errorf("field %s: range bounds must be numbers or strings", f.Field)
+		}
+	}
+	return nil
+}
+
+// validateFilterScalar checks a value compared to a field is a string, or also a number or boolean for metadata
+func validateFilterScalar(field string, value interface{}, textOnly bool) error {
+	switch value.(type) {
+	case string:
+		return nil
+	case float64, bool:
+		if !textOnly {
+			return nil
+		}
+	}
+	if textOnly {
+		return fmt.Errorf("field %s: values must be strings", field)
+	}
+	return fmt.Errorf("field %s: values must be strings, numbers or booleans", field)
+}
+
+// FilterBound is a bound of a range condition
+type FilterBound struct {
+	// Op is gt, gte, lt or lte
+	Op string
+	// Value is a number or a string, an RFC3339 time or a date for created_at
+	Value interface{}
+}
+
+// boundSQLOperators maps the bounds of a range to SQL comparison operators
+var boundSQLOperators = map[string]string{"gt": ">", "gte": ">=", "lt": "<", "lte": "<="}
+
+// Bounds lists the bounds set on a range condition
+func (f *RetrievalFilter) Bounds() []FilterBound {
+	bounds := make([]FilterBound, 0, 4)
+	for _, bound := range []FilterBound{{"gt", f.Gt}, {"gte", f.Gte}, {"lt", f.Lt}, {"lte", f.Lte}} {
+		if bound.Value != nil {
+			bounds = append(bounds, bound)
+		}
+	}
+	return bounds
+}
+
+// ParseFilterTime parses an RFC3339 time or a date
+func ParseFilterTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, use RFC3339 or YYYY-MM-DD", value)
+	}
+	return t, nil
+}
+
+// KnowledgeSQL renders a validated filter as a SQL condition on the columns of the knowledges table.
+// bind adds a query argument and returns its placeholder, so that the condition fits queries
+// with either ? or numbered placeholders. A condition on a missing field is false, also under not.
+func (f *RetrievalFilter) KnowledgeSQL(bind func(arg interface{}) string) string {
+	switch {
+	case len(f.And) > 0 || len(f.Or) > 0:
+		children, separator := f.And, " AND "
+		if len(f.Or) > 0 {
+			children, separator = f.Or, " OR "
+		}
+		parts := make([]string, 0, len(children))
+		for _, child := range children {
+			parts = append(parts, child.KnowledgeSQL(bind))
+		}
+		return "(" + strings.Join(parts, separator) + ")"
+	case f.Not != nil:
+		return "(NOT " + f.Not.KnowledgeSQL(bind) + ")"
+	}
+	return "COALESCE(" + f.conditionSQL(bind) + ", FALSE)"
+}
+
+// conditionSQL renders a condition on a field
+func (f *RetrievalFilter) conditionSQL(bind func(arg interface{}) string) string {
+	values := f.Values()
+	list := func(column string, render func(value interface{}) string) string {
+		// Tag names resolved to no tag leave an empty list, which matches nothing
+		if len(values) == 0 {
+			return "FALSE"
+		}
+		placeholders := make([]string, 0, len(values))
+		for _, value := range values {
+			placeholders = append(placeholders, render(value))
+		}
+		return column + " IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+
+	switch {
+	case f.Field == FilterFieldTagID:
+		if f.Op == FilterOpExists {
+			return "(tag_id IS NOT NULL AND tag_id <> '')"
+		}
+		return list("tag_id", bind)
+	case f.Field == FilterFieldTag:
+		return "(tag_id IN (SELECT knowledge_tags.id FROM knowledge_tags " +
+			"WHERE knowledge_tags.knowledge_base_id = knowledges.knowledge_base_id AND " +
+			list("knowledge_tags.name", bind) + "))"
+	case f.Field == FilterFieldFileType:
+		return list("LOWER(file_type)", func(value interface{}) string { return bind(FileTypeValue(value)) })
+	case f.Field == FilterFieldCreatedAt:
+		parts := make([]string, 0, 4)
+		for _, bound := range f.Bounds() {
+			parts = append(parts, "created_at "+boundSQLOperators[bound.Op]+" "+bind(FilterTimeValue(bound.Value)))
+		}
+		return "(" + strings.Join(parts, " AND ") + ")"
+	}
+
+	// Metadata keys are compared as JSON, so that 1 matches 1.0 and "1" does not.
+	// Arguments are bound as text and cast, so that drivers do not have to encode arrays or JSON.
+	// The path is bound on every use, as ? placeholders cannot be referenced twice.
+	key := strings.TrimPrefix(f.Field, FilterFieldMetadataPrefix)
+	path := func() string {
+		return "(" + bind("{"+strings.ReplaceAll(key, ".", ",")+"}") + "::text)::text[]"
+	}
+	value := func() string { return "(metadata::jsonb #> " + path() + ")" }
+	switch f.Op {
+	case FilterOpExists:
+		return "(" + value() + " IS NOT NULL)"
+	case FilterOpRange:
+		bounds := f.Bounds()
+		// Numbers are only compared to numbers and strings to strings
+		jsonType, cast := "string", ""
+		if _, numeric := bounds[0].Value.(float64); numeric {
+			jsonType, cast = "number", "::numeric"
+		}
+		parts := make([]string, 0, len(bounds))
+		for _, bound := range bounds {
+			// Placeholders are bound in the order they appear in the condition
+			compared := "(CASE WHEN jsonb_typeof(" + value() + ") = '" + jsonType + "' THEN " +
+				"(metadata::jsonb #>> " + path() + ")" + cast + " END)"
+			parts = append(parts, compared+" "+boundSQLOperators[bound.Op]+" "+bind(bound.Value))
+		}
+		return "(" + strings.Join(parts, " AND ") + ")"
+	}
+	return list(value(), func(v interface{}) string {
+		encoded, _ := json.Marshal(v)
+		return "(" + bind(string(encoded)) + "::text)::jsonb"
+	})
+}
@@ -0,0 +1,280 @@
+package types
+
+import (
+	"cmp"
+	"encoding/json"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxFilterMetadataValueLength bounds the length of the metadata strings indexed for filtering,
+// longer strings only count for exists
+const maxFilterMetadataValueLength = 256
+
+// metadataKeySegmentPattern matches a key of the metadata which filters can refer to
+var metadataKeySegmentPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// FilterMetadataEntry is a string, number or boolean of the metadata of a document under its dotted key
+type FilterMetadataEntry struct {
+	Key    string   `json:"key"`
+	String *string  `json:"string,omitempty"`
+	Number *float64 `json:"number,omitempty"`
+	Bool   *bool    `json:"bool,omitempty"`
+}
+
+// IndexFilterFields are the fields of a knowledge which retrieval filters match. They are stored with
+// the indexed chunks of the knowledge, so that the engines other than Postgres filter natively.
+type IndexFilterFields struct {
+	// TagID is the ID of the tag of the knowledge, empty when it has none
+	TagID string `json:"tag_id,omitempty"`
+	// FileType is the lowercase file type of the knowledge
+	FileType string `json:"file_type,omitempty"`
+	// CreatedAt is the creation time of the knowledge
+	CreatedAt *time.Time `json:"knowledge_created_at,omitempty"`
+	// MetadataKeys lists every dotted key of the metadata, also of objects, arrays and nulls
+	MetadataKeys []string `json:"metadata_keys,omitempty"`
+	// Metadata holds the strings, numbers and booleans of the metadata
+	Metadata []FilterMetadataEntry `json:"filter_metadata,omitempty"`
+}
+
+// NewIndexFilterFields builds the filter fields of a knowledge
+func NewIndexFilterFields(knowledge *Knowledge) *IndexFilterFields {
+	fields := &IndexFilterFields{
+		TagID:    knowledge.TagID,
+		FileType: strings.ToLower(knowledge.FileType),
+	}
+	if !knowledge.CreatedAt.IsZero() {
+		createdAt := knowledge.CreatedAt.UTC()
+		fields.CreatedAt = &createdAt
+	}
+	var metadata map[string]interface{}
+	if len(knowledge.Metadata) > 0 && json.Unmarshal(knowledge.Metadata, &metadata) == nil {
+		fields.flattenMetadata("", metadata)
+	}
+	return fields
+}
+
+// flattenMetadata adds the keys and scalar values of a metadata object, in key order
+func (f *IndexFilterFields) flattenMetadata(prefix string, object map[string]interface{}) {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		// Filters cannot refer to other keys, see metadataKeyPattern
+		if metadataKeySegmentPattern.MatchString(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		path := prefix + key
+		f.MetadataKeys = append(f.MetadataKeys, path)
+		switch value := object[key].(type) {
+		case map[string]interface{}:
+			f.flattenMetadata(path+".", value)
+		case string:
+			if len(value) <= maxFilterMetadataValueLength {
+				f.Metadata = append(f.Metadata, FilterMetadataEntry{Key: path, String: &value})
+			}
+		case float64:
+			f.Metadata = append(f.Metadata, FilterMetadataEntry{Key: path, Number: &value})
+		case bool:
+			f.Metadata = append(f.Metadata, FilterMetadataEntry{Key: path, Bool: &value})
+		}
+	}
+}
+
+// metadataEntry returns the scalar value of the metadata under a dotted key, nil when there is none
+func (f *IndexFilterFields) metadataEntry(key string) *FilterMetadataEntry {
+	for i := range f.Metadata {
+		if f.Metadata[i].Key == key {
+			return &f.Metadata[i]
+		}
+	}
+	return nil
+}
+
+// ResolveTagNames returns a copy of the filter where the conditions on tag names are replaced by
+// conditions on the IDs of the tags, as only the tag IDs of documents are indexed. tagIDs returns
+// the IDs of the tags with the given names, a name without a tag matches no document.
+func (f *RetrievalFilter) ResolveTagNames(tagIDs func(names []string) ([]string, error)) (*RetrievalFilter, error) {
+	resolved := *f
+	for _, children := range []*[]*RetrievalFilter{&resolved.And, &resolved.Or} {
+		if len(*children) == 0 {
+			continue
+		}
+		copied := make([]*RetrievalFilter, 0, len(*children))
+		for _, child := range *children {
+			c, err := child.ResolveTagNames(tagIDs)
+			if err != nil {
+				return nil, err
+			}
+			copied = append(copied, c)
+		}
+		*children = copied
+	}
+	if f.Not != nil {
+		not, err := f.Not.ResolveTagNames(tagIDs)
+		if err != nil {
+			return nil, err
+		}
+		resolved.Not = not
+	}
+	if f.Field != FilterFieldTag {
+		return &resolved, nil
+	}
+
+	names := make([]string, 0)
+	for _, value := range f.Values() {
+		names = append(names, value.(string))
+	}
+	ids, err := tagIDs(names)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id)
+	}
+	resolved.Field, resolved.Op, resolved.Value = FilterFieldTagID, FilterOpIn, values
+	return &resolved, nil
+}
+
+// Values returns the value of an eq condition, or the values of an in condition
+func (f *RetrievalFilter) Values() []interface{} {
+	if f.Op == FilterOpIn {
+		values, _ := f.Value.([]interface{})
+		return values
+	}
+	return []interface{}{f.Value}
+}
+
+// FileTypeValue normalizes a file type compared to the file_type field, such as .PDF to pdf
+func FileTypeValue(value interface{}) string {
+	fileType, _ := value.(string)
+	return strings.TrimPrefix(strings.ToLower(fileType), ".")
+}
+
+// FilterTimeValue parses a bound of a range on created_at, which Validate has checked
+func FilterTimeValue(value interface{}) time.Time {
+	s, _ := value.(string)
+	t, _ := ParseFilterTime(s)
+	return t
+}
+
+// Match reports whether the filter fields of a document match a validated filter, the same way
+// the retrieval engines filtering on the indexed fields do. Tag names must be resolved first.
+func (f *RetrievalFilter) Match(fields *IndexFilterFields) bool {
+	switch {
+	case len(f.And) > 0:
+		for _, child := range f.And {
+			if !child.Match(fields) {
+				return false
+			}
+		}
+		return true
+	case len(f.Or) > 0:
+		for _, child := range f.Or {
+			if child.Match(fields) {
+				return true
+			}
+		}
+		return false
+	case f.Not != nil:
+		return !f.Not.Match(fields)
+	}
+	if fields == nil {
+		return false
+	}
+
+	switch {
+	case f.Field == FilterFieldTagID:
+		if f.Op == FilterOpExists {
+			return fields.TagID != ""
+		}
+		return fields.TagID != "" && slices.Contains(f.Values(), interface{}(fields.TagID))
+	case f.Field == FilterFieldFileType:
+		for _, value := range f.Values() {
+			if fields.FileType != "" && fields.FileType == FileTypeValue(value) {
+				return true
+			}
+		}
+		return false
+	case f.Field == FilterFieldCreatedAt:
+		if fields.CreatedAt == nil {
+			return false
+		}
+		for _, bound := range f.Bounds() {
+			if !inBound(fields.CreatedAt.Compare(FilterTimeValue(bound.Value)), bound.Op) {
+				return false
+			}
+		}
+		return true
+	case !strings.HasPrefix(f.Field, FilterFieldMetadataPrefix):
+		// Tag names are not indexed
+		return false
+	}
+
+	key := strings.TrimPrefix(f.Field, FilterFieldMetadataPrefix)
+	if f.Op == FilterOpExists {
+		return slices.Contains(fields.MetadataKeys, key)
+	}
+	entry := fields.metadataEntry(key)
+	if entry == nil {
+		return false
+	}
+	if f.Op == FilterOpRange {
+		for _, bound := range f.Bounds() {
+			var compared int
+			switch value := bound.Value.(type) {
+			case float64:
+				if entry.Number == nil {
+					return false
+				}
+				compared = cmp.Compare(*entry.Number, value)
+			case string:
+				if entry.String == nil {
+					return false
+				}
+				compared = strings.Compare(*entry.String, value)
+			}
+			if !inBound(compared, bound.Op) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, value := range f.Values() {
+		switch value := value.(type) {
+		case string:
+			if entry.String != nil && *entry.String == value {
+				return true
+			}
+		case float64:
+			if entry.Number != nil && *entry.Number == value {
+				return true
+			}
+		case bool:
+			if entry.Bool != nil && *entry.Bool == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inBound reports whether a comparison of a value to a bound satisfies the bound
+func inBound(compared int, op string) bool {
+	switch op {
+	case "gt":
+		return compared > 0
+	case "gte":
+		return compared >= 0
+	case "lt":
+		return compared < 0
+	default:
+		return compared <= 0
+	}
+}
@@ -0,0 +1,154 @@
+package types
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewIndexFilterFields(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 8, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	fields := NewIndexFilterFields(&Knowledge{
+		TagID:     "t1",
+		FileType:  "PDF",
+		CreatedAt: createdAt,
+		Metadata: []byte(`{"product": "WeKnora", "version": 2, "public": true, "source": {"system": "crm"},
+			"labels": ["a"], "empty": null, "long": "` + strings.Repeat("x", maxFilterMetadataValueLength+1) + `",
+			"bad key": "ignored"}`),
+	})
+
+	assert.Equal(t, "t1", fields.TagID)
+	assert.Equal(t, "pdf", fields.FileType)
+	require.NotNil(t, fields.CreatedAt)
+	assert.True(t, fields.CreatedAt.Equal(createdAt))
+	assert.Equal(t, time.UTC, fields.CreatedAt.Location())
+	assert.Equal(t, []string{"empty", "labels", "long", "product", "public", "source", "source.system", "version"},
+		fields.MetadataKeys)
+
+	keys := make([]string, 0, len(fields.Metadata))
+	for _, entry := range fields.Metadata {
+		keys = append(keys, entry.Key)
+	}
+	assert.Equal(t, []string{"product", "public", "source.system", "version"}, keys)
+	assert.Equal(t, 2.0, *fields.metadataEntry("version").Number)
+	assert.True(t, *fields.metadataEntry("public").Bool)
+	assert.Nil(t, fields.metadataEntry("long"))
+}
+
+func TestRetrievalFilterMatch(t *testing.T) {
+	fields := NewIndexFilterFields(&Knowledge{
+		TagID:     "t1",
+		FileType:  "pdf",
+		CreatedAt: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		Metadata:  []byte(`{"product": "WeKnora", "version": 2, "public": true, "release": "v2", "source": {}}`),
+	})
+	untagged := NewIndexFilterFields(&Knowledge{FileType: "md"})
+
+	tests := []struct {
+		name     string
+		filter   string
+		fields   *IndexFilterFields
+		noFields bool
+		match    bool
+	}{
+		{name: "tag_id eq", filter: `{"field": "tag_id", "op": "eq", "value": "t1"}`, match: true},
+		{name: "tag_id in", filter: `{"field": "tag_id", "op": "in", "value": ["t2", "t3"]}`},
+		{name: "tag_id exists", filter: `{"field": "tag_id", "op": "exists"}`, match: true},
+		{name: "untagged", filter: `{"field": "tag_id", "op": "exists"}`, fields: untagged},
+		{name: "file_type normalized", filter: `{"field": "file_type", "op": "eq", "value": ".PDF"}`, match: true},
+		{
+			name:   "created_at in range",
+			filter: `{"field": "created_at", "op": "range", "gte": "2024-03-01"}`,
+			match:  true,
+		},
+		{name: "created_at out of range", filter: `{"field": "created_at", "op": "range", "lt": "2024-03-01"}`},
+		{
+			name:   "created_at missing",
+			filter: `{"field": "created_at", "op": "range", "lt": "2099-01-01"}`,
+			fields: untagged,
+		},
+		{name: "metadata object exists", filter: `{"field": "metadata.source", "op": "exists"}`, match: true},
+		{name: "metadata missing", filter: `{"field": "metadata.owner", "op": "exists"}`},
+		{name: "metadata eq", filter: `{"field": "metadata.product", "op": "eq", "value": "WeKnora"}`, match: true},
+		{name: "number matches float", filter: `{"field": "metadata.version", "op": "eq", "value": 2.0}`, match: true},
+		{name: "number does not match string", filter: `{"field": "metadata.version", "op": "eq", "value": "2"}`},
+		{
+			name:   "metadata in bool",
+			filter: `{"field": "metadata.public", "op": "in", "value": ["x", true]}`,
+			match:  true,
+		},
+		{name: "numeric range", filter: `{"field": "metadata.version", "op": "range", "gt": 1, "lte": 2}`, match: true},
+		{name: "numeric range on string", filter: `{"field": "metadata.release", "op": "range", "gt": 1}`},
+		{name: "string range", filter: `{"field": "metadata.release", "op": "range", "gte": "v1"}`, match: true},
+		{name: "string range on number", filter: `{"field": "metadata.version", "op": "range", "gte": "1"}`},
+		{
+			name:   "not of missing field",
+			filter: `{"not": {"field": "metadata.owner", "op": "eq", "value": "a"}}`,
+			match:  true,
+		},
+		{
+			name: "and of or",
+			filter: `{"and": [{"field": "file_type", "op": "eq", "value": "pdf"},
+				{"or": [{"field": "tag_id", "op": "eq", "value": "t2"},
+					{"field": "metadata.public", "op": "eq", "value": true}]}]}`,
+			match: true,
+		},
+		{name: "tag names are not indexed", filter: `{"field": "tag", "op": "eq", "value": "faq"}`},
+		{name: "document without fields", filter: `{"field": "file_type", "op": "eq", "value": "pdf"}`, noFields: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := parseFilter(t, tt.filter)
+			require.NoError(t, filter.Validate())
+			target := tt.fields
+			if target == nil && !tt.noFields {
+				target = fields
+			}
+			assert.Equal(t, tt.match, filter.Match(target))
+		})
+	}
+}
+
+func TestRetrievalFilterResolveTagNames(t *testing.T) {
+	filter := parseFilter(t, `{"or": [
+		{"field": "tag", "op": "in", "value": ["faq", "missing"]},
+		{"not": {"field": "tag", "op": "eq", "value": "missing"}},
+		{"field": "file_type", "op": "eq", "value": "pdf"}
+	]}`)
+	tagIDs := map[string]string{"faq": "t1"}
+
+	resolved, err := filter.ResolveTagNames(func(names []string) ([]string, error) {
+		ids := make([]string, 0, len(names))
+		for _, name := range names {
+			if id, ok := tagIDs[name]; ok {
+				ids = append(ids, id)
+			}
+		}
+		return ids, nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, resolved.Or, 3)
+	assert.Equal(t, FilterFieldTagID, resolved.Or[0].Field)
+	assert.Equal(t, FilterOpIn, resolved.Or[0].Op)
+	assert.Equal(t, []interface{}{"t1"}, resolved.Or[0].Value)
+	assert.Equal(t, FilterFieldTagID, resolved.Or[1].Not.Field)
+	assert.Equal(t, []interface{}{}, resolved.Or[1].Not.Value)
+	assert.Equal(t, filter.Or[2], resolved.Or[2])
+	// The filter of the request is left as is
+	assert.Equal(t, FilterFieldTag, filter.Or[0].Field)
+	assert.Equal(t, FilterFieldTag, filter.Or[1].Not.Field)
+
+	assert.True(t, resolved.Match(&IndexFilterFields{TagID: "t2"}))
+	assert.False(t, resolved.Or[0].Match(&IndexFilterFields{TagID: "t2"}))
+	assert.True(t, resolved.Or[0].Match(&IndexFilterFields{TagID: "t1"}))
+
+	lookupErr := errors.New("lookup failed")
+	_, err = filter.ResolveTagNames(func([]string) ([]string, error) { return nil, lookupErr })
+	assert.ErrorIs(t, err, lookupErr)
+}
@@ -0,0 +1,261 @@
+package types
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseFilter decodes a filter the way the handlers do, so that numbers are float64
+func parseFilter(t *testing.T, s string) *RetrievalFilter {
+	t.Helper()
+	var filter RetrievalFilter
+	require.NoError(t, json.Unmarshal([]byte(s), &filter))
+	return &filter
+}
+
+func TestRetrievalFilterKnowledgeSQL(t *testing.T) {
+	const metadataPath = "(metadata::jsonb #> (?::text)::text[])"
+	tests := []struct {
+		name   string
+		filter string
+		sql    string
+		args   []interface{}
+	}{
+		{
+			name:   "tag_id eq",
+			filter: `{"field": "tag_id", "op": "eq", "value": "t1"}`,
+			sql:    "COALESCE(tag_id IN (?), FALSE)",
+			args:   []interface{}{"t1"},
+		},
+		{
+			name:   "tag_id in",
+			filter: `{"field": "tag_id", "op": "in", "value": ["t1", "t2"]}`,
+			sql:    "COALESCE(tag_id IN (?, ?), FALSE)",
+			args:   []interface{}{"t1", "t2"},
+		},
+		{
+			name:   "tag_id exists",
+			filter: `{"field": "tag_id", "op": "exists"}`,
+			sql:    "COALESCE((tag_id IS NOT NULL AND tag_id <> ''), FALSE)",
+		},
+		{
+			name:   "tag in",
+			filter: `{"field": "tag", "op": "in", "value": ["faq", "guide"]}`,
+			sql: "COALESCE((tag_id IN (SELECT knowledge_tags.id FROM knowledge_tags " +
+				"WHERE knowledge_tags.knowledge_base_id = knowledges.knowledge_base_id AND " +
+				"knowledge_tags.name IN (?, ?))), FALSE)",
+			args: []interface{}{"faq", "guide"},
+		},
+		{
+			name:   "file_type eq is normalized",
+			filter: `{"field": "file_type", "op": "eq", "value": ".PDF"}`,
+			sql:    "COALESCE(LOWER(file_type) IN (?), FALSE)",
+			args:   []interface{}{"pdf"},
+		},
+		{
+			name:   "created_at range",
+			filter: `{"field": "created_at", "op": "range", "gte": "2024-01-01", "lt": "2024-02-01T08:00:00+08:00"}`,
+			sql:    "COALESCE((created_at >= ? AND created_at < ?), FALSE)",
+			args: []interface{}{
+				time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:   "metadata exists",
+			filter: `{"field": "metadata.source.system", "op": "exists"}`,
+			sql:    "COALESCE((" + metadataPath + " IS NOT NULL), FALSE)",
+			args:   []interface{}{"{source,system}"},
+		},
+		{
+			name:   "metadata eq number",
+			filter: `{"field": "metadata.version", "op": "eq", "value": 1}`,
+			sql:    "COALESCE(" + metadataPath + " IN ((?::text)::jsonb), FALSE)",
+			args:   []interface{}{"{version}", "1"},
+		},
+		{
+			name:   "metadata in mixed values",
+			filter: `{"field": "metadata.product", "op": "in", "value": ["WeKnora", true]}`,
+			sql:    "COALESCE(" + metadataPath + " IN ((?::text)::jsonb, (?::text)::jsonb), FALSE)",
+			args:   []interface{}{"{product}", `"WeKnora"`, "true"},
+		},
+		{
+			name:   "metadata numeric range",
+			filter: `{"field": "metadata.score", "op": "range", "gt": 1, "lte": 5}`,
+			sql: "COALESCE(((CASE WHEN jsonb_typeof(" + metadataPath + ") = 'number' THEN " +
+				"(metadata::jsonb #>> (?::text)::text[])::numeric END) > ? AND " +
+				"(CASE WHEN jsonb_typeof(" + metadataPath + ") = 'number' THEN " +
+				"(metadata::jsonb #>> (?::text)::text[])::numeric END) <= ?), FALSE)",
+			args: []interface{}{"{score}", "{score}", 1.0, "{score}", "{score}", 5.0},
+		},
+		{
+			name:   "metadata string range",
+			filter: `{"field": "metadata.release", "op": "range", "gte": "v2"}`,
+			sql: "COALESCE(((CASE WHEN jsonb_typeof(" + metadataPath + ") = 'string' THEN " +
+				"(metadata::jsonb #>> (?::text)::text[]) END) >= ?), FALSE)",
+			args: []interface{}{"{release}", "{release}", "v2"},
+		},
+		{
+			name: "and, or and not",
+			filter: `{"and": [
+				{"or": [{"field": "file_type", "op": "eq", "value": "md"},
+					{"field": "tag_id", "op": "eq", "value": "t1"}]},
+				{"not": {"field": "tag_id", "op": "exists"}}
+			]}`,
+			sql: "((COALESCE(LOWER(file_type) IN (?), FALSE) OR COALESCE(tag_id IN (?), FALSE)) AND " +
+				"(NOT COALESCE((tag_id IS NOT NULL AND tag_id <> ''), FALSE)))",
+			args: []interface{}{"md", "t1"},
+		},
+		{
+			name:   "empty list matches nothing",
+			filter: `{"field": "tag_id", "op": "in", "value": []}`,
+			sql:    "COALESCE(FALSE, FALSE)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args []interface{}
+			sql := parseFilter(t, tt.filter).KnowledgeSQL(func(arg interface{}) string {
+				args = append(args, arg)
+				return "?"
+			})
+			assert.Equal(t, tt.sql, sql)
+			assert.Equal(t, strings.Count(sql, "?"), len(args))
+			if len(tt.args) == 0 {
+				assert.Empty(t, args)
+				return
+			}
+			require.Len(t, args, len(tt.args))
+			for i, want := range tt.args {
+				if wantTime, ok := want.(time.Time); ok {
+					gotTime, ok := args[i].(time.Time)
+					require.True(t, ok, "arg %d is not a time", i)
+					assert.True(t, wantTime.Equal(gotTime), "arg %d: want %v, got %v", i, wantTime, gotTime)
+					continue
+				}
+				assert.Equal(t, want, args[i], "arg %d", i)
+			}
+		})
+	}
+}
+
+func TestRetrievalFilterValidate(t *testing.T) {
+	nested := `{"field": "tag_id", "op": "exists"}`
+	for i := 0; i < maxFilterDepth-1; i++ {
+		nested = `{"not": ` + nested + `}`
+	}
+	conditions := make([]string, 0, maxFilterConditions+1)
+	for i := 0; i <= maxFilterConditions; i++ {
+		conditions = append(conditions, `{"field": "tag_id", "op": "exists"}`)
+	}
+	values := make([]string, 0, maxFilterValues+1)
+	for i := 0; i <= maxFilterValues; i++ {
+		values = append(values, `"v"`)
+	}
+
+	tests := []struct {
+		name   string
+		filter string
+		err    string
+	}{
+		{name: "condition", filter: `{"field": "metadata.product", "op": "eq", "value": "WeKnora"}`},
+		{name: "nested key", filter: `{"field": "metadata.source.system_id", "op": "exists"}`},
+		{name: "numeric range", filter: `{"field": "metadata.score", "op": "range", "gte": 1, "lt": 2.5}`},
+		{name: "string range", filter: `{"field": "metadata.release", "op": "range", "gt": "v1", "lte": "v3"}`},
+		{name: "date range", filter: `{"field": "created_at", "op": "range", "gte": "2024-01-01"}`},
+		{name: "depth limit", filter: nested},
+		{
+			name:   "conditions limit",
+			filter: `{"or": [` + strings.Join(conditions[:maxFilterConditions], ", ") + `]}`,
+		},
+		{name: "empty filter", filter: `{}`, err: "exactly one of"},
+		{
+			name:   "field and combinator",
+			filter: `{"field": "tag_id", "op": "exists", "not": {"field": "tag_id", "op": "exists"}}`,
+			err:    "exactly one of",
+		},
+		{name: "unknown field", filter: `{"field": "title", "op": "eq", "value": "a"}`, err: "unknown field"},
+		{name: "empty metadata key", filter: `{"field": "metadata.", "op": "exists"}`, err: "invalid metadata key"},
+		{
+			name:   "metadata key with spaces",
+			filter: `{"field": "metadata.source system", "op": "exists"}`,
+			err:    "invalid metadata key",
+		},
+		{
+			name:   "metadata key with empty segment",
+			filter: `{"field": "metadata.source..system", "op": "exists"}`,
+			err:    "invalid metadata key",
+		},
+		{
+			name:   "metadata key with quote",
+			filter: `{"field": "metadata.a'b", "op": "exists"}`,
+			err:    "invalid metadata key",
+		},
+		{name: "unsupported op", filter: `{"field": "tag", "op": "exists"}`, err: "does not support operator"},
+		{name: "created_at eq", filter: `{"field": "created_at", "op": "eq", "value": "x"}`, err: "does not support"},
+		{name: "tag_id number", filter: `{"field": "tag_id", "op": "eq", "value": 1}`, err: "must be strings"},
+		{
+			name:   "metadata object",
+			filter: `{"field": "metadata.a", "op": "eq", "value": {}}`,
+			err:    "numbers or booleans",
+		},
+		{name: "empty in", filter: `{"field": "tag_id", "op": "in", "value": []}`, err: "non-empty list"},
+		{name: "in without list", filter: `{"field": "tag_id", "op": "in", "value": "t1"}`, err: "non-empty list"},
+		{
+			name:   "too many values",
+			filter: `{"field": "tag_id", "op": "in", "value": [` + strings.Join(values, ", ") + `]}`,
+			err:    "at most",
+		},
+		{name: "range without bounds", filter: `{"field": "metadata.a", "op": "range"}`, err: "at least one"},
+		{
+			name:   "number then string",
+			filter: `{"field": "metadata.a", "op": "range", "gt": 1, "lt": "2"}`,
+			err:    "all be numbers or all be strings",
+		},
+		{
+			name:   "string then number",
+			filter: `{"field": "metadata.a", "op": "range", "gt": "1", "lt": 2}`,
+			err:    "all be numbers or all be strings",
+		},
+		{
+			name:   "boolean bound",
+			filter: `{"field": "metadata.a", "op": "range", "gt": true}`,
+			err:    "numbers or strings",
+		},
+		{
+			name:   "numeric created_at",
+			filter: `{"field": "created_at", "op": "range", "gt": 1700000000}`,
+			err:    "RFC3339 times or dates",
+		},
+		{
+			name:   "invalid created_at",
+			filter: `{"field": "created_at", "op": "range", "gt": "yesterday"}`,
+			err:    "invalid time",
+		},
+		{name: "too deep", filter: `{"not": ` + nested + `}`, err: "nested deeper than"},
+		{
+			name:   "too many conditions",
+			filter: `{"or": [` + strings.Join(conditions, ", ") + `]}`,
+			err:    "more than",
+		},
+		{name: "invalid child", filter: `{"and": [{"field": "tag_id", "op": "exists"}, {}]}`, err: "exactly one of"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := parseFilter(t, tt.filter).Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
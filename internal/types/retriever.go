@@ -10,6 +10,7 @@ const (
 	InfinityRetrieverEngineType      RetrieverEngineType = "infinity"
 	ElasticFaissRetrieverEngineType  RetrieverEngineType = "elasticfaiss"
 	QdrantRetrieverEngineType        RetrieverEngineType = "qdrant"
+	LocalRetrieverEngineType         RetrieverEngineType = "local"
 )
 
 // RetrieverType represents the type of retriever
@@ -36,6 +37,8 @@ type RetrieveParams struct {
 	ExcludeKnowledgeIDs []string
 	// Excluded chunk IDs
 	ExcludeChunkIDs []string
+	// Filter on the documents of the chunks, such as their tags or metadata
+	Filter *RetrievalFilter
 	// Number of results to return
 	TopK int
 	// Similarity threshold
@@ -98,6 +98,10 @@ type SearchParams struct {
 	DisableKeywordsMatch bool     `json:"disable_keywords_match"`
 	DisableVectorMatch   bool     `json:"disable_vector_match"`
 	KnowledgeIDs         []string `json:"knowledge_ids"`
+	// Fusion overrides the knowledge base retrieval config for this search when set
+	Fusion *RetrievalConfig `json:"fusion,omitempty"`
+	// Filter narrows the search to the documents whose tags, file type, creation time or metadata match
+	Filter *RetrievalFilter `json:"filter,omitempty"`
 }
 
 // Value implements the driver.Valuer interface, used to convert SearchResult to database value
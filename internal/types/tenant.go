@@ -27,6 +27,10 @@ var retrieverEngineMapping = map[string][]RetrieverEngineParams{
 		{RetrieverType: KeywordsRetrieverType, RetrieverEngineType: QdrantRetrieverEngineType},
 		{RetrieverType: VectorRetrieverType, RetrieverEngineType: QdrantRetrieverEngineType},
 	},
+	"local": {
+		{RetrieverType: KeywordsRetrieverType, RetrieverEngineType: LocalRetrieverEngineType},
+		{RetrieverType: VectorRetrieverType, RetrieverEngineType: LocalRetrieverEngineType},
+	},
 }
 
 // GetDefaultRetrieverEngines returns the default retriever engines based on RETRIEVE_DRIVER env
@@ -77,6 +81,8 @@ type Tenant struct {
 	WebSearchConfig *WebSearchConfig `yaml:"web_search_config"   json:"web_search_config"   gorm:"type:jsonb"`
 	// Global Conversation configuration for this tenant (default for normal mode sessions)
 	ConversationConfig *ConversationConfig `yaml:"conversation_config" json:"conversation_config" gorm:"type:jsonb"`
+	// Model usage limits of the tenant, nil means unlimited
+	UsageLimits *UsageLimits `yaml:"usage_limits" json:"usage_limits" gorm:"type:jsonb"`
 	// Creation time
 	CreatedAt time.Time `yaml:"created_at"          json:"created_at"`
 	// Last updated time
@@ -158,6 +164,9 @@ type ConversationConfig struct {
 	// Rewrite prompts
 	RewritePromptSystem string `json:"rewrite_prompt_system"`
 	RewritePromptUser   string `json:"rewrite_prompt_user"`
+
+	// Pipeline is the chat pipeline of knowledge QA for knowledge bases declaring none, nil uses the default one
+	Pipeline *PipelineConfig `json:"pipeline,omitempty"`
 }
 
 // Value implements the driver.Valuer interface, used to convert ConversationConfig to database value
@@ -0,0 +1,112 @@
+package types
+
+import (
+	"path"
+	"time"
+)
+
+// ToolPolicy decides whether the agent may run a tool
+type ToolPolicy string
+
+const (
+	// ToolPolicyAuto runs the tool calls right away
+	ToolPolicyAuto ToolPolicy = "auto"
+	// ToolPolicyRequireApproval pauses the agent until a person approves or rejects each tool call
+	ToolPolicyRequireApproval ToolPolicy = "require_approval"
+	// ToolPolicyDeny hides the tool from the agent and rejects its calls
+	ToolPolicyDeny ToolPolicy = "deny"
+)
+
+// IsValid reports whether the policy is one of the known policies
+func (p ToolPolicy) IsValid() bool {
+	switch p {
+	case ToolPolicyAuto, ToolPolicyRequireApproval, ToolPolicyDeny:
+		return true
+	}
+	return false
+}
+
+// restrictiveness orders the policies, a tool matched by several patterns gets the most restrictive one
+func (p ToolPolicy) restrictiveness() int {
+	switch p {
+	case ToolPolicyDeny:
+		return 2
+	case ToolPolicyRequireApproval:
+		return 1
+	}
+	return 0
+}
+
+// ToolPolicy returns the policy of a tool. The policy set for the exact tool name wins,
+// otherwise the most restrictive policy of the matching glob patterns such as "mcp.*" applies,
+// tools without a matching policy run automatically.
+func (c *AgentConfig) ToolPolicy(toolName string) ToolPolicy {
+	if c == nil || len(c.ToolPolicies) == 0 {
+		return ToolPolicyAuto
+	}
+	if policy, ok := c.ToolPolicies[toolName]; ok {
+		return policy
+	}
+	policy := ToolPolicyAuto
+	for pattern, p := range c.ToolPolicies {
+		if matched, err := path.Match(pattern, toolName); err == nil && matched &&
+			p.restrictiveness() > policy.restrictiveness() {
+			policy = p
+		}
+	}
+	return policy
+}
+
+// ToolApprovalStatus is the state of a tool approval
+type ToolApprovalStatus string
+
+const (
+	// ToolApprovalPending is a tool call waiting for a decision
+	ToolApprovalPending ToolApprovalStatus = "pending"
+	// ToolApprovalApproved is a tool call a person approved, the agent runs it
+	ToolApprovalApproved ToolApprovalStatus = "approved"
+	// ToolApprovalRejected is a tool call a person rejected, the agent skips it
+	ToolApprovalRejected ToolApprovalStatus = "rejected"
+	// ToolApprovalExpired is a tool call nobody decided on in time, or whose agent stopped, the agent skips it
+	ToolApprovalExpired ToolApprovalStatus = "expired"
+)
+
+// ToolApproval is a tool call of an agent which waits for a person to approve it
+type ToolApproval struct {
+	// Unique identifier of the approval
+	ID string `json:"id" gorm:"type:varchar(36);primaryKey"`
+	// Tenant ID
+	TenantID uint64 `json:"tenant_id" gorm:"index"`
+	// Session of the agent
+	SessionID string `json:"session_id" gorm:"type:varchar(36);index"`
+	// Assistant message the agent is answering
+	MessageID string `json:"message_id" gorm:"type:varchar(36)"`
+	// Tool call ID of the LLM
+	ToolCallID string `json:"tool_call_id" gorm:"type:varchar(255)"`
+	// Tool name
+	ToolName string `json:"tool_name" gorm:"type:varchar(255)"`
+	// Tool arguments
+	Arguments JSON `json:"arguments" gorm:"type:jsonb"`
+	// State of the approval
+	Status ToolApprovalStatus `json:"status" gorm:"type:varchar(32)"`
+	// Reason given with the decision
+	Reason string `json:"reason" gorm:"type:text"`
+	// User who decided, empty for API keys and expired approvals
+	DecidedBy string `json:"decided_by" gorm:"type:varchar(36)"`
+	// Time of the decision
+	DecidedAt *time.Time `json:"decided_at"`
+	// Time after which the tool call is skipped without a decision
+	ExpiresAt time.Time `json:"expires_at"`
+	// Creation time of the approval
+	CreatedAt time.Time `json:"created_at"`
+	// Last updated time of the approval
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ToolApprovalDecisionRequest is the decision of a person on a pending tool call
+type ToolApprovalDecisionRequest struct {
+	// Whether the agent may run the tool call
+	Approved *bool `json:"approved" binding:"required"`
+	// Reason of the decision, passed to the agent when the call is rejected
+	Reason string `json:"reason"`
+}
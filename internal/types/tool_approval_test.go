@@ -0,0 +1,57 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgentConfigToolPolicy(t *testing.T) {
+	config := &AgentConfig{ToolPolicies: map[string]ToolPolicy{
+		"mcp.*":               ToolPolicyRequireApproval,
+		"mcp.tickets.*":       ToolPolicyDeny,
+		"mcp.tickets.search":  ToolPolicyAuto,
+		"mcp.mail.send":       ToolPolicyRequireApproval,
+		"web_*":               ToolPolicyRequireApproval,
+		"web_fetch":           ToolPolicyDeny,
+		"knowledge_[":         ToolPolicyDeny,
+		"database_query":      ToolPolicyAuto,
+		"mcp.calendar.events": ToolPolicyAuto,
+	}}
+	tests := []struct {
+		tool     string
+		expected ToolPolicy
+	}{
+		// The exact tool name wins over every pattern, even a more restrictive one
+		{"mcp.tickets.search", ToolPolicyAuto},
+		{"mcp.calendar.events", ToolPolicyAuto},
+		{"web_fetch", ToolPolicyDeny},
+		// Otherwise the most restrictive matching pattern applies
+		{"mcp.tickets.create", ToolPolicyDeny},
+		{"mcp.wiki.read", ToolPolicyRequireApproval},
+		{"web_search", ToolPolicyRequireApproval},
+		// Tools without a matching policy run automatically, invalid patterns never match
+		{"knowledge_search", ToolPolicyAuto},
+		{"database_query", ToolPolicyAuto},
+		{"grep_chunks", ToolPolicyAuto},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			assert.Equal(t, tt.expected, config.ToolPolicy(tt.tool))
+		})
+	}
+}
+
+func TestAgentConfigToolPolicyWithoutPolicies(t *testing.T) {
+	var config *AgentConfig
+	assert.Equal(t, ToolPolicyAuto, config.ToolPolicy("web_fetch"))
+	assert.Equal(t, ToolPolicyAuto, (&AgentConfig{}).ToolPolicy("web_fetch"))
+}
+
+func TestToolPolicyIsValid(t *testing.T) {
+	assert.True(t, ToolPolicyAuto.IsValid())
+	assert.True(t, ToolPolicyRequireApproval.IsValid())
+	assert.True(t, ToolPolicyDeny.IsValid())
+	assert.False(t, ToolPolicy("ask").IsValid())
+	assert.False(t, ToolPolicy("").IsValid())
+}
@@ -0,0 +1,101 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+const (
+	// DefaultURLCrawlMaxDepth is the default number of links followed from the start page
+	DefaultURLCrawlMaxDepth = 2
+	// DefaultURLCrawlMaxPages is the default number of pages imported by a crawl
+	DefaultURLCrawlMaxPages = 100
+	// MaxURLCrawlDepth bounds the link depth of a crawl
+	MaxURLCrawlDepth = 10
+	// MaxURLCrawlPages bounds the number of pages imported by a crawl
+	MaxURLCrawlPages = 1000
+)
+
+// URLCrawlConfig represents the crawl scope of URL knowledge.
+// Pages found from the start URL are imported as separate URL knowledge of the same knowledge base.
+type URLCrawlConfig struct {
+	// Only follow links on the host of the start URL (default: true)
+	SameDomain bool `json:"same_domain"`
+	// Only follow links whose path starts with the prefix, empty follows every path
+	PathPrefix string `json:"path_prefix,omitempty"`
+	// Number of links followed from the start page (default: 2)
+	MaxDepth int `json:"max_depth"`
+	// Number of pages imported including the start page (default: 100)
+	MaxPages int `json:"max_pages"`
+	// Skip pages disallowed by robots.txt (default: true)
+	RespectRobotsTxt bool `json:"respect_robots_txt"`
+	// Also import the pages listed in sitemap.xml (default: true)
+	UseSitemap bool `json:"use_sitemap"`
+}
+
+// UnmarshalJSON fills the switches that are on by default before decoding
+func (c *URLCrawlConfig) UnmarshalJSON(data []byte) error {
+	type alias URLCrawlConfig
+	res := alias{SameDomain: true, RespectRobotsTxt: true, UseSitemap: true}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return err
+	}
+	*c = URLCrawlConfig(res)
+	return nil
+}
+
+// WithDefaults returns a copy of the config with unset limits filled and oversized limits capped
+func (c URLCrawlConfig) WithDefaults() URLCrawlConfig {
+	if c.MaxDepth <= 0 {
+		c.MaxDepth = DefaultURLCrawlMaxDepth
+	}
+	if c.MaxDepth > MaxURLCrawlDepth {
+		c.MaxDepth = MaxURLCrawlDepth
+	}
+	if c.MaxPages <= 0 {
+		c.MaxPages = DefaultURLCrawlMaxPages
+	}
+	if c.MaxPages > MaxURLCrawlPages {
+		c.MaxPages = MaxURLCrawlPages
+	}
+	return c
+}
+
+// Value implements driver.Valuer
+func (c URLCrawlConfig) Value() (driver.Value, error) {
+	return json.Marshal(c)
+}
+
+// Scan implements sql.Scanner
+func (c *URLCrawlConfig) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, c)
+}
+
+// URLKnowledgeOptions are the optional settings of URL knowledge
+type URLKnowledgeOptions struct {
+	// Crawl scope, nil imports the single page
+	Crawl *URLCrawlConfig `json:"crawl,omitempty"`
+	// Standard five-field cron expression of the refresh schedule, empty disables refreshing
+	RefreshSchedule string `json:"refresh_schedule,omitempty"`
+}
+
+// URLCrawlPayload represents the URL crawl task payload
+type URLCrawlPayload struct {
+	RequestId       string `json:"request_id"`
+	TenantID        uint64 `json:"tenant_id"`
+	KnowledgeID     string `json:"knowledge_id"`
+	KnowledgeBaseID string `json:"knowledge_base_id"`
+}
+
+// URLRefreshPayload represents the URL refresh task payload
+type URLRefreshPayload struct {
+	TenantID    uint64 `json:"tenant_id"`
+	KnowledgeID string `json:"knowledge_id"`
+}
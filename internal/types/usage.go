@@ -0,0 +1,114 @@
+package types
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+)
+
+// UsageCallType is the kind of model call metered in the usage ledger
+type UsageCallType string
+
+const (
+	// UsageCallTypeChat is a chat completion call
+	UsageCallTypeChat UsageCallType = "chat"
+	// UsageCallTypeEmbedding is an embedding call
+	UsageCallTypeEmbedding UsageCallType = "embedding"
+	// UsageCallTypeRerank is a rerank call
+	UsageCallTypeRerank UsageCallType = "rerank"
+)
+
+// UsageLimits limits the model calls of a tenant or an API key, zero values mean unlimited
+type UsageLimits struct {
+	// Maximum number of model calls per minute
+	RequestsPerMinute int `json:"requests_per_minute"`
+	// Maximum number of tokens per calendar month
+	MonthlyTokenBudget int64 `json:"monthly_token_budget"`
+}
+
+// Value implements the driver.Valuer interface, used to convert UsageLimits to database value
+func (l UsageLimits) Value() (driver.Value, error) {
+	return json.Marshal(l)
+}
+
+// Scan implements the sql.Scanner interface, used to convert database value to UsageLimits
+func (l *UsageLimits) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(b, l)
+}
+
+// UsageRecord is an entry of the usage ledger, one per metered model call
+type UsageRecord struct {
+	// Unique identifier of the record
+	ID uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	// Tenant charged for the call
+	TenantID uint64 `json:"tenant_id" gorm:"index"`
+	// API key the call was made with, empty for users and the tenant API key
+	APIKeyID string `json:"api_key_id" gorm:"type:varchar(36);index"`
+	// Model ID
+	ModelID string `json:"model_id" gorm:"type:varchar(64)"`
+	// Model name
+	ModelName string `json:"model_name" gorm:"type:varchar(255)"`
+	// Kind of call
+	CallType UsageCallType `json:"call_type" gorm:"type:varchar(32)"`
+	// Prompt tokens
+	PromptTokens int64 `json:"prompt_tokens"`
+	// Completion tokens
+	CompletionTokens int64 `json:"completion_tokens"`
+	// Total tokens
+	TotalTokens int64 `json:"total_tokens"`
+	// Number of requests, always 1 for a single call
+	Requests int64 `json:"requests"`
+	// Whether the token counts are estimated because the model did not report them
+	Estimated bool `json:"estimated"`
+	// Time of the call
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// UsageReportItem is the usage of a model on a day
+type UsageReportItem struct {
+	// Day in YYYY-MM-DD format
+	Day string `json:"day"`
+	// Model ID
+	ModelID string `json:"model_id"`
+	// Model name
+	ModelName string `json:"model_name"`
+	// Kind of call
+	CallType UsageCallType `json:"call_type"`
+	// Prompt tokens
+	PromptTokens int64 `json:"prompt_tokens"`
+	// Completion tokens
+	CompletionTokens int64 `json:"completion_tokens"`
+	// Total tokens
+	TotalTokens int64 `json:"total_tokens"`
+	// Number of requests
+	Requests int64 `json:"requests"`
+}
+
+// UsageTotals sums the usage of a period
+type UsageTotals struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+	Requests         int64 `json:"requests"`
+}
+
+// UsageReport is the usage of a tenant over a period, by day and model
+type UsageReport struct {
+	// First day of the period, inclusive
+	From string `json:"from"`
+	// Last day of the period, inclusive
+	To string `json:"to"`
+	// API key the report is restricted to, empty for the whole tenant
+	APIKeyID string `json:"api_key_id,omitempty"`
+	// Totals of the period
+	Totals UsageTotals `json:"totals"`
+	// Usage by day and model
+	Items []*UsageReportItem `json:"items"`
+}
@@ -0,0 +1,129 @@
+package types
+
+import "time"
+
+// WebhookSecretPrefix is the prefix of generated webhook signing secrets
+const WebhookSecretPrefix = "whsec_"
+
+// Webhook is an HTTP endpoint of a tenant which receives lifecycle events as signed POST requests
+type Webhook struct {
+	// Unique identifier of the webhook
+	ID string `json:"id" gorm:"type:varchar(36);primaryKey"`
+	// Tenant ID
+	TenantID uint64 `json:"tenant_id" gorm:"index"`
+	// Name describing the receiver
+	Name string `json:"name" gorm:"type:varchar(255)"`
+	// URL the events are posted to
+	URL string `json:"url" gorm:"type:varchar(1024)"`
+	// Secret used to sign the requests with HMAC-SHA256, only returned on creation and rotation
+	Secret string `json:"-" gorm:"type:varchar(128)"`
+	// Events delivered to the webhook
+	Events StringArray `json:"events" gorm:"type:json"`
+	// Whether events are delivered to the webhook
+	Enabled bool `json:"enabled"`
+	// User who created the webhook
+	CreatedBy string `json:"created_by" gorm:"type:varchar(36)"`
+	// Creation time of the webhook
+	CreatedAt time.Time `json:"created_at"`
+	// Last updated time of the webhook
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CreateWebhookRequest is the request to create a webhook
+type CreateWebhookRequest struct {
+	// Name describing the receiver
+	Name string `json:"name" binding:"required"`
+	// URL the events are posted to, http or https
+	URL string `json:"url" binding:"required"`
+	// Events delivered to the webhook
+	Events []string `json:"events" binding:"required,min=1"`
+	// Signing secret, generated when empty
+	Secret string `json:"secret"`
+}
+
+// UpdateWebhookRequest is the request to update a webhook, nil fields are left unchanged
+type UpdateWebhookRequest struct {
+	// Name describing the receiver
+	Name *string `json:"name"`
+	// URL the events are posted to, http or https
+	URL *string `json:"url"`
+	// Events delivered to the webhook
+	Events []string `json:"events"`
+	// Whether events are delivered to the webhook
+	Enabled *bool `json:"enabled"`
+	// Whether to generate a new signing secret
+	RotateSecret bool `json:"rotate_secret"`
+}
+
+// WebhookWithSecret is a webhook together with its signing secret, returned on creation and rotation
+type WebhookWithSecret struct {
+	*Webhook
+	// Signing secret
+	Secret string `json:"secret"`
+}
+
+// WebhookDeliveryStatus is the state of a delivery
+type WebhookDeliveryStatus string
+
+const (
+	// WebhookDeliveryPending is a delivery which has not succeeded yet and will be attempted again
+	WebhookDeliveryPending WebhookDeliveryStatus = "pending"
+	// WebhookDeliverySucceeded is a delivery the receiver answered with a 2xx status
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	// WebhookDeliveryFailed is a delivery which failed on every attempt
+	WebhookDeliveryFailed WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is the log entry of the delivery of an event to a webhook
+type WebhookDelivery struct {
+	// Unique identifier of the delivery, sent in the X-WeKnora-Delivery header
+	ID string `json:"id" gorm:"type:varchar(36);primaryKey"`
+	// Tenant ID
+	TenantID uint64 `json:"tenant_id" gorm:"index"`
+	// Webhook the event is delivered to
+	WebhookID string `json:"webhook_id" gorm:"type:varchar(36);index"`
+	// Event type, e.g. "knowledge.failed"
+	Event string `json:"event" gorm:"type:varchar(64)"`
+	// Request body
+	Payload JSON `json:"payload" gorm:"type:jsonb"`
+	// State of the delivery
+	Status WebhookDeliveryStatus `json:"status" gorm:"type:varchar(32)"`
+	// Number of attempts so far
+	Attempts int `json:"attempts"`
+	// HTTP status of the last response, zero when no response was received
+	ResponseStatus int `json:"response_status"`
+	// Beginning of the body of the last response
+	ResponseBody string `json:"response_body" gorm:"type:text"`
+	// Error of the last attempt
+	Error string `json:"error" gorm:"type:text"`
+	// Duration of the last attempt in milliseconds
+	DurationMs int64 `json:"duration_ms"`
+	// Time of the successful attempt
+	DeliveredAt *time.Time `json:"delivered_at"`
+	// Creation time of the delivery
+	CreatedAt time.Time `json:"created_at"`
+	// Time of the last attempt
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhookPayload is the body posted to webhooks
+type WebhookPayload struct {
+	// Event ID, the same for every webhook the event is delivered to
+	ID string `json:"id"`
+	// Event type
+	Event string `json:"event"`
+	// Tenant ID
+	TenantID uint64 `json:"tenant_id"`
+	// Request ID of the operation which produced the event
+	RequestID string `json:"request_id,omitempty"`
+	// Time of the event
+	CreatedAt time.Time `json:"created_at"`
+	// Event data
+	Data interface{} `json:"data"`
+}
+
+// WebhookDeliveryPayload represents the webhook delivery task payload
+type WebhookDeliveryPayload struct {
+	TenantID   uint64 `json:"tenant_id"`
+	DeliveryID string `json:"delivery_id"`
+}
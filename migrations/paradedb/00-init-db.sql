@@ -200,7 +200,7 @@ CREATE TABLE IF NOT EXISTS embeddings (
     embedding halfvec
 );
 
-CREATE UNIQUE INDEX IF NOT EXISTS embeddings_unique_source ON embeddings(source_id, source_type);
+CREATE UNIQUE INDEX IF NOT EXISTS embeddings_unique_source ON embeddings(source_id, source_type, knowledge_base_id);
 CREATE INDEX IF NOT EXISTS embeddings_search_idx ON embeddings
 USING bm25 (id, knowledge_base_id, content, knowledge_id, chunk_id)
 WITH (
@@ -0,0 +1,13 @@
+-- Restore the embeddings unique index on source only, dropping leftover shadow rows first
+
+DO $$
+BEGIN
+    IF to_regclass('embeddings') IS NULL THEN
+        RETURN;
+    END IF;
+
+    DELETE FROM embeddings WHERE knowledge_base_id LIKE 'reembed-%';
+
+    DROP INDEX IF EXISTS embeddings_unique_source;
+    CREATE UNIQUE INDEX IF NOT EXISTS embeddings_unique_source ON embeddings(source_id, source_type);
+END $$;
@@ -0,0 +1,19 @@
+-- Migration: embeddings_shadow_index
+-- Description: Scope the embeddings unique index to the knowledge base, so a knowledge base can be
+-- re-embedded into a shadow index that shares source IDs with the live one
+
+DO $$
+BEGIN
+    IF to_regclass('embeddings') IS NULL THEN
+        RAISE NOTICE '[Migration 000006] Skipping, embeddings table does not exist';
+        RETURN;
+    END IF;
+
+    RAISE NOTICE '[Migration 000006] Recreating embeddings unique index with knowledge_base_id...';
+
+    DROP INDEX IF EXISTS embeddings_unique_source;
+    CREATE UNIQUE INDEX IF NOT EXISTS embeddings_unique_source
+        ON embeddings(source_id, source_type, knowledge_base_id);
+
+    RAISE NOTICE '[Migration 000006] Embeddings unique index recreated successfully';
+END $$;
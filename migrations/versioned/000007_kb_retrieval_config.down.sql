@@ -0,0 +1,3 @@
+-- Remove retrieval_config column from knowledge_bases table
+
+ALTER TABLE knowledge_bases DROP COLUMN IF EXISTS retrieval_config;
@@ -0,0 +1,6 @@
+-- Add retrieval_config column to knowledge_bases table
+-- This column stores how keyword and vector retrieval results are fused (rrf, weighted, max)
+
+ALTER TABLE knowledge_bases ADD COLUMN IF NOT EXISTS retrieval_config JSONB NULL;
+
+COMMENT ON COLUMN knowledge_bases.retrieval_config IS 'Hybrid retrieval fusion configuration (fusion_mode, rrf_k, alpha)';
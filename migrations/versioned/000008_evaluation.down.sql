@@ -0,0 +1,5 @@
+-- Remove evaluation tables
+
+DROP TABLE IF EXISTS evaluation_datasets;
+DROP TABLE IF EXISTS evaluation_question_results;
+DROP TABLE IF EXISTS evaluation_tasks;
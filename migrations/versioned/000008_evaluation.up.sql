@@ -0,0 +1,72 @@
+-- Migration: 000008_evaluation
+-- Description: Persist evaluation tasks, per-question results and uploaded evaluation datasets
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000008] Creating evaluation tables...'; END $$;
+
+CREATE TABLE IF NOT EXISTS evaluation_tasks (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INTEGER NOT NULL,
+    dataset_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    knowledge_base_id VARCHAR(36) NOT NULL DEFAULT '',
+    eval_knowledge_base_id VARCHAR(36) NOT NULL DEFAULT '',
+    eval_knowledge_id VARCHAR(36) NOT NULL DEFAULT '',
+    start_time TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    end_time TIMESTAMP WITH TIME ZONE,
+    status INTEGER NOT NULL DEFAULT 0,
+    err_msg TEXT NOT NULL DEFAULT '',
+    total INTEGER NOT NULL DEFAULT 0,
+    finished INTEGER NOT NULL DEFAULT 0,
+    params JSONB,
+    metric JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_evaluation_tasks_tenant_created ON evaluation_tasks(tenant_id, created_at DESC);
+
+COMMENT ON TABLE evaluation_tasks IS 'Evaluation runs of a dataset against a retrieval and generation configuration';
+COMMENT ON COLUMN evaluation_tasks.status IS 'Task status: 0-pending, 1-running, 2-success, 3-failed';
+COMMENT ON COLUMN evaluation_tasks.params IS 'Pipeline parameters used for every question';
+COMMENT ON COLUMN evaluation_tasks.metric IS 'Averaged metrics of the finished questions';
+
+CREATE TABLE IF NOT EXISTS evaluation_question_results (
+    id BIGSERIAL PRIMARY KEY,
+    task_id VARCHAR(36) NOT NULL,
+    tenant_id INTEGER NOT NULL,
+    question_index INTEGER NOT NULL,
+    qid INTEGER NOT NULL DEFAULT 0,
+    question TEXT NOT NULL DEFAULT '',
+    expected_answer TEXT NOT NULL DEFAULT '',
+    relevant_passage_ids JSONB,
+    search_results JSONB,
+    rerank_results JSONB,
+    generated_answer TEXT NOT NULL DEFAULT '',
+    err_msg TEXT NOT NULL DEFAULT '',
+    metric JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_eval_results_task_question ON evaluation_question_results(task_id, question_index);
+
+COMMENT ON TABLE evaluation_question_results IS 'Retrieval hits, rerank output, generated answer and metrics of each evaluated question';
+
+CREATE TABLE IF NOT EXISTS evaluation_datasets (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INTEGER NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    format VARCHAR(16) NOT NULL DEFAULT '',
+    qa_count INTEGER NOT NULL DEFAULT 0,
+    passage_count INTEGER NOT NULL DEFAULT 0,
+    qa_pairs JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_evaluation_datasets_tenant_id ON evaluation_datasets(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_evaluation_datasets_deleted_at ON evaluation_datasets(deleted_at);
+
+COMMENT ON TABLE evaluation_datasets IS 'User uploaded evaluation datasets (JSONL/CSV of question, answer and relevant passages)';
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000008] Evaluation tables created'; END $$;
@@ -0,0 +1,7 @@
+-- Remove evaluation comparisons
+
+DROP TABLE IF EXISTS evaluation_comparisons;
+DROP INDEX IF EXISTS idx_evaluation_tasks_comparison_id;
+ALTER TABLE evaluation_tasks DROP COLUMN IF EXISTS variant;
+ALTER TABLE evaluation_tasks DROP COLUMN IF EXISTS variant_name;
+ALTER TABLE evaluation_tasks DROP COLUMN IF EXISTS comparison_id;
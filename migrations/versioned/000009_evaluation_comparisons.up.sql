@@ -0,0 +1,29 @@
+-- Migration: 000009_evaluation_comparisons
+-- Description: Compare evaluation runs of several pipeline variants on the same dataset
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000009] Creating evaluation comparison tables...'; END $$;
+
+ALTER TABLE evaluation_tasks ADD COLUMN IF NOT EXISTS comparison_id VARCHAR(36) NOT NULL DEFAULT '';
+ALTER TABLE evaluation_tasks ADD COLUMN IF NOT EXISTS variant_name VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE evaluation_tasks ADD COLUMN IF NOT EXISTS variant JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_evaluation_tasks_comparison_id ON evaluation_tasks(comparison_id);
+
+COMMENT ON COLUMN evaluation_tasks.variant IS 'Pipeline variant overrides (models, retrieval parameters, chunking, fusion)';
+
+CREATE TABLE IF NOT EXISTS evaluation_comparisons (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INTEGER NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    dataset_id VARCHAR(64) NOT NULL DEFAULT 'default',
+    knowledge_base_id VARCHAR(36) NOT NULL DEFAULT '',
+    variants JSONB,
+    task_ids JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_evaluation_comparisons_tenant_created ON evaluation_comparisons(tenant_id, created_at DESC);
+
+COMMENT ON TABLE evaluation_comparisons IS 'Side-by-side evaluations of pipeline variants, the first variant is the baseline';
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000009] Evaluation comparison tables created'; END $$;
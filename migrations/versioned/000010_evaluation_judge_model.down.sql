@@ -0,0 +1,3 @@
+-- Remove judge model from evaluation tasks
+
+ALTER TABLE evaluation_tasks DROP COLUMN IF EXISTS judge_model_id;
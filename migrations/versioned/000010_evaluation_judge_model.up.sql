@@ -0,0 +1,10 @@
+-- Migration: 000010_evaluation_judge_model
+-- Description: Grade evaluation answers with a judge model (LLM-as-judge metrics)
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000010] Adding judge model to evaluation tasks...'; END $$;
+
+ALTER TABLE evaluation_tasks ADD COLUMN IF NOT EXISTS judge_model_id VARCHAR(64) NOT NULL DEFAULT '';
+
+COMMENT ON COLUMN evaluation_tasks.judge_model_id IS 'Chat model grading faithfulness, answer relevance, context precision and context recall';
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000010] Judge model added to evaluation tasks'; END $$;
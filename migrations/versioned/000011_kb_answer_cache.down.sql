@@ -0,0 +1,3 @@
+-- Remove answer_cache_config column from knowledge_bases table
+
+ALTER TABLE knowledge_bases DROP COLUMN IF EXISTS answer_cache_config;
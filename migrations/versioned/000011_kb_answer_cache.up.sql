@@ -0,0 +1,6 @@
+-- Add answer_cache_config column to knowledge_bases table
+-- This column stores the opt-in semantic answer cache settings of knowledge QA
+
+ALTER TABLE knowledge_bases ADD COLUMN IF NOT EXISTS answer_cache_config JSONB NULL;
+
+COMMENT ON COLUMN knowledge_bases.answer_cache_config IS 'Semantic answer cache configuration (enabled, similarity_threshold, ttl_seconds)';
@@ -0,0 +1,9 @@
+-- Remove crawl and refresh columns from knowledges table
+
+DROP INDEX IF EXISTS idx_knowledges_crawl_root_id;
+
+ALTER TABLE knowledges DROP COLUMN IF EXISTS last_refreshed_at;
+ALTER TABLE knowledges DROP COLUMN IF EXISTS content_hash;
+ALTER TABLE knowledges DROP COLUMN IF EXISTS refresh_schedule;
+ALTER TABLE knowledges DROP COLUMN IF EXISTS crawl_root_id;
+ALTER TABLE knowledges DROP COLUMN IF EXISTS crawl_config;
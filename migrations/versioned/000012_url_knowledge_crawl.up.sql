@@ -0,0 +1,16 @@
+-- Add crawl and refresh columns to knowledges table
+-- URL knowledge may crawl the pages linked from it and be re-fetched on a cron schedule
+
+ALTER TABLE knowledges ADD COLUMN IF NOT EXISTS crawl_config JSONB NULL;
+ALTER TABLE knowledges ADD COLUMN IF NOT EXISTS crawl_root_id VARCHAR(36) NULL;
+ALTER TABLE knowledges ADD COLUMN IF NOT EXISTS refresh_schedule VARCHAR(64) NULL;
+ALTER TABLE knowledges ADD COLUMN IF NOT EXISTS content_hash VARCHAR(64) NULL;
+ALTER TABLE knowledges ADD COLUMN IF NOT EXISTS last_refreshed_at TIMESTAMP WITH TIME ZONE NULL;
+
+CREATE INDEX IF NOT EXISTS idx_knowledges_crawl_root_id ON knowledges(crawl_root_id);
+
+COMMENT ON COLUMN knowledges.crawl_config IS 'Crawl scope of URL knowledge (same_domain, path_prefix, max_depth, max_pages, respect_robots_txt, use_sitemap)';
+COMMENT ON COLUMN knowledges.crawl_root_id IS 'ID of the URL knowledge whose crawl imported this page';
+COMMENT ON COLUMN knowledges.refresh_schedule IS 'Cron expression of the URL refresh schedule';
+COMMENT ON COLUMN knowledges.content_hash IS 'Hash of the page content fetched by the last refresh';
+COMMENT ON COLUMN knowledges.last_refreshed_at IS 'Last time the URL was refreshed';
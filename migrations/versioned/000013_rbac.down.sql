@@ -0,0 +1,4 @@
+-- Remove tenant members and knowledge base grants
+
+DROP TABLE IF EXISTS knowledge_base_grants;
+DROP TABLE IF EXISTS tenant_members;
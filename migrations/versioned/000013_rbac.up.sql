@@ -0,0 +1,38 @@
+-- Migration: 000013_rbac
+-- Description: Tenant members with roles and per-knowledge-base permissions
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000013] Creating tenant member and knowledge base grant tables...'; END $$;
+
+CREATE TABLE IF NOT EXISTS tenant_members (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INTEGER NOT NULL,
+    user_id VARCHAR(36) NOT NULL,
+    role VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tenant_members_tenant_user ON tenant_members(tenant_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_tenant_members_user_id ON tenant_members(user_id);
+
+COMMENT ON TABLE tenant_members IS 'Users invited into a tenant they do not own';
+COMMENT ON COLUMN tenant_members.role IS 'Role of the member in the tenant (owner, editor, viewer)';
+
+CREATE TABLE IF NOT EXISTS knowledge_base_grants (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INTEGER NOT NULL,
+    knowledge_base_id VARCHAR(36) NOT NULL,
+    user_id VARCHAR(36) NOT NULL,
+    permission VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_knowledge_base_grants_kb_user
+    ON knowledge_base_grants(tenant_id, knowledge_base_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_knowledge_base_grants_tenant_user ON knowledge_base_grants(tenant_id, user_id);
+
+COMMENT ON TABLE knowledge_base_grants IS 'Permissions of tenant members on knowledge bases';
+COMMENT ON COLUMN knowledge_base_grants.permission IS 'Permission on the knowledge base (read, write, admin)';
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000013] Tenant member and knowledge base grant tables created'; END $$;
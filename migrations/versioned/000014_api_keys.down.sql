@@ -0,0 +1,3 @@
+-- Remove scoped API keys
+
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,30 @@
+-- Migration: 000014_api_keys
+-- Description: Scoped, revocable API keys of tenants
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000014] Creating api_keys table...'; END $$;
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INTEGER NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    key_prefix VARCHAR(32) NOT NULL DEFAULT '',
+    key_hash VARCHAR(64) NOT NULL,
+    scopes JSONB,
+    knowledge_base_ids JSONB,
+    expires_at TIMESTAMP WITH TIME ZONE NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE NULL,
+    created_by VARCHAR(36) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key_hash ON api_keys(key_hash);
+CREATE INDEX IF NOT EXISTS idx_api_keys_tenant_id ON api_keys(tenant_id);
+
+COMMENT ON TABLE api_keys IS 'Scoped API keys of tenants, the plain key is only shown on creation';
+COMMENT ON COLUMN api_keys.key_hash IS 'SHA-256 hash of the plain key';
+COMMENT ON COLUMN api_keys.scopes IS 'Granted scopes (chat:write, kb:read, kb:write, admin)';
+COMMENT ON COLUMN api_keys.knowledge_base_ids IS 'Knowledge bases the key is restricted to, empty for all';
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000014] api_keys table created'; END $$;
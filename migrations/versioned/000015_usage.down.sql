@@ -0,0 +1,7 @@
+-- Remove the model usage ledger and usage limits
+
+ALTER TABLE api_keys DROP COLUMN IF EXISTS monthly_token_budget;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS requests_per_minute;
+ALTER TABLE tenants DROP COLUMN IF EXISTS usage_limits;
+
+DROP TABLE IF EXISTS usage_records;
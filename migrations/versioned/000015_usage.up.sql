@@ -0,0 +1,36 @@
+-- Migration: 000015_usage
+-- Description: Model usage ledger and usage limits of tenants and API keys
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000015] Creating usage_records table...'; END $$;
+
+CREATE TABLE IF NOT EXISTS usage_records (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id INTEGER NOT NULL,
+    api_key_id VARCHAR(36) NOT NULL DEFAULT '',
+    model_id VARCHAR(64) NOT NULL DEFAULT '',
+    model_name VARCHAR(255) NOT NULL DEFAULT '',
+    call_type VARCHAR(32) NOT NULL,
+    prompt_tokens BIGINT NOT NULL DEFAULT 0,
+    completion_tokens BIGINT NOT NULL DEFAULT 0,
+    total_tokens BIGINT NOT NULL DEFAULT 0,
+    requests BIGINT NOT NULL DEFAULT 1,
+    estimated BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_records_tenant_created ON usage_records(tenant_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_usage_records_api_key_created ON usage_records(api_key_id, created_at);
+
+COMMENT ON TABLE usage_records IS 'Ledger of metered chat, embedding and rerank model calls';
+COMMENT ON COLUMN usage_records.api_key_id IS 'Scoped API key the call was made with, empty otherwise';
+COMMENT ON COLUMN usage_records.estimated IS 'Token counts are estimated because the model did not report them';
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000015] Adding usage limits to tenants and api_keys...'; END $$;
+
+ALTER TABLE tenants ADD COLUMN IF NOT EXISTS usage_limits JSONB;
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS requests_per_minute INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS monthly_token_budget BIGINT NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN tenants.usage_limits IS 'Model call rate limit and monthly token budget of the tenant';
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000015] Usage tables created'; END $$;
@@ -0,0 +1,45 @@
+-- Migration: 000016_audit_logs
+-- Description: Append-only audit log of administrative and data-changing operations
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000016] Creating audit_logs table...'; END $$;
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id INTEGER NOT NULL,
+    actor_type VARCHAR(32) NOT NULL,
+    actor_id VARCHAR(36) NOT NULL DEFAULT '',
+    actor_name VARCHAR(255) NOT NULL DEFAULT '',
+    action VARCHAR(255) NOT NULL,
+    resource_type VARCHAR(255) NOT NULL DEFAULT '',
+    resource_id VARCHAR(255) NOT NULL DEFAULT '',
+    path VARCHAR(1024) NOT NULL DEFAULT '',
+    status_code INTEGER NOT NULL DEFAULT 0,
+    changes JSONB,
+    request_id VARCHAR(64) NOT NULL DEFAULT '',
+    client_ip VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_tenant_created ON audit_logs(tenant_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_tenant_resource ON audit_logs(tenant_id, resource_type, resource_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_tenant_actor ON audit_logs(tenant_id, actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_request_id ON audit_logs(request_id);
+
+COMMENT ON TABLE audit_logs IS 'Append-only audit log, records cannot be updated or deleted';
+COMMENT ON COLUMN audit_logs.action IS 'Method and route of the request, e.g. DELETE /knowledge-bases/:id';
+COMMENT ON COLUMN audit_logs.changes IS 'Changed fields of the target resource with their values before and after, secrets redacted';
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000016] Making audit_logs append-only...'; END $$;
+
+CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_logs is append-only, % is not allowed', TG_OP;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_audit_logs_append_only ON audit_logs;
+CREATE TRIGGER trg_audit_logs_append_only
+    BEFORE UPDATE OR DELETE ON audit_logs
+    FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only();
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000016] audit_logs table created'; END $$;
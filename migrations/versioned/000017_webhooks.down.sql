@@ -0,0 +1,4 @@
+-- Remove outbound webhooks
+
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
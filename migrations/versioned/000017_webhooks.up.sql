@@ -0,0 +1,50 @@
+-- Migration: 000017_webhooks
+-- Description: Outbound webhooks of tenants and their delivery log
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000017] Creating webhooks table...'; END $$;
+
+CREATE TABLE IF NOT EXISTS webhooks (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INTEGER NOT NULL,
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    url VARCHAR(1024) NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    events JSONB,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by VARCHAR(36) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_tenant_id ON webhooks(tenant_id);
+
+COMMENT ON TABLE webhooks IS 'HTTP endpoints of tenants receiving lifecycle events as signed POST requests';
+COMMENT ON COLUMN webhooks.secret IS 'Secret signing the requests with HMAC-SHA256';
+COMMENT ON COLUMN webhooks.events IS 'Subscribed events, e.g. knowledge.failed';
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000017] Creating webhook_deliveries table...'; END $$;
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INTEGER NOT NULL,
+    webhook_id VARCHAR(36) NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event VARCHAR(64) NOT NULL,
+    payload JSONB,
+    status VARCHAR(32) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_status INTEGER NOT NULL DEFAULT 0,
+    response_body TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    delivered_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_created ON webhook_deliveries(webhook_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_tenant_id ON webhook_deliveries(tenant_id);
+
+COMMENT ON TABLE webhook_deliveries IS 'Delivery log of webhook events, retried with exponential backoff';
+COMMENT ON COLUMN webhook_deliveries.status IS 'pending, succeeded or failed';
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000017] webhooks tables created'; END $$;
@@ -0,0 +1,28 @@
+-- Migration: 000018_tool_approvals
+-- Description: Agent tool calls waiting for the approval of a person
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000018] Creating tool_approvals table...'; END $$;
+
+CREATE TABLE IF NOT EXISTS tool_approvals (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INTEGER NOT NULL,
+    session_id VARCHAR(36) NOT NULL,
+    message_id VARCHAR(36) NOT NULL DEFAULT '',
+    tool_call_id VARCHAR(255) NOT NULL DEFAULT '',
+    tool_name VARCHAR(255) NOT NULL,
+    arguments JSONB,
+    status VARCHAR(32) NOT NULL DEFAULT 'pending',
+    reason TEXT NOT NULL DEFAULT '',
+    decided_by VARCHAR(36) NOT NULL DEFAULT '',
+    decided_at TIMESTAMP WITH TIME ZONE NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_tool_approvals_tenant_session ON tool_approvals(tenant_id, session_id, created_at);
+
+COMMENT ON TABLE tool_approvals IS 'Agent tool calls paused by the require_approval tool policy until a person decides';
+COMMENT ON COLUMN tool_approvals.status IS 'pending, approved, rejected or expired';
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000018] tool_approvals table created'; END $$;
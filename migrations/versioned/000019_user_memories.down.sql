@@ -0,0 +1,3 @@
+-- Remove long-term user memories
+
+DROP TABLE IF EXISTS user_memories;
@@ -0,0 +1,25 @@
+-- Migration: 000019_user_memories
+-- Description: Long-term memories of the agent about the users, kept across sessions
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000019] Creating user_memories table...'; END $$;
+
+CREATE TABLE IF NOT EXISTS user_memories (
+    id VARCHAR(36) PRIMARY KEY,
+    tenant_id INTEGER NOT NULL,
+    user_id VARCHAR(36) NOT NULL,
+    content TEXT NOT NULL,
+    category VARCHAR(32) NOT NULL DEFAULT 'fact',
+    source_session_id VARCHAR(36) NOT NULL DEFAULT '',
+    embedding_model_id VARCHAR(64) NOT NULL DEFAULT '',
+    embedding JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_memories_tenant_user ON user_memories(tenant_id, user_id, updated_at);
+
+COMMENT ON TABLE user_memories IS 'Facts and preferences of users extracted from their conversations with the agent';
+COMMENT ON COLUMN user_memories.category IS 'fact or preference';
+COMMENT ON COLUMN user_memories.embedding IS 'Embedding of the content by embedding_model_id, compared to the query on recall';
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000019] user_memories table created'; END $$;
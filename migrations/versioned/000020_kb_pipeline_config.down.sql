@@ -0,0 +1,3 @@
+-- Remove pipeline_config column from knowledge_bases table
+
+ALTER TABLE knowledge_bases DROP COLUMN IF EXISTS pipeline_config;
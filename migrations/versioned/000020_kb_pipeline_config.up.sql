@@ -0,0 +1,6 @@
+-- Add pipeline_config column to knowledge_bases table
+-- This column stores the declarative chat pipeline of knowledge QA over the knowledge base
+
+ALTER TABLE knowledge_bases ADD COLUMN IF NOT EXISTS pipeline_config JSONB NULL;
+
+COMMENT ON COLUMN knowledge_bases.pipeline_config IS 'Chat pipeline definition (ordered stages with per-stage parameters), NULL uses the default pipeline';
@@ -0,0 +1,3 @@
+-- Remove citations column from messages table
+
+ALTER TABLE messages DROP COLUMN IF EXISTS citations;
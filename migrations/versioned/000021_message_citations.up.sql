@@ -0,0 +1,7 @@
+-- Add citations column to messages table
+-- This column links the sentences of assistant answers to the reference chunks they cite
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS citations JSONB DEFAULT '[]';
+
+-- Add comment for the column
+COMMENT ON COLUMN messages.citations IS 'Verified citations of the answer (source, sentence offsets and text, cited chunk)';
@@ -0,0 +1,5 @@
+-- Remove answer feedback and the chat model of messages
+
+ALTER TABLE messages DROP COLUMN IF EXISTS chat_model_id;
+DROP TABLE IF EXISTS message_feedback_references;
+DROP TABLE IF EXISTS message_feedbacks;
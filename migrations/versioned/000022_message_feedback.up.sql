@@ -0,0 +1,54 @@
+-- Migration: 000022_message_feedback
+-- Description: Feedback of users on answers and the chat model of the answers, for retrieval-quality analytics
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000022] Creating message_feedbacks tables...'; END $$;
+
+CREATE TABLE IF NOT EXISTS message_feedbacks (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id INTEGER NOT NULL,
+    session_id VARCHAR(36) NOT NULL,
+    message_id VARCHAR(36) NOT NULL,
+    user_id VARCHAR(36) NOT NULL DEFAULT '',
+    rating VARCHAR(16) NOT NULL DEFAULT '',
+    reason VARCHAR(64) NOT NULL DEFAULT '',
+    comment TEXT NOT NULL DEFAULT '',
+    query TEXT NOT NULL DEFAULT '',
+    answer TEXT NOT NULL DEFAULT '',
+    chat_model_id VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_message_feedbacks_message_user ON message_feedbacks(tenant_id, message_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_message_feedbacks_tenant_created ON message_feedbacks(tenant_id, created_at);
+
+COMMENT ON TABLE message_feedbacks IS 'Feedback of users on answers, one per user and assistant message';
+COMMENT ON COLUMN message_feedbacks.rating IS 'up, down, or empty when only references are marked not relevant';
+COMMENT ON COLUMN message_feedbacks.query IS 'Question of the user, copied when rating';
+COMMENT ON COLUMN message_feedbacks.answer IS 'Rated answer, copied when rating';
+
+CREATE TABLE IF NOT EXISTS message_feedback_references (
+    id BIGSERIAL PRIMARY KEY,
+    feedback_id BIGINT NOT NULL REFERENCES message_feedbacks(id) ON DELETE CASCADE,
+    tenant_id INTEGER NOT NULL,
+    chunk_id VARCHAR(255) NOT NULL DEFAULT '',
+    knowledge_id VARCHAR(36) NOT NULL DEFAULT '',
+    knowledge_base_id VARCHAR(36) NOT NULL DEFAULT '',
+    knowledge_title TEXT NOT NULL DEFAULT '',
+    content TEXT NOT NULL DEFAULT '',
+    irrelevant BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_feedback_references_feedback ON message_feedback_references(feedback_id);
+CREATE INDEX IF NOT EXISTS idx_message_feedback_references_chunk ON message_feedback_references(tenant_id, chunk_id);
+
+COMMENT ON TABLE message_feedback_references IS 'References of the rated answers, copied when rating';
+COMMENT ON COLUMN message_feedback_references.irrelevant IS 'Whether the user marked the reference as not relevant to the question';
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000022] Adding chat_model_id to messages...'; END $$;
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS chat_model_id VARCHAR(64) NOT NULL DEFAULT '';
+
+COMMENT ON COLUMN messages.chat_model_id IS 'Chat model which generated the answer (for assistant messages)';
+
+DO $$ BEGIN RAISE NOTICE '[Migration 000022] message_feedbacks tables created'; END $$;
@@ -0,0 +1,3 @@
+-- Remove attachments column from messages table
+
+ALTER TABLE messages DROP COLUMN IF EXISTS attachments;
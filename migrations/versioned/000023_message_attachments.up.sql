@@ -0,0 +1,7 @@
+-- Add attachments column to messages table
+-- This column stores the images and documents attached to user questions
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS attachments JSONB DEFAULT '[]';
+
+-- Add comment for the column
+COMMENT ON COLUMN messages.attachments IS 'Images and documents attached to the question (type, name, stored file path, caption or parsed text)';